@@ -33,8 +33,11 @@ import (
 
 var (
 	createOptions = struct {
-		SourceKeyspace string
-		TableSettings  tableSettings
+		SourceKeyspace      string
+		TableSettings       tableSettings
+		Sink                string
+		SourceStream        string
+		EvaluateExpressions bool
 	}{}
 
 	// create makes a MaterializeCreate gRPC call to a vtctld.
@@ -68,6 +71,11 @@ should be copied as-is from the source keyspace. Here's an example value for tab
     "create_ddl": "create table sales_by_sku (sku varbinary(128) not null primary key, orders bigint, revenue bigint)"
   }
 ]
+
+The rows can be applied to a sink instead of the target tables with the sink flag. The sinks are URLs:
+file:///path/to/dir appends the changes to newline-delimited JSON files, parquet:///path/to/dir writes
+them to Parquet files, http(s)://host/path posts them to a webhook, and stream://name feeds the stream
+read by another workflow of the target keyspace, created with the source-stream flag.
 `,
 		SilenceUsage:          true,
 		DisableFlagsInUseLine: true,
@@ -100,6 +108,9 @@ func commandCreate(cmd *cobra.Command, args []string) error {
 		Cell:                      strings.Join(common.CreateOptions.Cells, ","),
 		TabletTypes:               topoproto.MakeStringTypeCSV(common.CreateOptions.TabletTypes),
 		TabletSelectionPreference: tsp,
+		Sink:                      createOptions.Sink,
+		SourceStream:              createOptions.SourceStream,
+		EvaluateExpressions:       createOptions.EvaluateExpressions,
 	}
 
 	req := &vtctldatapb.MaterializeCreateRequest{
@@ -46,6 +46,9 @@ func registerCommands(root *cobra.Command) {
 	create.Flags().Var(&createOptions.TableSettings, "table-settings", "A JSON array defining what tables to materialize using what select statements. See the --help output for more details.")
 	create.MarkFlagRequired("table-settings")
 	create.Flags().BoolVar(&common.CreateOptions.StopAfterCopy, "stop-after-copy", false, "Stop the workflow after it's finished copying the existing rows and before it starts replicating changes.")
+	create.Flags().StringVar(&createOptions.Sink, "sink", "", "URL of the sink the rows are applied to instead of the target tables. See the --help output for more details.")
+	create.Flags().StringVar(&createOptions.SourceStream, "source-stream", "", "Name of the stream the rows are read from instead of the source keyspace, fed by a workflow of the target keyspace with the stream://<name> sink. The source keyspace must be the target keyspace.")
+	create.Flags().BoolVar(&createOptions.EvaluateExpressions, "evaluate-expressions", false, "Compute the expressions of the select statements of the table settings in vttablet with the evalengine, in UTC and with the binary collation like the statements applied to the target, rather than in those statements.")
 	base.AddCommand(create)
 
 	// Generic workflow commands.
@@ -56,6 +56,8 @@ func registerReshardCommands(root *cobra.Command) {
 	common.AddCommonSwitchTrafficFlags(reverseTrafficCommand, false)
 	reshard.AddCommand(reverseTrafficCommand)
 
+	registerRollbackCommand(reshard)
+
 	reshard.AddCommand(common.GetCompleteCommand(opts))
 	reshard.AddCommand(common.GetCancelCommand(opts))
 }
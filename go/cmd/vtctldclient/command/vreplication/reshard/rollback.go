@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reshard
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/cmd/vtctldclient/command/vreplication/common"
+	"vitess.io/vitess/go/protoutil"
+
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	reshardRollbackOptions = struct {
+		timeout                  time.Duration
+		maxReplicationLagAllowed time.Duration
+		skipVDiff                bool
+		waitForVDiff             bool
+	}{}
+
+	// reshardRollback makes a ReshardRollback gRPC call to a vtctld.
+	reshardRollback = &cobra.Command{
+		Use:   "rollback",
+		Short: "Switch the traffic of a Reshard VReplication workflow back to the original shards, restart the workflow and verify it with a VDiff.",
+		Long: `Switch the reads and writes of a Reshard VReplication workflow back to the original shards, restart the workflow and verify it with a VDiff.
+The rollback is resumed by running it again if it fails part way through, and it does nothing once it has completed.
+Each step is reported as done or skipped, and a rollback which fails reports the step at which it stopped.`,
+		Example:               `vtctldclient --server localhost:15999 reshard --workflow customer2customer --target-keyspace customer rollback --wait-for-vdiff`,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Rollback"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandReshardRollback,
+	}
+)
+
+func commandReshardRollback(cmd *cobra.Command, args []string) error {
+	format, err := common.GetOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	cli.FinishedParsing(cmd)
+
+	req := &vtctldatapb.ReshardRollbackRequest{
+		Keyspace:                 common.BaseOptions.TargetKeyspace,
+		Workflow:                 common.BaseOptions.Workflow,
+		Timeout:                  protoutil.DurationToProto(reshardRollbackOptions.timeout),
+		MaxReplicationLagAllowed: protoutil.DurationToProto(reshardRollbackOptions.maxReplicationLagAllowed),
+		SkipVdiff:                reshardRollbackOptions.skipVDiff,
+		WaitForVdiff:             reshardRollbackOptions.waitForVDiff,
+	}
+	resp, err := common.GetClient().ReshardRollback(common.GetCommandCtx(), req)
+	if resp != nil {
+		// A rollback which stopped part way through reports the steps it did before the error.
+		output, ferr := formatRollbackResponse(resp, format)
+		if ferr != nil {
+			return ferr
+		}
+		fmt.Printf("%s\n", output)
+	}
+	return err
+}
+
+func formatRollbackResponse(resp *vtctldatapb.ReshardRollbackResponse, format string) ([]byte, error) {
+	if format == "json" {
+		return cli.MarshalJSONPretty(resp)
+	}
+	tout := bytes.Buffer{}
+	tout.WriteString(resp.Summary + "\n\n")
+	for _, step := range resp.Steps {
+		tout.WriteString(fmt.Sprintf("%s: %s\n", step.Name, step.Message))
+	}
+	if resp.VdiffUuid != "" {
+		tout.WriteString(fmt.Sprintf("VDiff: %s\n", resp.VdiffUuid))
+	}
+	tout.WriteString(fmt.Sprintf("\nStart State: %s\n", resp.StartState))
+	tout.WriteString(fmt.Sprintf("Current State: %s\n", resp.CurrentState))
+	return tout.Bytes(), nil
+}
+
+func registerRollbackCommand(root *cobra.Command) {
+	reshardRollback.Flags().DurationVar(&reshardRollbackOptions.timeout, "timeout", common.TimeoutDefault, "Specifies the maximum time to wait for the reverse workflow to catch up when the writes are switched back.")
+	reshardRollback.Flags().DurationVar(&reshardRollbackOptions.maxReplicationLagAllowed, "max-replication-lag-allowed", common.MaxReplicationLagDefault, "Allow the writes to be switched back only if the lag of the reverse workflow is below this.")
+	reshardRollback.Flags().BoolVar(&reshardRollbackOptions.skipVDiff, "skip-vdiff", false, "Do not verify the restarted workflow with a VDiff.")
+	reshardRollback.Flags().BoolVar(&reshardRollbackOptions.waitForVDiff, "wait-for-vdiff", false, "Wait for the VDiff verifying the restarted workflow to complete, and fail if it finds differences.")
+	root.AddCommand(reshardRollback)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reshard
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+func TestFormatRollbackResponse(t *testing.T) {
+	resp := &vtctldatapb.ReshardRollbackResponse{
+		Summary:      "Rollback was successful for workflow ks.wf",
+		StartState:   "Reads switched. Writes switched",
+		CurrentState: "Reads Not Switched. Writes Not Switched",
+		Steps: []*vtctldatapb.ReshardRollbackResponse_Step{
+			{Name: "ValidateReverseWorkflow", Message: "reverse workflow wf_reverse is caught up"},
+			{Name: "StartWorkflow", Skipped: true, Message: "the streams of workflow wf are running"},
+		},
+		VdiffUuid: "5f4bc4ba-83b6-5d1c-a44b-7e0b6c9d4e5f",
+	}
+
+	output, err := formatRollbackResponse(resp, "text")
+	require.NoError(t, err)
+	require.Equal(t, `Rollback was successful for workflow ks.wf
+
+ValidateReverseWorkflow: reverse workflow wf_reverse is caught up
+StartWorkflow: the streams of workflow wf are running
+VDiff: 5f4bc4ba-83b6-5d1c-a44b-7e0b6c9d4e5f
+
+Start State: Reads switched. Writes switched
+Current State: Reads Not Switched. Writes Not Switched
+`, string(output))
+
+	output, err = formatRollbackResponse(resp, "json")
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(output, &decoded))
+	require.Equal(t, resp.VdiffUuid, decoded["vdiff_uuid"])
+	require.Len(t, decoded["steps"], 2)
+}
@@ -66,6 +66,9 @@ var (
 		Wait                        bool
 		WaitUpdateInterval          time.Duration
 		AutoRetry                   bool
+		Recheck                     bool
+		ScheduleInterval            time.Duration
+		Incremental                 bool
 	}{}
 
 	deleteOptions = struct {
@@ -112,6 +115,12 @@ var (
 				createOptions.Tables[i] = strings.TrimSpace(table)
 			}
 		}
+		if createOptions.ScheduleInterval < 0 || (createOptions.ScheduleInterval > 0 && createOptions.ScheduleInterval < time.Second) {
+			return fmt.Errorf("invalid --schedule-interval of %v, it must be at least one second", createOptions.ScheduleInterval)
+		}
+		if createOptions.Incremental && createOptions.ScheduleInterval == 0 {
+			return fmt.Errorf("--incremental requires a --schedule-interval")
+		}
 		return nil
 	}
 
@@ -279,6 +288,9 @@ func commandCreate(cmd *cobra.Command, args []string) error {
 		Wait:                        createOptions.Wait,
 		WaitUpdateInterval:          protoutil.DurationToProto(createOptions.WaitUpdateInterval),
 		AutoRetry:                   createOptions.AutoRetry,
+		Recheck:                     createOptions.Recheck,
+		ScheduleInterval:            protoutil.DurationToProto(createOptions.ScheduleInterval),
+		Incremental:                 createOptions.Incremental,
 	})
 
 	if err != nil {
@@ -869,6 +881,9 @@ func registerCommands(root *cobra.Command) {
 	create.Flags().BoolVar(&createOptions.Wait, "wait", false, "When creating or resuming a vdiff, wait for it to finish before exiting.")
 	create.Flags().DurationVar(&createOptions.WaitUpdateInterval, "wait-update-interval", time.Duration(1*time.Minute), "When waiting on a vdiff to finish, check and display the current status this often.")
 	create.Flags().BoolVar(&createOptions.AutoRetry, "auto-retry", true, "Should this vdiff automatically retry and continue in case of recoverable errors.")
+	create.Flags().BoolVar(&createOptions.Recheck, "recheck", false, "Re-check the rows which differ once the target has caught up with the source, and only report the rows which still differ.")
+	create.Flags().DurationVar(&createOptions.ScheduleInterval, "schedule-interval", 0, "Run the vdiff again this long after each run completes, to keep the workflow continuously validated.")
+	create.Flags().BoolVar(&createOptions.Incremental, "incremental", false, "In the scheduled runs, only compare the rows changed on the source since the last run which found no differences in the table; requires --schedule-interval.")
 	create.Flags().BoolVar(&createOptions.UpdateTableStats, "update-table-stats", false, "Update the table statistics, using ANALYZE TABLE, on each table involved in the VDiff during initialization. This will ensure that progress estimates are as accurate as possible -- but it does involve locks and can potentially impact query processing on the target keyspace.")
 	base.AddCommand(create)
 
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 
 	"vitess.io/vitess/go/sqltypes"
@@ -410,6 +411,36 @@ func TestVDiffSharded(t *testing.T) {
 	}
 }
 
+func TestParseAndValidateCreate(t *testing.T) {
+	registerCommands(&cobra.Command{})
+	defer func() {
+		createOptions.ScheduleInterval = 0
+		createOptions.Incremental = false
+	}()
+
+	tests := []struct {
+		scheduleInterval time.Duration
+		incremental      bool
+		wantErr          string
+	}{
+		{},
+		{scheduleInterval: time.Hour, incremental: true},
+		{scheduleInterval: time.Millisecond, wantErr: "invalid --schedule-interval of 1ms, it must be at least one second"},
+		{scheduleInterval: -time.Hour, wantErr: "invalid --schedule-interval of -1h0m0s, it must be at least one second"},
+		{incremental: true, wantErr: "--incremental requires a --schedule-interval"},
+	}
+	for _, tt := range tests {
+		createOptions.ScheduleInterval = tt.scheduleInterval
+		createOptions.Incremental = tt.incremental
+		err := parseAndValidateCreate(create, nil)
+		if tt.wantErr != "" {
+			require.EqualError(t, err, tt.wantErr)
+			continue
+		}
+		require.NoError(t, err)
+	}
+}
+
 func TestGetStructNames(t *testing.T) {
 	type s struct {
 		A string
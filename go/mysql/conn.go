@@ -226,6 +226,14 @@ type PrepareData struct {
 	BindVars    map[string]*querypb.BindVariable
 	StatementID uint32
 	ParamsCount uint16
+
+	// ClientData is a place where the handler can store any
+	// data related to the prepared statement, like its plan.
+	ClientData any
+
+	// cursor is the read-only cursor opened by the last execution
+	// of the statement, until all its rows are fetched.
+	cursor *cursor
 }
 
 // execResult is an enum signifying the result of executing a query
@@ -967,10 +975,15 @@ func (c *Conn) handleNextCommand(handler Handler) bool {
 		stmtID, ok := c.parseComStmtClose(data)
 		c.recycleReadPacket()
 		if ok {
+			if prepare, ok := c.PrepareData[stmtID]; ok {
+				prepare.closeCursor()
+			}
 			delete(c.PrepareData, stmtID)
 		}
 	case ComStmtReset:
 		return c.handleComStmtReset(data)
+	case ComStmtFetch:
+		return c.handleComStmtFetch(handler, data)
 	case ComResetConnection:
 		c.handleComResetConnection(handler)
 		return true
@@ -1067,6 +1080,7 @@ func (c *Conn) handleComResetConnection(handler Handler) {
 	c.recycleReadPacket()
 	handler.ComResetConnection(c)
 	// Reset prepared statements
+	c.closeCursors()
 	c.PrepareData = make(map[uint32]*PrepareData)
 	err := c.writeOKPacket(&PacketOK{})
 	if err != nil {
@@ -1079,24 +1093,22 @@ func (c *Conn) handleComStmtReset(data []byte) bool {
 	c.recycleReadPacket()
 	if !ok {
 		log.Error("Got unhandled packet from client %v, returning error: %v", c.ConnectionID, data)
-		if !c.writeErrorAndLog(sqlerror.ERUnknownComError, sqlerror.SSNetError, "error handling packet: %v", data) {
-			return false
-		}
+		return c.writeErrorAndLog(sqlerror.ERUnknownComError, sqlerror.SSNetError, "error handling packet: %v", data)
 	}
 
 	prepare, ok := c.PrepareData[stmtID]
 	if !ok {
 		log.Error("Commands were executed in an improper order from client %v, packet: %v", c.ConnectionID, data)
-		if !c.writeErrorAndLog(sqlerror.CRCommandsOutOfSync, sqlerror.SSNetError, "commands were executed in an improper order: %v", data) {
-			return false
-		}
+		return c.writeErrorAndLog(sqlerror.CRCommandsOutOfSync, sqlerror.SSNetError, "commands were executed in an improper order: %v", data)
 	}
 
+	// Resetting a statement discards the data sent with COM_STMT_SEND_LONG_DATA, and closes its cursor.
 	if prepare.BindVars != nil {
 		for k := range prepare.BindVars {
 			prepare.BindVars[k] = nil
 		}
 	}
+	prepare.closeCursor()
 
 	if err := c.writeOKPacket(&PacketOK{statusFlags: c.StatusFlags}); err != nil {
 		log.Error("Error writing ComStmtReset OK packet to client %v: %v", c.ConnectionID, err)
@@ -1144,7 +1156,7 @@ func (c *Conn) handleComStmtExecute(handler Handler, data []byte) (kontinue bool
 		}
 	}()
 	queryStart := time.Now()
-	stmtID, _, err := c.parseComStmtExecute(c.PrepareData, data)
+	stmtID, cursorType, err := c.parseComStmtExecute(c.PrepareData, data)
 	c.recycleReadPacket()
 
 	if stmtID != uint32(0) {
@@ -1159,10 +1171,16 @@ func (c *Conn) handleComStmtExecute(handler Handler, data []byte) (kontinue bool
 		return c.writeErrorPacketFromErrorAndLog(err)
 	}
 
+	// Executing the statement again closes the cursor of its previous execution.
+	prepare := c.PrepareData[stmtID]
+	prepare.closeCursor()
+	if cursorType&CursorTypeReadOnly != 0 {
+		return c.handleComStmtExecuteCursor(handler, prepare, queryStart)
+	}
+
 	fieldSent := false
 	// sendFinished is set if the response should just be an OK packet.
 	sendFinished := false
-	prepare := c.PrepareData[stmtID]
 	err = handler.ComStmtExecute(c, prepare, func(qr *sqltypes.Result) error {
 		if sendFinished {
 			// Failsafe: Unreachable if server is well-behaved.
@@ -1263,9 +1281,7 @@ func (c *Conn) handleComPrepare(handler Handler, data []byte) (kontinue bool) {
 	statement, err := sqlparser.ParseStrictDDL(query)
 	if err != nil {
 		log.Errorf("Conn %v: Error parsing prepared statement: %v", c, err)
-		if !c.writeErrorPacketFromErrorAndLog(err) {
-			return false
-		}
+		return c.writeErrorPacketFromErrorAndLog(err)
 	}
 
 	paramsCount := uint16(0)
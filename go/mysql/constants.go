@@ -246,6 +246,9 @@ const (
 	// https://dev.mysql.com/doc/internals/en/com-register-slave.html
 	ComRegisterReplica = 0x15
 
+	// CursorTypeReadOnly is the CURSOR_TYPE_READ_ONLY flag of COM_STMT_EXECUTE.
+	CursorTypeReadOnly = 0x01
+
 	// OKPacket is the header of the OK packet.
 	OKPacket = 0x00
 
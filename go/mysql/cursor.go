@@ -0,0 +1,300 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"vitess.io/vitess/go/mysql/sqlerror"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/tb"
+	"vitess.io/vitess/go/vt/log"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// Read-only cursors: a COM_STMT_EXECUTE with the CURSOR_TYPE_READ_ONLY flag only sends the fields
+// of the result, and the client then fetches its rows with COM_STMT_FETCH. If the handler implements
+// CursorHandler, the rows are streamed from the execution as they are fetched, otherwise the
+// result is buffered in the cursor.
+
+// CursorHandler is implemented by the handlers which can stream the rows of the read-only cursors.
+type CursorHandler interface {
+	// ComStmtExecuteCursor is called when a connection receives a statement
+	// execute query opening a read-only cursor. It returns the function executing
+	// the statement, which runs in its own goroutine, concurrently with the next
+	// commands of the connection: its callback blocks until the rows are fetched,
+	// and returns an error once the cursor is closed. The function cannot use
+	// the prepare data, which is reset after the call.
+	// It returns nil if the statement cannot run concurrently with the
+	// connection, in which case ComStmtExecute is called and its result buffered.
+	ComStmtExecuteCursor(c *Conn, prepare *PrepareData) func(callback func(*sqltypes.Result) error) error
+}
+
+var errCursorClosed = errors.New("cursor closed")
+
+// cursor holds the rows of a result until they are fetched.
+type cursor struct {
+	fields []*querypb.Field
+	rows   [][]sqltypes.Value
+
+	// results, done and finished are set when the rows are streamed: the
+	// execution sends its results on results, and stops once done is closed.
+	// err is the error of the execution, set before results and finished are closed.
+	results  chan *sqltypes.Result
+	done     chan struct{}
+	finished chan struct{}
+	err      error
+}
+
+// streamCursor starts the execution of a statement whose rows are streamed to the cursor.
+func streamCursor(execute func(callback func(*sqltypes.Result) error) error) *cursor {
+	cur := &cursor{
+		results:  make(chan *sqltypes.Result),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	go func() {
+		defer close(cur.finished)
+		defer close(cur.results)
+		defer func() {
+			if x := recover(); x != nil {
+				log.Errorf("mysql_server caught panic in cursor:\n%v\n%s", x, tb.Stack(4))
+				cur.err = fmt.Errorf("panic in cursor: %v", x)
+			}
+		}()
+		cur.err = execute(func(qr *sqltypes.Result) error {
+			select {
+			case cur.results <- qr:
+				return nil
+			case <-cur.done:
+				return errCursorClosed
+			}
+		})
+	}()
+	return cur
+}
+
+// first returns the first result of the execution, which has its fields.
+func (cur *cursor) first() (*sqltypes.Result, error) {
+	qr, ok := <-cur.results
+	if !ok {
+		cur.results = nil
+		return nil, cur.err
+	}
+	return qr, nil
+}
+
+// fill waits for the next rows of the execution if the cursor has none left.
+// The cursor has no rows after it if the result is exhausted.
+func (cur *cursor) fill() error {
+	for len(cur.rows) == 0 && cur.results != nil {
+		qr, ok := <-cur.results
+		if !ok {
+			cur.results = nil
+			return cur.err
+		}
+		cur.rows = qr.Rows
+	}
+	return nil
+}
+
+// close stops the execution streaming the rows, and waits for it to return.
+func (cur *cursor) close() {
+	if cur.results == nil {
+		return
+	}
+	close(cur.done)
+	<-cur.finished
+	cur.results = nil
+}
+
+// closeCursor closes the cursor of the statement, if it has one open.
+func (prepare *PrepareData) closeCursor() {
+	if prepare.cursor != nil {
+		prepare.cursor.close()
+		prepare.cursor = nil
+	}
+}
+
+// closeCursors closes the cursors of all the prepared statements of the connection.
+func (c *Conn) closeCursors() {
+	for _, prepare := range c.PrepareData {
+		prepare.closeCursor()
+	}
+}
+
+// bufferResult executes the statement with ComStmtExecute, and returns its whole result.
+func bufferResult(c *Conn, handler Handler, prepare *PrepareData) (*sqltypes.Result, error) {
+	var result *sqltypes.Result
+	err := handler.ComStmtExecute(c, prepare, func(qr *sqltypes.Result) error {
+		if result == nil {
+			result = qr.ShallowCopy()
+			return nil
+		}
+		result.Rows = append(result.Rows, qr.Rows...)
+		return nil
+	})
+	return result, err
+}
+
+// handleComStmtExecuteCursor executes a statement opening a read-only cursor. Only the fields of the
+// result are sent, and the cursor keeps its rows until they are fetched. A statement without a result
+// set is answered with an OK packet, as without a cursor.
+func (c *Conn) handleComStmtExecuteCursor(handler Handler, prepare *PrepareData, queryStart time.Time) bool {
+	var cur *cursor
+	var qr *sqltypes.Result
+	var err error
+	if ch, ok := handler.(CursorHandler); ok {
+		if execute := ch.ComStmtExecuteCursor(c, prepare); execute != nil {
+			cur = streamCursor(execute)
+			qr, err = cur.first()
+		}
+	}
+	if cur == nil {
+		cur = &cursor{}
+		qr, err = bufferResult(c, handler, prepare)
+	}
+
+	if err == nil && qr == nil {
+		// This is just a failsafe. Should never happen.
+		err = sqlerror.NewSQLErrorFromError(errors.New("unexpected: query ended without no results and no error"))
+	}
+	if err != nil {
+		cur.close()
+		return c.writeErrorPacketFromErrorAndLog(err)
+	}
+
+	if len(qr.Fields) == 0 {
+		cur.close()
+		ok := PacketOK{
+			affectedRows:     qr.RowsAffected,
+			lastInsertID:     qr.InsertID,
+			statusFlags:      c.StatusFlags,
+			sessionStateData: qr.SessionStateChanges,
+		}
+		if err := c.writeOKPacket(&ok); err != nil {
+			log.Errorf("Error writing result to %s: %v", c, err)
+			return false
+		}
+		timings.Record(queryTimingKey, queryStart)
+		return true
+	}
+
+	cur.fields = qr.Fields
+	cur.rows = qr.Rows
+	prepare.cursor = cur
+	if err := c.writeCursorFields(qr); err != nil {
+		log.Errorf("Error writing fields to %s: %v", c, err)
+		return false
+	}
+	timings.Record(queryTimingKey, queryStart)
+	return true
+}
+
+// handleComStmtFetch sends the next rows of the cursor of a statement. The rows are followed by the end
+// of the result, whose status tells the client if rows are left. The cursor is closed once it has no rows left.
+func (c *Conn) handleComStmtFetch(handler Handler, data []byte) (kontinue bool) {
+	c.startWriterBuffering()
+	defer func() {
+		if err := c.endWriterBuffering(); err != nil {
+			log.Errorf("conn %v: flush() failed: %v", c.ID(), err)
+			kontinue = false
+		}
+	}()
+
+	stmtID, numRows, ok := c.parseComStmtFetch(data)
+	c.recycleReadPacket()
+	if !ok {
+		log.Errorf("Got unhandled packet from client %v, returning error: %v", c.ConnectionID, data)
+		return c.writeErrorAndLog(sqlerror.ERUnknownComError, sqlerror.SSNetError, "error handling packet: %v", data)
+	}
+
+	prepare, ok := c.PrepareData[stmtID]
+	if !ok {
+		return c.writeErrorAndLog(sqlerror.ERUnknownStmtHandler, sqlerror.SSUnknownSQLState, "unknown prepared statement handler (%v) given to mysqld_stmt_fetch", stmtID)
+	}
+	cur := prepare.cursor
+	if cur == nil {
+		return c.writeErrorAndLog(sqlerror.ERStmtHasNoOpenCursor, sqlerror.SSUnknownSQLState, "the statement (%v) has no open cursor", stmtID)
+	}
+
+	for sent := uint32(0); ; sent++ {
+		// The rows are read ahead of the last one sent, to know if it is the last row of the result.
+		if err := cur.fill(); err != nil {
+			// The error ends the result, after the rows already sent.
+			prepare.closeCursor()
+			return c.writeErrorPacketFromErrorAndLog(err)
+		}
+		if sent == numRows || len(cur.rows) == 0 {
+			break
+		}
+		if err := c.writeBinaryRow(cur.fields, cur.rows[0]); err != nil {
+			log.Errorf("Error writing row to %s: %v", c, err)
+			return false
+		}
+		cur.rows = cur.rows[1:]
+	}
+
+	flags := ServerStatusCursorExists
+	if len(cur.rows) == 0 {
+		flags = ServerStatusLastRowSent
+		prepare.closeCursor()
+	}
+	if err := c.writeCursorEndResult(flags, handler.WarningCount(c)); err != nil {
+		log.Errorf("Error writing result to %s: %v", c, err)
+		return false
+	}
+	return true
+}
+
+func (c *Conn) parseComStmtFetch(data []byte) (uint32, uint32, bool) {
+	stmtID, pos, ok := readUint32(data, 1)
+	if !ok {
+		return 0, 0, false
+	}
+	numRows, _, ok := readUint32(data, pos)
+	return stmtID, numRows, ok
+}
+
+// writeCursorFields writes the fields of the result of a cursor, which are
+// followed by the end of the result since the rows are sent when fetched.
+func (c *Conn) writeCursorFields(result *sqltypes.Result) error {
+	if err := c.sendColumnCount(uint64(len(result.Fields))); err != nil {
+		return err
+	}
+	for _, field := range result.Fields {
+		if err := c.writeColumnDefinition(field); err != nil {
+			return err
+		}
+	}
+	return c.writeCursorEndResult(ServerStatusCursorExists, 0)
+}
+
+// writeCursorEndResult ends a response for a cursor with the status of the cursor.
+func (c *Conn) writeCursorEndResult(cursorStatus uint16, warnings uint16) error {
+	flags := c.StatusFlags | cursorStatus
+	if c.Capabilities&CapabilityClientDeprecateEOF == 0 {
+		return c.writeEOFPacket(flags, warnings)
+	}
+	return c.writeOKPacketWithEOFHeader(&PacketOK{
+		statusFlags: flags,
+		warnings:    warnings,
+	})
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/mysql/sqlerror"
+	"vitess.io/vitess/go/sqltypes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// cursorHandler executes the statements by sending its results to the callback.
+type cursorHandler struct {
+	UnimplementedHandler
+	results []*sqltypes.Result
+}
+
+func (h *cursorHandler) ComQuery(c *Conn, query string, callback func(*sqltypes.Result) error) error {
+	panic("implement me")
+}
+
+func (h *cursorHandler) ComPrepare(c *Conn, query string, bindVars map[string]*querypb.BindVariable) ([]*querypb.Field, error) {
+	panic("implement me")
+}
+
+func (h *cursorHandler) ComStmtExecute(c *Conn, prepare *PrepareData, callback func(*sqltypes.Result) error) error {
+	return h.execute(callback)
+}
+
+func (h *cursorHandler) execute(callback func(*sqltypes.Result) error) error {
+	for _, qr := range h.results {
+		if err := callback(qr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *cursorHandler) ComRegisterReplica(c *Conn, replicaHost string, replicaPort uint16, replicaUser string, replicaPassword string) error {
+	panic("implement me")
+}
+
+func (h *cursorHandler) ComBinlogDump(c *Conn, logFile string, binlogPos uint32) error {
+	panic("implement me")
+}
+
+func (h *cursorHandler) ComBinlogDumpGTID(c *Conn, logFile string, logPos uint64, gtidSet replication.GTIDSet) error {
+	panic("implement me")
+}
+
+func (h *cursorHandler) WarningCount(c *Conn) uint16 {
+	return 0
+}
+
+// streamingCursorHandler streams the rows of the cursors, and reports the errors of their executions.
+type streamingCursorHandler struct {
+	cursorHandler
+	executed chan error
+}
+
+func (h *streamingCursorHandler) ComStmtExecuteCursor(c *Conn, prepare *PrepareData) func(callback func(*sqltypes.Result) error) error {
+	return func(callback func(*sqltypes.Result) error) error {
+		err := h.execute(callback)
+		h.executed <- err
+		return err
+	}
+}
+
+var cursorResults = []*sqltypes.Result{{
+	Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT64}},
+}, {
+	Rows: [][]sqltypes.Value{{sqltypes.NewInt64(1)}, {sqltypes.NewInt64(2)}},
+}, {
+	Rows: [][]sqltypes.Value{{sqltypes.NewInt64(3)}},
+}}
+
+func writeCommand(t *testing.T, cConn *Conn, command byte, args ...uint32) {
+	t.Helper()
+	packet := make([]byte, packetHeaderSize, packetHeaderSize+1+4*len(args))
+	packet = append(packet, command)
+	for _, arg := range args {
+		packet = binary.LittleEndian.AppendUint32(packet, arg)
+	}
+	cConn.sequence = 0
+	require.NoError(t, cConn.writePacket(packet))
+}
+
+// writeExecuteWithCursor sends a COM_STMT_EXECUTE opening a read-only cursor.
+func writeExecuteWithCursor(t *testing.T, cConn *Conn, stmtID uint32) {
+	t.Helper()
+	packet := make([]byte, packetHeaderSize)
+	packet = append(packet, ComStmtExecute)
+	packet = binary.LittleEndian.AppendUint32(packet, stmtID)
+	packet = append(packet, CursorTypeReadOnly)
+	packet = binary.LittleEndian.AppendUint32(packet, 1) // iteration count
+	cConn.sequence = 0
+	require.NoError(t, cConn.writePacket(packet))
+}
+
+// readCursorStatus reads the EOF packet ending a cursor response, and returns its status flags.
+func readCursorStatus(t *testing.T, cConn *Conn) uint16 {
+	t.Helper()
+	data, err := cConn.ReadPacket()
+	require.NoError(t, err)
+	require.True(t, cConn.isEOFPacket(data), "expected EOF packet, got %v", data)
+	_, status, err := parseEOFPacket(data)
+	require.NoError(t, err)
+	return status
+}
+
+// readBinaryRows reads the binary rows of a fetch, with a single BIGINT column, and the EOF packet ending them.
+func readBinaryRows(t *testing.T, cConn *Conn) ([]int64, uint16) {
+	t.Helper()
+	var rows []int64
+	for {
+		data, err := cConn.ReadPacket()
+		require.NoError(t, err)
+		if cConn.isEOFPacket(data) {
+			_, status, err := parseEOFPacket(data)
+			require.NoError(t, err)
+			return rows, status
+		}
+		require.EqualValues(t, OKPacket, data[0], "expected binary row, got %v", data)
+		rows = append(rows, int64(binary.LittleEndian.Uint64(data[len(data)-8:])))
+	}
+}
+
+func testCursor(t *testing.T, handler Handler) {
+	listener, sConn, cConn := createSocketPair(t)
+	defer func() {
+		listener.Close()
+		sConn.Close()
+		cConn.Close()
+	}()
+	sConn.PrepareData[1] = &PrepareData{StatementID: 1, PrepareStmt: "select id from t"}
+
+	writeExecuteWithCursor(t, cConn, 1)
+	require.True(t, sConn.handleNextCommand(handler))
+	data, err := cConn.ReadPacket()
+	require.NoError(t, err)
+	assert.EqualValues(t, []byte{1}, data, "column count")
+	_, err = cConn.ReadPacket()
+	require.NoError(t, err)
+	assert.Equal(t, ServerStatusCursorExists, readCursorStatus(t, cConn)&ServerStatusCursorExists)
+
+	writeCommand(t, cConn, ComStmtFetch, 1, 2)
+	require.True(t, sConn.handleNextCommand(handler))
+	rows, status := readBinaryRows(t, cConn)
+	assert.Equal(t, []int64{1, 2}, rows)
+	assert.Equal(t, ServerStatusCursorExists, status&(ServerStatusCursorExists|ServerStatusLastRowSent))
+
+	writeCommand(t, cConn, ComStmtFetch, 1, 10)
+	require.True(t, sConn.handleNextCommand(handler))
+	rows, status = readBinaryRows(t, cConn)
+	assert.Equal(t, []int64{3}, rows)
+	assert.Equal(t, ServerStatusLastRowSent, status&(ServerStatusCursorExists|ServerStatusLastRowSent))
+	assert.Nil(t, sConn.PrepareData[1].cursor)
+
+	// the cursor is closed once all its rows are fetched
+	writeCommand(t, cConn, ComStmtFetch, 1, 10)
+	require.True(t, sConn.handleNextCommand(handler))
+	data, err = cConn.ReadPacket()
+	require.NoError(t, err)
+	sqlErr, ok := ParseErrorPacket(data).(*sqlerror.SQLError)
+	require.True(t, ok)
+	assert.Equal(t, sqlerror.ERStmtHasNoOpenCursor, sqlErr.Number())
+
+	writeCommand(t, cConn, ComStmtFetch, 2, 10)
+	require.True(t, sConn.handleNextCommand(handler))
+	data, err = cConn.ReadPacket()
+	require.NoError(t, err)
+	sqlErr, ok = ParseErrorPacket(data).(*sqlerror.SQLError)
+	require.True(t, ok)
+	assert.Equal(t, sqlerror.ERUnknownStmtHandler, sqlErr.Number())
+}
+
+func TestCursorBuffered(t *testing.T) {
+	testCursor(t, &cursorHandler{results: cursorResults})
+}
+
+func TestCursorStreamed(t *testing.T) {
+	handler := &streamingCursorHandler{cursorHandler: cursorHandler{results: cursorResults}, executed: make(chan error, 1)}
+	testCursor(t, handler)
+	assert.NoError(t, <-handler.executed)
+}
+
+func TestCursorReset(t *testing.T) {
+	listener, sConn, cConn := createSocketPair(t)
+	defer func() {
+		listener.Close()
+		sConn.Close()
+		cConn.Close()
+	}()
+	handler := &streamingCursorHandler{cursorHandler: cursorHandler{results: cursorResults}, executed: make(chan error, 1)}
+	sConn.PrepareData[1] = &PrepareData{StatementID: 1, PrepareStmt: "select id from t"}
+
+	writeExecuteWithCursor(t, cConn, 1)
+	require.True(t, sConn.handleNextCommand(handler))
+	for i := 0; i < 3; i++ {
+		_, err := cConn.ReadPacket()
+		require.NoError(t, err)
+	}
+	writeCommand(t, cConn, ComStmtFetch, 1, 1)
+	require.True(t, sConn.handleNextCommand(handler))
+	rows, _ := readBinaryRows(t, cConn)
+	assert.Equal(t, []int64{1}, rows)
+
+	// resetting the statement stops the execution streaming its rows
+	writeCommand(t, cConn, ComStmtReset, 1)
+	require.True(t, sConn.handleNextCommand(handler))
+	data, err := cConn.ReadPacket()
+	require.NoError(t, err)
+	assert.EqualValues(t, OKPacket, data[0])
+	assert.Nil(t, sConn.PrepareData[1].cursor)
+	assert.ErrorIs(t, <-handler.executed, errCursorClosed)
+
+	// resetting an unknown statement returns an error, and keeps the connection
+	writeCommand(t, cConn, ComStmtReset, 2)
+	require.True(t, sConn.handleNextCommand(handler))
+	data, err = cConn.ReadPacket()
+	require.NoError(t, err)
+	assert.EqualValues(t, ErrPacket, data[0])
+}
+
+func TestCursorWithoutResultSet(t *testing.T) {
+	listener, sConn, cConn := createSocketPair(t)
+	defer func() {
+		listener.Close()
+		sConn.Close()
+		cConn.Close()
+	}()
+	handler := &cursorHandler{results: []*sqltypes.Result{{RowsAffected: 3}}}
+	sConn.PrepareData[1] = &PrepareData{StatementID: 1, PrepareStmt: "update t set a = 1"}
+
+	writeExecuteWithCursor(t, cConn, 1)
+	require.True(t, sConn.handleNextCommand(handler))
+	data, err := cConn.ReadPacket()
+	require.NoError(t, err)
+	require.EqualValues(t, OKPacket, data[0])
+	assert.EqualValues(t, 3, data[1], "affected rows")
+	assert.Nil(t, sConn.PrepareData[1].cursor)
+}
@@ -370,6 +370,8 @@ func (l *Listener) handle(conn net.Conn, connectionID uint32, acceptTime time.Ti
 	// Tell the handler about the connection coming and going.
 	l.handler.NewConnection(c)
 	defer l.handler.ConnectionClosed(c)
+	// Stop the executions streaming the rows of the cursors left open.
+	defer c.closeCursors()
 
 	// Adjust the count of open connections
 	defer connCount.Add(-1)
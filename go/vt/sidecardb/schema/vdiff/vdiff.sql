@@ -29,6 +29,9 @@ CREATE TABLE IF NOT EXISTS vdiff
     `liveness_timestamp` timestamp    NULL     DEFAULT NULL,
     `completed_at`       timestamp    NULL     DEFAULT NULL,
     `last_error`         varbinary(1024)      DEFAULT NULL,
+    `recheck`            tinyint(1)   NOT NULL DEFAULT '0',
+    `schedule_interval_seconds` bigint(20) NOT NULL DEFAULT '0',
+    `incremental`        tinyint(1)   NOT NULL DEFAULT '0',
     PRIMARY KEY (`id`),
     UNIQUE KEY `uuid_idx` (`vdiff_uuid`),
     KEY `state` (`state`),
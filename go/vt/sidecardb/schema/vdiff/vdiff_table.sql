@@ -24,6 +24,7 @@ CREATE TABLE IF NOT EXISTS vdiff_table
     `rows_compared` bigint(20)     NOT NULL DEFAULT '0',
     `mismatch`      tinyint(1)     NOT NULL DEFAULT '0',
     `report`        json                    DEFAULT NULL,
+    `last_verified_pos` json                DEFAULT NULL,
     `created_at`    timestamp      NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at`    timestamp      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`vdiff_id`, `table_name`)
@@ -1917,6 +1917,8 @@ func (ty ShowCommandType) ToString() string {
 		return VGtidExecGlobalStr
 	case VitessMigrations:
 		return VitessMigrationsStr
+	case VitessQueryDigests:
+		return VitessQueryDigestsStr
 	case VitessReplicationStatus:
 		return VitessReplicationStatusStr
 	case VitessShards:
@@ -19,6 +19,7 @@ package sqlparser
 import (
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
@@ -56,6 +57,14 @@ const (
 	// DirectivePriority specifies the priority of a workload. It should be an integer between 0 and MaxPriorityValue,
 	// where 0 is the highest priority, and MaxPriorityValue is the lowest one.
 	DirectivePriority = "PRIORITY"
+	// DirectiveResultCache caches the result of a read-only query in vtgate. The writes of the session
+	// invalidate the cached results of their tables once committed, but the writes made through other
+	// vtgates are only seen once the results are invalidated by the VStream of their keyspace.
+	DirectiveResultCache = "RESULT_CACHE"
+	// DirectiveResultCacheTTL caches the result of a read-only query in vtgate, for the given number of milliseconds.
+	DirectiveResultCacheTTL = "RESULT_CACHE_TTL_MS"
+	// DirectiveHedgedReads enables or disables the hedging of a read-only query on the replica and rdonly tablets.
+	DirectiveHedgedReads = "HEDGED_READS"
 
 	// MaxPriorityValue specifies the maximum value allowed for the priority query directive. Valid priority values are
 	// between zero and MaxPriorityValue.
@@ -403,6 +412,38 @@ func Consolidator(stmt Statement) querypb.ExecuteOptions_Consolidator {
 	return querypb.ExecuteOptions_CONSOLIDATOR_UNSPECIFIED
 }
 
+// ResultCache returns whether the statement asks for its result to be cached by vtgate,
+// and for how long. A zero duration means the default TTL of the cache.
+func ResultCache(stmt Statement) (bool, time.Duration) {
+	sel, ok := stmt.(*Select)
+	if !ok || sel.Comments == nil {
+		return false, 0
+	}
+	directives := sel.Comments.Directives()
+	if val, isSet := directives.GetString(DirectiveResultCacheTTL, ""); isSet {
+		ms, err := strconv.Atoi(val)
+		if err != nil || ms <= 0 {
+			return false, 0
+		}
+		return true, time.Duration(ms) * time.Millisecond
+	}
+	return directives.IsSet(DirectiveResultCache), 0
+}
+
+// HedgedReads returns whether the statement enables or disables the hedging of its queries,
+// and false for isSet if it does not use the directive.
+func HedgedReads(stmt Statement) (enabled bool, isSet bool) {
+	sel, ok := stmt.(*Select)
+	if !ok || sel.Comments == nil {
+		return false, false
+	}
+	directives := sel.Comments.Directives()
+	if _, isSet = directives.GetString(DirectiveHedgedReads, ""); !isSet {
+		return false, false
+	}
+	return directives.IsSet(DirectiveHedgedReads), true
+}
+
 // GetWorkloadNameFromStatement gets the workload name from the provided Statement, using workloadLabel as the name of
 // the query directive that specifies it.
 func GetWorkloadNameFromStatement(statement Statement) string {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -487,6 +488,56 @@ func TestConsolidator(t *testing.T) {
 	}
 }
 
+func TestResultCache(t *testing.T) {
+	testCases := []struct {
+		query    string
+		cached   bool
+		expected time.Duration
+	}{
+		{"select * from users", false, 0},
+		{"select /*vt+ RESULT_CACHE */ * from users", true, 0},
+		{"select /*vt+ RESULT_CACHE=false */ * from users", false, 0},
+		{"select /*vt+ RESULT_CACHE_TTL_MS=1500 */ * from users", true, 1500 * time.Millisecond},
+		{"select /*vt+ RESULT_CACHE_TTL_MS=0 */ * from users", false, 0},
+		{"select /*vt+ RESULT_CACHE_TTL_MS=soon */ * from users", false, 0},
+		{"update /*vt+ RESULT_CACHE */ users set name=1", false, 0},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.query, func(t *testing.T) {
+			stmt, err := Parse(test.query)
+			require.NoError(t, err)
+			cached, ttl := ResultCache(stmt)
+			assert.Equal(t, test.cached, cached)
+			assert.Equal(t, test.expected, ttl)
+		})
+	}
+}
+
+func TestHedgedReads(t *testing.T) {
+	testCases := []struct {
+		query   string
+		enabled bool
+		isSet   bool
+	}{
+		{"select * from users", false, false},
+		{"select /*vt+ HEDGED_READS */ * from users", true, true},
+		{"select /*vt+ HEDGED_READS=1 */ * from users", true, true},
+		{"select /*vt+ HEDGED_READS=false */ * from users", false, true},
+		{"update /*vt+ HEDGED_READS */ users set name=1", false, false},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.query, func(t *testing.T) {
+			stmt, err := Parse(test.query)
+			require.NoError(t, err)
+			enabled, isSet := HedgedReads(stmt)
+			assert.Equal(t, test.enabled, enabled)
+			assert.Equal(t, test.isSet, isSet)
+		})
+	}
+}
+
 func TestGetPriorityFromStatement(t *testing.T) {
 	testCases := []struct {
 		query            string
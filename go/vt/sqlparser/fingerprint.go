@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sqlparser
+
+// Fingerprint returns the fingerprint of the statement: the statement without its comments,
+// where the literals and the bind variables are replaced by ?, and the lists of values by (?).
+// The queries that only differ by their values, or by their normalization, have the same fingerprint.
+func Fingerprint(stmt Statement) string {
+	buf := NewTrackedBuffer(formatFingerprint)
+	buf.Myprintf("%v", stmt)
+	return buf.String()
+}
+
+func formatFingerprint(buf *TrackedBuffer, node SQLNode) {
+	switch node := node.(type) {
+	case *ParsedComments:
+	case *Literal, *Argument:
+		buf.WriteString("?")
+	case ListArg:
+		buf.WriteString("(?)")
+	case ValTuple:
+		for _, expr := range node {
+			switch expr.(type) {
+			case *Literal, *Argument, *NullVal:
+			default:
+				node.Format(buf)
+				return
+			}
+		}
+		buf.WriteString("(?)")
+	default:
+		node.Format(buf)
+	}
+}
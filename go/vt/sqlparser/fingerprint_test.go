@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func TestFingerprint(t *testing.T) {
+	tcases := []struct {
+		in, out string
+	}{{
+		in:  "select a, b from t where x = 1234 and y = 'apple' limit 10",
+		out: "select a, b from t where x = ? and y = ? limit ?",
+	}, {
+		in:  "select /*vt+ QUERY_TIMEOUT_MS=10 */ a from t where x = :x and y = ?",
+		out: "select a from t where x = ? and y = ?",
+	}, {
+		in:  "select a from t where x in (1, 2, 3) and y in (a, 1) and z is null",
+		out: "select a from t where x in (?) and y in (a, ?) and z is null",
+	}, {
+		in:  "insert into t(a, b) values (1, 'x'), (2, null)",
+		out: "insert into t(a, b) values (?), (?)",
+	}, {
+		in:  "update t set a = a + 1 where id = 5",
+		out: "update t set a = a + ? where id = ?",
+	}}
+	for _, tc := range tcases {
+		t.Run(tc.in, func(t *testing.T) {
+			stmt, err := Parse(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.out, Fingerprint(stmt))
+		})
+	}
+}
+
+func TestFingerprintOfNormalizedQuery(t *testing.T) {
+	query := "select a from t where x = 1 and y in (1, 2, 3) and z = 'apple'"
+	stmt, reservedVars, err := Parse2(query)
+	require.NoError(t, err)
+	fingerprint := Fingerprint(stmt)
+
+	err = Normalize(stmt, NewReservedVars("vtg", reservedVars), map[string]*querypb.BindVariable{})
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, Fingerprint(stmt))
+}
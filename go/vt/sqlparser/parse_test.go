@@ -2351,6 +2351,10 @@ var (
 		output: "show keyspaces like '%'",
 	}, {
 		input: "show vitess_metadata variables",
+	}, {
+		input: "show vitess_query_digests",
+	}, {
+		input: "show vitess_query_digests like '%orders%'",
 	}, {
 		input: "show vitess_replication_status",
 	}, {
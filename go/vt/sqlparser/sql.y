@@ -330,7 +330,7 @@ func markBindVariable(yylex yyLexer, bvar string) {
 // SHOW tokens
 %token <str> CODE COLLATION COLUMNS DATABASES ENGINES EVENT EXTENDED FIELDS FULL FUNCTION GTID_EXECUTED
 %token <str> KEYSPACES OPEN PLUGINS PRIVILEGES PROCESSLIST SCHEMAS TABLES TRIGGERS USER
-%token <str> VGTID_EXECUTED VITESS_KEYSPACES VITESS_METADATA VITESS_MIGRATIONS VITESS_REPLICATION_STATUS VITESS_SHARDS VITESS_TABLETS VITESS_TARGET VSCHEMA VITESS_THROTTLED_APPS
+%token <str> VGTID_EXECUTED VITESS_KEYSPACES VITESS_METADATA VITESS_MIGRATIONS VITESS_QUERY_DIGESTS VITESS_REPLICATION_STATUS VITESS_SHARDS VITESS_TABLETS VITESS_TARGET VSCHEMA VITESS_THROTTLED_APPS
 
 // SET tokens
 %token <str> NAMES GLOBAL SESSION ISOLATION LEVEL READ WRITE ONLY REPEATABLE COMMITTED UNCOMMITTED SERIALIZABLE
@@ -4140,6 +4140,10 @@ show_statement:
   {
     $$ = &Show{&ShowBasic{Command: Warnings}}
   }
+| SHOW VITESS_QUERY_DIGESTS like_opt
+  {
+    $$ = &Show{&ShowBasic{Command: VitessQueryDigests, Filter: $3}}
+  }
 | SHOW VITESS_SHARDS like_or_where_opt
   {
     $$ = &Show{&ShowBasic{Command: VitessShards, Filter: $3}}
@@ -8507,6 +8511,7 @@ non_reserved_keyword:
 | VITESS_METADATA
 | VITESS_MIGRATION
 | VITESS_MIGRATIONS
+| VITESS_QUERY_DIGESTS
 | VITESS_REPLICATION_STATUS
 | VITESS_SHARDS
 | VITESS_TABLETS
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package experimental
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/vt/concurrency"
+	vtadminhttp "vitess.io/vitess/go/vt/vtadmin/http"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+
+	vtadminpb "vitess.io/vitess/go/vt/proto/vtadmin"
+)
+
+// QueryDigests fetches the query digests of the vtgates from their
+// /debug/query_digests route, and merges them. The vtgates are looked up via
+// VTAdmin's GetGates rpc, and need a FQDN.
+func QueryDigests(ctx context.Context, r vtadminhttp.Request, api *vtadminhttp.API) *vtadminhttp.JSONResponse {
+	resp, err := api.Server().GetGates(ctx, &vtadminpb.GetGatesRequest{
+		ClusterIds: r.URL.Query()["cluster_id"],
+	})
+	if err != nil {
+		return vtadminhttp.NewJSONResponse(nil, err)
+	}
+
+	var (
+		digests [][]*querydigest.Digest
+		wg      sync.WaitGroup
+		er      concurrency.AllErrorRecorder
+		m       sync.Mutex
+	)
+
+	for _, gate := range resp.Gates {
+		wg.Add(1)
+
+		go func(gate *vtadminpb.VTGate) {
+			defer wg.Done()
+
+			ds, err := getQueryDigests(ctx, gate)
+			if err != nil {
+				er.RecordError(fmt.Errorf("vtgate %s: %w", gate.Hostname, err))
+				return
+			}
+
+			m.Lock()
+			defer m.Unlock()
+
+			digests = append(digests, ds)
+		}(gate)
+	}
+
+	wg.Wait()
+
+	if er.HasErrors() {
+		return vtadminhttp.NewJSONResponse(nil, er.Error())
+	}
+
+	return vtadminhttp.NewJSONResponse(querydigest.Merge(digests...), nil)
+}
+
+func getQueryDigests(ctx context.Context, gate *vtadminpb.VTGate) ([]*querydigest.Digest, error) {
+	if gate.FQDN == "" {
+		return nil, fmt.Errorf("no FQDN to fetch the query digests from")
+	}
+
+	url := gate.FQDN
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/debug/query_digests", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var digests []*querydigest.Digest
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
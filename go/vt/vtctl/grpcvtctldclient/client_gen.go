@@ -695,6 +695,15 @@ func (client *gRPCVtctldClient) ReshardCreate(ctx context.Context, in *vtctldata
 	return client.c.ReshardCreate(ctx, in, opts...)
 }
 
+// ReshardRollback is part of the vtctlservicepb.VtctldClient interface.
+func (client *gRPCVtctldClient) ReshardRollback(ctx context.Context, in *vtctldatapb.ReshardRollbackRequest, opts ...grpc.CallOption) (*vtctldatapb.ReshardRollbackResponse, error) {
+	if client.c == nil {
+		return nil, status.Error(codes.Unavailable, connClosedMsg)
+	}
+
+	return client.c.ReshardRollback(ctx, in, opts...)
+}
+
 // RestoreFromBackup is part of the vtctlservicepb.VtctldClient interface.
 func (client *gRPCVtctldClient) RestoreFromBackup(ctx context.Context, in *vtctldatapb.RestoreFromBackupRequest, opts ...grpc.CallOption) (vtctlservicepb.Vtctld_RestoreFromBackupClient, error) {
 	if client.c == nil {
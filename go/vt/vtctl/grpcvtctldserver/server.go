@@ -3142,6 +3142,23 @@ func (s *VtctldServer) ReshardCreate(ctx context.Context, req *vtctldatapb.Resha
 	resp, err = s.ws.ReshardCreate(ctx, req)
 	return resp, err
 }
+
+// ReshardRollback is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) ReshardRollback(ctx context.Context, req *vtctldatapb.ReshardRollbackRequest) (resp *vtctldatapb.ReshardRollbackResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.ReshardRollback")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("skip_vdiff", req.SkipVdiff)
+	span.Annotate("wait_for_vdiff", req.WaitForVdiff)
+
+	resp, err = s.ws.ReshardRollback(ctx, req)
+	return resp, err
+}
+
 func (s *VtctldServer) RestoreFromBackup(req *vtctldatapb.RestoreFromBackupRequest, stream vtctlservicepb.Vtctld_RestoreFromBackupServer) (err error) {
 	span, ctx := trace.NewSpan(stream.Context(), "VtctldServer.RestoreFromBackup")
 	defer span.Finish()
@@ -491,6 +491,11 @@ func (client *localVtctldClient) ReshardCreate(ctx context.Context, in *vtctldat
 	return client.s.ReshardCreate(ctx, in)
 }
 
+// ReshardRollback is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) ReshardRollback(ctx context.Context, in *vtctldatapb.ReshardRollbackRequest, opts ...grpc.CallOption) (*vtctldatapb.ReshardRollbackResponse, error) {
+	return client.s.ReshardRollback(ctx, in)
+}
+
 type restoreFromBackupStreamAdapter struct {
 	*grpcshim.BidiStream
 	ch chan *vtctldatapb.RestoreFromBackupResponse
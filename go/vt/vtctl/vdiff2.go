@@ -67,6 +67,9 @@ func commandVDiff2(ctx context.Context, wr *wrangler.Wrangler, subFlags *pflag.F
 	verbose := subFlags.Bool("verbose", false, "Show verbose vdiff output in summaries")
 	wait := subFlags.Bool("wait", false, "When creating or resuming a vdiff, wait for it to finish before exiting")
 	waitUpdateInterval := subFlags.Duration("wait-update-interval", time.Duration(1*time.Minute), "When waiting on a vdiff to finish, check and display the current status this often")
+	recheck := subFlags.Bool("recheck", false, "Re-check the rows which differ once the target has caught up with the source, and only report the rows which still differ")
+	scheduleInterval := subFlags.Duration("schedule-interval", 0, "Run the vdiff again this long after each run completes, to keep the workflow continuously validated")
+	incremental := subFlags.Bool("incremental", false, "In the scheduled runs, only compare the rows changed on the source since the last run which found no differences in the table; requires --schedule-interval")
 	updateTableStats := subFlags.Bool("update-table-stats", false, "Update the table statistics, using ANALYZE TABLE, on each table involved in the VDiff during initialization. This will ensure that progress estimates are as accurate as possible -- but it does involve locks and can potentially impact query processing on the target keyspace.")
 
 	if err := subFlags.Parse(args); err != nil {
@@ -138,6 +141,14 @@ func commandVDiff2(ctx context.Context, wr *wrangler.Wrangler, subFlags *pflag.F
 		if err != nil {
 			return fmt.Errorf("%v, please provide a valid UUID", err)
 		}
+		options.ScheduleOptions = &tabletmanagerdatapb.VDiffScheduleOptions{
+			Recheck:         *recheck,
+			IntervalSeconds: int64(scheduleInterval.Seconds()),
+			Incremental:     *incremental,
+		}
+		if *scheduleInterval > 0 && options.ScheduleOptions.IntervalSeconds == 0 {
+			return fmt.Errorf("invalid --schedule-interval of %v, it must be at least one second", *scheduleInterval)
+		}
 	case vdiff.ShowAction:
 		switch actionArg {
 		case vdiff.AllActionArg, vdiff.LastActionArg:
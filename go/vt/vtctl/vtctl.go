@@ -438,7 +438,7 @@ var commands = []commandGroup{
 			{
 				name:   "Reshard",
 				method: commandReshard,
-				params: "[--source_shards=<source_shards>] [--target_shards=<target_shards>] [--cells=<cells>] [--tablet_types=<source_tablet_types>] [--on-ddl=<ddl-action>] [--defer-secondary-keys] [--skip_schema_copy] <action> 'action must be one of the following: Create, Complete, Cancel, SwitchTraffic, ReverseTrafffic, Show, or Progress' <keyspace.workflow>",
+				params: "[--source_shards=<source_shards>] [--target_shards=<target_shards>] [--cells=<cells>] [--tablet_types=<source_tablet_types>] [--on-ddl=<ddl-action>] [--defer-secondary-keys] [--skip_schema_copy] [--skip-vdiff] [--wait-for-vdiff] <action> 'action must be one of the following: Create, Complete, Cancel, SwitchTraffic, ReverseTrafffic, Rollback, Show, or Progress' <keyspace.workflow>",
 				help:   "Start a Resharding process.",
 			},
 			{
@@ -474,7 +474,7 @@ var commands = []commandGroup{
 			{
 				name:   "VDiff",
 				method: commandVDiff,
-				params: "[--source_cell=<cell>] [--target_cell=<cell>] [--tablet_types=in_order:RDONLY,REPLICA,PRIMARY] [--limit=<max rows to diff>] [--tables=<table list>] [--format=json] [--auto-retry] [--verbose] [--max_extra_rows_to_compare=1000] [--filtered_replication_wait_time=30s] [--debug_query] [--only_pks] [--wait] [--wait-update-interval=1m] <keyspace.workflow> [<action>] [<UUID>]",
+				params: "[--source_cell=<cell>] [--target_cell=<cell>] [--tablet_types=in_order:RDONLY,REPLICA,PRIMARY] [--limit=<max rows to diff>] [--tables=<table list>] [--format=json] [--auto-retry] [--verbose] [--max_extra_rows_to_compare=1000] [--filtered_replication_wait_time=30s] [--debug_query] [--only_pks] [--wait] [--wait-update-interval=1m] [--recheck] [--schedule-interval=<duration>] [--incremental] <keyspace.workflow> [<action>] [<UUID>]",
 				help:   "Perform a diff of all tables in the workflow",
 			},
 			{
@@ -2063,6 +2063,7 @@ const (
 	vReplicationWorkflowActionShow           = "show"
 	vReplicationWorkflowActionProgress       = "progress"
 	vReplicationWorkflowActionGetState       = "getstate"
+	vReplicationWorkflowActionRollback       = "rollback"
 )
 
 func commandMigrate(ctx context.Context, wr *wrangler.Wrangler, subFlags *pflag.FlagSet, args []string) error {
@@ -2128,6 +2129,8 @@ func commandVReplicationWorkflow(ctx context.Context, wr *wrangler.Wrangler, sub
 	targetShards := subFlags.String("target_shards", "", "Reshard only. Target shards")
 	*targetShards = strings.TrimSpace(*targetShards)
 	skipSchemaCopy := subFlags.Bool("skip_schema_copy", false, "Reshard only. Skip copying of schema to target shards")
+	skipVDiff := subFlags.Bool("skip-vdiff", false, "Reshard Rollback only. Do not verify the restarted workflow with a VDiff")
+	waitForVDiff := subFlags.Bool("wait-for-vdiff", false, "Reshard Rollback only. Wait for the VDiff verifying the restarted workflow to complete, and fail if it finds differences")
 
 	if err := subFlags.Parse(args); err != nil {
 		return err
@@ -2225,6 +2228,37 @@ func commandVReplicationWorkflow(ctx context.Context, wr *wrangler.Wrangler, sub
 		}
 	}
 
+	if action == vReplicationWorkflowActionRollback {
+		if workflowType != wrangler.ReshardWorkflow {
+			return fmt.Errorf("%s is only supported for Reshard workflows", originalAction)
+		}
+		// The rollback switches the traffic back and restarts the workflow in one operation,
+		// which can be run again to resume it if it fails part way through.
+		resp, err := workflow.NewServer(wr.TopoServer(), wr.TabletManagerClient()).ReshardRollback(ctx, &vtctldatapb.ReshardRollbackRequest{
+			Keyspace:                 target,
+			Workflow:                 workflowName,
+			Timeout:                  protoutil.DurationToProto(*timeout),
+			MaxReplicationLagAllowed: protoutil.DurationToProto(*maxReplicationLagAllowed),
+			SkipVdiff:                *skipVDiff,
+			WaitForVdiff:             *waitForVDiff,
+		})
+		// A rollback which stopped part way through reports the steps it did.
+		if resp != nil {
+			for _, step := range resp.Steps {
+				wr.Logger().Printf("%s: %s\n", step.Name, step.Message)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if resp.VdiffUuid != "" {
+			wr.Logger().Printf("VDiff %s verifies workflow %s.%s, see: VDiff -- %s.%s show %s\n",
+				resp.VdiffUuid, target, workflowName, target, workflowName, resp.VdiffUuid)
+		}
+		wr.Logger().Printf("%s\nStart State: %s\nCurrent State: %s\n\n", resp.Summary, resp.StartState, resp.CurrentState)
+		return nil
+	}
+
 	switch action {
 	case vReplicationWorkflowActionCreate:
 		switch workflowType {
@@ -0,0 +1,631 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/grpcclient"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/topotools"
+	"vitess.io/vitess/go/vt/vttablet/queryservice"
+	"vitess.io/vitess/go/vt/vttablet/tabletconn"
+	"vitess.io/vitess/go/vt/vttablet/tabletconntest"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vdiff"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+const testPosition = "MySQL56/16b1039f-22b6-11ed-b765-0a43f95f28a3:1-10"
+
+// testEnv is a sharded keyspace whose primary tablets are faked by a testTMClient, which
+// keeps the vreplication streams and the journals of each tablet so that the workflows
+// can be created, switched and rolled back.
+type testEnv struct {
+	ws       *Server
+	topoServ *topo.Server
+	tmc      *testTMClient
+	cell     string
+	keyspace string
+	tablets  map[int]*topodatapb.Tablet
+}
+
+var (
+	// testQueryServices are the query services of the tablets of the current testEnv,
+	// which the tablet picker uses to check that the tablets are serving.
+	testQueryServicesMu sync.Mutex
+	testQueryServices   map[uint32]queryservice.QueryService
+)
+
+func init() {
+	tabletconn.RegisterDialer("WorkflowTest", func(tablet *topodatapb.Tablet, failFast grpcclient.FailFast) (queryservice.QueryService, error) {
+		testQueryServicesMu.Lock()
+		defer testQueryServicesMu.Unlock()
+		if qs, ok := testQueryServices[tablet.Alias.Uid]; ok {
+			return qs, nil
+		}
+		return nil, fmt.Errorf("tablet %d not found", tablet.Alias.Uid)
+	})
+}
+
+// newTestEnv creates the keyspace with a primary tablet for each of the source shards,
+// starting at 100, and for each of the target shards, starting at 200. The source shards
+// serve the keyspace.
+func newTestEnv(t *testing.T, ctx context.Context, keyspace string, sourceShards, targetShards []string) *testEnv {
+	t.Helper()
+	tabletconntest.SetProtocol("go.vt.vtctl.workflow.framework_test", "WorkflowTest")
+	env := &testEnv{
+		topoServ: memorytopo.NewServer(ctx, "cell"),
+		tmc:      newTestTMClient(),
+		cell:     "cell",
+		keyspace: keyspace,
+		tablets:  make(map[int]*topodatapb.Tablet),
+	}
+	env.ws = NewServer(env.topoServ, env.tmc)
+	testQueryServicesMu.Lock()
+	testQueryServices = make(map[uint32]queryservice.QueryService)
+	testQueryServicesMu.Unlock()
+
+	err := env.topoServ.SaveVSchema(ctx, keyspace, &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "hash"}}},
+		},
+	})
+	require.NoError(t, err)
+	for i, shard := range sourceShards {
+		env.addTablet(t, ctx, 100+i*10, shard, true)
+	}
+	for i, shard := range targetShards {
+		env.addTablet(t, ctx, 200+i*10, shard, false)
+	}
+	require.NoError(t, env.topoServ.RebuildSrvVSchema(ctx, nil))
+	require.NoError(t, topotools.RebuildKeyspace(ctx, logutil.NewConsoleLogger(), env.topoServ, keyspace, nil, false))
+	return env
+}
+
+func (env *testEnv) addTablet(t *testing.T, ctx context.Context, id int, shard string, serving bool) {
+	t.Helper()
+	_, keyRange, err := topo.ValidateShardName(shard)
+	require.NoError(t, err)
+	tablet := &topodatapb.Tablet{
+		Alias:    &topodatapb.TabletAlias{Cell: env.cell, Uid: uint32(id)},
+		Keyspace: env.keyspace,
+		Shard:    shard,
+		KeyRange: keyRange,
+		Type:     topodatapb.TabletType_PRIMARY,
+		PortMap:  map[string]int32{"test": int32(id)},
+	}
+	require.NoError(t, env.topoServ.InitTablet(ctx, tablet, false /* allowPrimaryOverride */, true /* createShardAndKeyspace */, false /* allowUpdate */))
+	_, err = env.topoServ.UpdateShardFields(ctx, env.keyspace, shard, func(si *topo.ShardInfo) error {
+		si.PrimaryAlias = tablet.Alias
+		si.IsPrimaryServing = serving
+		return nil
+	})
+	require.NoError(t, err)
+	env.tablets[id] = tablet
+	env.tmc.addTablet(tablet)
+	testQueryServicesMu.Lock()
+	testQueryServices[tablet.Alias.Uid] = &testQueryService{tablet: tablet}
+	testQueryServicesMu.Unlock()
+}
+
+// addStream adds a running vreplication stream of the workflow to the tablet.
+func (env *testEnv) addStream(t *testing.T, tabletID int, workflow string, workflowType binlogdatapb.VReplicationWorkflowType, bls *binlogdatapb.BinlogSource) {
+	query := fmt.Sprintf("insert into _vt.vreplication (workflow, source, pos, time_updated, state, db_name, workflow_type) values (%s, %s, %s, %d, 'Running', %s, %d)",
+		encodeString(workflow), encodeString(bls.String()), encodeString(testPosition), time.Now().Unix(), encodeString("vt_"+env.keyspace), workflowType)
+	_, err := env.tmc.VReplicationExec(context.Background(), env.tablets[tabletID], query)
+	require.NoError(t, err)
+}
+
+func (env *testEnv) close() {
+	env.topoServ.Close()
+}
+
+// testQueryService reports the tablet as healthy and serving.
+type testQueryService struct {
+	queryservice.QueryService
+	tablet *topodatapb.Tablet
+}
+
+func (qs *testQueryService) StreamHealth(ctx context.Context, callback func(*querypb.StreamHealthResponse) error) error {
+	return callback(&querypb.StreamHealthResponse{
+		Serving: true,
+		Target: &querypb.Target{
+			Keyspace:   qs.tablet.Keyspace,
+			Shard:      qs.tablet.Shard,
+			TabletType: qs.tablet.Type,
+		},
+		RealtimeStats: &querypb.RealtimeStats{},
+	})
+}
+
+func (qs *testQueryService) Close(ctx context.Context) error {
+	return nil
+}
+
+// testTMClient fakes the _vt.vreplication and _vt.resharding_journal tables, and the
+// vdiffs, of the tablets. It executes the queries which the workflows run on these
+// tables: the where clauses are conjunctions of comparisons of a column with values.
+type testTMClient struct {
+	tmclient.TabletManagerClient
+
+	mu     sync.Mutex
+	dbs    map[uint32]*testTabletDB
+	failOn map[uint32]*regexp.Regexp
+}
+
+type testTabletDB struct {
+	tablet       *topodatapb.Tablet
+	streams      []map[string]sqltypes.Value
+	lastID       int64
+	journals     map[string]string
+	vdiffs       []*tabletmanagerdatapb.VDiffRequest
+	vdiffUUIDs   []string
+	vdiffDeletes int
+}
+
+func newTestTMClient() *testTMClient {
+	return &testTMClient{
+		dbs:    make(map[uint32]*testTabletDB),
+		failOn: make(map[uint32]*regexp.Regexp),
+	}
+}
+
+func (tmc *testTMClient) addTablet(tablet *topodatapb.Tablet) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	tmc.dbs[tablet.Alias.Uid] = &testTabletDB{tablet: tablet, journals: make(map[string]string)}
+}
+
+// failQueries fails the queries matching the expression on the tablet, until it is called with an empty expression.
+func (tmc *testTMClient) failQueries(tabletID int, expr string) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	if expr == "" {
+		delete(tmc.failOn, uint32(tabletID))
+		return
+	}
+	tmc.failOn[uint32(tabletID)] = regexp.MustCompile(expr)
+}
+
+// streams returns the column of the streams of the workflow on the tablet, by stream id.
+func (tmc *testTMClient) streams(tabletID int, workflow, column string) map[int64]string {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	values := make(map[int64]string)
+	for _, row := range tmc.dbs[uint32(tabletID)].streams {
+		if row["workflow"].ToString() == workflow {
+			id, _ := row["id"].ToCastInt64()
+			values[id] = row[column].ToString()
+		}
+	}
+	return values
+}
+
+// setStreams sets the column of the streams of the workflow on the tablet.
+func (tmc *testTMClient) setStreams(tabletID int, workflow, column string, value sqltypes.Value) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	for _, row := range tmc.dbs[uint32(tabletID)].streams {
+		if row["workflow"].ToString() == workflow {
+			row[column] = value
+		}
+	}
+}
+
+// vdiffRequests returns the vdiffs created on the tablet.
+func (tmc *testTMClient) vdiffRequests(tabletID int) []*tabletmanagerdatapb.VDiffRequest {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	return tmc.dbs[uint32(tabletID)].vdiffs
+}
+
+func (tmc *testTMClient) ReadVReplicationWorkflow(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.ReadVReplicationWorkflowRequest) (*tabletmanagerdatapb.ReadVReplicationWorkflowResponse, error) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	resp := &tabletmanagerdatapb.ReadVReplicationWorkflowResponse{Workflow: req.Workflow}
+	for _, row := range tmc.dbs[tablet.Alias.Uid].streams {
+		if row["workflow"].ToString() != req.Workflow {
+			continue
+		}
+		bls := &binlogdatapb.BinlogSource{}
+		if err := prototext.Unmarshal([]byte(row["source"].ToString()), bls); err != nil {
+			return nil, err
+		}
+		id, _ := row["id"].ToCastInt64()
+		workflowType, _ := row["workflow_type"].ToCastInt64()
+		workflowSubType, _ := row["workflow_sub_type"].ToCastInt64()
+		timeUpdated, _ := row["time_updated"].ToCastInt64()
+		resp.Cells = row["cell"].ToString()
+		resp.WorkflowType = binlogdatapb.VReplicationWorkflowType(workflowType)
+		resp.WorkflowSubType = binlogdatapb.VReplicationWorkflowSubType(workflowSubType)
+		resp.Streams = append(resp.Streams, &tabletmanagerdatapb.ReadVReplicationWorkflowResponse_Stream{
+			Id:          int32(id),
+			Bls:         bls,
+			Pos:         row["pos"].ToString(),
+			State:       binlogdatapb.VReplicationWorkflowState(binlogdatapb.VReplicationWorkflowState_value[row["state"].ToString()]),
+			Message:     row["message"].ToString(),
+			TimeUpdated: protoutil.TimeToProto(time.Unix(timeUpdated, 0)),
+		})
+	}
+	if tabletTypes := workflowTabletTypes(tmc.dbs[tablet.Alias.Uid].streams, req.Workflow); tabletTypes != "" {
+		types, err := topoproto.ParseTabletTypes(tabletTypes)
+		if err != nil {
+			return nil, err
+		}
+		resp.TabletTypes = types
+	}
+	return resp, nil
+}
+
+func (tmc *testTMClient) VReplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	if expr := tmc.failOn[tablet.Alias.Uid]; expr != nil && expr.MatchString(query) {
+		return nil, fmt.Errorf("tablet %d failed to execute %s", tablet.Alias.Uid, query)
+	}
+	db := tmc.dbs[tablet.Alias.Uid]
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	var result *sqltypes.Result
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		result, err = db.selectRows(stmt)
+	case *sqlparser.Insert:
+		result, err = db.insertRows(stmt)
+	case *sqlparser.Update:
+		result, err = db.updateRows(stmt)
+	case *sqlparser.Delete:
+		result, err = db.deleteRows(stmt)
+	default:
+		err = fmt.Errorf("unsupported query %s", query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sqltypes.ResultToProto3(result), nil
+}
+
+func (tmc *testTMClient) VReplicationWaitForPos(ctx context.Context, tablet *topodatapb.Tablet, id int32, pos string) error {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	if expr := tmc.failOn[tablet.Alias.Uid]; expr != nil && expr.MatchString("VReplicationWaitForPos") {
+		return fmt.Errorf("tablet %d failed to wait for position %s", tablet.Alias.Uid, pos)
+	}
+	return nil
+}
+
+func (tmc *testTMClient) PrimaryPosition(ctx context.Context, tablet *topodatapb.Tablet) (string, error) {
+	return testPosition, nil
+}
+
+func (tmc *testTMClient) RefreshState(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return nil
+}
+
+func (tmc *testTMClient) ExecuteFetchAsAllPrivs(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.ExecuteFetchAsAllPrivsRequest) (*querypb.QueryResult, error) {
+	return &querypb.QueryResult{}, nil
+}
+
+func (tmc *testTMClient) VDiff(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.VDiffRequest) (*tabletmanagerdatapb.VDiffResponse, error) {
+	tmc.mu.Lock()
+	defer tmc.mu.Unlock()
+	if expr := tmc.failOn[tablet.Alias.Uid]; expr != nil && expr.MatchString("VDiff "+req.Action) {
+		return nil, fmt.Errorf("tablet %d failed to %s the vdiff", tablet.Alias.Uid, req.Action)
+	}
+	db := tmc.dbs[tablet.Alias.Uid]
+	resp := &tabletmanagerdatapb.VDiffResponse{VdiffUuid: req.VdiffUuid}
+	switch vdiff.VDiffAction(req.Action) {
+	case vdiff.CreateAction:
+		db.vdiffs = append(db.vdiffs, req)
+		db.vdiffUUIDs = append(db.vdiffUUIDs, req.VdiffUuid)
+		resp.Id = int64(len(db.vdiffs))
+	case vdiff.ShowAction:
+		var rows []string
+		for _, uuid := range db.vdiffUUIDs {
+			if req.ActionArg == vdiff.AllActionArg || req.ActionArg == uuid {
+				rows = append(rows, uuid+"|pending")
+			}
+		}
+		resp.Output = sqltypes.ResultToProto3(sqltypes.MakeTestResult(sqltypes.MakeTestFields("vdiff_uuid|vdiff_state", "varchar|varchar"), rows...))
+	case vdiff.DeleteAction:
+		db.vdiffDeletes++
+	}
+	return resp, nil
+}
+
+// workflowTabletTypes returns the tablet types of the workflow, which are the same for all of its streams.
+func workflowTabletTypes(streams []map[string]sqltypes.Value, workflow string) string {
+	for _, row := range streams {
+		if row["workflow"].ToString() == workflow {
+			return row["tablet_types"].ToString()
+		}
+	}
+	return ""
+}
+
+// vreplicationDefaults are the values of the columns of _vt.vreplication which are not inserted.
+var vreplicationDefaults = map[string]sqltypes.Value{
+	"pos":                   sqltypes.NewVarChar(""),
+	"stop_pos":              sqltypes.NULL,
+	"max_tps":               sqltypes.NewInt64(0),
+	"max_replication_lag":   sqltypes.NewInt64(0),
+	"cell":                  sqltypes.NewVarChar(""),
+	"tablet_types":          sqltypes.NewVarChar(""),
+	"time_updated":          sqltypes.NewInt64(0),
+	"transaction_timestamp": sqltypes.NewInt64(0),
+	"state":                 sqltypes.NewVarChar("Stopped"),
+	"message":               sqltypes.NewVarChar(""),
+	"tags":                  sqltypes.NewVarChar(""),
+	"workflow_type":         sqltypes.NewInt64(0),
+	"workflow_sub_type":     sqltypes.NewInt64(0),
+	"time_heartbeat":        sqltypes.NewInt64(0),
+	"defer_secondary_keys":  sqltypes.NewInt64(0),
+	"component_throttled":   sqltypes.NewVarChar(""),
+	"time_throttled":        sqltypes.NewInt64(0),
+	"rows_copied":           sqltypes.NewInt64(0),
+}
+
+func tableName(expr sqlparser.TableExpr) (string, error) {
+	aliased, ok := expr.(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return "", fmt.Errorf("unsupported table %s", sqlparser.String(expr))
+	}
+	table, err := aliased.TableName()
+	if err != nil {
+		return "", err
+	}
+	return table.Name.String(), nil
+}
+
+func exprValue(expr sqlparser.Expr) (sqltypes.Value, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.Literal:
+		return sqlparser.LiteralToValue(expr)
+	case *sqlparser.NullVal:
+		return sqltypes.NULL, nil
+	}
+	return sqltypes.NULL, fmt.Errorf("unsupported value %s", sqlparser.String(expr))
+}
+
+// matches returns true if the row satisfies the where clause.
+func matches(row map[string]sqltypes.Value, where *sqlparser.Where) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+	var match func(expr sqlparser.Expr) (bool, error)
+	match = func(expr sqlparser.Expr) (bool, error) {
+		switch expr := expr.(type) {
+		case *sqlparser.AndExpr:
+			left, err := match(expr.Left)
+			if err != nil || !left {
+				return false, err
+			}
+			return match(expr.Right)
+		case *sqlparser.ComparisonExpr:
+			col, ok := expr.Left.(*sqlparser.ColName)
+			if !ok {
+				break
+			}
+			value := row[col.Name.Lowered()].ToString()
+			var values []sqlparser.Expr
+			switch expr.Operator {
+			case sqlparser.EqualOp, sqlparser.NotEqualOp:
+				values = []sqlparser.Expr{expr.Right}
+			case sqlparser.InOp, sqlparser.NotInOp:
+				tuple, ok := expr.Right.(sqlparser.ValTuple)
+				if !ok {
+					return false, fmt.Errorf("unsupported expression %s", sqlparser.String(expr))
+				}
+				values = tuple
+			default:
+				return false, fmt.Errorf("unsupported expression %s", sqlparser.String(expr))
+			}
+			found := false
+			for _, v := range values {
+				v, err := exprValue(v)
+				if err != nil {
+					return false, err
+				}
+				found = found || v.ToString() == value
+			}
+			return found == (expr.Operator == sqlparser.EqualOp || expr.Operator == sqlparser.InOp), nil
+		}
+		return false, fmt.Errorf("unsupported expression %s", sqlparser.String(expr))
+	}
+	return match(where.Expr)
+}
+
+func (db *testTabletDB) selectRows(stmt *sqlparser.Select) (*sqltypes.Result, error) {
+	table, err := tableName(stmt.From[0])
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]sqltypes.Value
+	switch table {
+	case "vreplication":
+		for _, row := range db.streams {
+			ok, err := matches(row, stmt.Where)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rows = append(rows, row)
+			}
+		}
+	case "resharding_journal":
+		for id, val := range db.journals {
+			row := map[string]sqltypes.Value{"id": sqltypes.NewVarChar(id), "val": sqltypes.NewVarChar(val)}
+			ok, err := matches(row, stmt.Where)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rows = append(rows, row)
+			}
+		}
+	}
+	result := &sqltypes.Result{}
+	for _, expr := range stmt.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, fmt.Errorf("unsupported select expression %s", sqlparser.String(expr))
+		}
+		name := aliased.ColumnName()
+		fieldType := sqltypes.VarChar
+		if col, ok := aliased.Expr.(*sqlparser.ColName); ok && len(rows) > 0 && rows[0][col.Name.Lowered()].Type() == sqltypes.Int64 {
+			fieldType = sqltypes.Int64
+		}
+		result.Fields = append(result.Fields, &querypb.Field{Name: name, Type: fieldType})
+	}
+	for _, row := range rows {
+		var values []sqltypes.Value
+		for _, expr := range stmt.SelectExprs {
+			aliased := expr.(*sqlparser.AliasedExpr)
+			if col, ok := aliased.Expr.(*sqlparser.ColName); ok {
+				values = append(values, row[col.Name.Lowered()])
+				continue
+			}
+			v, err := exprValue(aliased.Expr)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, nil
+}
+
+func (db *testTabletDB) insertRows(stmt *sqlparser.Insert) (*sqltypes.Result, error) {
+	values, ok := stmt.Rows.(sqlparser.Values)
+	if !ok {
+		return nil, fmt.Errorf("unsupported insert %s", sqlparser.String(stmt))
+	}
+	table, err := tableName(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	result := &sqltypes.Result{}
+	for _, tuple := range values {
+		row := make(map[string]sqltypes.Value)
+		for i, col := range stmt.Columns {
+			v, err := exprValue(tuple[i])
+			if err != nil {
+				return nil, err
+			}
+			row[col.Lowered()] = v
+		}
+		switch table {
+		case "vreplication":
+			for col, v := range vreplicationDefaults {
+				if _, ok := row[col]; !ok {
+					row[col] = v
+				}
+			}
+			db.lastID++
+			row["id"] = sqltypes.NewInt64(db.lastID)
+			db.streams = append(db.streams, row)
+			result.InsertID = uint64(db.lastID)
+		case "resharding_journal":
+			db.journals[row["id"].ToString()] = row["val"].ToString()
+		default:
+			return nil, fmt.Errorf("unsupported insert %s", sqlparser.String(stmt))
+		}
+		result.RowsAffected++
+	}
+	return result, nil
+}
+
+func (db *testTabletDB) updateRows(stmt *sqlparser.Update) (*sqltypes.Result, error) {
+	table, err := tableName(stmt.TableExprs[0])
+	if err != nil {
+		return nil, err
+	}
+	if table != "vreplication" {
+		return nil, fmt.Errorf("unsupported update %s", sqlparser.String(stmt))
+	}
+	result := &sqltypes.Result{}
+	for _, row := range db.streams {
+		ok, err := matches(row, stmt.Where)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		for _, expr := range stmt.Exprs {
+			v, err := exprValue(expr.Expr)
+			if err != nil {
+				return nil, err
+			}
+			row[expr.Name.Name.Lowered()] = v
+		}
+		result.RowsAffected++
+	}
+	return result, nil
+}
+
+func (db *testTabletDB) deleteRows(stmt *sqlparser.Delete) (*sqltypes.Result, error) {
+	table, err := tableName(stmt.TableExprs[0])
+	if err != nil {
+		return nil, err
+	}
+	result := &sqltypes.Result{}
+	if table != "vreplication" {
+		return result, nil
+	}
+	var kept []map[string]sqltypes.Value
+	for _, row := range db.streams {
+		ok, err := matches(row, stmt.Where)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.RowsAffected++
+			continue
+		}
+		kept = append(kept, row)
+	}
+	db.streams = kept
+	return result, nil
+}
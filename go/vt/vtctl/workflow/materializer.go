@@ -176,14 +176,17 @@ func (mz *materializer) generateInserts(ctx context.Context, sourceShards []*top
 
 	for _, sourceShard := range sourceShards {
 		bls := &binlogdatapb.BinlogSource{
-			Keyspace:        mz.ms.SourceKeyspace,
-			Shard:           sourceShard.ShardName(),
-			Filter:          &binlogdatapb.Filter{},
-			StopAfterCopy:   mz.ms.StopAfterCopy,
-			ExternalCluster: mz.ms.ExternalCluster,
-			SourceTimeZone:  mz.ms.SourceTimeZone,
-			TargetTimeZone:  mz.ms.TargetTimeZone,
-			OnDdl:           binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[mz.ms.OnDdl]),
+			Keyspace:            mz.ms.SourceKeyspace,
+			Shard:               sourceShard.ShardName(),
+			Filter:              &binlogdatapb.Filter{},
+			StopAfterCopy:       mz.ms.StopAfterCopy,
+			ExternalCluster:     mz.ms.ExternalCluster,
+			SourceTimeZone:      mz.ms.SourceTimeZone,
+			TargetTimeZone:      mz.ms.TargetTimeZone,
+			OnDdl:               binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[mz.ms.OnDdl]),
+			Sink:                mz.ms.Sink,
+			SourceStream:        mz.ms.SourceStream,
+			EvaluateExpressions: mz.ms.EvaluateExpressions,
 		}
 		for _, ts := range mz.ms.TableSettings {
 			rule := &binlogdatapb.Rule{
@@ -275,14 +278,17 @@ func (mz *materializer) generateBinlogSources(ctx context.Context, targetShard *
 	blses := make([]*binlogdatapb.BinlogSource, 0, len(mz.sourceShards))
 	for _, sourceShard := range sourceShards {
 		bls := &binlogdatapb.BinlogSource{
-			Keyspace:        mz.ms.SourceKeyspace,
-			Shard:           sourceShard.ShardName(),
-			Filter:          &binlogdatapb.Filter{},
-			StopAfterCopy:   mz.ms.StopAfterCopy,
-			ExternalCluster: mz.ms.ExternalCluster,
-			SourceTimeZone:  mz.ms.SourceTimeZone,
-			TargetTimeZone:  mz.ms.TargetTimeZone,
-			OnDdl:           binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[mz.ms.OnDdl]),
+			Keyspace:            mz.ms.SourceKeyspace,
+			Shard:               sourceShard.ShardName(),
+			Filter:              &binlogdatapb.Filter{},
+			StopAfterCopy:       mz.ms.StopAfterCopy,
+			ExternalCluster:     mz.ms.ExternalCluster,
+			SourceTimeZone:      mz.ms.SourceTimeZone,
+			TargetTimeZone:      mz.ms.TargetTimeZone,
+			OnDdl:               binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[mz.ms.OnDdl]),
+			Sink:                mz.ms.Sink,
+			SourceStream:        mz.ms.SourceStream,
+			EvaluateExpressions: mz.ms.EvaluateExpressions,
 		}
 		for _, ts := range mz.ms.TableSettings {
 			rule := &binlogdatapb.Rule{
@@ -464,6 +470,9 @@ func (mz *materializer) deploySchema() error {
 func (mz *materializer) buildMaterializer() error {
 	ctx := mz.ctx
 	ms := mz.ms
+	if ms.SourceStream != "" && (ms.SourceKeyspace != ms.TargetKeyspace || ms.ExternalCluster != "") {
+		return fmt.Errorf("the source stream %s of workflow %s is read in the target keyspace %s, not in keyspace %s", ms.SourceStream, ms.Workflow, ms.TargetKeyspace, ms.SourceKeyspace)
+	}
 	vschema, err := mz.ts.GetVSchema(ctx, ms.TargetKeyspace)
 	if err != nil {
 		return err
@@ -649,6 +658,15 @@ func (mz *materializer) checkTZConversion(ctx context.Context, tz string) error
 // data between the shards. This optimization is only applied for MoveTables
 // when the source and target shard have the same primary vindexes.
 func (mz *materializer) filterSourceShards(targetShard *topo.ShardInfo) []*topo.ShardInfo {
+	if mz.ms.SourceStream != "" {
+		// The stream is read on the primary of the target shard.
+		for _, sourceShard := range mz.sourceShards {
+			if sourceShard.ShardName() == targetShard.ShardName() {
+				return []*topo.ShardInfo{sourceShard}
+			}
+		}
+		return nil
+	}
 	if mz.primaryVindexesDiffer || mz.ms.MaterializationIntent != vtctldatapb.MaterializationIntent_MOVETABLES {
 		// Use all source shards.
 		return mz.sourceShards
@@ -2929,6 +2929,89 @@ func TestMaterializerStopAfterCopy(t *testing.T) {
 	env.tmc.verifyQueries(t)
 }
 
+func TestMaterializerEvaluateExpressions(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:            "workflow",
+		SourceKeyspace:      "sourceks",
+		TargetKeyspace:      "targetks",
+		EvaluateExpressions: true,
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t1",
+			SourceExpression: "select id, upper(name) as name from t1",
+			CreateDdl:        "t1ddl",
+		}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	env := newTestMaterializerEnv(t, ctx, ms, []string{"0"}, []string{"0"})
+	defer env.close()
+
+	env.tmc.expectVRQuery(200, mzSelectFrozenQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, insertPrefix+`.*evaluate_expressions:true`, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, mzUpdateQuery, &sqltypes.Result{})
+
+	err := env.ws.Materialize(ctx, ms)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+}
+
+func TestMaterializerSourceStream(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "targetks",
+		TargetKeyspace: "targetks",
+		SourceStream:   "orders",
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t2",
+			SourceExpression: "select * from t1",
+			CreateDdl:        "t2ddl",
+		}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	env := newTestMaterializerEnv(t, ctx, ms, []string{"-80", "80-"}, []string{"-80", "80-"})
+	defer env.close()
+
+	// each shard reads the stream of its own primary
+	for _, tabletID := range []int{100, 110} {
+		env.tmc.expectVRQuery(tabletID, "select 1 from _vt.vreplication where db_name='vt_targetks' and workflow='workflow'", &sqltypes.Result{})
+		env.tmc.expectVRQuery(tabletID, mzSelectFrozenQuery, &sqltypes.Result{})
+	}
+	env.tmc.expectVRQuery(100, insertPrefix+`.*shard:\\"-80\\" .*source_stream:\\"orders\\"`, &sqltypes.Result{})
+	env.tmc.expectVRQuery(110, insertPrefix+`.*shard:\\"80-\\" .*source_stream:\\"orders\\"`, &sqltypes.Result{})
+	env.tmc.expectVRQuery(100, mzUpdateQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(110, mzUpdateQuery, &sqltypes.Result{})
+
+	err := env.ws.Materialize(ctx, ms)
+	require.NoError(t, err)
+	env.tmc.verifyQueries(t)
+}
+
+func TestMaterializerSourceStreamOtherKeyspace(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		SourceStream:   "orders",
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t1",
+			SourceExpression: "select * from t1",
+			CreateDdl:        "t1ddl",
+		}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	env := newTestMaterializerEnv(t, ctx, ms, []string{"0"}, []string{"0"})
+	defer env.close()
+
+	env.tmc.expectVRQuery(200, mzSelectFrozenQuery, &sqltypes.Result{})
+	err := env.ws.Materialize(ctx, ms)
+	require.EqualError(t, err, "the source stream orders of workflow workflow is read in the target keyspace targetks, not in keyspace sourceks")
+}
+
 func TestMaterializerNoTargetVSchema(t *testing.T) {
 	ms := &vtctldatapb.MaterializeSettings{
 		Workflow:       "workflow",
@@ -0,0 +1,408 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/maps"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vdiff"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// A Reshard whose writes were switched is rolled back by switching the traffic back to
+// the original shards, while the reverse workflow keeps them up to date with the writes
+// made on the new shards, and by restarting the workflow so that the traffic can be
+// switched again once the cause of the rollback is fixed. Each step of the rollback
+// first checks whether it is still needed, so that a rollback which failed part way
+// through is resumed by running it again, and a completed rollback does nothing. The
+// response reports each step as it was done or skipped, and a failed rollback returns
+// the steps done so far with the error of the step at which it stopped.
+
+// RollbackStep is a step of a Reshard rollback.
+type RollbackStep string
+
+const (
+	// RollbackStepValidate checks that the reverse workflow is caught up.
+	RollbackStepValidate RollbackStep = "ValidateReverseWorkflow"
+	// RollbackStepSwitchReads switches the reads back to the original shards.
+	RollbackStepSwitchReads RollbackStep = "SwitchReadsBack"
+	// RollbackStepSwitchWrites switches the writes back to the original shards.
+	RollbackStepSwitchWrites RollbackStep = "SwitchWritesBack"
+	// RollbackStepStartWorkflow restarts the streams of the workflow.
+	RollbackStepStartWorkflow RollbackStep = "StartWorkflow"
+	// RollbackStepCreateVDiff creates the VDiff verifying the restarted workflow.
+	RollbackStepCreateVDiff RollbackStep = "CreateVDiff"
+	// RollbackStepWaitForVDiff waits for the VDiff to complete without differences.
+	RollbackStepWaitForVDiff RollbackStep = "WaitForVDiff"
+)
+
+// vdiffPollInterval is how often the VDiff of a rollback is checked when waiting for it.
+var vdiffPollInterval = 10 * time.Second
+
+// ReshardRollback switches the reads and writes of a Reshard workflow back to the
+// original shards, restarts the workflow and verifies it with a VDiff.
+func (s *Server) ReshardRollback(ctx context.Context, req *vtctldatapb.ReshardRollbackRequest) (*vtctldatapb.ReshardRollbackResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.ReshardRollback")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("skip_vdiff", req.SkipVdiff)
+
+	timeout, _, err := protoutil.DurationFromProto(req.Timeout)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid timeout")
+	}
+	if timeout == 0 {
+		timeout = defaultDuration
+	}
+	maxReplicationLagAllowed, _, err := protoutil.DurationFromProto(req.MaxReplicationLagAllowed)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid max replication lag allowed")
+	}
+	if maxReplicationLagAllowed == 0 {
+		maxReplicationLagAllowed = defaultDuration
+	}
+
+	resp := &vtctldatapb.ReshardRollbackResponse{}
+	report := func(step RollbackStep, skipped bool, format string, args ...any) {
+		result := &vtctldatapb.ReshardRollbackResponse_Step{Name: string(step), Skipped: skipped, Message: fmt.Sprintf(format, args...)}
+		resp.Steps = append(resp.Steps, result)
+		log.Infof("Rollback of workflow %s.%s: %s: %s", req.Keyspace, req.Workflow, step, result.Message)
+	}
+	setCurrentState := func() {
+		_, currentState, err := s.getWorkflowState(ctx, req.Keyspace, req.Workflow)
+		if err != nil {
+			resp.CurrentState = fmt.Sprintf("Error reloading workflow state after the rollback: %v", err)
+		} else {
+			resp.CurrentState = currentState.String()
+		}
+	}
+	// stop returns the steps done so far with the error of the step at which the rollback
+	// stopped, which is where running the rollback again resumes it.
+	stop := func(step RollbackStep, err error) (*vtctldatapb.ReshardRollbackResponse, error) {
+		log.Errorf("Rollback of workflow %s.%s stopped at %s: %v", req.Keyspace, req.Workflow, step, err)
+		resp.Summary = fmt.Sprintf("Rollback of workflow %s.%s stopped at %s", req.Keyspace, req.Workflow, step)
+		setCurrentState()
+		return resp, vterrors.Wrapf(err, "rollback of workflow %s.%s stopped at %s", req.Keyspace, req.Workflow, step)
+	}
+
+	ts, startState, err := s.getWorkflowState(ctx, req.Keyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	if startState.WorkflowType != TypeReshard {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid action for %s workflow: Rollback", startState.WorkflowType)
+	}
+	resp.StartState = startState.String()
+
+	// The writes are switched back using the reverse workflow. When the workflow is found
+	// with its writes not switched but the reverse workflow has created its journals, a
+	// previous rollback failed after the point of no return and is completed.
+	var (
+		reverseTs    *trafficSwitcher
+		reverseState *State
+		resumeWrites bool
+	)
+	if startState.WritesSwitched {
+		reverseTs, reverseState, err = s.getWorkflowState(ctx, req.Keyspace, ts.reverseWorkflow)
+		if err != nil {
+			return stop(RollbackStepValidate, vterrors.Wrapf(err, "the writes cannot be switched back without the reverse workflow %s.%s", req.Keyspace, ts.reverseWorkflow))
+		}
+	} else {
+		reverseTs, err = s.buildTrafficSwitcher(ctx, req.Keyspace, ts.reverseWorkflow)
+		switch {
+		case errors.Is(err, ErrNoStreams):
+			reverseTs = nil
+		case err != nil:
+			return stop(RollbackStepValidate, err)
+		case !reverseTs.frozen:
+			if resumeWrites, _, err = reverseTs.checkJournals(ctx); err != nil {
+				return stop(RollbackStepValidate, err)
+			}
+		}
+	}
+
+	// Validate the reverse workflow.
+	switch {
+	case startState.WritesSwitched:
+		reason, err := s.canSwitch(ctx, reverseTs, reverseState, DirectionBackward, int64(maxReplicationLagAllowed.Seconds()))
+		if err != nil {
+			return stop(RollbackStepValidate, err)
+		}
+		if reason != "" {
+			return stop(RollbackStepValidate, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot roll back workflow %s.%s at this time: reverse workflow %s: %s",
+				req.Keyspace, req.Workflow, ts.reverseWorkflow, reason))
+		}
+		report(RollbackStepValidate, false, "reverse workflow %s is caught up", ts.reverseWorkflow)
+	case resumeWrites:
+		report(RollbackStepValidate, true, "the writes were already being switched back by reverse workflow %s", ts.reverseWorkflow)
+	default:
+		report(RollbackStepValidate, true, "the writes are served by the original shards")
+	}
+
+	// Switch the reads back.
+	var readTypes []topodatapb.TabletType
+	cells := make(map[string]bool)
+	if len(startState.RdonlyCellsSwitched) > 0 {
+		readTypes = append(readTypes, topodatapb.TabletType_RDONLY)
+	}
+	if len(startState.ReplicaCellsSwitched) > 0 {
+		readTypes = append(readTypes, topodatapb.TabletType_REPLICA)
+	}
+	for _, cell := range append(startState.RdonlyCellsSwitched, startState.ReplicaCellsSwitched...) {
+		cells[cell] = true
+	}
+	if len(readTypes) > 0 {
+		readCells := maps.Keys(cells)
+		sort.Strings(readCells)
+		readReq := &vtctldatapb.WorkflowSwitchTrafficRequest{
+			Keyspace:    req.Keyspace,
+			Workflow:    req.Workflow,
+			Cells:       readCells,
+			TabletTypes: readTypes,
+			Direction:   int32(DirectionBackward),
+		}
+		if _, err := s.switchReads(ctx, readReq, ts, startState, timeout, false, DirectionBackward); err != nil {
+			return stop(RollbackStepSwitchReads, vterrors.Wrapf(err, "failed to switch the reads back"))
+		}
+		report(RollbackStepSwitchReads, false, "switched the %s reads back in cells %s",
+			topoproto.MakeStringTypeCSV(readTypes), strings.Join(readReq.Cells, ","))
+	} else {
+		report(RollbackStepSwitchReads, true, "the reads are served by the original shards")
+	}
+
+	// Switch the writes back, which also recreates and starts the streams of the workflow.
+	if startState.WritesSwitched || resumeWrites {
+		writeReq := &vtctldatapb.WorkflowSwitchTrafficRequest{
+			Keyspace:                 req.Keyspace,
+			Workflow:                 ts.reverseWorkflow,
+			TabletTypes:              []topodatapb.TabletType{topodatapb.TabletType_PRIMARY},
+			EnableReverseReplication: true,
+		}
+		if _, _, err := s.switchWrites(ctx, writeReq, reverseTs, timeout, false); err != nil {
+			return stop(RollbackStepSwitchWrites, vterrors.Wrapf(err, "failed to switch the writes back"))
+		}
+		report(RollbackStepSwitchWrites, false, "switched the writes back using reverse workflow %s", ts.reverseWorkflow)
+	} else {
+		report(RollbackStepSwitchWrites, true, "the writes are served by the original shards")
+	}
+
+	// Start the streams of the workflow.
+	wf, err := s.GetWorkflow(ctx, req.Keyspace, req.Workflow, false)
+	if err != nil {
+		return stop(RollbackStepStartWorkflow, err)
+	}
+	started, err := s.startRollbackStreams(ctx, wf)
+	if started > 0 {
+		report(RollbackStepStartWorkflow, false, "started %d streams of workflow %s", started, req.Workflow)
+	}
+	if err != nil {
+		return stop(RollbackStepStartWorkflow, err)
+	}
+	if started == 0 {
+		report(RollbackStepStartWorkflow, true, "the streams of workflow %s are running", req.Workflow)
+	}
+
+	// Verify the workflow.
+	if req.SkipVdiff {
+		report(RollbackStepCreateVDiff, true, "skipped as requested")
+		report(RollbackStepWaitForVDiff, true, "skipped as requested")
+	} else {
+		resp.VdiffUuid = rollbackVDiffUUID(req.Keyspace, req.Workflow, wf)
+		created, err := s.createRollbackVDiff(ctx, req.Keyspace, req.Workflow, resp.VdiffUuid, timeout)
+		if err != nil {
+			return stop(RollbackStepCreateVDiff, err)
+		}
+		if created {
+			report(RollbackStepCreateVDiff, false, "created vdiff %s", resp.VdiffUuid)
+		} else {
+			report(RollbackStepCreateVDiff, true, "vdiff %s was already created", resp.VdiffUuid)
+		}
+		if req.WaitForVdiff {
+			if err := s.waitForRollbackVDiff(ctx, req.Keyspace, req.Workflow, resp.VdiffUuid); err != nil {
+				return stop(RollbackStepWaitForVDiff, err)
+			}
+			report(RollbackStepWaitForVDiff, false, "vdiff %s completed without differences", resp.VdiffUuid)
+		} else {
+			report(RollbackStepWaitForVDiff, true, "not waiting for vdiff %s to complete", resp.VdiffUuid)
+		}
+	}
+
+	resp.Summary = fmt.Sprintf("Rollback was successful for workflow %s.%s", req.Keyspace, req.Workflow)
+	setCurrentState()
+	return resp, nil
+}
+
+// startRollbackStreams starts the stopped streams of the workflow and returns how many
+// were started. The streams are only frozen if the writes are still switched.
+func (s *Server) startRollbackStreams(ctx context.Context, wf *vtctldatapb.Workflow) (int, error) {
+	started := 0
+	for _, shardStreams := range wf.ShardStreams {
+		for _, stream := range shardStreams.Streams {
+			if stream.Message == Frozen {
+				return started, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "stream %d of workflow %s on shard %s is frozen: the writes have not been switched back",
+					stream.Id, wf.Name, stream.Shard)
+			}
+			if stream.State != binlogdatapb.VReplicationWorkflowState_Stopped.String() {
+				continue
+			}
+			if _, err := s.VReplicationExec(ctx, stream.Tablet, binlogplayer.StartVReplication(int32(stream.Id))); err != nil {
+				return started, vterrors.Wrapf(err, "failed to start stream %d of workflow %s on shard %s", stream.Id, wf.Name, stream.Shard)
+			}
+			started++
+		}
+	}
+	return started, nil
+}
+
+// rollbackVDiffUUID returns the UUID of the VDiff verifying the workflow after a rollback.
+// The UUID depends on the streams of the workflow, which are recreated when the writes
+// are switched back, so that a resumed rollback finds the VDiff it created.
+func rollbackVDiffUUID(keyspace, workflow string, wf *vtctldatapb.Workflow) string {
+	var streams []string
+	for _, shardStreams := range wf.ShardStreams {
+		for _, stream := range shardStreams.Streams {
+			streams = append(streams, fmt.Sprintf("%s:%d", stream.Shard, stream.Id))
+		}
+	}
+	sort.Strings(streams)
+	name := fmt.Sprintf("rollback/%s/%s/%s", keyspace, workflow, strings.Join(streams, ","))
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(name)).String()
+}
+
+// createRollbackVDiff creates the VDiff unless it already exists, and returns true if it was created.
+func (s *Server) createRollbackVDiff(ctx context.Context, keyspace, workflow, vdiffUUID string, timeout time.Duration) (bool, error) {
+	show, err := s.VDiffShow(ctx, &vtctldatapb.VDiffShowRequest{
+		TargetKeyspace: keyspace,
+		Workflow:       workflow,
+		Arg:            vdiff.AllActionArg,
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, tabletResp := range show.TabletResponses {
+		qr := sqltypes.Proto3ToResult(tabletResp.GetOutput())
+		if qr == nil {
+			continue
+		}
+		for _, row := range qr.Named().Rows {
+			if row.AsString("vdiff_uuid", "") == vdiffUUID {
+				return false, nil
+			}
+		}
+	}
+	_, err = s.VDiffCreate(ctx, &vtctldatapb.VDiffCreateRequest{
+		Workflow:                    workflow,
+		TargetKeyspace:              keyspace,
+		Uuid:                        vdiffUUID,
+		TabletTypes:                 []topodatapb.TabletType{topodatapb.TabletType_RDONLY, topodatapb.TabletType_REPLICA, topodatapb.TabletType_PRIMARY},
+		TabletSelectionPreference:   tabletmanagerdatapb.TabletSelectionPreference_INORDER,
+		Limit:                       math.MaxInt64,
+		FilteredReplicationWaitTime: protoutil.DurationToProto(timeout),
+		MaxExtraRowsToCompare:       1000,
+		AutoRetry:                   true,
+	})
+	if err != nil {
+		return false, vterrors.Wrapf(err, "failed to create vdiff %s", vdiffUUID)
+	}
+	return true, nil
+}
+
+// waitForRollbackVDiff waits for the VDiff to complete on all of the shards.
+func (s *Server) waitForRollbackVDiff(ctx context.Context, keyspace, workflow, vdiffUUID string) error {
+	ticker := time.NewTicker(vdiffPollInterval)
+	defer ticker.Stop()
+	for {
+		show, err := s.VDiffShow(ctx, &vtctldatapb.VDiffShowRequest{
+			TargetKeyspace: keyspace,
+			Workflow:       workflow,
+			Arg:            vdiffUUID,
+		})
+		if err != nil {
+			return err
+		}
+		pending, err := rollbackVDiffProgress(vdiffUUID, show)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		log.Infof("Rollback of workflow %s.%s: %s: waiting for vdiff %s on shards %s", keyspace, workflow, RollbackStepWaitForVDiff, vdiffUUID, strings.Join(pending, ","))
+		select {
+		case <-ctx.Done():
+			return vterrors.Errorf(vtrpcpb.Code_DEADLINE_EXCEEDED, "vdiff %s did not complete on shards %s: %v",
+				vdiffUUID, strings.Join(pending, ","), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollbackVDiffProgress returns the shards on which the VDiff has not completed yet, or
+// an error if it failed or found differences.
+func rollbackVDiffProgress(vdiffUUID string, show *vtctldatapb.VDiffShowResponse) ([]string, error) {
+	var pending []string
+	for shard, tabletResp := range show.TabletResponses {
+		qr := sqltypes.Proto3ToResult(tabletResp.GetOutput())
+		if qr == nil || len(qr.Rows) == 0 {
+			pending = append(pending, shard)
+			continue
+		}
+		completed := true
+		for _, row := range qr.Named().Rows {
+			switch state := row.AsString("vdiff_state", ""); vdiff.VDiffState(state) {
+			case vdiff.CompletedState:
+			case vdiff.ErrorState, vdiff.StoppedState:
+				return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "vdiff %s is in state %s on shard %s: %s",
+					vdiffUUID, state, shard, row.AsString("last_error", ""))
+			default:
+				completed = false
+			}
+			if row.AsInt64("has_mismatch", 0) == 1 {
+				return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "vdiff %s found differences in table %s on shard %s",
+					vdiffUUID, row.AsString("table_name", ""), shard)
+			}
+		}
+		if !completed {
+			pending = append(pending, shard)
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/sqltypes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+func TestRollbackVDiffUUID(t *testing.T) {
+	workflow := func(ids map[string][]int64) *vtctldatapb.Workflow {
+		wf := &vtctldatapb.Workflow{ShardStreams: make(map[string]*vtctldatapb.Workflow_ShardStream)}
+		for shard, streamIDs := range ids {
+			shardStreams := &vtctldatapb.Workflow_ShardStream{}
+			for _, id := range streamIDs {
+				shardStreams.Streams = append(shardStreams.Streams, &vtctldatapb.Workflow_Stream{Id: id, Shard: shard})
+			}
+			wf.ShardStreams[shard+"/zone1-100"] = shardStreams
+		}
+		return wf
+	}
+
+	id := rollbackVDiffUUID("ks", "wf", workflow(map[string][]int64{"-80": {1, 2}, "80-": {1}}))
+	_, err := uuid.Parse(id)
+	require.NoError(t, err)
+	// the same streams give the same vdiff, so that a resumed rollback finds it
+	assert.Equal(t, id, rollbackVDiffUUID("ks", "wf", workflow(map[string][]int64{"80-": {1}, "-80": {2, 1}})))
+	// the streams recreated by another rollback give another vdiff
+	assert.NotEqual(t, id, rollbackVDiffUUID("ks", "wf", workflow(map[string][]int64{"-80": {3, 4}, "80-": {2}})))
+	assert.NotEqual(t, id, rollbackVDiffUUID("ks", "wf2", workflow(map[string][]int64{"-80": {1, 2}, "80-": {1}})))
+}
+
+func TestRollbackVDiffProgress(t *testing.T) {
+	fields := sqltypes.MakeTestFields("vdiff_state|last_error|table_name|has_mismatch", "varchar|varchar|varchar|int64")
+	show := func(rows map[string][]string) *vtctldatapb.VDiffShowResponse {
+		resp := &vtctldatapb.VDiffShowResponse{TabletResponses: make(map[string]*tabletmanagerdatapb.VDiffResponse)}
+		for shard, shardRows := range rows {
+			resp.TabletResponses[shard] = &tabletmanagerdatapb.VDiffResponse{
+				Output: sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, shardRows...)),
+			}
+		}
+		return resp
+	}
+
+	pending, err := rollbackVDiffProgress("uuid1", show(map[string][]string{
+		"-80": {"completed||t1|0", "completed||t2|0"},
+		"80-": {"started||t1|0", "started||t2|0"},
+		"c0-": {"pending||t1|0"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"80-", "c0-"}, pending)
+
+	pending, err = rollbackVDiffProgress("uuid1", show(map[string][]string{
+		"-80": {"completed||t1|0"},
+		"80-": {"completed||t1|0"},
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, err = rollbackVDiffProgress("uuid1", show(map[string][]string{
+		"-80": {"completed||t1|0", "completed||t2|1"},
+	}))
+	assert.EqualError(t, err, "vdiff uuid1 found differences in table t2 on shard -80")
+
+	_, err = rollbackVDiffProgress("uuid1", show(map[string][]string{
+		"80-": {"error|connection refused|t1|0"},
+	}))
+	assert.EqualError(t, err, "vdiff uuid1 is in state error on shard 80-: connection refused")
+}
+
+// newReshardEnv returns a testEnv with workflow wf resharding ks from shard 0 to
+// shards -80 and 80-, with its traffic switched.
+func newReshardEnv(t *testing.T, ctx context.Context) *testEnv {
+	env := newTestEnv(t, ctx, "ks", []string{"0"}, []string{"-80", "80-"})
+	for i, shard := range []string{"-80", "80-"} {
+		env.addStream(t, 200+i*10, "wf", binlogdatapb.VReplicationWorkflowType_Reshard, &binlogdatapb.BinlogSource{
+			Keyspace: "ks",
+			Shard:    "0",
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{Match: "/.*", Filter: shard}},
+			},
+		})
+	}
+	_, err := env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:                 "ks",
+		Workflow:                 "wf",
+		TabletTypes:              []topodatapb.TabletType{topodatapb.TabletType_RDONLY, topodatapb.TabletType_REPLICA, topodatapb.TabletType_PRIMARY},
+		MaxReplicationLagAllowed: protoutil.DurationToProto(time.Minute),
+		Timeout:                  protoutil.DurationToProto(time.Minute),
+		EnableReverseReplication: true,
+		Direction:                int32(DirectionForward),
+	})
+	require.NoError(t, err)
+	requireWritesSwitched(t, ctx, env, true)
+	return env
+}
+
+func requireWritesSwitched(t *testing.T, ctx context.Context, env *testEnv, switched bool) {
+	t.Helper()
+	_, state, err := env.ws.getWorkflowState(ctx, "ks", "wf")
+	require.NoError(t, err)
+	require.Equal(t, switched, state.WritesSwitched)
+}
+
+func stepResults(resp *vtctldatapb.ReshardRollbackResponse) map[RollbackStep]bool {
+	skipped := make(map[RollbackStep]bool)
+	for _, step := range resp.Steps {
+		skipped[RollbackStep(step.Name)] = step.Skipped
+	}
+	return skipped
+}
+
+func TestReshardRollback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardEnv(t, ctx)
+	defer env.close()
+
+	req := &vtctldatapb.ReshardRollbackRequest{
+		Keyspace:                 "ks",
+		Workflow:                 "wf",
+		Timeout:                  protoutil.DurationToProto(time.Minute),
+		MaxReplicationLagAllowed: protoutil.DurationToProto(time.Minute),
+	}
+	resp, err := env.ws.ReshardRollback(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, map[RollbackStep]bool{
+		RollbackStepValidate:      false,
+		RollbackStepSwitchReads:   false,
+		RollbackStepSwitchWrites:  false,
+		RollbackStepStartWorkflow: true,
+		RollbackStepCreateVDiff:   false,
+		RollbackStepWaitForVDiff:  true,
+	}, stepResults(resp))
+	_, state, err := env.ws.getWorkflowState(ctx, "ks", "wf")
+	require.NoError(t, err)
+	assert.False(t, state.WritesSwitched)
+	assert.Empty(t, state.ReplicaCellsSwitched)
+	assert.Empty(t, state.RdonlyCellsSwitched)
+
+	// The workflow streams from the original shard again, and the reverse workflow is frozen.
+	for _, id := range []int{200, 210} {
+		states := env.tmc.streams(id, "wf", "state")
+		require.NotEmpty(t, states)
+		for _, state := range states {
+			assert.Equal(t, "Running", state)
+		}
+		for _, message := range env.tmc.streams(id, "wf", "message") {
+			assert.NotEqual(t, Frozen, message)
+		}
+		vdiffs := env.tmc.vdiffRequests(id)
+		require.Len(t, vdiffs, 1)
+		assert.Equal(t, resp.VdiffUuid, vdiffs[0].VdiffUuid)
+		assert.Equal(t, int64(math.MaxInt64), vdiffs[0].Options.CoreOptions.MaxRows)
+		assert.Equal(t, int64(1000), vdiffs[0].Options.CoreOptions.MaxExtraRowsToCompare)
+	}
+	reverseMessages := env.tmc.streams(100, "wf_reverse", "message")
+	require.NotEmpty(t, reverseMessages)
+	for _, message := range reverseMessages {
+		assert.Equal(t, Frozen, message)
+	}
+
+	// A completed rollback has nothing left to do.
+	resp2, err := env.ws.ReshardRollback(ctx, req)
+	require.NoError(t, err)
+	for _, step := range resp2.Steps {
+		assert.True(t, step.Skipped, "step %s: %s", step.Name, step.Message)
+	}
+	assert.Equal(t, resp.VdiffUuid, resp2.VdiffUuid)
+	assert.Len(t, env.tmc.vdiffRequests(200), 1)
+}
+
+func TestReshardRollbackResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardEnv(t, ctx)
+	defer env.close()
+
+	// The rollback fails after the reverse workflow created its journals, when it freezes
+	// the reverse workflow.
+	env.tmc.failQueries(100, "set message = 'FROZEN'")
+	req := &vtctldatapb.ReshardRollbackRequest{
+		Keyspace:                 "ks",
+		Workflow:                 "wf",
+		Timeout:                  protoutil.DurationToProto(time.Minute),
+		MaxReplicationLagAllowed: protoutil.DurationToProto(time.Minute),
+	}
+	resp, err := env.ws.ReshardRollback(ctx, req)
+	require.ErrorContains(t, err, "rollback of workflow ks.wf stopped at SwitchWritesBack: failed to switch the writes back")
+	// The response reports the steps which were done before the rollback stopped.
+	require.NotNil(t, resp)
+	assert.Equal(t, map[RollbackStep]bool{
+		RollbackStepValidate:    false,
+		RollbackStepSwitchReads: false,
+	}, stepResults(resp))
+	assert.Equal(t, "Rollback of workflow ks.wf stopped at SwitchWritesBack", resp.Summary)
+	assert.NotEmpty(t, resp.CurrentState)
+	assert.Empty(t, env.tmc.vdiffRequests(200))
+
+	env.tmc.failQueries(100, "")
+	resp, err = env.ws.ReshardRollback(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, map[RollbackStep]bool{
+		RollbackStepValidate:      true,
+		RollbackStepSwitchReads:   true,
+		RollbackStepSwitchWrites:  false,
+		RollbackStepStartWorkflow: true,
+		RollbackStepCreateVDiff:   false,
+		RollbackStepWaitForVDiff:  true,
+	}, stepResults(resp))
+	assert.Contains(t, resp.Steps[0].Message, "already being switched back")
+	requireWritesSwitched(t, ctx, env, false)
+	reverseMessages := env.tmc.streams(100, "wf_reverse", "message")
+	require.NotEmpty(t, reverseMessages)
+	for _, message := range reverseMessages {
+		assert.Equal(t, Frozen, message)
+	}
+	assert.Len(t, env.tmc.vdiffRequests(200), 1)
+	assert.Len(t, env.tmc.vdiffRequests(210), 1)
+}
+
+func TestReshardRollbackCannotSwitch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardEnv(t, ctx)
+	defer env.close()
+
+	// The reverse workflow is lagging.
+	env.tmc.setStreams(100, "wf_reverse", "time_updated", sqltypes.NewInt64(time.Now().Add(-time.Hour).Unix()))
+	req := &vtctldatapb.ReshardRollbackRequest{
+		Keyspace:                 "ks",
+		Workflow:                 "wf",
+		Timeout:                  protoutil.DurationToProto(time.Minute),
+		MaxReplicationLagAllowed: protoutil.DurationToProto(30 * time.Second),
+	}
+	resp, err := env.ws.ReshardRollback(ctx, req)
+	require.ErrorContains(t, err, "cannot roll back workflow ks.wf at this time: reverse workflow wf_reverse:")
+	assert.Empty(t, resp.Steps)
+
+	requireWritesSwitched(t, ctx, env, true)
+	reverseMessages := env.tmc.streams(100, "wf_reverse", "message")
+	require.NotEmpty(t, reverseMessages)
+	for _, message := range reverseMessages {
+		assert.NotEqual(t, Frozen, message)
+	}
+	assert.Empty(t, env.tmc.vdiffRequests(200))
+}
+
+func TestReshardRollbackWaitForVDiff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newReshardEnv(t, ctx)
+	defer env.close()
+	oldInterval := vdiffPollInterval
+	vdiffPollInterval = 10 * time.Millisecond
+	defer func() { vdiffPollInterval = oldInterval }()
+
+	// The VDiff of the test tablets never completes.
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Second)
+	defer waitCancel()
+	req := &vtctldatapb.ReshardRollbackRequest{
+		Keyspace:                 "ks",
+		Workflow:                 "wf",
+		Timeout:                  protoutil.DurationToProto(time.Minute),
+		MaxReplicationLagAllowed: protoutil.DurationToProto(time.Minute),
+		WaitForVdiff:             true,
+	}
+	resp, err := env.ws.ReshardRollback(waitCtx, req)
+	require.ErrorContains(t, err, "rollback of workflow ks.wf stopped at WaitForVDiff")
+	require.NotEmpty(t, resp.VdiffUuid)
+	assert.Equal(t, map[RollbackStep]bool{
+		RollbackStepValidate:      false,
+		RollbackStepSwitchReads:   false,
+		RollbackStepSwitchWrites:  false,
+		RollbackStepStartWorkflow: true,
+		RollbackStepCreateVDiff:   false,
+	}, stepResults(resp))
+	requireWritesSwitched(t, ctx, env, false)
+
+	// Running the rollback again only waits for the VDiff it created.
+	resp, err = env.ws.ReshardRollback(ctx, &vtctldatapb.ReshardRollbackRequest{Keyspace: "ks", Workflow: "wf"})
+	require.NoError(t, err)
+	for _, step := range resp.Steps {
+		assert.True(t, step.Skipped, "step %s: %s", step.Name, step.Message)
+	}
+	assert.Len(t, env.tmc.vdiffRequests(200), 1)
+}
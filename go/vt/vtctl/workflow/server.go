@@ -1622,6 +1622,9 @@ func (s *Server) VDiffCreate(ctx context.Context, req *vtctldatapb.VDiffCreateRe
 	span.Annotate("tablet_types", req.TabletTypes)
 	span.Annotate("tables", req.Tables)
 	span.Annotate("auto_retry", req.AutoRetry)
+	span.Annotate("recheck", req.Recheck)
+	span.Annotate("schedule_interval_seconds", req.ScheduleInterval.GetSeconds())
+	span.Annotate("incremental", req.Incremental)
 
 	tabletTypesStr := topoproto.MakeStringTypeCSV(req.TabletTypes)
 	if req.TabletSelectionPreference == tabletmanagerdatapb.TabletSelectionPreference_INORDER {
@@ -1637,7 +1640,7 @@ func (s *Server) VDiffCreate(ctx context.Context, req *vtctldatapb.VDiffCreateRe
 		CoreOptions: &tabletmanagerdatapb.VDiffCoreOptions{
 			Tables:                strings.Join(req.Tables, ","),
 			AutoRetry:             req.AutoRetry,
-			MaxRows:               req.MaxExtraRowsToCompare,
+			MaxRows:               req.Limit,
 			TimeoutSeconds:        req.FilteredReplicationWaitTime.Seconds,
 			MaxExtraRowsToCompare: req.MaxExtraRowsToCompare,
 			UpdateTableStats:      req.UpdateTableStats,
@@ -1646,6 +1649,11 @@ func (s *Server) VDiffCreate(ctx context.Context, req *vtctldatapb.VDiffCreateRe
 			OnlyPks:    req.OnlyPKs,
 			DebugQuery: req.DebugQuery,
 		},
+		ScheduleOptions: &tabletmanagerdatapb.VDiffScheduleOptions{
+			Recheck:         req.Recheck,
+			IntervalSeconds: req.ScheduleInterval.GetSeconds(),
+			Incremental:     req.Incremental,
+		},
 	}
 
 	tabletreq := &tabletmanagerdatapb.VDiffRequest{
@@ -20,19 +20,24 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/encoding/prototext"
 
+	"vitess.io/vitess/go/protoutil"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/discovery"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vttablet/tmclient"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
 )
 
 type fakeTMC struct {
@@ -163,3 +168,45 @@ func TestCheckReshardingJournalExistsOnTablet(t *testing.T) {
 		})
 	}
 }
+
+func TestVDiffCreate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newTestEnv(t, ctx, "ks", []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+	for i, shard := range []string{"-80", "80-"} {
+		env.addStream(t, 200+i*10, "wf", binlogdatapb.VReplicationWorkflowType_Reshard, &binlogdatapb.BinlogSource{
+			Keyspace: "ks",
+			Shard:    "0",
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{Match: "/.*", Filter: shard}},
+			},
+		})
+	}
+
+	resp, err := env.ws.VDiffCreate(ctx, &vtctldatapb.VDiffCreateRequest{
+		Workflow:                    "wf",
+		TargetKeyspace:              "ks",
+		Uuid:                        "c3e5ad6e-7d3b-11ee-b962-0242ac120002",
+		TabletTypes:                 []topodatapb.TabletType{topodatapb.TabletType_REPLICA},
+		TabletSelectionPreference:   tabletmanagerdatapb.TabletSelectionPreference_INORDER,
+		Tables:                      []string{"t1"},
+		Limit:                       100,
+		MaxExtraRowsToCompare:       10,
+		FilteredReplicationWaitTime: protoutil.DurationToProto(30 * time.Second),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "c3e5ad6e-7d3b-11ee-b962-0242ac120002", resp.UUID)
+	for _, id := range []int{200, 210} {
+		vdiffs := env.tmc.vdiffRequests(id)
+		require.Len(t, vdiffs, 1)
+		assert.Equal(t, resp.UUID, vdiffs[0].VdiffUuid)
+		assert.Equal(t, discovery.InOrderHint+"replica", vdiffs[0].Options.PickerOptions.TabletTypes)
+		core := vdiffs[0].Options.CoreOptions
+		assert.Equal(t, "t1", core.Tables)
+		assert.EqualValues(t, 100, core.MaxRows)
+		assert.EqualValues(t, 10, core.MaxExtraRowsToCompare)
+		assert.EqualValues(t, 30, core.TimeoutSeconds)
+	}
+	assert.Empty(t, env.tmc.vdiffRequests(100))
+}
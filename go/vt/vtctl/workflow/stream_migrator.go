@@ -696,14 +696,16 @@ func (sm *StreamMigrator) templatizeKeyRange(ctx context.Context, rule *binlogda
 			continue
 		}
 
+		// The keyrange is the last parameter, after the columns and the vindex if there are any.
+		// There can be several columns when the vindex is a multi-column one.
 		var krExpr sqlparser.SelectExpr
 		switch len(funcExpr.Exprs) {
 		case 1:
 			krExpr = funcExpr.Exprs[0]
-		case 3:
-			krExpr = funcExpr.Exprs[2]
-		default:
+		case 0, 2:
 			return fmt.Errorf("unexpected in_keyrange parameters: %v", sqlparser.String(funcExpr))
+		default:
+			krExpr = funcExpr.Exprs[len(funcExpr.Exprs)-1]
 		}
 
 		aliased, ok := krExpr.(*sqlparser.AliasedExpr)
@@ -726,15 +728,20 @@ func (sm *StreamMigrator) templatizeKeyRange(ctx context.Context, rule *binlogda
 	}
 
 	// There was no in_keyrange expression. Create a new one.
+	// It uses all the columns of the primary vindex, and refers to the vindex by name
+	// so that the params of the vindex, e.g. for a multi-column one, are known.
 	vtable := sm.ts.SourceKeyspaceSchema().Tables[rule.Match]
+	primaryVindex := vtable.ColumnVindexes[0]
 	inkr := &sqlparser.FuncExpr{
 		Name: sqlparser.NewIdentifierCI("in_keyrange"),
-		Exprs: sqlparser.SelectExprs{
-			&sqlparser.AliasedExpr{Expr: &sqlparser.ColName{Name: vtable.ColumnVindexes[0].Columns[0]}},
-			&sqlparser.AliasedExpr{Expr: sqlparser.NewStrLiteral(vtable.ColumnVindexes[0].Type)},
-			&sqlparser.AliasedExpr{Expr: sqlparser.NewStrLiteral("{{.}}")},
-		},
 	}
+	for _, col := range primaryVindex.Columns {
+		inkr.Exprs = append(inkr.Exprs, &sqlparser.AliasedExpr{Expr: &sqlparser.ColName{Name: col}})
+	}
+	inkr.Exprs = append(inkr.Exprs,
+		&sqlparser.AliasedExpr{Expr: sqlparser.NewStrLiteral(fmt.Sprintf("%s.%s", sm.ts.SourceKeyspaceName(), primaryVindex.Name))},
+		&sqlparser.AliasedExpr{Expr: sqlparser.NewStrLiteral("{{.}}")},
+	)
 	sel.AddWhere(inkr)
 	rule.Filter = sqlparser.String(statement)
 	return nil
@@ -173,7 +173,33 @@ func TestTemplatize(t *testing.T) {
 				},
 			},
 		}},
-		out: `[{"ID":0,"Workflow":"","BinlogSource":{"filter":{"rules":[{"match":"t1","filter":"select * from t1 where in_keyrange(c1, 'hash', '{{.}}')"}]}}}]`,
+		out: `[{"ID":0,"Workflow":"","BinlogSource":{"filter":{"rules":[{"match":"t1","filter":"select * from t1 where in_keyrange(c1, 'ks.thash', '{{.}}')"}]}}}]`,
+	}, {
+		// Select expression with no keyrange value on a table with a multi-column vindex
+		in: []*VReplicationStream{{
+			BinlogSource: &binlogdatapb.BinlogSource{
+				Filter: &binlogdatapb.Filter{
+					Rules: []*binlogdatapb.Rule{{
+						Match:  "t3",
+						Filter: "select * from t3",
+					}},
+				},
+			},
+		}},
+		out: `[{"ID":0,"Workflow":"","BinlogSource":{"filter":{"rules":[{"match":"t3","filter":"select * from t3 where in_keyrange(c1, c2, 'ks.tmulticol', '{{.}}')"}]}}}]`,
+	}, {
+		// Select expression with the keyrange of a multi-column vindex
+		in: []*VReplicationStream{{
+			BinlogSource: &binlogdatapb.BinlogSource{
+				Filter: &binlogdatapb.Filter{
+					Rules: []*binlogdatapb.Rule{{
+						Match:  "t3",
+						Filter: "select * from t3 where in_keyrange(c1, c2, 'ks.tmulticol', '-80')",
+					}},
+				},
+			},
+		}},
+		out: `[{"ID":0,"Workflow":"","BinlogSource":{"filter":{"rules":[{"match":"t3","filter":"select * from t3 where in_keyrange(c1, c2, 'ks.tmulticol', '{{.}}')"}]}}}]`,
 	}, {
 		// Select expresstion with one keyrange value
 		in: []*VReplicationStream{{
@@ -285,6 +311,12 @@ func TestTemplatize(t *testing.T) {
 			"thash": {
 				Type: "hash",
 			},
+			"tmulticol": {
+				Type: "multicol",
+				Params: map[string]string{
+					"column_count": "2",
+				},
+			},
 		},
 		Tables: map[string]*vschemapb.Table{
 			"t1": {
@@ -299,6 +331,12 @@ func TestTemplatize(t *testing.T) {
 					Name:    "thash",
 				}},
 			},
+			"t3": {
+				ColumnVindexes: []*vschemapb.ColumnVindex{{
+					Columns: []string{"c1", "c2"},
+					Name:    "tmulticol",
+				}},
+			},
 			"ref": {
 				Type: vindexes.TypeReference,
 			},
@@ -308,6 +346,7 @@ func TestTemplatize(t *testing.T) {
 	require.NoError(t, err, "could not create test keyspace %+v", vs)
 
 	ts := &testTrafficSwitcher{
+		trafficSwitcher:      trafficSwitcher{sourceKSSchema: ksschema},
 		sourceKeyspaceSchema: ksschema,
 	}
 	for _, tt := range tests {
@@ -49,6 +49,7 @@ var (
 	VT03024 = errorWithoutState("VT03024", vtrpcpb.Code_INVALID_ARGUMENT, "'%s' user defined variable does not exists", "The query cannot be prepared using the user defined variable as it does not exists for this session.")
 	VT03025 = errorWithState("VT03025", vtrpcpb.Code_INVALID_ARGUMENT, WrongArguments, "Incorrect arguments to %s", "The execute statement have wrong number of arguments")
 	VT03026 = errorWithoutState("VT03024", vtrpcpb.Code_INVALID_ARGUMENT, "'%s' bind variable does not exists", "The query cannot be executed as missing the bind variable.")
+	VT03027 = errorWithState("VT03027", vtrpcpb.Code_INVALID_ARGUMENT, WrongNumberOfColumnsInSelect, "in definition of common table expression '%s', SELECT list and column names list have different column counts", "The number of columns in the column list of the common table expression does not match the number of columns in its SELECT list.")
 
 	VT05001 = errorWithState("VT05001", vtrpcpb.Code_NOT_FOUND, DbDropExists, "cannot drop database '%s'; database does not exists", "The given database does not exist; Vitess cannot drop it.")
 	VT05002 = errorWithState("VT05002", vtrpcpb.Code_NOT_FOUND, BadDb, "cannot alter database '%s'; unknown database", "The given database does not exist; Vitess cannot alter it.")
@@ -81,6 +82,7 @@ var (
 	VT09017 = errorWithoutState("VT09017", vtrpcpb.Code_FAILED_PRECONDITION, "%s", "Invalid syntax for the statement type.")
 	VT09018 = errorWithoutState("VT09018", vtrpcpb.Code_FAILED_PRECONDITION, "%s", "Invalid syntax for the vindex function statement.")
 	VT09019 = errorWithoutState("VT09019", vtrpcpb.Code_FAILED_PRECONDITION, "%s has cyclic foreign keys", "Vitess doesn't support cyclic foreign keys.")
+	VT09020 = errorWithoutState("VT09020", vtrpcpb.Code_FAILED_PRECONDITION, "recursive query aborted after %d iterations", "The recursive common table expression did not reach a fixpoint within the maximum number of iterations Vitess allows. Please check the recursive query for a termination condition.")
 
 	VT10001 = errorWithoutState("VT10001", vtrpcpb.Code_ABORTED, "foreign key constraints are not allowed", "Foreign key constraints are not allowed, see https://vitess.io/blog/2021-06-15-online-ddl-why-no-fk/.")
 
@@ -124,6 +126,7 @@ var (
 		VT03024,
 		VT03025,
 		VT03026,
+		VT03027,
 		VT05001,
 		VT05002,
 		VT05003,
@@ -151,6 +154,8 @@ var (
 		VT09016,
 		VT09017,
 		VT09018,
+		VT09019,
+		VT09020,
 		VT10001,
 		VT12001,
 		VT12002,
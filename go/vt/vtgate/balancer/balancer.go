@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package balancer decides in which order the TabletGateway tries the healthy tablets of a target.
+package balancer
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"vitess.io/vitess/go/vt/discovery"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+const (
+	// ModeRandom prefers the tablets in the local cell, and picks randomly among them.
+	ModeRandom = "random"
+	// ModeLeastLoaded prefers the tablets in the local cell, and uses the power of two choices
+	// to pick the tablet with the lowest load, based on the observed query latency,
+	// the number of queries in flight and the replication lag.
+	ModeLeastLoaded = "least_loaded"
+)
+
+// TabletBalancer orders the healthy tablets of a target before the gateway sends a query to them.
+type TabletBalancer interface {
+	// ShuffleTablets reorders the tablets in place. The gateway uses the first one,
+	// and only moves on to the next ones when it has to retry.
+	ShuffleTablets(target *querypb.Target, tablets []*discovery.TabletHealth)
+
+	// QueryStarted is called right before a query is sent to the tablet.
+	QueryStarted(alias string)
+
+	// QueryDone is called when a query started with QueryStarted returns.
+	// The latency of streaming queries says nothing about the tablet,
+	// so it is zero for them, and only the number of queries in flight is tracked.
+	QueryDone(alias string, latency time.Duration)
+}
+
+// Config contains the settings of a TabletBalancer.
+type Config struct {
+	Mode      string
+	LocalCell string
+
+	// LatencyDecay is how long it takes for an observed latency to lose most of its weight
+	// in the moving average of the latency of a tablet. Only used by ModeLeastLoaded.
+	LatencyDecay time.Duration
+	// LagPenalty is the latency added to a tablet for every second of replication lag.
+	// Only used by ModeLeastLoaded.
+	LagPenalty time.Duration
+}
+
+// NewTabletBalancer creates the TabletBalancer for the given mode.
+func NewTabletBalancer(cfg Config) (TabletBalancer, error) {
+	switch cfg.Mode {
+	case ModeRandom, "":
+		return &randomBalancer{localCell: cfg.LocalCell}, nil
+	case ModeLeastLoaded:
+		return newLeastLoadedBalancer(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown tablet balancer mode %q, allowed values: %s, %s", cfg.Mode, ModeRandom, ModeLeastLoaded)
+	}
+}
+
+// randomBalancer is the default balancer
+type randomBalancer struct {
+	localCell string
+}
+
+var _ TabletBalancer = (*randomBalancer)(nil)
+
+// ShuffleTablets implements the TabletBalancer interface
+func (b *randomBalancer) ShuffleTablets(_ *querypb.Target, tablets []*discovery.TabletHealth) {
+	shuffleTablets(b.localCell, tablets)
+}
+
+// QueryStarted implements the TabletBalancer interface
+func (b *randomBalancer) QueryStarted(string) {}
+
+// QueryDone implements the TabletBalancer interface
+func (b *randomBalancer) QueryDone(string, time.Duration) {}
+
+// shuffleTablets moves the tablets in the given cell to the front, and shuffles both groups.
+// It returns the number of tablets in the given cell.
+func shuffleTablets(cell string, tablets []*discovery.TabletHealth) int {
+	sameCell, diffCell, sameCellMax := 0, 0, -1
+	length := len(tablets)
+
+	// move all same cell tablets to the front, this is O(n)
+	for {
+		sameCellMax = diffCell - 1
+		sameCell = nextTablet(cell, tablets, sameCell, length, true)
+		diffCell = nextTablet(cell, tablets, diffCell, length, false)
+		// either no more diffs or no more same cells should stop the iteration
+		if sameCell < 0 || diffCell < 0 {
+			break
+		}
+
+		if sameCell < diffCell {
+			// fast forward the `sameCell` lookup to `diffCell + 1`, `diffCell` unchanged
+			sameCell = diffCell + 1
+		} else {
+			// sameCell > diffCell, swap needed
+			tablets[sameCell], tablets[diffCell] = tablets[diffCell], tablets[sameCell]
+			sameCell++
+			diffCell++
+		}
+	}
+	if diffCell < 0 {
+		// every tablet from the last diffCell on is in the same cell
+		sameCellMax = length - 1
+	}
+
+	// shuffle in same cell tablets
+	for i := sameCellMax; i > 0; i-- {
+		swap := rand.Intn(i + 1)
+		tablets[i], tablets[swap] = tablets[swap], tablets[i]
+	}
+
+	// shuffle in diff cell tablets
+	for i, diffCellMin := length-1, sameCellMax+1; i > diffCellMin; i-- {
+		swap := rand.Intn(i-sameCellMax) + diffCellMin
+		tablets[i], tablets[swap] = tablets[swap], tablets[i]
+	}
+	return sameCellMax + 1
+}
+
+func nextTablet(cell string, tablets []*discovery.TabletHealth, offset, length int, sameCell bool) int {
+	for ; offset < length; offset++ {
+		if (tablets[offset].Tablet.Alias.Cell == cell) == sameCell {
+			return offset
+		}
+	}
+	return -1
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package balancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func newTabletHealth(uid uint32, cell string, lag uint32) *discovery.TabletHealth {
+	return &discovery.TabletHealth{
+		Tablet:  topo.NewTablet(uid, cell, "host"),
+		Serving: true,
+		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: lag},
+	}
+}
+
+func alias(th *discovery.TabletHealth) string {
+	return topoproto.TabletAliasString(th.Tablet.Alias)
+}
+
+func TestNewTabletBalancer(t *testing.T) {
+	b, err := NewTabletBalancer(Config{Mode: ModeRandom})
+	require.NoError(t, err)
+	assert.IsType(t, &randomBalancer{}, b)
+
+	b, err = NewTabletBalancer(Config{})
+	require.NoError(t, err)
+	assert.IsType(t, &randomBalancer{}, b)
+
+	b, err = NewTabletBalancer(Config{Mode: ModeLeastLoaded})
+	require.NoError(t, err)
+	assert.IsType(t, &leastLoadedBalancer{}, b)
+
+	_, err = NewTabletBalancer(Config{Mode: "round_robin"})
+	require.EqualError(t, err, `unknown tablet balancer mode "round_robin", allowed values: random, least_loaded`)
+}
+
+func TestShuffleTabletsSameCellCount(t *testing.T) {
+	tests := []struct {
+		cells    []string
+		sameCell int
+	}{
+		{cells: []string{"cell1", "cell1", "cell1"}, sameCell: 3},
+		{cells: []string{"cell2", "cell2"}, sameCell: 0},
+		{cells: []string{"cell2", "cell1", "cell2", "cell1"}, sameCell: 2},
+		{cells: []string{"cell1"}, sameCell: 1},
+		{cells: nil, sameCell: 0},
+	}
+	for _, tt := range tests {
+		var tablets []*discovery.TabletHealth
+		for i, cell := range tt.cells {
+			tablets = append(tablets, newTabletHealth(uint32(i), cell, 0))
+		}
+		for i := 0; i < 10; i++ {
+			require.Equal(t, tt.sameCell, shuffleTablets("cell1", tablets), "%v", tt.cells)
+			for j, th := range tablets {
+				assert.Equal(t, j < tt.sameCell, th.Tablet.Alias.Cell == "cell1", "%v", tt.cells)
+			}
+		}
+	}
+}
+
+func TestLeastLoadedPrefersLowLatency(t *testing.T) {
+	b := newLeastLoadedBalancer(Config{Mode: ModeLeastLoaded, LocalCell: "cell1", LatencyDecay: time.Second})
+	fast := newTabletHealth(1, "cell1", 0)
+	slow := newTabletHealth(2, "cell1", 0)
+
+	b.QueryStarted(alias(fast))
+	b.QueryDone(alias(fast), time.Millisecond)
+	b.QueryStarted(alias(slow))
+	b.QueryDone(alias(slow), 100*time.Millisecond)
+
+	for i := 0; i < 20; i++ {
+		tablets := []*discovery.TabletHealth{slow, fast}
+		b.ShuffleTablets(nil, tablets)
+		require.Equal(t, fast, tablets[0])
+	}
+}
+
+func TestLeastLoadedPrefersFewerQueriesInFlight(t *testing.T) {
+	b := newLeastLoadedBalancer(Config{Mode: ModeLeastLoaded, LocalCell: "cell1", LatencyDecay: time.Second})
+	idle := newTabletHealth(1, "cell1", 0)
+	busy := newTabletHealth(2, "cell1", 0)
+
+	// both tablets have the same latency, but the busy one has more queries in flight
+	for _, th := range []*discovery.TabletHealth{idle, busy} {
+		b.QueryStarted(alias(th))
+		b.QueryDone(alias(th), 10*time.Millisecond)
+	}
+	b.QueryStarted(alias(busy))
+	b.QueryStarted(alias(busy))
+
+	for i := 0; i < 20; i++ {
+		tablets := []*discovery.TabletHealth{busy, idle}
+		b.ShuffleTablets(nil, tablets)
+		require.Equal(t, idle, tablets[0])
+	}
+
+	b.QueryDone(alias(busy), 10*time.Millisecond)
+	b.QueryDone(alias(busy), 10*time.Millisecond)
+	assert.Equal(t, 0, b.tablets[alias(busy)].inFlight)
+}
+
+func TestLeastLoadedReplicationLag(t *testing.T) {
+	b := newLeastLoadedBalancer(Config{Mode: ModeLeastLoaded, LocalCell: "cell1", LatencyDecay: time.Second, LagPenalty: 10 * time.Millisecond})
+	lagging := newTabletHealth(1, "cell1", 5)
+	upToDate := newTabletHealth(2, "cell1", 0)
+
+	for i := 0; i < 20; i++ {
+		tablets := []*discovery.TabletHealth{lagging, upToDate}
+		b.ShuffleTablets(nil, tablets)
+		require.Equal(t, upToDate, tablets[0])
+	}
+}
+
+func TestLeastLoadedPrefersLocalCell(t *testing.T) {
+	b := newLeastLoadedBalancer(Config{Mode: ModeLeastLoaded, LocalCell: "cell1", LatencyDecay: time.Second})
+	local := newTabletHealth(1, "cell1", 0)
+	remote := newTabletHealth(2, "cell2", 0)
+
+	b.QueryStarted(alias(local))
+	b.QueryDone(alias(local), time.Second)
+
+	for i := 0; i < 20; i++ {
+		tablets := []*discovery.TabletHealth{remote, local}
+		b.ShuffleTablets(nil, tablets)
+		require.Equal(t, local, tablets[0])
+	}
+}
+
+func TestLeastLoadedLatencyDecay(t *testing.T) {
+	now := time.Now()
+	b := newLeastLoadedBalancer(Config{Mode: ModeLeastLoaded, LatencyDecay: time.Second})
+	b.now = func() time.Time { return now }
+
+	b.QueryStarted("cell1-1")
+	b.QueryDone("cell1-1", 100*time.Millisecond)
+	assert.EqualValues(t, 100*time.Millisecond, b.tablets["cell1-1"].latency)
+
+	// a slower query is taken into account right away
+	b.QueryStarted("cell1-1")
+	b.QueryDone("cell1-1", 200*time.Millisecond)
+	assert.EqualValues(t, 200*time.Millisecond, b.tablets["cell1-1"].latency)
+
+	// faster queries only bring the latency down over time
+	now = now.Add(time.Second)
+	b.QueryStarted("cell1-1")
+	b.QueryDone("cell1-1", 10*time.Millisecond)
+	latency := b.tablets["cell1-1"].latency
+	assert.Less(t, latency, float64(200*time.Millisecond))
+	assert.Greater(t, latency, float64(10*time.Millisecond))
+
+	// streaming queries only count as queries in flight
+	b.QueryStarted("cell1-1")
+	b.QueryDone("cell1-1", 0)
+	assert.Equal(t, latency, b.tablets["cell1-1"].latency)
+
+	// a tablet that has not been used for a long time is forgotten
+	now = now.Add(time.Minute)
+	b.QueryStarted("cell1-1")
+	b.QueryDone("cell1-1", 0)
+	assert.NotContains(t, b.tablets, "cell1-1")
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package balancer
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// leastLoadedBalancer picks the tablet to use with the power of two choices:
+// it takes two random tablets, and uses the one with the lowest load.
+// The load of a tablet is its latency multiplied by the number of queries in flight,
+// the latency being a moving average of the observed latencies that decays over time,
+// and that jumps up right away when a slower query is observed (peak EWMA).
+// Using two random tablets instead of the least loaded one keeps the tablets
+// with the best stats from getting all the traffic at once.
+type leastLoadedBalancer struct {
+	localCell    string
+	latencyDecay time.Duration
+	lagPenalty   time.Duration
+
+	// now is replaced in tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	tablets map[string]*tabletLoad
+}
+
+// tabletLoad contains what the balancer observed for a tablet
+type tabletLoad struct {
+	inFlight int
+	// latency is the moving average of the latency of the queries, in nanoseconds
+	latency    float64
+	lastUpdate time.Time
+}
+
+var _ TabletBalancer = (*leastLoadedBalancer)(nil)
+
+func newLeastLoadedBalancer(cfg Config) *leastLoadedBalancer {
+	return &leastLoadedBalancer{
+		localCell:    cfg.LocalCell,
+		latencyDecay: cfg.LatencyDecay,
+		lagPenalty:   cfg.LagPenalty,
+		now:          time.Now,
+		tablets:      make(map[string]*tabletLoad),
+	}
+}
+
+// ShuffleTablets implements the TabletBalancer interface
+func (b *leastLoadedBalancer) ShuffleTablets(_ *querypb.Target, tablets []*discovery.TabletHealth) {
+	sameCell := shuffleTablets(b.localCell, tablets)
+
+	// the tablets in the local cell are still preferred, so we only choose
+	// between the first two tablets if they are both in the local cell, or both in other cells
+	candidates := sameCell
+	if candidates == 0 {
+		candidates = len(tablets)
+	}
+	if candidates < 2 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cost(tablets[1]) < b.cost(tablets[0]) {
+		tablets[0], tablets[1] = tablets[1], tablets[0]
+	}
+}
+
+// cost returns the load of the tablet. Lower is better.
+func (b *leastLoadedBalancer) cost(th *discovery.TabletHealth) float64 {
+	// tablets without any latency yet are tried, so we learn about them
+	var latency float64 = 1
+	inFlight := 0
+	if load, ok := b.tablets[topoproto.TabletAliasString(th.Tablet.Alias)]; ok {
+		latency = math.Max(latency, load.latency)
+		inFlight = load.inFlight
+	}
+	if th.Stats != nil {
+		latency += float64(th.Stats.ReplicationLagSeconds) * float64(b.lagPenalty)
+	}
+	return latency * float64(inFlight+1)
+}
+
+// QueryStarted implements the TabletBalancer interface
+func (b *leastLoadedBalancer) QueryStarted(alias string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	load, ok := b.tablets[alias]
+	if !ok {
+		load = &tabletLoad{lastUpdate: b.now()}
+		b.tablets[alias] = load
+	}
+	load.inFlight++
+}
+
+// QueryDone implements the TabletBalancer interface
+func (b *leastLoadedBalancer) QueryDone(alias string, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	load, ok := b.tablets[alias]
+	if !ok {
+		return
+	}
+	load.inFlight--
+	if latency > 0 {
+		load.observe(b.now(), float64(latency), b.latencyDecay)
+	}
+	if load.inFlight == 0 && b.now().Sub(load.lastUpdate) > 10*b.latencyDecay {
+		// the latency of this tablet has decayed to nothing, so there is no point in remembering it;
+		// this also makes us forget about the tablets that are gone
+		delete(b.tablets, alias)
+	}
+}
+
+func (l *tabletLoad) observe(now time.Time, latency float64, decay time.Duration) {
+	elapsed := now.Sub(l.lastUpdate)
+	l.lastUpdate = now
+	if latency > l.latency || decay <= 0 {
+		l.latency = latency
+		return
+	}
+	w := math.Exp(-float64(elapsed) / float64(decay))
+	l.latency = l.latency*w + latency*(1-w)
+}
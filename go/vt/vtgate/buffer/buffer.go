@@ -15,6 +15,8 @@ limitations under the License.
 */
 
 // Package buffer provides a buffer for PRIMARY traffic during failovers.
+// Per keyspace policies can also enable the buffering of REPLICA and RDONLY
+// traffic while none of the tablets of a shard is available.
 //
 // Instead of returning an error to the application (when the vttablet primary
 // becomes unavailable), the buffer will automatically retry buffered requests
@@ -28,8 +30,10 @@ package buffer
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/semaphore"
 
@@ -38,6 +42,8 @@ import (
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
 
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
@@ -60,6 +66,17 @@ const (
 	bufferModeDryRun
 )
 
+func (m bufferMode) String() string {
+	switch m {
+	case bufferModeEnabled:
+		return "enabled"
+	case bufferModeDryRun:
+		return "dry_run"
+	default:
+		return "disabled"
+	}
+}
+
 // RetryDoneFunc will be returned for each buffered request and must be called
 // after the buffered request was retried.
 // Without this signal, the buffer would not know how many buffered requests are
@@ -70,6 +87,7 @@ const (
 	ClusterEventReshardingInProgress = "current keyspace is being resharded"
 	ClusterEventReparentInProgress   = "primary is not serving, there may be a reparent operation in progress"
 	ClusterEventMoveTables           = "disallowed due to rule"
+	ClusterEventNoServingTablets     = "no serving tablet is available, the tablets may be restarting or a traffic switch may be in progress"
 )
 
 var ClusterEvents []string
@@ -79,6 +97,7 @@ func init() {
 		ClusterEventReshardingInProgress,
 		ClusterEventReparentInProgress,
 		ClusterEventMoveTables,
+		ClusterEventNoServingTablets,
 	}
 }
 
@@ -123,6 +142,11 @@ func isFailoverError(err error) (string, bool) {
 	return reason, isFailover
 }
 
+// tabletStats returns the healthy tablets of a target, it is implemented by discovery.HealthCheck.
+type tabletStats interface {
+	GetHealthyTabletStats(target *querypb.Target) []*discovery.TabletHealth
+}
+
 // Buffer is used to track ongoing PRIMARY tablet failovers and buffer
 // requests while the PRIMARY tablet is unavailable.
 // Once the new PRIMARY starts accepting requests, buffering stops and requests
@@ -148,10 +172,18 @@ type Buffer struct {
 	mu sync.RWMutex
 	// buffers holds a shardBuffer object per shard, even if no failover is in
 	// progress.
-	// Key Format: "<keyspace>/<shard>"
+	// Key Format: "<keyspace>/<shard>", followed by "@<tablet type>" for
+	// the tablet types other than PRIMARY.
 	buffers map[string]*shardBuffer
 	// stopped is true after Shutdown() was run.
 	stopped bool
+	// policies has the configuration of the keyspaces with a buffering policy.
+	policies map[string]*Config
+	// keyspaceSemas limits how many requests can be buffered for the keyspaces
+	// whose policy changes the buffer size.
+	keyspaceSemas map[string]*semaphore.Weighted
+	// hc is used to find out when the tablets of a non-PRIMARY tablet type are available again.
+	hc tabletStats
 }
 
 // New creates a new Buffer object.
@@ -172,38 +204,113 @@ func New(cfg *Config) *Buffer {
 // If it does not return an error, it may return a RetryDoneFunc which must be
 // called after the request was retried.
 func (b *Buffer) WaitForFailoverEnd(ctx context.Context, keyspace, shard string, err error) (RetryDoneFunc, error) {
+	return b.WaitForTabletTypeFailoverEnd(ctx, keyspace, shard, topodatapb.TabletType_PRIMARY, err)
+}
+
+// WaitForTabletTypeFailoverEnd is WaitForFailoverEnd for the requests of any tablet type.
+// The requests of the tablet types other than PRIMARY are buffered until
+// some of the tablets of their type are available again.
+func (b *Buffer) WaitForTabletTypeFailoverEnd(ctx context.Context, keyspace, shard string, tabletType topodatapb.TabletType, err error) (RetryDoneFunc, error) {
 	// If an err is given, it must be related to a failover.
 	// We never buffer requests with other errors.
 	if err != nil && !CausedByFailover(err) {
 		return nil, nil
 	}
 
-	sb := b.getOrCreateBuffer(keyspace, shard)
+	sb := b.getOrCreateBuffer(keyspace, shard, tabletType)
 	if sb == nil {
 		// Buffer is shut down. Ignore all calls.
-		requestsSkipped.Add([]string{keyspace, shard, skippedShutdown}, 1)
+		requestsSkipped.Add([]string{keyspace, shardStatsName(shard, tabletType), skippedShutdown}, 1)
 		return nil, nil
 	}
-	if sb.disabled() {
-		requestsSkipped.Add([]string{keyspace, shard, skippedDisabled}, 1)
+	cfg, sema := b.configFor(keyspace)
+	mode := cfg.bufferingMode(keyspace, shard)
+	if mode == bufferModeDisabled || !cfg.buffersTabletType(tabletType) {
+		requestsSkipped.Add(append(sb.statsKey, skippedDisabled), 1)
 		return nil, nil
 	}
-	return sb.waitForFailoverEnd(ctx, keyspace, shard, err)
+	return sb.waitForFailoverEnd(ctx, cfg, mode, sema, err)
+}
+
+// Buffers returns true if the requests of the tablet type can be buffered for the shard,
+// i.e. if buffering or its dry-run mode is enabled for the shard and for the tablet type.
+func (b *Buffer) Buffers(keyspace, shard string, tabletType topodatapb.TabletType) bool {
+	cfg, _ := b.configFor(keyspace)
+	return cfg.buffersTabletType(tabletType) && cfg.bufferingMode(keyspace, shard) != bufferModeDisabled
+}
+
+// configFor returns the configuration of the keyspace, and the semaphore limiting its buffer size.
+func (b *Buffer) configFor(keyspace string) (*Config, *semaphore.Weighted) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if cfg, ok := b.policies[keyspace]; ok {
+		if sema, ok := b.keyspaceSemas[keyspace]; ok {
+			return cfg, sema
+		}
+		return cfg, b.bufferSizeSema
+	}
+	return b.config, b.bufferSizeSema
+}
+
+// SetHealthCheck sets the health check used to find out when the tablets of
+// a non-PRIMARY tablet type are available again, and buffering can stop.
+func (b *Buffer) SetHealthCheck(hc tabletStats) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hc = hc
+}
+
+func (b *Buffer) healthCheck() tabletStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.hc
 }
 
 func (b *Buffer) HandleKeyspaceEvent(ksevent *discovery.KeyspaceEvent) {
 	for _, shard := range ksevent.Shards {
-		sb := b.getOrCreateBuffer(shard.Target.Keyspace, shard.Target.Shard)
+		sb := b.getOrCreateBuffer(shard.Target.Keyspace, shard.Target.Shard, topodatapb.TabletType_PRIMARY)
 		if sb != nil {
 			sb.recordKeyspaceEvent(shard.Tablet, shard.Serving, ksevent)
 		}
+		// The traffic of the other tablet types is switched along with the PRIMARY traffic,
+		// or was switched before, so their buffering can stop as well.
+		for _, sb := range b.nonPrimaryBuffers(shard.Target.Keyspace, shard.Target.Shard) {
+			sb.stopBuffering(stopFailoverEndDetected, "a keyspace event was received for the shard")
+		}
 	}
 }
 
-// getOrCreateBuffer returns the ShardBuffer for the given keyspace and shard.
+// nonPrimaryBuffers returns the existing buffers of the shard for the tablet types other than PRIMARY.
+func (b *Buffer) nonPrimaryBuffers(keyspace, shard string) []*shardBuffer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var buffers []*shardBuffer
+	for _, sb := range b.buffers {
+		if sb.keyspace == keyspace && sb.shard == shard && sb.tabletType != topodatapb.TabletType_PRIMARY {
+			buffers = append(buffers, sb)
+		}
+	}
+	return buffers
+}
+
+// bufferKey returns the key of the buffer of the shard and tablet type in Buffer.buffers.
+func bufferKey(keyspace, shard string, tabletType topodatapb.TabletType) string {
+	return topoproto.KeyspaceShardString(keyspace, shardStatsName(shard, tabletType))
+}
+
+// shardStatsName returns the name of the shard used in the stats: the name of the shard
+// for the PRIMARY buffers, followed by "@<tablet type>" for the other tablet types.
+func shardStatsName(shard string, tabletType topodatapb.TabletType) string {
+	if tabletType == topodatapb.TabletType_PRIMARY {
+		return shard
+	}
+	return shard + "@" + topoproto.TabletTypeLString(tabletType)
+}
+
+// getOrCreateBuffer returns the ShardBuffer for the given keyspace, shard and tablet type.
 // It returns nil if Buffer is shut down and all calls should be ignored.
-func (b *Buffer) getOrCreateBuffer(keyspace, shard string) *shardBuffer {
-	key := topoproto.KeyspaceShardString(keyspace, shard)
+func (b *Buffer) getOrCreateBuffer(keyspace, shard string, tabletType topodatapb.TabletType) *shardBuffer {
+	key := bufferKey(keyspace, shard, tabletType)
 	b.mu.RLock()
 	sb, ok := b.buffers[key]
 	stopped := b.stopped
@@ -221,7 +328,7 @@ func (b *Buffer) getOrCreateBuffer(keyspace, shard string) *shardBuffer {
 	// Look it up again because it could have been created in the meantime.
 	sb, ok = b.buffers[key]
 	if !ok {
-		sb = newShardBufferHealthCheck(b, b.config.bufferingMode(keyspace, shard), keyspace, shard)
+		sb = newShardBufferHealthCheck(b, keyspace, shard, tabletType)
 		b.buffers[key] = sb
 	}
 	return sb
@@ -253,3 +360,50 @@ func (b *Buffer) waitForShutdown() {
 		sb.waitForShutdown()
 	}
 }
+
+// ShardBufferStatus is the state of the buffer of a shard and tablet type.
+type ShardBufferStatus struct {
+	Keyspace   string `json:"keyspace"`
+	Shard      string `json:"shard"`
+	TabletType string `json:"tablet_type"`
+	Mode       string `json:"mode"`
+	State      string `json:"state"`
+	QueueSize  int    `json:"queue_size"`
+
+	LastStart *time.Time `json:"last_start,omitempty"`
+	LastEnd   *time.Time `json:"last_end,omitempty"`
+
+	Window                  string `json:"window"`
+	Size                    int    `json:"size"`
+	MaxFailoverDuration     string `json:"max_failover_duration"`
+	MinTimeBetweenFailovers string `json:"min_time_between_failovers"`
+	DrainConcurrency        int    `json:"drain_concurrency"`
+}
+
+// Status returns the state of the buffers of all the shards and tablet types
+// that saw requests, ordered by keyspace, shard and tablet type.
+// While a shard is buffering, its configuration is the one used since buffering started,
+// otherwise it is the configuration of its keyspace.
+func (b *Buffer) Status() []*ShardBufferStatus {
+	b.mu.RLock()
+	buffers := make([]*shardBuffer, 0, len(b.buffers))
+	for _, sb := range b.buffers {
+		buffers = append(buffers, sb)
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(buffers, func(i, j int) bool {
+		if buffers[i].keyspace != buffers[j].keyspace {
+			return buffers[i].keyspace < buffers[j].keyspace
+		}
+		if buffers[i].shard != buffers[j].shard {
+			return buffers[i].shard < buffers[j].shard
+		}
+		return buffers[i].tabletType < buffers[j].tabletType
+	})
+	statuses := make([]*ShardBufferStatus, 0, len(buffers))
+	for _, sb := range buffers {
+		statuses = append(statuses, sb.status())
+	}
+	return statuses
+}
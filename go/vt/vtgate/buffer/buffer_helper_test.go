@@ -71,7 +71,7 @@ func issueRequestAndBlockRetry(ctx context.Context, t *testing.T, b *Buffer, err
 // This check is potentially racy and therefore retried up to a timeout of 10s.
 func waitForRequestsInFlight(b *Buffer, count int) error {
 	start := time.Now()
-	sb := b.getOrCreateBuffer(keyspace, shard)
+	sb := b.getOrCreateBuffer(keyspace, shard, topodatapb.TabletType_PRIMARY)
 	for {
 		got, want := sb.testGetSize(), count
 		if got == want {
@@ -88,7 +88,7 @@ func waitForRequestsInFlight(b *Buffer, count int) error {
 // waitForState polls the buffer data for up to 10 seconds and returns an error
 // if shardBuffer doesn't have the wanted state by then.
 func waitForState(b *Buffer, want bufferState) error {
-	sb := b.getOrCreateBuffer(keyspace, shard)
+	sb := b.getOrCreateBuffer(keyspace, shard, topodatapb.TabletType_PRIMARY)
 	start := time.Now()
 	for {
 		got := sb.testGetState()
@@ -390,7 +390,7 @@ func testPassthroughDuringDrain1(t *testing.T, fail failover) {
 	// Stop buffering and trigger drain.
 	fail(b, newPrimary, keyspace, shard, time.Unix(1, 0))
 
-	if got, want := b.getOrCreateBuffer(keyspace, shard).testGetState(), stateDraining; got != want {
+	if got, want := b.getOrCreateBuffer(keyspace, shard, topodatapb.TabletType_PRIMARY).testGetState(), stateDraining; got != want {
 		t.Fatalf("wrong expected state. got = %v, want = %v", got, want)
 	}
 
@@ -740,7 +740,7 @@ func testWindow1(t *testing.T, fail failover) {
 
 	// At this point the buffer is empty but buffering is still active.
 	// Simulate that the buffering stops because the max duration (10m) was reached.
-	b.getOrCreateBuffer(keyspace, shard).stopBufferingDueToMaxDuration()
+	b.getOrCreateBuffer(keyspace, shard, topodatapb.TabletType_PRIMARY).stopBufferingDueToMaxDuration()
 	// Wait for the failover end to avoid races.
 	if err := waitForState(b, stateIdle); err != nil {
 		t.Fatal(err)
@@ -27,6 +27,8 @@ import (
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
 
 var (
@@ -40,6 +42,9 @@ var (
 
 	bufferDrainConcurrency = 1
 	bufferKeyspaceShards   string
+
+	bufferPoliciesTopoKey        string
+	bufferPoliciesReloadInterval = 30 * time.Second
 )
 
 func registerFlags(fs *pflag.FlagSet) {
@@ -53,6 +58,9 @@ func registerFlags(fs *pflag.FlagSet) {
 
 	fs.IntVar(&bufferDrainConcurrency, "buffer_drain_concurrency", 1, "Maximum number of requests retried simultaneously. More concurrency will increase the load on the PRIMARY vttablet when draining the buffer.")
 	fs.StringVar(&bufferKeyspaceShards, "buffer_keyspace_shards", "", "If not empty, limit buffering to these entries (comma separated). Entry format: keyspace or keyspace/shard. Requires --enable_buffer=true.")
+
+	fs.StringVar(&bufferPoliciesTopoKey, "buffer-policies-topo-key", "", "Name of the vitess metadata key in the global topo holding the JSON buffering policies of the keyspaces, as set with SET @@vitess_metadata.<key> = '<policies>'. A policy overrides the buffer flags for its keyspace, and can enable the buffering of REPLICA and RDONLY requests.")
+	fs.DurationVar(&bufferPoliciesReloadInterval, "buffer-policies-reload-interval", bufferPoliciesReloadInterval, "How often the buffering policies are reloaded from --buffer-policies-topo-key.")
 }
 
 func init() {
@@ -139,6 +147,9 @@ type Config struct {
 
 	DrainConcurrency int
 
+	// TabletTypes are the tablet types whose requests are buffered. If empty, only PRIMARY requests are buffered.
+	TabletTypes []topodatapb.TabletType
+
 	// keyspaces has the same purpose as "shards" but applies to a whole keyspace.
 	Keyspaces map[string]bool
 	// shards is a set of keyspace/shard entries to which buffering is limited.
@@ -0,0 +1,218 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buffer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+// Policy overrides the buffering configuration of the flags for a keyspace.
+// The fields that are not set keep the values of the flags.
+// The policies are stored as a JSON object, indexed by keyspace name, in the topo.
+type Policy struct {
+	// Enabled defaults to true: having a policy enables buffering for the keyspace.
+	Enabled *bool `json:"enabled,omitempty"`
+	DryRun  bool  `json:"dry_run,omitempty"`
+
+	Window                  string `json:"window,omitempty"`
+	Size                    int    `json:"size,omitempty"`
+	MaxFailoverDuration     string `json:"max_failover_duration,omitempty"`
+	MinTimeBetweenFailovers string `json:"min_time_between_failovers,omitempty"`
+	DrainConcurrency        int    `json:"drain_concurrency,omitempty"`
+
+	// TabletTypes are the tablet types whose requests are buffered, PRIMARY by default.
+	// The requests of the other tablet types are buffered when none of their tablets is available.
+	TabletTypes []string `json:"tablet_types,omitempty"`
+}
+
+// ParsePolicies parses the JSON buffering policies, and returns the configuration of each keyspace,
+// based on the given configuration.
+func ParsePolicies(data []byte, base *Config) (map[string]*Config, error) {
+	configs := make(map[string]*Config)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return configs, nil
+	}
+	var policies map[string]*Policy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("invalid buffering policies: %w", err)
+	}
+	for keyspace, policy := range policies {
+		cfg, err := base.withPolicy(policy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid buffering policy for keyspace %s: %w", keyspace, err)
+		}
+		configs[keyspace] = cfg
+	}
+	return configs, nil
+}
+
+// withPolicy returns a copy of the configuration, overridden by the policy
+func (cfg *Config) withPolicy(policy *Policy) (*Config, error) {
+	c := &Config{
+		Enabled: policy.Enabled == nil || *policy.Enabled,
+		DryRun:  policy.DryRun,
+
+		Window:                  cfg.Window,
+		Size:                    cfg.Size,
+		MaxFailoverDuration:     cfg.MaxFailoverDuration,
+		MinTimeBetweenFailovers: cfg.MinTimeBetweenFailovers,
+		DrainConcurrency:        cfg.DrainConcurrency,
+		TabletTypes:             cfg.TabletTypes,
+
+		now: cfg.now,
+	}
+	for _, d := range []struct {
+		value string
+		to    *time.Duration
+	}{
+		{policy.Window, &c.Window},
+		{policy.MaxFailoverDuration, &c.MaxFailoverDuration},
+		{policy.MinTimeBetweenFailovers, &c.MinTimeBetweenFailovers},
+	} {
+		if d.value == "" {
+			continue
+		}
+		var err error
+		if *d.to, err = time.ParseDuration(d.value); err != nil {
+			return nil, err
+		}
+	}
+	if policy.Size != 0 {
+		c.Size = policy.Size
+	}
+	if policy.DrainConcurrency != 0 {
+		c.DrainConcurrency = policy.DrainConcurrency
+	}
+	if len(policy.TabletTypes) > 0 {
+		c.TabletTypes = nil
+		for _, name := range policy.TabletTypes {
+			tabletType, err := topoproto.ParseTabletType(name)
+			if err != nil {
+				return nil, err
+			}
+			c.TabletTypes = append(c.TabletTypes, tabletType)
+		}
+	}
+
+	if c.Window <= 0 || c.Window > c.MaxFailoverDuration {
+		return nil, fmt.Errorf("window must be > 0 and <= max_failover_duration: %v vs. %v", c.Window, c.MaxFailoverDuration)
+	}
+	if c.Size < 1 {
+		return nil, fmt.Errorf("size must be >= 1 (specified value: %d)", c.Size)
+	}
+	if c.DrainConcurrency < 1 {
+		return nil, fmt.Errorf("drain_concurrency must be >= 1 (specified value: %d)", c.DrainConcurrency)
+	}
+	return c, nil
+}
+
+// buffersTabletType returns true if the requests of the tablet type are buffered
+func (cfg *Config) buffersTabletType(tabletType topodatapb.TabletType) bool {
+	if len(cfg.TabletTypes) == 0 {
+		return tabletType == topodatapb.TabletType_PRIMARY
+	}
+	for _, tt := range cfg.TabletTypes {
+		if tt == tabletType {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPolicies replaces the per keyspace configurations. The keyspaces without one use the configuration of the flags.
+// A keyspace with its own buffer size gets its own slots in the buffer, instead of sharing the slots of the flags.
+// The new configuration of a shard is used the next time it starts buffering.
+func (b *Buffer) SetPolicies(configs map[string]*Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	semas := make(map[string]*semaphore.Weighted)
+	for keyspace, cfg := range configs {
+		if cfg.Size == b.config.Size {
+			continue
+		}
+		if old, ok := b.policies[keyspace]; ok && old.Size == cfg.Size {
+			// keep the slots taken by the requests that are buffered right now
+			semas[keyspace] = b.keyspaceSemas[keyspace]
+			continue
+		}
+		semas[keyspace] = semaphore.NewWeighted(int64(cfg.Size))
+	}
+	b.policies = configs
+	b.keyspaceSemas = semas
+}
+
+// LoadPolicies loads the buffering policies from the topo, and keeps reloading them
+// until the context is done. It does nothing if --buffer-policies-topo-key is not set.
+func (b *Buffer) LoadPolicies(ctx context.Context, ts *topo.Server) {
+	if bufferPoliciesTopoKey == "" || ts == nil {
+		return
+	}
+	key, interval := bufferPoliciesTopoKey, bufferPoliciesReloadInterval
+	var last []byte
+	load := func() {
+		metadata, err := ts.GetMetadata(ctx, key)
+		if err != nil && !topo.IsErrType(err, topo.NoNode) {
+			log.Warningf("Unable to load the buffering policies, keeping the previous ones: %v", err)
+			return
+		}
+		data := []byte(metadata[key])
+		if last != nil && bytes.Equal(data, last) {
+			return
+		}
+		last = data
+		configs, err := ParsePolicies(data, b.config)
+		if err != nil {
+			log.Warningf("Invalid buffering policies, keeping the previous ones: %v", err)
+			return
+		}
+		b.SetPolicies(configs)
+		log.Infof("Loaded the buffering policies of %d keyspaces", len(configs))
+	}
+
+	load()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				load()
+			}
+		}
+	}()
+}
+
+// PoliciesConfigured returns true if the buffering policies are loaded from the topo,
+// in which case buffering can be enabled for some keyspaces only.
+func PoliciesConfigured() bool {
+	return bufferPoliciesTopoKey != ""
+}
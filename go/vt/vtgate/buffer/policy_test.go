@@ -0,0 +1,275 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buffer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+func TestParsePolicies(t *testing.T) {
+	base := NewDefaultConfig()
+	tests := []struct {
+		policies string
+		err      string
+	}{{
+		policies: ``,
+	}, {
+		policies: `{"ks1": {"window": "5s", "size": 100, "tablet_types": ["primary", "replica"]}}`,
+	}, {
+		policies: `{"ks1": {"window": "30s"}}`,
+		err:      "invalid buffering policy for keyspace ks1: window must be > 0 and <= max_failover_duration: 30s vs. 20s",
+	}, {
+		policies: `{"ks1": {"window": "5 seconds"}}`,
+		err:      `invalid buffering policy for keyspace ks1: time: unknown unit " seconds" in duration "5 seconds"`,
+	}, {
+		policies: `{"ks1": {"size": -1}}`,
+		err:      "invalid buffering policy for keyspace ks1: size must be >= 1 (specified value: -1)",
+	}, {
+		policies: `{"ks1": {"tablet_types": ["replicas"]}}`,
+		err:      "invalid buffering policy for keyspace ks1: unknown TabletType replicas",
+	}, {
+		policies: `{"ks1": `,
+		err:      "invalid buffering policies: unexpected end of JSON input",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.policies, func(t *testing.T) {
+			_, err := ParsePolicies([]byte(tt.policies), base)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+		})
+	}
+
+	configs, err := ParsePolicies([]byte(`{"ks1": {"window": "5s", "size": 100, "tablet_types": ["replica"]}, "ks2": {"enabled": false, "dry_run": true}}`), base)
+	require.NoError(t, err)
+	ks1 := configs["ks1"]
+	assert.True(t, ks1.Enabled)
+	assert.Equal(t, 5*time.Second, ks1.Window)
+	assert.Equal(t, 100, ks1.Size)
+	assert.Equal(t, base.MaxFailoverDuration, ks1.MaxFailoverDuration)
+	assert.True(t, ks1.buffersTabletType(topodatapb.TabletType_REPLICA))
+	assert.False(t, ks1.buffersTabletType(topodatapb.TabletType_PRIMARY))
+	assert.Equal(t, bufferModeDryRun, configs["ks2"].bufferingMode("ks2", "0"))
+}
+
+func TestPoliciesEnableBuffering(t *testing.T) {
+	resetVariables()
+	defer checkVariables(t)
+
+	b := New(NewDefaultConfig())
+	defer b.Shutdown()
+	assert.False(t, b.Buffers(keyspace, shard, topodatapb.TabletType_PRIMARY))
+
+	configs, err := ParsePolicies([]byte(`{"ks1": {"size": 1}}`), b.config)
+	require.NoError(t, err)
+	b.SetPolicies(configs)
+	assert.True(t, b.Buffers(keyspace, shard, topodatapb.TabletType_PRIMARY))
+	assert.False(t, b.Buffers(keyspace, shard, topodatapb.TabletType_REPLICA))
+	assert.False(t, b.Buffers("ks2", shard, topodatapb.TabletType_PRIMARY))
+
+	stopped := issueRequest(context.Background(), t, b, failoverErr)
+	require.NoError(t, waitForRequestsInFlight(b, 1))
+
+	// the keyspace has its own buffer size: the next request evicts the first one
+	stopped2 := issueRequest(context.Background(), t, b, failoverErr)
+	require.NoError(t, isEvictedError(<-stopped))
+	require.NoError(t, waitForRequestsInFlight(b, 1))
+
+	statuses := b.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, &ShardBufferStatus{
+		Keyspace:   keyspace,
+		Shard:      shard,
+		TabletType: "primary",
+		Mode:       "enabled",
+		State:      string(stateBuffering),
+		QueueSize:  1,
+
+		LastStart: statuses[0].LastStart,
+
+		Window:                  "10s",
+		Size:                    1,
+		MaxFailoverDuration:     "20s",
+		MinTimeBetweenFailovers: "1m0s",
+		DrainConcurrency:        1,
+	}, statuses[0])
+
+	b.HandleKeyspaceEvent(&discovery.KeyspaceEvent{
+		Keyspace: keyspace,
+		Shards: []discovery.ShardEvent{{
+			Tablet:  newPrimary.Alias,
+			Target:  &querypb.Target{Keyspace: keyspace, Shard: shard, TabletType: topodatapb.TabletType_PRIMARY},
+			Serving: true,
+		}},
+	})
+	require.NoError(t, <-stopped2)
+	require.NoError(t, waitForState(b, stateIdle))
+}
+
+type fakeTabletStats struct {
+	mu      sync.Mutex
+	tablets []*discovery.TabletHealth
+}
+
+func (f *fakeTabletStats) GetHealthyTabletStats(target *querypb.Target) []*discovery.TabletHealth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tablets
+}
+
+func (f *fakeTabletStats) setTablets(tablets ...*discovery.TabletHealth) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tablets = tablets
+}
+
+func TestReplicaBuffering(t *testing.T) {
+	resetVariables()
+	defer checkVariables(t)
+	defer func(interval time.Duration) {
+		tabletsPollInterval = interval
+	}(tabletsPollInterval)
+	tabletsPollInterval = time.Millisecond
+
+	b := New(NewDefaultConfig())
+	defer b.Shutdown()
+	hc := &fakeTabletStats{}
+	b.SetHealthCheck(hc)
+
+	configs, err := ParsePolicies([]byte(`{"ks1": {"tablet_types": ["primary", "replica"]}}`), b.config)
+	require.NoError(t, err)
+	b.SetPolicies(configs)
+	require.True(t, b.Buffers(keyspace, shard, topodatapb.TabletType_REPLICA))
+	require.False(t, b.Buffers(keyspace, shard, topodatapb.TabletType_RDONLY))
+
+	noTablets := vterrors.Errorf(vtrpcpb.Code_CLUSTER_EVENT, ClusterEventNoServingTablets)
+	done := make(chan error)
+	go func() {
+		retryDone, err := b.WaitForTabletTypeFailoverEnd(context.Background(), keyspace, shard, topodatapb.TabletType_REPLICA, noTablets)
+		if retryDone != nil {
+			retryDone()
+		}
+		done <- err
+	}()
+
+	sb := b.getOrCreateBuffer(keyspace, shard, topodatapb.TabletType_REPLICA)
+	require.Eventually(t, func() bool {
+		return sb.testGetSize() == 1
+	}, 10*time.Second, time.Millisecond)
+	// the PRIMARY requests are not buffered
+	assert.Equal(t, stateIdle, b.getOrCreateBuffer(keyspace, shard, topodatapb.TabletType_PRIMARY).testGetState())
+	assert.EqualValues(t, 1, requestsBuffered.Counts()["ks1.0@replica"])
+
+	hc.setTablets(&discovery.TabletHealth{Serving: true})
+	require.NoError(t, <-done)
+	require.Eventually(t, func() bool {
+		return sb.testGetState() == stateIdle
+	}, 10*time.Second, time.Millisecond)
+	assert.EqualValues(t, 1, stops.Counts()["ks1.0@replica."+string(stopTabletsAvailable)])
+}
+
+func TestParallelDrain(t *testing.T) {
+	resetVariables()
+	defer checkVariables(t)
+
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.DrainConcurrency = 2
+	b := New(cfg)
+	defer b.Shutdown()
+
+	markRetryDone := make(chan struct{})
+	unblocked := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			retryDone, err := b.WaitForFailoverEnd(context.Background(), keyspace, shard, failoverErr)
+			unblocked <- err
+			<-markRetryDone
+			if retryDone != nil {
+				retryDone()
+			}
+		}()
+	}
+	require.NoError(t, waitForRequestsInFlight(b, 2))
+
+	b.HandleKeyspaceEvent(&discovery.KeyspaceEvent{
+		Keyspace: keyspace,
+		Shards: []discovery.ShardEvent{{
+			Tablet:  newPrimary.Alias,
+			Target:  &querypb.Target{Keyspace: keyspace, Shard: shard, TabletType: topodatapb.TabletType_PRIMARY},
+			Serving: true,
+		}},
+	})
+	// both requests are retried at the same time: the drain does not wait
+	// for the retry of the first one before unblocking the second one
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-unblocked:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("the buffered requests were not retried in parallel")
+		}
+	}
+
+	close(markRetryDone)
+	require.NoError(t, waitForState(b, stateIdle))
+}
+
+func TestLoadPolicies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer func() {
+		bufferPoliciesTopoKey = ""
+		bufferPoliciesReloadInterval = 30 * time.Second
+	}()
+	bufferPoliciesTopoKey = "buffer_policies"
+	bufferPoliciesReloadInterval = 10 * time.Millisecond
+
+	// the topo server is not closed, the reload loop may still be using it when the test ends
+	ts := memorytopo.NewServer(ctx, "cell1")
+	b := New(NewDefaultConfig())
+	defer b.Shutdown()
+	b.LoadPolicies(ctx, ts)
+	require.False(t, b.Buffers(keyspace, shard, topodatapb.TabletType_PRIMARY))
+
+	require.NoError(t, ts.UpsertMetadata(ctx, "buffer_policies", `{"ks1": {"window": "5s"}}`))
+	require.Eventually(t, func() bool {
+		return b.Buffers(keyspace, shard, topodatapb.TabletType_PRIMARY)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// invalid policies are ignored
+	require.NoError(t, ts.UpsertMetadata(ctx, "buffer_policies", `{"ks1": `))
+	time.Sleep(50 * time.Millisecond)
+	cfg, _ := b.configFor(keyspace)
+	assert.Equal(t, 5*time.Second, cfg.Window)
+}
@@ -23,17 +23,24 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/sync/semaphore"
+
 	"vitess.io/vitess/go/vt/discovery"
 
 	"vitess.io/vitess/go/vt/vtgate/errorsanitizer"
 
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/logutil"
+	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
 )
 
+// tabletsPollInterval is how often a buffer of a tablet type other than
+// PRIMARY checks if some tablets are available again.
+var tabletsPollInterval = 100 * time.Millisecond
+
 // bufferState represents the different states a shardBuffer object can be in.
 type bufferState string
 
@@ -61,6 +68,9 @@ type entry struct {
 	// err is set if the buffering failed e.g. when the entry was evicted.
 	err error
 
+	// sema is the semaphore the slot of the entry was taken from.
+	sema *semaphore.Weighted
+
 	// bufferCtx wraps the request ctx and is used to track the retry of a
 	// request during the drain phase. Once the retry is done, the caller
 	// must cancel this context (by calling bufferCancel).
@@ -79,10 +89,10 @@ type entry struct {
 // - drain() thread
 type shardBuffer struct {
 	// Immutable fields set at construction.
-	buf      *Buffer
-	mode     bufferMode
-	keyspace string
-	shard    string
+	buf        *Buffer
+	keyspace   string
+	shard      string
+	tabletType topodatapb.TabletType
 
 	// statsKey is used to update the stats variables.
 	statsKey []string
@@ -93,6 +103,11 @@ type shardBuffer struct {
 	// mu guards the fields below.
 	mu    sync.RWMutex
 	state bufferState
+	// cfg, mode and sema are the configuration of the keyspace when the
+	// buffering started. A new buffering policy is used by the next failover.
+	cfg  *Config
+	mode bufferMode
+	sema *semaphore.Weighted
 	// queue is the list of buffered requests (ordered by arrival).
 	queue []*entry
 	// lastStart is the last time we saw the start of a failover.
@@ -112,19 +127,21 @@ type shardBuffer struct {
 	wg sync.WaitGroup
 }
 
-func newShardBufferHealthCheck(buf *Buffer, mode bufferMode, keyspace, shard string) *shardBuffer {
-	statsKey := []string{keyspace, shard}
+func newShardBufferHealthCheck(buf *Buffer, keyspace, shard string, tabletType topodatapb.TabletType) *shardBuffer {
+	statsKey := []string{keyspace, shardStatsName(shard, tabletType)}
 	initVariablesForShard(statsKey)
 
 	return &shardBuffer{
 		buf:            buf,
-		mode:           mode,
 		keyspace:       keyspace,
 		shard:          shard,
+		tabletType:     tabletType,
 		statsKey:       statsKey,
-		statsKeyJoined: fmt.Sprintf("%s.%s", keyspace, shard),
-		logTooRecent:   logutil.NewThrottledLogger(fmt.Sprintf("FailoverTooRecent-%v", topoproto.KeyspaceShardString(keyspace, shard)), 5*time.Second),
+		statsKeyJoined: fmt.Sprintf("%s.%s", statsKey[0], statsKey[1]),
+		logTooRecent:   logutil.NewThrottledLogger(fmt.Sprintf("FailoverTooRecent-%v", bufferKey(keyspace, shard, tabletType)), 5*time.Second),
 		state:          stateIdle,
+		cfg:            buf.config,
+		sema:           buf.bufferSizeSema,
 	}
 }
 
@@ -132,12 +149,15 @@ func (sb *shardBuffer) timeNow() time.Time {
 	return sb.buf.config.now()
 }
 
-// disabled returns true if neither buffering nor the dry-run mode is enabled.
-func (sb *shardBuffer) disabled() bool {
-	return sb.mode == bufferModeDisabled
+// name returns the keyspace/shard of the buffer, followed by its tablet type if it is not PRIMARY.
+func (sb *shardBuffer) name() string {
+	return bufferKey(sb.keyspace, sb.shard, sb.tabletType)
 }
 
-func (sb *shardBuffer) waitForFailoverEnd(ctx context.Context, keyspace, shard string, err error) (RetryDoneFunc, error) {
+// waitForFailoverEnd buffers the request if a failover is in progress, or if err
+// shows that a failover just started. cfg, mode and sema are the current configuration
+// of the keyspace, which is used if buffering starts.
+func (sb *shardBuffer) waitForFailoverEnd(ctx context.Context, cfg *Config, mode bufferMode, sema *semaphore.Weighted, err error) (RetryDoneFunc, error) {
 	// We assume if err != nil then it's always caused by a failover.
 	// Other errors must be filtered at higher layers.
 	failoverDetected := err != nil
@@ -167,7 +187,7 @@ func (sb *shardBuffer) waitForFailoverEnd(ctx context.Context, keyspace, shard s
 		// OR
 		// b) we did not buffer, but observed a reparent very recently
 		now := sb.timeNow()
-		minTimeBetweenFailovers := sb.buf.config.MinTimeBetweenFailovers
+		minTimeBetweenFailovers := cfg.MinTimeBetweenFailovers
 
 		// a) Buffering was stopped recently.
 		// This can happen when we stop buffering while MySQL is not ready yet
@@ -176,13 +196,13 @@ func (sb *shardBuffer) waitForFailoverEnd(ctx context.Context, keyspace, shard s
 		if !sb.lastEnd.IsZero() && lastBufferingStopped < minTimeBetweenFailovers {
 			sb.mu.Unlock()
 			msg := "NOT starting buffering"
-			if sb.mode == bufferModeDryRun {
+			if mode == bufferModeDryRun {
 				msg = "Dry-run: Would NOT have started buffering"
 			}
 
 			sb.logTooRecent.Infof("%v for shard: %s because the last failover which triggered buffering is too recent (%v < %v)."+
 				" (A failover was detected by this seen error: %v.)",
-				msg, topoproto.KeyspaceShardString(keyspace, shard), lastBufferingStopped, minTimeBetweenFailovers, err)
+				msg, sb.name(), lastBufferingStopped, minTimeBetweenFailovers, err)
 
 			statsKeyWithReason := append(sb.statsKey, string(skippedLastFailoverTooRecent))
 			requestsSkipped.Add(statsKeyWithReason, 1)
@@ -198,19 +218,20 @@ func (sb *shardBuffer) waitForFailoverEnd(ctx context.Context, keyspace, shard s
 		if !sb.lastReparent.IsZero() && lastReparentAgo < minTimeBetweenFailovers {
 			sb.mu.Unlock()
 			msg := "NOT starting buffering"
-			if sb.mode == bufferModeDryRun {
+			if mode == bufferModeDryRun {
 				msg = "Dry-run: Would NOT have started buffering"
 			}
 
 			sb.logTooRecent.Infof("%v for shard: %s because the last reparent is too recent (%v < %v)."+
 				" (A failover was detected by this seen error: %v.)",
-				msg, topoproto.KeyspaceShardString(keyspace, shard), lastReparentAgo, minTimeBetweenFailovers, err)
+				msg, sb.name(), lastReparentAgo, minTimeBetweenFailovers, err)
 
 			statsKeyWithReason := append(sb.statsKey, string(skippedLastReparentTooRecent))
 			requestsSkipped.Add(statsKeyWithReason, 1)
 			return nil, nil
 		}
 
+		sb.cfg, sb.mode, sb.sema = cfg, mode, sema
 		sb.startBufferingLocked(err)
 	}
 
@@ -266,8 +287,16 @@ func (sb *shardBuffer) startBufferingLocked(err error) {
 	sb.state = stateBuffering
 	sb.queue = make([]*entry, 0)
 
-	sb.timeoutThread = newTimeoutThread(sb, sb.buf.config.MaxFailoverDuration)
+	sb.timeoutThread = newTimeoutThread(sb, sb.cfg.MaxFailoverDuration)
 	sb.timeoutThread.start()
+	if sb.tabletType != topodatapb.TabletType_PRIMARY {
+		// There is no keyspace event when the tablets of the other tablet types
+		// are back, we have to check it ourselves.
+		if hc := sb.buf.healthCheck(); hc != nil {
+			sb.wg.Add(1)
+			go sb.waitForTablets(hc, sb.lastStart)
+		}
+	}
 	msg := "Starting buffering"
 	if sb.mode == bufferModeDryRun {
 		msg = "Dry-run: Would have started buffering"
@@ -275,10 +304,10 @@ func (sb *shardBuffer) startBufferingLocked(err error) {
 	starts.Add(sb.statsKey, 1)
 	log.Infof("%v for shard: %s (window: %v, size: %v, max failover duration: %v) (A failover was detected by this seen error: %v.)",
 		msg,
-		topoproto.KeyspaceShardString(sb.keyspace, sb.shard),
-		sb.buf.config.Window,
-		sb.buf.config.Size,
-		sb.buf.config.MaxFailoverDuration,
+		sb.name(),
+		sb.cfg.Window,
+		sb.cfg.Size,
+		sb.cfg.MaxFailoverDuration,
 		errorsanitizer.NormalizeError(err.Error()),
 	)
 }
@@ -301,7 +330,7 @@ func (sb *shardBuffer) logErrorIfStateNotLocked(state bufferState) {
 // give up their spot in the buffer. It also holds the "bufferCancel" function.
 // If buffering fails e.g. due to a full buffer, an error is returned.
 func (sb *shardBuffer) bufferRequestLocked(ctx context.Context) (*entry, error) {
-	if !sb.buf.bufferSizeSema.TryAcquire(1) {
+	if !sb.sema.TryAcquire(1) {
 		// Buffer is full. Evict the oldest entry and buffer this request instead.
 		if len(sb.queue) == 0 {
 			// Overall buffer is full, but this shard's queue is empty. That means
@@ -327,7 +356,8 @@ func (sb *shardBuffer) bufferRequestLocked(ctx context.Context) (*entry, error)
 
 	e := &entry{
 		done:     make(chan struct{}),
-		deadline: sb.timeNow().Add(sb.buf.config.Window),
+		deadline: sb.timeNow().Add(sb.cfg.Window),
+		sema:     sb.sema,
 	}
 	e.bufferCtx, e.bufferCancel = context.WithCancel(ctx)
 	sb.queue = append(sb.queue, e)
@@ -378,7 +408,7 @@ func (sb *shardBuffer) waitForRequestFinish(e *entry, releaseSlot, async bool) {
 	// the buffer full eviction or the timeout thread does not block on us.
 	// This way, the request's slot can only be reused after the request finished.
 	if releaseSlot {
-		sb.buf.bufferSizeSema.Release(1)
+		e.sema.Release(1)
 	}
 }
 
@@ -510,7 +540,37 @@ func (sb *shardBuffer) stopBufferingDueToMaxDuration() {
 	defer sb.mu.Unlock()
 
 	sb.stopBufferingLocked(stopMaxFailoverDurationExceeded,
-		fmt.Sprintf("stopping buffering because failover did not finish in time (%v)", sb.buf.config.MaxFailoverDuration))
+		fmt.Sprintf("stopping buffering because failover did not finish in time (%v)", sb.cfg.MaxFailoverDuration))
+}
+
+// stopBuffering stops buffering, if the buffer is buffering.
+func (sb *shardBuffer) stopBuffering(reason stopReason, details string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.stopBufferingLocked(reason, details)
+}
+
+// waitForTablets stops buffering as soon as some tablets of the tablet type of the
+// buffer are available. It returns when the buffering that started at "start" is over.
+func (sb *shardBuffer) waitForTablets(hc tabletStats, start time.Time) {
+	defer sb.wg.Done()
+
+	target := &querypb.Target{Keyspace: sb.keyspace, Shard: sb.shard, TabletType: sb.tabletType}
+	ticker := time.NewTicker(tabletsPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sb.mu.Lock()
+		if sb.state != stateBuffering || !sb.lastStart.Equal(start) {
+			sb.mu.Unlock()
+			return
+		}
+		if len(hc.GetHealthyTabletStats(target)) > 0 {
+			sb.stopBufferingLocked(stopTabletsAvailable, stopTabletsAvailableMessage)
+			sb.mu.Unlock()
+			return
+		}
+		sb.mu.Unlock()
+	}
 }
 
 func (sb *shardBuffer) stopBufferingLocked(reason stopReason, details string) {
@@ -529,11 +589,11 @@ func (sb *shardBuffer) stopBufferingLocked(reason stopReason, details string) {
 	failoverDurationSumMs.Add(sb.statsKey, int64(d/time.Millisecond))
 	if sb.mode == bufferModeDryRun {
 		utilDryRunMax := int64(
-			float64(lastRequestsDryRunMax.Counts()[sb.statsKeyJoined]) / float64(sb.buf.config.Size) * 100.0)
+			float64(lastRequestsDryRunMax.Counts()[sb.statsKeyJoined]) / float64(sb.cfg.Size) * 100.0)
 		utilizationDryRunSum.Add(sb.statsKey, utilDryRunMax)
 	} else {
 		utilMax := int64(
-			float64(lastRequestsInFlightMax.Counts()[sb.statsKeyJoined]) / float64(sb.buf.config.Size) * 100.0)
+			float64(lastRequestsInFlightMax.Counts()[sb.statsKeyJoined]) / float64(sb.cfg.Size) * 100.0)
 		utilizationSum.Add(sb.statsKey, utilMax)
 	}
 
@@ -549,7 +609,7 @@ func (sb *shardBuffer) stopBufferingLocked(reason stopReason, details string) {
 		msg = "Dry-run: Would have stopped buffering"
 	}
 	log.Infof("%v for shard: %s after: %.1f seconds due to: %v. Draining %d buffered requests now.",
-		msg, topoproto.KeyspaceShardString(sb.keyspace, sb.shard), d.Seconds(), details, len(q))
+		msg, sb.name(), d.Seconds(), details, len(q))
 
 	var clientEntryError error
 	if reason == stopShardMissing {
@@ -558,10 +618,12 @@ func (sb *shardBuffer) stopBufferingLocked(reason stopReason, details string) {
 
 	// Start the drain. (Use a new Go routine to release the lock.)
 	sb.wg.Add(1)
-	go sb.drain(q, clientEntryError)
+	go sb.drain(q, clientEntryError, sb.cfg.DrainConcurrency)
 }
 
-func (sb *shardBuffer) drain(q []*entry, err error) {
+// drain retries the buffered requests, in the order they arrived,
+// with up to "concurrency" requests retried at the same time.
+func (sb *shardBuffer) drain(q []*entry, err error, concurrency int) {
 	defer sb.wg.Done()
 
 	// stop must be called outside of the lock because the thread may access
@@ -569,12 +631,24 @@ func (sb *shardBuffer) drain(q []*entry, err error) {
 	sb.timeoutThread.stop()
 
 	start := sb.timeNow()
-	// TODO(mberlin): Parallelize the drain by pumping the data through a channel.
+	entries := make(chan *entry)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range entries {
+				sb.unblockAndWait(e, err, true /* releaseSlot */, true /* blockingWait */)
+			}
+		}()
+	}
 	for _, e := range q {
-		sb.unblockAndWait(e, err, true /* releaseSlot */, true /* blockingWait */)
+		entries <- e
 	}
+	close(entries)
+	wg.Wait()
 	d := sb.timeNow().Sub(start)
-	log.Infof("Draining finished for shard: %s Took: %v for: %d requests.", topoproto.KeyspaceShardString(sb.keyspace, sb.shard), d, len(q))
+	log.Infof("Draining finished for shard: %s Took: %v for: %d requests.", sb.name(), d, len(q))
 	requestsDrained.Add(sb.statsKey, int64(len(q)))
 
 	// Draining is done. Change state from "draining" to "idle".
@@ -610,3 +684,38 @@ func (sb *shardBuffer) testGetState() bufferState {
 	defer sb.mu.RUnlock()
 	return sb.state
 }
+
+// status returns the state of the buffer.
+func (sb *shardBuffer) status() *ShardBufferStatus {
+	cfg, _ := sb.buf.configFor(sb.keyspace)
+	mode := cfg.bufferingMode(sb.keyspace, sb.shard)
+
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+	if sb.state != stateIdle {
+		cfg, mode = sb.cfg, sb.mode
+	}
+	status := &ShardBufferStatus{
+		Keyspace:   sb.keyspace,
+		Shard:      sb.shard,
+		TabletType: topoproto.TabletTypeLString(sb.tabletType),
+		Mode:       mode.String(),
+		State:      string(sb.state),
+		QueueSize:  len(sb.queue),
+
+		Window:                  cfg.Window.String(),
+		Size:                    cfg.Size,
+		MaxFailoverDuration:     cfg.MaxFailoverDuration.String(),
+		MinTimeBetweenFailovers: cfg.MinTimeBetweenFailovers.String(),
+		DrainConcurrency:        cfg.DrainConcurrency,
+	}
+	if !sb.lastStart.IsZero() {
+		lastStart := sb.lastStart
+		status.LastStart = &lastStart
+	}
+	if !sb.lastEnd.IsZero() {
+		lastEnd := sb.lastEnd
+		status.LastEnd = &lastEnd
+	}
+	return status
+}
@@ -105,7 +105,7 @@ var (
 // stopReason is used in "stopsByReason" as "Reason" label.
 type stopReason string
 
-var stopReasons = []stopReason{stopShardMissing, stopFailoverEndDetected, stopMaxFailoverDurationExceeded, stopShutdown}
+var stopReasons = []stopReason{stopShardMissing, stopFailoverEndDetected, stopMaxFailoverDurationExceeded, stopShutdown, stopTabletsAvailable}
 
 const (
 	stopShardMissing                stopReason = "ReshardingComplete"
@@ -113,10 +113,12 @@ const (
 	stopMaxFailoverDurationExceeded stopReason = "MaxDurationExceeded"
 	stopShutdown                    stopReason = "Shutdown"
 	stopMoveTablesSwitchingTraffic  stopReason = "MoveTablesSwitchedTraffic"
+	stopTabletsAvailable            stopReason = "TabletsAvailable"
 
 	stopMoveTablesSwitchingTrafficMessage = "MoveTables has switched writes"
 	stopFailoverEndDetectedMessage        = "a primary promotion has been detected"
 	stopShardMissingMessage               = "the keyspace has been resharded"
+	stopTabletsAvailableMessage           = "serving tablets are available again"
 )
 
 // evictedReason is used in "requestsEvicted" as "Reason" label.
@@ -173,6 +173,40 @@ func (cached *DML) CachedSize(alloc bool) int64 {
 	size += cached.RoutingParameters.CachedSize(true)
 	return size
 }
+func (cached *DMLWithInput) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(80)
+	}
+	// field Input vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	if cc, ok := cached.Input.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	// field DMLs []vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.DMLs)) * int64(16))
+		for _, elem := range cached.DMLs {
+			if cc, ok := elem.(cachedObject); ok {
+				size += cc.CachedSize(true)
+			}
+		}
+	}
+	// field BVName string
+	size += hack.RuntimeAllocSize(int64(len(cached.BVName)))
+	// field OutputCols [][]int
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.OutputCols)) * int64(24))
+		for _, elem := range cached.OutputCols {
+			{
+				size += hack.RuntimeAllocSize(int64(cap(elem)) * int64(8))
+			}
+		}
+	}
+	return size
+}
 func (cached *Delete) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -255,7 +289,7 @@ func (cached *FkCascade) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(64)
+		size += int64(80)
 	}
 	// field Selection vitess.io/vitess/go/vt/vtgate/engine.Primitive
 	if cc, ok := cached.Selection.(cachedObject); ok {
@@ -272,6 +306,13 @@ func (cached *FkCascade) CachedSize(alloc bool) int64 {
 	if cc, ok := cached.Parent.(cachedObject); ok {
 		size += cc.CachedSize(true)
 	}
+	// field NonLiteralInfo []vitess.io/vitess/go/vt/vtgate/engine.NonLiteralUpdateInfo
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.NonLiteralInfo)) * int64(40))
+		for _, elem := range cached.NonLiteralInfo {
+			size += elem.CachedSize(false)
+		}
+	}
 	return size
 }
 func (cached *FkChild) CachedSize(alloc bool) int64 {
@@ -612,6 +653,22 @@ func (cached *MergeSort) CachedSize(alloc bool) int64 {
 	}
 	return size
 }
+func (cached *NonLiteralUpdateInfo) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	// field UpdateExprBvName string
+	size += hack.RuntimeAllocSize(int64(len(cached.UpdateExprBvName)))
+	// field UpdateExpr vitess.io/vitess/go/vt/vtgate/evalengine.Expr
+	if cc, ok := cached.UpdateExpr.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	return size
+}
 func (cached *OnlineDDL) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -670,7 +727,7 @@ func (cached *Plan) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(144)
+		size += int64(184)
 	}
 	// field Original string
 	size += hack.RuntimeAllocSize(int64(len(cached.Original)))
@@ -694,6 +751,15 @@ func (cached *Plan) CachedSize(alloc bool) int64 {
 			size += hack.RuntimeAllocSize(int64(len(elem)))
 		}
 	}
+	// field Target string
+	size += hack.RuntimeAllocSize(int64(len(cached.Target)))
+	// field Arguments []*vitess.io/vitess/go/vt/sqlparser.Argument
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.Arguments)) * int64(8))
+		for _, elem := range cached.Arguments {
+			size += elem.CachedSize(true)
+		}
+	}
 	return size
 }
 func (cached *Projection) CachedSize(alloc bool) int64 {
@@ -726,6 +792,45 @@ func (cached *Projection) CachedSize(alloc bool) int64 {
 	}
 	return size
 }
+func (cached *RecurseCTE) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(64)
+	}
+	// field Seed vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	if cc, ok := cached.Seed.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	// field Term vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	if cc, ok := cached.Term.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	// field Vars map[string]int
+	if cached.Vars != nil {
+		size += int64(48)
+		hmap := reflect.ValueOf(cached.Vars)
+		numBuckets := int(math.Pow(2, float64((*(*uint8)(unsafe.Pointer(hmap.Pointer() + uintptr(9)))))))
+		numOldBuckets := (*(*uint16)(unsafe.Pointer(hmap.Pointer() + uintptr(10))))
+		size += hack.RuntimeAllocSize(int64(numOldBuckets * 208))
+		if len(cached.Vars) > 0 || numBuckets > 1 {
+			size += hack.RuntimeAllocSize(int64(numBuckets * 208))
+		}
+		for k := range cached.Vars {
+			size += hack.RuntimeAllocSize(int64(len(k)))
+		}
+	}
+	// field CheckCols []vitess.io/vitess/go/vt/vtgate/engine.CheckCol
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.CheckCols)) * int64(23))
+		for _, elem := range cached.CheckCols {
+			size += elem.CachedSize(false)
+		}
+	}
+	return size
+}
 func (cached *RenameFields) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -1200,6 +1305,41 @@ func (cached *UpdateTarget) CachedSize(alloc bool) int64 {
 	size += hack.RuntimeAllocSize(int64(len(cached.Target)))
 	return size
 }
+func (cached *Upsert) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(24)
+	}
+	// field Upserts []*vitess.io/vitess/go/vt/vtgate/engine.UpsertRow
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.Upserts)) * int64(8))
+		for _, elem := range cached.Upserts {
+			size += elem.CachedSize(true)
+		}
+	}
+	return size
+}
+func (cached *UpsertRow) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	// field Insert vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	if cc, ok := cached.Insert.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	// field Update vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	if cc, ok := cached.Update.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	return size
+}
 func (cached *UserDefinedVariable) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -1272,7 +1412,7 @@ func (cached *VindexFunc) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(96)
+		size += int64(112)
 	}
 	// field Fields []*vitess.io/vitess/go/vt/proto/query.Field
 	{
@@ -1285,7 +1425,7 @@ func (cached *VindexFunc) CachedSize(alloc bool) int64 {
 	{
 		size += hack.RuntimeAllocSize(int64(cap(cached.Cols)) * int64(8))
 	}
-	// field Vindex vitess.io/vitess/go/vt/vtgate/vindexes.SingleColumn
+	// field Vindex vitess.io/vitess/go/vt/vtgate/vindexes.Vindex
 	if cc, ok := cached.Vindex.(cachedObject); ok {
 		size += cc.CachedSize(true)
 	}
@@ -1293,6 +1433,15 @@ func (cached *VindexFunc) CachedSize(alloc bool) int64 {
 	if cc, ok := cached.Value.(cachedObject); ok {
 		size += cc.CachedSize(true)
 	}
+	// field Values []vitess.io/vitess/go/vt/vtgate/evalengine.Expr
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.Values)) * int64(16))
+		for _, elem := range cached.Values {
+			if cc, ok := elem.(cachedObject); ok {
+				size += cc.CachedSize(true)
+			}
+		}
+	}
 	return size
 }
 func (cached *VindexLookup) CachedSize(alloc bool) int64 {
@@ -1376,6 +1525,61 @@ func (cached *VitessMetadata) CachedSize(alloc bool) int64 {
 	size += hack.RuntimeAllocSize(int64(len(cached.Value)))
 	return size
 }
+func (cached *Window) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(112)
+	}
+	// field Source vitess.io/vitess/go/vt/vtgate/engine.Primitive
+	if cc, ok := cached.Source.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	// field PartitionBy []vitess.io/vitess/go/vt/vtgate/engine.CheckCol
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.PartitionBy)) * int64(23))
+		for _, elem := range cached.PartitionBy {
+			size += elem.CachedSize(false)
+		}
+	}
+	// field OrderBy []vitess.io/vitess/go/vt/vtgate/engine.CheckCol
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.OrderBy)) * int64(23))
+		for _, elem := range cached.OrderBy {
+			size += elem.CachedSize(false)
+		}
+	}
+	// field Funcs []*vitess.io/vitess/go/vt/vtgate/engine.WindowFunc
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.Funcs)) * int64(8))
+		for _, elem := range cached.Funcs {
+			size += elem.CachedSize(true)
+		}
+	}
+	// field Cols []int
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.Cols)) * int64(8))
+	}
+	return size
+}
+func (cached *WindowFunc) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	// field Alias string
+	size += hack.RuntimeAllocSize(int64(len(cached.Alias)))
+	// field N vitess.io/vitess/go/vt/vtgate/evalengine.Expr
+	if cc, ok := cached.N.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
+	return size
+}
 
 //go:nocheckptr
 func (cached *shardRoute) CachedSize(alloc bool) int64 {
@@ -0,0 +1,163 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"strings"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/vterrors"
+)
+
+var _ Primitive = (*DMLWithInput)(nil)
+
+// DMLWithInput is a primitive that first executes the Input primitive to find the rows to modify,
+// and then executes the DML primitives with the values of those rows as a bind variable.
+// It is used for UPDATE and DELETE statements with LIMIT that can affect rows on more than one shard,
+// and for multi-table UPDATE and DELETE statements, where each of the DMLs modifies one of the tables.
+type DMLWithInput struct {
+	// Input is the Primitive that finds the rows to be modified. It locks the rows it returns.
+	Input Primitive
+	// DMLs are the Primitives that modify the rows, using the values from Input.
+	DMLs []Primitive
+
+	// BVName is the name of the bind variable that holds the values from the Input.
+	BVName string
+	// OutputCols are the column offsets in the Input rows that are sent to each of the DMLs.
+	// If there is more than one column, the values are sent as tuples.
+	OutputCols [][]int
+
+	txNeeded
+}
+
+// RouteType implements the Primitive interface
+func (dml *DMLWithInput) RouteType() string {
+	return "DMLWithInput"
+}
+
+// GetKeyspaceName implements the Primitive interface
+func (dml *DMLWithInput) GetKeyspaceName() string {
+	return dml.DMLs[0].GetKeyspaceName()
+}
+
+// GetTableName implements the Primitive interface
+func (dml *DMLWithInput) GetTableName() string {
+	tableNames := make([]string, 0, len(dml.DMLs))
+	for _, prim := range dml.DMLs {
+		tableNames = append(tableNames, prim.GetTableName())
+	}
+	return strings.Join(tableNames, "_")
+}
+
+// Inputs implements the Primitive interface
+func (dml *DMLWithInput) Inputs() ([]Primitive, []map[string]any) {
+	return append([]Primitive{dml.Input}, dml.DMLs...), nil
+}
+
+// TryExecute implements the Primitive interface
+func (dml *DMLWithInput) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, _ bool) (*sqltypes.Result, error) {
+	inputRes, err := vcursor.ExecutePrimitive(ctx, dml.Input, bindVars, false)
+	if err != nil {
+		return nil, err
+	}
+	return dml.execDML(ctx, vcursor, bindVars, inputRes.Rows)
+}
+
+// TryStreamExecute implements the Primitive interface
+func (dml *DMLWithInput) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, _ bool, callback func(*sqltypes.Result) error) error {
+	var rows []sqltypes.Row
+	err := vcursor.StreamExecutePrimitive(ctx, dml.Input, bindVars, false, func(result *sqltypes.Result) error {
+		rows = append(rows, result.Rows...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	res, err := dml.execDML(ctx, vcursor, bindVars, rows)
+	if err != nil {
+		return err
+	}
+	return callback(res)
+}
+
+func (dml *DMLWithInput) execDML(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, rows []sqltypes.Row) (*sqltypes.Result, error) {
+	res := &sqltypes.Result{}
+	for idx, prim := range dml.DMLs {
+		bv := dml.bindVarFor(rows, dml.OutputCols[idx])
+		// If no rows are to be modified, there is nothing to do.
+		if len(bv.Values) == 0 {
+			continue
+		}
+
+		// The bind variables are copied, so the values we add don't leak back to the caller.
+		newBindVars := make(map[string]*querypb.BindVariable, len(bindVars)+1)
+		for k, v := range bindVars {
+			newBindVars[k] = v
+		}
+		newBindVars[dml.BVName] = bv
+		qr, err := vcursor.ExecutePrimitive(ctx, prim, newBindVars, false)
+		if err != nil {
+			return nil, err
+		}
+		res.RowsAffected += qr.RowsAffected
+	}
+	return res, nil
+}
+
+// bindVarFor returns the values of the given columns in the rows as a list bind variable.
+// Rows with NULL values are skipped, they come from an outer join and don't point to a row to modify.
+func (dml *DMLWithInput) bindVarFor(rows []sqltypes.Row, cols []int) *querypb.BindVariable {
+	bv := &querypb.BindVariable{
+		Type: querypb.Type_TUPLE,
+	}
+outer:
+	for _, row := range rows {
+		for _, colIdx := range cols {
+			if row[colIdx].IsNull() {
+				continue outer
+			}
+		}
+		if len(cols) == 1 {
+			bv.Values = append(bv.Values, sqltypes.ValueToProto(row[cols[0]]))
+			continue
+		}
+		tupleValues := make([]sqltypes.Value, 0, len(cols))
+		for _, colIdx := range cols {
+			tupleValues = append(tupleValues, row[colIdx])
+		}
+		bv.Values = append(bv.Values, sqltypes.TupleToProto(tupleValues))
+	}
+	return bv
+}
+
+// GetFields implements the Primitive interface
+func (dml *DMLWithInput) GetFields(context.Context, VCursor, map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
+	return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "[BUG] GetFields should not be called")
+}
+
+func (dml *DMLWithInput) description() PrimitiveDescription {
+	return PrimitiveDescription{
+		OperatorType: dml.RouteType(),
+		Other: map[string]any{
+			"BvName":     dml.BVName,
+			"OutputCols": dml.OutputCols,
+		},
+	}
+}
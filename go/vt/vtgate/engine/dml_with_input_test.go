@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+// TestDeleteWithInputSingleColumn tests that DMLWithInput sends the selected values as a list to the DML primitive.
+func TestDeleteWithInputSingleColumn(t *testing.T) {
+	input := &Route{
+		Query: "select id from tbl order by ts asc limit 2 for update",
+		RoutingParameters: &RoutingParameters{
+			Opcode:   Unsharded,
+			Keyspace: &vindexes.Keyspace{Name: "ks"},
+		},
+	}
+	del := &Delete{
+		DML: &DML{
+			Query: "delete from tbl where id in ::dml_vals",
+			RoutingParameters: &RoutingParameters{
+				Opcode:   Unsharded,
+				Keyspace: &vindexes.Keyspace{Name: "ks"},
+			},
+		},
+	}
+	dml := &DMLWithInput{
+		Input:      input,
+		DMLs:       []Primitive{del},
+		BVName:     "dml_vals",
+		OutputCols: [][]int{{0}},
+	}
+
+	vc := newDMLTestVCursor("0")
+	vc.results = []*sqltypes.Result{
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1", "2"),
+	}
+	_, err := dml.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true)
+	require.NoError(t, err)
+	vc.ExpectLog(t, []string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: select id from tbl order by ts asc limit 2 for update {} false false`,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: delete from tbl where id in ::dml_vals {dml_vals: type:TUPLE values:{type:INT64 value:"1"} values:{type:INT64 value:"2"}} true true`,
+	})
+
+	vc.Rewind()
+	err = dml.TryStreamExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true, func(result *sqltypes.Result) error { return nil })
+	require.NoError(t, err)
+	vc.ExpectLog(t, []string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`StreamExecuteMulti select id from tbl order by ts asc limit 2 for update ks.0: {} `,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: delete from tbl where id in ::dml_vals {dml_vals: type:TUPLE values:{type:INT64 value:"1"} values:{type:INT64 value:"2"}} true true`,
+	})
+}
+
+// TestUpdateWithInputMultiColumn tests that DMLWithInput sends tuples to the DML primitive when the key has more than one column.
+func TestUpdateWithInputMultiColumn(t *testing.T) {
+	input := &Route{
+		Query: "select ts, a, b from tbl order by ts asc limit 2 for update",
+		RoutingParameters: &RoutingParameters{
+			Opcode:   Unsharded,
+			Keyspace: &vindexes.Keyspace{Name: "ks"},
+		},
+	}
+	upd := &Update{
+		DML: &DML{
+			Query: "update tbl set foo = 1 where (a, b) in ::dml_vals",
+			RoutingParameters: &RoutingParameters{
+				Opcode:   Unsharded,
+				Keyspace: &vindexes.Keyspace{Name: "ks"},
+			},
+		},
+	}
+	dml := &DMLWithInput{
+		Input:      input,
+		DMLs:       []Primitive{upd},
+		BVName:     "dml_vals",
+		OutputCols: [][]int{{1, 2}},
+	}
+
+	vc := newDMLTestVCursor("0")
+	vc.results = []*sqltypes.Result{
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("ts|a|b", "int64|int64|varchar"), "10|1|a", "11|2|b"),
+	}
+	_, err := dml.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true)
+	require.NoError(t, err)
+	vc.ExpectLog(t, []string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: select ts, a, b from tbl order by ts asc limit 2 for update {} false false`,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: update tbl set foo = 1 where (a, b) in ::dml_vals {dml_vals: type:TUPLE values:{type:TUPLE value:"\x89\x02\x011\x950\x01a"} values:{type:TUPLE value:"\x89\x02\x012\x950\x01b"}} true true`,
+	})
+
+	// no rows selected means no DML is sent
+	vc.Rewind()
+	vc.results = []*sqltypes.Result{sqltypes.MakeTestResult(sqltypes.MakeTestFields("ts|a|b", "int64|int64|varchar"))}
+	_, err = dml.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true)
+	require.NoError(t, err)
+	vc.ExpectLog(t, []string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: select ts, a, b from tbl order by ts asc limit 2 for update {} false false`,
+	})
+}
+
+// TestDeleteWithInputMultipleDMLs tests that each DML gets its own columns of the input rows, and that NULL values are skipped.
+func TestDeleteWithInputMultipleDMLs(t *testing.T) {
+	input := &Route{
+		Query: "select t1.id, t2.id from t1 left join t2 on t1.id = t2.t1_id for update",
+		RoutingParameters: &RoutingParameters{
+			Opcode:   Unsharded,
+			Keyspace: &vindexes.Keyspace{Name: "ks"},
+		},
+	}
+	newDelete := func(tbl string) *Delete {
+		return &Delete{
+			DML: &DML{
+				Query: "delete from " + tbl + " where id in ::dml_vals",
+				RoutingParameters: &RoutingParameters{
+					Opcode:   Unsharded,
+					Keyspace: &vindexes.Keyspace{Name: "ks"},
+				},
+			},
+		}
+	}
+	dml := &DMLWithInput{
+		Input:      input,
+		DMLs:       []Primitive{newDelete("t1"), newDelete("t2")},
+		BVName:     "dml_vals",
+		OutputCols: [][]int{{0}, {1}},
+	}
+
+	vc := newDMLTestVCursor("0")
+	vc.results = []*sqltypes.Result{
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|id", "int64|int64"), "1|10", "2|null"),
+		{RowsAffected: 2},
+		{RowsAffected: 1},
+	}
+	qr, err := dml.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, qr.RowsAffected)
+	vc.ExpectLog(t, []string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: select t1.id, t2.id from t1 left join t2 on t1.id = t2.t1_id for update {} false false`,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: delete from t1 where id in ::dml_vals {dml_vals: type:TUPLE values:{type:INT64 value:"1"} values:{type:INT64 value:"2"}} true true`,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: delete from t2 where id in ::dml_vals {dml_vals: type:TUPLE values:{type:INT64 value:"10"}} true true`,
+	})
+}
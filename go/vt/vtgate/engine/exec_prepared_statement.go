@@ -23,7 +23,6 @@ import (
 	"vitess.io/vitess/go/sqltypes"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	"vitess.io/vitess/go/vt/sqlparser"
-	"vitess.io/vitess/go/vt/vterrors"
 )
 
 var _ Primitive = (*ExecStmt)(nil)
@@ -31,6 +30,8 @@ var _ Primitive = (*ExecStmt)(nil)
 type ExecStmt struct {
 	Params []*sqlparser.Variable
 	Input  Primitive
+	// InputType is the type of the prepared statement.
+	InputType sqlparser.StatementType
 }
 
 func (e *ExecStmt) NeedsTransaction() bool {
@@ -49,8 +50,14 @@ func (e *ExecStmt) GetTableName() string {
 	return e.Input.GetTableName()
 }
 
+// GetFields returns the fields of the prepared statement. Only the selects and the shows have fields.
 func (e *ExecStmt) GetFields(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
-	return nil, vterrors.VT12001("prepare command on execute statement")
+	switch e.InputType {
+	case sqlparser.StmtSelect, sqlparser.StmtShow:
+		bindVars = e.prepareBindVars(vcursor, bindVars)
+		return e.Input.GetFields(ctx, vcursor, bindVars)
+	}
+	return &sqltypes.Result{}, nil
 }
 
 func (e *ExecStmt) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
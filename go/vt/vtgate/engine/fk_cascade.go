@@ -20,10 +20,13 @@ import (
 	"context"
 	"fmt"
 
+	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/sqltypes"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
 )
 
 // FkChild contains the Child Primitive to be executed collecting the values from the Selection Primitive using the column indexes.
@@ -34,6 +37,18 @@ type FkChild struct {
 	Exec   Primitive
 }
 
+// NonLiteralUpdateInfo contains the information needed to cascade an update that sets a column to a non-literal value.
+// The new value depends on the row being updated, so it is computed for each of the rows found by the Selection,
+// and passed to the children in the UpdateExprBvName bind variable.
+type NonLiteralUpdateInfo struct {
+	// UpdateExprBvName is the name of the bind variable that holds the new value.
+	UpdateExprBvName string
+	// UpdateExpr computes the new value from the columns of the row, when vtgate can evaluate it.
+	UpdateExpr evalengine.Expr
+	// UpdateExprCol is the offset of the new value in the row, when it is computed by the Selection instead.
+	UpdateExprCol int
+}
+
 // FkCascade is a primitive that implements foreign key cascading using Selection as values required to execute the FkChild Primitives.
 // On success, it executes the Parent Primitive.
 type FkCascade struct {
@@ -43,6 +58,9 @@ type FkCascade struct {
 	Children []*FkChild
 	// Parent is the Primitive that is executed after the children are modified.
 	Parent Primitive
+	// NonLiteralInfo is set when the parent update sets the foreign key columns to non-literal values.
+	// The children are then executed once for each row from the Selection Primitive.
+	NonLiteralInfo []NonLiteralUpdateInfo
 
 	txNeeded
 }
@@ -81,6 +99,15 @@ func (fkc *FkCascade) TryExecute(ctx context.Context, vcursor VCursor, bindVars
 		return &sqltypes.Result{}, nil
 	}
 
+	if len(fkc.NonLiteralInfo) > 0 {
+		err = fkc.executeNonLiteralChildren(ctx, vcursor, bindVars, selectionRes.Rows)
+		if err != nil {
+			return nil, err
+		}
+		// All the children are modified successfully, we can now execute the Parent Primitive.
+		return vcursor.ExecutePrimitive(ctx, fkc.Parent, bindVars, wantfields)
+	}
+
 	for _, child := range fkc.Children {
 		// We create a bindVariable for each Child
 		// that stores the tuple of columns involved in the fk constraint.
@@ -109,8 +136,74 @@ func (fkc *FkCascade) TryExecute(ctx context.Context, vcursor VCursor, bindVars
 	return vcursor.ExecutePrimitive(ctx, fkc.Parent, bindVars, wantfields)
 }
 
+// executeNonLiteralChildren executes the children once for each of the given rows,
+// with the new values of the updated columns computed for that row.
+func (fkc *FkCascade) executeNonLiteralChildren(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, rows []sqltypes.Row) error {
+	env := evalengine.NewExpressionEnv(ctx, bindVars, vcursor)
+	for _, row := range rows {
+		env.Row = row
+		for _, info := range fkc.NonLiteralInfo {
+			value, err := info.newValue(env, row, vcursor.ConnCollation())
+			if err != nil {
+				return err
+			}
+			bindVars[info.UpdateExprBvName] = sqltypes.ValueBindVariable(value)
+		}
+		for _, child := range fkc.Children {
+			var tupleValues []sqltypes.Value
+			for _, colIdx := range child.Cols {
+				tupleValues = append(tupleValues, row[colIdx])
+			}
+			bindVars[child.BVName] = &querypb.BindVariable{
+				Type:   querypb.Type_TUPLE,
+				Values: []*querypb.Value{sqltypes.TupleToProto(tupleValues)},
+			}
+			_, err := vcursor.ExecutePrimitive(ctx, child.Exec, bindVars, false)
+			if err != nil {
+				return err
+			}
+			delete(bindVars, child.BVName)
+		}
+	}
+	for _, info := range fkc.NonLiteralInfo {
+		delete(bindVars, info.UpdateExprBvName)
+	}
+	return nil
+}
+
+func (info NonLiteralUpdateInfo) newValue(env *evalengine.ExpressionEnv, row sqltypes.Row, coll collations.ID) (sqltypes.Value, error) {
+	if info.UpdateExpr == nil {
+		return row[info.UpdateExprCol], nil
+	}
+	res, err := env.Evaluate(info.UpdateExpr)
+	if err != nil {
+		return sqltypes.Value{}, err
+	}
+	return res.Value(coll), nil
+}
+
 // TryStreamExecute implements the Primitive interface.
 func (fkc *FkCascade) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool, callback func(*sqltypes.Result) error) error {
+	if len(fkc.NonLiteralInfo) > 0 {
+		// The children are executed for each row, so we need all the rows from the Selection first.
+		var rows []sqltypes.Row
+		err := vcursor.StreamExecutePrimitive(ctx, fkc.Selection, bindVars, wantfields, func(result *sqltypes.Result) error {
+			rows = append(rows, result.Rows...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return callback(&sqltypes.Result{})
+		}
+		err = fkc.executeNonLiteralChildren(ctx, vcursor, bindVars, rows)
+		if err != nil {
+			return err
+		}
+		return vcursor.StreamExecutePrimitive(ctx, fkc.Parent, bindVars, wantfields, callback)
+	}
+
 	// We create a bindVariable for each Child
 	// that stores the tuple of columns involved in the fk constraint.
 	var bindVariables []*querypb.BindVariable
@@ -183,7 +276,21 @@ func (fkc *FkCascade) Inputs() ([]Primitive, []map[string]any) {
 }
 
 func (fkc *FkCascade) description() PrimitiveDescription {
-	return PrimitiveDescription{OperatorType: fkc.RouteType()}
+	desc := PrimitiveDescription{OperatorType: fkc.RouteType()}
+	if len(fkc.NonLiteralInfo) > 0 {
+		var nonLiteralInfo []map[string]any
+		for _, info := range fkc.NonLiteralInfo {
+			infoMap := map[string]any{"UpdateExprBvName": info.UpdateExprBvName}
+			if info.UpdateExpr != nil {
+				infoMap["UpdateExpr"] = sqlparser.String(info.UpdateExpr)
+			} else {
+				infoMap["UpdateExprCol"] = info.UpdateExprCol
+			}
+			nonLiteralInfo = append(nonLiteralInfo, infoMap)
+		}
+		desc.Other = map[string]any{"NonLiteralUpdateInfo": nonLiteralInfo}
+	}
+	return desc
 }
 
 var _ Primitive = (*FkCascade)(nil)
@@ -22,8 +22,11 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/sqltypes"
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 )
 
@@ -149,6 +152,83 @@ func TestUpdateCascade(t *testing.T) {
 	})
 }
 
+// TestNonLiteralUpdateCascade tests that FkCascade executes the children once for each row, with the new value computed for that row.
+func TestNonLiteralUpdateCascade(t *testing.T) {
+	fakeRes := sqltypes.MakeTestResult(sqltypes.MakeTestFields("cola|colb|upd", "int64|varchar|varchar"), "1|a|x", "2|b|y")
+
+	expr := &sqlparser.BinaryExpr{
+		Operator: sqlparser.PlusOp,
+		Left:     sqlparser.NewColName("cola"),
+		Right:    sqlparser.NewIntLiteral("10"),
+	}
+	updateExpr, err := evalengine.Translate(expr, &evalengine.Config{
+		Collation:     collations.Default(),
+		ResolveColumn: evalengine.FieldResolver(fakeRes.Fields).Column,
+	})
+	require.NoError(t, err)
+
+	inputP := &Route{
+		Query: "select cola, colb, concat(colb, 'x') from parent where foo = 48",
+		RoutingParameters: &RoutingParameters{
+			Opcode:   Unsharded,
+			Keyspace: &vindexes.Keyspace{Name: "ks"},
+		},
+	}
+	childP := &Update{
+		DML: &DML{
+			Query: "update child set ca = :fkc_upd, cb = :fkc_upd1 where (ca, cb) in ::__vals",
+			RoutingParameters: &RoutingParameters{
+				Opcode:   Unsharded,
+				Keyspace: &vindexes.Keyspace{Name: "ks"},
+			},
+		},
+	}
+	parentP := &Update{
+		DML: &DML{
+			Query: "update parent set cola = cola + 10, colb = concat(colb, 'x') where foo = 48",
+			RoutingParameters: &RoutingParameters{
+				Opcode:   Unsharded,
+				Keyspace: &vindexes.Keyspace{Name: "ks"},
+			},
+		},
+	}
+	fkc := &FkCascade{
+		Selection: inputP,
+		Children:  []*FkChild{{BVName: "__vals", Cols: []int{0, 1}, Exec: childP}},
+		Parent:    parentP,
+		NonLiteralInfo: []NonLiteralUpdateInfo{
+			{UpdateExprBvName: "fkc_upd", UpdateExpr: updateExpr},
+			{UpdateExprBvName: "fkc_upd1", UpdateExprCol: 2},
+		},
+	}
+
+	expectedLog := []string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: update child set ca = :fkc_upd, cb = :fkc_upd1 where (ca, cb) in ::__vals {__vals: type:TUPLE values:{type:TUPLE value:"\x89\x02\x011\x950\x01a"} fkc_upd: type:INT64 value:"11" fkc_upd1: type:VARCHAR value:"x"} true true`,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: update child set ca = :fkc_upd, cb = :fkc_upd1 where (ca, cb) in ::__vals {__vals: type:TUPLE values:{type:TUPLE value:"\x89\x02\x012\x950\x01b"} fkc_upd: type:INT64 value:"12" fkc_upd1: type:VARCHAR value:"y"} true true`,
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: update parent set cola = cola + 10, colb = concat(colb, 'x') where foo = 48 {} true true`,
+	}
+
+	vc := newDMLTestVCursor("0")
+	vc.results = []*sqltypes.Result{fakeRes}
+	_, err = fkc.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true)
+	require.NoError(t, err)
+	vc.ExpectLog(t, append([]string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: select cola, colb, concat(colb, 'x') from parent where foo = 48 {} false false`,
+	}, expectedLog...))
+
+	vc.Rewind()
+	err = fkc.TryStreamExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, true, func(result *sqltypes.Result) error { return nil })
+	require.NoError(t, err)
+	vc.ExpectLog(t, append([]string{
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`StreamExecuteMulti select cola, colb, concat(colb, 'x') from parent where foo = 48 ks.0: {} `,
+	}, expectedLog...))
+}
+
 // TestNeedsTransactionInExecPrepared tests that if we have a foreign key cascade inside an ExecStmt plan, then we do mark the plan to require a transaction.
 func TestNeedsTransactionInExecPrepared(t *testing.T) {
 	// Even if FkCascade is wrapped in ExecStmt, the plan should be marked such that it requires a transaction.
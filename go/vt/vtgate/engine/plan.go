@@ -38,6 +38,8 @@ type Plan struct {
 	BindVarNeeds *sqlparser.BindVarNeeds // Stores BindVars needed to be provided as part of expression rewriting
 	Warnings     []*query.QueryWarning   // Warnings that need to be yielded every time this query runs
 	TablesUsed   []string                // TablesUsed is the list of tables that this plan will query
+	Target       string                  // Target is the target of the session the plan was cached for
+	Arguments    []*sqlparser.Argument   // Arguments are the typed bind variables of the normalized query, whose types are not kept by the parser
 
 	ExecCount    uint64 // Count of times this plan was executed
 	ExecTime     uint64 // Total execution time
@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"slices"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/vterrors"
+)
+
+// maxRecursionDepth is the maximum number of iterations we allow a recursive CTE to run.
+// It is the same as the default value of @@cte_max_recursion_depth in MySQL.
+const maxRecursionDepth = 1000
+
+var _ Primitive = (*RecurseCTE)(nil)
+
+// RecurseCTE is used to evaluate a recursive common table expression in vtgate.
+// The Seed is executed once, and the rows it produces are the first iteration.
+// The Term is then executed once for every row produced by the previous iteration,
+// and the rows it returns make up the next iteration. This goes on until an iteration
+// does not produce any rows.
+type RecurseCTE struct {
+	// Seed is the non-recursive part of the CTE
+	Seed Primitive
+	// Term is the recursive part of the CTE
+	Term Primitive
+
+	// Vars are the bind variables that the Term uses to read
+	// the columns of the row from the previous iteration
+	Vars map[string]int `json:",omitempty"`
+
+	// CheckCols is set when the CTE is using UNION DISTINCT.
+	// Rows that have already been produced are then not returned again,
+	// and not used as input for the next iteration
+	CheckCols []CheckCol `json:",omitempty"`
+}
+
+// TryExecute implements the Primitive interface
+func (r *RecurseCTE) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
+	seed, err := vcursor.ExecutePrimitive(ctx, r.Seed, bindVars, wantfields)
+	if err != nil {
+		return nil, err
+	}
+
+	// we clip the rows so appending to them will not change the result of the seed
+	res := &sqltypes.Result{Fields: seed.Fields, Rows: slices.Clip(seed.Rows)}
+	var pt *probeTable
+	if len(r.CheckCols) > 0 {
+		pt = newProbeTable(r.CheckCols)
+		res.Rows, err = pt.filterSeen(res.Rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	current := res.Rows
+	for depth := 0; len(current) > 0; depth++ {
+		if depth == maxRecursionDepth {
+			return nil, vterrors.VT09020(maxRecursionDepth + 1)
+		}
+		var next []sqltypes.Row
+		for _, row := range current {
+			joinVars := make(map[string]*querypb.BindVariable, len(r.Vars))
+			for k, col := range r.Vars {
+				joinVars[k] = sqltypes.ValueBindVariable(row[col])
+			}
+			tres, err := vcursor.ExecutePrimitive(ctx, r.Term, combineVars(bindVars, joinVars), false)
+			if err != nil {
+				return nil, err
+			}
+			rows := tres.Rows
+			if pt != nil {
+				rows, err = pt.filterSeen(rows)
+				if err != nil {
+					return nil, err
+				}
+			}
+			next = append(next, rows...)
+		}
+		res.Rows = append(res.Rows, next...)
+		current = next
+	}
+	return res, nil
+}
+
+// TryStreamExecute implements the Primitive interface.
+// The whole CTE has to be evaluated before we know that we are done, so we can't stream the results
+func (r *RecurseCTE) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool, callback func(*sqltypes.Result) error) error {
+	res, err := r.TryExecute(ctx, vcursor, bindVars, wantfields)
+	if err != nil {
+		return err
+	}
+	return callback(res)
+}
+
+// GetFields implements the Primitive interface
+func (r *RecurseCTE) GetFields(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
+	return r.Seed.GetFields(ctx, vcursor, bindVars)
+}
+
+// Inputs implements the Primitive interface
+func (r *RecurseCTE) Inputs() ([]Primitive, []map[string]any) {
+	return []Primitive{r.Seed, r.Term}, []map[string]any{{
+		inputName: "Seed",
+	}, {
+		inputName: "Term",
+	}}
+}
+
+// RouteType implements the Primitive interface
+func (r *RecurseCTE) RouteType() string {
+	return "RecurseCTE"
+}
+
+// GetKeyspaceName implements the Primitive interface
+func (r *RecurseCTE) GetKeyspaceName() string {
+	if r.Seed.GetKeyspaceName() == r.Term.GetKeyspaceName() {
+		return r.Seed.GetKeyspaceName()
+	}
+	return r.Seed.GetKeyspaceName() + "_" + r.Term.GetKeyspaceName()
+}
+
+// GetTableName implements the Primitive interface
+func (r *RecurseCTE) GetTableName() string {
+	return r.Seed.GetTableName()
+}
+
+// NeedsTransaction implements the Primitive interface
+func (r *RecurseCTE) NeedsTransaction() bool {
+	return r.Seed.NeedsTransaction() || r.Term.NeedsTransaction()
+}
+
+func (r *RecurseCTE) description() PrimitiveDescription {
+	other := map[string]any{}
+	if len(r.Vars) > 0 {
+		other["JoinVars"] = orderedStringIntMap(r.Vars)
+	}
+	if len(r.CheckCols) > 0 {
+		var colls []string
+		for _, checkCol := range r.CheckCols {
+			colls = append(colls, checkCol.String())
+		}
+		other["Collations"] = colls
+	}
+
+	return PrimitiveDescription{
+		OperatorType: "RecurseCTE",
+		Other:        other,
+	}
+}
+
+// filterSeen returns the rows that the probe table has not seen before, and remembers them
+func (pt *probeTable) filterSeen(rows []sqltypes.Row) ([]sqltypes.Row, error) {
+	var unseen []sqltypes.Row
+	for _, row := range rows {
+		exists, err := pt.exists(row)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			unseen = append(unseen, row)
+		}
+	}
+	return unseen, nil
+}
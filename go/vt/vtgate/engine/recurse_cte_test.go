@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/test/utils"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+)
+
+func TestRecurseCTEExecute(t *testing.T) {
+	fields := sqltypes.MakeTestFields(
+		"id|name",
+		"int64|varchar",
+	)
+	seed := &fakePrimitive{
+		results: []*sqltypes.Result{
+			sqltypes.MakeTestResult(fields, "1|a", "2|b"),
+		},
+	}
+	term := &fakePrimitive{
+		results: []*sqltypes.Result{
+			// children of 1
+			sqltypes.MakeTestResult(fields, "3|c"),
+			// children of 2
+			sqltypes.MakeTestResult(fields),
+			// children of 3
+			sqltypes.MakeTestResult(fields, "4|d", "5|e"),
+			// children of 4 and 5
+			sqltypes.MakeTestResult(fields),
+			sqltypes.MakeTestResult(fields),
+		},
+	}
+	bv := map[string]*querypb.BindVariable{
+		"a": sqltypes.Int64BindVariable(10),
+	}
+
+	cte := &RecurseCTE{
+		Seed: seed,
+		Term: term,
+		Vars: map[string]int{"c_id": 0},
+	}
+
+	r, err := cte.TryExecute(context.Background(), &noopVCursor{}, bv, true)
+	require.NoError(t, err)
+
+	seed.ExpectLog(t, []string{
+		`Execute a: type:INT64 value:"10" true`,
+	})
+	term.ExpectLog(t, []string{
+		`Execute a: type:INT64 value:"10" c_id: type:INT64 value:"1" false`,
+		`Execute a: type:INT64 value:"10" c_id: type:INT64 value:"2" false`,
+		`Execute a: type:INT64 value:"10" c_id: type:INT64 value:"3" false`,
+		`Execute a: type:INT64 value:"10" c_id: type:INT64 value:"4" false`,
+		`Execute a: type:INT64 value:"10" c_id: type:INT64 value:"5" false`,
+	})
+	utils.MustMatch(t, sqltypes.MakeTestResult(fields, "1|a", "2|b", "3|c", "4|d", "5|e"), r)
+
+	// streaming gives the same result
+	seed.rewind()
+	term.rewind()
+	r, err = wrapStreamExecute(cte, &noopVCursor{}, bv, true)
+	require.NoError(t, err)
+	utils.MustMatch(t, sqltypes.MakeTestResult(fields, "1|a", "2|b", "3|c", "4|d", "5|e"), r)
+}
+
+func TestRecurseCTEDistinct(t *testing.T) {
+	fields := sqltypes.MakeTestFields(
+		"n",
+		"int64",
+	)
+	seed := &fakePrimitive{
+		results: []*sqltypes.Result{
+			sqltypes.MakeTestResult(fields, "1", "1"),
+		},
+	}
+	term := &fakePrimitive{
+		results: []*sqltypes.Result{
+			sqltypes.MakeTestResult(fields, "2", "1"),
+			sqltypes.MakeTestResult(fields, "1", "2"),
+		},
+	}
+
+	cte := &RecurseCTE{
+		Seed:      seed,
+		Term:      term,
+		Vars:      map[string]int{"c_n": 0},
+		CheckCols: []CheckCol{{Col: 0, Type: evalengine.Type{Type: sqltypes.Int64, Coll: collations.CollationBinaryID}}},
+	}
+
+	r, err := cte.TryExecute(context.Background(), &noopVCursor{}, nil, true)
+	require.NoError(t, err)
+	term.ExpectLog(t, []string{
+		`Execute c_n: type:INT64 value:"1" false`,
+		`Execute c_n: type:INT64 value:"2" false`,
+	})
+	utils.MustMatch(t, sqltypes.MakeTestResult(fields, "1", "2"), r)
+}
+
+func TestRecurseCTEMaxDepth(t *testing.T) {
+	fields := sqltypes.MakeTestFields(
+		"n",
+		"int64",
+	)
+	seed := &fakePrimitive{
+		results: []*sqltypes.Result{
+			sqltypes.MakeTestResult(fields, "0"),
+		},
+	}
+	term := &fakePrimitive{}
+	for i := 1; i <= maxRecursionDepth; i++ {
+		term.results = append(term.results, sqltypes.MakeTestResult(fields, fmt.Sprintf("%d", i)))
+	}
+
+	cte := &RecurseCTE{
+		Seed: seed,
+		Term: term,
+		Vars: map[string]int{"c_n": 0},
+	}
+
+	_, err := cte.TryExecute(context.Background(), &noopVCursor{}, nil, true)
+	require.EqualError(t, err, "VT09020: recursive query aborted after 1001 iterations")
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package engine
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sort"
@@ -26,7 +27,9 @@ import (
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
 	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
@@ -127,23 +130,28 @@ func (upd *Update) updateVindexEntries(ctx context.Context, vcursor VCursor, bin
 		if err != nil {
 			return err
 		}
+		newKsid, err := upd.newKeyspaceID(ctx, vcursor, env, row, ksid)
+		if err != nil {
+			return err
+		}
+		ksidChanged := !bytes.Equal(ksid, newKsid)
 
 		for _, colVindex := range upd.Vindexes {
-			// Skip this vindex if no rows are being changed
-			updColValues, ok := upd.ChangedVindexValues[colVindex.Name]
-			if !ok {
+			if colVindex.Name == upd.KsidVindex.String() {
+				// The primary vindex decides the keyspace id of the row, which was handled above.
 				continue
 			}
-
-			offset := updColValues.Offset
-			if !row[offset].IsNull() {
-				val, err := row[offset].ToCastInt64()
+			updColValues, changed := upd.ChangedVindexValues[colVindex.Name]
+			if changed {
+				unchanged, err := vindexValuesUnchanged(row, updColValues.Offset)
 				if err != nil {
 					return err
 				}
-				if val == int64(1) { // 1 means that the old and new value are same and vindex update is not required.
-					continue
-				}
+				changed = !unchanged
+			}
+			// Skip this vindex if no rows are being changed, unless the keyspace id the entries point to is changing
+			if !changed && !ksidChanged {
+				continue
 			}
 
 			fromIds := make([]sqltypes.Value, 0, len(colVindex.Columns))
@@ -152,6 +160,10 @@ func (upd *Update) updateVindexEntries(ctx context.Context, vcursor VCursor, bin
 				// Fetch the column values.
 				origColValue := row[fieldColNumMap[vCol.String()]]
 				fromIds = append(fromIds, origColValue)
+				if !changed {
+					vindexColumnKeys = append(vindexColumnKeys, origColValue)
+					continue
+				}
 				if colValue, exists := updColValues.EvalExprMap[vCol.String()]; exists {
 					resolvedVal, err := env.Evaluate(colValue)
 					if err != nil {
@@ -165,7 +177,18 @@ func (upd *Update) updateVindexEntries(ctx context.Context, vcursor VCursor, bin
 			}
 
 			if colVindex.Owned {
-				if err := colVindex.Vindex.(vindexes.Lookup).Update(ctx, vcursor, fromIds, ksid, vindexColumnKeys); err != nil {
+				lookup := colVindex.Vindex.(vindexes.Lookup)
+				if !ksidChanged {
+					if err := lookup.Update(ctx, vcursor, fromIds, ksid, vindexColumnKeys); err != nil {
+						return err
+					}
+					continue
+				}
+				// The entry has to point to the new keyspace id of the row.
+				if err := lookup.Delete(ctx, vcursor, [][]sqltypes.Value{fromIds}, ksid); err != nil {
+					return err
+				}
+				if err := lookup.Create(ctx, vcursor, [][]sqltypes.Value{vindexColumnKeys}, [][]byte{newKsid}, false /* ignoreMode */); err != nil {
 					return err
 				}
 			} else {
@@ -183,7 +206,7 @@ func (upd *Update) updateVindexEntries(ctx context.Context, vcursor VCursor, bin
 				}
 
 				// If values were supplied, we validate against keyspace id.
-				verified, err := vindexes.Verify(ctx, colVindex.Vindex, vcursor, [][]sqltypes.Value{vindexColumnKeys}, [][]byte{ksid})
+				verified, err := vindexes.Verify(ctx, colVindex.Vindex, vcursor, [][]sqltypes.Value{vindexColumnKeys}, [][]byte{newKsid})
 				if err != nil {
 					return err
 				}
@@ -197,6 +220,75 @@ func (upd *Update) updateVindexEntries(ctx context.Context, vcursor VCursor, bin
 	return nil
 }
 
+// newKeyspaceID returns the keyspace id of the row once it is updated, which only differs from
+// the current one when the update changes the columns of a multi-column primary vindex.
+// The row is not moved, so the new keyspace id has to map to the shard that holds the row.
+func (upd *Update) newKeyspaceID(ctx context.Context, vcursor VCursor, env *evalengine.ExpressionEnv, row []sqltypes.Value, ksid []byte) ([]byte, error) {
+	var primary *vindexes.ColumnVindex
+	for _, colVindex := range upd.Vindexes {
+		if colVindex.Name == upd.KsidVindex.String() && !colVindex.IsPartialVindex() {
+			primary = colVindex
+			break
+		}
+	}
+	if primary == nil {
+		return ksid, nil
+	}
+	updColValues, ok := upd.ChangedVindexValues[primary.Name]
+	if !ok {
+		return ksid, nil
+	}
+	unchanged, err := vindexValuesUnchanged(row, updColValues.Offset)
+	if err != nil || unchanged {
+		return ksid, err
+	}
+
+	newValues := make([]sqltypes.Value, 0, upd.KsidLength)
+	for idx, vCol := range primary.Columns {
+		colValue, exists := updColValues.EvalExprMap[vCol.String()]
+		if !exists {
+			newValues = append(newValues, row[idx])
+			continue
+		}
+		resolvedVal, err := env.Evaluate(colValue)
+		if err != nil {
+			return nil, err
+		}
+		newValues = append(newValues, resolvedVal.Value(vcursor.ConnCollation()))
+	}
+	newKsid, err := resolveKeyspaceID(ctx, vcursor, upd.KsidVindex, newValues)
+	if err != nil {
+		return nil, err
+	}
+	if newKsid == nil {
+		return nil, fmt.Errorf("values %v for column %v does not map to a keyspace id", newValues, primary.Columns)
+	}
+	if bytes.Equal(ksid, newKsid) {
+		return ksid, nil
+	}
+	rss, _, err := vcursor.ResolveDestinations(ctx, upd.Keyspace.Name, nil, []key.Destination{key.DestinationKeyspaceID(ksid), key.DestinationKeyspaceID(newKsid)})
+	if err != nil {
+		return nil, err
+	}
+	if len(rss) != 1 {
+		return nil, vterrors.VT12001(fmt.Sprintf("moving a row to another shard by updating the columns of its primary vindex %s", primary.Name))
+	}
+	return newKsid, nil
+}
+
+// vindexValuesUnchanged uses the column of the owned vindex query at the given offset
+// to tell whether the update keeps the values of a vindex as they are.
+func vindexValuesUnchanged(row []sqltypes.Value, offset int) (bool, error) {
+	if row[offset].IsNull() {
+		return false, nil
+	}
+	val, err := row[offset].ToCastInt64()
+	if err != nil {
+		return false, err
+	}
+	return val == int64(1), nil // 1 means that the old and new value are same and vindex update is not required.
+}
+
 func (upd *Update) description() PrimitiveDescription {
 	other := map[string]any{
 		"Query":                upd.Query,
@@ -501,6 +501,64 @@ func TestUpdateEqualMultiColChangedVindex(t *testing.T) {
 	})
 }
 
+func TestUpdateEqualMultiColChangedPrimaryVindex(t *testing.T) {
+	ks := buildTestVSchema().Keyspaces["sharded"]
+	upd := &Update{
+		DML: &DML{
+			RoutingParameters: &RoutingParameters{
+				Opcode:   Equal,
+				Keyspace: ks.Keyspace,
+				Vindex:   ks.Vindexes["rg_vdx"],
+				Values:   []evalengine.Expr{evalengine.NewLiteralInt(1), evalengine.NewLiteralInt(2)},
+			},
+			Query:            "dummy_update",
+			TableNames:       []string{ks.Tables["rg_tbl"].Name.String()},
+			Vindexes:         ks.Tables["rg_tbl"].ColumnVindexes,
+			OwnedVindexQuery: "dummy_subquery",
+			KsidVindex:       ks.Vindexes["rg_vdx"],
+			KsidLength:       2,
+		},
+		ChangedVindexValues: map[string]*VindexValues{
+			"rg_vdx": {
+				EvalExprMap: map[string]evalengine.Expr{
+					"colb": evalengine.NewLiteralInt(3),
+				},
+				Offset: 3,
+			},
+		},
+	}
+
+	results := []*sqltypes.Result{sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields(
+			"cola|colb|colc|colb=3",
+			"int64|int64|int64|int64",
+		),
+		"1|2|4|0",
+	)}
+	vc := newDMLTestVCursor("-20", "20-")
+	vc.results = results
+
+	_, err := upd.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, false)
+	require.NoError(t, err)
+	vc.ExpectLog(t, []string{
+		`ResolveDestinationsMultiCol sharded [[INT64(1) INT64(2)]] Destinations:DestinationKeyspaceID(0106e7ea22ce92708f)`,
+		`ExecuteMultiShard sharded.-20: dummy_subquery {} false false`,
+		// The new keyspace id of the row is in the same shard as the old one.
+		`ResolveDestinations sharded [] Destinations:DestinationKeyspaceID(0106e7ea22ce92708f),DestinationKeyspaceID(014eb190c9a2fa169c)`,
+		// The lookup vindex entry has to point to the new keyspace id.
+		`Execute delete from lkp_rg_tbl where from = :from and toc = :toc from: type:INT64 value:"4" toc: type:VARBINARY value:"\x01\x06\xe7\xea\"Βp\x8f" true`,
+		`Execute insert into lkp_rg_tbl(from, toc) values(:from_0, :toc_0) from_0: type:INT64 value:"4" toc_0: type:VARBINARY value:"\x01N\xb1\x90ɢ\xfa\x16\x9c" true`,
+		`ExecuteMultiShard sharded.-20: dummy_update {} true true`,
+	})
+
+	// The update would move the row to another shard.
+	vc.Rewind()
+	vc.results = results
+	vc.shardForKsid = []string{"-20", "-20", "20-"}
+	_, err = upd.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, false)
+	require.EqualError(t, err, "VT12001: unsupported: moving a row to another shard by updating the columns of its primary vindex rg_vdx")
+}
+
 func TestUpdateScatterChangedVindex(t *testing.T) {
 	// update t1 set c1 = 1, c2 = 2, c3 = 3
 	ks := buildTestVSchema().Keyspaces["sharded"]
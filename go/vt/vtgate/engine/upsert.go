@@ -0,0 +1,150 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/vterrors"
+)
+
+var _ Primitive = (*Upsert)(nil)
+
+// Upsert is a primitive that executes INSERT ... ON DUPLICATE KEY UPDATE and REPLACE statements
+// on tables whose foreign keys are managed by Vitess. The statement is split into one UpsertRow per row.
+// The Insert of a row is executed first, and if it fails because the row already exists,
+// the Update is executed instead, doing the foreign key cascades and verifications the row needs.
+type Upsert struct {
+	Upserts []*UpsertRow
+
+	txNeeded
+}
+
+// UpsertRow contains the Primitives used to upsert a single row.
+type UpsertRow struct {
+	// Insert inserts the row.
+	Insert Primitive
+	// Update is executed when the Insert fails with a duplicate key error.
+	Update Primitive
+	// Reinsert is set for REPLACE statements, where the Update deletes the existing row
+	// and the Insert is executed again afterwards.
+	Reinsert bool
+}
+
+// RouteType implements the Primitive interface
+func (u *Upsert) RouteType() string {
+	return "Upsert"
+}
+
+// GetKeyspaceName implements the Primitive interface
+func (u *Upsert) GetKeyspaceName() string {
+	return u.Upserts[0].Insert.GetKeyspaceName()
+}
+
+// GetTableName implements the Primitive interface
+func (u *Upsert) GetTableName() string {
+	return u.Upserts[0].Insert.GetTableName()
+}
+
+// GetFields implements the Primitive interface
+func (u *Upsert) GetFields(context.Context, VCursor, map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
+	return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "[BUG] GetFields should not be called")
+}
+
+// TryExecute implements the Primitive interface
+func (u *Upsert) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
+	res := &sqltypes.Result{}
+	for _, row := range u.Upserts {
+		qr, err := row.execute(ctx, vcursor, bindVars, wantfields)
+		if err != nil {
+			return nil, err
+		}
+		res.RowsAffected += qr.RowsAffected
+		if qr.InsertID != 0 {
+			res.InsertID = qr.InsertID
+		}
+	}
+	return res, nil
+}
+
+// TryStreamExecute implements the Primitive interface
+func (u *Upsert) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool, callback func(*sqltypes.Result) error) error {
+	res, err := u.TryExecute(ctx, vcursor, bindVars, wantfields)
+	if err != nil {
+		return err
+	}
+	return callback(res)
+}
+
+func (ur *UpsertRow) execute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
+	insRes, err := vcursor.ExecutePrimitive(ctx, ur.Insert, bindVars, wantfields)
+	if err == nil {
+		return insRes, nil
+	}
+	if vterrors.Code(err) != vtrpcpb.Code_ALREADY_EXISTS {
+		return nil, err
+	}
+
+	updRes, err := vcursor.ExecutePrimitive(ctx, ur.Update, bindVars, wantfields)
+	if err != nil {
+		return nil, err
+	}
+	if ur.Reinsert {
+		// REPLACE counts both the deleted and the inserted rows.
+		insRes, err = vcursor.ExecutePrimitive(ctx, ur.Insert, bindVars, wantfields)
+		if err != nil {
+			return nil, err
+		}
+		insRes.RowsAffected += updRes.RowsAffected
+		return insRes, nil
+	}
+	// MySQL reports 2 affected rows when an existing row is updated, and 0 when it is left unchanged.
+	if updRes.RowsAffected > 0 {
+		updRes.RowsAffected++
+	}
+	return updRes, nil
+}
+
+// Inputs implements the Primitive interface
+func (u *Upsert) Inputs() ([]Primitive, []map[string]any) {
+	var inputs []Primitive
+	var inputsMap []map[string]any
+	for idx, row := range u.Upserts {
+		updateName := "Update"
+		if row.Reinsert {
+			updateName = "Delete"
+		}
+		inputs = append(inputs, row.Insert, row.Update)
+		inputsMap = append(inputsMap,
+			map[string]any{inputName: fmt.Sprintf("Insert-%d", idx+1)},
+			map[string]any{inputName: fmt.Sprintf("%s-%d", updateName, idx+1)},
+		)
+	}
+	return inputs, inputsMap
+}
+
+func (u *Upsert) description() PrimitiveDescription {
+	desc := PrimitiveDescription{OperatorType: u.RouteType()}
+	if len(u.Upserts) > 0 && u.Upserts[0].Reinsert {
+		desc.Other = map[string]any{"Reinsert": true}
+	}
+	return desc
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+func TestUpsert(t *testing.T) {
+	ks := &vindexes.Keyspace{Name: "ks"}
+	newUpdate := func(query string) *Update {
+		return &Update{
+			DML: &DML{
+				Query: query,
+				RoutingParameters: &RoutingParameters{
+					Opcode:   Unsharded,
+					Keyspace: ks,
+				},
+			},
+		}
+	}
+	upsert := &Upsert{
+		Upserts: []*UpsertRow{{
+			Insert: NewQueryInsert(InsertUnsharded, ks, "insert into t(id, col) values (1, 2)"),
+			Update: newUpdate("update t set col = 2 where id = 1"),
+		}, {
+			Insert: NewQueryInsert(InsertUnsharded, ks, "insert into t(id, col) values (3, 4)"),
+			Update: newUpdate("update t set col = 4 where id = 3"),
+		}},
+	}
+	dupErr := vterrors.Errorf(vtrpcpb.Code_ALREADY_EXISTS, "Duplicate entry '3' for key 't.PRIMARY'")
+
+	t.Run("insert and update", func(t *testing.T) {
+		vc := newDMLTestVCursor("0")
+		vc.results = []*sqltypes.Result{{RowsAffected: 1}, nil, {RowsAffected: 1}}
+		vc.resultErr = dupErr
+		res, err := upsert.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, false)
+		require.NoError(t, err)
+		vc.ExpectLog(t, []string{
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: insert into t(id, col) values (1, 2) {} true true`,
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: insert into t(id, col) values (3, 4) {} true true`,
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: update t set col = 4 where id = 3 {} true true`,
+		})
+		// 1 for the inserted row and 2 for the updated one, like MySQL does.
+		require.EqualValues(t, 3, res.RowsAffected)
+	})
+
+	t.Run("insert failure", func(t *testing.T) {
+		vc := newDMLTestVCursor("0")
+		vc.results = []*sqltypes.Result{nil}
+		vc.resultErr = vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "Cannot add or update a child row: a foreign key constraint fails")
+		_, err := upsert.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, false)
+		require.ErrorContains(t, err, "Cannot add or update a child row: a foreign key constraint fails")
+		vc.ExpectLog(t, []string{
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: insert into t(id, col) values (1, 2) {} true true`,
+		})
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		replace := &Upsert{
+			Upserts: []*UpsertRow{{
+				Insert: NewQueryInsert(InsertUnsharded, ks, "insert into t(id, col) values (1, 2)"),
+				Update: &Delete{
+					DML: &DML{
+						Query: "delete from t where id = 1",
+						RoutingParameters: &RoutingParameters{
+							Opcode:   Unsharded,
+							Keyspace: ks,
+						},
+					},
+				},
+				Reinsert: true,
+			}},
+		}
+		vc := newDMLTestVCursor("0")
+		vc.results = []*sqltypes.Result{nil, {RowsAffected: 1}, {RowsAffected: 1}}
+		vc.resultErr = dupErr
+		res, err := replace.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, false)
+		require.NoError(t, err)
+		vc.ExpectLog(t, []string{
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: insert into t(id, col) values (1, 2) {} true true`,
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: delete from t where id = 1 {} true true`,
+			`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+			`ExecuteMultiShard ks.0: insert into t(id, col) values (1, 2) {} true true`,
+		})
+		require.EqualValues(t, 2, res.RowsAffected)
+	})
+}
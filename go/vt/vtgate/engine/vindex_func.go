@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
@@ -42,10 +43,14 @@ type VindexFunc struct {
 	// Fields is the field info for the result.
 	Fields []*querypb.Field
 	// Cols contains source column numbers: 0 for id, 1 for keyspace_id.
-	Cols []int
-	// TODO(sougou): add support for MultiColumn.
-	Vindex vindexes.SingleColumn
-	Value  evalengine.Expr
+	Cols   []int
+	Vindex vindexes.Vindex
+	// Value is the value to map when Vindex is a SingleColumn.
+	Value evalengine.Expr
+	// Values are the rows to map when Vindex is a MultiColumn. Each of them
+	// evaluates to a tuple holding the values of a prefix of the vindex columns,
+	// or to a single value for the first column.
+	Values []evalengine.Expr
 
 	// VindexFunc does not take inputs
 	noInputs
@@ -113,24 +118,52 @@ func (vf *VindexFunc) GetFields(ctx context.Context, vcursor VCursor, bindVars m
 
 func (vf *VindexFunc) mapVindex(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
 	env := evalengine.NewExpressionEnv(ctx, bindVars, vcursor)
-	k, err := env.Evaluate(vf.Value)
-	if err != nil {
-		return nil, err
-	}
 	var values []sqltypes.Value
-	value := k.Value(vcursor.ConnCollation())
-	if value.Type() == querypb.Type_TUPLE {
-		values = k.TupleValues()
-	} else {
-		values = append(values, value)
+	var destinations []key.Destination
+	switch vindex := vf.Vindex.(type) {
+	case vindexes.SingleColumn:
+		k, err := env.Evaluate(vf.Value)
+		if err != nil {
+			return nil, err
+		}
+		value := k.Value(vcursor.ConnCollation())
+		if value.Type() == querypb.Type_TUPLE {
+			values = k.TupleValues()
+		} else {
+			values = append(values, value)
+		}
+		destinations, err = vindex.Map(ctx, vcursor, values)
+		if err != nil {
+			return nil, err
+		}
+	case vindexes.MultiColumn:
+		rowsColValues := make([][]sqltypes.Value, 0, len(vf.Values))
+		for _, expr := range vf.Values {
+			k, err := env.Evaluate(expr)
+			if err != nil {
+				return nil, err
+			}
+			var colValues []sqltypes.Value
+			value := k.Value(vcursor.ConnCollation())
+			if value.Type() == querypb.Type_TUPLE {
+				colValues = k.TupleValues()
+			} else {
+				colValues = []sqltypes.Value{value}
+			}
+			rowsColValues = append(rowsColValues, colValues)
+			values = append(values, multiColumnID(colValues))
+		}
+		var err error
+		destinations, err = vindex.Map(ctx, vcursor, rowsColValues)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, vterrors.VT13001(fmt.Sprintf("unexpected vindex type: %T", vf.Vindex))
 	}
 	result := &sqltypes.Result{
 		Fields: vf.Fields,
 	}
-	destinations, err := vf.Vindex.Map(ctx, vcursor, values)
-	if err != nil {
-		return nil, err
-	}
 	if len(destinations) != len(values) {
 		// should never happen
 		return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "Vindex.Map() length mismatch: input values count is %d, output destinations count is %d",
@@ -194,6 +227,20 @@ func (vf *VindexFunc) mapVindex(ctx context.Context, vcursor VCursor, bindVars m
 	return result, nil
 }
 
+// multiColumnID returns the id reported for the column values of a multi-column vindex, e.g. (1, 'a').
+func multiColumnID(colValues []sqltypes.Value) sqltypes.Value {
+	var buf strings.Builder
+	buf.WriteByte('(')
+	for i, colValue := range colValues {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		colValue.EncodeSQLStringBuilder(&buf)
+	}
+	buf.WriteByte(')')
+	return sqltypes.NewVarBinary(buf.String())
+}
+
 func (vf *VindexFunc) buildRow(id sqltypes.Value, ksid []byte, kr *topodatapb.KeyRange) ([]sqltypes.Value, error) {
 	row := make([]sqltypes.Value, 0, len(vf.Fields))
 	for _, col := range vf.Cols {
@@ -246,7 +293,16 @@ func (vf *VindexFunc) description() PrimitiveDescription {
 	other := map[string]any{
 		"Fields":  fields,
 		"Columns": vf.Cols,
-		"Value":   sqlparser.String(vf.Value),
+	}
+	if vf.Value != nil {
+		other["Value"] = sqlparser.String(vf.Value)
+	}
+	if len(vf.Values) > 0 {
+		values := make([]string, 0, len(vf.Values))
+		for _, value := range vf.Values {
+			values = append(values, sqlparser.String(value))
+		}
+		other["Values"] = values
 	}
 	if vf.Vindex != nil {
 		other["Vindex"] = vf.Vindex.String()
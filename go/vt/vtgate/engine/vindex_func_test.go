@@ -249,6 +249,46 @@ func TestVindexFuncGetFields(t *testing.T) {
 	}
 }
 
+func TestVindexFuncMapMultiColumn(t *testing.T) {
+	vindex, err := vindexes.CreateVindex("multicol", "multicol", map[string]string{
+		"column_count":  "2",
+		"column_bytes":  "1,7",
+		"column_vindex": "numeric,numeric",
+	})
+	require.NoError(t, err)
+
+	// All the columns of the vindex give a keyspace id, and a prefix of them gives a keyrange.
+	vf := &VindexFunc{
+		Fields: sqltypes.MakeTestFields("id|keyspace_id|hex(keyspace_id)|range_start|range_end", "varbinary|varbinary|varbinary|varbinary|varbinary"),
+		Cols:   []int{0, 1, 4, 2, 3},
+		Opcode: VindexMap,
+		Vindex: vindex,
+		Values: []evalengine.Expr{
+			evalengine.TupleExpr{evalengine.NewLiteralInt(0x0100000000000000), evalengine.NewLiteralInt(0x0200)},
+			evalengine.NewLiteralInt(0x0100000000000000),
+		},
+	}
+	got, err := vf.TryExecute(context.Background(), &noopVCursor{}, nil, false)
+	require.NoError(t, err)
+	want := &sqltypes.Result{
+		Fields: vf.Fields,
+		Rows: [][]sqltypes.Value{{
+			sqltypes.NewVarBinary("(72057594037927936, 512)"),
+			sqltypes.MakeTrusted(sqltypes.VarBinary, []byte{0x01, 0, 0, 0, 0, 0, 0, 0x02}),
+			sqltypes.NewVarBinary("0100000000000002"),
+			sqltypes.NULL,
+			sqltypes.NULL,
+		}, {
+			sqltypes.NewVarBinary("(72057594037927936)"),
+			sqltypes.NULL,
+			sqltypes.NULL,
+			sqltypes.MakeTrusted(sqltypes.VarBinary, []byte{0x01}),
+			sqltypes.MakeTrusted(sqltypes.VarBinary, []byte{0x02}),
+		}},
+	}
+	require.Equal(t, want, got)
+}
+
 func TestFieldOrder(t *testing.T) {
 	vf := testVindexFunc(&nvindex{matchid: true})
 	vf.Fields = sqltypes.MakeTestFields("keyspace_id|id|keyspace_id", "varbinary|varbinary|varbinary")
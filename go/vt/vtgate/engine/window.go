@@ -0,0 +1,370 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/slice"
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine/opcode"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+)
+
+var _ Primitive = (*Window)(nil)
+
+type (
+	// Window is used to evaluate window functions in vtgate.
+	// The input has to be sorted by the partition columns followed by the ordering columns
+	// of the window. A whole partition is buffered before its rows are returned, since
+	// functions like CUME_DIST need to know the size of the partition.
+	Window struct {
+		Source Primitive
+
+		// PartitionBy are the columns that split the input into partitions
+		PartitionBy []CheckCol `json:",omitempty"`
+		// OrderBy are the columns that decide which rows are peers of each other
+		OrderBy []CheckCol `json:",omitempty"`
+
+		Funcs []*WindowFunc
+
+		// Cols defines the columns returned by this primitive.
+		// A positive value or zero is the offset of a column in the source row,
+		// and a negative value -n is the result of Funcs[n-1].
+		Cols []int
+	}
+
+	// WindowFunc is a single window function evaluated by the Window primitive
+	WindowFunc struct {
+		Opcode opcode.WindowOpcode
+		Alias  string
+
+		// N is the number of buckets for NTILE
+		N evalengine.Expr `json:",omitempty"`
+	}
+
+	// windowState holds the rows of the partition being processed
+	windowState struct {
+		w           *Window
+		partitionBy []CheckCol
+		orderBy     []CheckCol
+		buckets     []int64
+		partition   []sqltypes.Row
+	}
+)
+
+// TryExecute implements the Primitive interface
+func (w *Window) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
+	state, err := w.newState(ctx, vcursor, bindVars)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := vcursor.ExecutePrimitive(ctx, w.Source, bindVars, wantfields)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &sqltypes.Result{}
+	if input.Fields != nil {
+		result.Fields = w.fields(input.Fields)
+	}
+
+	for _, row := range input.Rows {
+		out, err := state.add(row)
+		if err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, out...)
+	}
+
+	out, err := state.flush()
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = append(result.Rows, out...)
+	return result, nil
+}
+
+// TryStreamExecute implements the Primitive interface
+func (w *Window) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool, callback func(*sqltypes.Result) error) error {
+	state, err := w.newState(ctx, vcursor, bindVars)
+	if err != nil {
+		return err
+	}
+
+	err = vcursor.StreamExecutePrimitive(ctx, w.Source, bindVars, wantfields, func(input *sqltypes.Result) error {
+		result := &sqltypes.Result{}
+		if input.Fields != nil {
+			result.Fields = w.fields(input.Fields)
+		}
+		for _, row := range input.Rows {
+			out, err := state.add(row)
+			if err != nil {
+				return err
+			}
+			result.Rows = append(result.Rows, out...)
+		}
+		if result.Fields == nil && len(result.Rows) == 0 {
+			return nil
+		}
+		return callback(result)
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := state.flush()
+	if err != nil || len(out) == 0 {
+		return err
+	}
+	return callback(&sqltypes.Result{Rows: out})
+}
+
+// GetFields implements the Primitive interface
+func (w *Window) GetFields(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
+	qr, err := w.Source.GetFields(ctx, vcursor, bindVars)
+	if err != nil {
+		return nil, err
+	}
+	return &sqltypes.Result{Fields: w.fields(qr.Fields)}, nil
+}
+
+// RouteType implements the Primitive interface
+func (w *Window) RouteType() string {
+	return w.Source.RouteType()
+}
+
+// GetKeyspaceName implements the Primitive interface
+func (w *Window) GetKeyspaceName() string {
+	return w.Source.GetKeyspaceName()
+}
+
+// GetTableName implements the Primitive interface
+func (w *Window) GetTableName() string {
+	return w.Source.GetTableName()
+}
+
+// NeedsTransaction implements the Primitive interface
+func (w *Window) NeedsTransaction() bool {
+	return w.Source.NeedsTransaction()
+}
+
+// Inputs implements the Primitive interface
+func (w *Window) Inputs() ([]Primitive, []map[string]any) {
+	return []Primitive{w.Source}, nil
+}
+
+func (w *Window) description() PrimitiveDescription {
+	other := map[string]any{
+		"Functions": slice.Map(w.Funcs, func(f *WindowFunc) string { return f.String() }),
+		"Columns":   w.Cols,
+	}
+	if len(w.PartitionBy) > 0 {
+		other["PartitionBy"] = slice.Map(w.PartitionBy, CheckCol.String)
+	}
+	if len(w.OrderBy) > 0 {
+		other["OrderBy"] = slice.Map(w.OrderBy, CheckCol.String)
+	}
+	return PrimitiveDescription{
+		OperatorType: "Window",
+		Other:        other,
+	}
+}
+
+func (w *Window) fields(input []*querypb.Field) []*querypb.Field {
+	fields := make([]*querypb.Field, 0, len(w.Cols))
+	for _, col := range w.Cols {
+		if col >= 0 {
+			fields = append(fields, input[col])
+			continue
+		}
+		f := w.Funcs[-col-1]
+		fields = append(fields, &querypb.Field{
+			Name:    f.Alias,
+			Type:    f.Opcode.Type(),
+			Charset: uint32(collations.CollationBinaryID),
+			Flags:   uint32(querypb.MySqlFlag_NOT_NULL_FLAG),
+		})
+	}
+	return fields
+}
+
+func (w *Window) newState(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*windowState, error) {
+	state := &windowState{
+		w:           w,
+		partitionBy: append([]CheckCol(nil), w.PartitionBy...),
+		orderBy:     append([]CheckCol(nil), w.OrderBy...),
+		buckets:     make([]int64, len(w.Funcs)),
+	}
+
+	env := evalengine.NewExpressionEnv(ctx, bindVars, vcursor)
+	for i, f := range w.Funcs {
+		if f.Opcode != opcode.WindowNtile {
+			continue
+		}
+		res, err := env.Evaluate(f.N)
+		if err != nil {
+			return nil, err
+		}
+		value := res.Value(vcursor.ConnCollation())
+		n, err := value.ToInt64()
+		if err != nil || value.IsNull() || n <= 0 {
+			return nil, vterrors.NewErrorf(vtrpcpb.Code_INVALID_ARGUMENT, vterrors.WrongArguments, "Incorrect arguments to ntile")
+		}
+		state.buckets[i] = n
+	}
+	return state, nil
+}
+
+// add adds a row to the current partition. If the row starts a new partition,
+// the rows of the previous partition are returned with the window functions evaluated.
+func (s *windowState) add(row sqltypes.Row) ([]sqltypes.Row, error) {
+	var out []sqltypes.Row
+	if len(s.partition) > 0 {
+		same, err := equalOn(s.partitionBy, s.partition[len(s.partition)-1], row)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			out, err = s.flush()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	s.partition = append(s.partition, row)
+	return out, nil
+}
+
+// flush evaluates the window functions over the current partition and returns its rows
+func (s *windowState) flush() ([]sqltypes.Row, error) {
+	rows := s.partition
+	s.partition = nil
+
+	size := len(rows)
+	out := make([]sqltypes.Row, 0, size)
+	values := make([]sqltypes.Value, len(s.w.Funcs))
+	denseRank := 0
+	for start := 0; start < size; {
+		// rows that are equal on the ORDER BY columns are peers, and get the same rank
+		end := start + 1
+		for ; end < size; end++ {
+			peer, err := equalOn(s.orderBy, rows[start], rows[end])
+			if err != nil {
+				return nil, err
+			}
+			if !peer {
+				break
+			}
+		}
+		denseRank++
+
+		for idx := start; idx < end; idx++ {
+			for i, f := range s.w.Funcs {
+				switch f.Opcode {
+				case opcode.WindowRowNumber:
+					values[i] = sqltypes.NewUint64(uint64(idx + 1))
+				case opcode.WindowRank:
+					values[i] = sqltypes.NewUint64(uint64(start + 1))
+				case opcode.WindowDenseRank:
+					values[i] = sqltypes.NewUint64(uint64(denseRank))
+				case opcode.WindowPercentRank:
+					var pr float64
+					if size > 1 {
+						pr = float64(start) / float64(size-1)
+					}
+					values[i] = sqltypes.NewFloat64(pr)
+				case opcode.WindowCumeDist:
+					values[i] = sqltypes.NewFloat64(float64(end) / float64(size))
+				case opcode.WindowNtile:
+					values[i] = sqltypes.NewUint64(uint64(ntileBucket(int64(idx), int64(size), s.buckets[i])))
+				default:
+					return nil, vterrors.VT13001(fmt.Sprintf("unexpected window function: %s", f.Opcode.String()))
+				}
+			}
+			out = append(out, s.w.project(rows[idx], values))
+		}
+		start = end
+	}
+	return out, nil
+}
+
+func (w *Window) project(row sqltypes.Row, values []sqltypes.Value) sqltypes.Row {
+	out := make(sqltypes.Row, 0, len(w.Cols))
+	for _, col := range w.Cols {
+		if col >= 0 {
+			out = append(out, row[col])
+		} else {
+			out = append(out, values[-col-1])
+		}
+	}
+	return out
+}
+
+// ntileBucket returns the bucket that the row at idx belongs to, when a partition of the given size
+// is split into n buckets. Like in MySQL, the first buckets get one extra row when the rows can't be
+// evenly distributed.
+func ntileBucket(idx, size, n int64) int64 {
+	perBucket, rest := size/n, size%n
+	if big := rest * (perBucket + 1); idx >= big {
+		return rest + (idx-big)/perBucket + 1
+	}
+	return idx/(perBucket+1) + 1
+}
+
+// equalOn checks if the two rows are equal on the given columns. If a comparison
+// can't be done on the column value, the weight string column is used instead.
+func equalOn(cols []CheckCol, a, b sqltypes.Row) (bool, error) {
+	for i, col := range cols {
+		cmp, err := evalengine.NullsafeCompare(a[col.Col], b[col.Col], col.Type.Coll)
+		if err != nil {
+			_, isComparisonErr := err.(evalengine.UnsupportedComparisonError)
+			_, isCollationErr := err.(evalengine.UnsupportedCollationError)
+			if !isComparisonErr && !isCollationErr || col.WsCol == nil {
+				return false, err
+			}
+			col = col.SwitchToWeightString()
+			cols[i] = col
+			cmp, err = evalengine.NullsafeCompare(a[col.Col], b[col.Col], col.Type.Coll)
+			if err != nil {
+				return false, err
+			}
+		}
+		if cmp != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f *WindowFunc) String() string {
+	var args string
+	if f.N != nil {
+		args = sqlparser.String(f.N)
+	}
+	if f.Alias == "" {
+		return fmt.Sprintf("%s(%s)", f.Opcode.String(), args)
+	}
+	return fmt.Sprintf("%s(%s) AS %s", f.Opcode.String(), args, f.Alias)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/vtgate/engine/opcode"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+)
+
+func TestWindowExecute(t *testing.T) {
+	fields := sqltypes.MakeTestFields(
+		"col|id",
+		"varchar|int64",
+	)
+	input := &fakePrimitive{
+		results: []*sqltypes.Result{
+			sqltypes.MakeTestResult(fields, "a|1", "a|2", "A|2", "a|3", "b|5"),
+		},
+	}
+
+	window := &Window{
+		Source:      input,
+		PartitionBy: []CheckCol{{Col: 0, Type: evalengine.Type{Type: sqltypes.VarChar, Coll: collations.ID(45)}}},
+		OrderBy:     []CheckCol{{Col: 1, Type: evalengine.Type{Type: sqltypes.Int64, Coll: collations.CollationBinaryID}}},
+		Funcs: []*WindowFunc{
+			{Opcode: opcode.WindowRowNumber},
+			{Opcode: opcode.WindowRank},
+			{Opcode: opcode.WindowDenseRank},
+			{Opcode: opcode.WindowPercentRank},
+			{Opcode: opcode.WindowCumeDist},
+			{Opcode: opcode.WindowNtile, N: evalengine.NewLiteralInt(2)},
+		},
+		Cols: []int{1, -1, -2, -3, -4, -5, -6},
+	}
+
+	expected := sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields(
+			"id|rn|rnk|dense|pr|cume|tile",
+			"int64|uint64|uint64|uint64|float64|float64|uint64",
+		),
+		"1|1|1|1|0|0.25|1",
+		"2|2|2|2|0.3333333333333333|0.75|1",
+		"2|3|2|2|0.3333333333333333|0.75|2",
+		"3|4|4|3|1|1|2",
+		"5|1|1|1|0|1|1",
+	)
+
+	r, err := window.TryExecute(context.Background(), &noopVCursor{}, nil, true)
+	require.NoError(t, err)
+	utils.MustMatch(t, expected.Rows, r.Rows)
+	require.Len(t, r.Fields, 7)
+	assert.Equal(t, sqltypes.Uint64, r.Fields[1].Type)
+	assert.Equal(t, sqltypes.Float64, r.Fields[4].Type)
+
+	// streaming gives the same result, even when a partition is spread over multiple packets
+	input.rewind()
+	r, err = wrapStreamExecute(window, &noopVCursor{}, nil, true)
+	require.NoError(t, err)
+	utils.MustMatch(t, expected.Rows, r.Rows)
+}
+
+func TestWindowNoPartition(t *testing.T) {
+	fields := sqltypes.MakeTestFields(
+		"id",
+		"int64",
+	)
+	input := &fakePrimitive{
+		results: []*sqltypes.Result{
+			sqltypes.MakeTestResult(fields, "1", "2", "3", "4", "5"),
+		},
+	}
+
+	window := &Window{
+		Source: input,
+		Funcs: []*WindowFunc{
+			{Opcode: opcode.WindowRank},
+			{Opcode: opcode.WindowNtile, N: evalengine.NewLiteralInt(3)},
+		},
+		Cols: []int{0, -1, -2},
+	}
+
+	r, err := window.TryExecute(context.Background(), &noopVCursor{}, nil, true)
+	require.NoError(t, err)
+	// without ORDER BY, all rows are peers
+	expected := sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id|rnk|tile", "int64|uint64|uint64"),
+		"1|1|1", "2|1|1", "3|1|2", "4|1|2", "5|1|3",
+	)
+	utils.MustMatch(t, expected.Rows, r.Rows)
+}
+
+func TestWindowNtileArgument(t *testing.T) {
+	window := &Window{
+		Source: &fakePrimitive{},
+		Funcs:  []*WindowFunc{{Opcode: opcode.WindowNtile, N: evalengine.NewLiteralInt(0)}},
+		Cols:   []int{-1},
+	}
+
+	_, err := window.TryExecute(context.Background(), &noopVCursor{}, nil, true)
+	require.EqualError(t, err, "Incorrect arguments to ntile")
+}
@@ -58,6 +58,10 @@ import (
 	"vitess.io/vitess/go/vt/vtgate/logstats"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+	"vitess.io/vitess/go/vt/vtgate/queryrewrite"
+	"vitess.io/vitess/go/vt/vtgate/quota"
+	"vitess.io/vitess/go/vt/vtgate/resultcache"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vtgate/vschemaacl"
 	"vitess.io/vitess/go/vt/vtgate/vtgateservice"
@@ -122,6 +126,18 @@ type Executor struct {
 
 	warmingReadsPercent int
 	warmingReadsChannel chan bool
+
+	// quotas, if set, limits the queries and transactions of the callers.
+	quotas *quota.Limiter
+
+	// rewriteRules, if set, rewrites or denies the queries before they are planned.
+	rewriteRules *queryrewrite.Engine
+
+	// digests, if set, aggregates the statistics of the executed queries by normalized query.
+	digests *querydigest.Collector
+
+	// resultCache, if set, caches the results of the read-only queries asking for it.
+	resultCache *resultcache.Cache
 }
 
 var executorOnce sync.Once
@@ -323,6 +339,7 @@ func (e *Executor) StreamExecute(
 
 		// Check if there was partial DML execution. If so, rollback the effect of the partially executed query.
 		if err != nil {
+			e.recordQueryDigest(plan, vc, logStats, srr.rowsAffected, uint64(srr.rowsReturned), err)
 			if !canReturnRows(plan.Type) {
 				return e.rollbackExecIfNeeded(ctx, safeSession, bindVars, logStats, err)
 			}
@@ -347,6 +364,7 @@ func (e *Executor) StreamExecute(
 		logStats.ActiveKeyspace = vc.keyspace
 
 		e.updateQueryCounts(plan.Instructions.RouteType(), plan.Instructions.GetKeyspaceName(), plan.Instructions.GetTableName(), int64(logStats.ShardQueries))
+		e.recordQueryDigest(plan, vc, logStats, srr.rowsAffected, uint64(srr.rowsReturned), nil)
 
 		return err
 	}
@@ -679,6 +697,9 @@ func (e *Executor) handleKill(ctx context.Context, mysqlCtx vtgateservice.MySQLC
 // CloseSession releases the current connection, which rollbacks open transactions and closes reserved connections.
 // It is called then the MySQL servers closes the connection to its client.
 func (e *Executor) CloseSession(ctx context.Context, safeSession *SafeSession) error {
+	if e.quotas != nil {
+		e.quotas.EndTransaction(safeSession.GetSessionUUID())
+	}
 	return e.txConn.ReleaseAll(ctx, safeSession)
 }
 
@@ -1039,16 +1060,7 @@ func (e *Executor) getPlan(
 		return nil, vterrors.VT13001("vschema not initialized")
 	}
 
-	vcursor.SetIgnoreMaxMemoryRows(sqlparser.IgnoreMaxMaxMemoryRowsDirective(stmt))
-	vcursor.SetConsolidator(sqlparser.Consolidator(stmt))
-	vcursor.SetWorkloadName(sqlparser.GetWorkloadNameFromStatement(stmt))
-	priority, err := sqlparser.GetPriorityFromStatement(stmt)
-	if err != nil {
-		return nil, err
-	}
-	vcursor.SetPriority(priority)
-
-	setVarComment, err := prepareSetVarComment(vcursor, stmt)
+	setVarComment, err := setPlanDirectives(vcursor, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -1083,6 +1095,23 @@ func (e *Executor) getPlan(
 	return e.cacheAndBuildStatement(ctx, vcursor, query, stmt, reservedVars, bindVarNeeds, logStats)
 }
 
+// setPlanDirectives sets the directives of the statement on the vcursor, and returns
+// the SET_VAR comment the statement is rewritten with.
+func setPlanDirectives(vcursor *vcursorImpl, stmt sqlparser.Statement) (string, error) {
+	vcursor.SetIgnoreMaxMemoryRows(sqlparser.IgnoreMaxMaxMemoryRowsDirective(stmt))
+	vcursor.SetConsolidator(sqlparser.Consolidator(stmt))
+	vcursor.SetWorkloadName(sqlparser.GetWorkloadNameFromStatement(stmt))
+	vcursor.resultCache, vcursor.resultCacheTTL = sqlparser.ResultCache(stmt)
+	vcursor.hedgedReads, vcursor.hedgedReadsSet = sqlparser.HedgedReads(stmt)
+	priority, err := sqlparser.GetPriorityFromStatement(stmt)
+	if err != nil {
+		return "", err
+	}
+	vcursor.SetPriority(priority)
+
+	return prepareSetVarComment(vcursor, stmt)
+}
+
 func (e *Executor) hashPlan(ctx context.Context, vcursor *vcursorImpl, query string) PlanCacheKey {
 	hasher := vthash.New256()
 	vcursor.keyForPlan(ctx, query, hasher)
@@ -1128,7 +1157,14 @@ func (e *Executor) cacheAndBuildStatement(
 		var plan *engine.Plan
 		var err error
 		plan, logStats.CachedPlan, err = e.plans.GetOrLoad(planKey, e.epoch.Load(), func() (*engine.Plan, error) {
-			return e.buildStatement(ctx, vcursor, query, stmt, reservedVars, bindVarNeeds)
+			arguments := typedArguments(stmt)
+			plan, err := e.buildStatement(ctx, vcursor, query, stmt, reservedVars, bindVarNeeds)
+			if err == nil {
+				// remember the target and the types of the bind variables, to build the plan again when warming up the cache
+				plan.Target = vcursor.safeSession.TargetString
+				plan.Arguments = arguments
+			}
+			return plan, err
 		})
 		return plan, err
 	}
@@ -1324,11 +1360,19 @@ func (e *Executor) prepare(ctx context.Context, safeSession *SafeSession, sql st
 	switch stmtType {
 	case sqlparser.StmtSelect, sqlparser.StmtShow:
 		return e.handlePrepare(ctx, safeSession, sql, bindVars, logStats)
-	case sqlparser.StmtDDL, sqlparser.StmtBegin, sqlparser.StmtCommit, sqlparser.StmtRollback, sqlparser.StmtSet, sqlparser.StmtInsert, sqlparser.StmtReplace, sqlparser.StmtUpdate, sqlparser.StmtDelete,
-		sqlparser.StmtUse, sqlparser.StmtOther, sqlparser.StmtAnalyze, sqlparser.StmtComment, sqlparser.StmtExplain, sqlparser.StmtFlush, sqlparser.StmtKill:
-		return nil, nil
 	}
-	return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "[BUG] unrecognized prepare statement: %s", sql)
+
+	stmt, _, err := parseAndValidateQuery(sql)
+	if err != nil {
+		return nil, err
+	}
+	// The fields of an EXECUTE statement are the fields of the statement it executes.
+	if sqlparser.ASTToStatementType(stmt) == sqlparser.StmtExecute {
+		return e.handlePrepare(ctx, safeSession, sql, bindVars, logStats)
+	}
+	// The other statements do not return rows, or their fields are only known
+	// once they are executed, like for EXPLAIN: they are prepared without fields.
+	return nil, nil
 }
 
 func (e *Executor) handlePrepare(ctx context.Context, safeSession *SafeSession, sql string, bindVars map[string]*querypb.BindVariable, logStats *logstats.LogStats) ([]*querypb.Field, error) {
@@ -1371,6 +1415,14 @@ func (e *Executor) handlePrepare(ctx context.Context, safeSession *SafeSession,
 }
 
 func parseAndValidateQuery(query string) (sqlparser.Statement, *sqlparser.ReservedVars, error) {
+	stmt, reserved, err := parseAndValidate(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stmt, sqlparser.NewReservedVars("vtg", reserved), nil
+}
+
+func parseAndValidate(query string) (sqlparser.Statement, sqlparser.BindVars, error) {
 	stmt, reserved, err := sqlparser.Parse2(query)
 	if err != nil {
 		return nil, nil, err
@@ -1378,7 +1430,7 @@ func parseAndValidateQuery(query string) (sqlparser.Statement, *sqlparser.Reserv
 	if !sqlparser.IgnoreMaxPayloadSizeDirective(stmt) && !isValidPayloadSize(query) {
 		return nil, nil, vterrors.NewErrorf(vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.NetPacketTooLarge, "query payload size above threshold")
 	}
-	return stmt, sqlparser.NewReservedVars("vtg", reserved), nil
+	return stmt, reserved, nil
 }
 
 // ExecuteMultiShard implements the IExecutor interface
@@ -42,6 +42,7 @@ import (
 	"vitess.io/vitess/go/test/utils"
 	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/discovery"
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
@@ -52,6 +53,10 @@ import (
 	"vitess.io/vitess/go/vt/vtgate/buffer"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/logstats"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+	"vitess.io/vitess/go/vt/vtgate/queryrewrite"
+	"vitess.io/vitess/go/vt/vtgate/quota"
+	"vitess.io/vitess/go/vt/vtgate/resultcache"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vtgate/vschemaacl"
 	"vitess.io/vitess/go/vt/vtgate/vtgateservice"
@@ -2776,6 +2781,92 @@ func TestExecutorPrepareExecute(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestExecutorPrepareStatementTypes(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+	session := &vtgatepb.Session{TargetString: "@primary", Autocommit: true}
+
+	_, err := executorExecSession(ctx, executor, "prepare prep_user from 'select id from user where id = ?'", nil, session)
+	require.NoError(t, err)
+	_, err = executorExecSession(ctx, executor, "prepare prep_ins from 'insert into user(id) values (?)'", nil, session)
+	require.NoError(t, err)
+	_, err = executorExecSession(ctx, executor, "set @id = 1", nil, session)
+	require.NoError(t, err)
+
+	// the execute statements have the fields of the statement they execute
+	fields, err := executorPrepare(ctx, executor, session, "execute prep_user using @id", nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, fields)
+	fields, err = executorPrepare(ctx, executor, session, "execute prep_ins using @id", nil)
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	for _, sql := range []string{
+		"prepare prep_user2 from 'select 1'",
+		"deallocate prepare prep_user",
+		"savepoint a",
+		"release savepoint a",
+		"rollback to a",
+		"lock tables user read",
+		"unlock tables",
+		"call proc()",
+		"/* comment */",
+		"vstream * from user",
+		"revert vitess_migration '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90'",
+	} {
+		fields, err := executorPrepare(ctx, executor, session, sql, nil)
+		assert.NoError(t, err, sql)
+		assert.Empty(t, fields, sql)
+	}
+
+	_, err = executorPrepare(ctx, executor, session, "savepoint", nil)
+	assert.ErrorContains(t, err, "syntax error")
+}
+
+func TestExecutorPreparedPlan(t *testing.T) {
+	executor, sbc1, _, _, ctx := createExecutorEnv(t)
+	executor.normalize = true
+	session := &vtgatepb.Session{TargetString: "@primary", Autocommit: true}
+
+	sql := "select id from user where id = :v1 and name = 'foo'"
+	pp := &preparedPlan{sql: sql}
+	execute := func() {
+		t.Helper()
+		_, err := executorExecSession(withPreparedPlan(ctx, pp), executor, sql, map[string]*querypb.BindVariable{"v1": sqltypes.Int64BindVariable(1)}, session)
+		require.NoError(t, err)
+	}
+
+	// the statement is rewritten at its first execution
+	execute()
+	rewritten := pp.rewritten
+	require.NotNil(t, rewritten)
+	assert.Equal(t, "select id from `user` where id = :v1 and `name` = :name /* VARCHAR */", rewritten.query)
+	wantQueries := []*querypb.BoundQuery{{
+		Sql: "select id from `user` where id = :v1 and `name` = :name /* VARCHAR */",
+		BindVariables: map[string]*querypb.BindVariable{
+			"v1":   sqltypes.Int64BindVariable(1),
+			"name": sqltypes.StringBindVariable("foo"),
+		},
+	}}
+	utils.MustMatch(t, wantQueries, sbc1.Queries)
+
+	// and only looked up in the plan cache afterwards
+	sbc1.Queries = nil
+	execute()
+	assert.Same(t, rewritten, pp.rewritten)
+	utils.MustMatch(t, wantQueries, sbc1.Queries)
+
+	// until the session changes
+	sbc1.Queries = nil
+	session.Options = &querypb.ExecuteOptions{SqlSelectLimit: 10}
+	execute()
+	assert.NotSame(t, rewritten, pp.rewritten)
+	require.Len(t, sbc1.Queries, 1)
+	assert.Equal(t, "select id from `user` where id = :v1 and `name` = :name /* VARCHAR */ limit 10", sbc1.Queries[0].Sql)
+
+	// the other queries are not bound to the plan of the statement
+	assert.Nil(t, preparedPlanFromContext(withPreparedPlan(ctx, pp), "select id from user"))
+}
+
 func TestExecutorTruncateErrors(t *testing.T) {
 	executor, _, _, _, ctx := createExecutorEnv(t)
 
@@ -2795,7 +2886,7 @@ func TestExecutorTruncateErrors(t *testing.T) {
 	assert.EqualError(t, err, "syntax error at posi [TRUNCATED]")
 
 	_, err = executor.Prepare(context.Background(), "TestExecute", session, "invalid statement", nil)
-	assert.EqualError(t, err, "[BUG] unrecognized p [TRUNCATED]")
+	assert.EqualError(t, err, "syntax error at posi [TRUNCATED]")
 }
 
 func TestExecutorFlushStmt(t *testing.T) {
@@ -2940,6 +3031,293 @@ func exec(executor *Executor, session *SafeSession, sql string) (*sqltypes.Resul
 	return executor.Execute(context.Background(), nil, "TestExecute", session, sql, nil)
 }
 
+func TestExecutorQuotas(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+	cfg, err := quota.ParseConfig([]byte(`{"rules": [
+		{"name": "tx", "immediate_caller": "redUser", "max_concurrent_transactions": 1},
+		{"name": "olap", "workload": "olap", "max_qps": 1}
+	]}`))
+	require.NoError(t, err)
+	executor.quotas = quota.NewLimiter(false)
+	executor.quotas.SetConfig(cfg)
+
+	ctxRedUser := callerid.NewContext(ctx, &vtrpcpb.CallerID{}, &querypb.VTGateCallerID{Username: "redUser"})
+	ctxBlueUser := callerid.NewContext(ctx, &vtrpcpb.CallerID{}, &querypb.VTGateCallerID{Username: "blueUser"})
+	session1 := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "s1"})
+	session2 := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "s2"})
+
+	_, err = executor.Execute(ctxRedUser, nil, "TestExecute", session1, "begin", nil)
+	require.NoError(t, err)
+	_, err = executor.Execute(ctxRedUser, nil, "TestExecute", session1, "select id from main1", nil)
+	require.NoError(t, err)
+
+	// the same user cannot open a second transaction, but other users can
+	_, err = executor.Execute(ctxRedUser, nil, "TestExecute", session2, "begin", nil)
+	require.EqualError(t, err, "quota exceeded: concurrent transactions limit of rule tx, retry later")
+	assert.False(t, session2.InTransaction())
+	session3 := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "s3"})
+	_, err = executor.Execute(ctxBlueUser, nil, "TestExecute", session3, "begin", nil)
+	require.NoError(t, err)
+
+	// once the first transaction is over, the transaction slot is released
+	_, err = executor.Execute(ctxRedUser, nil, "TestExecute", session1, "commit", nil)
+	require.NoError(t, err)
+	_, err = executor.Execute(ctxRedUser, nil, "TestExecute", session2, "begin", nil)
+	require.NoError(t, err)
+	// closing the session also releases it
+	require.NoError(t, executor.CloseSession(ctxRedUser, session2))
+	_, err = executor.Execute(ctxRedUser, nil, "TestExecute", session1, "begin", nil)
+	require.NoError(t, err)
+
+	// the workload name is taken from the comment directive
+	session4 := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", Autocommit: true})
+	_, err = executor.Execute(ctxBlueUser, nil, "TestExecute", session4, "select /*vt+ WORKLOAD_NAME=olap */ id from main1", nil)
+	require.NoError(t, err)
+	_, err = executor.Execute(ctxBlueUser, nil, "TestExecute", session4, "select /*vt+ WORKLOAD_NAME=olap */ id from main1", nil)
+	require.EqualError(t, err, "quota exceeded: qps limit of rule olap, retry later")
+	// the workload name stays in the session options
+	_, err = executor.Execute(ctxBlueUser, nil, "TestExecute", session4, "select id from main1", nil)
+	require.EqualError(t, err, "quota exceeded: qps limit of rule olap, retry later")
+	session5 := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", Autocommit: true})
+	_, err = executor.Execute(ctxBlueUser, nil, "TestExecute", session5, "select id from main1", nil)
+	require.NoError(t, err)
+}
+
+func TestExecutorQueryRewriteRules(t *testing.T) {
+	executor, _, _, sbclookup, ctx := createExecutorEnv(t)
+	cfg, err := queryrewrite.ParseConfig([]byte(`{"rules": [
+		{"name": "bad", "user": "badUser", "action": "deny"},
+		{"name": "main1", "tables": ["main1"], "action": "rewrite", "limit": 10, "index_hints": [{"table": "main1", "type": "use", "indexes": ["idx"]}]},
+		{"name": "replica", "fingerprint": "select id from main1 where id = ?", "action": "rewrite", "tablet_type": "replica"}
+	]}`))
+	require.NoError(t, err)
+	executor.rewriteRules = queryrewrite.NewEngine()
+	executor.rewriteRules.SetConfig(cfg)
+
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", Autocommit: true})
+	_, err = executor.Execute(ctx, nil, "TestExecute", session, "select id from main1", nil)
+	require.NoError(t, err)
+	utils.MustMatch(t, []*querypb.BoundQuery{{
+		Sql:           "select id from main1 use index (idx) limit 10",
+		BindVariables: map[string]*querypb.BindVariable{},
+	}}, sbclookup.Queries)
+
+	// the query is sent to a replica instead of the primary
+	sbclookup.Queries = nil
+	_, err = executor.Execute(ctx, nil, "TestExecute", session, "select id from main1 where id = 1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, sbclookup.Queries)
+
+	// but not in a transaction
+	_, err = executor.Execute(ctx, nil, "TestExecute", session, "begin", nil)
+	require.NoError(t, err)
+	_, err = executor.Execute(ctx, nil, "TestExecute", session, "select id from main1 where id = 1", nil)
+	require.NoError(t, err)
+	assert.Len(t, sbclookup.Queries, 1)
+
+	ctxBadUser := callerid.NewContext(ctx, &vtrpcpb.CallerID{}, &querypb.VTGateCallerID{Username: "badUser"})
+	_, err = executor.Execute(ctxBadUser, nil, "TestExecute", NewSafeSession(&vtgatepb.Session{TargetString: "@primary", Autocommit: true}), "select id from main1", nil)
+	require.EqualError(t, err, "disallowed due to rule: bad")
+}
+
+func TestExecutorQueryDigests(t *testing.T) {
+	executor, _, _, sbclookup, ctx := createExecutorEnv(t)
+
+	// the digests are disabled by default
+	qr, err := executor.Execute(ctx, nil, "TestExecute", NewSafeSession(&vtgatepb.Session{}), "show vitess_query_digests", nil)
+	require.NoError(t, err)
+	assert.Len(t, qr.Fields, 14)
+	assert.Empty(t, qr.Rows)
+
+	executor.digests = querydigest.NewCollector(10)
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary"})
+	for i := 0; i < 2; i++ {
+		_, err = executor.Execute(ctx, nil, "TestExecute", session, "select id from main1", nil)
+		require.NoError(t, err)
+	}
+	_, err = executorStream(ctx, executor, "select id from main1")
+	require.NoError(t, err)
+	sbclookup.MustFailCodes[vtrpcpb.Code_INVALID_ARGUMENT] = 1
+	_, err = executor.Execute(ctx, nil, "TestExecute", session, "update main1 set id = 2", nil)
+	require.Error(t, err)
+
+	qr, err = executor.Execute(ctx, nil, "TestExecute", session, "show vitess_query_digests like 'select%'", nil)
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	row := qr.Rows[0]
+	assert.Equal(t, `[VARCHAR("select id from main1") VARCHAR("Unsharded") UINT64(3) UINT64(0) UINT64(0) UINT64(3) UINT64(3)]`, fmt.Sprintf("%v", row[:7]))
+
+	// the shards queried several times by a join are only counted once
+	_, err = executor.Execute(ctx, nil, "TestExecute", session, "select u.id from user u join music m on u.name = m.name where u.id = 1", nil)
+	require.NoError(t, err)
+
+	digests := executor.digests.Digests()
+	require.Len(t, digests, 4)
+	byQuery := make(map[string]*querydigest.Digest)
+	for _, d := range digests {
+		byQuery[d.Query] = d
+	}
+	assert.EqualValues(t, 1, byQuery["update main1 set id = 2"].Count)
+	assert.EqualValues(t, 1, byQuery["update main1 set id = 2"].Errors)
+	join := byQuery["select u.id from `user` as u join music as m on u.`name` = m.`name` where u.id = 1"]
+	require.NotNil(t, join)
+	assert.EqualValues(t, 8, join.Shards)
+}
+
+func TestExecutorReadAfterWrite(t *testing.T) {
+	ctx := utils.LeakCheckContext(t)
+	executor, primary, replica := createExecutorEnvWithPrimaryReplicaConn(t, ctx, 0)
+	const uuid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	gtidResult := func(gtid string) *sqltypes.Result {
+		return sqltypes.MakeTestResult(sqltypes.MakeTestFields("@@global.gtid_executed", "varchar"), gtid)
+	}
+
+	session := &vtgatepb.Session{TargetString: KsTestUnsharded, Autocommit: true}
+	_, err := executorExecSession(ctx, executor, "insert into t1(id) values (1)", nil, session)
+	require.NoError(t, err)
+	assert.Nil(t, session.ReadAfterWrite)
+
+	_, err = executorExecSession(ctx, executor, "set session_track_gtids = own_gtid", nil, session)
+	require.NoError(t, err)
+
+	// the shard is marked as written when MySQL does not report the GTID of the write
+	primary.Queries = nil
+	primary.SetResults([]*sqltypes.Result{{RowsAffected: 1}})
+	_, err = executorExecSession(ctx, executor, "insert into t1(id) values (2)", nil, session)
+	require.NoError(t, err)
+	require.Len(t, primary.Queries, 1)
+	assert.Equal(t, "TestUnsharded/0|", session.ReadAfterWrite.ReadAfterWriteGtid)
+
+	// the first replica read captures the GTIDs executed by the primary, and waits for them
+	session.TargetString = KsTestUnsharded + "@replica"
+	primary.Queries = nil
+	primary.SetResults([]*sqltypes.Result{gtidResult(uuid + ":1-5")})
+	replica.SetResults([]*sqltypes.Result{sqltypes.MakeTestResult(sqltypes.MakeTestFields("wait", "int64"), "0")})
+	_, err = executorExecSession(ctx, executor, "select id from t1", nil, session)
+	require.NoError(t, err)
+	require.Len(t, primary.Queries, 1)
+	assert.Equal(t, executedGTIDsQuery, primary.Queries[0].Sql)
+	require.Len(t, replica.Queries, 2)
+	assert.Equal(t, waitForGTIDQuery, replica.Queries[0].Sql)
+	assert.Equal(t, uuid+":1-5", string(replica.Queries[0].BindVariables["gtid"].Value))
+	assert.Equal(t, "select id from t1", replica.Queries[1].Sql)
+	assert.Equal(t, "TestUnsharded/0|"+uuid+":1-5", session.ReadAfterWrite.ReadAfterWriteGtid)
+
+	// the GTIDs reported by MySQL are added up
+	session.TargetString = KsTestUnsharded
+	primary.Queries = nil
+	primary.SetResults([]*sqltypes.Result{{RowsAffected: 1, SessionStateChanges: uuid + ":6"}})
+	_, err = executorExecSession(ctx, executor, "insert into t1(id) values (3)", nil, session)
+	require.NoError(t, err)
+	require.Len(t, primary.Queries, 1)
+	assert.Equal(t, "TestUnsharded/0|"+uuid+":1-6", session.ReadAfterWrite.ReadAfterWriteGtid)
+
+	// the commit of a transaction marks the shard as written
+	_, err = executorExecSession(ctx, executor, "begin", nil, session)
+	require.NoError(t, err)
+	primary.Queries = nil
+	primary.SetResults([]*sqltypes.Result{{RowsAffected: 1}})
+	_, err = executorExecSession(ctx, executor, "insert into t1(id) values (4)", nil, session)
+	require.NoError(t, err)
+	assert.Equal(t, "TestUnsharded/0|"+uuid+":1-6", session.ReadAfterWrite.ReadAfterWriteGtid)
+	_, err = executorExecSession(ctx, executor, "commit", nil, session)
+	require.NoError(t, err)
+	assert.Equal(t, "TestUnsharded/0|", session.ReadAfterWrite.ReadAfterWriteGtid)
+	for _, query := range primary.Queries {
+		assert.NotEqual(t, executedGTIDsQuery, query.Sql)
+	}
+
+	// the replica reads wait for the captured GTIDs
+	session.TargetString = KsTestUnsharded + "@replica"
+	replica.Queries = nil
+	primary.SetResults([]*sqltypes.Result{gtidResult(uuid + ":1-8")})
+	replica.SetResults([]*sqltypes.Result{sqltypes.MakeTestResult(sqltypes.MakeTestFields("wait", "int64"), "0")})
+	_, err = executorExecSession(ctx, executor, "select id from t1", nil, session)
+	require.NoError(t, err)
+	require.Len(t, replica.Queries, 2)
+	assert.Equal(t, waitForGTIDQuery, replica.Queries[0].Sql)
+	assert.Equal(t, uuid+":1-8", string(replica.Queries[0].BindVariables["gtid"].Value))
+	assert.Equal(t, "select id from t1", replica.Queries[1].Sql)
+
+	// and do not capture them again
+	primary.Queries = nil
+	replica.Queries = nil
+	_, err = executorExecSession(ctx, executor, "select id from t1", nil, session)
+	require.NoError(t, err)
+	assert.Empty(t, primary.Queries)
+	require.Len(t, replica.Queries, 1)
+}
+
+func TestExecutorResultCache(t *testing.T) {
+	executor, _, _, sbclookup, ctx := createExecutorEnv(t)
+	streams := make(chan func([]*binlogdatapb.VEvent) error, 10)
+	executor.resultCache = resultcache.NewCache(1024*1024, func(ctx context.Context, keyspace string, tables []string, send func([]*binlogdatapb.VEvent) error) error {
+		streams <- send
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	defer executor.resultCache.Close()
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", Autocommit: true})
+	run := func(sql string) {
+		t.Helper()
+		_, err := executor.Execute(ctx, nil, "TestExecute", session, sql, nil)
+		require.NoError(t, err)
+	}
+
+	// the first query starts the invalidation stream, its result cannot be cached before the stream is running
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	send := <-streams
+	require.NoError(t, send([]*binlogdatapb.VEvent{{Type: binlogdatapb.VEventType_HEARTBEAT}}))
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 2)
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 2)
+
+	// the queries without the directive, or with other bind variables, are not answered from the cache
+	run("select id from main1")
+	require.Len(t, sbclookup.Queries, 3)
+	run("select /*vt+ RESULT_CACHE */ id from main1 where id = 1")
+	run("select /*vt+ RESULT_CACHE */ id from main1 where id = 1")
+	require.Len(t, sbclookup.Queries, 4)
+	run("select /*vt+ RESULT_CACHE */ id from main1 where id = 2")
+	require.Len(t, sbclookup.Queries, 5)
+
+	// the changes of the table invalidate the results
+	require.NoError(t, send([]*binlogdatapb.VEvent{{Type: binlogdatapb.VEventType_ROW, RowEvent: &binlogdatapb.RowEvent{TableName: "TestUnsharded.main1"}}}))
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 6)
+
+	// the queries of a transaction see its changes, they are not answered from the cache
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 6)
+	run("begin")
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 7)
+	run("rollback")
+
+	// the callers do not share their results
+	_, err := executor.Execute(callerid.NewContext(ctx, &vtrpcpb.CallerID{}, &querypb.VTGateCallerID{Username: "other"}), nil, "TestExecute", session, "select /*vt+ RESULT_CACHE */ id from main1", nil)
+	require.NoError(t, err)
+	require.Len(t, sbclookup.Queries, 8)
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 8)
+
+	// the writes of the session invalidate the results once committed, without waiting for the stream
+	run("insert into main1(id) values (1)")
+	require.Len(t, sbclookup.Queries, 9)
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 10)
+	run("begin")
+	run("update main1 set id = 2")
+	assert.Equal(t, []string{"TestUnsharded.main1"}, session.ResultCacheInvalidations)
+	run("commit")
+	assert.Empty(t, session.ResultCacheInvalidations)
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 12)
+	run("select /*vt+ RESULT_CACHE */ id from main1")
+	require.Len(t, sbclookup.Queries, 12)
+}
+
 func makeComments(text string) sqlparser.MarginComments {
 	return sqlparser.MarginComments{Trailing: text}
 }
@@ -0,0 +1,260 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+// planSnapshotEntry is a cached plan saved to disk, to warm up the plan cache after a restart.
+type planSnapshotEntry struct {
+	// Query is the normalized query of the plan
+	Query string `json:"query"`
+	// Target is the target of the session the plan was built for
+	Target string `json:"target,omitempty"`
+	// ArgumentTypes are the types of the bind variables of the query, by name
+	ArgumentTypes map[string]string `json:"argument_types,omitempty"`
+	ExecCount     uint64            `json:"exec_count,omitempty"`
+}
+
+// planSnapshot returns the most executed plans of the plan cache, at most size of them.
+func (e *Executor) planSnapshot(size int) []planSnapshotEntry {
+	var entries []planSnapshotEntry
+	e.ForEachPlan(func(plan *engine.Plan) bool {
+		entry := planSnapshotEntry{
+			Query:     plan.Original,
+			Target:    plan.Target,
+			ExecCount: atomic.LoadUint64(&plan.ExecCount),
+		}
+		if len(plan.Arguments) > 0 {
+			entry.ArgumentTypes = make(map[string]string, len(plan.Arguments))
+			for _, arg := range plan.Arguments {
+				entry.ArgumentTypes[arg.Name] = arg.Type.String()
+			}
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ExecCount > entries[j].ExecCount
+	})
+	if len(entries) > size {
+		entries = entries[:size]
+	}
+	return entries
+}
+
+// savePlanSnapshot saves the most executed plans of the plan cache to the file.
+// The file is replaced atomically, so a crash cannot leave a truncated snapshot behind.
+func (e *Executor) savePlanSnapshot(path string, size int) error {
+	data, err := json.Marshal(e.planSnapshot(size))
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadPlanSnapshot reads the plans saved by savePlanSnapshot.
+func loadPlanSnapshot(path string) ([]planSnapshotEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []planSnapshotEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// warmPlanCache plans the queries of the snapshot, and caches their plans. It returns the number
+// of queries that could be planned. The queries that cannot be planned anymore, because the
+// schema changed for example, are skipped.
+func (e *Executor) warmPlanCache(ctx context.Context, entries []planSnapshotEntry, concurrency int) int {
+	var planned atomic.Int64
+	var wg sync.WaitGroup
+	work := make(chan planSnapshotEntry)
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range work {
+				if err := e.planQuery(ctx, entry); err != nil {
+					log.V(2).Infof("Unable to warm up the plan of %q: %v", entry.Query, err)
+					continue
+				}
+				planned.Add(1)
+			}
+		}()
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- entry
+	}
+	close(work)
+	wg.Wait()
+	return int(planned.Load())
+}
+
+// planQuery plans the query of the entry for a session with its target, as if it was executed, which caches its plan.
+// The margin comments are not split from the query: the cached queries do not have any, and the type of their
+// last bind variable would look like one.
+func (e *Executor) planQuery(ctx context.Context, entry planSnapshotEntry) error {
+	safeSession := NewSafeSession(&vtgatepb.Session{TargetString: entry.Target, Autocommit: true})
+	logStats := logstats.NewLogStats(ctx, "PlanCacheWarmup", entry.Query, "", nil)
+	var comments sqlparser.MarginComments
+	vcursor, err := newVCursorImpl(safeSession, comments, e, logStats, e.vm, e.VSchema(), e.resolver.resolver, e.serv, e.warnShardedOnly, e.pv)
+	if err != nil {
+		return err
+	}
+	stmt, reservedVars, err := parseAndValidateQuery(entry.Query)
+	if err != nil {
+		return err
+	}
+	if err := restoreArgumentTypes(stmt, entry.ArgumentTypes); err != nil {
+		return err
+	}
+	_, err = e.getPlan(ctx, vcursor, entry.Query, stmt, comments, make(map[string]*querypb.BindVariable), reservedVars, e.normalize, logStats)
+	return err
+}
+
+// typedArguments returns the bind variables of the normalized statement which have a type.
+func typedArguments(stmt sqlparser.Statement) []*sqlparser.Argument {
+	var arguments []*sqlparser.Argument
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if arg, ok := node.(*sqlparser.Argument); ok && arg.Type >= 0 {
+			arguments = append(arguments, sqlparser.NewTypedArgument(arg.Name, arg.Type))
+		}
+		return true, nil
+	}, stmt)
+	return arguments
+}
+
+// restoreArgumentTypes sets the types of the bind variables of the parsed normalized query, since the parser
+// does not keep them, so the query is printed exactly as it was when its plan was cached.
+func restoreArgumentTypes(stmt sqlparser.Statement, types map[string]string) error {
+	if len(types) == 0 {
+		return nil
+	}
+	return sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if arg, ok := node.(*sqlparser.Argument); ok {
+			if name, ok := types[arg.Name]; ok {
+				t, ok := querypb.Type_value[name]
+				if !ok {
+					return false, fmt.Errorf("unknown type %s of bind variable %s", name, arg.Name)
+				}
+				arg.Type = sqltypes.Type(t)
+			}
+		}
+		return true, nil
+	}, stmt)
+}
+
+// waitForVSchema waits until the executor has a vschema to plan the queries with.
+func (e *Executor) waitForVSchema(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for e.VSchema() == nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// startPlanCacheSnapshots warms up the plan cache from the snapshot file, and keeps saving
+// the most executed plans to it. The vtgate is not healthy until the warm-up is over.
+func (vtg *VTGate) startPlanCacheSnapshots(ctx context.Context) {
+	if planCacheSnapshotFile == "" {
+		return
+	}
+	path, size, interval := planCacheSnapshotFile, planCacheSnapshotSize, planCacheSnapshotInterval
+
+	entries, err := loadPlanSnapshot(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Warningf("Unable to load the plan cache snapshot %s, the plan cache will not be warmed up: %v", path, err)
+	case len(entries) > 0:
+		vtg.planCacheWarming.Store(true)
+		go func() {
+			defer vtg.planCacheWarming.Store(false)
+			ctx, cancel := context.WithTimeout(ctx, planCacheWarmupTimeout)
+			defer cancel()
+			if err := vtg.executor.waitForVSchema(ctx); err != nil {
+				log.Warningf("The plan cache was not warmed up, no vschema was loaded: %v", err)
+				return
+			}
+			start := time.Now()
+			planned := vtg.executor.warmPlanCache(ctx, entries, planCacheWarmupConcurrency)
+			log.Infof("Warmed up the plan cache with %d of the %d queries of %s in %v", planned, len(entries), path, time.Since(start))
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// an unfinished warm-up would replace the snapshot with a part of it
+				if vtg.planCacheWarming.Load() {
+					continue
+				}
+				if err := vtg.executor.savePlanSnapshot(path, size); err != nil {
+					log.Warningf("Unable to save the plan cache snapshot %s: %v", path, err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/vtgate/engine"
+
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+func TestPlanCacheSnapshot(t *testing.T) {
+	executor, _, _, _, ctx := createExecutorEnv(t)
+	executor.normalize = true
+
+	primary := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", Autocommit: true})
+	keyspace := NewSafeSession(&vtgatepb.Session{TargetString: "TestExecutor", Autocommit: true})
+	for i := 0; i < 3; i++ {
+		_, err := executor.Execute(ctx, nil, "TestExecute", primary, "select id from user where id = 1", nil)
+		require.NoError(t, err)
+	}
+	_, err := executor.Execute(ctx, nil, "TestExecute", primary, "select id from main1", nil)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = executor.Execute(ctx, nil, "TestExecute", keyspace, "select id from user where id = 5 and name = 'x'", nil)
+		require.NoError(t, err)
+	}
+	// wait for the cache to settle
+	time.Sleep(100 * time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "plans.json")
+	require.NoError(t, executor.savePlanSnapshot(path, 2))
+	entries, err := loadPlanSnapshot(path)
+	require.NoError(t, err)
+	// only the most executed plans are saved
+	assert.Equal(t, []planSnapshotEntry{
+		{Query: "select id from `user` where id = :id /* INT64 */", Target: "@primary", ArgumentTypes: map[string]string{"id": "INT64"}, ExecCount: 3},
+		{Query: "select id from `user` where id = :id /* INT64 */ and `name` = :name /* VARCHAR */", Target: "TestExecutor", ArgumentTypes: map[string]string{"id": "INT64", "name": "VARCHAR"}, ExecCount: 2},
+	}, entries)
+
+	// a corrupted snapshot is not loaded
+	require.NoError(t, os.WriteFile(path, []byte("[{"), 0o644))
+	_, err = loadPlanSnapshot(path)
+	require.Error(t, err)
+}
+
+func TestPlanCacheWarmup(t *testing.T) {
+	restarted, _, _, _, ctx := createExecutorEnv(t)
+	restarted.normalize = true
+
+	entries := []planSnapshotEntry{
+		{Query: "select id from `user` where id = :id /* INT64 */", Target: "@primary", ArgumentTypes: map[string]string{"id": "INT64"}, ExecCount: 3},
+		{Query: "select id from `user` where id = :id /* INT64 */ and `name` = :name /* VARCHAR */", Target: "TestExecutor", ArgumentTypes: map[string]string{"id": "INT64", "name": "VARCHAR"}, ExecCount: 2},
+	}
+	// a restarted vtgate builds the same plans again
+	assert.Equal(t, 2, restarted.warmPlanCache(ctx, entries, 2))
+	time.Sleep(100 * time.Millisecond)
+	plans := make(map[string]string)
+	restarted.ForEachPlan(func(plan *engine.Plan) bool {
+		plans[plan.Original] = plan.Target
+		return true
+	})
+	assert.Equal(t, map[string]string{
+		"select id from `user` where id = :id /* INT64 */":                                  "@primary",
+		"select id from `user` where id = :id /* INT64 */ and `name` = :name /* VARCHAR */": "TestExecutor",
+	}, plans)
+
+	// the warmed up plans are used by the queries
+	_, err := restarted.Execute(ctx, nil, "TestExecute", NewSafeSession(&vtgatepb.Session{TargetString: "TestExecutor", Autocommit: true}), "select id from user where id = 7 and name = 'y'", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, restarted.plans.Len())
+
+	// the queries that cannot be planned anymore are skipped
+	assert.Equal(t, 0, restarted.warmPlanCache(ctx, []planSnapshotEntry{{Query: "select id from unknown_table", Target: "@primary"}}, 1))
+	assert.Equal(t, 0, restarted.warmPlanCache(ctx, []planSnapshotEntry{{Query: "select id from `user` where id = :id /* INT128 */", Target: "@primary", ArgumentTypes: map[string]string{"id": "INT128"}}}, 1))
+}
+
+func TestPlanCacheWarmingIsNotHealthy(t *testing.T) {
+	vtg := &VTGate{}
+	require.NoError(t, vtg.IsHealthy())
+	vtg.planCacheWarming.Store(true)
+	require.EqualError(t, vtg.IsHealthy(), "the plan cache is warming up")
+}
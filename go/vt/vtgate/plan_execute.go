@@ -19,10 +19,12 @@ package vtgate
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/log"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
@@ -30,7 +32,11 @@ import (
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/logstats"
+	"vitess.io/vitess/go/vt/vtgate/queryrewrite"
+	"vitess.io/vitess/go/vt/vtgate/quota"
+	"vitess.io/vitess/go/vt/vtgate/resultcache"
 	"vitess.io/vitess/go/vt/vtgate/vtgateservice"
+	"vitess.io/vitess/go/vt/vthash"
 )
 
 type planExec func(ctx context.Context, plan *engine.Plan, vc *vcursorImpl, bindVars map[string]*querypb.BindVariable, startTime time.Time) error
@@ -67,12 +73,6 @@ func (e *Executor) newExecute(
 ) error {
 	// 1: Prepare before planning and execution
 
-	// Start an implicit transaction if necessary.
-	err := e.startTxIfNecessary(ctx, safeSession)
-	if err != nil {
-		return err
-	}
-
 	if bindVars == nil {
 		bindVars = make(map[string]*querypb.BindVariable)
 	}
@@ -80,11 +80,49 @@ func (e *Executor) newExecute(
 	query, comments := sqlparser.SplitMarginComments(sql)
 
 	// 2: Parse and Validate query
-	stmt, reservedVars, err := parseAndValidateQuery(query)
+	// A prepared statement is only parsed once, and bound to its plan.
+	prepared := preparedPlanFromContext(ctx, sql)
+	var stmt sqlparser.Statement
+	var reservedVars *sqlparser.ReservedVars
+	var err error
+	if prepared != nil {
+		stmt, reservedVars, err = prepared.statement(query)
+	} else {
+		stmt, reservedVars, err = parseAndValidateQuery(query)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Apply the query rewriting rules, which can deny the query or change it before it is planned.
+	rewrite, err := e.applyRewriteRules(ctx, safeSession, stmt, comments)
+	if err != nil {
+		return err
+	}
+	if rewrite != nil && rewrite.Rewritten {
+		query = sqlparser.String(stmt)
+		prepared = nil
+	}
+
+	// Check the quotas of the caller before doing any work for the query.
+	release, err := e.checkQuotas(ctx, safeSession, stmt)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Invalidate the cached results of the tables written by the session, once its transaction has ended.
+	defer e.invalidateResultCache(safeSession)
+
+	// Start an implicit transaction if necessary.
+	err = e.startTxIfNecessary(ctx, safeSession)
 	if err != nil {
 		return err
 	}
 
+	// Make the replica reads wait for the previous writes of the session, if it asks for it.
+	ctx = withReadAfterWrite(ctx, safeSession)
+
 	var lastVSchemaCreated time.Time
 	vs := e.VSchema()
 	lastVSchemaCreated = vs.GetCreated()
@@ -101,6 +139,9 @@ func (e *Executor) newExecute(
 		if err != nil {
 			return err
 		}
+		if rewrite != nil {
+			vcursor.applyQueryRewrite(rewrite, stmt)
+		}
 
 		// 3: Create a plan for the query
 		// If we are retrying, it is likely that the routing rules have changed and hence we need to
@@ -112,7 +153,11 @@ func (e *Executor) newExecute(
 		// will help and if it will result in hard-to-track edge cases.
 
 		var plan *engine.Plan
-		plan, err = e.getPlan(ctx, vcursor, query, stmt, comments, bindVars, reservedVars, e.normalize, logStats)
+		if prepared != nil && try == 0 {
+			plan, err = e.getPreparedPlan(ctx, vcursor, prepared, query, stmt, comments, bindVars, reservedVars, logStats)
+		} else {
+			plan, err = e.getPlan(ctx, vcursor, query, stmt, comments, bindVars, reservedVars, e.normalize, logStats)
+		}
 		execStart := e.logPlanningFinished(logStats, plan)
 
 		if err != nil {
@@ -216,6 +261,67 @@ func (e *Executor) handleTransactions(
 	return nil, nil
 }
 
+// checkQuotas checks that the caller is within its quotas to run the statement, and to start a transaction
+// if the statement starts one. The returned function has to be called once the statement is done.
+// Transactions are only tracked for the sessions that have an id, which are the MySQL protocol sessions.
+func (e *Executor) checkQuotas(ctx context.Context, safeSession *SafeSession, stmt sqlparser.Statement) (func(), error) {
+	if e.quotas == nil {
+		return func() {}, nil
+	}
+	caller := quota.Caller{
+		ImmediateCaller: callerid.ImmediateCallerIDFromContext(ctx).GetUsername(),
+		EffectiveCaller: callerid.EffectiveCallerIDFromContext(ctx).GetPrincipal(),
+		Workload:        sqlparser.GetWorkloadNameFromStatement(stmt),
+	}
+	if caller.Workload == "" {
+		caller.Workload = safeSession.GetOptions().GetWorkloadName()
+	}
+
+	sessionID := safeSession.GetSessionUUID()
+	startsTx := !safeSession.InTransaction() && (!safeSession.Autocommit || sqlparser.ASTToStatementType(stmt) == sqlparser.StmtBegin)
+	if sessionID != "" && startsTx {
+		if err := e.quotas.StartTransaction(sessionID, caller); err != nil {
+			return nil, err
+		}
+	}
+
+	releaseQuery, err := e.quotas.StartQuery(caller)
+	if err != nil {
+		if sessionID != "" && startsTx {
+			e.quotas.EndTransaction(sessionID)
+		}
+		return nil, err
+	}
+	return func() {
+		releaseQuery()
+		if sessionID != "" && !safeSession.InTransaction() {
+			e.quotas.EndTransaction(sessionID)
+		}
+	}, nil
+}
+
+// applyRewriteRules applies the query rewriting rules to the statement, which is changed in place.
+// It returns a nil result when no rule matched the query.
+func (e *Executor) applyRewriteRules(ctx context.Context, safeSession *SafeSession, stmt sqlparser.Statement, comments sqlparser.MarginComments) (*queryrewrite.Result, error) {
+	if e.rewriteRules == nil {
+		return nil, nil
+	}
+	var keyspace string
+	if vs := e.VSchema(); vs != nil {
+		var err error
+		keyspace, _, _, err = parseDestinationTarget(safeSession.TargetString, vs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e.rewriteRules.Apply(&queryrewrite.Query{
+		Statement: stmt,
+		Comments:  comments,
+		Keyspace:  keyspace,
+		User:      callerid.ImmediateCallerIDFromContext(ctx).GetUsername(),
+	})
+}
+
 func (e *Executor) startTxIfNecessary(ctx context.Context, safeSession *SafeSession) error {
 	if !safeSession.Autocommit && !safeSession.InTransaction() {
 		if err := e.txConn.Begin(ctx, safeSession, nil); err != nil {
@@ -277,6 +383,25 @@ func (e *Executor) executePlan(
 	execStart time.Time,
 ) (*sqltypes.Result, error) {
 
+	// Answer from the result cache if the query asks for it.
+	var cacheResult func(*sqltypes.Result)
+	if ttl := e.resultCacheTTL(safeSession, plan, vcursor); ttl > 0 {
+		key := e.hashResult(ctx, vcursor, plan, bindVars, safeSession)
+		if qr, ok := e.resultCache.Get(key); ok {
+			e.setLogStats(logStats, plan, vcursor, execStart, nil, qr)
+			return qr, nil
+		}
+		// the state of the tables has to be taken before running the query, to not miss the changes made meanwhile
+		generations := e.resultCache.Snapshot(plan.TablesUsed)
+		cacheResult = func(qr *sqltypes.Result) {
+			e.resultCache.Set(key, qr, generations, ttl)
+		}
+	}
+
+	if vcursor.hedgedReadsSet {
+		ctx = withHedgedReads(ctx, vcursor.hedgedReads)
+	}
+
 	// 4: Execute!
 	qr, err := vcursor.ExecutePrimitive(ctx, plan.Instructions, bindVars, true)
 
@@ -287,9 +412,108 @@ func (e *Executor) executePlan(
 	if err != nil {
 		return nil, e.rollbackExecIfNeeded(ctx, safeSession, bindVars, logStats, err)
 	}
+	if cacheResult != nil {
+		cacheResult(qr)
+	}
+	if e.resultCache != nil && isWrite(plan.Type) {
+		safeSession.AddResultCacheInvalidations(plan.TablesUsed)
+	}
 	return qr, nil
 }
 
+func isWrite(stmtType sqlparser.StatementType) bool {
+	switch stmtType {
+	case sqlparser.StmtInsert, sqlparser.StmtReplace, sqlparser.StmtUpdate, sqlparser.StmtDelete:
+		return true
+	}
+	return false
+}
+
+// invalidateResultCache invalidates the cached results of the tables written by the session,
+// once the transaction which wrote them has been committed or rolled back. They are invalidated
+// by the VStream as well, but only once the changes arrive: invalidating them right away makes
+// sure the next queries of the session see its writes.
+func (e *Executor) invalidateResultCache(safeSession *SafeSession) {
+	if e.resultCache == nil {
+		return
+	}
+	for _, table := range safeSession.TakeResultCacheInvalidations() {
+		e.resultCache.Invalidate(table)
+	}
+}
+
+// resultCacheTTL returns how long the result of the plan can be cached, or 0 if it cannot be cached.
+// Only the SELECTs outside of transactions and reserved connections are cached, since they have to
+// see the changes of their session. For the same reason, the sessions reading their writes on the
+// replicas do not use the cache.
+func (e *Executor) resultCacheTTL(safeSession *SafeSession, plan *engine.Plan, vcursor *vcursorImpl) time.Duration {
+	if e.resultCache == nil || plan.Type != sqlparser.StmtSelect {
+		return 0
+	}
+	if safeSession.InTransaction() || safeSession.InReservedConn() {
+		return 0
+	}
+	if gtids, _ := safeSession.readAfterWrite(); !gtids.empty() {
+		return 0
+	}
+	return e.resultCache.TTL(vcursor.resultCache, vcursor.resultCacheTTL, plan.TablesUsed)
+}
+
+// hashResult returns the key of the result of the plan in the result cache. Besides the query and its target,
+// the key depends on the bind variables, including the ones added for the functions and variables of the query,
+// on the system variables of the session, and on the immediate and effective callers.
+func (e *Executor) hashResult(ctx context.Context, vcursor *vcursorImpl, plan *engine.Plan, bindVars map[string]*querypb.BindVariable, safeSession *SafeSession) resultcache.Key {
+	hasher := vthash.New256()
+	vcursor.keyForPlan(ctx, plan.Original, hasher)
+
+	names := make([]string, 0, len(bindVars))
+	for name := range bindVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bv, _ := bindVars[name].MarshalVT()
+		_, _ = hasher.WriteString("+BindVar:")
+		_, _ = hasher.WriteString(name)
+		_, _ = hasher.WriteString("=")
+		_, _ = hasher.Write(bv)
+	}
+
+	names = names[:0]
+	for name := range safeSession.SystemVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = hasher.WriteString("+SysVar:")
+		_, _ = hasher.WriteString(name)
+		_, _ = hasher.WriteString("=")
+		_, _ = hasher.WriteString(safeSession.SystemVariables[name])
+	}
+	_, _ = hasher.WriteString("+Fields:")
+	_, _ = hasher.WriteString(safeSession.GetOptions().GetIncludedFields().String())
+
+	// The callers can be allowed to read different tables, so they do not share their results.
+	immediateCaller := callerid.ImmediateCallerIDFromContext(ctx)
+	_, _ = hasher.WriteString("+ImmediateCaller:")
+	_, _ = hasher.WriteString(immediateCaller.GetUsername())
+	effectiveCaller := callerid.EffectiveCallerIDFromContext(ctx)
+	_, _ = hasher.WriteString("+EffectiveCaller:")
+	_, _ = hasher.WriteString(effectiveCaller.GetPrincipal())
+	_, _ = hasher.WriteString("/")
+	_, _ = hasher.WriteString(effectiveCaller.GetComponent())
+	_, _ = hasher.WriteString("/")
+	_, _ = hasher.WriteString(effectiveCaller.GetSubcomponent())
+	for _, group := range effectiveCaller.GetGroups() {
+		_, _ = hasher.WriteString("+Group:")
+		_, _ = hasher.WriteString(group)
+	}
+
+	var key resultcache.Key
+	hasher.Sum(key[:0])
+	return key
+}
+
 // rollbackExecIfNeeded rollbacks the partial execution if earlier it was detected that it needs partial query execution to be rolled back.
 func (e *Executor) rollbackExecIfNeeded(ctx context.Context, safeSession *SafeSession, bindVars map[string]*querypb.BindVariable, logStats *logstats.LogStats, err error) error {
 	if safeSession.InTransaction() && safeSession.IsRollbackSet() {
@@ -344,6 +568,7 @@ func (e *Executor) setLogStats(logStats *logstats.LogStats, plan *engine.Plan, v
 	logStats.TabletType = vcursor.TabletType().String()
 	errCount := e.logExecutionEnd(logStats, execStart, plan, err, qr)
 	plan.AddStats(1, time.Since(logStats.StartTime), logStats.ShardQueries, logStats.RowsAffected, logStats.RowsReturned, errCount)
+	e.recordQueryDigest(plan, vcursor, logStats, logStats.RowsAffected, logStats.RowsReturned, err)
 }
 
 func (e *Executor) logExecutionEnd(logStats *logstats.LogStats, execStart time.Time, plan *engine.Plan, err error, qr *sqltypes.Result) uint64 {
@@ -24,25 +24,28 @@ var _ logicalPlan = (*fkCascade)(nil)
 
 // fkCascade is the logicalPlan for engine.FkCascade.
 type fkCascade struct {
-	parent    logicalPlan
-	selection logicalPlan
-	children  []*engine.FkChild
+	parent         logicalPlan
+	selection      logicalPlan
+	children       []*engine.FkChild
+	nonLiteralInfo []engine.NonLiteralUpdateInfo
 }
 
 // newFkCascade builds a new fkCascade.
-func newFkCascade(parent, selection logicalPlan, children []*engine.FkChild) *fkCascade {
+func newFkCascade(parent, selection logicalPlan, children []*engine.FkChild, nonLiteralInfo []engine.NonLiteralUpdateInfo) *fkCascade {
 	return &fkCascade{
-		parent:    parent,
-		selection: selection,
-		children:  children,
+		parent:         parent,
+		selection:      selection,
+		children:       children,
+		nonLiteralInfo: nonLiteralInfo,
 	}
 }
 
 // Primitive implements the logicalPlan interface
 func (fkc *fkCascade) Primitive() engine.Primitive {
 	return &engine.FkCascade{
-		Parent:    fkc.parent.Primitive(),
-		Selection: fkc.selection.Primitive(),
-		Children:  fkc.children,
+		Parent:         fkc.parent.Primitive(),
+		Selection:      fkc.selection.Primitive(),
+		Children:       fkc.children,
+		NonLiteralInfo: fkc.nonLiteralInfo,
 	}
 }
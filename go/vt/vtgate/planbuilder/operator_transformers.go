@@ -66,8 +66,18 @@ func transformToLogicalPlan(ctx *plancontext.PlanningContext, op ops.Operator) (
 		return transformFkCascade(ctx, op)
 	case *operators.FkVerify:
 		return transformFkVerify(ctx, op)
+	case *operators.DMLWithInput:
+		return transformDMLWithInput(ctx, op)
+	case *operators.Upsert:
+		return transformUpsert(ctx, op)
 	case *operators.InsertSelection:
 		return transformInsertionSelection(ctx, op)
+	case *operators.RecurseCTE:
+		return transformRecurseCTE(ctx, op)
+	case *operators.CTERow:
+		return transformCTERow(op), nil
+	case *operators.Window:
+		return transformWindow(ctx, op)
 	}
 
 	return nil, vterrors.VT13001(fmt.Sprintf("unknown type encountered: %T (transformToLogicalPlan)", op))
@@ -145,7 +155,55 @@ func transformFkCascade(ctx *plancontext.PlanningContext, fkc *operators.FkCasca
 		})
 	}
 
-	return newFkCascade(parentLP, selLP, children), nil
+	return newFkCascade(parentLP, selLP, children, fkc.NonLiteralInfo), nil
+}
+
+// transformDMLWithInput transforms a DMLWithInput operator into a logical plan.
+func transformDMLWithInput(ctx *plancontext.PlanningContext, op *operators.DMLWithInput) (logicalPlan, error) {
+	// The input and the DMLs were planned as separate statements, so we use their semantic tables.
+	ctx.SemTable = op.SourceSemTable
+	input, err := transformToLogicalPlan(ctx, op.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var dmls []logicalPlan
+	for idx, dmlOp := range op.DML {
+		ctx.SemTable = op.DMLSemTables[idx]
+		dml, err := transformToLogicalPlan(ctx, dmlOp)
+		if err != nil {
+			return nil, err
+		}
+		dmls = append(dmls, dml)
+	}
+
+	return &dmlWithInput{
+		input:      input,
+		dmls:       dmls,
+		bvName:     op.BVName,
+		outputCols: op.Offsets,
+	}, nil
+}
+
+// transformUpsert transforms an Upsert operator into a logical plan.
+func transformUpsert(ctx *plancontext.PlanningContext, op *operators.Upsert) (logicalPlan, error) {
+	u := &upsert{reinsert: op.Reinsert}
+	for _, source := range op.Sources {
+		// The inserts and the updates were planned as separate statements, so we use their semantic tables.
+		ctx.SemTable = source.InsertSemTable
+		insLP, err := transformToLogicalPlan(ctx, source.Insert)
+		if err != nil {
+			return nil, err
+		}
+		ctx.SemTable = source.UpdateSemTable
+		updLP, err := transformToLogicalPlan(ctx, source.Update)
+		if err != nil {
+			return nil, err
+		}
+		u.insert = append(u.insert, insLP)
+		u.update = append(u.update, updLP)
+	}
+	return u, nil
 }
 
 func transformSubQuery(ctx *plancontext.PlanningContext, op *operators.SubQuery) (logicalPlan, error) {
@@ -764,6 +822,37 @@ func transformUnionPlan(ctx *plancontext.PlanningContext, op *operators.Union) (
 
 }
 
+func transformRecurseCTE(ctx *plancontext.PlanningContext, op *operators.RecurseCTE) (logicalPlan, error) {
+	seed, err := transformToLogicalPlan(ctx, op.Seed)
+	if err != nil {
+		return nil, err
+	}
+	term, err := transformToLogicalPlan(ctx, op.Term)
+	if err != nil {
+		return nil, err
+	}
+	return &recurseCTE{
+		seed:      seed,
+		term:      term,
+		vars:      op.Vars,
+		checkCols: op.CheckCols,
+	}, nil
+}
+
+func transformCTERow(op *operators.CTERow) logicalPlan {
+	var columnNames []string
+	for _, col := range op.Columns {
+		columnNames = append(columnNames, col.ColumnName())
+	}
+	return &projection{
+		source: &primitiveWrapper{prim: &engine.SingleRow{}},
+		primitive: &engine.Projection{
+			Cols:  columnNames,
+			Exprs: op.EExprs,
+		},
+	}
+}
+
 func transformLimit(ctx *plancontext.PlanningContext, op *operators.Limit) (logicalPlan, error) {
 	plan, err := transformToLogicalPlan(ctx, op.Source)
 	if err != nil {
@@ -791,3 +880,67 @@ func createLimit(input logicalPlan, limit *sqlparser.Limit) (logicalPlan, error)
 
 	return plan, nil
 }
+
+func transformWindow(ctx *plancontext.PlanningContext, op *operators.Window) (logicalPlan, error) {
+	src, err := transformToLogicalPlan(ctx, op.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	prim := &engine.Window{
+		PartitionBy: op.PartitionBy,
+		OrderBy:     op.OrderBy,
+		Cols:        op.Offsets,
+	}
+	for _, expr := range op.Funcs {
+		f, err := createWindowFunc(ctx, expr)
+		if err != nil {
+			return nil, err
+		}
+		prim.Funcs = append(prim.Funcs, f)
+	}
+	// the window functions are named after the first column returning them
+	for i, offset := range op.Offsets {
+		if offset >= 0 {
+			continue
+		}
+		if f := prim.Funcs[-offset-1]; f.Alias == "" {
+			f.Alias = op.Columns[i].ColumnName()
+		}
+	}
+
+	return &window{
+		logicalPlanCommon: newBuilderCommon(src),
+		eWindow:           prim,
+	}, nil
+}
+
+func createWindowFunc(ctx *plancontext.PlanningContext, expr sqlparser.Expr) (*engine.WindowFunc, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.ArgumentLessWindowExpr:
+		var code opcode.WindowOpcode
+		switch expr.Type {
+		case sqlparser.RowNumberExprType:
+			code = opcode.WindowRowNumber
+		case sqlparser.RankExprType:
+			code = opcode.WindowRank
+		case sqlparser.DenseRankExprType:
+			code = opcode.WindowDenseRank
+		case sqlparser.PercentRankExprType:
+			code = opcode.WindowPercentRank
+		case sqlparser.CumeDistExprType:
+			code = opcode.WindowCumeDist
+		}
+		return &engine.WindowFunc{Opcode: code}, nil
+	case *sqlparser.NtileExpr:
+		n, err := evalengine.Translate(expr.N, &evalengine.Config{
+			Collation:   ctx.SemTable.Collation,
+			ResolveType: ctx.SemTable.TypeForExpr,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &engine.WindowFunc{Opcode: opcode.WindowNtile, N: n}, nil
+	}
+	return nil, vterrors.VT13001(fmt.Sprintf("unexpected window function: %s", sqlparser.String(expr)))
+}
@@ -34,6 +34,14 @@ type (
 		stmt        sqlparser.Statement
 		tableNames  []string
 		dmlOperator ops.Operator
+
+		// ctes are the recursive common table expressions used by the query.
+		// They are added to the WITH clause of the final statement
+		ctes []*sqlparser.CommonTableExpr
+
+		// dmlFiltered is set when predicates are added on top of the DML operator,
+		// which happens for the predicates using subqueries
+		dmlFiltered bool
 	}
 )
 
@@ -49,9 +57,39 @@ func ToSQL(ctx *plancontext.PlanningContext, op ops.Operator) (_ sqlparser.State
 	if ctx.SemTable != nil {
 		q.sortTables()
 	}
+	q.addWith()
+	if q.dmlFiltered {
+		if err := q.updateOwnedVindexQuery(); err != nil {
+			return nil, nil, err
+		}
+	}
 	return q.stmt, q.dmlOperator, nil
 }
 
+// updateOwnedVindexQuery generates the owned vindex query of the DML again, using the final WHERE clause.
+// The owned vindex query has to select the same rows as the DML, and the predicates using subqueries
+// are only added to the DML once the subqueries are merged or replaced by the bind variables holding their results.
+func (qb *queryBuilder) updateOwnedVindexQuery() error {
+	switch op := qb.dmlOperator.(type) {
+	case *Delete:
+		if op.OwnedVindexQuery == "" {
+			return nil
+		}
+		tblExpr := &sqlparser.AliasedTableExpr{Expr: sqlparser.TableName{Name: op.VTable.Name}, As: op.QTable.Alias.As}
+		op.OwnedVindexQuery = generateOwnedVindexQuery(tblExpr, qb.stmt.(*sqlparser.Delete), op.VTable, op.VTable.ColumnVindexes[0].Columns)
+	case *Update:
+		if op.OwnedVindexQuery == "" {
+			return nil
+		}
+		_, ovq, _, err := buildChangedVindexesValues(qb.stmt.(*sqlparser.Update), op.VTable, op.VTable.ColumnVindexes[0].Columns, op.Assignments)
+		if err != nil {
+			return err
+		}
+		op.OwnedVindexQuery = ovq
+	}
+	return nil
+}
+
 func (qb *queryBuilder) addTable(db, tableName, alias string, tableID semantics.TableSet, hints sqlparser.IndexHints) {
 	tableExpr := sqlparser.TableName{
 		Name:      sqlparser.NewIdentifierCS(tableName),
@@ -84,6 +122,36 @@ func (qb *queryBuilder) addTableExpr(
 	qb.tableNames = append(qb.tableNames, tableName)
 }
 
+func (qb *queryBuilder) addCTE(cte *sqlparser.CommonTableExpr) {
+	for _, existing := range qb.ctes {
+		if existing.ID.String() == cte.ID.String() {
+			return
+		}
+	}
+	qb.ctes = append(qb.ctes, cte)
+}
+
+// mergeCTEs brings over the CTEs used by a query built by another queryBuilder
+func (qb *queryBuilder) mergeCTEs(other *queryBuilder) {
+	for _, cte := range other.ctes {
+		qb.addCTE(cte)
+	}
+}
+
+func (qb *queryBuilder) addWith() {
+	if len(qb.ctes) == 0 {
+		return
+	}
+	sel, ok := qb.stmt.(sqlparser.SelectStatement)
+	if !ok {
+		panic(vterrors.VT12001(fmt.Sprintf("recursive common table expression in %T", qb.stmt)))
+	}
+	sel.SetWith(&sqlparser.With{
+		Recursive: true,
+		CTEs:      qb.ctes,
+	})
+}
+
 func (qb *queryBuilder) addPredicate(expr sqlparser.Expr) {
 	if _, toBeSkipped := qb.ctx.SkipPredicates[expr]; toBeSkipped {
 		// This is a predicate that was added to the RHS of an ApplyJoin.
@@ -194,6 +262,7 @@ func (qb *queryBuilder) clearProjections() {
 }
 
 func (qb *queryBuilder) unionWith(other *queryBuilder, distinct bool) {
+	qb.mergeCTEs(other)
 	qb.stmt = &sqlparser.Union{
 		Left:     qb.asSelectStatement(),
 		Right:    other.asSelectStatement(),
@@ -202,6 +271,7 @@ func (qb *queryBuilder) unionWith(other *queryBuilder, distinct bool) {
 }
 
 func (qb *queryBuilder) joinInnerWith(other *queryBuilder, onCondition sqlparser.Expr) {
+	qb.mergeCTEs(other)
 	sel := qb.stmt.(*sqlparser.Select)
 	otherSel := other.stmt.(*sqlparser.Select)
 	sel.From = append(sel.From, otherSel.From...)
@@ -224,6 +294,7 @@ func (qb *queryBuilder) joinInnerWith(other *queryBuilder, onCondition sqlparser
 }
 
 func (qb *queryBuilder) joinOuterWith(other *queryBuilder, onCondition sqlparser.Expr) {
+	qb.mergeCTEs(other)
 	sel := qb.stmt.(*sqlparser.Select)
 	otherSel := other.stmt.(*sqlparser.Select)
 	var lhs sqlparser.TableExpr
@@ -288,13 +359,11 @@ func (ts *tableSorter) Len() int {
 
 // Less implements the Sort interface
 func (ts *tableSorter) Less(i, j int) bool {
-	lhs := ts.sel.From[i]
-	rhs := ts.sel.From[j]
-	left, ok := lhs.(*sqlparser.AliasedTableExpr)
+	left, ok := sortableTable(ts.sel.From[i])
 	if !ok {
 		return i < j
 	}
-	right, ok := rhs.(*sqlparser.AliasedTableExpr)
+	right, ok := sortableTable(ts.sel.From[j])
 	if !ok {
 		return i < j
 	}
@@ -302,6 +371,19 @@ func (ts *tableSorter) Less(i, j int) bool {
 	return ts.tbl.TableSetFor(left).TableOffset() < ts.tbl.TableSetFor(right).TableOffset()
 }
 
+// sortableTable returns the table if it can be moved around in the FROM clause.
+// Lateral derived tables have to stay after the tables they are using.
+func sortableTable(expr sqlparser.TableExpr) (*sqlparser.AliasedTableExpr, bool) {
+	tbl, ok := expr.(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, false
+	}
+	if dt, isDerived := tbl.Expr.(*sqlparser.DerivedTable); isDerived && dt.Lateral {
+		return nil, false
+	}
+	return tbl, true
+}
+
 // Swap implements the Sort interface
 func (ts *tableSorter) Swap(i, j int) {
 	ts.sel.From[i], ts.sel.From[j] = ts.sel.From[j], ts.sel.From[i]
@@ -351,6 +433,10 @@ func buildQuery(op ops.Operator, qb *queryBuilder) {
 	switch op := op.(type) {
 	case *Table:
 		buildTable(op, qb)
+	case *CTETable:
+		buildCTETable(op, qb)
+	case *JSONTable:
+		buildJSONTable(op, qb)
 	case *Projection:
 		buildProjection(op, qb)
 	case *ApplyJoin:
@@ -471,6 +557,34 @@ func buildTable(op *Table, qb *queryBuilder) {
 	}
 }
 
+func buildCTETable(op *CTETable, qb *queryBuilder) {
+	cte := sqlparser.CloneRefOfCommonTableExpr(op.CTE)
+	sqlparser.RemoveKeyspace(cte)
+	qb.addCTE(cte)
+
+	alias := op.Alias
+	if alias == cte.ID.String() {
+		alias = ""
+	}
+	qb.addTableExpr(op.CTE.ID.String(), alias, TableID(op), sqlparser.NewTableName(op.CTE.ID.String()), nil, nil)
+	for _, name := range op.Columns {
+		qb.addProjection(&sqlparser.AliasedExpr{Expr: name})
+	}
+}
+
+func buildJSONTable(op *JSONTable, qb *queryBuilder) {
+	jt := sqlparser.CloneRefOfJSONTableExpr(op.AST)
+	sqlparser.RemoveKeyspace(jt)
+	if qb.stmt == nil {
+		qb.stmt = &sqlparser.Select{}
+	}
+	sel := qb.stmt.(*sqlparser.Select)
+	sel.From = append(sel.From, jt)
+	for _, name := range op.Columns {
+		qb.addProjection(&sqlparser.AliasedExpr{Expr: name})
+	}
+}
+
 func buildProjection(op *Projection, qb *queryBuilder) {
 	buildQuery(op.Source, qb)
 
@@ -537,6 +651,9 @@ func buildUnion(op *Union, qb *queryBuilder) {
 
 func buildFilter(op *Filter, qb *queryBuilder) {
 	buildQuery(op.Source, qb)
+	if qb.dmlOperator != nil {
+		qb.dmlFiltered = true
+	}
 
 	for _, pred := range op.Predicates {
 		qb.addPredicate(pred)
@@ -572,7 +689,8 @@ func buildDerivedUnion(op *Horizon, qb *queryBuilder, union *sqlparser.Union) {
 	union.Distinct = opQuery.Distinct
 
 	qb.addTableExpr(op.Alias, op.Alias, TableID(op), &sqlparser.DerivedTable{
-		Select: union,
+		Lateral: op.Lateral,
+		Select:  union,
 	}, nil, op.ColumnAliases)
 }
 
@@ -587,7 +705,8 @@ func buildDerivedSelect(op *Horizon, qb *queryBuilder, sel *sqlparser.Select) {
 	sel.Having = mergeHaving(sel.Having, opQuery.Having)
 	sel.SelectExprs = opQuery.SelectExprs
 	qb.addTableExpr(op.Alias, op.Alias, TableID(op), &sqlparser.DerivedTable{
-		Select: sel,
+		Lateral: op.Lateral,
+		Select:  sel,
 	}, nil, op.ColumnAliases)
 	for _, col := range op.Columns {
 		qb.addProjection(&sqlparser.AliasedExpr{Expr: col})
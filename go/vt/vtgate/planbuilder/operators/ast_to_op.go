@@ -27,7 +27,10 @@ import (
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 )
 
-const foreignKeyConstraintValues = "fkc_vals"
+const (
+	foreignKeyConstraintValues = "fkc_vals"
+	foreignKeyUpdateExpr       = "fkc_upd"
+)
 
 // translateQueryToOp creates an operator tree that represents the input SELECT or UNION query
 func translateQueryToOp(ctx *plancontext.PlanningContext, selStmt sqlparser.Statement) (op ops.Operator, err error) {
@@ -203,10 +206,16 @@ func createOperatorFromUnion(ctx *plancontext.PlanningContext, node *sqlparser.U
 //  2. fkToIgnore: The foreign key constraint to specifically ignore while planning the statement. This field is used in UPDATE CASCADE planning, wherein while planning the child update
 //     query, we need to ignore the parent foreign key constraint that caused the cascade in question.
 func createOpFromStmt(ctx *plancontext.PlanningContext, stmt sqlparser.Statement, verifyAllFKs bool, fkToIgnore string) (ops.Operator, error) {
+	op, _, err := createOpAndCtxFromStmt(ctx, stmt, verifyAllFKs, fkToIgnore)
+	return op, err
+}
+
+// createOpAndCtxFromStmt works like createOpFromStmt, and also returns the planning context that was created for the statement.
+func createOpAndCtxFromStmt(ctx *plancontext.PlanningContext, stmt sqlparser.Statement, verifyAllFKs bool, fkToIgnore string) (ops.Operator, *plancontext.PlanningContext, error) {
 	var err error
 	ctx, err = plancontext.CreatePlanningContext(stmt, ctx.ReservedVars, ctx.VSchema, ctx.PlannerVersion)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// TODO (@GuptaManan100, @harshit-gangal): When we add cross-shard foreign keys support,
@@ -218,7 +227,7 @@ func createOpFromStmt(ctx *plancontext.PlanningContext, stmt sqlparser.Statement
 	// From all the parent foreign keys involved, we should remove the one that we need to ignore.
 	err = ctx.SemTable.RemoveParentForeignKey(fkToIgnore)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Now, we can filter the foreign keys further based on the planning context, specifically whether we are running
@@ -232,10 +241,11 @@ func createOpFromStmt(ctx *plancontext.PlanningContext, stmt sqlparser.Statement
 		err = ctx.SemTable.RemoveNonRequiredForeignKeys(ctx.VerifyAllFKs, vindexes.DeleteAction)
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return PlanQuery(ctx, stmt)
+	op, err := PlanQuery(ctx, stmt)
+	return op, ctx, err
 }
 
 func getOperatorFromTableExpr(ctx *plancontext.PlanningContext, tableExpr sqlparser.TableExpr, onlyTable bool) (ops.Operator, error) {
@@ -246,6 +256,8 @@ func getOperatorFromTableExpr(ctx *plancontext.PlanningContext, tableExpr sqlpar
 		return getOperatorFromJoinTableExpr(ctx, tableExpr)
 	case *sqlparser.ParenTableExpr:
 		return crossJoin(ctx, tableExpr.Exprs)
+	case *sqlparser.JSONTableExpr:
+		return createJSONTableRoute(tableExpr, ctx.SemTable.TableSetForJSONTable(tableExpr)), nil
 	default:
 		return nil, vterrors.VT13001(fmt.Sprintf("unable to use: %T table type", tableExpr))
 	}
@@ -261,11 +273,26 @@ func getOperatorFromJoinTableExpr(ctx *plancontext.PlanningContext, tableExpr *s
 		return nil, err
 	}
 
+	lateral := getLateralTable(ctx, lhs, tableExpr.RightExpr)
+	if lateral != nil {
+		if tableExpr.Join == sqlparser.RightJoinType {
+			return nil, vterrors.VT12001("lateral table on the right side of a RIGHT JOIN")
+		}
+		if subq, _ := getSubQuery(tableExpr.Condition.On); subq != nil {
+			return nil, vterrors.VT12001("subquery in the ON clause of a join with a lateral table")
+		}
+	}
+
 	switch tableExpr.Join {
 	case sqlparser.NormalJoinType:
-		return createInnerJoin(ctx, tableExpr, lhs, rhs)
+		return createInnerJoin(ctx, tableExpr, lhs, rhs, lateral)
 	case sqlparser.LeftJoinType, sqlparser.RightJoinType:
-		return createOuterJoin(tableExpr, lhs, rhs)
+		op, err := createOuterJoin(tableExpr, lhs, rhs)
+		if err != nil {
+			return nil, err
+		}
+		op.Lateral = lateral
+		return op, nil
 	default:
 		return nil, vterrors.VT13001("unsupported: %s", tableExpr.Join.ToString())
 	}
@@ -280,6 +307,13 @@ func getOperatorFromAliasedTableExpr(ctx *plancontext.PlanningContext, tableExpr
 			return nil, err
 		}
 
+		if cte, isCTE := tableInfo.(*semantics.CTETable); isCTE {
+			if cte.SelfReference {
+				return createCTERow(ctx, tableExpr, tableID, cte), nil
+			}
+			return createRecurseCTE(ctx, tableExpr, tableID, cte)
+		}
+
 		if vt, isVindex := tableInfo.(*semantics.VindexTable); isVindex {
 			solves := tableID
 			return &Vindex{
@@ -299,29 +333,42 @@ func getOperatorFromAliasedTableExpr(ctx *plancontext.PlanningContext, tableExpr
 		qg.Tables = append(qg.Tables, qt)
 		return qg, nil
 	case *sqlparser.DerivedTable:
-		if onlyTable && tbl.Select.GetLimit() == nil {
-			tbl.Select.SetOrderBy(nil)
-		}
+		return createDerivedTableOp(ctx, tableExpr, tableID, tbl.Select, onlyTable)
+	default:
+		return nil, vterrors.VT13001(fmt.Sprintf("unable to use: %T", tbl))
+	}
+}
+
+// createDerivedTableOp plans the given statement as the derived table of tableExpr.
+// The statement is passed in separately, so a rewritten version of the derived table can be planned.
+func createDerivedTableOp(
+	ctx *plancontext.PlanningContext,
+	tableExpr *sqlparser.AliasedTableExpr,
+	tableID semantics.TableSet,
+	stmt sqlparser.SelectStatement,
+	onlyTable bool,
+) (ops.Operator, error) {
+	if onlyTable && stmt.GetLimit() == nil {
+		stmt.SetOrderBy(nil)
+	}
 
-		inner, err := translateQueryToOp(ctx, tbl.Select)
+	inner, err := translateQueryToOp(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	if horizon, ok := inner.(*Horizon); ok {
+		horizon.TableId = &tableID
+		horizon.Alias = tableExpr.As.String()
+		horizon.ColumnAliases = tableExpr.Columns
+		horizon.Lateral = tableExpr.Expr.(*sqlparser.DerivedTable).Lateral
+		qp, err := CreateQPFromSelectStatement(ctx, stmt)
 		if err != nil {
 			return nil, err
 		}
-		if horizon, ok := inner.(*Horizon); ok {
-			horizon.TableId = &tableID
-			horizon.Alias = tableExpr.As.String()
-			horizon.ColumnAliases = tableExpr.Columns
-			qp, err := CreateQPFromSelectStatement(ctx, tbl.Select)
-			if err != nil {
-				return nil, err
-			}
-			horizon.QP = qp
-		}
-
-		return inner, nil
-	default:
-		return nil, vterrors.VT13001(fmt.Sprintf("unable to use: %T", tbl))
+		horizon.QP = qp
 	}
+
+	return inner, nil
 }
 
 func crossJoin(ctx *plancontext.PlanningContext, exprs sqlparser.TableExprs) (ops.Operator, error) {
@@ -333,6 +380,8 @@ func crossJoin(ctx *plancontext.PlanningContext, exprs sqlparser.TableExprs) (op
 		}
 		if output == nil {
 			output = op
+		} else if lateral := getLateralTable(ctx, output, tableExpr); lateral != nil {
+			output = &Join{LHS: output, RHS: op, Lateral: lateral}
 		} else {
 			output = createJoin(ctx, output, op)
 		}
@@ -21,7 +21,6 @@ import (
 
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
-	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
 	"vitess.io/vitess/go/vt/vtgate/semantics"
@@ -74,6 +73,10 @@ func (d *Delete) Statement() sqlparser.Statement {
 }
 
 func createOperatorFromDelete(ctx *plancontext.PlanningContext, deleteStmt *sqlparser.Delete) (ops.Operator, error) {
+	if len(deleteStmt.Targets) > 0 {
+		return createMultiTableDeleteOperator(ctx, deleteStmt)
+	}
+
 	tableInfo, qt, err := createQueryTableForDML(ctx, deleteStmt.TableExprs[0], deleteStmt.Where)
 	if err != nil {
 		return nil, err
@@ -84,6 +87,12 @@ func createOperatorFromDelete(ctx *plancontext.PlanningContext, deleteStmt *sqlp
 		return nil, err
 	}
 
+	childFks := ctx.SemTable.GetChildForeignKeysList()
+	// If the delete statement has a limit, we don't support it yet.
+	if len(childFks) > 0 && deleteStmt.Limit != nil {
+		return nil, vterrors.VT12001("foreign keys management at vitess with limit")
+	}
+
 	delClone := sqlparser.CloneRefOfDelete(deleteStmt)
 	// Create the delete operator first.
 	delOp, err := createDeleteOperator(ctx, deleteStmt, qt, vindexTable, routing)
@@ -91,6 +100,11 @@ func createOperatorFromDelete(ctx *plancontext.PlanningContext, deleteStmt *sqlp
 		return nil, err
 	}
 
+	if _, isDMLWithInput := delOp.(*DMLWithInput); isDMLWithInput {
+		// the comments and the foreign keys are handled by the statements planned by the DMLWithInput
+		return delOp, nil
+	}
+
 	if deleteStmt.Comments != nil {
 		delOp = &LockAndComment{
 			Source:   delOp,
@@ -98,15 +112,10 @@ func createOperatorFromDelete(ctx *plancontext.PlanningContext, deleteStmt *sqlp
 		}
 	}
 
-	childFks := ctx.SemTable.GetChildForeignKeysList()
 	// If there are no foreign key constraints, then we don't need to do anything.
 	if len(childFks) == 0 {
 		return delOp, nil
 	}
-	// If the delete statement has a limit, we don't support it yet.
-	if deleteStmt.Limit != nil {
-		return nil, vterrors.VT12001("foreign keys management at vitess with limit")
-	}
 
 	return createFkCascadeOpForDelete(ctx, delOp, delClone, childFks)
 }
@@ -122,52 +131,158 @@ func createDeleteOperator(
 		VTable: vindexTable,
 		AST:    deleteStmt,
 	}
-	route := &Route{
-		Source:  del,
-		Routing: routing,
-	}
-
-	if !vindexTable.Keyspace.Sharded {
-		return route, nil
-	}
 
-	primaryVindex, vindexAndPredicates, err := getVindexInformation(qt.ID, vindexTable)
-	if err != nil {
-		return nil, err
-	}
+	if vindexTable.Keyspace.Sharded {
+		primaryVindex, vindexAndPredicates, err := getVindexInformation(qt.ID, vindexTable)
+		if err != nil {
+			return nil, err
+		}
 
-	tr, ok := routing.(*ShardedRouting)
-	if ok {
-		tr.VindexPreds = vindexAndPredicates
-	}
+		tr, ok := routing.(*ShardedRouting)
+		if ok {
+			tr.VindexPreds = vindexAndPredicates
+		}
 
-	var ovq string
-	if len(vindexTable.Owned) > 0 {
-		tblExpr := &sqlparser.AliasedTableExpr{Expr: sqlparser.TableName{Name: vindexTable.Name}, As: qt.Alias.As}
-		ovq = generateOwnedVindexQuery(tblExpr, deleteStmt, vindexTable, primaryVindex.Columns)
+		if len(vindexTable.Owned) > 0 {
+			tblExpr := &sqlparser.AliasedTableExpr{Expr: sqlparser.TableName{Name: vindexTable.Name}, As: qt.Alias.As}
+			del.OwnedVindexQuery = generateOwnedVindexQuery(tblExpr, deleteStmt, vindexTable, primaryVindex.Columns)
+		}
 	}
 
-	del.OwnedVindexQuery = ovq
-
 	sqc := &SubQueryBuilder{}
+	// handling the subqueries rewrites them, so we keep the original where clause to select the rows with
+	where := sqlparser.CloneRefOfWhere(deleteStmt.Where)
+	var predicates []sqlparser.Expr
+	correlated := false
 	for _, predicate := range qt.Predicates {
 		if subq, err := sqc.handleSubquery(ctx, predicate, qt.ID); err != nil {
 			return nil, err
 		} else if subq != nil {
+			correlated = correlated || len(subq.Predicates) > 0
 			continue
 		}
+		predicates = append(predicates, predicate)
+		var err error
 		routing, err = UpdateRoutingLogic(ctx, predicate, routing)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if routing.OpCode() == engine.Scatter && deleteStmt.Limit != nil {
-		// TODO systay: we should probably check for other op code types - IN could also hit multiple shards (2022-04-07)
-		return nil, vterrors.VT12001("multi shard DELETE with LIMIT")
+	tblExpr := sqlparser.CloneTableExpr(deleteStmt.TableExprs[0])
+	if aliasedTbl, ok := tblExpr.(*sqlparser.AliasedTableExpr); ok && len(deleteStmt.Partitions) > 0 {
+		// the rows we select have to come from the same partitions as the rows the delete can see
+		aliasedTbl.Partitions = sqlparser.ClonePartitions(deleteStmt.Partitions)
+	}
+	target := dmlTarget{
+		vTbl: vindexTable,
+		dml: func(where *sqlparser.Where) sqlparser.Statement {
+			return &sqlparser.Delete{
+				Comments:   deleteStmt.Comments,
+				Ignore:     deleteStmt.Ignore,
+				TableExprs: sqlparser.CloneTableExprs(deleteStmt.TableExprs),
+				Partitions: sqlparser.ClonePartitions(deleteStmt.Partitions),
+				Where:      where,
+			}
+		},
+	}
+	if needsDMLWithInput(routing, deleteStmt.Limit) {
+		return createDMLWithInput(ctx, []dmlTarget{target}, sqlparser.TableExprs{tblExpr}, where, deleteStmt.OrderBy, deleteStmt.Limit, deleteStmt.Comments)
+	}
+	if correlated && vindexTable.Keyspace.Sharded {
+		op, err := createDMLWithInputForSubqueries(ctx, target, sqlparser.TableExprs{tblExpr}, where, deleteStmt.Comments)
+		if err != nil || op != nil {
+			return op, err
+		}
+	}
+
+	if len(sqc.Inner) > 0 {
+		// The predicates using subqueries are added back when the subqueries are merged or settled,
+		// so the delete only keeps the other predicates.
+		deleteStmt.Where = sqlparser.NewWhere(sqlparser.WhereClause, sqlparser.AndExpressions(predicates...))
+		qt.Predicates = predicates
 	}
 
-	return sqc.getRootOperator(route, nil), nil
+	route := &Route{
+		Source:  del,
+		Routing: routing,
+	}
+	decorator := func(op ops.Operator) ops.Operator {
+		return &LockAndComment{
+			Source: op,
+			Lock:   sqlparser.ShareModeLock,
+		}
+	}
+	return sqc.getRootOperator(route, decorator), nil
+}
+
+// createMultiTableDeleteOperator plans a DELETE that removes rows from more than one table, or that joins
+// the table it removes rows from with other tables. The join is evaluated by the selection of a DMLWithInput,
+// and each of the targets gets a DELETE on its primary key, which takes care of the owned vindexes.
+func createMultiTableDeleteOperator(ctx *plancontext.PlanningContext, deleteStmt *sqlparser.Delete) (ops.Operator, error) {
+	if ctx.SemTable.ForeignKeysPresent() {
+		return nil, vterrors.VT12001("foreign keys management at vitess with multi-table DELETE")
+	}
+
+	aliasedTables := getAliasedTables(deleteStmt.TableExprs)
+	var targets []dmlTarget
+	for _, targetName := range deleteStmt.Targets {
+		tblExpr := findDeleteTarget(aliasedTables, targetName)
+		if tblExpr == nil {
+			return nil, vterrors.VT03003(targetName.Name.String())
+		}
+		tblName, isTable := tblExpr.Expr.(sqlparser.TableName)
+		if !isTable {
+			return nil, vterrors.VT03004(tblExpr.As.String())
+		}
+		tableInfo, err := ctx.SemTable.TableInfoFor(ctx.SemTable.TableSetFor(tblExpr))
+		if err != nil {
+			return nil, err
+		}
+		vTbl := tableInfo.GetVindexTable()
+		if vTbl == nil {
+			return nil, vterrors.VT03004(targetName.Name.String())
+		}
+		qualifier, err := tblExpr.TableName()
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, dmlTarget{
+			vTbl:      vTbl,
+			qualifier: qualifier,
+			dml: func(where *sqlparser.Where) sqlparser.Statement {
+				return &sqlparser.Delete{
+					Comments:   deleteStmt.Comments,
+					Ignore:     deleteStmt.Ignore,
+					TableExprs: sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: sqlparser.CloneTableName(tblName)}},
+					Where:      where,
+				}
+			},
+		})
+	}
+
+	return createDMLWithInput(ctx, targets, deleteStmt.TableExprs, deleteStmt.Where, nil, nil, deleteStmt.Comments)
+}
+
+// findDeleteTarget returns the table expression a target of a multi-table DELETE refers to.
+// Targets refer to the alias of a table when it has one, and to the table name otherwise.
+func findDeleteTarget(aliasedTables []*sqlparser.AliasedTableExpr, target sqlparser.TableName) *sqlparser.AliasedTableExpr {
+	for _, tblExpr := range aliasedTables {
+		if !tblExpr.As.IsEmpty() {
+			if target.Qualifier.IsEmpty() && sqlparser.Equals.IdentifierCS(tblExpr.As, target.Name) {
+				return tblExpr
+			}
+			continue
+		}
+		tblName, isTable := tblExpr.Expr.(sqlparser.TableName)
+		if !isTable || !sqlparser.Equals.IdentifierCS(tblName.Name, target.Name) {
+			continue
+		}
+		if target.Qualifier.IsEmpty() || sqlparser.Equals.IdentifierCS(tblName.Qualifier, target.Qualifier) {
+			return tblExpr
+		}
+	}
+	return nil
 }
 
 func createFkCascadeOpForDelete(ctx *plancontext.PlanningContext, parentOp ops.Operator, delStmt *sqlparser.Delete, childFks []vindexes.ChildFKInfo) (ops.Operator, error) {
@@ -60,6 +60,10 @@ func buildChangedVindexesValues(update *sqlparser.Update, table *vindexes.Table,
 	changedVindexes := make(map[string]*engine.VindexValues)
 	buf, offset := initialQuery(ksidCols, table)
 	for i, vindex := range table.ColumnVindexes {
+		if vindex.IsPartialVindex() {
+			// The columns of partial vindexes are a prefix of the columns of the primary vindex, which is handled already.
+			continue
+		}
 		vindexValueMap := make(map[string]evalengine.Expr)
 		first := true
 		for _, vcol := range vindex.Columns {
@@ -105,9 +109,12 @@ func buildChangedVindexesValues(update *sqlparser.Update, table *vindexes.Table,
 			return nil, "", nil, vterrors.VT12001(fmt.Sprintf("you need to provide the ORDER BY clause when using LIMIT; invalid update on vindex: %v", vindex.Name))
 		}
 		if i == 0 {
-			return nil, "", nil, vterrors.VT12001(fmt.Sprintf("you cannot UPDATE primary vindex columns; invalid update on vindex: %v", vindex.Name))
-		}
-		if _, ok := vindex.Vindex.(vindexes.Lookup); !ok {
+			// Changing the columns of a multi-column primary vindex gives the row a new keyspace id,
+			// which is fine as long as the row stays in its shard. That is checked when executing the update.
+			if _, isMultiColumn := vindex.Vindex.(vindexes.MultiColumn); !isMultiColumn {
+				return nil, "", nil, vterrors.VT12001(fmt.Sprintf("you cannot UPDATE primary vindex columns; invalid update on vindex: %v", vindex.Name))
+			}
+		} else if _, ok := vindex.Vindex.(vindexes.Lookup); !ok {
 			return nil, "", nil, vterrors.VT12001(fmt.Sprintf("you can only UPDATE lookup vindexes; invalid update on vindex: %v", vindex.Name))
 		}
 		changedVindexes[vindex.Name] = &engine.VindexValues{
@@ -0,0 +1,255 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operators
+
+import (
+	"fmt"
+	"slices"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/semantics"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+// dmlValues is the name of the bind variable used to send the selected rows to the DML
+const dmlValues = "dml_vals"
+
+// DMLWithInput is used to represent DMLs that modify the rows found by the Source operator.
+// This is used for UPDATE and DELETE statements with LIMIT that can affect rows on more than one shard,
+// and for multi-table UPDATE and DELETE statements in sharded keyspaces.
+// The Source selects the primary key of the rows to modify, and each DML targets its rows using the values in BVName.
+type DMLWithInput struct {
+	Source ops.Operator
+	DML    []ops.Operator
+
+	BVName string
+	// Offsets are the columns in the Source that are sent to each of the DMLs
+	Offsets [][]int
+
+	// The Source and the DMLs are planned as separate statements, and these are the semantic tables they were planned with
+	SourceSemTable *semantics.SemTable
+	DMLSemTables   []*semantics.SemTable
+
+	noColumns
+	noPredicates
+}
+
+var _ ops.Operator = (*DMLWithInput)(nil)
+
+// Inputs implements the Operator interface
+func (d *DMLWithInput) Inputs() []ops.Operator {
+	return append([]ops.Operator{d.Source}, d.DML...)
+}
+
+// SetInputs implements the Operator interface
+func (d *DMLWithInput) SetInputs(inputs []ops.Operator) {
+	if len(inputs) != len(d.DML)+1 {
+		panic("incorrect count of inputs for DMLWithInput")
+	}
+	d.Source = inputs[0]
+	d.DML = slices.Clone(inputs[1:])
+}
+
+// Clone implements the Operator interface
+func (d *DMLWithInput) Clone(inputs []ops.Operator) ops.Operator {
+	newD := *d
+	newD.SetInputs(inputs)
+	newD.Offsets = slices.Clone(d.Offsets)
+	newD.DMLSemTables = slices.Clone(d.DMLSemTables)
+	return &newD
+}
+
+// GetOrdering implements the Operator interface
+func (d *DMLWithInput) GetOrdering(*plancontext.PlanningContext) []ops.OrderBy {
+	return nil
+}
+
+// ShortDescription implements the Operator interface
+func (d *DMLWithInput) ShortDescription() string {
+	return fmt.Sprintf("%s %v", d.BVName, d.Offsets)
+}
+
+// dmlTarget is a table modified by a DMLWithInput
+type dmlTarget struct {
+	vTbl *vindexes.Table
+	// qualifier is used for the primary key columns in the selection, it is empty when only one table is selected from
+	qualifier sqlparser.TableName
+	// dml returns the statement that modifies the table, given the predicate on the primary key
+	dml func(where *sqlparser.Where) sqlparser.Statement
+}
+
+// needsDMLWithInput returns true if a DML with LIMIT can't be sent as is to the shards,
+// since the limit has to be applied over the rows of all the shards the DML is routed to.
+func needsDMLWithInput(routing Routing, limit *sqlparser.Limit) bool {
+	if limit == nil {
+		return false
+	}
+	switch code := routing.OpCode(); code {
+	case engine.None, engine.ByDestination:
+		return false
+	default:
+		return !code.IsSingleShard()
+	}
+}
+
+// createDMLWithInput plans a DML as two steps. First the primary key of the rows to modify are selected
+// using the table expressions, predicates, ORDER BY and LIMIT of the DML, locking the rows.
+// Then a DML is sent for each of the targets to the shards holding these rows, using the primary key values
+// instead of the original predicates. When a primary key column is part of the primary vindex,
+// the DML is routed to only the needed shards.
+func createDMLWithInput(
+	ctx *plancontext.PlanningContext,
+	targets []dmlTarget,
+	tableExprs sqlparser.TableExprs,
+	where *sqlparser.Where,
+	orderBy sqlparser.OrderBy,
+	limit *sqlparser.Limit,
+	comments *sqlparser.ParsedComments,
+) (ops.Operator, error) {
+	selection, err := createDMLSelection(ctx, targets, tableExprs, where, orderBy, limit, comments)
+	if err != nil {
+		return nil, err
+	}
+	return selection.addDMLs(ctx, targets)
+}
+
+// createDMLWithInputForSubqueries plans a DML with correlated subqueries in its WHERE clause as a DMLWithInput,
+// so that the subqueries are evaluated by the selection. It returns nil when the selection can be sent as
+// a single query, since the subqueries can then be merged with the DML as well. It also returns nil when
+// the primary key of the table is not known, and the DML has to be planned on its own.
+func createDMLWithInputForSubqueries(
+	ctx *plancontext.PlanningContext,
+	target dmlTarget,
+	tableExprs sqlparser.TableExprs,
+	where *sqlparser.Where,
+	comments *sqlparser.ParsedComments,
+) (ops.Operator, error) {
+	if len(target.vTbl.PrimaryKey) == 0 {
+		return nil, nil
+	}
+	selection, err := createDMLSelection(ctx, []dmlTarget{target}, tableExprs, where, nil, nil, comments)
+	if err != nil {
+		return nil, err
+	}
+	if _, isRoute := selection.op.(*Route); isRoute {
+		return nil, nil
+	}
+	return selection.addDMLs(ctx, []dmlTarget{target})
+}
+
+// dmlSelection is the planned query that selects the primary keys of the rows modified by a DMLWithInput
+type dmlSelection struct {
+	op       ops.Operator
+	semTable *semantics.SemTable
+	// pkCols are the primary key columns of each target, and offsets are where to find them in the selection
+	pkCols  []sqlparser.ValTuple
+	offsets [][]int
+}
+
+func createDMLSelection(
+	ctx *plancontext.PlanningContext,
+	targets []dmlTarget,
+	tableExprs sqlparser.TableExprs,
+	where *sqlparser.Where,
+	orderBy sqlparser.OrderBy,
+	limit *sqlparser.Limit,
+	comments *sqlparser.ParsedComments,
+) (*dmlSelection, error) {
+	var selectExprs sqlparser.SelectExprs
+	selection := &dmlSelection{}
+	for _, target := range targets {
+		if len(target.vTbl.PrimaryKey) == 0 {
+			return nil, vterrors.VT09015()
+		}
+		var lhs sqlparser.ValTuple
+		var tblOffsets []int
+		for _, col := range target.vTbl.PrimaryKey {
+			tblOffsets = append(tblOffsets, len(selectExprs))
+			selectExprs = append(selectExprs, aeWrap(sqlparser.NewColNameWithQualifier(col.String(), target.qualifier)))
+			lhs = append(lhs, sqlparser.NewColName(col.String()))
+		}
+		selection.pkCols = append(selection.pkCols, lhs)
+		selection.offsets = append(selection.offsets, tblOffsets)
+	}
+
+	selectionStmt := &sqlparser.Select{
+		Comments:    comments,
+		SelectExprs: selectExprs,
+		From:        sqlparser.CloneTableExprs(tableExprs),
+		Where:       sqlparser.CloneRefOfWhere(where),
+		OrderBy:     sqlparser.CloneOrderBy(orderBy),
+		Limit:       sqlparser.CloneRefOfLimit(limit),
+		Lock:        sqlparser.ForUpdateLock,
+	}
+	// There are no foreign keys to check for a select query, so we can pass anything for verifyAllFKs and fkToIgnore.
+	selectionOp, selectionCtx, err := createOpAndCtxFromStmt(ctx, selectionStmt, false /* verifyAllFKs */, "" /* fkToIgnore */)
+	if err != nil {
+		return nil, err
+	}
+	selection.op = selectionOp
+	selection.semTable = selectionCtx.SemTable
+	return selection, nil
+}
+
+// addDMLs plans the DML of each of the targets, and returns the DMLWithInput using the selection as its source
+func (selection *dmlSelection) addDMLs(ctx *plancontext.PlanningContext, targets []dmlTarget) (ops.Operator, error) {
+	dmlWithInput := &DMLWithInput{
+		Source:         selection.op,
+		BVName:         ctx.ReservedVars.ReserveVariable(dmlValues),
+		Offsets:        selection.offsets,
+		SourceSemTable: selection.semTable,
+	}
+	for idx, target := range targets {
+		pkCols := selection.pkCols[idx]
+		var left sqlparser.Expr = pkCols
+		if len(pkCols) == 1 {
+			left = pkCols[0]
+		}
+		dmlStmt := target.dml(&sqlparser.Where{
+			Type: sqlparser.WhereClause,
+			Expr: sqlparser.NewComparisonExpr(sqlparser.InOp, left, sqlparser.NewListArg(dmlWithInput.BVName), nil),
+		})
+		// The foreign keys of the targets are handled when planning their DML.
+		dmlOp, dmlCtx, err := createOpAndCtxFromStmt(ctx, dmlStmt, ctx.VerifyAllFKs, "" /* fkToIgnore */)
+		if err != nil {
+			return nil, err
+		}
+		dmlWithInput.DML = append(dmlWithInput.DML, dmlOp)
+		dmlWithInput.DMLSemTables = append(dmlWithInput.DMLSemTables, dmlCtx.SemTable)
+	}
+	return dmlWithInput, nil
+}
+
+// getAliasedTables returns the table expressions of the tables joined in a multi-table DML.
+func getAliasedTables(tableExprs sqlparser.TableExprs) []*sqlparser.AliasedTableExpr {
+	var tables []*sqlparser.AliasedTableExpr
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.AliasedTableExpr:
+			tables = append(tables, node)
+			return false, nil
+		case *sqlparser.Subquery:
+			return false, nil
+		}
+		return true, nil
+	}, tableExprs)
+	return tables
+}
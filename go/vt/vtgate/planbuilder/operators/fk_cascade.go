@@ -19,6 +19,7 @@ package operators
 import (
 	"slices"
 
+	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
 )
@@ -41,6 +42,9 @@ type FkCascade struct {
 	Children  []*FkChild
 	Parent    ops.Operator
 
+	// NonLiteralInfo is set when the foreign key columns are updated to non-literal values
+	NonLiteralInfo []engine.NonLiteralUpdateInfo
+
 	noColumns
 	noPredicates
 }
@@ -79,8 +83,9 @@ func (fkc *FkCascade) Clone(inputs []ops.Operator) ops.Operator {
 		panic("incorrect count of inputs for FkCascade")
 	}
 	newFkc := &FkCascade{
-		Parent:    inputs[0],
-		Selection: inputs[1],
+		Parent:         inputs[0],
+		Selection:      inputs[1],
+		NonLiteralInfo: slices.Clone(fkc.NonLiteralInfo),
 	}
 	for idx, operator := range inputs {
 		if idx < 2 {
@@ -41,6 +41,7 @@ type Horizon struct {
 	TableId       *semantics.TableSet
 	Alias         string
 	ColumnAliases sqlparser.Columns // derived tables can have their column aliases specified outside the subquery
+	Lateral       bool              // a lateral derived table can use the columns of the tables before it
 
 	// QP contains the QueryProjection for this op
 	QP *QueryProjection
@@ -145,13 +145,21 @@ func createProjectionFromSelect(ctx *plancontext.PlanningContext, horizon *Horiz
 	}
 
 	if !qp.NeedsAggregation() {
-		projX := createProjectionWithoutAggr(ctx, qp, horizon.src())
+		var src ops.Operator = horizon.src()
+		if w := createWindow(ctx, qp, src); w != nil {
+			src = w
+		}
+		projX := createProjectionWithoutAggr(ctx, qp, src)
 		projX.DT = dt
 		out = projX
 
 		return out
 	}
 
+	if w := createWindow(ctx, qp, horizon.src()); w != nil {
+		panic(vterrors.VT12001("window functions on aggregated results across shards"))
+	}
+
 	aggregations, complexAggr, err := qp.AggregationExpressions(ctx, true)
 	if err != nil {
 		panic(err)
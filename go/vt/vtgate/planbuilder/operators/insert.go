@@ -116,6 +116,8 @@ func createOperatorFromInsert(ctx *plancontext.PlanningContext, ins *sqlparser.I
 		return nil, err
 	}
 
+	// planning the insert rewrites the rows for the auto-increment column, so we keep the original statement for upserts
+	insClone := sqlparser.CloneRefOfInsert(ins)
 	insOp, err := createInsertOperator(ctx, ins, vindexTable, routing)
 	if err != nil {
 		return nil, err
@@ -145,7 +147,8 @@ func createOperatorFromInsert(ctx *plancontext.PlanningContext, ins *sqlparser.I
 	if len(parentFKs) > 0 {
 		return nil, vterrors.VT12002()
 	}
-	return nil, vterrors.VT12001("ON DUPLICATE KEY UPDATE with foreign keys")
+	// The child foreign keys are only involved for ON DUPLICATE KEY UPDATE and REPLACE statements.
+	return createUpsertOperator(ctx, insClone, vindexTable)
 }
 
 func createInsertOperator(ctx *plancontext.PlanningContext, insStmt *sqlparser.Insert, vTbl *vindexes.Table, routing Routing) (ops.Operator, error) {
@@ -30,6 +30,9 @@ type Join struct {
 	Predicate sqlparser.Expr
 	LeftJoin  bool
 
+	// Lateral is set when the RHS is a lateral derived table or a JSON_TABLE using columns from the LHS
+	Lateral *lateralTable
+
 	noColumns
 }
 
@@ -45,6 +48,7 @@ func (j *Join) Clone(inputs []ops.Operator) ops.Operator {
 		RHS:       inputs[1],
 		Predicate: j.Predicate,
 		LeftJoin:  j.LeftJoin,
+		Lateral:   j.Lateral,
 	}
 }
 
@@ -63,8 +67,8 @@ func (j *Join) SetInputs(ops []ops.Operator) {
 }
 
 func (j *Join) Compact(ctx *plancontext.PlanningContext) (ops.Operator, *rewrite.ApplyResult, error) {
-	if j.LeftJoin {
-		// we can't merge outer joins into a single QG
+	if j.LeftJoin || j.Lateral != nil {
+		// we can't merge outer joins or lateral joins into a single QG
 		return j, rewrite.SameTree, nil
 	}
 
@@ -85,7 +89,7 @@ func (j *Join) Compact(ctx *plancontext.PlanningContext) (ops.Operator, *rewrite
 	return newOp, rewrite.NewTree("merge querygraphs into a single one", newOp), nil
 }
 
-func createOuterJoin(tableExpr *sqlparser.JoinTableExpr, lhs, rhs ops.Operator) (ops.Operator, error) {
+func createOuterJoin(tableExpr *sqlparser.JoinTableExpr, lhs, rhs ops.Operator) (*Join, error) {
 	if tableExpr.Join == sqlparser.RightJoinType {
 		lhs, rhs = rhs, lhs
 	}
@@ -112,8 +116,13 @@ func createJoin(ctx *plancontext.PlanningContext, LHS, RHS ops.Operator) ops.Ope
 	return &Join{LHS: LHS, RHS: RHS}
 }
 
-func createInnerJoin(ctx *plancontext.PlanningContext, tableExpr *sqlparser.JoinTableExpr, lhs, rhs ops.Operator) (ops.Operator, error) {
-	op := createJoin(ctx, lhs, rhs)
+func createInnerJoin(ctx *plancontext.PlanningContext, tableExpr *sqlparser.JoinTableExpr, lhs, rhs ops.Operator, lateral *lateralTable) (ops.Operator, error) {
+	var op ops.Operator
+	if lateral != nil {
+		op = &Join{LHS: lhs, RHS: rhs, Lateral: lateral}
+	} else {
+		op = createJoin(ctx, lhs, rhs)
+	}
 	sqc := &SubQueryBuilder{}
 	outerID := TableID(op)
 	joinPredicate := tableExpr.Condition.On
@@ -133,6 +142,9 @@ func createInnerJoin(ctx *plancontext.PlanningContext, tableExpr *sqlparser.Join
 }
 
 func (j *Join) AddPredicate(ctx *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	if j.Lateral != nil {
+		return j.addLateralPredicate(ctx, expr)
+	}
 	return AddPredicate(ctx, j, expr, false, newFilter)
 }
 
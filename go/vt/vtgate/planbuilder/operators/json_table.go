@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operators
+
+import (
+	"vitess.io/vitess/go/slice"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/semantics"
+)
+
+// JSONTable is a JSON_TABLE table function in the FROM clause.
+// It does not read any table, so it is planned as a route that can be sent to any shard,
+// and merged with the route of the tables whose columns it uses.
+type JSONTable struct {
+	ID      semantics.TableSet
+	AST     *sqlparser.JSONTableExpr
+	Columns []*sqlparser.ColName
+
+	noInputs
+}
+
+var _ ops.Operator = (*JSONTable)(nil)
+
+func createJSONTableRoute(tableExpr *sqlparser.JSONTableExpr, tableID semantics.TableSet) *Route {
+	return &Route{
+		Source: &JSONTable{
+			ID:  tableID,
+			AST: tableExpr,
+		},
+		Routing: &DualRouting{},
+	}
+}
+
+// Clone implements the Operator interface
+func (jt *JSONTable) Clone([]ops.Operator) ops.Operator {
+	var columns []*sqlparser.ColName
+	for _, name := range jt.Columns {
+		columns = append(columns, sqlparser.CloneRefOfColName(name))
+	}
+	klone := *jt
+	klone.Columns = columns
+	return &klone
+}
+
+// AddPredicate implements the Operator interface
+func (jt *JSONTable) AddPredicate(_ *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	return newFilter(jt, expr)
+}
+
+func (jt *JSONTable) AddColumn(*plancontext.PlanningContext, bool, bool, *sqlparser.AliasedExpr) int {
+	panic(vterrors.VT13001("did not expect this method to be called"))
+}
+
+func (jt *JSONTable) FindCol(_ *plancontext.PlanningContext, expr sqlparser.Expr, _ bool) int {
+	colToFind, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return -1
+	}
+
+	for idx, colName := range jt.Columns {
+		if colName.Name.Equal(colToFind.Name) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+func (jt *JSONTable) GetColumns(*plancontext.PlanningContext) []*sqlparser.AliasedExpr {
+	return slice.Map(jt.Columns, colNameToExpr)
+}
+
+func (jt *JSONTable) GetSelectExprs(ctx *plancontext.PlanningContext) sqlparser.SelectExprs {
+	return transformColumnsToSelectExprs(ctx, jt)
+}
+
+func (jt *JSONTable) GetOrdering(*plancontext.PlanningContext) []ops.OrderBy {
+	return nil
+}
+
+func (jt *JSONTable) GetColNames() []*sqlparser.ColName {
+	return jt.Columns
+}
+
+func (jt *JSONTable) AddCol(col *sqlparser.ColName) {
+	jt.Columns = append(jt.Columns, col)
+}
+
+func (jt *JSONTable) introducesTableID() semantics.TableSet {
+	return jt.ID
+}
+
+func (jt *JSONTable) ShortDescription() string {
+	return "json_table AS " + jt.AST.Alias.String()
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operators
+
+import (
+	"slices"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/rewrite"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/semantics"
+)
+
+// lateralTable is the right side of a join that is a LATERAL derived table or a JSON_TABLE,
+// and that uses columns from the left side of the join.
+// If the two sides of the join can't be merged into a single route, the right side is planned again,
+// this time with the columns of the left side replaced by arguments, so it can be the RHS of an ApplyJoin.
+type lateralTable struct {
+	tableExpr sqlparser.TableExpr
+	tableID   semantics.TableSet
+
+	// predicates are the predicates inside the lateral derived table comparing it with the left side.
+	// They are only used to check if the two sides can be merged.
+	predicates []sqlparser.Expr
+}
+
+// getLateralTable returns the lateralTable for tableExpr, or nil if it is not
+// a lateral table using any of the tables on the left side of the join
+func getLateralTable(ctx *plancontext.PlanningContext, lhs ops.Operator, tableExpr sqlparser.TableExpr) *lateralTable {
+	var node sqlparser.SQLNode
+	var tableID semantics.TableSet
+	switch tbl := tableExpr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		dt, isDerived := tbl.Expr.(*sqlparser.DerivedTable)
+		if !isDerived || !dt.Lateral {
+			return nil
+		}
+		node = dt.Select
+		tableID = ctx.SemTable.TableSetFor(tbl)
+	case *sqlparser.JSONTableExpr:
+		node = tbl.Expr
+		tableID = ctx.SemTable.TableSetForJSONTable(tbl)
+	default:
+		return nil
+	}
+
+	lhsID := TableID(lhs)
+	if !usesTables(ctx, node, lhsID) {
+		return nil
+	}
+
+	lateral := &lateralTable{
+		tableExpr: tableExpr,
+		tableID:   tableID,
+	}
+	if sel, isSel := node.(*sqlparser.Select); isSel && sel.Where != nil {
+		for _, pred := range sqlparser.SplitAndExpression(nil, sel.Where.Expr) {
+			if ctx.SemTable.RecursiveDeps(pred).IsOverlapping(lhsID) {
+				lateral.predicates = append(lateral.predicates, pred)
+			}
+		}
+	}
+	return lateral
+}
+
+// usesTables returns true if any column in the node is coming from the given tables
+func usesTables(ctx *plancontext.PlanningContext, node sqlparser.SQLNode, tables semantics.TableSet) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		col, isCol := node.(*sqlparser.ColName)
+		if isCol && ctx.SemTable.RecursiveDeps(col).IsOverlapping(tables) {
+			found = true
+		}
+		return !found, nil
+	}, node)
+	return found
+}
+
+// addLateralPredicate adds a predicate to a join with a lateral table on the right side.
+// The right side might have to be planned again later, so we don't push any predicates into it.
+func (j *Join) addLateralPredicate(ctx *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	deps := ctx.SemTable.RecursiveDeps(expr)
+	switch {
+	case deps.IsSolvedBy(TableID(j.LHS)):
+		j.LHS = j.LHS.AddPredicate(ctx, expr)
+		return j
+	case j.LeftJoin:
+		return newFilter(j, expr)
+	default:
+		j.Predicate = ctx.SemTable.AndExpressions(j.Predicate, expr)
+		return j
+	}
+}
+
+// optimizeLateralJoin pushes the join down to MySQL if both sides can be merged into a single route.
+// If not, the lateral table is planned with arguments for the columns of the left side,
+// and the join is done in vtgate with an ApplyJoin.
+func optimizeLateralJoin(ctx *plancontext.PlanningContext, op *Join) (ops.Operator, *rewrite.ApplyResult, error) {
+	joinPredicates := sqlparser.SplitAndExpression(nil, op.Predicate)
+	inner := !op.LeftJoin
+
+	// the lateral table is fully planned first, so we know if it ends up in a single route
+	rhs, err := runRewriters(ctx, op.RHS)
+	if err != nil {
+		return nil, nil, err
+	}
+	mergePredicates := append(slices.Clone(joinPredicates), op.Lateral.predicates...)
+	newPlan := mergeJoinInputs(ctx, op.LHS, rhs, mergePredicates, newJoinMerge(joinPredicates, inner))
+	if newPlan != nil {
+		return newPlan, rewrite.NewTree("merge lateral join into single operator", newPlan), nil
+	}
+
+	rhs, vars, err := op.Lateral.planWithArguments(ctx, TableID(op.LHS))
+	if err != nil {
+		return nil, nil, err
+	}
+	join := NewApplyJoin(Clone(op.LHS), rhs, nil, !inner)
+	join.ExtraLHSVars = vars
+
+	if len(joinPredicates) > 0 && requiresSwitchingSides(ctx, rhs) {
+		// the right side has to be evaluated in vtgate, and it needs the values from the left side,
+		// so we can't switch sides. Instead, the join predicates are evaluated after the join.
+		if !inner {
+			return nil, nil, vterrors.VT12001("LEFT JOIN with a lateral derived table that has to be evaluated in vtgate")
+		}
+		newOp := newFilter(join, ctx.SemTable.AndExpressions(joinPredicates...))
+		return newOp, rewrite.NewTree("lateral join to applyJoin, filtering after the join", newOp), nil
+	}
+
+	newOp, err := pushJoinPredicates(ctx, joinPredicates, join)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newOp, rewrite.NewTree("lateral join to applyJoin", newOp), nil
+}
+
+// planWithArguments plans the lateral table again, replacing the columns coming from the left side of the join with arguments.
+// It returns the new operator, and the expressions the left side needs to provide for these arguments.
+func (lt *lateralTable) planWithArguments(ctx *plancontext.PlanningContext, lhsID semantics.TableSet) (ops.Operator, []BindVarExpr, error) {
+	var vars []BindVarExpr
+	replaceLHSColumns := func(cursor *sqlparser.CopyOnWriteCursor) {
+		col, isCol := cursor.Node().(*sqlparser.ColName)
+		if !isCol || !ctx.SemTable.RecursiveDeps(col).IsSolvedBy(lhsID) {
+			return
+		}
+		bvName := ctx.GetReservedArgumentFor(col)
+		if !slices.ContainsFunc(vars, func(bve BindVarExpr) bool { return bve.Name == bvName }) {
+			vars = append(vars, BindVarExpr{Name: bvName, Expr: col})
+		}
+		cursor.Replace(sqlparser.NewArgument(bvName))
+	}
+	// the rewritten expressions get their dependencies from the columns that are left,
+	// so we only copy the semantic information of the statements
+	copyStatementInfo := func(from, to sqlparser.SQLNode) {
+		if _, isStmt := from.(sqlparser.SelectStatement); isStmt {
+			ctx.SemTable.CopySemanticInfo(from, to)
+		}
+	}
+
+	switch tbl := lt.tableExpr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		dt := tbl.Expr.(*sqlparser.DerivedTable)
+		for _, sel := range sqlparser.GetAllSelects(dt.Select) {
+			// the columns of the derived table are still bound to the original select expressions,
+			// so they can't be rewritten to use arguments
+			if usesTables(ctx, sel.SelectExprs, lhsID) {
+				return nil, nil, vterrors.VT12001("lateral derived table selecting columns from the left side of the join in a cross-shard query")
+			}
+		}
+		stmt := sqlparser.CopyOnRewrite(dt.Select, nil, replaceLHSColumns, copyStatementInfo).(sqlparser.SelectStatement)
+		op, err := createDerivedTableOp(ctx, tbl, lt.tableID, stmt, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if horizon, isHorizon := op.(*Horizon); isHorizon {
+			// the columns of the left side have been replaced with arguments, so it does not have to be lateral anymore
+			horizon.Lateral = false
+		}
+		return op, vars, nil
+	case *sqlparser.JSONTableExpr:
+		jt := *tbl
+		jt.Expr = sqlparser.CopyOnRewrite(tbl.Expr, nil, replaceLHSColumns, nil).(sqlparser.Expr)
+		return createJSONTableRoute(&jt, lt.tableID), vars, nil
+	default:
+		return nil, nil, vterrors.VT13001("unexpected lateral table: " + sqlparser.String(tbl))
+	}
+}
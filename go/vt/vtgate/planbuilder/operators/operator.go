@@ -66,6 +66,12 @@ func PlanQuery(ctx *plancontext.PlanningContext, stmt sqlparser.Statement) (resu
 		return nil, err
 	}
 
+	switch op.(type) {
+	case *DMLWithInput, *Upsert:
+		// the inputs were planned as separate statements, so there is nothing more to do
+		return op, nil
+	}
+
 	if rewrite.DebugOperatorTree {
 		fmt.Println("Initial tree:")
 		fmt.Println(ops.ToTree(op))
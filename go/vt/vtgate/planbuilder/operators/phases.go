@@ -191,16 +191,27 @@ func addGroupByOnRHSOfJoin(root ops.Operator) (ops.Operator, error) {
 }
 
 func addLiteralGroupingToRHS(in *ApplyJoin) (ops.Operator, *rewrite.ApplyResult, error) {
-	_ = rewrite.Visit(in.RHS, func(op ops.Operator) error {
+	visitor := func(op ops.Operator, _ semantics.TableSet, _ bool) (ops.Operator, *rewrite.ApplyResult, error) {
 		aggr, isAggr := op.(*Aggregator)
-		if !isAggr {
-			return nil
+		if !isAggr || aggr.Original {
+			return op, rewrite.SameTree, nil
 		}
 		if len(aggr.Grouping) == 0 {
 			gb := sqlparser.NewIntLiteral(".0")
 			aggr.Grouping = append(aggr.Grouping, NewGroupBy(gb, gb, aeWrap(gb)))
 		}
-		return nil
-	})
+		return op, rewrite.SameTree, nil
+	}
+	// the original aggregations of the query, such as the ones in a derived table or a subquery
+	// on the RHS of the join, have to return a row even when they have no input rows
+	notOriginalAggregation := func(op ops.Operator) rewrite.VisitRule {
+		aggr, isAggr := op.(*Aggregator)
+		return rewrite.VisitRule(!isAggr || !aggr.Original)
+	}
+
+	_, err := rewrite.TopDown(in.RHS, TableID, visitor, notOriginalAggregation)
+	if err != nil {
+		return nil, nil, err
+	}
 	return in, rewrite.SameTree, nil
 }
@@ -117,6 +117,8 @@ func runRewriters(ctx *plancontext.PlanningContext, root ops.Operator) (ops.Oper
 			return optimizeQueryGraph(ctx, in)
 		case *LockAndComment:
 			return pushLockAndComment(in)
+		case *RecurseCTE:
+			return tryMergeRecurseCTE(ctx, in)
 		default:
 			return in, rewrite.SameTree, nil
 		}
@@ -188,7 +190,8 @@ func pushOrExpandHorizon(ctx *plancontext.PlanningContext, in *Horizon) (ops.Ope
 		!needsOrdering &&
 		!qp.NeedsAggregation() &&
 		!in.selectStatement().IsDistinct() &&
-		in.selectStatement().GetLimit() == nil
+		in.selectStatement().GetLimit() == nil &&
+		!needsWindow(ctx, qp, rb)
 
 	if canPush {
 		return rewrite.Swap(in, rb, "push horizon into route")
@@ -514,6 +517,9 @@ func setUpperLimit(in *Limit) (ops.Operator, *rewrite.ApplyResult, error) {
 		case *Join, *ApplyJoin, *SubQueryContainer, *SubQuery:
 			// we can't push limits down on either side
 			return rewrite.SkipChildren
+		case *Window:
+			// the window functions need to see all the rows of their partitions
+			return rewrite.SkipChildren
 		case *Route:
 			newSrc := &Limit{
 				Source: op.Source,
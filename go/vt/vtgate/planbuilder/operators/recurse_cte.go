@@ -0,0 +1,556 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operators
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"vitess.io/vitess/go/slice"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/rewrite"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/semantics"
+)
+
+type (
+	// RecurseCTE is used to evaluate a recursive common table expression in vtgate.
+	// The Seed is run once, and the Term is then run once per row produced by the previous
+	// iteration, until no more rows are produced.
+	// Inside the Term, the reference to the CTE itself is represented by a CTERow operator.
+	RecurseCTE struct {
+		Seed, Term ops.Operator
+
+		// ID is the table set of the reference to the CTE that this operator produces rows for
+		ID  semantics.TableSet
+		CTE *sqlparser.CommonTableExpr
+
+		// Alias is the name used to reference the CTE in the outer query
+		Alias       string
+		ColumnNames []string
+		Columns     []*sqlparser.AliasedExpr
+
+		// Vars maps the bind variables used by the Term to the column offsets of the row from the previous iteration
+		Vars map[string]int
+
+		Distinct bool
+
+		// This is only filled in during offset planning
+		CheckCols []engine.CheckCol
+	}
+
+	// CTERow is the reference to a recursive CTE inside its own recursive part.
+	// It produces a single row, built from the bind variables that the RecurseCTE
+	// sets for every row of the previous iteration.
+	CTERow struct {
+		ID          semantics.TableSet
+		ColumnNames []string
+
+		// Vars are the bind variable names, one per column of the CTE
+		Vars []string
+
+		Columns []*sqlparser.AliasedExpr
+
+		// This is only filled in during offset planning
+		EExprs []evalengine.Expr
+
+		noInputs
+	}
+
+	// CTETable is used when a recursive CTE has been merged into a route.
+	// The whole CTE is sent to MySQL, and this operator is the reference to it from the outer query.
+	CTETable struct {
+		ID      semantics.TableSet
+		CTE     *sqlparser.CommonTableExpr
+		Alias   string
+		Columns []*sqlparser.ColName
+
+		noInputs
+	}
+)
+
+func createRecurseCTE(ctx *plancontext.PlanningContext, tableExpr *sqlparser.AliasedTableExpr, tableID semantics.TableSet, info *semantics.CTETable) (ops.Operator, error) {
+	union := info.Union()
+
+	seed, err := translateQueryToOp(ctx, union.Left)
+	if err != nil {
+		return nil, err
+	}
+	term, err := translateQueryToOp(ctx, union.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	var row *CTERow
+	_ = rewrite.Visit(term, func(op ops.Operator) error {
+		if r, ok := op.(*CTERow); ok {
+			row = r
+		}
+		return nil
+	})
+	if row == nil {
+		return nil, vterrors.VT13001("could not find the reference to the recursive CTE in its recursive part")
+	}
+
+	alias := tableExpr.As.String()
+	if alias == "" {
+		alias = info.CTE.ID.String()
+	}
+
+	vars := make(map[string]int, len(row.Vars))
+	for idx, bvName := range row.Vars {
+		vars[bvName] = idx
+	}
+
+	seedExprs := sqlparser.GetFirstSelect(union.Left).SelectExprs
+	var columns []*sqlparser.AliasedExpr
+	for idx, name := range info.ColumnNames() {
+		col := sqlparser.NewColNameWithQualifier(name, sqlparser.NewTableName(alias))
+		ctx.SemTable.Recursive[col] = tableID
+		ctx.SemTable.Direct[col] = tableID
+		if ae, ok := seedExprs[idx].(*sqlparser.AliasedExpr); ok {
+			if typ, found := ctx.SemTable.TypeForExpr(ae.Expr); found {
+				ctx.SemTable.ExprTypes[col] = typ
+			}
+		}
+		columns = append(columns, aeWrap(col))
+	}
+
+	return &RecurseCTE{
+		Seed: seed,
+		Term: term,
+		ID:   tableID,
+		// we keep a copy of the original CTE, so that we can send it to MySQL
+		// as is if the whole CTE can be merged into a single route
+		CTE:         sqlparser.CloneRefOfCommonTableExpr(info.CTE),
+		Alias:       alias,
+		ColumnNames: info.ColumnNames(),
+		Columns:     columns,
+		Vars:        vars,
+		Distinct:    union.Distinct,
+	}, nil
+}
+
+func createCTERow(ctx *plancontext.PlanningContext, tableExpr *sqlparser.AliasedTableExpr, tableID semantics.TableSet, info *semantics.CTETable) *CTERow {
+	alias := tableExpr.As.String()
+	if alias == "" {
+		alias = info.CTE.ID.String()
+	}
+	var vars []string
+	for _, name := range info.ColumnNames() {
+		col := sqlparser.NewColNameWithQualifier(name, sqlparser.NewTableName(alias))
+		vars = append(vars, ctx.ReservedVars.ReserveColName(col))
+	}
+	return &CTERow{
+		ID:          tableID,
+		ColumnNames: info.ColumnNames(),
+		Vars:        vars,
+	}
+}
+
+// Clone implements the Operator interface
+func (r *RecurseCTE) Clone(inputs []ops.Operator) ops.Operator {
+	klone := *r
+	klone.Seed = inputs[0]
+	klone.Term = inputs[1]
+	klone.Columns = slices.Clone(r.Columns)
+	klone.CheckCols = slices.Clone(r.CheckCols)
+	return &klone
+}
+
+// Inputs implements the Operator interface
+func (r *RecurseCTE) Inputs() []ops.Operator {
+	return []ops.Operator{r.Seed, r.Term}
+}
+
+// SetInputs implements the Operator interface
+func (r *RecurseCTE) SetInputs(operators []ops.Operator) {
+	r.Seed = operators[0]
+	r.Term = operators[1]
+}
+
+// AddPredicate implements the Operator interface.
+// Predicates can't be pushed into the CTE, since the rows that are filtered out could be needed by the recursion
+func (r *RecurseCTE) AddPredicate(_ *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	return newFilter(r, expr)
+}
+
+func (r *RecurseCTE) AddColumn(ctx *plancontext.PlanningContext, reuse bool, gb bool, expr *sqlparser.AliasedExpr) int {
+	if reuse {
+		offset := r.FindCol(ctx, expr.Expr, false)
+		if offset >= 0 {
+			return offset
+		}
+	}
+
+	switch e := expr.Expr.(type) {
+	case *sqlparser.ColName:
+		offset := slices.IndexFunc(r.ColumnNames, func(name string) bool {
+			return e.Name.EqualString(name)
+		})
+		if offset == -1 {
+			panic(vterrors.VT13001(fmt.Sprintf("could not find the column '%s' on the recursive CTE", sqlparser.String(e))))
+		}
+		return offset
+	default:
+		return r.addColumnToSeedAndTerm(ctx, gb, expr)
+	}
+}
+
+// addColumnToSeedAndTerm is used for expressions that are not plain columns of the CTE.
+// The seed and the term have to produce the same columns, so we rewrite the expression
+// to use the columns of each side, and add it to both of them
+func (r *RecurseCTE) addColumnToSeedAndTerm(ctx *plancontext.PlanningContext, gb bool, expr *sqlparser.AliasedExpr) int {
+	rewriteTo := func(columns []*sqlparser.AliasedExpr) *sqlparser.AliasedExpr {
+		rewritten := sqlparser.CopyOnRewrite(expr.Expr, nil, func(cursor *sqlparser.CopyOnWriteCursor) {
+			col, ok := cursor.Node().(*sqlparser.ColName)
+			if !ok {
+				return
+			}
+			offset := slices.IndexFunc(r.ColumnNames, func(name string) bool {
+				return col.Name.EqualString(name)
+			})
+			if offset == -1 {
+				panic(vterrors.VT13001(fmt.Sprintf("could not find the column '%s' on the recursive CTE", sqlparser.String(col))))
+			}
+			cursor.Replace(columns[offset].Expr)
+		}, ctx.SemTable.CopySemanticInfo).(sqlparser.Expr)
+		return aeWrap(rewritten)
+	}
+
+	seedOffset := r.Seed.AddColumn(ctx, false, gb, rewriteTo(r.Seed.GetColumns(ctx)))
+	termOffset := r.Term.AddColumn(ctx, false, gb, rewriteTo(r.Term.GetColumns(ctx)))
+	if seedOffset != termOffset || seedOffset != len(r.Columns) {
+		panic(vterrors.VT12001("column offsets did not line up for recursive CTE"))
+	}
+	r.Columns = append(r.Columns, expr)
+	return seedOffset
+}
+
+func (r *RecurseCTE) FindCol(ctx *plancontext.PlanningContext, expr sqlparser.Expr, _ bool) int {
+	for idx, col := range r.Columns {
+		if ctx.SemTable.EqualsExprWithDeps(expr, col.Expr) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (r *RecurseCTE) GetColumns(*plancontext.PlanningContext) []*sqlparser.AliasedExpr {
+	return r.Columns
+}
+
+func (r *RecurseCTE) GetSelectExprs(ctx *plancontext.PlanningContext) sqlparser.SelectExprs {
+	return transformColumnsToSelectExprs(ctx, r)
+}
+
+func (r *RecurseCTE) GetOrdering(*plancontext.PlanningContext) []ops.OrderBy {
+	return nil
+}
+
+func (r *RecurseCTE) introducesTableID() semantics.TableSet {
+	return r.ID
+}
+
+// NoLHSTableSet is implemented because the Term does not get any data from the Seed through the table set.
+// The rows flow from one iteration to the next using the CTERow operator
+func (r *RecurseCTE) NoLHSTableSet() {}
+
+func (r *RecurseCTE) planOffsets(ctx *plancontext.PlanningContext) {
+	if !r.Distinct {
+		return
+	}
+	r.CheckCols = nil
+	for idx := range r.ColumnNames {
+		typ, _ := ctx.SemTable.TypeForExpr(r.Columns[idx].Expr)
+		r.CheckCols = append(r.CheckCols, engine.CheckCol{
+			Col:  idx,
+			Type: typ,
+		})
+	}
+}
+
+func (r *RecurseCTE) ShortDescription() string {
+	desc := r.Alias
+	if r.Distinct {
+		desc += " DISTINCT"
+	}
+	return desc
+}
+
+// Clone implements the Operator interface
+func (c *CTERow) Clone([]ops.Operator) ops.Operator {
+	klone := *c
+	klone.Columns = slices.Clone(c.Columns)
+	klone.EExprs = slices.Clone(c.EExprs)
+	return &klone
+}
+
+// AddPredicate implements the Operator interface
+func (c *CTERow) AddPredicate(_ *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	return newFilter(c, expr)
+}
+
+func (c *CTERow) AddColumn(ctx *plancontext.PlanningContext, reuse bool, _ bool, expr *sqlparser.AliasedExpr) int {
+	if reuse {
+		offset := c.FindCol(ctx, expr.Expr, false)
+		if offset >= 0 {
+			return offset
+		}
+	}
+	c.Columns = append(c.Columns, expr)
+	return len(c.Columns) - 1
+}
+
+func (c *CTERow) FindCol(ctx *plancontext.PlanningContext, expr sqlparser.Expr, _ bool) int {
+	for idx, col := range c.Columns {
+		if ctx.SemTable.EqualsExprWithDeps(expr, col.Expr) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (c *CTERow) GetColumns(*plancontext.PlanningContext) []*sqlparser.AliasedExpr {
+	return c.Columns
+}
+
+func (c *CTERow) GetSelectExprs(ctx *plancontext.PlanningContext) sqlparser.SelectExprs {
+	return transformColumnsToSelectExprs(ctx, c)
+}
+
+func (c *CTERow) GetOrdering(*plancontext.PlanningContext) []ops.OrderBy {
+	return nil
+}
+
+func (c *CTERow) introducesTableID() semantics.TableSet {
+	return c.ID
+}
+
+// planOffsets turns the requested columns into evalengine expressions,
+// where the columns of the CTE are read from the bind variables
+func (c *CTERow) planOffsets(ctx *plancontext.PlanningContext) {
+	cfg := &evalengine.Config{
+		ResolveType: ctx.SemTable.TypeForExpr,
+		Collation:   ctx.SemTable.Collation,
+	}
+
+	c.EExprs = nil
+	for _, col := range c.Columns {
+		rewritten := sqlparser.CopyOnRewrite(col.Expr, nil, func(cursor *sqlparser.CopyOnWriteCursor) {
+			colName, ok := cursor.Node().(*sqlparser.ColName)
+			if !ok || ctx.SemTable.DirectDeps(colName) != c.ID {
+				return
+			}
+			idx := slices.IndexFunc(c.ColumnNames, func(name string) bool {
+				return colName.Name.EqualString(name)
+			})
+			if idx == -1 {
+				return
+			}
+			typ, _ := ctx.SemTable.TypeForExpr(colName)
+			cursor.Replace(sqlparser.NewTypedArgument(c.Vars[idx], typ.Type))
+		}, ctx.SemTable.CopySemanticInfo).(sqlparser.Expr)
+
+		eexpr, err := evalengine.Translate(rewritten, cfg)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), evalengine.ErrTranslateExprNotSupported) {
+				panic(vterrors.Errorf(vtrpcpb.Code_UNIMPLEMENTED, "%s: %s", evalengine.ErrTranslateExprNotSupported, sqlparser.String(col.Expr)))
+			}
+			panic(err)
+		}
+		c.EExprs = append(c.EExprs, eexpr)
+	}
+}
+
+func (c *CTERow) ShortDescription() string {
+	return strings.Join(c.Vars, ", ")
+}
+
+// Clone implements the Operator interface
+func (c *CTETable) Clone([]ops.Operator) ops.Operator {
+	var columns []*sqlparser.ColName
+	for _, name := range c.Columns {
+		columns = append(columns, sqlparser.CloneRefOfColName(name))
+	}
+	klone := *c
+	klone.Columns = columns
+	return &klone
+}
+
+// AddPredicate implements the Operator interface
+func (c *CTETable) AddPredicate(_ *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	return newFilter(c, expr)
+}
+
+func (c *CTETable) AddColumn(*plancontext.PlanningContext, bool, bool, *sqlparser.AliasedExpr) int {
+	panic(vterrors.VT13001("did not expect this method to be called"))
+}
+
+func (c *CTETable) FindCol(_ *plancontext.PlanningContext, expr sqlparser.Expr, _ bool) int {
+	colToFind, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return -1
+	}
+
+	for idx, colName := range c.Columns {
+		if colName.Name.Equal(colToFind.Name) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+func (c *CTETable) GetColumns(*plancontext.PlanningContext) []*sqlparser.AliasedExpr {
+	return slice.Map(c.Columns, colNameToExpr)
+}
+
+func (c *CTETable) GetSelectExprs(ctx *plancontext.PlanningContext) sqlparser.SelectExprs {
+	return transformColumnsToSelectExprs(ctx, c)
+}
+
+func (c *CTETable) GetOrdering(*plancontext.PlanningContext) []ops.OrderBy {
+	return nil
+}
+
+func (c *CTETable) GetColNames() []*sqlparser.ColName {
+	return c.Columns
+}
+
+func (c *CTETable) AddCol(col *sqlparser.ColName) {
+	c.Columns = append(c.Columns, col)
+}
+
+func (c *CTETable) introducesTableID() semantics.TableSet {
+	return c.ID
+}
+
+func (c *CTETable) ShortDescription() string {
+	if c.Alias == c.CTE.ID.String() {
+		return c.Alias
+	}
+	return c.CTE.ID.String() + " AS " + c.Alias
+}
+
+// tryMergeRecurseCTE checks if the whole recursive CTE can be sent to MySQL as is.
+// This is the case when the seed uses a single route, and the recursive part either only uses
+// the rows of the CTE, or uses a single route that is going to the same shard as the seed.
+func tryMergeRecurseCTE(ctx *plancontext.PlanningContext, in *RecurseCTE) (ops.Operator, *rewrite.ApplyResult, error) {
+	seed, ok := findSingleRoute(in.Seed)
+	if !ok || seed == nil {
+		return in, rewrite.SameTree, nil
+	}
+
+	termRoute, ok := findSingleRoute(in.Term)
+	if !ok {
+		return in, rewrite.SameTree, nil
+	}
+
+	routing := seed.Routing
+	mergedWith := []*Route{seed}
+	if termRoute != nil {
+		routing = mergeRecurseCTERouting(ctx, seed, termRoute)
+		if routing == nil {
+			return in, rewrite.SameTree, nil
+		}
+		mergedWith = append(mergedWith, termRoute)
+	}
+
+	// when the CTE is evaluated on multiple shards, every shard only sees its own rows.
+	// this is only correct if we don't have to remove duplicates between the shards
+	if in.Distinct && !(&Route{Routing: routing}).IsSingleShard() {
+		return in, rewrite.SameTree, nil
+	}
+
+	var columns []*sqlparser.ColName
+	for _, col := range in.Columns {
+		colName, ok := col.Expr.(*sqlparser.ColName)
+		if !ok {
+			// expressions have been pushed into the seed and the term - too late to merge now
+			return in, rewrite.SameTree, nil
+		}
+		columns = append(columns, colName)
+	}
+
+	route := &Route{
+		Source: &CTETable{
+			ID:      in.ID,
+			CTE:     in.CTE,
+			Alias:   in.Alias,
+			Columns: columns,
+		},
+		Routing:    routing,
+		MergedWith: mergedWith,
+	}
+	return route, rewrite.NewTree("merged recursive CTE into a single route", route), nil
+}
+
+// findSingleRoute returns the single route used by the seed or the recursive part of a CTE, if any.
+// ok is false if the operator tree contains operators that we can't send to MySQL as a part of the CTE,
+// or if it has not been fully planned yet
+func findSingleRoute(op ops.Operator) (route *Route, ok bool) {
+	switch op := op.(type) {
+	case *Route:
+		return op, true
+	case *CTERow:
+		return nil, true
+	case *ApplyJoin, *Filter, *Projection:
+		for _, input := range op.Inputs() {
+			r, ok := findSingleRoute(input)
+			if !ok || (r != nil && route != nil) {
+				return nil, false
+			}
+			if r != nil {
+				route = r
+			}
+		}
+		return route, true
+	default:
+		return nil, false
+	}
+}
+
+// mergeRecurseCTERouting returns the routing to use when merging the seed and the term of a recursive CTE
+// into a single route, or nil if they can't be merged.
+func mergeRecurseCTERouting(ctx *plancontext.PlanningContext, seed, term *Route) Routing {
+	_, _, routingA, routingB, a, b, sameKeyspace := prepareInputRoutes(seed, term)
+
+	switch {
+	case b == dual || (b == anyShard && sameKeyspace):
+		return routingA
+	case a == dual || (a == anyShard && sameKeyspace):
+		return routingB
+	case a == sharded && b == sharded && sameKeyspace:
+		tblA := routingA.(*ShardedRouting)
+		tblB := routingB.(*ShardedRouting)
+		if tblA.RouteOpCode != engine.EqualUnique || tblB.RouteOpCode != engine.EqualUnique {
+			return nil
+		}
+		if tblA.SelectedVindex() == tblB.SelectedVindex() &&
+			gen4ValuesEqual(ctx, tblA.VindexExpressions(), tblB.VindexExpressions()) {
+			return routingA
+		}
+	}
+	return nil
+}
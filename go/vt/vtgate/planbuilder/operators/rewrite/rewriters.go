@@ -229,7 +229,7 @@ func bottomUp(
 	childID := rootID
 
 	// noLHSTableSet is used to mark which operators that do not send data from the LHS to the RHS
-	// It's only UNION at this moment, but this package can't depend on the actual operators, so
+	// It's only UNION and recursive CTEs at this moment, but this package can't depend on the actual operators, so
 	// we use this interface to avoid direct dependencies
 	type noLHSTableSet interface{ NoLHSTableSet() }
 
@@ -56,6 +56,14 @@ func pushDerived(ctx *plancontext.PlanningContext, op *Horizon) (ops.Operator, *
 }
 
 func optimizeJoin(ctx *plancontext.PlanningContext, op *Join) (ops.Operator, *rewrite.ApplyResult, error) {
+	if !reachedPhase(ctx, initialPlanning) && (usesRecursiveCTE(op.LHS) || usesRecursiveCTE(op.RHS)) {
+		// a recursive CTE can only be merged into a route once its horizons have been planned,
+		// so we wait with the join until we know if the CTE is going to be a route or not
+		return op, rewrite.SameTree, nil
+	}
+	if op.Lateral != nil {
+		return optimizeLateralJoin(ctx, op)
+	}
 	return mergeOrJoin(ctx, op.LHS, op.RHS, sqlparser.SplitAndExpression(nil, op.Predicate), !op.LeftJoin)
 }
 
@@ -384,6 +392,17 @@ func mergeOrJoin(ctx *plancontext.PlanningContext, lhs, rhs ops.Operator, joinPr
 		return newOp, rewrite.NewTree("logical join to applyJoin, switching side because derived table", newOp), nil
 	}
 
+	if len(joinPredicates) > 0 && inner && usesRecursiveCTE(rhs) && !usesRecursiveCTE(lhs) {
+		// the recursive CTE can be evaluated on the RHS, but then it would be evaluated once per row of the LHS.
+		// by switching sides, we evaluate it once and send its values to the other side instead
+		join := NewApplyJoin(Clone(rhs), Clone(lhs), nil, false)
+		newOp, err := pushJoinPredicates(ctx, joinPredicates, join)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newOp, rewrite.NewTree("logical join to applyJoin, switching side because recursive CTE", newOp), nil
+	}
+
 	join := NewApplyJoin(Clone(lhs), Clone(rhs), nil, !inner)
 	newOp, err := pushJoinPredicates(ctx, joinPredicates, join)
 	if err != nil {
@@ -392,6 +411,20 @@ func mergeOrJoin(ctx *plancontext.PlanningContext, lhs, rhs ops.Operator, joinPr
 	return newOp, rewrite.NewTree("logical join to applyJoin ", newOp), nil
 }
 
+// usesRecursiveCTE returns true if the operator tree contains a recursive CTE that is evaluated in vtgate
+func usesRecursiveCTE(op ops.Operator) bool {
+	found := false
+	_ = rewrite.Visit(op, func(current ops.Operator) error {
+		switch current.(type) {
+		case *RecurseCTE, *CTERow:
+			found = true
+			return io.EOF
+		}
+		return nil
+	})
+	return found
+}
+
 func operatorsToRoutes(a, b ops.Operator) (*Route, *Route) {
 	aRoute, ok := a.(*Route)
 	if !ok {
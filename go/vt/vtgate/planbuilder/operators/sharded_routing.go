@@ -23,7 +23,6 @@ import (
 	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/slice"
 	"vitess.io/vitess/go/vt/sqlparser"
-	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/evalengine"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
@@ -609,7 +608,9 @@ func tryMergeJoinShardedRouting(
 		}
 
 		if !sameKeyspace {
-			panic(vterrors.VT12001("cross-shard correlated subquery"))
+			// routes to different keyspaces can't be merged, but the
+			// planner can still join them together in vtgate
+			return nil
 		}
 
 		canMerge := canMergeOnFilters(ctx, routeA, routeB, joinPredicates)
@@ -196,6 +196,9 @@ func (sq *SubQuery) GetMergePredicates() []sqlparser.Expr {
 	return sq.Predicates
 }
 
+// settle turns the subquery into an operator that can be executed, and returns the operator that replaces
+// both the subquery and the outer query. Most of the time this will be the SubQuery itself, but correlated
+// subqueries can also be replaced by a join between the outer and the inner query.
 func (sq *SubQuery) settle(ctx *plancontext.PlanningContext, outer ops.Operator) (ops.Operator, error) {
 	if !sq.TopLevel {
 		return nil, subqueryNotAtTopErr
@@ -203,23 +206,98 @@ func (sq *SubQuery) settle(ctx *plancontext.PlanningContext, outer ops.Operator)
 	if sq.IsProjection {
 		if len(sq.GetMergePredicates()) > 0 {
 			// this means that we have a correlated subquery on our hands
-			return nil, correlatedSubqueryErr
+			return sq.settleCorrelatedValue(ctx, outer)
 		}
 		sq.SubqueryValueName = sq.ArgName
-		return outer, nil
+		sq.Outer = outer
+		return sq, nil
 	}
 	return sq.settleFilter(ctx, outer)
 }
 
-var correlatedSubqueryErr = vterrors.VT12001("correlated subquery is only supported for EXISTS")
+var correlatedSubqueryErr = vterrors.VT12001("correlated subquery is only supported for EXISTS, IN and subqueries returning a single row")
 var subqueryNotAtTopErr = vterrors.VT12001("unmergable subquery can not be inside complex expression")
 
+// settleCorrelatedValue plans a correlated subquery returning a single value as an outer join between the
+// outer and the inner query. For every row of the outer query, the inner query is executed using the
+// values it needs from the outer row as bind variables. If the inner query returns no rows, the value is NULL.
+func (sq *SubQuery) settleCorrelatedValue(ctx *plancontext.PlanningContext, outer ops.Operator) (*ApplyJoin, error) {
+	if sq.FilterType != opcode.PulloutValue || !sq.returnsAtMostOneRow() {
+		return nil, correlatedSubqueryErr
+	}
+	joinColumns, err := sq.GetJoinColumns(ctx, outer)
+	if err != nil {
+		return nil, err
+	}
+
+	join := NewApplyJoin(outer, sq.Subquery, ctx.SemTable.AndExpressions(sq.Predicates...), true)
+	join.JoinPredicates = joinColumns
+	// the value of the subquery is exposed as a column up front,
+	// so the operators above can find it when planning offsets
+	join.AddColumn(ctx, true, false, aeWrap(sq.valueExpr()))
+	return join, nil
+}
+
+// returnsAtMostOneRow returns true if the subquery is a SELECT that is known to not return more than one row.
+// This is the case for aggregations without grouping, and for queries using LIMIT 1
+func (sq *SubQuery) returnsAtMostOneRow() bool {
+	sel, ok := sq.originalSubquery.Select.(*sqlparser.Select)
+	if !ok || len(sel.SelectExprs) != 1 {
+		return false
+	}
+	if _, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr); !ok {
+		return false
+	}
+	if len(sel.GroupBy) == 0 && sqlparser.ContainsAggregation(sel.SelectExprs) {
+		return true
+	}
+	if sel.Limit == nil {
+		return false
+	}
+	lit, ok := sel.Limit.Rowcount.(*sqlparser.Literal)
+	return ok && lit.Type == sqlparser.IntVal && (lit.Val == "0" || lit.Val == "1")
+}
+
+// valueExpr returns the expression that the subquery is selecting
+func (sq *SubQuery) valueExpr() sqlparser.Expr {
+	sel := sqlparser.GetFirstSelect(sq.originalSubquery.Select)
+	return sel.SelectExprs[0].(*sqlparser.AliasedExpr).Expr
+}
+
+// isJoined returns true if the subquery has been turned into a join with the outer query
+func (sq *SubQuery) isJoined(ctx *plancontext.PlanningContext) bool {
+	return sq.IsProjection && len(sq.GetMergePredicates()) > 0 && !sq.isMerged(ctx)
+}
+
 func (sq *SubQuery) settleFilter(ctx *plancontext.PlanningContext, outer ops.Operator) (ops.Operator, error) {
 	if len(sq.Predicates) > 0 {
-		if sq.FilterType != opcode.PulloutExists {
+		switch sq.FilterType {
+		case opcode.PulloutExists:
+		case opcode.PulloutIn:
+			if err := sq.settleCorrelatedIn(ctx, outer); err != nil {
+				return nil, err
+			}
+		case opcode.PulloutValue:
+			join, err := sq.settleCorrelatedValue(ctx, outer)
+			if err != nil {
+				return nil, err
+			}
+			// the semantic info of the subquery is not copied over to the value, since they have different dependencies
+			valueExpr := sq.valueExpr()
+			pred := sqlparser.CopyOnRewrite(sq.Original, dontEnterSubqueries, func(cursor *sqlparser.CopyOnWriteCursor) {
+				if _, ok := cursor.Node().(*sqlparser.Subquery); ok {
+					cursor.Replace(valueExpr)
+				}
+			}, nil).(sqlparser.Expr)
+			return &Filter{
+				Source:     join,
+				Predicates: []sqlparser.Expr{pred},
+			}, nil
+		default:
 			return nil, correlatedSubqueryErr
 		}
-		return outer, nil
+		sq.Outer = outer
+		return sq, nil
 	}
 
 	hasValuesArg := func() string {
@@ -260,10 +338,65 @@ func (sq *SubQuery) settleFilter(ctx *plancontext.PlanningContext, outer ops.Ope
 		predicates = append(predicates, rhsPred)
 		sq.SubqueryValueName = sq.ArgName
 	}
-	return &Filter{
+	sq.Outer = &Filter{
 		Source:     outer,
 		Predicates: predicates,
-	}, nil
+	}
+	return sq, nil
+}
+
+// settleCorrelatedIn turns `expr IN (SELECT col FROM ...)` into an EXISTS subquery, by adding the predicate
+// `col = expr` to the subquery. Correlated EXISTS subqueries are executed using a semi-join.
+func (sq *SubQuery) settleCorrelatedIn(ctx *plancontext.PlanningContext, outer ops.Operator) error {
+	cmp, ok := sq.Original.(*sqlparser.ComparisonExpr)
+	if !ok || cmp.Operator != sqlparser.InOp || !sq.canFilterInner() {
+		return correlatedSubqueryErr
+	}
+
+	sq.FilterType = opcode.PulloutExists
+	pred := sqlparser.NewComparisonExpr(sqlparser.EqualOp, cmp.Left, sq.valueExpr(), nil)
+	if slices.ContainsFunc(sq.Predicates, func(expr sqlparser.Expr) bool {
+		return sameComparison(ctx, pred, expr)
+	}) {
+		// the subquery is already comparing these two expressions
+		return nil
+	}
+
+	col, err := BreakExpressionInLHSandRHS(ctx, pred, TableID(outer))
+	if err != nil {
+		return err
+	}
+	sq.Subquery = &Filter{
+		Source:     sq.Subquery,
+		Predicates: []sqlparser.Expr{col.RHSExpr},
+	}
+	sq.Predicates = append(sq.Predicates, pred)
+	sq.JoinColumns = nil
+	return nil
+}
+
+// sameComparison returns true if both expressions are comparisons between the same two expressions
+func sameComparison(ctx *plancontext.PlanningContext, a *sqlparser.ComparisonExpr, other sqlparser.Expr) bool {
+	b, ok := other.(*sqlparser.ComparisonExpr)
+	if !ok || b.Operator != a.Operator {
+		return false
+	}
+	eq := ctx.SemTable.EqualsExprWithDeps
+	return eq(a.Left, b.Left) && eq(a.Right, b.Right) ||
+		eq(a.Left, b.Right) && eq(a.Right, b.Left)
+}
+
+// canFilterInner returns true if a predicate on the selected column can be added to the subquery
+// without changing which rows it would return
+func (sq *SubQuery) canFilterInner() bool {
+	sel, ok := sq.originalSubquery.Select.(*sqlparser.Select)
+	if !ok || len(sel.SelectExprs) != 1 || sel.Limit != nil || len(sel.GroupBy) > 0 {
+		return false
+	}
+	if _, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr); !ok {
+		return false
+	}
+	return !sqlparser.ContainsAggregation(sel.SelectExprs) && !sqlparser.ContainsAggregation(sel.Having)
 }
 
 func dontEnterSubqueries(node, _ sqlparser.SQLNode) bool {
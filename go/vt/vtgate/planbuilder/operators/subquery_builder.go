@@ -186,6 +186,9 @@ func createSubquery(
 	original = cloneASTAndSemState(ctx, original)
 	originalSq := cloneASTAndSemState(ctx, subq)
 	subqID := findTablesContained(ctx, subq.Select)
+	// when subqueries are nested, the outer tables we get from the parent
+	// subquery also contain the tables of this subquery
+	outerID = outerID.Remove(subqID)
 	totalID := subqID.Merge(outerID)
 	sqc := &SubQueryBuilder{totalID: totalID, subqID: subqID, outerID: outerID}
 
@@ -80,12 +80,16 @@ func settleSubqueries(ctx *plancontext.PlanningContext, op ops.Operator) ops.Ope
 		case *SubQueryContainer:
 			outer := op.Outer
 			for _, subq := range op.Inner {
+				if !subq.IsProjection && len(subq.Predicates) > 0 && isDMLRoute(outer) {
+					// correlated subqueries that can't be merged with a DML are evaluated by selecting
+					// the rows to modify first, which needs the primary key of the table
+					return nil, nil, vterrors.VT09015()
+				}
 				newOuter, err := subq.settle(ctx, outer)
 				if err != nil {
 					return nil, nil, err
 				}
-				subq.Outer = newOuter
-				outer = subq
+				outer = newOuter
 			}
 			return outer, rewrite.NewTree("extracted subqueries from subquery container", outer), nil
 		case *Projection:
@@ -99,6 +103,11 @@ func settleSubqueries(ctx *plancontext.PlanningContext, op ops.Operator) ops.Ope
 			}
 		case *Update:
 			for _, setExpr := range op.Assignments {
+				if se, ok := setExpr.Expr.Info.(SubQueryExpression); ok && slices.ContainsFunc(se, func(sq *SubQuery) bool {
+					return sq.isJoined(ctx)
+				}) {
+					return nil, nil, correlatedSubqueryErr
+				}
 				mergeSubqueryExpr(ctx, setExpr.Expr)
 			}
 		}
@@ -111,6 +120,25 @@ func settleSubqueries(ctx *plancontext.PlanningContext, op ops.Operator) ops.Ope
 	return op
 }
 
+// isDMLRoute returns true if the operator is a route sending an UPDATE or a DELETE
+func isDMLRoute(op ops.Operator) bool {
+	route, ok := op.(*Route)
+	if !ok {
+		return false
+	}
+	for src := route.Source; ; {
+		switch src.(type) {
+		case *Update, *Delete:
+			return true
+		}
+		inputs := src.Inputs()
+		if len(inputs) != 1 {
+			return false
+		}
+		src = inputs[0]
+	}
+}
+
 func mergeSubqueryExpr(ctx *plancontext.PlanningContext, pe *ProjExpr) {
 	se, ok := pe.Info.(SubQueryExpression)
 	if !ok {
@@ -120,6 +148,32 @@ func mergeSubqueryExpr(ctx *plancontext.PlanningContext, pe *ProjExpr) {
 	if rewritten {
 		pe.EvalExpr = newExpr
 	}
+	pe.EvalExpr = rewriteJoinedSubqueryExpr(ctx, se, pe.EvalExpr)
+}
+
+// rewriteJoinedSubqueryExpr replaces the arguments of subqueries that have been turned into joins
+// with the expression selected by the subquery, which is a column produced by the join
+func rewriteJoinedSubqueryExpr(ctx *plancontext.PlanningContext, se SubQueryExpression, expr sqlparser.Expr) sqlparser.Expr {
+	for _, sq := range se {
+		if !sq.isJoined(ctx) {
+			continue
+		}
+		valueExpr := sq.valueExpr()
+		expr = sqlparser.Rewrite(expr, nil, func(cursor *sqlparser.Cursor) bool {
+			switch node := cursor.Node().(type) {
+			case *sqlparser.ColName:
+				if node.Name.String() == sq.ArgName {
+					cursor.Replace(valueExpr)
+				}
+			case *sqlparser.Argument:
+				if node.Name == sq.ArgName {
+					cursor.Replace(valueExpr)
+				}
+			}
+			return true
+		}).(sqlparser.Expr)
+	}
+	return expr
 }
 
 func rewriteMergedSubqueryExpr(ctx *plancontext.PlanningContext, se SubQueryExpression, expr sqlparser.Expr) (sqlparser.Expr, bool) {
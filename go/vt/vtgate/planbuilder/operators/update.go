@@ -22,9 +22,11 @@ import (
 	"slices"
 	"strings"
 
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
 	"vitess.io/vitess/go/vt/vtgate/semantics"
@@ -98,6 +100,10 @@ func (u *Update) ShortDescription() string {
 }
 
 func createOperatorFromUpdate(ctx *plancontext.PlanningContext, updStmt *sqlparser.Update) (ops.Operator, error) {
+	if _, isAliased := updStmt.TableExprs[0].(*sqlparser.AliasedTableExpr); !isAliased || len(updStmt.TableExprs) > 1 {
+		return createMultiTableUpdateOperator(ctx, updStmt)
+	}
+
 	tableInfo, qt, err := createQueryTableForDML(ctx, updStmt.TableExprs[0], updStmt.Where)
 	if err != nil {
 		return nil, err
@@ -108,21 +114,22 @@ func createOperatorFromUpdate(ctx *plancontext.PlanningContext, updStmt *sqlpars
 		return nil, err
 	}
 
+	parentFks := ctx.SemTable.GetParentForeignKeysList()
+	childFks := ctx.SemTable.GetChildForeignKeysList()
+
 	updClone := sqlparser.CloneRefOfUpdate(updStmt)
 	updOp, err := createUpdateOperator(ctx, updStmt, vindexTable, qt, routing)
 	if err != nil {
 		return nil, err
 	}
 
-	parentFks := ctx.SemTable.GetParentForeignKeysList()
-	childFks := ctx.SemTable.GetChildForeignKeysList()
-	if len(childFks) == 0 && len(parentFks) == 0 {
+	if _, isDMLWithInput := updOp.(*DMLWithInput); isDMLWithInput {
+		// the foreign keys are handled by the update planned by the DMLWithInput
 		return updOp, nil
 	}
 
-	// If the delete statement has a limit, we don't support it yet.
-	if updStmt.Limit != nil {
-		return nil, vterrors.VT12001("update with limit with foreign key constraints")
+	if len(childFks) == 0 && len(parentFks) == 0 {
+		return updOp, nil
 	}
 
 	return buildFkOperator(ctx, updOp, updClone, parentFks, childFks, vindexTable)
@@ -159,22 +166,50 @@ func createUpdateOperator(ctx *plancontext.PlanningContext, updStmt *sqlparser.U
 		tr.VindexPreds = vp
 	}
 
+	// handling the subqueries rewrites them, so we keep the original where clause to select the rows with
+	where := sqlparser.CloneRefOfWhere(updStmt.Where)
+	var predicates []sqlparser.Expr
+	correlated := false
 	for _, predicate := range qt.Predicates {
 		if subq, err := sqc.handleSubquery(ctx, predicate, qt.ID); err != nil {
 			return nil, err
 		} else if subq != nil {
+			correlated = correlated || len(subq.Predicates) > 0
 			continue
 		}
+		predicates = append(predicates, predicate)
 		routing, err = UpdateRoutingLogic(ctx, predicate, routing)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if routing.OpCode() == engine.Scatter && updStmt.Limit != nil {
-		// TODO systay: we should probably check for other op code types - IN could also hit multiple shards (2022-04-07)
-		return nil, vterrors.VT12001("multi shard UPDATE with LIMIT")
+	target := dmlTarget{
+		vTbl: vindexTable,
+		dml: func(where *sqlparser.Where) sqlparser.Statement {
+			return &sqlparser.Update{
+				Comments:   updStmt.Comments,
+				Ignore:     updStmt.Ignore,
+				TableExprs: sqlparser.CloneTableExprs(updStmt.TableExprs),
+				Exprs:      sqlparser.CloneUpdateExprs(updStmt.Exprs),
+				Where:      where,
+			}
+		},
+	}
+	// The foreign key cascades and verifications need to know which rows are updated,
+	// so with a LIMIT they are done by the update of the selected rows.
+	fkPlanNeeded := len(ctx.SemTable.GetChildForeignKeysList()) > 0 || len(ctx.SemTable.GetParentForeignKeysList()) > 0
+	if needsDMLWithInput(routing, updStmt.Limit) || (fkPlanNeeded && updStmt.Limit != nil) {
+		return createDMLWithInput(ctx, []dmlTarget{target}, updStmt.TableExprs, where, updStmt.OrderBy, updStmt.Limit, updStmt.Comments)
 	}
+	if correlated && vindexTable.Keyspace.Sharded {
+		op, err := createDMLWithInputForSubqueries(ctx, target, updStmt.TableExprs, where, updStmt.Comments)
+		if err != nil || op != nil {
+			return op, err
+		}
+	}
+	// The predicates using subqueries are added back when the subqueries are merged or settled
+	qt.Predicates = predicates
 
 	route := &Route{
 		Source: &Update{
@@ -202,10 +237,82 @@ func createUpdateOperator(ctx *plancontext.PlanningContext, updStmt *sqlparser.U
 	return sqc.getRootOperator(route, decorator), nil
 }
 
+// createMultiTableUpdateOperator plans an UPDATE with more than one table in its table expressions.
+// The join is evaluated by the selection of a DMLWithInput, and each of the updated tables gets an UPDATE
+// with its own SET expressions on its primary key, which takes care of the changed vindexes.
+func createMultiTableUpdateOperator(ctx *plancontext.PlanningContext, updStmt *sqlparser.Update) (ops.Operator, error) {
+	if ctx.SemTable.ForeignKeysPresent() {
+		return nil, vterrors.VT12001("foreign keys management at vitess with multi-table UPDATE")
+	}
+	if updStmt.Limit != nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "Incorrect usage of UPDATE and LIMIT")
+	}
+	if len(updStmt.OrderBy) > 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "Incorrect usage of UPDATE and ORDER BY")
+	}
+
+	aliasedTables := getAliasedTables(updStmt.TableExprs)
+	var tableIDs []semantics.TableSet
+	var tableExprs []*sqlparser.AliasedTableExpr
+	var updExprs []sqlparser.UpdateExprs
+	for _, updExpr := range updStmt.Exprs {
+		tableID := ctx.SemTable.DirectDeps(updExpr.Name)
+		if !ctx.SemTable.RecursiveDeps(updExpr.Expr).IsSolvedBy(tableID) {
+			return nil, vterrors.VT12001("multi-table UPDATE with SET expressions that reference other tables")
+		}
+		idx := slices.Index(tableIDs, tableID)
+		if idx < 0 {
+			idx = slices.IndexFunc(aliasedTables, func(tblExpr *sqlparser.AliasedTableExpr) bool {
+				return ctx.SemTable.TableSetFor(tblExpr) == tableID
+			})
+			if idx < 0 {
+				return nil, vterrors.VT13001(fmt.Sprintf("could not find the table of the updated column %s", sqlparser.String(updExpr.Name)))
+			}
+			tableExprs = append(tableExprs, aliasedTables[idx])
+			tableIDs = append(tableIDs, tableID)
+			updExprs = append(updExprs, nil)
+			idx = len(tableIDs) - 1
+		}
+		updExprs[idx] = append(updExprs[idx], updExpr)
+	}
+
+	var targets []dmlTarget
+	for idx, tblExpr := range tableExprs {
+		tableInfo, err := ctx.SemTable.TableInfoFor(tableIDs[idx])
+		if err != nil {
+			return nil, err
+		}
+		vTbl := tableInfo.GetVindexTable()
+		if _, isTable := tblExpr.Expr.(sqlparser.TableName); !isTable || vTbl == nil {
+			return nil, &semantics.TableNotUpdatableError{Table: tblExpr.As.String()}
+		}
+		qualifier, err := tblExpr.TableName()
+		if err != nil {
+			return nil, err
+		}
+		tblExpr, exprs := sqlparser.CloneRefOfAliasedTableExpr(tblExpr), updExprs[idx]
+		targets = append(targets, dmlTarget{
+			vTbl:      vTbl,
+			qualifier: qualifier,
+			dml: func(where *sqlparser.Where) sqlparser.Statement {
+				return &sqlparser.Update{
+					Comments:   updStmt.Comments,
+					Ignore:     updStmt.Ignore,
+					TableExprs: sqlparser.TableExprs{tblExpr},
+					Exprs:      sqlparser.CloneUpdateExprs(exprs),
+					Where:      where,
+				}
+			},
+		})
+	}
+
+	return createDMLWithInput(ctx, targets, updStmt.TableExprs, updStmt.Where, nil, nil, updStmt.Comments)
+}
+
 func buildFkOperator(ctx *plancontext.PlanningContext, updOp ops.Operator, updClone *sqlparser.Update, parentFks []vindexes.ParentFKInfo, childFks []vindexes.ChildFKInfo, updatedTable *vindexes.Table) (ops.Operator, error) {
-	// We only support simple expressions in update queries for foreign key handling.
-	if isNonLiteral(updClone.Exprs, parentFks, childFks) {
-		return nil, vterrors.VT12001("update expression with non-literal values with foreign key constraints")
+	// The new values of non-literal update expressions are computed separately from the update query itself.
+	if err := checkNonLiteralUpdateExprs(updClone.Exprs, parentFks, childFks); err != nil {
+		return nil, err
 	}
 
 	restrictChildFks, cascadeChildFks := splitChildFks(childFks)
@@ -218,23 +325,48 @@ func buildFkOperator(ctx *plancontext.PlanningContext, updOp ops.Operator, updCl
 	return createFKVerifyOp(ctx, op, updClone, parentFks, restrictChildFks)
 }
 
-func isNonLiteral(updExprs sqlparser.UpdateExprs, parentFks []vindexes.ParentFKInfo, childFks []vindexes.ChildFKInfo) bool {
-	for _, updateExpr := range updExprs {
-		if sqlparser.IsLiteral(updateExpr.Expr) {
+// checkNonLiteralUpdateExprs verifies that the non-literal update expressions on foreign key columns
+// evaluate to the same value when computed for the foreign key handling as they do in the update query.
+func checkNonLiteralUpdateExprs(updExprs sqlparser.UpdateExprs, parentFks []vindexes.ParentFKInfo, childFks []vindexes.ChildFKInfo) error {
+	for idx, updateExpr := range updExprs {
+		if sqlparser.IsLiteral(updateExpr.Expr) || !isForeignKeyColumn(updateExpr.Name.Name, parentFks, childFks) {
 			continue
 		}
-		for _, parentFk := range parentFks {
-			if parentFk.ChildColumns.FindColumn(updateExpr.Name.Name) >= 0 {
-				return true
+		var err error
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			switch node := node.(type) {
+			case *sqlparser.ColName:
+				// MySQL uses the new value of a column updated by an earlier update expression.
+				for _, prevExpr := range updExprs[:idx] {
+					if prevExpr.Name.Name.Equal(node.Name) {
+						err = vterrors.VT12001(fmt.Sprintf("update expression on foreign key column %s using the updated column %s", updateExpr.Name.Name.String(), node.Name.String()))
+					}
+				}
+			case *sqlparser.CurTimeFuncExpr:
+				err = vterrors.VT12001(fmt.Sprintf("non-deterministic update expression on foreign key column %s", updateExpr.Name.Name.String()))
+			case *sqlparser.FuncExpr:
+				switch node.Name.Lowered() {
+				case "rand", "uuid", "uuid_short", "uuid_to_bin", "sysdate", "unix_timestamp", "utc_timestamp", "utc_date", "utc_time", "curdate", "current_date", "curtime", "current_time", "connection_id", "random_bytes", "sleep":
+					err = vterrors.VT12001(fmt.Sprintf("non-deterministic update expression on foreign key column %s", updateExpr.Name.Name.String()))
+				}
 			}
+			return err == nil, nil
+		}, updateExpr.Expr)
+		if err != nil {
+			return err
 		}
-		for _, childFk := range childFks {
-			if childFk.ParentColumns.FindColumn(updateExpr.Name.Name) >= 0 {
-				return true
-			}
+	}
+	return nil
+}
+
+// isForeignKeyColumn returns true if the column is used by any of the foreign keys of the updated table.
+func isForeignKeyColumn(col sqlparser.IdentifierCI, parentFks []vindexes.ParentFKInfo, childFks []vindexes.ChildFKInfo) bool {
+	for _, parentFk := range parentFks {
+		if parentFk.ChildColumns.FindColumn(col) >= 0 {
+			return true
 		}
 	}
-	return false
+	return isParentColumn(childFks, col)
 }
 
 // splitChildFks splits the child foreign keys into restrict and cascade list as restrict is handled through Verify operator and cascade is handled through Cascade operator.
@@ -263,6 +395,7 @@ func createFKCascadeOp(ctx *plancontext.PlanningContext, parentOp ops.Operator,
 
 	var fkChildren []*FkChild
 	var selectExprs []sqlparser.SelectExpr
+	var childCols [][]int
 
 	for _, fk := range childFks {
 		// We should have already filtered out update restrict foreign keys.
@@ -273,8 +406,12 @@ func createFKCascadeOp(ctx *plancontext.PlanningContext, parentOp ops.Operator,
 		// We need to select all the parent columns for the foreign key constraint, to use in the update of the child table.
 		cols, exprs := selectParentColumns(fk, len(selectExprs))
 		selectExprs = append(selectExprs, exprs...)
+		childCols = append(childCols, cols)
+	}
 
-		fkChild, err := createFkChildForUpdate(ctx, fk, updStmt, cols, updatedTable)
+	childUpdStmt, nonLiteralInfo, selectExprs := createNonLiteralUpdateInfo(ctx, updStmt, childFks, selectExprs)
+	for idx, fk := range childFks {
+		fkChild, err := createFkChildForUpdate(ctx, fk, childUpdStmt, childCols[idx], updatedTable)
 		if err != nil {
 			return nil, err
 		}
@@ -287,12 +424,76 @@ func createFKCascadeOp(ctx *plancontext.PlanningContext, parentOp ops.Operator,
 	}
 
 	return &FkCascade{
-		Selection: selectionOp,
-		Children:  fkChildren,
-		Parent:    parentOp,
+		Selection:      selectionOp,
+		Children:       fkChildren,
+		Parent:         parentOp,
+		NonLiteralInfo: nonLiteralInfo,
 	}, nil
 }
 
+// createNonLiteralUpdateInfo handles the update expressions that set the parent columns of the foreign keys to non-literal values.
+// The new values depend on the row being updated, so they are computed for each row found by the selection,
+// by vtgate when the evalengine supports the expression, and by MySQL in the selection otherwise.
+// It returns the update statement to build the children with, where these expressions are replaced by the bind variables holding the new values,
+// and the select expressions of the selection, with the columns needed to compute the new values.
+func createNonLiteralUpdateInfo(
+	ctx *plancontext.PlanningContext,
+	updStmt *sqlparser.Update,
+	childFks []vindexes.ChildFKInfo,
+	selectExprs []sqlparser.SelectExpr,
+) (*sqlparser.Update, []engine.NonLiteralUpdateInfo, []sqlparser.SelectExpr) {
+	var nonLiteralInfo []engine.NonLiteralUpdateInfo
+	childUpdStmt := updStmt
+	for idx, updateExpr := range updStmt.Exprs {
+		if sqlparser.IsLiteral(updateExpr.Expr) || !isParentColumn(childFks, updateExpr.Name.Name) {
+			continue
+		}
+		if childUpdStmt == updStmt {
+			childUpdStmt = sqlparser.CloneRefOfUpdate(updStmt)
+		}
+
+		info := engine.NonLiteralUpdateInfo{
+			UpdateExprBvName: ctx.ReservedVars.ReserveVariable(foreignKeyUpdateExpr),
+		}
+		var colExprs []sqlparser.SelectExpr
+		expr, err := evalengine.Translate(updateExpr.Expr, &evalengine.Config{
+			Collation:   ctx.SemTable.Collation,
+			ResolveType: ctx.SemTable.TypeForExpr,
+			ResolveColumn: func(col *sqlparser.ColName) (int, error) {
+				// the columns used by the expression are read from the selection
+				allExprs := append(selectExprs[:len(selectExprs):len(selectExprs)], colExprs...)
+				for offset, selectExpr := range allExprs {
+					if selectedCol, ok := selectExpr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName); ok && selectedCol.Name.Equal(col.Name) {
+						return offset, nil
+					}
+				}
+				colExprs = append(colExprs, aeWrap(sqlparser.NewColName(col.Name.String())))
+				return len(allExprs), nil
+			},
+		})
+		if err == nil {
+			info.UpdateExpr = expr
+			selectExprs = append(selectExprs, colExprs...)
+		} else {
+			info.UpdateExprCol = len(selectExprs)
+			selectExprs = append(selectExprs, aeWrap(sqlparser.CloneExpr(updateExpr.Expr)))
+		}
+		childUpdStmt.Exprs[idx].Expr = sqlparser.NewArgument(info.UpdateExprBvName)
+		nonLiteralInfo = append(nonLiteralInfo, info)
+	}
+	return childUpdStmt, nonLiteralInfo, selectExprs
+}
+
+// isParentColumn returns true if the column is one of the parent columns of the foreign keys.
+func isParentColumn(childFks []vindexes.ChildFKInfo, col sqlparser.IdentifierCI) bool {
+	for _, fk := range childFks {
+		if fk.ParentColumns.FindColumn(col) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // createFkChildForUpdate creates the update query operator for the child table based on the foreign key constraints.
 func createFkChildForUpdate(ctx *plancontext.PlanningContext, fk vindexes.ChildFKInfo, updStmt *sqlparser.Update, cols []int, updatedTable *vindexes.Table) (*FkChild, error) {
 	// Create a ValTuple of child column names
@@ -491,10 +692,21 @@ func createFkVerifyOpForParentFKForUpdate(ctx *plancontext.PlanningContext, updS
 				Right:    sqlparser.NewColNameWithQualifier(pFK.ChildColumns[idx].String(), childTbl),
 			}
 		} else {
+			newValue := prefixColNames(childTbl, sqlparser.CloneExpr(matchedExpr.Expr))
 			joinExpr = &sqlparser.ComparisonExpr{
 				Operator: sqlparser.EqualOp,
 				Left:     sqlparser.NewColNameWithQualifier(pFK.ParentColumns[idx].String(), parentTbl),
-				Right:    prefixColNames(childTbl, matchedExpr.Expr),
+				Right:    newValue,
+			}
+			if !sqlparser.IsLiteral(matchedExpr.Expr) {
+				// a non-literal value can turn out to be NULL, which doesn't need a row in the parent table
+				predicate = &sqlparser.AndExpr{
+					Left: parentIsNullExpr,
+					Right: &sqlparser.IsExpr{
+						Left:  sqlparser.CloneExpr(newValue),
+						Right: sqlparser.IsNotNullOp,
+					},
+				}
 			}
 		}
 
@@ -573,7 +785,18 @@ func createFkVerifyOpForChildFKForUpdate(ctx *plancontext.PlanningContext, updSt
 	// For example, if we are setting `update child cola = :v1 and colb = :v2`, then on the parent, the where condition would look something like this -
 	// `:v1 IS NULL OR :v2 IS NULL OR (cola, colb) NOT IN ((:v1,:v2))`
 	// So, if either of :v1 or :v2 is NULL, then the entire condition is true (which is the same as not having the condition when :v1 or :v2 is NULL).
-	compExpr := nullSafeNotInComparison(updStmt.Exprs, cFk)
+	// Non-literal values use the columns of the parent table, so we qualify them in the join.
+	updExprs := make(sqlparser.UpdateExprs, 0, len(updStmt.Exprs))
+	for _, updateExpr := range updStmt.Exprs {
+		if !sqlparser.IsLiteral(updateExpr.Expr) {
+			updateExpr = &sqlparser.UpdateExpr{
+				Name: updateExpr.Name,
+				Expr: prefixColNames(parentTbl, sqlparser.CloneExpr(updateExpr.Expr)),
+			}
+		}
+		updExprs = append(updExprs, updateExpr)
+	}
+	compExpr := nullSafeNotInComparison(updExprs, cFk)
 	if compExpr != nil {
 		whereCond = sqlparser.AndExpressions(whereCond, compExpr)
 	}
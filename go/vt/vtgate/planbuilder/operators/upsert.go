@@ -0,0 +1,222 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operators
+
+import (
+	"fmt"
+	"slices"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/semantics"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+// Upsert is used to represent INSERT ... ON DUPLICATE KEY UPDATE and REPLACE statements
+// on tables with foreign keys managed by Vitess. Every row of the statement is planned
+// as an insert, and as the update to run instead when the row already exists.
+// For REPLACE, the update is a delete of the existing row, and the insert is run again after it.
+// The update and delete are planned as separate statements, so they do their own foreign key cascades and verifications.
+type Upsert struct {
+	Sources  []UpsertSource
+	Reinsert bool
+
+	noColumns
+	noPredicates
+}
+
+// UpsertSource is the insert and the update of a single row of an Upsert
+type UpsertSource struct {
+	Insert ops.Operator
+	Update ops.Operator
+
+	// The Insert and the Update are planned as separate statements, and these are the semantic tables they were planned with
+	InsertSemTable *semantics.SemTable
+	UpdateSemTable *semantics.SemTable
+}
+
+var _ ops.Operator = (*Upsert)(nil)
+
+// Inputs implements the Operator interface
+func (u *Upsert) Inputs() []ops.Operator {
+	var inputs []ops.Operator
+	for _, source := range u.Sources {
+		inputs = append(inputs, source.Insert, source.Update)
+	}
+	return inputs
+}
+
+// SetInputs implements the Operator interface
+func (u *Upsert) SetInputs(inputs []ops.Operator) {
+	if len(inputs) != 2*len(u.Sources) {
+		panic("incorrect count of inputs for Upsert")
+	}
+	for idx := range u.Sources {
+		u.Sources[idx].Insert = inputs[2*idx]
+		u.Sources[idx].Update = inputs[2*idx+1]
+	}
+}
+
+// Clone implements the Operator interface
+func (u *Upsert) Clone(inputs []ops.Operator) ops.Operator {
+	newU := &Upsert{
+		Sources:  slices.Clone(u.Sources),
+		Reinsert: u.Reinsert,
+	}
+	newU.SetInputs(inputs)
+	return newU
+}
+
+// GetOrdering implements the Operator interface
+func (u *Upsert) GetOrdering(*plancontext.PlanningContext) []ops.OrderBy {
+	return nil
+}
+
+// ShortDescription implements the Operator interface
+func (u *Upsert) ShortDescription() string {
+	if u.Reinsert {
+		return "replace"
+	}
+	return ""
+}
+
+// createUpsertOperator plans an INSERT ... ON DUPLICATE KEY UPDATE or a REPLACE that needs foreign key handling.
+// An existing row is found using the primary key values of the inserted row, so the primary key
+// columns have to be in the column list of the insert.
+func createUpsertOperator(ctx *plancontext.PlanningContext, ins *sqlparser.Insert, vTbl *vindexes.Table) (ops.Operator, error) {
+	stmtType := "ON DUPLICATE KEY UPDATE"
+	if ins.Action == sqlparser.ReplaceAct {
+		stmtType = "REPLACE INTO"
+	}
+	if ins.Ignore {
+		// the insert of a row would ignore the duplicate key error, instead of running the update
+		return nil, vterrors.VT12001("INSERT IGNORE ... ON DUPLICATE KEY UPDATE with foreign keys")
+	}
+	rows, isValues := ins.Rows.(sqlparser.Values)
+	if !isValues {
+		return nil, vterrors.VT12001(fmt.Sprintf("%s with foreign keys and a SELECT", stmtType))
+	}
+	if len(vTbl.PrimaryKey) == 0 {
+		return nil, vterrors.VT09015()
+	}
+	columns := ins.Columns
+	if columns == nil {
+		if !vTbl.ColumnListAuthoritative {
+			return nil, vterrors.VT09004()
+		}
+		for _, col := range vTbl.Columns {
+			columns = append(columns, col.Name)
+		}
+	}
+	var pkOffsets []int
+	for _, pkCol := range vTbl.PrimaryKey {
+		offset := columns.FindColumn(pkCol)
+		if offset < 0 {
+			return nil, vterrors.VT12001(fmt.Sprintf("%s with foreign keys without a value for the primary key column %s", stmtType, pkCol.String()))
+		}
+		pkOffsets = append(pkOffsets, offset)
+	}
+
+	upsert := &Upsert{Reinsert: ins.Action == sqlparser.ReplaceAct}
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			return nil, vterrors.VT03006()
+		}
+		insStmt := &sqlparser.Insert{
+			Action:     sqlparser.InsertAct,
+			Comments:   ins.Comments,
+			Table:      sqlparser.CloneRefOfAliasedTableExpr(ins.Table),
+			Partitions: ins.Partitions,
+			Columns:    sqlparser.CloneColumns(columns),
+			Rows:       sqlparser.Values{sqlparser.CloneValTuple(row)},
+		}
+		insOp, insCtx, err := createOpAndCtxFromStmt(ctx, insStmt, ctx.VerifyAllFKs, "" /* fkToIgnore */)
+		if err != nil {
+			return nil, err
+		}
+
+		var predicates []sqlparser.Expr
+		for idx, pkCol := range vTbl.PrimaryKey {
+			predicates = append(predicates, sqlparser.NewComparisonExpr(sqlparser.EqualOp, sqlparser.NewColName(pkCol.String()), sqlparser.CloneExpr(row[pkOffsets[idx]]), nil))
+		}
+		where := sqlparser.NewWhere(sqlparser.WhereClause, sqlparser.AndExpressions(predicates...))
+
+		var updStmt sqlparser.Statement
+		if upsert.Reinsert {
+			updStmt = &sqlparser.Delete{
+				Comments:   ins.Comments,
+				TableExprs: sqlparser.TableExprs{sqlparser.CloneRefOfAliasedTableExpr(ins.Table)},
+				Where:      where,
+			}
+		} else {
+			updExprs, err := upsertUpdateExprs(ins.OnDup, columns, row)
+			if err != nil {
+				return nil, err
+			}
+			updStmt = &sqlparser.Update{
+				Comments:   ins.Comments,
+				TableExprs: sqlparser.TableExprs{sqlparser.CloneRefOfAliasedTableExpr(ins.Table)},
+				Exprs:      updExprs,
+				Where:      where,
+			}
+		}
+		// The foreign keys of the table are handled when planning the update.
+		updOp, updCtx, err := createOpAndCtxFromStmt(ctx, updStmt, ctx.VerifyAllFKs, "" /* fkToIgnore */)
+		if err != nil {
+			return nil, err
+		}
+
+		upsert.Sources = append(upsert.Sources, UpsertSource{
+			Insert:         insOp,
+			Update:         updOp,
+			InsertSemTable: insCtx.SemTable,
+			UpdateSemTable: updCtx.SemTable,
+		})
+	}
+	return upsert, nil
+}
+
+// upsertUpdateExprs returns the update expressions of the ON DUPLICATE KEY UPDATE clause for the given row,
+// where the VALUES() function is replaced with the value of the column in the row.
+func upsertUpdateExprs(onDup sqlparser.OnDup, columns sqlparser.Columns, row sqlparser.ValTuple) (sqlparser.UpdateExprs, error) {
+	var err error
+	updExprs := make(sqlparser.UpdateExprs, 0, len(onDup))
+	for _, ue := range onDup {
+		expr := sqlparser.CopyOnRewrite(ue.Expr, nil, func(cursor *sqlparser.CopyOnWriteCursor) {
+			valuesFunc, isValuesFunc := cursor.Node().(*sqlparser.ValuesFuncExpr)
+			if !isValuesFunc {
+				return
+			}
+			offset := columns.FindColumn(valuesFunc.Name.Name)
+			if offset < 0 {
+				err = vterrors.VT03019(sqlparser.String(valuesFunc.Name))
+				return
+			}
+			cursor.Replace(sqlparser.CloneExpr(row[offset]))
+		}, nil).(sqlparser.Expr)
+		if err != nil {
+			return nil, err
+		}
+		updExprs = append(updExprs, &sqlparser.UpdateExpr{
+			Name: sqlparser.CloneRefOfColName(ue.Name),
+			Expr: expr,
+		})
+	}
+	return updExprs, nil
+}
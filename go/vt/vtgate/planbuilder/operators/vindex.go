@@ -149,21 +149,45 @@ func (v *Vindex) AddPredicate(ctx *plancontext.PlanningContext, expr sqlparser.E
 		}
 
 		// check RHS
-		var err error
-		if sqlparser.IsValue(comparison.Right) || sqlparser.IsSimpleTuple(comparison.Right) {
+		if _, isMultiColumn := v.Vindex.(vindexes.MultiColumn); isMultiColumn {
+			v.Value = multiColumnVindexRows(comparison)
+		} else if sqlparser.IsValue(comparison.Right) || sqlparser.IsSimpleTuple(comparison.Right) {
 			v.Value = comparison.Right
 		} else {
 			panic(vterrors.VT09018(wrongWhereCond + " (rhs is not a value)"))
 		}
-		if err != nil {
-			panic(vterrors.VT09018(wrongWhereCond+": %v", err))
-		}
 		v.OpCode = engine.VindexMap
 		v.Table.Predicates = append(v.Table.Predicates, e)
 	}
 	return v
 }
 
+// multiColumnVindexRows returns the rows of column values a multi-column vindex has to map,
+// as a tuple with one entry per row. A row is either a single value for the first column of
+// the vindex, or a tuple with the values of the first columns:
+// id = (1, 2) maps one row, and id in ((1, 2), (3, 4)) or id in (1, 3) map two rows.
+func multiColumnVindexRows(comparison *sqlparser.ComparisonExpr) sqlparser.ValTuple {
+	isRow := func(expr sqlparser.Expr) bool {
+		return sqlparser.IsValue(expr) || sqlparser.IsSimpleTuple(expr)
+	}
+	if comparison.Operator == sqlparser.EqualOp {
+		if !isRow(comparison.Right) {
+			panic(vterrors.VT09018(wrongWhereCond + " (rhs is not a value)"))
+		}
+		return sqlparser.ValTuple{comparison.Right}
+	}
+	rows, ok := comparison.Right.(sqlparser.ValTuple)
+	if !ok {
+		panic(vterrors.VT09018(wrongWhereCond + " (rhs is not a value)"))
+	}
+	for _, row := range rows {
+		if !isRow(row) {
+			panic(vterrors.VT09018(wrongWhereCond + " (rhs is not a value)"))
+		}
+	}
+	return rows
+}
+
 // TablesUsed implements the Operator interface.
 // It is not keyspace-qualified.
 func (v *Vindex) TablesUsed() []string {
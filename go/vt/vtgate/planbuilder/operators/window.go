@@ -0,0 +1,323 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operators
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"vitess.io/vitess/go/slice"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/operators/ops"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+)
+
+// Window evaluates window functions in vtgate. It is used when the rows of a
+// window partition can come from more than one shard, so MySQL can't evaluate the functions.
+// The input is sorted on the PARTITION BY expressions followed by the ORDER BY expressions of the window.
+type Window struct {
+	Source ops.Operator
+
+	// Spec is the window specification that all the window functions share
+	Spec *sqlparser.WindowSpecification
+	// Funcs are the window functions evaluated by this operator
+	Funcs []sqlparser.Expr
+
+	Columns []*sqlparser.AliasedExpr
+
+	// These are filled in during offset planning.
+	// Offsets has one entry per column. Columns coming from the input have
+	// a positive offset or zero, and a negative value -n means the result of Funcs[n-1]
+	Offsets       []int
+	PartitionBy   []engine.CheckCol
+	OrderBy       []engine.CheckCol
+	offsetPlanned bool
+}
+
+// createWindow returns a Window operator when the query uses window functions that have
+// to be evaluated in vtgate. If there are no window functions, or MySQL can evaluate them, nil is returned.
+func createWindow(ctx *plancontext.PlanningContext, qp *QueryProjection, src ops.Operator) *Window {
+	if !needsWindow(ctx, qp, src) {
+		return nil
+	}
+
+	w := &Window{}
+	for _, expr := range findWindowFunctions(qp) {
+		if w.funcIndex(ctx, expr) >= 0 {
+			continue
+		}
+		w.addFunc(ctx, expr)
+		// the window functions are exposed as columns up front, so the
+		// operators above will find them instead of fetching their arguments
+		w.Columns = append(w.Columns, aeWrap(expr))
+	}
+
+	var order []ops.OrderBy
+	for _, expr := range w.Spec.PartitionClause {
+		order = append(order, ops.OrderBy{
+			Inner:          &sqlparser.Order{Expr: expr, Direction: sqlparser.AscOrder},
+			SimplifiedExpr: expr,
+		})
+	}
+	for _, by := range w.Spec.OrderClause {
+		order = append(order, ops.OrderBy{
+			Inner:          by,
+			SimplifiedExpr: by.Expr,
+		})
+	}
+
+	w.Source = src
+	if len(order) > 0 {
+		w.Source = &Ordering{
+			Source: src,
+			Order:  order,
+		}
+	}
+	return w
+}
+
+// needsWindow returns true if the query uses window functions that MySQL can't evaluate for us
+func needsWindow(ctx *plancontext.PlanningContext, qp *QueryProjection, src ops.Operator) bool {
+	funcs := findWindowFunctions(qp)
+	return len(funcs) > 0 && !canPushWindowFunctions(ctx, src, funcs)
+}
+
+// findWindowFunctions returns the window functions used in the SELECT and ORDER BY expressions of the query
+func findWindowFunctions(qp *QueryProjection) (funcs []sqlparser.Expr) {
+	visit := func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case sqlparser.Expr:
+			if windowOverClause(node) != nil {
+				funcs = append(funcs, node)
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	for _, expr := range qp.SelectExprs {
+		_ = sqlparser.Walk(visit, expr.Col)
+	}
+	for _, order := range qp.OrderExprs {
+		_ = sqlparser.Walk(visit, order.Inner.Expr)
+	}
+	return
+}
+
+// canPushWindowFunctions returns true if the window functions can be evaluated by MySQL.
+// This is the case when all the rows of every window partition live on the same shard.
+func canPushWindowFunctions(ctx *plancontext.PlanningContext, src ops.Operator, funcs []sqlparser.Expr) bool {
+	rb, isRoute := src.(*Route)
+	if !isRoute {
+		return false
+	}
+	if rb.IsSingleShard() {
+		return true
+	}
+	for _, expr := range funcs {
+		spec := windowOverClause(expr).WindowSpec
+		if spec == nil || !slices.ContainsFunc(spec.PartitionClause, func(e sqlparser.Expr) bool {
+			return exprHasUniqueVindex(ctx, e)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// windowOverClause returns the OVER clause if the expression is a window function
+func windowOverClause(expr sqlparser.Expr) *sqlparser.OverClause {
+	switch expr := expr.(type) {
+	case *sqlparser.ArgumentLessWindowExpr:
+		return expr.OverClause
+	case *sqlparser.NtileExpr:
+		return expr.OverClause
+	case *sqlparser.FirstOrLastValueExpr:
+		return expr.OverClause
+	case *sqlparser.NTHValueExpr:
+		return expr.OverClause
+	case *sqlparser.LagLeadExpr:
+		return expr.OverClause
+	default:
+		return nil
+	}
+}
+
+func (w *Window) addFunc(ctx *plancontext.PlanningContext, expr sqlparser.Expr) {
+	if w.funcIndex(ctx, expr) >= 0 {
+		return
+	}
+	switch expr.(type) {
+	case *sqlparser.ArgumentLessWindowExpr, *sqlparser.NtileExpr:
+	default:
+		panic(vterrors.VT12001(fmt.Sprintf("window function '%s' across shards", sqlparser.String(expr))))
+	}
+
+	over := windowOverClause(expr)
+	spec := over.WindowSpec
+	if !over.WindowName.IsEmpty() || spec != nil && !spec.Name.IsEmpty() {
+		panic(vterrors.VT12001("named windows across shards"))
+	}
+	if spec == nil {
+		spec = &sqlparser.WindowSpecification{}
+	}
+
+	switch {
+	case w.Spec == nil:
+		w.Spec = spec
+	case !sameWindowSpec(ctx, w.Spec, spec):
+		panic(vterrors.VT12001("window functions with different window specifications across shards"))
+	}
+	w.Funcs = append(w.Funcs, expr)
+}
+
+func (w *Window) funcIndex(ctx *plancontext.PlanningContext, expr sqlparser.Expr) int {
+	return slices.IndexFunc(w.Funcs, func(f sqlparser.Expr) bool {
+		return ctx.SemTable.EqualsExprWithDeps(f, expr)
+	})
+}
+
+// sameWindowSpec checks if the two window specifications partition and order the rows the same way.
+// The frame clause is not compared, since the window functions we evaluate in vtgate always work on the whole partition.
+func sameWindowSpec(ctx *plancontext.PlanningContext, a, b *sqlparser.WindowSpecification) bool {
+	if len(a.PartitionClause) != len(b.PartitionClause) || len(a.OrderClause) != len(b.OrderClause) {
+		return false
+	}
+	for i, expr := range a.PartitionClause {
+		if !ctx.SemTable.EqualsExprWithDeps(expr, b.PartitionClause[i]) {
+			return false
+		}
+	}
+	for i, order := range a.OrderClause {
+		other := b.OrderClause[i]
+		if order.Direction != other.Direction || !ctx.SemTable.EqualsExprWithDeps(order.Expr, other.Expr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *Window) Clone(inputs []ops.Operator) ops.Operator {
+	kopy := *w
+	kopy.Source = inputs[0]
+	kopy.Funcs = slices.Clone(w.Funcs)
+	kopy.Columns = slices.Clone(w.Columns)
+	kopy.Offsets = slices.Clone(w.Offsets)
+	kopy.PartitionBy = slices.Clone(w.PartitionBy)
+	kopy.OrderBy = slices.Clone(w.OrderBy)
+	return &kopy
+}
+
+func (w *Window) Inputs() []ops.Operator {
+	return []ops.Operator{w.Source}
+}
+
+func (w *Window) SetInputs(operators []ops.Operator) {
+	w.Source = operators[0]
+}
+
+func (w *Window) AddPredicate(_ *plancontext.PlanningContext, expr sqlparser.Expr) ops.Operator {
+	// predicates can't be pushed under the window, since that would change the rows the window functions see
+	return &Filter{
+		Source:     w,
+		Predicates: []sqlparser.Expr{expr},
+	}
+}
+
+func (w *Window) AddColumn(ctx *plancontext.PlanningContext, reuse bool, _ bool, ae *sqlparser.AliasedExpr) int {
+	if reuse {
+		if offset := w.FindCol(ctx, ae.Expr, false); offset >= 0 {
+			return offset
+		}
+	}
+	if windowOverClause(ae.Expr) != nil {
+		w.addFunc(ctx, ae.Expr)
+	}
+
+	w.Columns = append(w.Columns, ae)
+	if w.offsetPlanned {
+		w.Offsets = append(w.Offsets, w.columnOffset(ctx, ae))
+	}
+	return len(w.Columns) - 1
+}
+
+func (w *Window) FindCol(ctx *plancontext.PlanningContext, expr sqlparser.Expr, _ bool) int {
+	if offset, found := canReuseColumn(ctx, w.Columns, expr, extractExpr); found {
+		return offset
+	}
+	return -1
+}
+
+func (w *Window) GetColumns(*plancontext.PlanningContext) []*sqlparser.AliasedExpr {
+	return w.Columns
+}
+
+func (w *Window) GetSelectExprs(ctx *plancontext.PlanningContext) sqlparser.SelectExprs {
+	return transformColumnsToSelectExprs(ctx, w)
+}
+
+func (w *Window) ShortDescription() string {
+	funcs := slice.Map(w.Funcs, func(e sqlparser.Expr) string {
+		return sqlparser.String(e)
+	})
+	return strings.Join(funcs, ", ")
+}
+
+func (w *Window) GetOrdering(ctx *plancontext.PlanningContext) []ops.OrderBy {
+	return w.Source.GetOrdering(ctx)
+}
+
+func (w *Window) planOffsets(ctx *plancontext.PlanningContext) {
+	if w.offsetPlanned {
+		return
+	}
+	w.offsetPlanned = true
+
+	for _, col := range w.Columns {
+		w.Offsets = append(w.Offsets, w.columnOffset(ctx, col))
+	}
+	for _, expr := range w.Spec.PartitionClause {
+		w.PartitionBy = append(w.PartitionBy, w.checkCol(ctx, expr))
+	}
+	for _, order := range w.Spec.OrderClause {
+		w.OrderBy = append(w.OrderBy, w.checkCol(ctx, order.Expr))
+	}
+}
+
+func (w *Window) columnOffset(ctx *plancontext.PlanningContext, ae *sqlparser.AliasedExpr) int {
+	if idx := w.funcIndex(ctx, ae.Expr); idx >= 0 {
+		return -idx - 1
+	}
+	return w.Source.AddColumn(ctx, true, false, ae)
+}
+
+func (w *Window) checkCol(ctx *plancontext.PlanningContext, expr sqlparser.Expr) engine.CheckCol {
+	typ, _ := ctx.SemTable.TypeForExpr(expr)
+	col := engine.CheckCol{
+		Col:  w.Source.AddColumn(ctx, true, false, aeWrap(expr)),
+		Type: typ,
+	}
+	if ctx.SemTable.NeedsWeightString(expr) {
+		offset := w.Source.AddColumn(ctx, true, false, aeWrap(weightStringFor(expr)))
+		col.WsCol = &offset
+	}
+	return col
+}
@@ -55,8 +55,10 @@ func makeTestOutput(t *testing.T) string {
 
 func TestPlan(t *testing.T) {
 	defer utils.EnsureNoLeaks(t)
+	vschema := loadSchema(t, "vschemas/schema.json", true)
+	addPKs(t, vschema, "user", []string{"user", "music"})
 	vschemaWrapper := &vschemawrapper.VSchemaWrapper{
-		V:             loadSchema(t, "vschemas/schema.json", true),
+		V:             vschema,
 		TabletType_:   topodatapb.TabletType_PRIMARY,
 		SysVarEnabled: true,
 		TestBuilder:   TestBuilder,
@@ -97,12 +99,14 @@ func TestPlan(t *testing.T) {
 	testFile(t, "vexplain_cases.json", testOutputTempDir, vschemaWrapper, false)
 	testFile(t, "misc_cases.json", testOutputTempDir, vschemaWrapper, false)
 	testFile(t, "cte_cases.json", testOutputTempDir, vschemaWrapper, false)
+	testFile(t, "window_cases.json", testOutputTempDir, vschemaWrapper, false)
 }
 
 // TestForeignKeyPlanning tests the planning of foreign keys in a managed mode by Vitess.
 func TestForeignKeyPlanning(t *testing.T) {
 	vschema := loadSchema(t, "vschemas/schema.json", true)
 	setFks(t, vschema)
+	addPKs(t, vschema, "unsharded_fk_allow", []string{"u_tbl1", "u_tbl2"})
 	vschemaWrapper := &vschemawrapper.VSchemaWrapper{
 		V:           vschema,
 		TestBuilder: TestBuilder,
@@ -178,6 +182,13 @@ func setFks(t *testing.T, vschema *vindexes.VSchema) {
 	}
 }
 
+// addPKs sets the id column as the primary key of the given tables, like the schema tracker would.
+func addPKs(t *testing.T, vschema *vindexes.VSchema, ks string, tbls []string) {
+	for _, tbl := range tbls {
+		require.NoError(t, vschema.AddPrimaryKey(ks, tbl, []string{"id"}))
+	}
+}
+
 func TestSystemTables57(t *testing.T) {
 	// first we move everything to use 5.7 logic
 	oldVer := servenv.MySQLServerVersion()
@@ -214,6 +225,7 @@ func TestOne(t *testing.T) {
 
 	lv := loadSchema(t, "vschemas/schema.json", true)
 	setFks(t, lv)
+	addPKs(t, lv, "user", []string{"user", "music"})
 	vschema := &vschemawrapper.VSchemaWrapper{
 		V:           lv,
 		TestBuilder: TestBuilder,
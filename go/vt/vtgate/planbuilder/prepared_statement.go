@@ -112,8 +112,9 @@ func buildExecuteStmtPlan(ctx context.Context, vschema plancontext.VSchema, eStm
 
 	return &planResult{
 		primitive: &engine.ExecStmt{
-			Params: eStmt.Arguments,
-			Input:  plan.Instructions,
+			Params:    eStmt.Arguments,
+			Input:     plan.Instructions,
+			InputType: plan.Type,
 		},
 		tables: plan.TablesUsed,
 	}, nil
@@ -0,0 +1,44 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package planbuilder
+
+import (
+	"vitess.io/vitess/go/vt/vtgate/engine"
+)
+
+var _ logicalPlan = (*upsert)(nil)
+
+// upsert is the logicalPlan for engine.Upsert.
+// insert and update hold the plans for each of the rows.
+type upsert struct {
+	insert   []logicalPlan
+	update   []logicalPlan
+	reinsert bool
+}
+
+// Primitive implements the logicalPlan interface
+func (u *upsert) Primitive() engine.Primitive {
+	up := &engine.Upsert{}
+	for idx, ins := range u.insert {
+		up.Upserts = append(up.Upserts, &engine.UpsertRow{
+			Insert:   ins.Primitive(),
+			Update:   u.update[idx].Primitive(),
+			Reinsert: u.reinsert,
+		})
+	}
+	return up
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package planbuilder
 
 import (
+	"fmt"
+
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/engine"
@@ -27,26 +29,41 @@ import (
 )
 
 func transformVindexPlan(ctx *plancontext.PlanningContext, op *operators.Vindex) (logicalPlan, error) {
-	single, ok := op.Vindex.(vindexes.SingleColumn)
-	if !ok {
-		return nil, vterrors.VT12001("multi-column vindexes not supported")
-	}
-
-	expr, err := evalengine.Translate(op.Value, &evalengine.Config{
+	cfg := &evalengine.Config{
 		Collation:   ctx.SemTable.Collation,
 		ResolveType: ctx.SemTable.TypeForExpr,
-	})
-	if err != nil {
-		return nil, err
+	}
+	eVindexFunc := &engine.VindexFunc{
+		Opcode: op.OpCode,
+		Vindex: op.Vindex,
+	}
+	switch op.Vindex.(type) {
+	case vindexes.SingleColumn:
+		expr, err := evalengine.Translate(op.Value, cfg)
+		if err != nil {
+			return nil, err
+		}
+		eVindexFunc.Value = expr
+	case vindexes.MultiColumn:
+		// the operator has already turned the value into one tuple entry per row to map
+		rows, ok := op.Value.(sqlparser.ValTuple)
+		if !ok {
+			return nil, vterrors.VT13001(fmt.Sprintf("unexpected value for a multi-column vindex: %s", sqlparser.String(op.Value)))
+		}
+		for _, row := range rows {
+			expr, err := evalengine.Translate(row, cfg)
+			if err != nil {
+				return nil, err
+			}
+			eVindexFunc.Values = append(eVindexFunc.Values, expr)
+		}
+	default:
+		return nil, vterrors.VT13001(fmt.Sprintf("unexpected vindex type: %T", op.Vindex))
 	}
 	plan := &vindexFunc{
-		order:   1,
-		tableID: op.Solved,
-		eVindexFunc: &engine.VindexFunc{
-			Opcode: op.OpCode,
-			Vindex: single,
-			Value:  expr,
-		},
+		order:       1,
+		tableID:     op.Solved,
+		eVindexFunc: eVindexFunc,
 	}
 
 	for _, col := range op.Columns {
@@ -340,6 +340,8 @@ func (vh *vtgateHandler) ComStmtExecute(c *mysql.Conn, prepare *mysql.PrepareDat
 		"VTGate MySQL Connector" /* subcomponent: part of the client */)
 	ctx = callerid.NewContext(ctx, ef, im)
 
+	ctx = withPreparedPlan(ctx, preparedPlanOf(prepare))
+
 	session := vh.session(c)
 	if !session.InTransaction {
 		vh.busyConnections.Add(1)
@@ -367,6 +369,48 @@ func (vh *vtgateHandler) ComStmtExecute(c *mysql.Conn, prepare *mysql.PrepareDat
 	return callback(qr)
 }
 
+// ComStmtExecuteCursor is part of the mysql.CursorHandler interface. The rows of the selects run outside
+// of transactions are streamed from the shards as the client fetches them. Since the connection runs
+// other statements meanwhile, the select runs with a copy of the session. The other statements
+// opening a cursor are executed with ComStmtExecute, and their result is buffered.
+func (vh *vtgateHandler) ComStmtExecuteCursor(c *mysql.Conn, prepare *mysql.PrepareData) func(callback func(*sqltypes.Result) error) error {
+	session := vh.session(c)
+	if session.InTransaction || session.InReservedConn || session.LockSession != nil || !session.Autocommit ||
+		sqlparser.Preview(prepare.PrepareStmt) != sqlparser.StmtSelect {
+		return nil
+	}
+	session = session.CloneVT()
+	query, bindVars, pp := prepare.PrepareStmt, prepare.BindVars, preparedPlanOf(prepare)
+
+	return func(callback func(*sqltypes.Result) error) error {
+		ctx := context.Background()
+		if mysqlQueryTimeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, mysqlQueryTimeout)
+			defer cancel()
+		}
+
+		ctx = callinfo.MysqlCallInfo(ctx, c)
+
+		// Fill in the ImmediateCallerID with the UserData returned by
+		// the AuthServer plugin for that user, like ComStmtExecute does.
+		im := c.UserData.Get()
+		ef := callerid.NewEffectiveCallerID(
+			c.User,                  /* principal: who */
+			c.RemoteAddr().String(), /* component: running client process */
+			"VTGate MySQL Connector" /* subcomponent: part of the client */)
+		ctx = callerid.NewContext(ctx, ef, im)
+		ctx = withPreparedPlan(ctx, pp)
+
+		if _, err := vh.vtg.StreamExecute(ctx, vh, session, query, bindVars, callback); err != nil {
+			return sqlerror.NewSQLErrorFromError(err)
+		}
+		return nil
+	}
+}
+
+var _ mysql.CursorHandler = (*vtgateHandler)(nil)
+
 func (vh *vtgateHandler) WarningCount(c *mysql.Conn) uint16 {
 	return uint16(len(vh.session(c).GetWarnings()))
 }
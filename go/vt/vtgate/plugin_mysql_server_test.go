@@ -419,3 +419,57 @@ func TestGracefulShutdownWithTransaction(t *testing.T) {
 
 	require.True(t, mysqlConn.IsMarkedForClose())
 }
+
+func TestComStmtExecuteCursor(t *testing.T) {
+	executor, sbc1, _, _, _ := createExecutorEnv(t)
+
+	vh := newVtgateHandler(&VTGate{executor: executor, timings: timings, rowsReturned: rowsReturned, rowsAffected: rowsAffected})
+	th := &testHandler{}
+	listener, err := mysql.NewListener("tcp", "127.0.0.1:", mysql.NewAuthServerNone(), th, 0, 0, false, false, 0)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	mysqlConn := mysql.GetTestServerConn(listener)
+	mysqlConn.ConnectionID = 1
+	mysqlConn.UserData = &mysql.StaticUserData{}
+	vh.connections[1] = mysqlConn
+
+	// the rows of a select are streamed
+	prepare := &mysql.PrepareData{
+		PrepareStmt: "select id from user where id = :v1",
+		BindVars:    map[string]*querypb.BindVariable{"v1": sqltypes.Int64BindVariable(1)},
+	}
+	execute := vh.ComStmtExecuteCursor(mysqlConn, prepare)
+	require.NotNil(t, execute)
+	var rows int
+	err = execute(func(qr *sqltypes.Result) error {
+		rows += len(qr.Rows)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, rows)
+	require.Len(t, sbc1.Queries, 1)
+	assert.Equal(t, "select id from `user` where id = :v1", sbc1.Queries[0].Sql)
+
+	// the other statements are not streamed
+	insert := &mysql.PrepareData{PrepareStmt: "insert into user(id) values (:v1)"}
+	assert.Nil(t, vh.ComStmtExecuteCursor(mysqlConn, insert))
+
+	// executing a statement binds it to its plan
+	err = vh.ComStmtExecute(mysqlConn, prepare, func(result *sqltypes.Result) error {
+		return nil
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &preparedPlan{}, prepare.ClientData)
+
+	// and the selects of a transaction are not streamed either, since they use its connections
+	err = vh.ComQuery(mysqlConn, "begin", func(result *sqltypes.Result) error {
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Nil(t, vh.ComStmtExecuteCursor(mysqlConn, prepare))
+	err = vh.ComQuery(mysqlConn, "rollback", func(result *sqltypes.Result) error {
+		return nil
+	})
+	require.NoError(t, err)
+}
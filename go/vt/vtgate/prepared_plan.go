@@ -0,0 +1,227 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// The statements prepared through the MySQL protocol are bound to their plan: a statement is
+// parsed once, and only rewritten again when the session state or the vschema it is executed
+// with changes. Its executions then look up its plan in the plan cache directly.
+
+// preparedPlan binds a prepared statement to its plan.
+type preparedPlan struct {
+	sql string
+
+	mu sync.Mutex
+	// stmt is the parsed statement, and known the bind variables it uses.
+	stmt  sqlparser.Statement
+	known sqlparser.BindVars
+	// rewritten is the statement rewritten for its last execution.
+	rewritten *rewrittenStatement
+}
+
+// rewrittenStatement is a prepared statement normalized and rewritten for a session state and a vschema.
+type rewrittenStatement struct {
+	key     string
+	vschema *vindexes.VSchema
+
+	query string
+	stmt  sqlparser.Statement
+	// bindVars are the values of the literals extracted by the normalization,
+	// and known the bind variables used by the rewritten statement.
+	bindVars     map[string]*querypb.BindVariable
+	known        sqlparser.BindVars
+	bindVarNeeds *sqlparser.BindVarNeeds
+}
+
+// preparedPlanOf returns the plan binding of the prepared statement, which is kept with its prepare data.
+func preparedPlanOf(prepare *mysql.PrepareData) *preparedPlan {
+	pp, ok := prepare.ClientData.(*preparedPlan)
+	if !ok || pp.sql != prepare.PrepareStmt {
+		pp = &preparedPlan{sql: prepare.PrepareStmt}
+		prepare.ClientData = pp
+	}
+	return pp
+}
+
+type preparedPlanKey struct{}
+
+// withPreparedPlan returns a context executing the prepared statement with its plan binding.
+func withPreparedPlan(ctx context.Context, pp *preparedPlan) context.Context {
+	return context.WithValue(ctx, preparedPlanKey{}, pp)
+}
+
+// preparedPlanFromContext returns the plan binding of the query, if it is a prepared statement.
+// The queries run while executing the prepared statement, like the queries of the lookup vindexes,
+// are not bound to its plan.
+func preparedPlanFromContext(ctx context.Context, sql string) *preparedPlan {
+	pp, ok := ctx.Value(preparedPlanKey{}).(*preparedPlan)
+	if !ok || pp.sql != sql {
+		return nil
+	}
+	return pp
+}
+
+// statement returns a copy of the parsed statement, and the bind variables reserved by it.
+func (pp *preparedPlan) statement(query string) (sqlparser.Statement, *sqlparser.ReservedVars, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.stmt == nil {
+		stmt, known, err := parseAndValidate(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		pp.stmt, pp.known = stmt, known
+	}
+	return sqlparser.CloneStatement(pp.stmt), sqlparser.NewReservedVars("vtg", copyKnownBindVars(pp.known)), nil
+}
+
+// rewrittenFor returns the statement rewritten for the session state and the vschema, if its last execution had them.
+func (pp *preparedPlan) rewrittenFor(key string, vschema *vindexes.VSchema) *rewrittenStatement {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.rewritten == nil || pp.rewritten.key != key || pp.rewritten.vschema != vschema {
+		return nil
+	}
+	return pp.rewritten
+}
+
+func (pp *preparedPlan) setRewritten(rewritten *rewrittenStatement) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.rewritten = rewritten
+}
+
+func copyKnownBindVars(known sqlparser.BindVars) sqlparser.BindVars {
+	cp := make(sqlparser.BindVars, len(known))
+	for name := range known {
+		cp[name] = struct{}{}
+	}
+	return cp
+}
+
+// rewriteKey describes the state of the session the statements are rewritten for.
+func rewriteKey(vcursor *vcursorImpl, parameterize bool, setVarComment string) string {
+	var sysVars []string
+	for name := range vcursor.safeSession.SystemVariables {
+		sysVars = append(sysVars, name)
+	}
+	sort.Strings(sysVars)
+
+	var key strings.Builder
+	key.WriteString(vcursor.keyspace)
+	key.WriteString("+Limit:")
+	key.WriteString(strconv.Itoa(vcursor.safeSession.getSelectLimit()))
+	key.WriteString("+Normalize:")
+	key.WriteString(strconv.FormatBool(parameterize))
+	key.WriteString("+SetVar:")
+	key.WriteString(setVarComment)
+	key.WriteString("+SysVars:")
+	key.WriteString(strings.Join(sysVars, ","))
+	return key.String()
+}
+
+// getPreparedPlan computes the plan of a prepared statement, like getPlan. The statement is only rewritten
+// again if the session state or the vschema changed since its last execution, otherwise its plan is looked
+// up directly with the rewritten statement.
+func (e *Executor) getPreparedPlan(
+	ctx context.Context,
+	vcursor *vcursorImpl,
+	prepared *preparedPlan,
+	query string,
+	stmt sqlparser.Statement,
+	comments sqlparser.MarginComments,
+	bindVars map[string]*querypb.BindVariable,
+	reservedVars *sqlparser.ReservedVars,
+	logStats *logstats.LogStats,
+) (*engine.Plan, error) {
+	if e.VSchema() == nil {
+		return nil, vterrors.VT13001("vschema not initialized")
+	}
+
+	setVarComment, err := setPlanDirectives(vcursor, stmt)
+	if err != nil {
+		return nil, err
+	}
+	shouldNormalize := e.canNormalizeStatement(stmt, setVarComment)
+	parameterize := e.normalize && shouldNormalize
+
+	key := rewriteKey(vcursor, parameterize, setVarComment)
+	rewritten := prepared.rewrittenFor(key, vcursor.vschema)
+	if rewritten == nil {
+		extracted := make(map[string]*querypb.BindVariable)
+		rewriteASTResult, err := sqlparser.PrepareAST(
+			stmt,
+			reservedVars,
+			extracted,
+			parameterize,
+			vcursor.keyspace,
+			vcursor.safeSession.getSelectLimit(),
+			setVarComment,
+			vcursor.safeSession.SystemVariables,
+			vcursor,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if shouldNormalize {
+			query = sqlparser.String(rewriteASTResult.AST)
+		}
+		known := copyKnownBindVars(prepared.known)
+		for name := range extracted {
+			known[name] = struct{}{}
+		}
+		rewritten = &rewrittenStatement{
+			key:          key,
+			vschema:      vcursor.vschema,
+			query:        query,
+			stmt:         sqlparser.CloneStatement(rewriteASTResult.AST),
+			bindVars:     extracted,
+			known:        known,
+			bindVarNeeds: rewriteASTResult.BindVarNeeds,
+		}
+		prepared.setRewritten(rewritten)
+		stmt = rewriteASTResult.AST
+	} else {
+		stmt = sqlparser.CloneStatement(rewritten.stmt)
+		reservedVars = sqlparser.NewReservedVars("vtg", copyKnownBindVars(rewritten.known))
+	}
+	for name, bv := range rewritten.bindVars {
+		bindVars[name] = bv
+	}
+
+	logStats.SQL = comments.Leading + rewritten.query + comments.Trailing
+	logStats.BindVariables = sqltypes.CopyBindVariables(bindVars)
+
+	return e.cacheAndBuildStatement(ctx, vcursor, rewritten.query, stmt, reservedVars, rewritten.bindVarNeeds, logStats)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// recordQueryDigest adds the execution of the plan to the query digests, if they are enabled.
+func (e *Executor) recordQueryDigest(plan *engine.Plan, vcursor *vcursorImpl, logStats *logstats.LogStats, rowsAffected, rowsReturned uint64, err error) {
+	if e.digests == nil {
+		return
+	}
+	e.digests.Record(querydigest.Execution{
+		Query:        plan.Original,
+		PlanType:     plan.Instructions.RouteType(),
+		Latency:      time.Since(logStats.StartTime),
+		Shards:       vcursor.shards.len(),
+		RowsAffected: rowsAffected,
+		RowsReturned: rowsReturned,
+		Failed:       err != nil,
+	})
+}
+
+// shardSet is the set of the distinct shards queried by an execution. A nil shardSet does not count them.
+type shardSet struct {
+	mu     sync.Mutex
+	shards map[string]bool
+}
+
+func (s *shardSet) add(rss ...*srvtopo.ResolvedShard) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shards == nil {
+		s.shards = make(map[string]bool)
+	}
+	for _, rs := range rss {
+		s.shards[topoproto.KeyspaceShardString(rs.Target.Keyspace, rs.Target.Shard)] = true
+	}
+}
+
+func (s *shardSet) len() uint64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uint64(len(s.shards))
+}
+
+// showQueryDigests returns the query digests, for SHOW VITESS_QUERY_DIGESTS.
+// The latencies are in milliseconds.
+func (e *Executor) showQueryDigests(filter *sqlparser.ShowFilter) (*sqltypes.Result, error) {
+	fields := []*querypb.Field{
+		queryDigestField("Query", sqltypes.VarChar),
+		queryDigestField("PlanType", sqltypes.VarChar),
+		queryDigestField("Count", sqltypes.Uint64),
+		queryDigestField("Errors", sqltypes.Uint64),
+		queryDigestField("RowsAffected", sqltypes.Uint64),
+		queryDigestField("RowsReturned", sqltypes.Uint64),
+		queryDigestField("Shards", sqltypes.Uint64),
+		queryDigestField("TotalLatency", sqltypes.Float64),
+		queryDigestField("P50Latency", sqltypes.Float64),
+		queryDigestField("P95Latency", sqltypes.Float64),
+		queryDigestField("P99Latency", sqltypes.Float64),
+		queryDigestField("MaxLatency", sqltypes.Float64),
+		queryDigestField("FirstSeen", sqltypes.VarChar),
+		queryDigestField("LastSeen", sqltypes.VarChar),
+	}
+	result := &sqltypes.Result{Fields: fields}
+	if e.digests == nil {
+		return result, nil
+	}
+
+	digests := e.digests.Digests()
+	if filter != nil && filter.Like != "" {
+		queryRegexp := sqlparser.LikeToRegexp(filter.Like)
+		filtered := digests[:0]
+		for _, d := range digests {
+			if queryRegexp.MatchString(d.Query) {
+				filtered = append(filtered, d)
+			}
+		}
+		digests = filtered
+	}
+	for _, d := range digests {
+		result.Rows = append(result.Rows, []sqltypes.Value{
+			sqltypes.NewVarChar(d.Query),
+			sqltypes.NewVarChar(d.PlanType),
+			sqltypes.NewUint64(d.Count),
+			sqltypes.NewUint64(d.Errors),
+			sqltypes.NewUint64(d.RowsAffected),
+			sqltypes.NewUint64(d.RowsReturned),
+			sqltypes.NewUint64(d.Shards),
+			milliseconds(d.TotalLatency),
+			milliseconds(d.Percentile(50)),
+			milliseconds(d.Percentile(95)),
+			milliseconds(d.Percentile(99)),
+			milliseconds(d.MaxLatency),
+			sqltypes.NewVarChar(d.FirstSeen.UTC().Format(time.RFC3339)),
+			sqltypes.NewVarChar(d.LastSeen.UTC().Format(time.RFC3339)),
+		})
+	}
+	return result, nil
+}
+
+func queryDigestField(name string, typ querypb.Type) *querypb.Field {
+	field := &querypb.Field{
+		Name:  name,
+		Type:  typ,
+		Flags: uint32(querypb.MySqlFlag_NOT_NULL_FLAG),
+	}
+	if typ == sqltypes.VarChar {
+		field.Charset = uint32(collations.SystemCollation.Collation)
+	} else {
+		field.Charset = collations.CollationBinaryID
+		field.Flags |= uint32(querypb.MySqlFlag_NUM_FLAG)
+	}
+	return field
+}
+
+func milliseconds(d time.Duration) sqltypes.Value {
+	return sqltypes.NewFloat64(float64(d) / float64(time.Millisecond))
+}
+
+// registerDebugQueryDigestsHandler serves the query digests as JSON on /debug/query_digests, so the
+// digests of several vtgates can be merged. /debug/query_digests?reset=true returns the digests and resets them.
+//
+// This is the only way to reset the digests. There is no SQL statement for it, since vtgate has no privilege
+// to check which MySQL users could reset them, while the HTTP route requires the ADMIN ACL role. There is no
+// vtctld command either, since vtctld does not know the vtgates: the digests are reset on every vtgate.
+func (vtg *VTGate) registerDebugQueryDigestsHandler() {
+	servenv.HTTPHandleFunc("/debug/query_digests", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		digests := vtg.executor.digests
+		if digests == nil {
+			http.Error(w, "the query digests are disabled, see --query-digests-max-queries", http.StatusNotFound)
+			return
+		}
+		if reset, _ := strconv.ParseBool(r.FormValue("reset")); reset {
+			if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+				acl.SendError(w, err)
+				return
+			}
+			returnAsJSON(w, digests.Reset())
+			return
+		}
+		returnAsJSON(w, digests.Digests())
+	})
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package querydigest aggregates the statistics of the queries executed by vtgate by normalized query,
+// like the statement digests of the MySQL performance schema. The latencies are counted in fixed buckets,
+// so the digests of several vtgates can be merged.
+package querydigest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/stats"
+)
+
+// LatencyBuckets are the upper bounds of the latency buckets of the digests.
+// The last bucket of a digest counts the executions slower than the last bound.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+var digestsDropped = stats.NewCounter("QueryDigestsDropped", "Query executions not counted in the query digests because there were too many normalized queries")
+
+// Execution is a query executed by vtgate.
+type Execution struct {
+	// Query is the normalized query
+	Query    string
+	PlanType string
+	Latency  time.Duration
+	// Shards is the number of distinct shards queried
+	Shards       uint64
+	RowsAffected uint64
+	RowsReturned uint64
+	Failed       bool
+}
+
+// Digest is the statistics of the executions of a normalized query.
+type Digest struct {
+	Query    string `json:"query"`
+	PlanType string `json:"plan_type"`
+
+	Count        uint64 `json:"count"`
+	Errors       uint64 `json:"errors"`
+	RowsAffected uint64 `json:"rows_affected"`
+	RowsReturned uint64 `json:"rows_returned"`
+	// Shards is the sum of the distinct shards queried by every execution
+	Shards uint64 `json:"shards"`
+
+	TotalLatency time.Duration `json:"total_latency"`
+	MaxLatency   time.Duration `json:"max_latency"`
+	// Latencies counts the executions by latency, in the LatencyBuckets.
+	Latencies []uint64 `json:"latencies"`
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func newDigest(query string) *Digest {
+	return &Digest{Query: query, Latencies: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+func (d *Digest) add(ex Execution, now time.Time) {
+	if d.Count == 0 {
+		d.FirstSeen = now
+	}
+	d.LastSeen = now
+	d.PlanType = ex.PlanType
+	d.Count++
+	if ex.Failed {
+		d.Errors++
+	}
+	d.RowsAffected += ex.RowsAffected
+	d.RowsReturned += ex.RowsReturned
+	d.Shards += ex.Shards
+	d.TotalLatency += ex.Latency
+	d.MaxLatency = max(d.MaxLatency, ex.Latency)
+	d.Latencies[sort.Search(len(LatencyBuckets), func(i int) bool { return ex.Latency <= LatencyBuckets[i] })]++
+}
+
+// merge adds the statistics of the other digest of the same query to the digest.
+func (d *Digest) merge(other *Digest) {
+	if other.Count == 0 {
+		return
+	}
+	if d.Count == 0 || other.FirstSeen.Before(d.FirstSeen) {
+		d.FirstSeen = other.FirstSeen
+	}
+	if d.Count == 0 || other.LastSeen.After(d.LastSeen) {
+		d.LastSeen = other.LastSeen
+		d.PlanType = other.PlanType
+	}
+	d.Count += other.Count
+	d.Errors += other.Errors
+	d.RowsAffected += other.RowsAffected
+	d.RowsReturned += other.RowsReturned
+	d.Shards += other.Shards
+	d.TotalLatency += other.TotalLatency
+	d.MaxLatency = max(d.MaxLatency, other.MaxLatency)
+	for i := 0; i < len(d.Latencies) && i < len(other.Latencies); i++ {
+		d.Latencies[i] += other.Latencies[i]
+	}
+}
+
+// Percentile returns an estimation of the latency percentile of the executions: the upper bound
+// of the latency bucket it is in, or the maximum latency if it is lower.
+func (d *Digest) Percentile(p float64) time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	rank := uint64(float64(d.Count)*p/100 + 0.5)
+	var seen uint64
+	for i, count := range d.Latencies {
+		seen += count
+		if seen >= rank && i < len(LatencyBuckets) {
+			return min(LatencyBuckets[i], d.MaxLatency)
+		}
+	}
+	return d.MaxLatency
+}
+
+// Collector aggregates the executions in digests.
+type Collector struct {
+	maxDigests int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	digests map[string]*Digest
+}
+
+// NewCollector creates a Collector keeping the digests of at most maxDigests normalized queries.
+// The executions of the other queries are not counted, until the digests are reset.
+func NewCollector(maxDigests int) *Collector {
+	return &Collector{
+		maxDigests: maxDigests,
+		now:        time.Now,
+		digests:    make(map[string]*Digest),
+	}
+}
+
+// Record adds the execution to the digest of its query.
+func (c *Collector) Record(ex Execution) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.digests[ex.Query]
+	if !ok {
+		if len(c.digests) >= c.maxDigests {
+			digestsDropped.Add(1)
+			return
+		}
+		d = newDigest(ex.Query)
+		c.digests[ex.Query] = d
+	}
+	d.add(ex, c.now())
+}
+
+// Digests returns a copy of the digests, by descending total latency.
+func (c *Collector) Digests() []*Digest {
+	c.mu.Lock()
+	digests := make([]*Digest, 0, len(c.digests))
+	for _, d := range c.digests {
+		cp := *d
+		cp.Latencies = append([]uint64(nil), d.Latencies...)
+		digests = append(digests, &cp)
+	}
+	c.mu.Unlock()
+	sortDigests(digests)
+	return digests
+}
+
+// Reset forgets all the digests, and returns them by descending total latency.
+func (c *Collector) Reset() []*Digest {
+	c.mu.Lock()
+	old := c.digests
+	c.digests = make(map[string]*Digest)
+	c.mu.Unlock()
+
+	digests := make([]*Digest, 0, len(old))
+	for _, d := range old {
+		digests = append(digests, d)
+	}
+	sortDigests(digests)
+	return digests
+}
+
+// Merge merges the digests of the same queries, like the digests of several vtgates,
+// and returns them by descending total latency.
+func Merge(lists ...[]*Digest) []*Digest {
+	merged := make(map[string]*Digest)
+	for _, digests := range lists {
+		for _, d := range digests {
+			m, ok := merged[d.Query]
+			if !ok {
+				m = newDigest(d.Query)
+				merged[d.Query] = m
+			}
+			m.merge(d)
+		}
+	}
+	digests := make([]*Digest, 0, len(merged))
+	for _, d := range merged {
+		digests = append(digests, d)
+	}
+	sortDigests(digests)
+	return digests
+}
+
+func sortDigests(digests []*Digest) {
+	sort.Slice(digests, func(i, j int) bool {
+		if digests[i].TotalLatency != digests[j].TotalLatency {
+			return digests[i].TotalLatency > digests[j].TotalLatency
+		}
+		return digests[i].Query < digests[j].Query
+	})
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package querydigest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCollector(maxDigests int) (*Collector, *time.Time) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCollector(maxDigests)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestRecord(t *testing.T) {
+	c, now := newTestCollector(2)
+	first := *now
+	c.Record(Execution{Query: "select * from t where id = :id", PlanType: "EqualUnique", Latency: 2 * time.Millisecond, Shards: 1, RowsReturned: 1})
+	*now = now.Add(time.Second)
+	c.Record(Execution{Query: "select * from t where id = :id", PlanType: "EqualUnique", Latency: 20 * time.Millisecond, Shards: 1, Failed: true})
+	c.Record(Execution{Query: "update t set a = :a", PlanType: "Scatter", Latency: 5 * time.Millisecond, Shards: 4, RowsAffected: 10})
+
+	digests := c.Digests()
+	require.Len(t, digests, 2)
+	d := digests[0]
+	assert.Equal(t, "select * from t where id = :id", d.Query)
+	assert.Equal(t, "EqualUnique", d.PlanType)
+	assert.EqualValues(t, 2, d.Count)
+	assert.EqualValues(t, 1, d.Errors)
+	assert.EqualValues(t, 1, d.RowsReturned)
+	assert.EqualValues(t, 2, d.Shards)
+	assert.Equal(t, 22*time.Millisecond, d.TotalLatency)
+	assert.Equal(t, 20*time.Millisecond, d.MaxLatency)
+	assert.Equal(t, first, d.FirstSeen)
+	assert.Equal(t, *now, d.LastSeen)
+	assert.EqualValues(t, 10, digests[1].RowsAffected)
+
+	// the digests are copies
+	d.Count = 100
+	assert.EqualValues(t, 2, c.Digests()[0].Count)
+
+	// a third query does not fit
+	dropped := digestsDropped.Get()
+	c.Record(Execution{Query: "select 1", Latency: time.Second})
+	assert.Len(t, c.Digests(), 2)
+	assert.Equal(t, dropped+1, digestsDropped.Get())
+}
+
+func TestPercentile(t *testing.T) {
+	c, _ := newTestCollector(1)
+	assert.Zero(t, newDigest("select 1").Percentile(50))
+
+	for i := 0; i < 90; i++ {
+		c.Record(Execution{Query: "select 1", Latency: 800 * time.Microsecond})
+	}
+	for i := 0; i < 9; i++ {
+		c.Record(Execution{Query: "select 1", Latency: 40 * time.Millisecond})
+	}
+	c.Record(Execution{Query: "select 1", Latency: time.Minute})
+
+	d := c.Digests()[0]
+	assert.Equal(t, time.Millisecond, d.Percentile(50))
+	assert.Equal(t, 50*time.Millisecond, d.Percentile(95))
+	assert.Equal(t, 50*time.Millisecond, d.Percentile(99))
+	assert.Equal(t, time.Minute, d.Percentile(100))
+
+	// the percentiles are never above the maximum latency
+	c, _ = newTestCollector(1)
+	c.Record(Execution{Query: "select 1", Latency: 3 * time.Millisecond})
+	assert.Equal(t, 3*time.Millisecond, c.Digests()[0].Percentile(50))
+}
+
+func TestReset(t *testing.T) {
+	c, _ := newTestCollector(1)
+	c.Record(Execution{Query: "select 1", Latency: time.Millisecond})
+
+	old := c.Reset()
+	require.Len(t, old, 1)
+	assert.EqualValues(t, 1, old[0].Count)
+	assert.Empty(t, c.Digests())
+
+	// the reset frees the room for other queries
+	c.Record(Execution{Query: "select 2", Latency: time.Millisecond})
+	assert.Equal(t, "select 2", c.Digests()[0].Query)
+}
+
+func TestMerge(t *testing.T) {
+	c1, now := newTestCollector(10)
+	c1.Record(Execution{Query: "select 1", PlanType: "Unsharded", Latency: time.Millisecond})
+	c1.Record(Execution{Query: "select 2", PlanType: "Unsharded", Latency: 10 * time.Millisecond})
+	c2, _ := newTestCollector(10)
+	c2.now = func() time.Time { return now.Add(time.Minute) }
+	c2.Record(Execution{Query: "select 1", PlanType: "Scatter", Latency: 30 * time.Millisecond, Failed: true})
+
+	merged := Merge(c1.Digests(), c2.Digests(), nil)
+	require.Len(t, merged, 2)
+	d := merged[0]
+	assert.Equal(t, "select 1", d.Query)
+	assert.Equal(t, "Scatter", d.PlanType)
+	assert.EqualValues(t, 2, d.Count)
+	assert.EqualValues(t, 1, d.Errors)
+	assert.Equal(t, 31*time.Millisecond, d.TotalLatency)
+	assert.Equal(t, 30*time.Millisecond, d.MaxLatency)
+	assert.Equal(t, *now, d.FirstSeen)
+	assert.Equal(t, now.Add(time.Minute), d.LastSeen)
+	assert.Equal(t, 30*time.Millisecond, d.Percentile(99))
+	assert.Equal(t, "select 2", merged[1].Query)
+
+	// the merged digests are new ones
+	d1 := c1.Digests()
+	merged = Merge(d1)
+	merged[0].Count = 100
+	assert.EqualValues(t, 1, d1[0].Count)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/topo"
+)
+
+var (
+	configFile     string
+	configTopoKey  string
+	reloadInterval = 30 * time.Second
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&configFile, "query-rewrite-rules-file", "", "JSON file with the rules rewriting or denying the queries before they are planned. The file is reloaded every --query-rewrite-rules-reload-interval")
+	fs.StringVar(&configTopoKey, "query-rewrite-rules-topo-key", "", "Name of the vitess metadata key in the global topo holding the JSON query rewriting rules, as set with SET @@vitess_metadata.<key> = '<rules>'. Cannot be used with --query-rewrite-rules-file")
+	fs.DurationVar(&reloadInterval, "query-rewrite-rules-reload-interval", reloadInterval, "How often the query rewriting rules are reloaded from --query-rewrite-rules-file or --query-rewrite-rules-topo-key")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewEngineFromFlags creates the Engine configured by the flags, and keeps reloading its rules
+// until the context is done. It returns nil when no query rewriting rules are configured.
+func NewEngineFromFlags(ctx context.Context, ts *topo.Server) (*Engine, error) {
+	var load func(context.Context) ([]byte, error)
+	switch {
+	case configFile != "" && configTopoKey != "":
+		return nil, errors.New("only one of --query-rewrite-rules-file and --query-rewrite-rules-topo-key can be set")
+	case configFile != "":
+		path := configFile
+		load = func(context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		}
+	case configTopoKey != "":
+		key := configTopoKey
+		load = func(ctx context.Context) ([]byte, error) {
+			return loadFromTopo(ctx, ts, key)
+		}
+	default:
+		return nil, nil
+	}
+
+	e := NewEngine()
+	// the first load has to succeed, we don't want to start without the rules we were asked for
+	data, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	e.SetConfig(cfg)
+	go e.reloadLoop(ctx, load, data)
+	return e, nil
+}
+
+// loadFromTopo returns the value of the vitess metadata key, which is empty if the key does not exist
+func loadFromTopo(ctx context.Context, ts *topo.Server, key string) ([]byte, error) {
+	metadata, err := ts.GetMetadata(ctx, key)
+	if err != nil && !topo.IsErrType(err, topo.NoNode) {
+		return nil, err
+	}
+	return []byte(metadata[key]), nil
+}
+
+// reloadLoop reloads the rules every reloadInterval. When the rules cannot be loaded,
+// or are invalid, the previous rules are kept.
+func (e *Engine) reloadLoop(ctx context.Context, load func(context.Context) ([]byte, error), last []byte) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		data, err := load(ctx)
+		if err != nil {
+			log.Warningf("Unable to reload the query rewriting rules, keeping the previous ones: %v", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		cfg, err := ParseConfig(data)
+		if err != nil {
+			log.Warningf("Invalid query rewriting rules, keeping the previous ones: %v", err)
+			continue
+		}
+		e.SetConfig(cfg)
+		log.Infof("Reloaded %d query rewriting rules", len(cfg.Rules))
+	}
+}
@@ -0,0 +1,467 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package queryrewrite implements the query rewriting rules of vtgate. The rules match the queries
+// by fingerprint, table, user and comments, and rewrite or deny them before they are planned.
+// They let operators fix the bad queries of applications that cannot be redeployed quickly.
+package queryrewrite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+const (
+	// ActionRewrite changes the queries matching the rule
+	ActionRewrite = "rewrite"
+	// ActionDeny rejects the queries matching the rule
+	ActionDeny = "deny"
+
+	hintUse    = "use"
+	hintForce  = "force"
+	hintIgnore = "ignore"
+)
+
+var rulesApplied = stats.NewCountersWithMultiLabels(
+	"QueryRewriteRulesApplied",
+	"Queries matched by a query rewriting rule",
+	[]string{"Rule", "Action"})
+
+// Config is the query rewriting configuration, loaded from a JSON file or from the topo.
+type Config struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule rewrites or denies the queries that match it.
+type Rule struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Fingerprint, Tables, User and Comment select the queries the rule applies to. A query has to match
+	// all the ones that are set, and at least one of them has to be set.
+
+	// Fingerprint is an example of the queries, or their fingerprint as returned by sqlparser.Fingerprint,
+	// where the values are written as ?. The queries with the same fingerprint match.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Tables are table or keyspace.table names. The queries using any of them match.
+	Tables []string `json:"tables,omitempty"`
+	// User is the immediate caller of the queries.
+	User string `json:"user,omitempty"`
+	// Comment is a regular expression matched against the comments of the queries.
+	Comment string `json:"comment,omitempty"`
+
+	// Action is what is done to the queries matching the rule: rewrite or deny.
+	Action string `json:"action"`
+
+	// IndexHints are added to the tables of the queries.
+	IndexHints []*IndexHint `json:"index_hints,omitempty"`
+	// Limit is the maximum number of rows returned by a select: it is added to the selects
+	// without a limit, and replaces larger limits.
+	Limit int `json:"limit,omitempty"`
+	// MaxExecutionTimeMs is added to the selects as a MAX_EXECUTION_TIME optimizer hint.
+	MaxExecutionTimeMs int `json:"max_execution_time_ms,omitempty"`
+	// TabletType routes the selects that are not in a transaction to another type of tablets.
+	TabletType string `json:"tablet_type,omitempty"`
+	// Keyspace is used as the default keyspace of the queries, instead of the keyspace of the session.
+	Keyspace string `json:"keyspace,omitempty"`
+}
+
+// IndexHint is an index hint added to a table.
+type IndexHint struct {
+	// Table is the table or keyspace.table name the hint is added to.
+	Table string `json:"table"`
+	// Type is use, force or ignore.
+	Type    string   `json:"type"`
+	Indexes []string `json:"indexes"`
+}
+
+// ParseConfig parses and validates a JSON query rewriting configuration.
+// Empty data is a configuration without rules.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid query rewriting configuration: %w", err)
+	}
+	names := make(map[string]bool)
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("invalid query rewriting configuration: every rule needs a name")
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("invalid query rewriting configuration: duplicate rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		if _, err := compile(r); err != nil {
+			return nil, fmt.Errorf("invalid query rewriting configuration: rule %q: %w", r.Name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// rule is a Rule ready to be matched against the queries
+type rule struct {
+	*Rule
+	fingerprint string
+	tables      []tableName
+	comment     *regexp.Regexp
+	tabletType  topodatapb.TabletType
+	hints       []indexHint
+}
+
+type tableName struct {
+	keyspace, name string
+}
+
+type indexHint struct {
+	table tableName
+	hint  *sqlparser.IndexHint
+}
+
+func compile(r *Rule) (*rule, error) {
+	c := &rule{Rule: r}
+	if r.Fingerprint == "" && len(r.Tables) == 0 && r.User == "" && r.Comment == "" {
+		return nil, fmt.Errorf("one of fingerprint, tables, user and comment has to be set")
+	}
+	if r.Fingerprint != "" {
+		stmt, err := sqlparser.Parse(r.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fingerprint: %w", err)
+		}
+		c.fingerprint = sqlparser.Fingerprint(stmt)
+	}
+	for _, t := range r.Tables {
+		c.tables = append(c.tables, parseTableName(t))
+	}
+	if r.Comment != "" {
+		var err error
+		if c.comment, err = regexp.Compile(r.Comment); err != nil {
+			return nil, fmt.Errorf("invalid comment: %w", err)
+		}
+	}
+
+	rewrites := len(r.IndexHints) > 0 || r.Limit > 0 || r.MaxExecutionTimeMs > 0 || r.TabletType != "" || r.Keyspace != ""
+	switch r.Action {
+	case ActionRewrite:
+		if !rewrites {
+			return nil, fmt.Errorf("a rewrite rule needs one of index_hints, limit, max_execution_time_ms, tablet_type and keyspace")
+		}
+	case ActionDeny:
+		if rewrites {
+			return nil, fmt.Errorf("a deny rule cannot rewrite the queries")
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q, allowed values: %s, %s", r.Action, ActionRewrite, ActionDeny)
+	}
+	if r.Limit < 0 || r.MaxExecutionTimeMs < 0 {
+		return nil, fmt.Errorf("limit and max_execution_time_ms cannot be negative")
+	}
+	if r.TabletType != "" {
+		tabletType, err := topoproto.ParseTabletType(r.TabletType)
+		if err != nil {
+			return nil, err
+		}
+		switch tabletType {
+		case topodatapb.TabletType_PRIMARY, topodatapb.TabletType_REPLICA, topodatapb.TabletType_RDONLY:
+		default:
+			return nil, fmt.Errorf("queries cannot be routed to %s tablets", r.TabletType)
+		}
+		c.tabletType = tabletType
+	}
+	for _, h := range r.IndexHints {
+		hint := &sqlparser.IndexHint{}
+		switch strings.ToLower(h.Type) {
+		case hintUse:
+			hint.Type = sqlparser.UseOp
+		case hintForce:
+			hint.Type = sqlparser.ForceOp
+		case hintIgnore:
+			hint.Type = sqlparser.IgnoreOp
+		default:
+			return nil, fmt.Errorf("unknown index hint type %q, allowed values: %s, %s, %s", h.Type, hintUse, hintForce, hintIgnore)
+		}
+		if h.Table == "" || len(h.Indexes) == 0 {
+			return nil, fmt.Errorf("an index hint needs a table and indexes")
+		}
+		for _, index := range h.Indexes {
+			hint.Indexes = append(hint.Indexes, sqlparser.NewIdentifierCI(index))
+		}
+		c.hints = append(c.hints, indexHint{table: parseTableName(h.Table), hint: hint})
+	}
+	return c, nil
+}
+
+func parseTableName(name string) tableName {
+	if keyspace, table, ok := strings.Cut(name, "."); ok {
+		return tableName{keyspace: keyspace, name: table}
+	}
+	return tableName{name: name}
+}
+
+// matches returns true if the table of the rule is the table of the query. A rule table without
+// a keyspace matches the tables of any keyspace. The tables of the query without a keyspace are
+// in the keyspace of the session.
+func (t tableName) matches(table sqlparser.TableName, keyspace string) bool {
+	if t.name != table.Name.String() {
+		return false
+	}
+	if t.keyspace == "" {
+		return true
+	}
+	if !table.Qualifier.IsEmpty() {
+		keyspace = table.Qualifier.String()
+	}
+	return t.keyspace == keyspace
+}
+
+// Query is a query the rules are applied to.
+type Query struct {
+	Statement sqlparser.Statement
+	// Comments are the margin comments of the query, which are not part of the statement
+	Comments sqlparser.MarginComments
+	// Keyspace is the keyspace of the session
+	Keyspace string
+	// User is the immediate caller of the query
+	User string
+
+	fingerprint *string
+	tables      []sqlparser.TableName
+	comments    *string
+}
+
+// Result tells how the rules changed a query.
+type Result struct {
+	// Rules are the names of the rules the query matched.
+	Rules []string
+	// Rewritten is true if the statement was changed.
+	Rewritten bool
+	// TabletType is the type of the tablets the query is routed to, or UNKNOWN
+	// if the query is routed to the tablets of the session.
+	TabletType topodatapb.TabletType
+	// Keyspace is the default keyspace of the query, or empty if it is the keyspace of the session.
+	Keyspace string
+}
+
+// Engine applies the query rewriting rules to the queries.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []*rule
+}
+
+// NewEngine creates an Engine without any rules.
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// SetConfig replaces the rules of the engine. The configuration has to be valid, see ParseConfig.
+func (e *Engine) SetConfig(cfg *Config) {
+	rules := make([]*rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		c, err := compile(r)
+		if err != nil {
+			// ParseConfig already validated the rules
+			continue
+		}
+		rules = append(rules, c)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// Apply applies the rules to the query, in their order. All the rules are matched against the query
+// as it was sent, and the rewrites of all the matching rules are applied to its statement, in place.
+// It returns an error if the query matched a deny rule, and a nil Result if it matched no rule.
+func (e *Engine) Apply(q *Query) (*Result, error) {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	var matched []*rule
+	for _, r := range rules {
+		if !r.matches(q) {
+			continue
+		}
+		rulesApplied.Add([]string{r.Name, r.Action}, 1)
+		if r.Action == ActionDeny {
+			desc := r.Description
+			if desc == "" {
+				desc = r.Name
+			}
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "disallowed due to rule: %s", desc)
+		}
+		matched = append(matched, r)
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	res := &Result{}
+	for _, r := range matched {
+		res.Rules = append(res.Rules, r.Name)
+		if r.rewrite(q) {
+			res.Rewritten = true
+		}
+		if r.tabletType != topodatapb.TabletType_UNKNOWN {
+			res.TabletType = r.tabletType
+		}
+		if r.Keyspace != "" {
+			res.Keyspace = r.Keyspace
+		}
+	}
+	return res, nil
+}
+
+func (r *rule) matches(q *Query) bool {
+	if r.User != "" && r.User != q.User {
+		return false
+	}
+	if r.fingerprint != "" && r.fingerprint != q.getFingerprint() {
+		return false
+	}
+	if len(r.tables) > 0 && !r.usesTables(q) {
+		return false
+	}
+	if r.comment != nil && !r.comment.MatchString(q.getComments()) {
+		return false
+	}
+	return true
+}
+
+func (r *rule) usesTables(q *Query) bool {
+	for _, table := range q.getTables() {
+		for _, t := range r.tables {
+			if t.matches(table, q.Keyspace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewrite applies the rewrites of the rule to the statement of the query,
+// and returns true if the statement was changed.
+func (r *rule) rewrite(q *Query) bool {
+	rewritten := false
+	if len(r.hints) > 0 {
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			aliased, ok := node.(*sqlparser.AliasedTableExpr)
+			if !ok {
+				return true, nil
+			}
+			table, ok := aliased.Expr.(sqlparser.TableName)
+			if !ok {
+				return true, nil
+			}
+			for _, h := range r.hints {
+				if h.table.matches(table, q.Keyspace) {
+					aliased.Hints = append(aliased.Hints, sqlparser.CloneRefOfIndexHint(h.hint))
+					rewritten = true
+				}
+			}
+			return true, nil
+		}, q.Statement)
+	}
+
+	sel, ok := q.Statement.(sqlparser.SelectStatement)
+	if !ok {
+		return rewritten
+	}
+	if r.Limit > 0 && limitAbove(sel.GetLimit(), r.Limit) {
+		limit := &sqlparser.Limit{}
+		if sel.GetLimit() != nil {
+			limit.Offset = sel.GetLimit().Offset
+		}
+		limit.Rowcount = sqlparser.NewIntLiteral(strconv.Itoa(r.Limit))
+		sel.SetLimit(limit)
+		rewritten = true
+	}
+	if r.MaxExecutionTimeMs > 0 {
+		sel.SetComments(sel.GetParsedComments().Prepend(fmt.Sprintf("/*+ MAX_EXECUTION_TIME(%d) */", r.MaxExecutionTimeMs)))
+		rewritten = true
+	}
+	return rewritten
+}
+
+// limitAbove returns true if the limit can return more than max rows. The limits using bind variables
+// are not known before the execution, and are left alone.
+func limitAbove(limit *sqlparser.Limit, max int) bool {
+	if limit == nil || limit.Rowcount == nil {
+		return true
+	}
+	lit, ok := limit.Rowcount.(*sqlparser.Literal)
+	if !ok || lit.Type != sqlparser.IntVal {
+		return false
+	}
+	n, err := strconv.ParseUint(lit.Val, 10, 64)
+	return err == nil && n > uint64(max)
+}
+
+func (q *Query) getFingerprint() string {
+	if q.fingerprint == nil {
+		fingerprint := sqlparser.Fingerprint(q.Statement)
+		q.fingerprint = &fingerprint
+	}
+	return *q.fingerprint
+}
+
+func (q *Query) getTables() []sqlparser.TableName {
+	if q.tables == nil {
+		q.tables = []sqlparser.TableName{}
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			if aliased, ok := node.(*sqlparser.AliasedTableExpr); ok {
+				if table, ok := aliased.Expr.(sqlparser.TableName); ok {
+					q.tables = append(q.tables, table)
+				}
+			}
+			return true, nil
+		}, q.Statement)
+	}
+	return q.tables
+}
+
+func (q *Query) getComments() string {
+	if q.comments == nil {
+		var parts []string
+		if q.Comments.Leading != "" {
+			parts = append(parts, strings.TrimSpace(q.Comments.Leading))
+		}
+		if commented, ok := q.Statement.(sqlparser.Commented); ok {
+			parts = append(parts, commented.GetParsedComments().GetComments()...)
+		}
+		if q.Comments.Trailing != "" {
+			parts = append(parts, strings.TrimSpace(q.Comments.Trailing))
+		}
+		comments := strings.Join(parts, " ")
+		q.comments = &comments
+	}
+	return *q.comments
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrewrite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+func newEngine(t *testing.T, config string) *Engine {
+	t.Helper()
+	cfg, err := ParseConfig([]byte(config))
+	require.NoError(t, err)
+	e := NewEngine()
+	e.SetConfig(cfg)
+	return e
+}
+
+func apply(t *testing.T, e *Engine, sql, keyspace, user string) (string, *Result, error) {
+	t.Helper()
+	query, comments := sqlparser.SplitMarginComments(sql)
+	stmt, err := sqlparser.Parse(query)
+	require.NoError(t, err)
+	res, err := e.Apply(&Query{Statement: stmt, Comments: comments, Keyspace: keyspace, User: user})
+	return sqlparser.String(stmt), res, err
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Rules)
+
+	tcases := []struct {
+		config, err string
+	}{{
+		config: `{"rules": [{"tables": ["t"], "action": "deny"}]}`,
+		err:    "invalid query rewriting configuration: every rule needs a name",
+	}, {
+		config: `{"rules": [{"name": "r", "tables": ["t"], "action": "deny"}, {"name": "r", "user": "u", "action": "deny"}]}`,
+		err:    `invalid query rewriting configuration: duplicate rule name "r"`,
+	}, {
+		config: `{"rules": [{"name": "r", "action": "deny"}]}`,
+		err:    `invalid query rewriting configuration: rule "r": one of fingerprint, tables, user and comment has to be set`,
+	}, {
+		config: `{"rules": [{"name": "r", "tables": ["t"], "action": "drop"}]}`,
+		err:    `invalid query rewriting configuration: rule "r": unknown action "drop", allowed values: rewrite, deny`,
+	}, {
+		config: `{"rules": [{"name": "r", "tables": ["t"], "action": "rewrite"}]}`,
+		err:    `invalid query rewriting configuration: rule "r": a rewrite rule needs one of index_hints, limit, max_execution_time_ms, tablet_type and keyspace`,
+	}, {
+		config: `{"rules": [{"name": "r", "tables": ["t"], "action": "deny", "limit": 10}]}`,
+		err:    `invalid query rewriting configuration: rule "r": a deny rule cannot rewrite the queries`,
+	}, {
+		config: `{"rules": [{"name": "r", "fingerprint": "selec", "action": "deny"}]}`,
+		err:    "syntax error at position 6 near 'selec'",
+	}, {
+		config: `{"rules": [{"name": "r", "comment": "(", "action": "deny"}]}`,
+		err:    "invalid query rewriting configuration: rule \"r\": invalid comment: error parsing regexp: missing closing ): `(`",
+	}, {
+		config: `{"rules": [{"name": "r", "tables": ["t"], "action": "rewrite", "tablet_type": "backup"}]}`,
+		err:    `invalid query rewriting configuration: rule "r": queries cannot be routed to backup tablets`,
+	}, {
+		config: `{"rules": [{"name": "r", "tables": ["t"], "action": "rewrite", "index_hints": [{"table": "t", "type": "prefer", "indexes": ["i"]}]}]}`,
+		err:    `invalid query rewriting configuration: rule "r": unknown index hint type "prefer", allowed values: use, force, ignore`,
+	}}
+	for _, tc := range tcases {
+		_, err := ParseConfig([]byte(tc.config))
+		assert.ErrorContains(t, err, tc.err, tc.config)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	e := newEngine(t, `{"rules": [
+		{"name": "hint", "tables": ["ks.orders"], "action": "rewrite", "index_hints": [{"table": "orders", "type": "force", "indexes": ["idx_created"]}]},
+		{"name": "limit", "tables": ["orders"], "action": "rewrite", "limit": 100},
+		{"name": "timeout", "fingerprint": "select * from orders where customer_id = ?", "action": "rewrite", "max_execution_time_ms": 500},
+		{"name": "reports", "user": "reporting", "action": "rewrite", "tablet_type": "rdonly", "keyspace": "reports"}
+	]}`)
+
+	tcases := []struct {
+		sql, keyspace, user string
+		out                 string
+		res                 *Result
+	}{{
+		sql:      "select * from orders where customer_id = 5",
+		keyspace: "ks",
+		out:      "select /*+ MAX_EXECUTION_TIME(500) */ * from orders force index (idx_created) where customer_id = 5 limit 100",
+		res:      &Result{Rules: []string{"hint", "limit", "timeout"}, Rewritten: true},
+	}, {
+		// the table is not in the keyspace of the hint rule, and the limit is already low enough
+		sql:      "select * from orders where customer_id = 5 limit 10",
+		keyspace: "other",
+		out:      "select * from orders where customer_id = 5 limit 10",
+		res:      &Result{Rules: []string{"limit"}},
+	}, {
+		sql:      "select * from ks.orders as o where customer_id = 5 limit 5, 1000",
+		keyspace: "other",
+		out:      "select * from ks.orders as o force index (idx_created) where customer_id = 5 limit 5, 100",
+		res:      &Result{Rules: []string{"hint", "limit"}, Rewritten: true},
+	}, {
+		// the limit is only added to the selects
+		sql:      "update orders set status = 1 where id = 2",
+		keyspace: "ks",
+		out:      "update orders force index (idx_created) set `status` = 1 where id = 2",
+		res:      &Result{Rules: []string{"hint", "limit"}, Rewritten: true},
+	}, {
+		sql:      "select * from customers",
+		keyspace: "ks",
+		user:     "reporting",
+		out:      "select * from customers",
+		res:      &Result{Rules: []string{"reports"}, TabletType: topodatapb.TabletType_RDONLY, Keyspace: "reports"},
+	}, {
+		sql:      "select * from customers",
+		keyspace: "ks",
+		user:     "app",
+		out:      "select * from customers",
+	}}
+	for _, tc := range tcases {
+		t.Run(tc.sql, func(t *testing.T) {
+			out, res, err := apply(t, e, tc.sql, tc.keyspace, tc.user)
+			require.NoError(t, err)
+			assert.Equal(t, tc.out, out)
+			assert.Equal(t, tc.res, res)
+		})
+	}
+}
+
+func TestDeny(t *testing.T) {
+	e := newEngine(t, `{"rules": [
+		{"name": "limit", "tables": ["t2"], "action": "rewrite", "limit": 10},
+		{"name": "batch", "description": "batch job hammering the primary", "comment": "job=cleanup", "action": "deny"},
+		{"name": "no-delete", "fingerprint": "delete from t", "user": "app", "action": "deny"}
+	]}`)
+
+	_, _, err := apply(t, e, "/* job=cleanup */ select * from t", "ks", "app")
+	require.EqualError(t, err, "disallowed due to rule: batch job hammering the primary")
+	_, _, err = apply(t, e, "select /* job=cleanup */ * from t", "ks", "app")
+	require.EqualError(t, err, "disallowed due to rule: batch job hammering the primary")
+	_, _, err = apply(t, e, "delete from t", "ks", "app")
+	require.EqualError(t, err, "disallowed due to rule: no-delete")
+
+	_, res, err := apply(t, e, "delete from t", "ks", "admin")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+	out, res, err := apply(t, e, "/* job=report */ select * from t2", "ks", "app")
+	require.NoError(t, err)
+	assert.Equal(t, "select * from t2 limit 10", out)
+	assert.Equal(t, []string{"limit"}, res.Rules)
+}
+
+func TestSetConfig(t *testing.T) {
+	e := newEngine(t, `{"rules": [{"name": "r", "tables": ["t"], "action": "deny"}]}`)
+	_, _, err := apply(t, e, "select * from t", "ks", "")
+	require.Error(t, err)
+
+	cfg, err := ParseConfig([]byte(`{"rules": []}`))
+	require.NoError(t, err)
+	e.SetConfig(cfg)
+	_, res, err := apply(t, e, "select * from t", "ks", "")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/topo"
+)
+
+var (
+	configFile     string
+	configTopoKey  string
+	reloadInterval = 30 * time.Second
+	dryRun         bool
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&configFile, "quota-config-file", "", "JSON file with the rules limiting the query rate, concurrent queries and concurrent transactions of the callers. The file is reloaded every --quota-config-reload-interval")
+	fs.StringVar(&configTopoKey, "quota-config-topo-key", "", "Name of the vitess metadata key in the global topo holding the JSON quota rules, as set with SET @@vitess_metadata.<key> = '<rules>'. Cannot be used with --quota-config-file")
+	fs.DurationVar(&reloadInterval, "quota-config-reload-interval", reloadInterval, "How often the quota rules are reloaded from --quota-config-file or --quota-config-topo-key")
+	fs.BoolVar(&dryRun, "quota-dry-run", false, "Log and count the queries and transactions over their quota, but do not reject them")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewLimiterFromFlags creates the Limiter configured by the flags, and keeps reloading its rules
+// until the context is done. It returns nil when no quota rules are configured.
+func NewLimiterFromFlags(ctx context.Context, ts *topo.Server) (*Limiter, error) {
+	var load func(context.Context) ([]byte, error)
+	switch {
+	case configFile != "" && configTopoKey != "":
+		return nil, errors.New("only one of --quota-config-file and --quota-config-topo-key can be set")
+	case configFile != "":
+		path := configFile
+		load = func(context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		}
+	case configTopoKey != "":
+		key := configTopoKey
+		load = func(ctx context.Context) ([]byte, error) {
+			return loadFromTopo(ctx, ts, key)
+		}
+	default:
+		return nil, nil
+	}
+
+	l := NewLimiter(dryRun)
+	// the first load has to succeed, we don't want to start without the quotas we were asked for
+	data, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	l.SetConfig(cfg)
+	go l.reloadLoop(ctx, load, data)
+	return l, nil
+}
+
+// loadFromTopo returns the value of the vitess metadata key, which is empty if the key does not exist
+func loadFromTopo(ctx context.Context, ts *topo.Server, key string) ([]byte, error) {
+	metadata, err := ts.GetMetadata(ctx, key)
+	if err != nil && !topo.IsErrType(err, topo.NoNode) {
+		return nil, err
+	}
+	return []byte(metadata[key]), nil
+}
+
+// reloadLoop reloads the rules every reloadInterval. When the rules cannot be loaded,
+// or are invalid, the previous rules are kept.
+func (l *Limiter) reloadLoop(ctx context.Context, load func(context.Context) ([]byte, error), last []byte) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		data, err := load(ctx)
+		if err != nil {
+			log.Warningf("Unable to reload the quota rules, keeping the previous ones: %v", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		cfg, err := ParseConfig(data)
+		if err != nil {
+			log.Warningf("Invalid quota rules, keeping the previous ones: %v", err)
+			continue
+		}
+		l.SetConfig(cfg)
+		log.Infof("Reloaded %d quota rules", len(cfg.Rules))
+	}
+}
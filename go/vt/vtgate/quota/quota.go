@@ -0,0 +1,344 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quota implements the admission control of vtgate: it limits the rate of queries,
+// the number of concurrent queries and the number of concurrent transactions of the callers,
+// identified by their immediate caller, effective caller and workload name.
+package quota
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+const (
+	// PerImmediateCaller applies the limits of a rule to every immediate caller separately
+	PerImmediateCaller = "immediate_caller"
+	// PerEffectiveCaller applies the limits of a rule to every effective caller separately
+	PerEffectiveCaller = "effective_caller"
+	// PerWorkload applies the limits of a rule to every workload separately
+	PerWorkload = "workload"
+
+	limitQPS          = "qps"
+	limitQueries      = "concurrent_queries"
+	limitTransactions = "concurrent_transactions"
+)
+
+var (
+	rejections = stats.NewCountersWithMultiLabels(
+		"QuotaRejections",
+		"Queries and transactions rejected because a quota was exceeded",
+		[]string{"Rule", "Limit"})
+	rejectionsDryRun = stats.NewCountersWithMultiLabels(
+		"QuotaRejectionsDryRun",
+		"Queries and transactions that would have been rejected because a quota was exceeded, in dry run mode",
+		[]string{"Rule", "Limit"})
+)
+
+// Config is the quota configuration, loaded from a JSON file or from the topo.
+type Config struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule limits the callers that match it. Every caller matching a rule has to be within its limits.
+// A limit of zero means there is no limit.
+type Rule struct {
+	Name string `json:"name"`
+
+	// ImmediateCaller, EffectiveCaller and Workload select the callers the rule applies to.
+	// An empty value matches any caller.
+	ImmediateCaller string `json:"immediate_caller,omitempty"`
+	EffectiveCaller string `json:"effective_caller,omitempty"`
+	Workload        string `json:"workload,omitempty"`
+
+	// Per lists the properties of the callers the limits are applied to separately.
+	// When empty, all the callers matching the rule share the same limits.
+	Per []string `json:"per,omitempty"`
+
+	// MaxQPS is the number of queries per second, with bursts of up to Burst queries.
+	// Burst defaults to MaxQPS, rounded up.
+	MaxQPS float64 `json:"max_qps,omitempty"`
+	Burst  int     `json:"burst,omitempty"`
+
+	MaxConcurrentQueries      int `json:"max_concurrent_queries,omitempty"`
+	MaxConcurrentTransactions int `json:"max_concurrent_transactions,omitempty"`
+}
+
+// ParseConfig parses and validates a JSON quota configuration. Empty data is a configuration without rules.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid quota configuration: %w", err)
+	}
+	names := make(map[string]bool)
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("invalid quota configuration: every rule needs a name")
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("invalid quota configuration: duplicate rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		for _, per := range r.Per {
+			switch per {
+			case PerImmediateCaller, PerEffectiveCaller, PerWorkload:
+			default:
+				return nil, fmt.Errorf("invalid quota configuration: rule %q: unknown per value %q, allowed values: %s, %s, %s", r.Name, per, PerImmediateCaller, PerEffectiveCaller, PerWorkload)
+			}
+		}
+		if r.MaxQPS < 0 || r.Burst < 0 || r.MaxConcurrentQueries < 0 || r.MaxConcurrentTransactions < 0 {
+			return nil, fmt.Errorf("invalid quota configuration: rule %q: limits cannot be negative", r.Name)
+		}
+	}
+	return cfg, nil
+}
+
+// Caller identifies who is sending a query.
+type Caller struct {
+	ImmediateCaller string
+	EffectiveCaller string
+	Workload        string
+}
+
+// Limiter checks the callers against the rules of the quota configuration.
+type Limiter struct {
+	dryRun bool
+
+	mu    sync.Mutex
+	rules []*rule
+	// transactions contains the function releasing the transaction slots, by session
+	transactions map[string]func()
+}
+
+// rule is a Rule with the state of its limits
+type rule struct {
+	*Rule
+	// buckets contains the state of the limits, by the key built from the Per properties of the caller
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	rule *rule
+	key  string
+
+	qps          *rate.Limiter
+	queries      int
+	transactions int
+}
+
+// NewLimiter creates a Limiter without any rules. In dry run mode, the callers over their limits
+// are logged and counted, but not rejected.
+func NewLimiter(dryRun bool) *Limiter {
+	return &Limiter{
+		dryRun:       dryRun,
+		transactions: make(map[string]func()),
+	}
+}
+
+// SetConfig replaces the rules of the limiter. The state of the rules that keep the same name is kept,
+// so the queries and transactions in flight are still counted.
+func (l *Limiter) SetConfig(cfg *Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	old := make(map[string]*rule, len(l.rules))
+	for _, r := range l.rules {
+		old[r.Name] = r
+	}
+	rules := make([]*rule, 0, len(cfg.Rules))
+	for _, cfgRule := range cfg.Rules {
+		r := &rule{Rule: cfgRule, buckets: make(map[string]*bucket)}
+		if prev, ok := old[cfgRule.Name]; ok {
+			r.buckets = prev.buckets
+			for _, b := range r.buckets {
+				b.rule = r
+				if b.qps.Limit() != r.limit() || b.qps.Burst() != r.burst() {
+					b.qps = rate.NewLimiter(r.limit(), r.burst())
+				}
+			}
+		}
+		rules = append(rules, r)
+	}
+	l.rules = rules
+}
+
+// StartQuery checks that the caller can run one more query, and counts it as running.
+// The returned function has to be called once the query is done.
+func (l *Limiter) StartQuery(caller Caller) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var taken []*bucket
+	for _, r := range l.rules {
+		if !r.matches(caller) {
+			continue
+		}
+		b := r.bucketFor(caller)
+		var err error
+		if r.MaxConcurrentQueries > 0 && b.queries >= r.MaxConcurrentQueries {
+			err = l.reject(r, limitQueries, caller)
+		}
+		if err == nil && r.MaxQPS > 0 && !b.qps.Allow() {
+			err = l.reject(r, limitQPS, caller)
+		}
+		if err != nil {
+			releaseQueries(taken)
+			return nil, err
+		}
+		b.queries++
+		taken = append(taken, b)
+	}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		releaseQueries(taken)
+	}, nil
+}
+
+// StartTransaction checks that the caller can open one more transaction, and counts it as open
+// until EndTransaction is called for the same session. Calling it again for a session that
+// already has an open transaction does nothing.
+func (l *Limiter) StartTransaction(session string, caller Caller) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.transactions[session]; ok {
+		return nil
+	}
+	var taken []*bucket
+	for _, r := range l.rules {
+		if !r.matches(caller) {
+			continue
+		}
+		b := r.bucketFor(caller)
+		if r.MaxConcurrentTransactions > 0 && b.transactions >= r.MaxConcurrentTransactions {
+			if err := l.reject(r, limitTransactions, caller); err != nil {
+				releaseTransactions(taken)
+				return err
+			}
+		}
+		b.transactions++
+		taken = append(taken, b)
+	}
+	l.transactions[session] = func() {
+		releaseTransactions(taken)
+	}
+	return nil
+}
+
+// EndTransaction releases the transaction slots taken by StartTransaction for the session.
+func (l *Limiter) EndTransaction(session string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if release, ok := l.transactions[session]; ok {
+		release()
+		delete(l.transactions, session)
+	}
+}
+
+func releaseQueries(buckets []*bucket) {
+	for _, b := range buckets {
+		b.queries--
+		b.prune()
+	}
+}
+
+func releaseTransactions(buckets []*bucket) {
+	for _, b := range buckets {
+		b.transactions--
+		b.prune()
+	}
+}
+
+// prune forgets about the bucket if it is back to its initial state,
+// so we don't keep a bucket for every caller we have ever seen
+func (b *bucket) prune() {
+	if b.queries == 0 && b.transactions == 0 && b.qps.Tokens() >= float64(b.qps.Burst()) {
+		delete(b.rule.buckets, b.key)
+	}
+}
+
+// reject counts the rejection, and returns the error to send to the caller,
+// or nil if we are in dry run mode
+func (l *Limiter) reject(r *rule, limit string, caller Caller) error {
+	if l.dryRun {
+		log.Infof("Quota: DRY RUN: %s limit of rule %s exceeded by %+v", limit, r.Name, caller)
+		rejectionsDryRun.Add([]string{r.Name, limit}, 1)
+		return nil
+	}
+	rejections.Add([]string{r.Name, limit}, 1)
+	return vterrors.Errorf(vtrpcpb.Code_RESOURCE_EXHAUSTED, "quota exceeded: %s limit of rule %s, retry later", strings.ReplaceAll(limit, "_", " "), r.Name)
+}
+
+func (r *rule) matches(caller Caller) bool {
+	return (r.ImmediateCaller == "" || r.ImmediateCaller == caller.ImmediateCaller) &&
+		(r.EffectiveCaller == "" || r.EffectiveCaller == caller.EffectiveCaller) &&
+		(r.Workload == "" || r.Workload == caller.Workload)
+}
+
+func (r *rule) bucketFor(caller Caller) *bucket {
+	var parts []string
+	for _, per := range r.Per {
+		switch per {
+		case PerImmediateCaller:
+			parts = append(parts, caller.ImmediateCaller)
+		case PerEffectiveCaller:
+			parts = append(parts, caller.EffectiveCaller)
+		case PerWorkload:
+			parts = append(parts, caller.Workload)
+		}
+	}
+	key := strings.Join(parts, "/")
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{rule: r, key: key, qps: rate.NewLimiter(r.limit(), r.burst())}
+		r.buckets[key] = b
+	}
+	return b
+}
+
+func (r *rule) limit() rate.Limit {
+	if r.MaxQPS == 0 {
+		return rate.Inf
+	}
+	return rate.Limit(r.MaxQPS)
+}
+
+func (r *rule) burst() int {
+	if r.MaxQPS == 0 {
+		return 0
+	}
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return int(math.Ceil(r.MaxQPS))
+}
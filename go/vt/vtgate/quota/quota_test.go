@@ -0,0 +1,254 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+func newTestLimiter(t *testing.T, dryRun bool, config string) *Limiter {
+	cfg, err := ParseConfig([]byte(config))
+	require.NoError(t, err)
+	l := NewLimiter(dryRun)
+	l.SetConfig(cfg)
+	return l
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{{
+		config: ``,
+	}, {
+		config: `{"rules": [{"name": "r1", "immediate_caller": "u1", "per": ["workload"], "max_qps": 10}]}`,
+	}, {
+		config: `{"rules": [{"max_qps": 10}]}`,
+		err:    "invalid quota configuration: every rule needs a name",
+	}, {
+		config: `{"rules": [{"name": "r1"}, {"name": "r1"}]}`,
+		err:    `invalid quota configuration: duplicate rule name "r1"`,
+	}, {
+		config: `{"rules": [{"name": "r1", "per": ["keyspace"]}]}`,
+		err:    `invalid quota configuration: rule "r1": unknown per value "keyspace", allowed values: immediate_caller, effective_caller, workload`,
+	}, {
+		config: `{"rules": [{"name": "r1", "max_concurrent_queries": -1}]}`,
+		err:    `invalid quota configuration: rule "r1": limits cannot be negative`,
+	}, {
+		config: `{"rules": [`,
+		err:    "invalid quota configuration: unexpected end of JSON input",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.config))
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestConcurrentQueries(t *testing.T) {
+	l := newTestLimiter(t, false, `{"rules": [{"name": "per_user", "per": ["immediate_caller"], "max_concurrent_queries": 2}]}`)
+	u1 := Caller{ImmediateCaller: "u1"}
+	u2 := Caller{ImmediateCaller: "u2"}
+
+	release1, err := l.StartQuery(u1)
+	require.NoError(t, err)
+	release2, err := l.StartQuery(u1)
+	require.NoError(t, err)
+
+	_, err = l.StartQuery(u1)
+	require.EqualError(t, err, "quota exceeded: concurrent queries limit of rule per_user, retry later")
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+
+	// the other users have their own limit
+	release3, err := l.StartQuery(u2)
+	require.NoError(t, err)
+	release3()
+
+	release1()
+	release1, err = l.StartQuery(u1)
+	require.NoError(t, err)
+
+	release1()
+	release2()
+	assert.Empty(t, l.rules[0].buckets)
+}
+
+func TestQPS(t *testing.T) {
+	l := newTestLimiter(t, false, `{"rules": [{"name": "olap", "workload": "olap", "max_qps": 1, "burst": 2}]}`)
+	olap := Caller{ImmediateCaller: "u1", Workload: "olap"}
+
+	for i := 0; i < 2; i++ {
+		release, err := l.StartQuery(olap)
+		require.NoError(t, err)
+		release()
+	}
+	_, err := l.StartQuery(olap)
+	require.EqualError(t, err, "quota exceeded: qps limit of rule olap, retry later")
+
+	// the rule does not match the other workloads
+	release, err := l.StartQuery(Caller{ImmediateCaller: "u1", Workload: "oltp"})
+	require.NoError(t, err)
+	release()
+}
+
+func TestRejectedQueryReleasesOtherRules(t *testing.T) {
+	l := newTestLimiter(t, false, `{"rules": [
+		{"name": "all", "max_concurrent_queries": 10},
+		{"name": "u1", "immediate_caller": "u1", "max_concurrent_queries": 1}
+	]}`)
+	u1 := Caller{ImmediateCaller: "u1"}
+
+	release, err := l.StartQuery(u1)
+	require.NoError(t, err)
+	_, err = l.StartQuery(u1)
+	require.Error(t, err)
+	assert.Equal(t, 1, l.rules[0].buckets[""].queries)
+	release()
+}
+
+func TestConcurrentTransactions(t *testing.T) {
+	l := newTestLimiter(t, false, `{"rules": [{"name": "tx", "effective_caller": "app", "max_concurrent_transactions": 1}]}`)
+	app := Caller{ImmediateCaller: "u1", EffectiveCaller: "app"}
+
+	require.NoError(t, l.StartTransaction("s1", app))
+	// the same session can keep using its transaction
+	require.NoError(t, l.StartTransaction("s1", app))
+
+	err := l.StartTransaction("s2", app)
+	require.EqualError(t, err, "quota exceeded: concurrent transactions limit of rule tx, retry later")
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+
+	l.EndTransaction("s1")
+	require.NoError(t, l.StartTransaction("s2", app))
+	l.EndTransaction("s2")
+	l.EndTransaction("s2")
+	assert.Empty(t, l.rules[0].buckets)
+}
+
+func TestDryRun(t *testing.T) {
+	l := newTestLimiter(t, true, `{"rules": [{"name": "dry", "max_concurrent_queries": 1, "max_concurrent_transactions": 1}]}`)
+	before := rejectionsDryRun.Counts()["dry.concurrent_queries"]
+
+	release1, err := l.StartQuery(Caller{})
+	require.NoError(t, err)
+	release2, err := l.StartQuery(Caller{})
+	require.NoError(t, err)
+	release1()
+	release2()
+	assert.Equal(t, before+1, rejectionsDryRun.Counts()["dry.concurrent_queries"])
+
+	require.NoError(t, l.StartTransaction("s1", Caller{}))
+	require.NoError(t, l.StartTransaction("s2", Caller{}))
+}
+
+func TestSetConfigKeepsState(t *testing.T) {
+	l := newTestLimiter(t, false, `{"rules": [{"name": "r1", "max_concurrent_queries": 1}]}`)
+	release, err := l.StartQuery(Caller{})
+	require.NoError(t, err)
+
+	cfg, err := ParseConfig([]byte(`{"rules": [{"name": "r1", "max_concurrent_queries": 1, "max_qps": 100}]}`))
+	require.NoError(t, err)
+	l.SetConfig(cfg)
+
+	// the query started with the previous rules still counts
+	_, err = l.StartQuery(Caller{})
+	require.Error(t, err)
+	release()
+	release, err = l.StartQuery(Caller{})
+	require.NoError(t, err)
+	release()
+
+	l.SetConfig(&Config{})
+	release, err = l.StartQuery(Caller{})
+	require.NoError(t, err)
+	release()
+}
+
+func TestNewLimiterFromFlags(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer func() {
+		configFile = ""
+		configTopoKey = ""
+		reloadInterval = 30 * time.Second
+	}()
+	reloadInterval = 10 * time.Millisecond
+
+	l, err := NewLimiterFromFlags(ctx, nil)
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	configFile = filepath.Join(t.TempDir(), "quotas.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"rules": [{"name": "r1", "max_concurrent_queries": 1}]}`), 0600))
+	l, err = NewLimiterFromFlags(ctx, nil)
+	require.NoError(t, err)
+	release, err := l.StartQuery(Caller{})
+	require.NoError(t, err)
+	_, err = l.StartQuery(Caller{})
+	require.Error(t, err)
+	release()
+
+	// the rules are reloaded, and invalid rules are ignored
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"rules": [{"name": "r1", "max_concurrent_queries": 2}]}`), 0600))
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.rules[0].MaxConcurrentQueries == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"rules": [`), 0600))
+	time.Sleep(50 * time.Millisecond)
+	l.mu.Lock()
+	assert.Equal(t, 2, l.rules[0].MaxConcurrentQueries)
+	l.mu.Unlock()
+
+	configTopoKey = "quotas"
+	_, err = NewLimiterFromFlags(ctx, nil)
+	require.EqualError(t, err, "only one of --quota-config-file and --quota-config-topo-key can be set")
+	configFile = ""
+
+	ts := memorytopo.NewServer(ctx, "cell1")
+	defer ts.Close()
+	l, err = NewLimiterFromFlags(ctx, ts)
+	require.NoError(t, err)
+	l.mu.Lock()
+	assert.Empty(t, l.rules)
+	l.mu.Unlock()
+
+	require.NoError(t, ts.UpsertMetadata(ctx, "quotas", `{"rules": [{"name": "from_topo", "max_qps": 5}]}`))
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return len(l.rules) == 1 && l.rules[0].Name == "from_topo"
+	}, 5*time.Second, 10*time.Millisecond)
+}
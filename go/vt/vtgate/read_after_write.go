@@ -0,0 +1,255 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/queryservice"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// Read-after-write consistency: when a session tracks its GTIDs (set session_track_gtids = own_gtid),
+// the GTIDs of its writes are kept in its read_after_write_gtid, by shard. The replica reads of the
+// session then wait until their replica has applied the GTIDs of its shard, and are sent to the
+// primary if it takes longer than the read_after_write_timeout. A GTID set can also be given
+// directly with read_after_write_gtid, and is then waited for on every shard.
+//
+// The GTID of a write is reported by MySQL when session_track_gtids is enabled on the MySQL server,
+// but not for the commits of the transactions. The shard is then only marked as written, and the
+// first replica read of the shard captures the GTIDs executed by the primary, which include the write.
+
+// readAfterWriteGTIDs are the GTIDs waited for by the replica reads of a session.
+// They are written as semicolon-separated entries: the GTID set of all the shards
+// first, if any, then the "keyspace/shard|GTID set" entries of the shards. The GTID
+// set of a shard is empty until it is captured, when the GTID of its write is unknown.
+type readAfterWriteGTIDs struct {
+	all    string
+	shards map[string]string
+}
+
+func parseReadAfterWriteGTIDs(s string) readAfterWriteGTIDs {
+	var gtids readAfterWriteGTIDs
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		shard, gtid, ok := strings.Cut(entry, "|")
+		if !ok {
+			gtids.all = entry
+			continue
+		}
+		if gtids.shards == nil {
+			gtids.shards = make(map[string]string)
+		}
+		gtids.shards[shard] = gtid
+	}
+	return gtids
+}
+
+func (gtids readAfterWriteGTIDs) String() string {
+	entries := make([]string, 0, len(gtids.shards)+1)
+	for shard, gtid := range gtids.shards {
+		entries = append(entries, shard+"|"+gtid)
+	}
+	sort.Strings(entries)
+	if gtids.all != "" {
+		entries = append([]string{gtids.all}, entries...)
+	}
+	return strings.Join(entries, ";")
+}
+
+func (gtids readAfterWriteGTIDs) empty() bool {
+	return gtids.all == "" && len(gtids.shards) == 0
+}
+
+// forShard returns the GTID set the replicas of the shard have to apply, and false if it is unknown
+// because it was not captured since the last write of the shard.
+func (gtids readAfterWriteGTIDs) forShard(keyspace, shard string) (string, bool) {
+	if gtid, ok := gtids.shards[keyspace+"/"+shard]; ok {
+		return gtid, gtid != ""
+	}
+	return gtids.all, true
+}
+
+// track records the GTIDs of a write on the shard, or marks the shard as written if they are unknown.
+// Since MySQL only reports the GTID of the last transaction of a connection, the GTIDs are added to
+// the ones of the shard if they are a MySQL GTID set. A shard whose GTIDs are unknown stays so,
+// until they are captured.
+func (gtids *readAfterWriteGTIDs) track(keyspace, shard, gtid string) {
+	gtid = strings.Join(strings.Fields(gtid), "")
+	key := keyspace + "/" + shard
+	if previous, ok := gtids.shards[key]; ok && gtid != "" {
+		if previous == "" {
+			return
+		}
+		prevSet, err1 := replication.ParseMysql56GTIDSet(previous)
+		newSet, err2 := replication.ParseMysql56GTIDSet(gtid)
+		if err1 == nil && err2 == nil {
+			gtid = prevSet.Union(newSet).String()
+		}
+	}
+	if gtids.shards == nil {
+		gtids.shards = make(map[string]string)
+	}
+	gtids.shards[key] = gtid
+}
+
+// capture sets the GTIDs executed by the primary of the shard, if its GTIDs are unknown.
+// They include the ones of the writes of the session.
+func (gtids *readAfterWriteGTIDs) capture(keyspace, shard, gtid string) {
+	key := keyspace + "/" + shard
+	if previous, ok := gtids.shards[key]; ok && previous == "" {
+		gtids.shards[key] = strings.Join(strings.Fields(gtid), "")
+	}
+}
+
+// TracksGTIDs returns true if the GTIDs of the writes of the session are tracked,
+// for its replica reads to wait for them.
+func (session *SafeSession) TracksGTIDs() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.ReadAfterWrite != nil && session.ReadAfterWrite.SessionTrackGtids
+}
+
+// TrackWriteGTID records the GTID of a write of the session on a primary. An empty GTID marks the shard
+// as written, for its GTIDs to be captured by its next replica read.
+func (session *SafeSession) TrackWriteGTID(target *querypb.Target, gtid string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.ReadAfterWrite == nil {
+		session.ReadAfterWrite = &vtgatepb.ReadAfterWrite{}
+	}
+	gtids := parseReadAfterWriteGTIDs(session.ReadAfterWrite.ReadAfterWriteGtid)
+	gtids.track(target.Keyspace, target.Shard, gtid)
+	session.ReadAfterWrite.ReadAfterWriteGtid = gtids.String()
+}
+
+// captureWriteGTID records the GTIDs executed by the primary of a shard whose GTIDs are unknown.
+func (session *SafeSession) captureWriteGTID(target *querypb.Target, gtid string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.ReadAfterWrite == nil {
+		return
+	}
+	gtids := parseReadAfterWriteGTIDs(session.ReadAfterWrite.ReadAfterWriteGtid)
+	gtids.capture(target.Keyspace, target.Shard, gtid)
+	session.ReadAfterWrite.ReadAfterWriteGtid = gtids.String()
+}
+
+// readAfterWrite returns the GTIDs the replica reads of the session wait for, and how long they wait for them.
+func (session *SafeSession) readAfterWrite() (readAfterWriteGTIDs, time.Duration) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.ReadAfterWrite == nil || session.ReadAfterWrite.ReadAfterWriteGtid == "" {
+		return readAfterWriteGTIDs{}, 0
+	}
+	timeout := readAfterWriteTimeout
+	if t := session.ReadAfterWrite.ReadAfterWriteTimeout; t > 0 {
+		timeout = time.Duration(t * float64(time.Second))
+	}
+	return parseReadAfterWriteGTIDs(session.ReadAfterWrite.ReadAfterWriteGtid), timeout
+}
+
+type readAfterWriteKey struct{}
+
+type readAfterWrite struct {
+	session *SafeSession
+	timeout time.Duration
+
+	mu    sync.Mutex
+	gtids readAfterWriteGTIDs
+}
+
+// withReadAfterWrite returns a context making the replica reads run with it wait
+// for the previous writes of the session.
+func withReadAfterWrite(ctx context.Context, session *SafeSession) context.Context {
+	gtids, timeout := session.readAfterWrite()
+	if gtids.empty() {
+		return ctx
+	}
+	return context.WithValue(ctx, readAfterWriteKey{}, &readAfterWrite{session: session, gtids: gtids, timeout: timeout})
+}
+
+// captureFunc returns the GTIDs executed by the primary of the shard of the target.
+type captureFunc func(ctx context.Context, target *querypb.Target) (string, error)
+
+// readAfterWriteGTID returns the GTID set the replica of the target has to apply before running a query,
+// and how long the query waits for it. If the GTIDs of the shard are unknown, they are captured first,
+// and kept in the session for its next reads.
+func readAfterWriteGTID(ctx context.Context, target *querypb.Target, capture captureFunc) (string, time.Duration, error) {
+	raw, ok := ctx.Value(readAfterWriteKey{}).(*readAfterWrite)
+	if !ok {
+		return "", 0, nil
+	}
+	raw.mu.Lock()
+	gtid, known := raw.gtids.forShard(target.Keyspace, target.Shard)
+	raw.mu.Unlock()
+	if known {
+		return gtid, raw.timeout, nil
+	}
+
+	gtid, err := capture(ctx, target)
+	if err != nil {
+		return "", 0, err
+	}
+	raw.mu.Lock()
+	raw.gtids.capture(target.Keyspace, target.Shard, gtid)
+	raw.mu.Unlock()
+	raw.session.captureWriteGTID(target, gtid)
+	return gtid, raw.timeout, nil
+}
+
+const executedGTIDsQuery = "select @@global.gtid_executed"
+
+// trackWriteGTID records the GTID of a write executed on a primary, for the session to read its writes
+// on the replicas. The GTID is reported by MySQL in the result of the write if session_track_gtids is
+// enabled on the MySQL server. Otherwise, the shard is marked as written: its GTIDs are only captured
+// if a replica read of the session needs them.
+func trackWriteGTID(session *SafeSession, target *querypb.Target, qr *sqltypes.Result) {
+	if target.TabletType != topodatapb.TabletType_PRIMARY || !session.TracksGTIDs() {
+		return
+	}
+	var gtid string
+	if qr != nil {
+		gtid = qr.SessionStateChanges
+	}
+	session.TrackWriteGTID(target, gtid)
+}
+
+func executedGTIDs(ctx context.Context, qs queryservice.QueryService, target *querypb.Target) (string, error) {
+	qr, err := qs.Execute(ctx, target, executedGTIDsQuery, nil, 0, 0, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+		return "", vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected result for %s: %v", executedGTIDsQuery, qr.Rows)
+	}
+	return qr.Rows[0][0].ToString(), nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadAfterWriteGTIDs(t *testing.T) {
+	const uuid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	const uuid2 = "4e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+	// a GTID set given by the user is waited for on every shard
+	gtids := parseReadAfterWriteGTIDs(uuid1 + ":1-5")
+	assertGTID(t, uuid1+":1-5", gtids, "ks", "-80")
+	assert.Equal(t, uuid1+":1-5", gtids.String())
+
+	gtids.track("ks", "80-", uuid2+":1-3,\n"+uuid1+":1-2")
+	assertGTID(t, uuid1+":1-5", gtids, "ks", "-80")
+	assertGTID(t, uuid2+":1-3,"+uuid1+":1-2", gtids, "ks", "80-")
+
+	// the GTIDs of a shard are added up
+	gtids.track("ks", "80-", uuid2+":4")
+	gtids.track("other", "0", uuid1+":7")
+	s := gtids.String()
+	assert.Equal(t, uuid1+":1-5;ks/80-|"+uuid1+":1-2,"+uuid2+":1-4;other/0|"+uuid1+":7", s)
+	assert.Equal(t, gtids, parseReadAfterWriteGTIDs(s))
+
+	// only MySQL GTID sets are added up
+	gtids.track("other", "0", "0-1-10")
+	assertGTID(t, "0-1-10", gtids, "other", "0")
+
+	// a write without a GTID marks the shard until its GTIDs are captured
+	gtids.track("ks", "-80", "")
+	gtid, known := gtids.forShard("ks", "-80")
+	assert.Empty(t, gtid)
+	assert.False(t, known)
+	gtids.track("ks", "-80", uuid1+":8")
+	_, known = gtids.forShard("ks", "-80")
+	assert.False(t, known)
+	assert.Equal(t, gtids, parseReadAfterWriteGTIDs(gtids.String()))
+	gtids.capture("ks", "-80", uuid1+":1-8,\n"+uuid2+":1-4")
+	assertGTID(t, uuid1+":1-8,"+uuid2+":1-4", gtids, "ks", "-80")
+
+	// the GTIDs of a shard that were not captured are kept
+	gtids.capture("ks", "-80", uuid1+":1-9")
+	assertGTID(t, uuid1+":1-8,"+uuid2+":1-4", gtids, "ks", "-80")
+	gtids.capture("ks", "c0-", uuid1+":1-9")
+	assertGTID(t, uuid1+":1-5", gtids, "ks", "c0-")
+
+	assert.True(t, parseReadAfterWriteGTIDs("").empty())
+}
+
+func assertGTID(t *testing.T, want string, gtids readAfterWriteGTIDs, keyspace, shard string) {
+	t.Helper()
+	gtid, known := gtids.forShard(keyspace, shard)
+	assert.True(t, known)
+	assert.Equal(t, want, gtid)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resultcache
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+var (
+	maxMemory  int64
+	defaultTTL = 30 * time.Second
+	tables     []string
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.Int64Var(&maxMemory, "result-cache-memory", maxMemory, "Maximum memory in bytes used by the cache of the results of read-only queries. The cache is disabled when set to 0")
+	fs.DurationVar(&defaultTTL, "result-cache-default-ttl", defaultTTL, "How long the results are cached, for the queries using the RESULT_CACHE directive without RESULT_CACHE_TTL_MS, and the queries of --result-cache-tables")
+	fs.StringSliceVar(&tables, "result-cache-tables", tables, "Comma separated list of keyspace.table whose queries are cached without a RESULT_CACHE directive, when they only read these tables. The cached results can miss the writes made through other vtgates until they are invalidated by the VStream of their keyspace")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewCacheFromFlags creates the Cache configured by the flags, whose results are invalidated
+// by the given stream. It returns nil when the cache is disabled.
+func NewCacheFromFlags(stream StreamFunc) *Cache {
+	if maxMemory <= 0 {
+		return nil
+	}
+	c := NewCache(maxMemory, stream)
+	c.SetDefaultTTL(defaultTTL)
+	c.SetCachedTables(tables)
+	return c
+}
@@ -0,0 +1,369 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resultcache caches the results of read-only queries in vtgate.
+//
+// The results are invalidated by the row change events of the tables they read,
+// received through a VStream per keyspace. The VStream events arrive shortly after
+// the changes are committed, so a cached result can be slightly stale: the cache
+// is meant for tables that are read much more often than they are written,
+// like configuration tables. Every result also expires after its TTL, which bounds
+// how stale a result can be if an invalidation is missed.
+//
+// The writes made through the vtgate invalidate the results of their tables as soon as
+// they are committed, so the sessions read their own writes. The writes made through
+// another vtgate, or directly on the tablets, are only seen once the VStream events arrive.
+package resultcache
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/cache/theine"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/log"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+var (
+	hits          = stats.NewCounter("ResultCacheHits", "Queries answered from the vtgate result cache")
+	misses        = stats.NewCounter("ResultCacheMisses", "Cacheable queries that were not in the vtgate result cache")
+	invalidations = stats.NewCountersWithSingleLabel("ResultCacheInvalidations", "Invalidations of the vtgate result cache, by table", "Table")
+)
+
+// Key identifies a cached result. It is a hash of the query and of everything its result depends on.
+type Key = theine.HashKey256
+
+// StreamFunc streams the row change events of the tables of the keyspace, from the current position,
+// until the context is done or the stream fails. The table names of the row events are qualified
+// with the keyspace name.
+type StreamFunc func(ctx context.Context, keyspace string, tables []string, send func([]*binlogdatapb.VEvent) error) error
+
+// Cache is the result cache. It is safe for concurrent use.
+type Cache struct {
+	store  *theine.Store[Key, *entry]
+	stream StreamFunc
+
+	// defaultTTL is used by the queries asking for the cache without a TTL
+	defaultTTL time.Duration
+	// cachedTables are the qualified names of the tables whose queries are always cached
+	cachedTables map[string]bool
+
+	// retryDelay is how long we wait before restarting a failed VStream
+	retryDelay time.Duration
+	now        func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// generations is incremented every time a table changes, by qualified table name.
+	// A result is valid as long as the generations of its tables did not change.
+	generations map[string]uint64
+	// watchers holds the invalidation stream of every keyspace with cached tables
+	watchers map[string]*watcher
+}
+
+type entry struct {
+	result      *sqltypes.Result
+	tables      []string
+	generations []uint64
+	expires     time.Time
+}
+
+// CachedSize returns the memory used by the entry, as required by the theine cache.
+func (e *entry) CachedSize(alloc bool) int64 {
+	var size int64
+	if alloc {
+		size += 80
+	}
+	size += e.result.CachedSize(true)
+	for _, t := range e.tables {
+		size += 16 + int64(len(t))
+	}
+	size += 8 * int64(len(e.generations))
+	return size
+}
+
+// watcher follows the row changes of the cached tables of a keyspace
+type watcher struct {
+	keyspace string
+	tables   map[string]bool
+	// ready is true while the stream is running, and we can rely on it to invalidate the results
+	ready bool
+	// changed is true when tables were added since the stream started
+	changed bool
+	cancel  context.CancelFunc
+}
+
+// NewCache creates a cache using up to maxMemory bytes, whose results are invalidated by the given stream.
+func NewCache(maxMemory int64, stream StreamFunc) *Cache {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Cache{
+		store:        theine.NewStore[Key, *entry](maxMemory, false),
+		stream:       stream,
+		defaultTTL:   30 * time.Second,
+		cachedTables: make(map[string]bool),
+		retryDelay:   5 * time.Second,
+		now:          time.Now,
+		ctx:          ctx,
+		cancel:       cancel,
+		generations:  make(map[string]uint64),
+		watchers:     make(map[string]*watcher),
+	}
+}
+
+// SetDefaultTTL sets how long the results are cached when the query does not specify a TTL.
+func (c *Cache) SetDefaultTTL(ttl time.Duration) {
+	c.defaultTTL = ttl
+}
+
+// SetCachedTables sets the tables whose queries are cached without a directive,
+// when they only read these tables. The tables are qualified with their keyspace name.
+func (c *Cache) SetCachedTables(tables []string) {
+	c.cachedTables = make(map[string]bool, len(tables))
+	for _, table := range tables {
+		c.cachedTables[strings.TrimSpace(table)] = true
+	}
+}
+
+// TTL returns how long the result of a query reading the tables is cached, or 0 if it is not cached.
+// The query asks for the cache with the RESULT_CACHE directive, optionally with its own TTL.
+func (c *Cache) TTL(requested bool, ttl time.Duration, tables []string) time.Duration {
+	if len(tables) == 0 {
+		return 0
+	}
+	if requested {
+		if ttl > 0 {
+			return ttl
+		}
+		return c.defaultTTL
+	}
+	for _, table := range tables {
+		if !c.cachedTables[table] {
+			return 0
+		}
+	}
+	return c.defaultTTL
+}
+
+// Close stops the invalidation streams and empties the cache.
+func (c *Cache) Close() {
+	c.cancel()
+	c.wg.Wait()
+	c.store.Close()
+}
+
+// Get returns a copy of the cached result, if it is still valid.
+func (c *Cache) Get(key Key) (*sqltypes.Result, bool) {
+	e, ok := c.store.Get(key, 0)
+	if !ok {
+		misses.Add(1)
+		return nil, false
+	}
+	if !c.valid(e) {
+		c.store.Delete(key)
+		misses.Add(1)
+		return nil, false
+	}
+	hits.Add(1)
+	return e.result.Copy(), true
+}
+
+func (c *Cache) valid(e *entry) bool {
+	if c.now().After(e.expires) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, table := range e.tables {
+		w := c.watchers[keyspaceOf(table)]
+		if w == nil || !w.ready || c.generations[table] != e.generations[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Generations is the state of the tables of a query, taken before running it.
+type Generations struct {
+	tables      []string
+	generations []uint64
+	ready       bool
+}
+
+// Snapshot returns the state of the tables, which has to be taken before running the query
+// whose result is going to be cached, so the changes made while it runs are not missed.
+// The tables are qualified with their keyspace name. The cache starts following the changes
+// of the tables it does not know yet.
+func (c *Cache) Snapshot(tables []string) Generations {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := Generations{
+		tables:      tables,
+		generations: make([]uint64, len(tables)),
+		ready:       true,
+	}
+	for i, table := range tables {
+		if !c.watchLocked(table) {
+			g.ready = false
+		}
+		g.generations[i] = c.generations[table]
+	}
+	return g
+}
+
+// Set caches the result of a query, unless its tables changed since the snapshot was taken.
+func (c *Cache) Set(key Key, result *sqltypes.Result, g Generations, ttl time.Duration) {
+	if !g.ready || ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	for i, table := range g.tables {
+		w := c.watchers[keyspaceOf(table)]
+		if w == nil || !w.ready || c.generations[table] != g.generations[i] {
+			c.mu.Unlock()
+			return
+		}
+	}
+	c.mu.Unlock()
+
+	e := &entry{
+		result:      result.Copy(),
+		tables:      g.tables,
+		generations: g.generations,
+		expires:     c.now().Add(ttl),
+	}
+	c.store.Set(key, e, 0, 0)
+}
+
+// Invalidate invalidates the cached results that read the table, qualified with its keyspace name.
+func (c *Cache) Invalidate(table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidateLocked(table)
+}
+
+func (c *Cache) invalidateLocked(table string) {
+	c.generations[table]++
+	invalidations.Add(table, 1)
+}
+
+// invalidateKeyspaceLocked invalidates the cached results of all the tables of the keyspace
+func (c *Cache) invalidateKeyspaceLocked(w *watcher) {
+	for table := range w.tables {
+		c.invalidateLocked(w.keyspace + "." + table)
+	}
+}
+
+// watchLocked makes sure the changes of the table are followed, and returns true
+// if its invalidation stream is running.
+func (c *Cache) watchLocked(table string) bool {
+	keyspace := keyspaceOf(table)
+	name := strings.TrimPrefix(table, keyspace+".")
+	w, ok := c.watchers[keyspace]
+	if !ok {
+		w = &watcher{keyspace: keyspace, tables: map[string]bool{name: true}}
+		c.watchers[keyspace] = w
+		c.wg.Add(1)
+		go c.watch(w)
+		return false
+	}
+	if !w.tables[name] {
+		// restart the stream to follow the new table as well
+		w.tables[name] = true
+		w.changed = true
+		w.ready = false
+		if w.cancel != nil {
+			w.cancel()
+		}
+		return false
+	}
+	return w.ready
+}
+
+// watch runs the invalidation stream of the keyspace until the cache is closed
+func (c *Cache) watch(w *watcher) {
+	defer c.wg.Done()
+	for {
+		c.mu.Lock()
+		tables := make([]string, 0, len(w.tables))
+		for table := range w.tables {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		// we may have missed changes while the stream was not running
+		c.invalidateKeyspaceLocked(w)
+		w.changed = false
+		ctx, cancel := context.WithCancel(c.ctx)
+		w.cancel = cancel
+		c.mu.Unlock()
+
+		err := c.stream(ctx, w.keyspace, tables, func(events []*binlogdatapb.VEvent) error {
+			c.handleEvents(w, events)
+			return nil
+		})
+		cancel()
+
+		c.mu.Lock()
+		w.ready = false
+		w.cancel = nil
+		changed := w.changed
+		c.mu.Unlock()
+
+		if c.ctx.Err() != nil {
+			return
+		}
+		if changed {
+			continue
+		}
+		log.Warningf("Result cache invalidation stream of keyspace %s stopped, restarting it in %v: %v", w.keyspace, c.retryDelay, err)
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(c.retryDelay):
+		}
+	}
+}
+
+func (c *Cache) handleEvents(w *watcher, events []*binlogdatapb.VEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// the first events tell us the stream is running
+	w.ready = true
+	for _, ev := range events {
+		switch ev.Type {
+		case binlogdatapb.VEventType_ROW:
+			c.invalidateLocked(ev.RowEvent.TableName)
+		case binlogdatapb.VEventType_DDL:
+			// the table could have been renamed or dropped, let's not guess which one
+			c.invalidateKeyspaceLocked(w)
+		}
+	}
+}
+
+// keyspaceOf returns the keyspace of a qualified table name
+func keyspaceOf(table string) string {
+	keyspace, _, _ := strings.Cut(table, ".")
+	return keyspace
+}
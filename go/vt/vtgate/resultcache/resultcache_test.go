@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resultcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+// fakeStream is a StreamFunc whose streams are driven by the test
+type fakeStream struct {
+	started chan *stream
+}
+
+type stream struct {
+	keyspace string
+	tables   []string
+	send     func([]*binlogdatapb.VEvent) error
+	fail     chan error
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{started: make(chan *stream, 10)}
+}
+
+func (f *fakeStream) stream(ctx context.Context, keyspace string, tables []string, send func([]*binlogdatapb.VEvent) error) error {
+	s := &stream{keyspace: keyspace, tables: tables, send: send, fail: make(chan error, 1)}
+	f.started <- s
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-s.fail:
+		return err
+	}
+}
+
+func (f *fakeStream) next(t *testing.T) *stream {
+	t.Helper()
+	select {
+	case s := <-f.started:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("the invalidation stream was not started")
+		return nil
+	}
+}
+
+func heartbeat() []*binlogdatapb.VEvent {
+	return []*binlogdatapb.VEvent{{Type: binlogdatapb.VEventType_HEARTBEAT}}
+}
+
+func rowEvent(table string) []*binlogdatapb.VEvent {
+	return []*binlogdatapb.VEvent{{Type: binlogdatapb.VEventType_ROW, RowEvent: &binlogdatapb.RowEvent{TableName: table}}}
+}
+
+var result = sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1", "2")
+
+func TestCache(t *testing.T) {
+	f := newFakeStream()
+	c := NewCache(1024*1024, f.stream)
+	defer c.Close()
+
+	key := Key{1}
+	tables := []string{"ks.t1"}
+
+	// the stream of the keyspace is not running yet: the result is not cached
+	g := c.Snapshot(tables)
+	s := f.next(t)
+	assert.Equal(t, "ks", s.keyspace)
+	assert.Equal(t, []string{"t1"}, s.tables)
+	c.Set(key, result, g, time.Minute)
+	_, ok := c.Get(key)
+	require.False(t, ok)
+
+	require.NoError(t, s.send(heartbeat()))
+	g = c.Snapshot(tables)
+	c.Set(key, result, g, time.Minute)
+	qr, ok := c.Get(key)
+	require.True(t, ok)
+	assert.Equal(t, result, qr)
+
+	// the changes of the other tables do not invalidate the result
+	require.NoError(t, s.send(rowEvent("ks.t2")))
+	_, ok = c.Get(key)
+	require.True(t, ok)
+
+	require.NoError(t, s.send(rowEvent("ks.t1")))
+	_, ok = c.Get(key)
+	require.False(t, ok)
+
+	// the table changed while the query was running: the result is not cached
+	g = c.Snapshot(tables)
+	require.NoError(t, s.send(rowEvent("ks.t1")))
+	c.Set(key, result, g, time.Minute)
+	_, ok = c.Get(key)
+	require.False(t, ok)
+
+	// a DDL invalidates all the tables of the keyspace
+	g = c.Snapshot(tables)
+	c.Set(key, result, g, time.Minute)
+	require.NoError(t, s.send([]*binlogdatapb.VEvent{{Type: binlogdatapb.VEventType_DDL}}))
+	_, ok = c.Get(key)
+	require.False(t, ok)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	f := newFakeStream()
+	c := NewCache(1024*1024, f.stream)
+	defer c.Close()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Snapshot([]string{"ks.t1"})
+	require.NoError(t, f.next(t).send(heartbeat()))
+
+	c.Set(Key{1}, result, c.Snapshot([]string{"ks.t1"}), time.Second)
+	_, ok := c.Get(Key{1})
+	require.True(t, ok)
+
+	now = now.Add(2 * time.Second)
+	_, ok = c.Get(Key{1})
+	require.False(t, ok)
+}
+
+func TestCacheStreamRestart(t *testing.T) {
+	f := newFakeStream()
+	c := NewCache(1024*1024, f.stream)
+	c.retryDelay = time.Millisecond
+	defer c.Close()
+
+	c.Snapshot([]string{"ks.t1"})
+	s := f.next(t)
+	require.NoError(t, s.send(heartbeat()))
+	c.Set(Key{1}, result, c.Snapshot([]string{"ks.t1"}), time.Minute)
+
+	// a new table restarts the stream to follow it too, which invalidates the cached results
+	g := c.Snapshot([]string{"ks.t1", "ks.t2"})
+	s = f.next(t)
+	assert.Equal(t, []string{"t1", "t2"}, s.tables)
+	c.Set(Key{2}, result, g, time.Minute)
+	_, ok := c.Get(Key{1})
+	require.False(t, ok)
+	_, ok = c.Get(Key{2})
+	require.False(t, ok)
+
+	require.NoError(t, s.send(heartbeat()))
+	c.Set(Key{2}, result, c.Snapshot([]string{"ks.t1", "ks.t2"}), time.Minute)
+	_, ok = c.Get(Key{2})
+	require.True(t, ok)
+
+	// the results are not valid while the stream is down
+	s.fail <- errors.New("stream failed")
+	s = f.next(t)
+	_, ok = c.Get(Key{2})
+	require.False(t, ok)
+	assert.Equal(t, []string{"t1", "t2"}, s.tables)
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := NewCache(1024*1024, newFakeStream().stream)
+	defer c.Close()
+	c.SetDefaultTTL(time.Minute)
+	c.SetCachedTables([]string{"ks.t1", "ks.t2"})
+
+	assert.Equal(t, time.Duration(0), c.TTL(true, time.Second, nil))
+	assert.Equal(t, time.Second, c.TTL(true, time.Second, []string{"ks.t3"}))
+	assert.Equal(t, time.Minute, c.TTL(true, 0, []string{"ks.t3"}))
+	assert.Equal(t, time.Minute, c.TTL(false, 0, []string{"ks.t1", "ks.t2"}))
+	assert.Equal(t, time.Duration(0), c.TTL(false, 0, []string{"ks.t1", "ks.t3"}))
+}
@@ -18,6 +18,7 @@ package vtgate
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -961,6 +962,33 @@ func (session *SafeSession) GetPrepareData(name string) *vtgatepb.PrepareData {
 	return session.PrepareStatement[name]
 }
 
+// AddResultCacheInvalidations records the tables written in the current transaction,
+// whose cached results have to be invalidated once it ends.
+func (session *SafeSession) AddResultCacheInvalidations(tables []string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	for _, table := range tables {
+		if !slices.Contains(session.ResultCacheInvalidations, table) {
+			session.ResultCacheInvalidations = append(session.ResultCacheInvalidations, table)
+		}
+	}
+}
+
+// TakeResultCacheInvalidations returns the tables written by the last transaction
+// and forgets them, once the transaction has ended.
+func (session *SafeSession) TakeResultCacheInvalidations() []string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.Session.InTransaction {
+		return nil
+	}
+	tables := session.ResultCacheInvalidations
+	session.ResultCacheInvalidations = nil
+	return tables
+}
+
 func (l *executeLogger) log(primitive engine.Primitive, target *querypb.Target, gateway srvtopo.Gateway, query string, begin bool, bv map[string]*querypb.BindVariable) {
 	if l == nil {
 		return
@@ -255,6 +255,9 @@ func (stc *ScatterConn) ExecuteMultiShard(
 			if err != nil {
 				return newInfo, err
 			}
+			if autocommit {
+				trackWriteGTID(session, rs.Target, innerqr)
+			}
 			mu.Lock()
 			defer mu.Unlock()
 
@@ -453,6 +456,9 @@ func (stc *ScatterConn) StreamExecuteMulti(
 			if err != nil {
 				return newInfo, err
 			}
+			if autocommit {
+				trackWriteGTID(session, rs.Target, nil)
+			}
 
 			return newInfo, nil
 		},
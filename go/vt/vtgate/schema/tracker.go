@@ -292,8 +292,9 @@ func (t *Tracker) updateTables(keyspace string, res map[string]string) {
 		}
 
 		cols := getColumns(ddl.TableSpec)
+		pk := getPrimaryKey(ddl.TableSpec)
 		fks := getForeignKeys(ddl.TableSpec)
-		t.tables.set(keyspace, tableName, cols, fks)
+		t.tables.set(keyspace, tableName, cols, pk, fks)
 	}
 }
 
@@ -313,6 +314,20 @@ func getColumns(tblSpec *sqlparser.TableSpec) []vindexes.Column {
 	return cols
 }
 
+func getPrimaryKey(tblSpec *sqlparser.TableSpec) sqlparser.Columns {
+	for _, idx := range tblSpec.Indexes {
+		if idx.Info.Type != sqlparser.IndexTypePrimary {
+			continue
+		}
+		var pk sqlparser.Columns
+		for _, col := range idx.Columns {
+			pk = append(pk, col.Column)
+		}
+		return pk
+	}
+	return nil
+}
+
 func getForeignKeys(tblSpec *sqlparser.TableSpec) []*sqlparser.ForeignKeyDefinition {
 	if tblSpec.Constraints == nil {
 		return nil
@@ -403,13 +418,13 @@ type tableMap struct {
 	m map[keyspaceStr]map[tableNameStr]*vindexes.TableInfo
 }
 
-func (tm *tableMap) set(ks, tbl string, cols []vindexes.Column, fks []*sqlparser.ForeignKeyDefinition) {
+func (tm *tableMap) set(ks, tbl string, cols []vindexes.Column, pk sqlparser.Columns, fks []*sqlparser.ForeignKeyDefinition) {
 	m := tm.m[ks]
 	if m == nil {
 		m = make(map[tableNameStr]*vindexes.TableInfo)
 		tm.m[ks] = m
 	}
-	m[tbl] = &vindexes.TableInfo{Columns: cols, ForeignKeys: fks}
+	m[tbl] = &vindexes.TableInfo{Columns: cols, PrimaryKey: pk, ForeignKeys: fks}
 }
 
 func (tm *tableMap) get(ks, tbl string) *vindexes.TableInfo {
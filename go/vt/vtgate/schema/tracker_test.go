@@ -317,6 +317,10 @@ func TestTableInfoRetrieval(t *testing.T) {
 			"my_tbl":       "",
 			"my_child_tbl": "foreign key (my_id, `name`) references my_tbl (id, `name`) on delete cascade",
 		},
+		expPk: map[string]string{
+			"my_tbl":       "(id)",
+			"my_child_tbl": "(id)",
+		},
 	}}
 
 	testTracker(t, schemaDefResult, testcases)
@@ -328,6 +332,7 @@ type testCases struct {
 	updTbl []string
 	expTbl map[string][]vindexes.Column
 	expFk  map[string]string
+	expPk  map[string]string
 
 	updView []string
 	expView map[string]string
@@ -376,6 +381,9 @@ func testTracker(t *testing.T, schemaDefResult []map[string]string, tcases []tes
 						utils.MustMatch(t, tcase.expFk[k], sqlparser.String(fk), "mismatch foreign keys for table: ", k)
 					}
 				}
+				if pk, ok := tcase.expPk[k]; ok {
+					utils.MustMatch(t, pk, sqlparser.String(tracker.Tables(keyspace)[k].PrimaryKey), "mismatch primary key for table: ", k)
+				}
 			}
 
 			for k, v := range tcase.expView {
@@ -817,8 +817,8 @@ func TestInvalidQueries(t *testing.T) {
 		sql:  "select is_free_lock('xyz') from user",
 		serr: "is_free_lock('xyz') allowed only with dual",
 	}, {
-		sql: "SELECT * FROM JSON_TABLE('[ {\"c1\": null} ]','$[*]' COLUMNS( c1 INT PATH '$.c1' ERROR ON ERROR )) as jt",
-		err: &JSONTablesError{},
+		sql:  "select * from json_table('[]', '$[*]' columns(c1 int path '$.a', nested path '$.b[*]' columns(c1 int path '$'))) as jt",
+		serr: "Duplicate column name 'c1'",
 	}, {
 		sql:             "select does_not_exist from t1",
 		notUnshardedErr: "column 'does_not_exist' not found in table 't1'",
@@ -832,8 +832,17 @@ func TestInvalidQueries(t *testing.T) {
 		sql:  "select 1 from t1 where (id, id) in (select 1, 2, 3)",
 		serr: "Operand should contain 2 column(s)",
 	}, {
-		sql:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte WHERE n < 5) SELECT * FROM cte",
-		serr: "VT12001: unsupported: recursive common table expression",
+		sql:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT cte.n + 1 FROM cte JOIN cte AS c2 ON cte.n = c2.n) SELECT * FROM cte",
+		serr: "VT12001: unsupported: recursive common table expression must be referenced exactly once in its recursive part",
+	}, {
+		sql:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT max(n) + 1 FROM cte WHERE n < 5) SELECT * FROM cte",
+		serr: "VT12001: unsupported: aggregation, DISTINCT, GROUP BY, ORDER BY or LIMIT in the recursive part of a common table expression",
+	}, {
+		sql:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT t1.id FROM t1 LEFT JOIN cte ON t1.id = cte.n) SELECT * FROM cte",
+		serr: "VT12001: unsupported: recursive common table expression referenced outside of the FROM clause or on the inner side of an outer join",
+	}, {
+		sql:  "WITH RECURSIVE cte (n, m) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte WHERE n < 5) SELECT * FROM cte",
+		serr: "VT03027: in definition of common table expression 'cte', SELECT list and column names list have different column counts",
 	}, {
 		sql:  "with x as (select 1), x as (select 1) select * from x",
 		serr: "VT03013: not unique table/alias: 'x'",
@@ -986,6 +995,57 @@ func TestScopingWDerivedTables(t *testing.T) {
 	}
 }
 
+func TestScopingWLateralTables(t *testing.T) {
+	queries := []struct {
+		query                string
+		errorMessage         string
+		recursiveExpectation TableSet
+		expectation          TableSet
+	}{
+		{
+			query:                "select t.a from user u, lateral (select u.id as a from dual) as t",
+			recursiveExpectation: TS0,
+			expectation:          TS2,
+		}, {
+			query:                "select t.a from user u join lateral (select x.id as a from music x where x.user_id = u.id) as t",
+			recursiveExpectation: TS1,
+			expectation:          TS2,
+		}, {
+			query:        "select t.a from user u, (select u.id as a) as t",
+			errorMessage: "column 'u.id' not found",
+		}, {
+			query:                "select jt.c from user u, json_table(u.col, '$[*]' columns(c int path '$.c')) as jt",
+			recursiveExpectation: TS1,
+			expectation:          TS1,
+		}, {
+			query:                "select jt.c from user u join json_table(u.col, '$[*]' columns(o for ordinality, nested path '$.n[*]' columns(c int path '$'))) as jt on u.id = jt.o",
+			recursiveExpectation: TS1,
+			expectation:          TS1,
+		}, {
+			query:        "select jt.d from json_table('[]', '$[*]' columns(c int path '$.c')) as jt",
+			errorMessage: "column 'jt.d' not found",
+		}}
+	for _, query := range queries {
+		t.Run(query.query, func(t *testing.T) {
+			parse, err := sqlparser.Parse(query.query)
+			require.NoError(t, err)
+			st, err := Analyze(parse, "user", &FakeSI{})
+
+			switch {
+			case query.errorMessage != "" && err != nil:
+				require.EqualError(t, err, query.errorMessage)
+			case query.errorMessage != "":
+				require.EqualError(t, st.NotUnshardedErr, query.errorMessage)
+			default:
+				require.NoError(t, err)
+				sel := parse.(*sqlparser.Select)
+				assert.Equal(t, query.recursiveExpectation, st.RecursiveDeps(extract(sel, 0)), "RecursiveDeps")
+				assert.Equal(t, query.expectation, st.DirectDeps(extract(sel, 0)), "DirectDeps")
+			}
+		})
+	}
+}
+
 func TestScopingWithWITH(t *testing.T) {
 	queries := []struct {
 		query             string
@@ -1062,6 +1122,21 @@ func TestScopingWithWITH(t *testing.T) {
 			query:     "select 1 from user uu where exists (select 1 from user where exists (select 1 from (select 1 from t1) uu where uu.user_id = uu.id))",
 			direct:    T0,
 			recursive: T0,
+		}, {
+			query:     "with recursive t as (select id from user union all select user.id from user join t on user.parent = t.id) select id from t",
+			direct:    TS3,
+			recursive: TS3,
+		}, {
+			query:     "with recursive t as (select id from user union all select user.id from user join t on user.parent = t.id) select user.id from t join user on t.id = user.id",
+			direct:    TS4,
+			recursive: TS4,
+		}, {
+			query:     "with recursive t(n) as (select 1 union all select n + 1 from t where n < 10) select t.n from t",
+			direct:    TS2,
+			recursive: TS2,
+		}, {
+			query:        "with recursive t(n) as (select 1 union all select n + 1 from t where n < 10) select t.foo from t",
+			errorMessage: "column 't.foo' not found",
 		}}
 	for _, query := range queries {
 		t.Run(query.query, func(t *testing.T) {
@@ -1545,9 +1620,6 @@ func TestUpdateErrors(t *testing.T) {
 		query, expectedError string
 	}{
 		{
-			query:         "update t1, t2 set id = 12",
-			expectedError: "VT12001: unsupported: multiple (2) tables in update",
-		}, {
 			query:         "update (select 1 from dual) dt set id = 1",
 			expectedError: "The target table dt of the UPDATE is not updatable",
 		},
@@ -36,18 +36,10 @@ func (a *analyzer) checkForInvalidConstructs(cursor *sqlparser.Cursor) error {
 		return &LockOnlyWithDualError{Node: node}
 	case *sqlparser.Union:
 		return checkUnion(node)
-	case *sqlparser.JSONTableExpr:
-		return &JSONTablesError{}
-	case *sqlparser.DerivedTable:
-		return checkDerived(node)
 	case *sqlparser.AssignmentExpr:
 		return vterrors.VT12001("Assignment expression")
 	case *sqlparser.Subquery:
 		return a.checkSubqueryColumns(cursor.Parent(), node)
-	case *sqlparser.With:
-		if node.Recursive {
-			return vterrors.VT12001("recursive common table expression")
-		}
 	case *sqlparser.Insert:
 		if node.Action == sqlparser.ReplaceAct {
 			return ShardedError{Inner: &UnsupportedConstruct{errString: "REPLACE INTO with sharded keyspace"}}
@@ -90,13 +82,6 @@ func (a *analyzer) checkSubqueryColumns(parent sqlparser.SQLNode, subq *sqlparse
 	return nil
 }
 
-func checkDerived(node *sqlparser.DerivedTable) error {
-	if node.Lateral {
-		return vterrors.VT12001("lateral derived tables")
-	}
-	return nil
-}
-
 func checkUnion(node *sqlparser.Union) error {
 	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
 		switch node := node.(type) {
@@ -179,11 +164,11 @@ func (a *analyzer) checkSelect(cursor *sqlparser.Cursor, node *sqlparser.Select)
 
 func checkUpdate(node *sqlparser.Update) error {
 	if len(node.TableExprs) != 1 {
-		return ShardedError{Inner: &UnsupportedMultiTablesInUpdateError{ExprCount: len(node.TableExprs)}}
+		return nil
 	}
 	alias, isAlias := node.TableExprs[0].(*sqlparser.AliasedTableExpr)
 	if !isAlias {
-		return ShardedError{Inner: &UnsupportedMultiTablesInUpdateError{NotAlias: true}}
+		return nil
 	}
 	_, isDerived := alias.Expr.(*sqlparser.DerivedTable)
 	if isDerived {
@@ -0,0 +1,239 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package semantics
+
+import (
+	"strings"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+// CTETable contains the information about a reference to a recursive common table expression.
+// Non-recursive CTEs are inlined as derived tables by the early rewriter, but recursive CTEs
+// can't be inlined, so every reference to them, including the one inside the recursive part
+// of the CTE itself, is represented by a CTETable.
+type CTETable struct {
+	tableName   string
+	ASTNode     *sqlparser.AliasedTableExpr
+	CTE         *sqlparser.CommonTableExpr
+	columnNames []string
+	types       []evalengine.Type
+
+	// SelfReference is true when this is the reference to the CTE inside its own recursive part
+	SelfReference bool
+}
+
+var _ TableInfo = (*CTETable)(nil)
+
+func newCTETable(node *sqlparser.AliasedTableExpr, cte *cteDef, org originable) (*CTETable, error) {
+	seed := sqlparser.GetFirstSelect(cte.def.Subquery.Select)
+	ct := &CTETable{
+		tableName:     node.As.String(),
+		ASTNode:       node,
+		CTE:           cte.def,
+		SelfReference: cte.selfRef == node,
+	}
+	if ct.tableName == "" {
+		ct.tableName = cte.def.ID.String()
+	}
+	if len(cte.def.Columns) > 0 && len(cte.def.Columns) != len(seed.SelectExprs) {
+		return nil, vterrors.VT03027(cte.def.ID.String())
+	}
+
+	for i, expr := range seed.SelectExprs {
+		ae, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, vterrors.VT09015()
+		}
+		_, _, typ := org.depsForExpr(ae.Expr)
+		ct.types = append(ct.types, typ)
+
+		switch {
+		case len(cte.def.Columns) > 0:
+			ct.columnNames = append(ct.columnNames, cte.def.Columns[i].String())
+		case !ae.As.IsEmpty():
+			ct.columnNames = append(ct.columnNames, ae.As.String())
+		default:
+			if col, ok := ae.Expr.(*sqlparser.ColName); ok {
+				ct.columnNames = append(ct.columnNames, col.Name.String())
+			} else {
+				ct.columnNames = append(ct.columnNames, sqlparser.String(ae.Expr))
+			}
+		}
+	}
+	return ct, nil
+}
+
+// dependencies implements the TableInfo interface
+func (ct *CTETable) dependencies(colName string, org originable) (dependencies, error) {
+	ts := org.tableSetFor(ct.ASTNode)
+	for i, name := range ct.columnNames {
+		if strings.EqualFold(name, colName) {
+			return createCertain(ts, ts, ct.types[i]), nil
+		}
+	}
+	return &nothing{}, nil
+}
+
+// getTableSet implements the TableInfo interface
+func (ct *CTETable) getTableSet(org originable) TableSet {
+	return org.tableSetFor(ct.ASTNode)
+}
+
+// getExprFor implements the TableInfo interface
+func (ct *CTETable) getExprFor(s string) (sqlparser.Expr, error) {
+	return nil, vterrors.NewErrorf(vtrpcpb.Code_NOT_FOUND, vterrors.BadFieldError, "Unknown column '%s' in 'field list'", s)
+}
+
+// IsInfSchema implements the TableInfo interface
+func (ct *CTETable) IsInfSchema() bool {
+	return false
+}
+
+// GetVindexTable implements the TableInfo interface
+func (ct *CTETable) GetVindexTable() *vindexes.Table {
+	return nil
+}
+
+// Name implements the TableInfo interface
+func (ct *CTETable) Name() (sqlparser.TableName, error) {
+	return ct.ASTNode.TableName()
+}
+
+// matches implements the TableInfo interface
+func (ct *CTETable) matches(name sqlparser.TableName) bool {
+	return ct.tableName == name.Name.String() && name.Qualifier.IsEmpty()
+}
+
+// authoritative implements the TableInfo interface
+func (ct *CTETable) authoritative() bool {
+	return true
+}
+
+func (ct *CTETable) getAliasedTableExpr() *sqlparser.AliasedTableExpr {
+	return ct.ASTNode
+}
+
+// canShortCut implements the TableInfo interface.
+// The CTE itself does not stop us from sending the query to a single unsharded keyspace,
+// the tables used inside the CTE are checked on their own.
+func (ct *CTETable) canShortCut() shortCut {
+	return canShortCut
+}
+
+// getColumns implements the TableInfo interface
+func (ct *CTETable) getColumns() []ColumnInfo {
+	cols := make([]ColumnInfo, 0, len(ct.columnNames))
+	for i, col := range ct.columnNames {
+		cols = append(cols, ColumnInfo{
+			Name: col,
+			Type: ct.types[i],
+		})
+	}
+	return cols
+}
+
+// ColumnNames returns the names of the columns this CTE produces, in order
+func (ct *CTETable) ColumnNames() []string {
+	return ct.columnNames
+}
+
+// Union returns the UNION that defines the recursive CTE.
+// The left side is the seed, and the right side is the recursive part.
+func (ct *CTETable) Union() *sqlparser.Union {
+	return ct.CTE.Subquery.Select.(*sqlparser.Union)
+}
+
+// checkRecursiveCTE makes sure the recursive CTE has a shape that we are able to plan,
+// and returns the reference to the CTE inside the recursive part.
+// We support a UNION where the left side is the seed, not referencing the CTE,
+// and the right side is a SELECT that uses the CTE exactly once in its FROM clause.
+func checkRecursiveCTE(cte *sqlparser.CommonTableExpr, name string) (*sqlparser.AliasedTableExpr, error) {
+	union, ok := cte.Subquery.Select.(*sqlparser.Union)
+	if !ok {
+		return nil, vterrors.VT12001("recursive common table expression without UNION")
+	}
+	if len(union.OrderBy) > 0 || union.Limit != nil {
+		return nil, vterrors.VT12001("ORDER BY or LIMIT in recursive common table expression")
+	}
+	if countCTEReferences(union.Left, name) > 0 {
+		return nil, vterrors.VT12001("recursive common table expression referenced in its seed")
+	}
+	term, ok := union.Right.(*sqlparser.Select)
+	if !ok || countCTEReferences(term, name) != 1 {
+		return nil, vterrors.VT12001("recursive common table expression must be referenced exactly once in its recursive part")
+	}
+	if term.Distinct || term.GroupBy != nil || term.Having != nil || len(term.OrderBy) > 0 || term.Limit != nil ||
+		sqlparser.ContainsAggregation(term.SelectExprs) {
+		return nil, vterrors.VT12001("aggregation, DISTINCT, GROUP BY, ORDER BY or LIMIT in the recursive part of a common table expression")
+	}
+	selfRef := findCTEInFrom(term.From, name)
+	if selfRef == nil {
+		return nil, vterrors.VT12001("recursive common table expression referenced outside of the FROM clause or on the inner side of an outer join")
+	}
+	return selfRef, nil
+}
+
+func countCTEReferences(stmt sqlparser.SQLNode, name string) (count int) {
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if tbl, ok := node.(*sqlparser.AliasedTableExpr); ok && isCTEReference(tbl.Expr, name) {
+			count++
+		}
+		return true, nil
+	}, stmt)
+	return
+}
+
+func isCTEReference(node sqlparser.SQLNode, name string) bool {
+	tbl, ok := node.(sqlparser.TableName)
+	return ok && tbl.Qualifier.IsEmpty() && tbl.Name.String() == name
+}
+
+// findCTEInFrom looks for the CTE among the tables of the FROM clause.
+// The CTE can be used anywhere in inner joins, but only on the outer side of a LEFT JOIN
+func findCTEInFrom(exprs sqlparser.TableExprs, name string) *sqlparser.AliasedTableExpr {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			if isCTEReference(expr.Expr, name) {
+				return expr
+			}
+		case *sqlparser.ParenTableExpr:
+			if found := findCTEInFrom(expr.Exprs, name); found != nil {
+				return found
+			}
+		case *sqlparser.JoinTableExpr:
+			if found := findCTEInFrom(sqlparser.TableExprs{expr.LeftExpr}, name); found != nil {
+				if expr.Join == sqlparser.RightJoinType {
+					return nil
+				}
+				return found
+			}
+			if expr.Join != sqlparser.NormalJoinType && expr.Join != sqlparser.StraightJoinType {
+				return nil
+			}
+			if found := findCTEInFrom(sqlparser.TableExprs{expr.RightExpr}, name); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
@@ -72,30 +72,37 @@ func (r *earlyRewriter) handleAliasedTable(node *sqlparser.AliasedTableExpr) err
 	}
 	scope := r.scoper.currentScope()
 	cte := scope.findCTE(tbl.Name.String())
-	if cte == nil {
+	if cte == nil || cte.recursive {
+		// recursive CTEs are not inlined - the table collector will handle them
 		return nil
 	}
 	if node.As.IsEmpty() {
 		node.As = tbl.Name
 	}
 	node.Expr = &sqlparser.DerivedTable{
-		Select: cte.Subquery.Select,
+		Select: cte.def.Subquery.Select,
 	}
-	if len(cte.Columns) > 0 {
-		node.Columns = cte.Columns
+	if len(cte.def.Columns) > 0 {
+		node.Columns = cte.def.Columns
 	}
 	return nil
 }
 
 func (r *earlyRewriter) handleWith(node *sqlparser.With) error {
 	scope := r.scoper.currentScope()
+	var recursiveCTEs []*sqlparser.CommonTableExpr
 	for _, cte := range node.CTEs {
-		err := scope.addCTE(cte)
+		def, err := scope.addCTE(cte, node.Recursive)
 		if err != nil {
 			return err
 		}
+		if def.recursive {
+			recursiveCTEs = append(recursiveCTEs, cte)
+		}
 	}
-	node.CTEs = nil
+	// the non-recursive CTEs are inlined where they are used,
+	// but the recursive ones have to stay in the WITH clause
+	node.CTEs = recursiveCTEs
 	return nil
 }
 
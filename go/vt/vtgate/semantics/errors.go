@@ -44,7 +44,6 @@ type (
 	NotSequenceTableError          struct{ Table string }
 	NextWithMultipleTablesError    struct{ CountTables int }
 	LockOnlyWithDualError          struct{ Node *sqlparser.LockingFunc }
-	JSONTablesError                struct{ Table string }
 	QualifiedOrderInUnionError     struct{ Table string }
 	BuggyError                     struct{ Msg string }
 	UnsupportedConstruct           struct{ errString string }
@@ -52,10 +51,6 @@ type (
 	SubqueryColumnCountError       struct{ Expected int }
 	ColumnsMissingInSchemaError    struct{}
 
-	UnsupportedMultiTablesInUpdateError struct {
-		ExprCount int
-		NotAlias  bool
-	}
 	UnionColumnsDoNotMatchError struct {
 		FirstProj  int
 		SecondProj int
@@ -91,18 +86,6 @@ func (e *UnionColumnsDoNotMatchError) Error() string {
 	return eprintf(e, "The used SELECT statements have a different number of columns: %v, %v", e.FirstProj, e.SecondProj)
 }
 
-// UnsupportedMultiTablesInUpdateError
-func (e *UnsupportedMultiTablesInUpdateError) Error() string {
-	switch {
-	case e.NotAlias:
-		return eprintf(e, "unaliased multiple tables in update")
-	default:
-		return eprintf(e, "multiple (%d) tables in update", e.ExprCount)
-	}
-}
-
-func (e *UnsupportedMultiTablesInUpdateError) unsupported() {}
-
 // UnsupportedNaturalJoinError
 func (e *UnsupportedNaturalJoinError) Error() string {
 	return eprintf(e, "%s", e.JoinExpr.Join.ToString())
@@ -192,13 +175,6 @@ func (e *QualifiedOrderInUnionError) Error() string {
 	return eprintf(e, "Table `%s` from one of the SELECTs cannot be used in global ORDER clause", e.Table)
 }
 
-// JSONTablesError
-func (e *JSONTablesError) Error() string {
-	return eprintf(e, "json_table expressions")
-}
-
-func (e *JSONTablesError) unsupported() {}
-
 // BuggyError is used for checking conditions that should never occur
 func (e *BuggyError) Error() string {
 	return eprintf(e, e.Msg)
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package semantics
+
+import (
+	"strings"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+// JSONTable contains the information about a JSON_TABLE table function used in the FROM clause.
+// JSON_TABLE is not an AliasedTableExpr, so unlike the other tables, it keeps track of its own TableSet.
+type JSONTable struct {
+	ASTNode     *sqlparser.JSONTableExpr
+	tableSet    TableSet
+	columnNames []string
+	types       []evalengine.Type
+}
+
+var _ TableInfo = (*JSONTable)(nil)
+
+func newJSONTable(node *sqlparser.JSONTableExpr, ts TableSet) (*JSONTable, error) {
+	jt := &JSONTable{
+		ASTNode:  node,
+		tableSet: ts,
+	}
+	jt.addColumns(node.Columns)
+	for i, name := range jt.columnNames {
+		for _, other := range jt.columnNames[i+1:] {
+			if strings.EqualFold(name, other) {
+				return nil, vterrors.NewErrorf(vtrpcpb.Code_INVALID_ARGUMENT, vterrors.DupFieldName, "Duplicate column name '%s'", name)
+			}
+		}
+	}
+	return jt, nil
+}
+
+// addColumns flattens the column definitions, including the ones of NESTED PATH, into the columns of the table
+func (jt *JSONTable) addColumns(cols []*sqlparser.JtColumnDefinition) {
+	for _, col := range cols {
+		switch {
+		case col.JtOrdinal != nil:
+			jt.columnNames = append(jt.columnNames, col.JtOrdinal.Name.String())
+			jt.types = append(jt.types, evalengine.Type{Type: sqltypes.Uint32, Coll: collations.CollationBinaryID})
+		case col.JtPath != nil:
+			typ := col.JtPath.Type.SQLType()
+			if col.JtPath.JtColExists {
+				typ = sqltypes.Int64
+			}
+			jt.columnNames = append(jt.columnNames, col.JtPath.Name.String())
+			jt.types = append(jt.types, evalengine.Type{Type: typ, Coll: collations.DefaultCollationForType(typ), Nullable: true})
+		case col.JtNestedPath != nil:
+			jt.addColumns(col.JtNestedPath.Columns)
+		}
+	}
+}
+
+// dependencies implements the TableInfo interface
+func (jt *JSONTable) dependencies(colName string, _ originable) (dependencies, error) {
+	for i, name := range jt.columnNames {
+		if strings.EqualFold(name, colName) {
+			return createCertain(jt.tableSet, jt.tableSet, jt.types[i]), nil
+		}
+	}
+	return &nothing{}, nil
+}
+
+// getTableSet implements the TableInfo interface
+func (jt *JSONTable) getTableSet(originable) TableSet {
+	return jt.tableSet
+}
+
+// getExprFor implements the TableInfo interface
+func (jt *JSONTable) getExprFor(s string) (sqlparser.Expr, error) {
+	return nil, vterrors.NewErrorf(vtrpcpb.Code_NOT_FOUND, vterrors.BadFieldError, "Unknown column '%s' in 'field list'", s)
+}
+
+// IsInfSchema implements the TableInfo interface
+func (jt *JSONTable) IsInfSchema() bool {
+	return false
+}
+
+// GetVindexTable implements the TableInfo interface
+func (jt *JSONTable) GetVindexTable() *vindexes.Table {
+	return nil
+}
+
+// Name implements the TableInfo interface
+func (jt *JSONTable) Name() (sqlparser.TableName, error) {
+	return sqlparser.TableName{Name: jt.ASTNode.Alias}, nil
+}
+
+// matches implements the TableInfo interface
+func (jt *JSONTable) matches(name sqlparser.TableName) bool {
+	return jt.ASTNode.Alias.String() == name.Name.String() && name.Qualifier.IsEmpty()
+}
+
+// authoritative implements the TableInfo interface
+func (jt *JSONTable) authoritative() bool {
+	return true
+}
+
+func (jt *JSONTable) getAliasedTableExpr() *sqlparser.AliasedTableExpr {
+	return nil
+}
+
+// canShortCut implements the TableInfo interface.
+// JSON_TABLE does not read from any table, so it can be sent along with the other tables of the query.
+func (jt *JSONTable) canShortCut() shortCut {
+	return canShortCut
+}
+
+// getColumns implements the TableInfo interface
+func (jt *JSONTable) getColumns() []ColumnInfo {
+	cols := make([]ColumnInfo, 0, len(jt.columnNames))
+	for i, col := range jt.columnNames {
+		cols = append(cols, ColumnInfo{
+			Name: col,
+			Type: jt.types[i],
+		})
+	}
+	return cols
+}
+
+// ColumnNames returns the names of the columns this JSON_TABLE produces, in order
+func (jt *JSONTable) ColumnNames() []string {
+	return jt.columnNames
+}
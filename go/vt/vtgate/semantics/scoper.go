@@ -46,7 +46,17 @@ type (
 		isUnion   bool
 		joinUsing map[string]TableSet
 		stmtScope bool
-		ctes      map[string]*sqlparser.CommonTableExpr
+		ctes      map[string]*cteDef
+	}
+
+	// cteDef is a common table expression declared in a WITH clause
+	cteDef struct {
+		def *sqlparser.CommonTableExpr
+
+		// recursive CTEs can't be inlined as derived tables.
+		// For them, we also keep track of the reference to the CTE in its recursive part
+		recursive bool
+		selfRef   *sqlparser.AliasedTableExpr
 	}
 )
 
@@ -145,6 +155,10 @@ func (s *scoper) enterJoinScope(cursor *sqlparser.Cursor) {
 		// To create this special context, we will find the parent scope of the select statement involved.
 		currScope := s.currentScope()
 		stmtScope := currScope.findParentScopeOfStatement()
+		if isLateral(cursor.Node()) {
+			// a lateral derived table or a JSON_TABLE can also see the tables that come before it in the FROM clause
+			stmtScope = currScope
+		}
 		nScope := newScope(stmtScope)
 		if stmtScope == nil {
 			// TODO: this feels hacky. revisit with a better plan
@@ -155,6 +169,17 @@ func (s *scoper) enterJoinScope(cursor *sqlparser.Cursor) {
 	}
 }
 
+func isLateral(node sqlparser.SQLNode) bool {
+	switch node := node.(type) {
+	case *sqlparser.JSONTableExpr:
+		return true
+	case *sqlparser.AliasedTableExpr:
+		dt, isDerived := node.Expr.(*sqlparser.DerivedTable)
+		return isDerived && dt.Lateral
+	}
+	return false
+}
+
 func (s *scoper) pushSelectScope(node *sqlparser.Select) {
 	currScope := newScope(s.currentScope())
 	currScope.stmtScope = true
@@ -299,21 +324,29 @@ func newScope(parent *scope) *scope {
 	return &scope{
 		parent:    parent,
 		joinUsing: map[string]TableSet{},
-		ctes:      map[string]*sqlparser.CommonTableExpr{},
+		ctes:      map[string]*cteDef{},
 	}
 }
 
-func (s *scope) addCTE(cte *sqlparser.CommonTableExpr) error {
+func (s *scope) addCTE(cte *sqlparser.CommonTableExpr, recursive bool) (*cteDef, error) {
 	name := cte.ID.String()
 	_, exists := s.ctes[name]
 	if exists {
-		return vterrors.VT03013(name)
+		return nil, vterrors.VT03013(name)
 	}
-	if err := checkForInvalidAliasUse(cte, name); err != nil {
-		return err
+	def := &cteDef{def: cte}
+	if recursive && countCTEReferences(cte.Subquery.Select, name) > 0 {
+		selfRef, err := checkRecursiveCTE(cte, name)
+		if err != nil {
+			return nil, err
+		}
+		def.recursive = true
+		def.selfRef = selfRef
+	} else if err := checkForInvalidAliasUse(cte, name); err != nil {
+		return nil, err
 	}
-	s.ctes[name] = cte
-	return nil
+	s.ctes[name] = def
+	return def, nil
 }
 
 func checkForInvalidAliasUse(cte *sqlparser.CommonTableExpr, name string) (err error) {
@@ -379,7 +412,7 @@ func (s *scope) findParentScopeOfStatement() *scope {
 }
 
 // findCTE will search in this scope, and then recursively search the parents
-func (s *scope) findCTE(name string) *sqlparser.CommonTableExpr {
+func (s *scope) findCTE(name string) *cteDef {
 	cte, found := s.ctes[name]
 	if found || s.parent == nil {
 		// if we don't have a parent, we'll return
@@ -429,6 +429,16 @@ func (st *SemTable) TableSetFor(t *sqlparser.AliasedTableExpr) TableSet {
 	return EmptyTableSet()
 }
 
+// TableSetForJSONTable returns the bitmask for the given JSON_TABLE
+func (st *SemTable) TableSetForJSONTable(t *sqlparser.JSONTableExpr) TableSet {
+	for idx, t2 := range st.Tables {
+		if jt, ok := t2.(*JSONTable); ok && jt.ASTNode == t {
+			return SingleTableSet(idx)
+		}
+	}
+	return EmptyTableSet()
+}
+
 // ReplaceTableSetFor replaces the given single TabletSet with the new *sqlparser.AliasedTableExpr
 func (st *SemTable) ReplaceTableSetFor(id TableSet, t *sqlparser.AliasedTableExpr) {
 	if st == nil {
@@ -48,6 +48,8 @@ func (tc *tableCollector) up(cursor *sqlparser.Cursor) error {
 	switch node := cursor.Node().(type) {
 	case *sqlparser.AliasedTableExpr:
 		return tc.visitAliasedTableExpr(node)
+	case *sqlparser.JSONTableExpr:
+		return tc.visitJSONTable(node)
 	case *sqlparser.Union:
 		return tc.visitUnion(node)
 	default:
@@ -101,7 +103,25 @@ func (tc *tableCollector) visitAliasedTableExpr(node *sqlparser.AliasedTableExpr
 	return nil
 }
 
+func (tc *tableCollector) visitJSONTable(node *sqlparser.JSONTableExpr) error {
+	tableInfo, err := newJSONTable(node, SingleTableSet(len(tc.Tables)))
+	if err != nil {
+		return err
+	}
+
+	tc.Tables = append(tc.Tables, tableInfo)
+	scope := tc.scoper.currentScope()
+	return scope.addTable(tableInfo)
+}
+
 func (tc *tableCollector) handleTableName(node *sqlparser.AliasedTableExpr, t sqlparser.TableName) error {
+	scope := tc.scoper.currentScope()
+	if t.Qualifier.IsEmpty() {
+		if cte := scope.findCTE(t.Name.String()); cte != nil && cte.recursive {
+			return tc.handleCTETable(node, cte)
+		}
+	}
+
 	var tbl *vindexes.Table
 	var vindex vindexes.Vindex
 	isInfSchema := sqlparser.SystemSchema(t.Qualifier.String())
@@ -115,13 +135,23 @@ func (tc *tableCollector) handleTableName(node *sqlparser.AliasedTableExpr, t sq
 		tbl = newVindexTable(t.Name)
 	}
 
-	scope := tc.scoper.currentScope()
 	tableInfo := tc.createTable(t, node, tbl, isInfSchema, vindex)
 
 	tc.Tables = append(tc.Tables, tableInfo)
 	return scope.addTable(tableInfo)
 }
 
+func (tc *tableCollector) handleCTETable(node *sqlparser.AliasedTableExpr, cte *cteDef) error {
+	tableInfo, err := newCTETable(node, cte, tc.org)
+	if err != nil {
+		return err
+	}
+
+	tc.Tables = append(tc.Tables, tableInfo)
+	scope := tc.scoper.currentScope()
+	return scope.addTable(tableInfo)
+}
+
 func (tc *tableCollector) handleDerivedTable(node *sqlparser.AliasedTableExpr, t *sqlparser.DerivedTable) error {
 	switch sel := t.Select.(type) {
 	case *sqlparser.Select:
@@ -67,6 +67,15 @@ func (t *typer) up(cursor *sqlparser.Cursor) error {
 		}
 		type_ := code.Type(inputType)
 		t.m[node] = evalengine.Type{Type: type_, Coll: collations.DefaultCollationForType(type_)}
+	case *sqlparser.ArgumentLessWindowExpr:
+		type_ := opcode.WindowRowNumber.Type()
+		if node.Type == sqlparser.PercentRankExprType || node.Type == sqlparser.CumeDistExprType {
+			type_ = opcode.WindowPercentRank.Type()
+		}
+		t.m[node] = evalengine.Type{Type: type_, Coll: collations.DefaultCollationForType(type_)}
+	case *sqlparser.NtileExpr:
+		type_ := opcode.WindowNtile.Type()
+		t.m[node] = evalengine.Type{Type: type_, Coll: collations.DefaultCollationForType(type_)}
 	}
 	return nil
 }
@@ -19,9 +19,9 @@ package vtgate
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"runtime/debug"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,6 +36,7 @@ import (
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/balancer"
 	"vitess.io/vitess/go/vt/vtgate/buffer"
 	"vitess.io/vitess/go/vt/vttablet/queryservice"
 
@@ -53,6 +54,18 @@ var (
 	initialTabletTimeout = 30 * time.Second
 	// retryCount is the number of times a query will be retried on error
 	retryCount = 2
+
+	// balancerMode is how the gateway chooses between the healthy tablets of a target
+	balancerMode = balancer.ModeRandom
+	// balancerKeyspaceModes overrides balancerMode for some keyspaces
+	balancerKeyspaceModes string
+	balancerLatencyDecay  = 10 * time.Second
+	balancerLagPenalty    = 10 * time.Millisecond
+
+	// hedgedReads enables the hedging of the read-only queries of the replica and rdonly tablets
+	hedgedReads           bool
+	hedgedReadsPercentile = 95.0
+	hedgedReadsMinDelay   = 5 * time.Millisecond
 )
 
 func init() {
@@ -62,6 +75,13 @@ func init() {
 		fs.MarkDeprecated("buffer_implementation", "The 'healthcheck' buffer implementation has been removed in v18 and this option will be removed in v19")
 		fs.DurationVar(&initialTabletTimeout, "gateway_initial_tablet_timeout", 30*time.Second, "At startup, the tabletGateway will wait up to this duration to get at least one tablet per keyspace/shard/tablet type")
 		fs.IntVar(&retryCount, "retry-count", 2, "retry count")
+		fs.StringVar(&balancerMode, "tablet-balancer-mode", balancer.ModeRandom, "How the gateway chooses between the healthy tablets of a shard, preferring the tablets in the local cell. Allowed values: random, least_loaded (power of two choices based on query latency, queries in flight and replication lag)")
+		fs.StringVar(&balancerKeyspaceModes, "tablet-balancer-keyspace-modes", "", "Comma-separated list of keyspace:mode entries overriding --tablet-balancer-mode for the given keyspaces")
+		fs.DurationVar(&balancerLatencyDecay, "tablet-balancer-latency-decay", 10*time.Second, "How long it takes for an observed query latency to lose most of its weight in the least_loaded tablet balancer")
+		fs.DurationVar(&balancerLagPenalty, "tablet-balancer-lag-penalty", 10*time.Millisecond, "Latency added to a tablet for every second of replication lag in the least_loaded tablet balancer")
+		fs.BoolVar(&hedgedReads, "tablet-hedged-reads", false, "Send the read-only queries of the replica and rdonly tablets to a second tablet of the shard when the first one is slow to answer, and use the first answer. Can be overridden per query with the HEDGED_READS directive")
+		fs.Float64Var(&hedgedReadsPercentile, "tablet-hedged-reads-percentile", 95, "Percentile of the recent query latencies of a shard and tablet type after which a read-only query is sent to a second tablet")
+		fs.DurationVar(&hedgedReadsMinDelay, "tablet-hedged-reads-min-delay", 5*time.Millisecond, "Minimum time a read-only query waits for its tablet before being sent to a second tablet")
 	})
 }
 
@@ -84,6 +104,18 @@ type TabletGateway struct {
 
 	// buffer, if enabled, buffers requests during a detected PRIMARY failover.
 	buffer *buffer.Buffer
+
+	// defaultBalancer orders the tablets of the keyspaces that are not in keyspaceBalancers.
+	defaultBalancer   balancer.TabletBalancer
+	keyspaceBalancers map[string]balancer.TabletBalancer
+
+	// latencyTrackers keeps the recent query latencies used to hedge the read-only queries, by keyspace/shard/tablet_type.
+	// It is protected by mu.
+	latencyTrackers map[string]*latencyTracker
+
+	// appliedGTIDs are the GTIDs the tablets are known to have applied, by tablet alias.
+	// It is protected by mu.
+	appliedGTIDs map[string]appliedGTIDs
 }
 
 func createHealthCheck(ctx context.Context, retryDelay, timeout time.Duration, ts *topo.Server, cell, cellsToWatch string) discovery.HealthCheck {
@@ -110,6 +142,11 @@ func NewTabletGateway(ctx context.Context, hc discovery.HealthCheck, serv srvtop
 		localCell:         localCell,
 		retryCount:        retryCount,
 		statusAggregators: make(map[string]*TabletStatusAggregator),
+		latencyTrackers:   make(map[string]*latencyTracker),
+		appliedGTIDs:      make(map[string]appliedGTIDs),
+	}
+	if err := gw.setupBalancers(); err != nil {
+		log.Exitf("Unable to create new TabletGateway: %v", err)
 	}
 	gw.setupBuffering(ctx)
 	gw.QueryService = queryservice.Wrap(nil, gw.withRetry)
@@ -118,11 +155,19 @@ func NewTabletGateway(ctx context.Context, hc discovery.HealthCheck, serv srvtop
 
 func (gw *TabletGateway) setupBuffering(ctx context.Context) {
 	cfg := buffer.NewConfigFromFlags()
-	if !cfg.Enabled {
+	if !cfg.Enabled && !buffer.PoliciesConfigured() {
 		log.Info("Query buffering is disabled")
 		return
 	}
 	gw.buffer = buffer.New(cfg)
+	gw.buffer.SetHealthCheck(gw.hc)
+	if gw.srvTopoServer != nil {
+		if ts, err := gw.srvTopoServer.GetTopoServer(); err != nil {
+			log.Errorf("Unable to load the buffering policies: %v", err)
+		} else {
+			gw.buffer.LoadPolicies(ctx, ts)
+		}
+	}
 
 	gw.kev = discovery.NewKeyspaceEventWatcher(ctx, gw.srvTopoServer, gw.hc, gw.localCell)
 	ksChan := gw.kev.Subscribe()
@@ -145,6 +190,53 @@ func (gw *TabletGateway) setupBuffering(ctx context.Context) {
 	}(bufferCtx, ksChan, gw.buffer)
 }
 
+// BufferStatus returns the state of the buffers, or nil if buffering is disabled.
+func (gw *TabletGateway) BufferStatus() []*buffer.ShardBufferStatus {
+	if gw.buffer == nil {
+		return nil
+	}
+	return gw.buffer.Status()
+}
+
+func (gw *TabletGateway) setupBalancers() error {
+	cfg := balancer.Config{
+		Mode:         balancerMode,
+		LocalCell:    gw.localCell,
+		LatencyDecay: balancerLatencyDecay,
+		LagPenalty:   balancerLagPenalty,
+	}
+	var err error
+	gw.defaultBalancer, err = balancer.NewTabletBalancer(cfg)
+	if err != nil {
+		return err
+	}
+
+	gw.keyspaceBalancers = make(map[string]balancer.TabletBalancer)
+	if balancerKeyspaceModes == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(balancerKeyspaceModes, ",") {
+		keyspace, mode, found := strings.Cut(entry, ":")
+		if !found || keyspace == "" {
+			return fmt.Errorf("invalid --tablet-balancer-keyspace-modes entry %q, expected keyspace:mode", entry)
+		}
+		cfg.Mode = mode
+		gw.keyspaceBalancers[keyspace], err = balancer.NewTabletBalancer(cfg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// balancerFor returns the balancer to use for the tablets of the given keyspace
+func (gw *TabletGateway) balancerFor(keyspace string) balancer.TabletBalancer {
+	if b, ok := gw.keyspaceBalancers[keyspace]; ok {
+		return b
+	}
+	return gw.defaultBalancer
+}
+
 // QueryServiceByAlias satisfies the Gateway interface
 func (gw *TabletGateway) QueryServiceByAlias(alias *topodatapb.TabletAlias, target *querypb.Target) (queryservice.QueryService, error) {
 	qs, err := gw.hc.TabletConnection(alias, target)
@@ -229,7 +321,7 @@ func (gw *TabletGateway) CacheStatus() TabletCacheStatusList {
 // withRetry also adds shard information to errors returned from the inner QueryService, so
 // withShardError should not be combined with withRetry.
 func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target, _ queryservice.QueryService,
-	_ string, inTransaction bool, inner func(ctx context.Context, target *querypb.Target, conn queryservice.QueryService) (bool, error)) error {
+	name string, inTransaction bool, inner func(ctx context.Context, target *querypb.Target, conn queryservice.QueryService) (bool, error)) error {
 
 	// for transactions, we connect to a specific tablet instead of letting gateway choose one
 	if inTransaction && target.TabletType != topodatapb.TabletType_PRIMARY {
@@ -252,15 +344,22 @@ func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target,
 		}
 	}
 
+	tabletBalancer := gw.balancerFor(target.Keyspace)
+	// the latency of a streaming query does not tell us anything about the tablet
+	streaming := strings.Contains(name, "Stream")
+
 	bufferedOnce := false
 	for i := 0; i < gw.retryCount+1; i++ {
-		// Check if we should buffer PRIMARY queries which failed due to an ongoing failover.
+		// Check if we should buffer queries which failed due to an ongoing failover.
+		// PRIMARY queries are buffered by default, the other tablet types when
+		// the buffering policy of the keyspace asks for it.
 		// Note: We only buffer once and only "!inTransaction" queries i.e.
 		// a) no transaction is necessary (e.g. critical reads) or
 		// b) no transaction was created yet.
-		if gw.buffer != nil && !bufferedOnce && !inTransaction && target.TabletType == topodatapb.TabletType_PRIMARY {
+		buffering := gw.buffer != nil && !bufferedOnce && !inTransaction && gw.buffer.Buffers(target.Keyspace, target.Shard, target.TabletType)
+		if buffering {
 			// The next call blocks if we should buffer during a failover.
-			retryDone, bufferErr := gw.buffer.WaitForFailoverEnd(ctx, target.Keyspace, target.Shard, err)
+			retryDone, bufferErr := gw.buffer.WaitForTabletTypeFailoverEnd(ctx, target.Keyspace, target.Shard, target.TabletType, err)
 
 			// Request may have been buffered.
 			if retryDone != nil {
@@ -301,12 +400,18 @@ func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target,
 				}
 			}
 
+			// buffer the queries of the other tablet types until some tablets are available
+			if buffering && target.TabletType != topodatapb.TabletType_PRIMARY {
+				err = vterrors.Errorf(vtrpcpb.Code_CLUSTER_EVENT, buffer.ClusterEventNoServingTablets)
+				continue
+			}
+
 			// fail fast if there is no tablet
 			err = vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "no healthy tablet available for '%s'", target.String())
 			break
 		}
 
-		gw.shuffleTablets(gw.localCell, tablets)
+		tabletBalancer.ShuffleTablets(target, tablets)
 
 		var th *discovery.TabletHealth
 		// skip tablets we tried before
@@ -334,12 +439,41 @@ func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target,
 
 		gw.updateDefaultConnCollation(tabletLastUsed)
 
+		alias := topoproto.TabletAliasString(tabletLastUsed.Alias)
+		var conn queryservice.QueryService
+		var hedge *discovery.TabletHealth
+		if gtid, timeout, gtidErr := gw.readAfterWriteGTID(ctx, target, name, inTransaction); gtidErr != nil || gtid != "" {
+			// the read of a session waiting for its writes cannot be hedged, the other tablet did not wait
+			if gtidErr != nil || !gw.waitForGTID(ctx, target, th, gtid, timeout) {
+				return gw.withRetry(ctx, primaryTarget(target), nil, name, inTransaction, inner)
+			}
+			conn = th.Conn
+		} else {
+			conn, hedge = gw.hedgedConn(ctx, target, name, inTransaction, th, tablets, invalidTablets)
+		}
+		tabletBalancer.QueryStarted(alias)
+		if hedge != nil {
+			tabletBalancer.QueryStarted(topoproto.TabletAliasString(hedge.Tablet.Alias))
+		}
 		startTime := time.Now()
 		var canRetry bool
-		canRetry, err = inner(ctx, target, th.Conn)
+		canRetry, err = inner(ctx, target, conn)
 		gw.updateStats(target, startTime, err)
+		if streaming {
+			tabletBalancer.QueryDone(alias, 0)
+		} else {
+			latency := time.Since(startTime)
+			tabletBalancer.QueryDone(alias, latency)
+			if err == nil && target.TabletType != topodatapb.TabletType_PRIMARY {
+				gw.latencyTrackerFor(target).record(latency, hedgedReadsPercentile)
+			}
+		}
+		if hedge != nil {
+			// the latency of the hedge tablet is not known, its query may have been cancelled
+			tabletBalancer.QueryDone(topoproto.TabletAliasString(hedge.Tablet.Alias), 0)
+		}
 		if canRetry {
-			invalidTablets[topoproto.TabletAliasString(tabletLastUsed.Alias)] = true
+			invalidTablets[alias] = true
 			continue
 		}
 		break
@@ -376,53 +510,6 @@ func (gw *TabletGateway) getStatsAggregator(target *querypb.Target) *TabletStatu
 	return aggr
 }
 
-func (gw *TabletGateway) shuffleTablets(cell string, tablets []*discovery.TabletHealth) {
-	sameCell, diffCell, sameCellMax := 0, 0, -1
-	length := len(tablets)
-
-	// move all same cell tablets to the front, this is O(n)
-	for {
-		sameCellMax = diffCell - 1
-		sameCell = gw.nextTablet(cell, tablets, sameCell, length, true)
-		diffCell = gw.nextTablet(cell, tablets, diffCell, length, false)
-		// either no more diffs or no more same cells should stop the iteration
-		if sameCell < 0 || diffCell < 0 {
-			break
-		}
-
-		if sameCell < diffCell {
-			// fast forward the `sameCell` lookup to `diffCell + 1`, `diffCell` unchanged
-			sameCell = diffCell + 1
-		} else {
-			// sameCell > diffCell, swap needed
-			tablets[sameCell], tablets[diffCell] = tablets[diffCell], tablets[sameCell]
-			sameCell++
-			diffCell++
-		}
-	}
-
-	// shuffle in same cell tablets
-	for i := sameCellMax; i > 0; i-- {
-		swap := rand.Intn(i + 1)
-		tablets[i], tablets[swap] = tablets[swap], tablets[i]
-	}
-
-	// shuffle in diff cell tablets
-	for i, diffCellMin := length-1, sameCellMax+1; i > diffCellMin; i-- {
-		swap := rand.Intn(i-sameCellMax) + diffCellMin
-		tablets[i], tablets[swap] = tablets[swap], tablets[i]
-	}
-}
-
-func (gw *TabletGateway) nextTablet(cell string, tablets []*discovery.TabletHealth, offset, length int, sameCell bool) int {
-	for ; offset < length; offset++ {
-		if (tablets[offset].Tablet.Alias.Cell == cell) == sameCell {
-			return offset
-		}
-	}
-	return -1
-}
-
 // TabletsCacheStatus returns a displayable version of the health check cache.
 func (gw *TabletGateway) TabletsCacheStatus() discovery.TabletsCacheStatusList {
 	return gw.hc.CacheStatus()
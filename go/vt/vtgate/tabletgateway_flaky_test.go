@@ -331,3 +331,63 @@ func TestInconsistentStateDetectedBuffering(t *testing.T) {
 		t.Fatalf("timed out waiting for query to execute")
 	}
 }
+
+// TestGatewayBufferingReplicaReads is used to test that the REPLICA queries are buffered while there is no
+// replica tablet, when the buffering policy of the keyspace asks for it
+func TestGatewayBufferingReplicaReads(t *testing.T) {
+	ctx := utils.LeakCheckContext(t)
+
+	buffer.SetBufferingModeInTestingEnv(true)
+	defer func() {
+		buffer.SetBufferingModeInTestingEnv(false)
+	}()
+
+	keyspace := "ks1"
+	shard := "-80"
+	target := &querypb.Target{
+		Keyspace:   keyspace,
+		Shard:      shard,
+		TabletType: topodatapb.TabletType_REPLICA,
+	}
+
+	ts := &fakeTopoServer{}
+	hc := discovery.NewFakeHealthCheck(make(chan *discovery.TabletHealth))
+	tg := NewTabletGateway(ctx, hc, ts, "cell")
+	defer tg.Close(ctx)
+
+	// without a policy, the REPLICA queries fail right away
+	_, err := tg.Execute(ctx, target, "query", nil, 0, 0, nil)
+	require.ErrorContains(t, err, "no healthy tablet available")
+
+	policies, err := buffer.ParsePolicies([]byte(`{"ks1": {"tablet_types": ["primary", "replica"]}}`), buffer.NewDefaultConfig())
+	require.NoError(t, err)
+	tg.buffer.SetPolicies(policies)
+
+	sqlResult1 := &sqltypes.Result{RowsAffected: 1}
+	queryChan := make(chan struct{})
+	var res *sqltypes.Result
+	go func() {
+		res, err = tg.Execute(ctx, target, "query", nil, 0, 0, nil)
+		queryChan <- struct{}{}
+	}()
+
+	// the query is buffered until a replica is available
+	require.Eventually(t, func() bool {
+		for _, status := range tg.BufferStatus() {
+			if status.TabletType == "replica" && status.QueueSize == 1 {
+				return true
+			}
+		}
+		return false
+	}, 10*time.Second, 10*time.Millisecond)
+	sbc := hc.AddTestTablet("cell", "1.1.1.1", 1001, keyspace, shard, topodatapb.TabletType_REPLICA, true, 10, nil)
+	sbc.SetResults([]*sqltypes.Result{sqlResult1})
+
+	select {
+	case <-queryChan:
+		require.NoError(t, err)
+		require.Equal(t, sqlResult1, res)
+	case <-time.After(15 * time.Second):
+		t.Fatalf("timed out waiting for query to execute")
+	}
+}
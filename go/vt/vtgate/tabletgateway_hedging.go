@@ -0,0 +1,201 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vttablet/queryservice"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+var (
+	hedgedReadsStarted = stats.NewCountersWithMultiLabels("TabletGatewayHedgedReads", "Read-only queries sent to a second tablet because the first one was slow to answer", []string{"Keyspace", "ShardName", "DbType"})
+	hedgedReadsWon     = stats.NewCountersWithMultiLabels("TabletGatewayHedgedReadsWon", "Hedged read-only queries answered by the second tablet first", []string{"Keyspace", "ShardName", "DbType"})
+)
+
+const (
+	// hedgingSamples is the number of recent query latencies of a target used to compute the hedging delay.
+	hedgingSamples = 256
+	// hedgingMinSamples is the number of latencies needed before hedging the queries of a target.
+	hedgingMinSamples = 32
+	// hedgingRefreshEvery is how many new latencies are recorded before computing the hedging delay again.
+	hedgingRefreshEvery = 32
+)
+
+type hedgedReadsKey struct{}
+
+// withHedgedReads returns a context enabling or disabling the hedged reads of the queries run with it,
+// whatever the value of --tablet-hedged-reads.
+func withHedgedReads(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, hedgedReadsKey{}, enabled)
+}
+
+// hedgedReadsEnabled returns true if the read-only queries of the context can be hedged.
+func hedgedReadsEnabled(ctx context.Context) bool {
+	if enabled, ok := ctx.Value(hedgedReadsKey{}).(bool); ok {
+		return enabled
+	}
+	return hedgedReads
+}
+
+// latencyTracker keeps the recent latencies of the queries of a target,
+// and the hedging delay computed from them.
+type latencyTracker struct {
+	mu        sync.Mutex
+	samples   [hedgingSamples]time.Duration
+	count     int
+	next      int
+	sinceLast int
+	delay     time.Duration
+}
+
+func (lt *latencyTracker) record(latency time.Duration, percentile float64) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.samples[lt.next] = latency
+	lt.next = (lt.next + 1) % hedgingSamples
+	if lt.count < hedgingSamples {
+		lt.count++
+	}
+	lt.sinceLast++
+	if lt.count < hedgingMinSamples || (lt.delay != 0 && lt.sinceLast < hedgingRefreshEvery) {
+		return
+	}
+	lt.sinceLast = 0
+	sorted := make([]time.Duration, lt.count)
+	copy(sorted, lt.samples[:lt.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(lt.count-1) * percentile / 100)
+	lt.delay = sorted[i]
+}
+
+// hedgingDelay returns how long a query waits for the first tablet before being sent to a second one,
+// or false if we do not know enough about the latencies of the target yet.
+func (lt *latencyTracker) hedgingDelay() (time.Duration, bool) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if lt.count < hedgingMinSamples {
+		return 0, false
+	}
+	return max(lt.delay, hedgedReadsMinDelay), true
+}
+
+func (gw *TabletGateway) latencyTrackerFor(target *querypb.Target) *latencyTracker {
+	key := fmt.Sprintf("%v/%v/%v", target.Keyspace, target.Shard, target.TabletType.String())
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	lt, ok := gw.latencyTrackers[key]
+	if !ok {
+		lt = &latencyTracker{}
+		gw.latencyTrackers[key] = lt
+	}
+	return lt
+}
+
+// hedgedConn returns a connection sending the query to the tablet, and to a second tablet of the shard
+// if the first one is slow to answer. It returns the connection of the tablet if the query cannot be hedged.
+// Only the non-streaming queries outside of transactions on the replica and rdonly tablets are hedged,
+// since they are read-only.
+func (gw *TabletGateway) hedgedConn(ctx context.Context, target *querypb.Target, name string, inTransaction bool,
+	th *discovery.TabletHealth, tablets []*discovery.TabletHealth, invalidTablets map[string]bool) (queryservice.QueryService, *discovery.TabletHealth) {
+	if name != "Execute" || inTransaction || target.TabletType == topodatapb.TabletType_PRIMARY || !hedgedReadsEnabled(ctx) {
+		return th.Conn, nil
+	}
+	delay, ok := gw.latencyTrackerFor(target).hedgingDelay()
+	if !ok {
+		return th.Conn, nil
+	}
+	// the tablets are already in the order of preference of the balancer
+	for _, t := range tablets {
+		if t == th || t.Conn == nil || invalidTablets[topoproto.TabletAliasString(t.Tablet.Alias)] {
+			continue
+		}
+		return &hedgingConn{
+			QueryService: th.Conn,
+			hedge:        t.Conn,
+			delay:        delay,
+			statsKey:     []string{target.Keyspace, target.Shard, topoproto.TabletTypeLString(target.TabletType)},
+		}, t
+	}
+	return th.Conn, nil
+}
+
+// hedgingConn sends the queries to its tablet, and to the hedge tablet if the first one did not answer
+// after the delay. The first successful answer is used, and the other query is cancelled.
+type hedgingConn struct {
+	queryservice.QueryService
+	hedge    queryservice.QueryService
+	delay    time.Duration
+	statsKey []string
+}
+
+type hedgedAnswer struct {
+	qr     *sqltypes.Result
+	err    error
+	hedged bool
+}
+
+// Execute is part of the QueryService interface.
+func (hc *hedgingConn) Execute(ctx context.Context, target *querypb.Target, query string, bindVars map[string]*querypb.BindVariable, transactionID, reservedID int64, options *querypb.ExecuteOptions) (*sqltypes.Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// cancels the query that did not answer first
+	defer cancel()
+
+	answers := make(chan hedgedAnswer, 2)
+	execute := func(conn queryservice.QueryService, hedged bool) {
+		qr, err := conn.Execute(ctx, target, query, bindVars, transactionID, reservedID, options)
+		answers <- hedgedAnswer{qr: qr, err: err, hedged: hedged}
+	}
+	go execute(hc.QueryService, false)
+
+	timer := time.NewTimer(hc.delay)
+	defer timer.Stop()
+	select {
+	case answer := <-answers:
+		return answer.qr, answer.err
+	case <-timer.C:
+	}
+	hedgedReadsStarted.Add(hc.statsKey, 1)
+	go execute(hc.hedge, true)
+
+	// an error does not end the query while the other tablet can still answer
+	var first hedgedAnswer
+	for i := 0; i < 2; i++ {
+		answer := <-answers
+		if answer.err == nil {
+			if answer.hedged {
+				hedgedReadsWon.Add(hc.statsKey, 1)
+			}
+			return answer.qr, nil
+		}
+		if i == 0 {
+			first = answer
+		}
+	}
+	return first.qr, first.err
+}
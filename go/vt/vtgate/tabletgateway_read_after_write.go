@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"time"
+
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+var (
+	readAfterWriteWaits     = stats.NewCountersWithMultiLabels("TabletGatewayReadAfterWriteWaits", "Replica reads that waited for their replica to apply the previous writes of their session", []string{"Keyspace", "ShardName", "DbType"})
+	readAfterWriteFallbacks = stats.NewCountersWithMultiLabels("TabletGatewayReadAfterWriteFallbacks", "Replica reads sent to the primary because their replica did not apply the previous writes of their session in time", []string{"Keyspace", "ShardName", "DbType"})
+)
+
+// appliedGTIDsTTL is how long the GTIDs a tablet is known to have applied are remembered. A tablet restored
+// from a backup does not have all of them anymore, but it is not serving for much longer than that.
+const appliedGTIDsTTL = time.Minute
+
+const waitForGTIDQuery = "select wait_for_executed_gtid_set(:gtid, :timeout)"
+
+// appliedGTIDs are the GTIDs a tablet is known to have applied.
+type appliedGTIDs struct {
+	gtids   replication.Mysql56GTIDSet
+	expires time.Time
+}
+
+// readAfterWriteGTID returns the GTID set the tablet has to apply before running the query, if the query is a
+// replica read of a session that waits for its writes, and how long the query waits for it. Only the queries
+// outside of transactions wait, since a transaction on a replica is tied to a tablet. The GTIDs executed by
+// the primary are captured if the GTIDs of the writes of the session on the shard are unknown.
+func (gw *TabletGateway) readAfterWriteGTID(ctx context.Context, target *querypb.Target, name string, inTransaction bool) (string, time.Duration, error) {
+	if inTransaction || target.TabletType == topodatapb.TabletType_PRIMARY || (name != "Execute" && name != "StreamExecute") {
+		return "", 0, nil
+	}
+	return readAfterWriteGTID(ctx, target, func(ctx context.Context, target *querypb.Target) (string, error) {
+		return executedGTIDs(ctx, gw, primaryTarget(target))
+	})
+}
+
+// waitForGTID waits until the tablet has applied the GTID set, and returns false if it did not apply it
+// before the timeout, or if the tablet could not wait for it.
+func (gw *TabletGateway) waitForGTID(ctx context.Context, target *querypb.Target, th *discovery.TabletHealth, gtid string, timeout time.Duration) bool {
+	alias := topoproto.TabletAliasString(th.Tablet.Alias)
+	gtidSet, parseErr := replication.ParseMysql56GTIDSet(gtid)
+	if parseErr == nil && gw.hasApplied(alias, gtidSet) {
+		return true
+	}
+
+	statsKey := []string{target.Keyspace, target.Shard, topoproto.TabletTypeLString(target.TabletType)}
+	readAfterWriteWaits.Add(statsKey, 1)
+	qr, err := th.Conn.Execute(ctx, target, waitForGTIDQuery, map[string]*querypb.BindVariable{
+		"gtid":    sqltypes.StringBindVariable(gtid),
+		"timeout": sqltypes.Float64BindVariable(timeout.Seconds()),
+	}, 0, 0, nil)
+	// the function returns 0 once the GTIDs are applied, and 1 after the timeout
+	if err != nil || len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 || qr.Rows[0][0].ToString() != "0" {
+		log.V(2).Infof("Tablet %s did not apply %s in %v, sending the query to the primary (error: %v)", alias, gtid, timeout, err)
+		readAfterWriteFallbacks.Add(statsKey, 1)
+		return false
+	}
+	if parseErr == nil {
+		gw.setApplied(alias, gtidSet)
+	}
+	return true
+}
+
+func (gw *TabletGateway) hasApplied(alias string, gtids replication.Mysql56GTIDSet) bool {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	applied, ok := gw.appliedGTIDs[alias]
+	return ok && time.Now().Before(applied.expires) && applied.gtids.Contains(gtids)
+}
+
+func (gw *TabletGateway) setApplied(alias string, gtids replication.Mysql56GTIDSet) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if applied, ok := gw.appliedGTIDs[alias]; ok && time.Now().Before(applied.expires) {
+		gtids = applied.gtids.Union(gtids).(replication.Mysql56GTIDSet)
+	}
+	gw.appliedGTIDs[alias] = appliedGTIDs{gtids: gtids, expires: time.Now().Add(appliedGTIDsTTL)}
+}
+
+// primaryTarget returns the target of the primary of the shard of the target.
+func primaryTarget(target *querypb.Target) *querypb.Target {
+	return &querypb.Target{
+		Keyspace:   target.Keyspace,
+		Shard:      target.Shard,
+		TabletType: topodatapb.TabletType_PRIMARY,
+		Cell:       target.Cell,
+	}
+}
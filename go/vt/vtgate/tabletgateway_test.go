@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,9 +32,13 @@ import (
 	"vitess.io/vitess/go/vt/discovery"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/balancer"
+	"vitess.io/vitess/go/vt/vttablet/queryservice"
+	"vitess.io/vitess/go/vt/vttablet/sandboxconn"
 )
 
 func TestTabletGatewayExecute(t *testing.T) {
@@ -95,7 +100,7 @@ func TestTabletGatewayShuffleTablets(t *testing.T) {
 
 	hc := discovery.NewFakeHealthCheck(nil)
 	ts := &fakeTopoServer{}
-	tg := NewTabletGateway(ctx, hc, ts, "local")
+	tg := NewTabletGateway(ctx, hc, ts, "cell1")
 	defer tg.Close(ctx)
 
 	ts1 := &discovery.TabletHealth{
@@ -131,17 +136,17 @@ func TestTabletGatewayShuffleTablets(t *testing.T) {
 	mixedTablets := []*discovery.TabletHealth{ts1, ts2, ts3, ts4}
 	// repeat shuffling 10 times and every time the same cell tablets should be in the front
 	for i := 0; i < 10; i++ {
-		tg.shuffleTablets("cell1", sameCellTablets)
+		tg.defaultBalancer.ShuffleTablets(nil, sameCellTablets)
 		assert.Len(t, sameCellTablets, 2, "Wrong number of TabletHealth")
 		assert.Equal(t, sameCellTablets[0].Tablet.Alias.Cell, "cell1", "Wrong tablet cell")
 		assert.Equal(t, sameCellTablets[1].Tablet.Alias.Cell, "cell1", "Wrong tablet cell")
 
-		tg.shuffleTablets("cell1", diffCellTablets)
+		tg.defaultBalancer.ShuffleTablets(nil, diffCellTablets)
 		assert.Len(t, diffCellTablets, 2, "should shuffle in only diff cell tablets")
 		assert.Contains(t, diffCellTablets, ts3, "diffCellTablets should contain %v", ts3)
 		assert.Contains(t, diffCellTablets, ts4, "diffCellTablets should contain %v", ts4)
 
-		tg.shuffleTablets("cell1", mixedTablets)
+		tg.defaultBalancer.ShuffleTablets(nil, mixedTablets)
 		assert.Len(t, mixedTablets, 4, "should have 4 tablets, got %+v", mixedTablets)
 
 		assert.Contains(t, mixedTablets[0:2], ts1, "should have same cell tablets in the front, got %+v", mixedTablets)
@@ -152,6 +157,116 @@ func TestTabletGatewayShuffleTablets(t *testing.T) {
 	}
 }
 
+func TestTabletGatewayKeyspaceBalancers(t *testing.T) {
+	defer func(mode, keyspaceModes string) {
+		balancerMode = mode
+		balancerKeyspaceModes = keyspaceModes
+	}(balancerMode, balancerKeyspaceModes)
+
+	balancerMode = balancer.ModeRandom
+	balancerKeyspaceModes = "ks1:least_loaded,ks2:random"
+	tg := &TabletGateway{localCell: "cell1"}
+	require.NoError(t, tg.setupBalancers())
+	assert.Len(t, tg.keyspaceBalancers, 2)
+	assert.Same(t, tg.keyspaceBalancers["ks1"], tg.balancerFor("ks1"))
+	assert.Same(t, tg.keyspaceBalancers["ks2"], tg.balancerFor("ks2"))
+	assert.Same(t, tg.defaultBalancer, tg.balancerFor("ks3"))
+
+	balancerKeyspaceModes = "ks1"
+	require.EqualError(t, tg.setupBalancers(), `invalid --tablet-balancer-keyspace-modes entry "ks1", expected keyspace:mode`)
+
+	balancerKeyspaceModes = "ks1:fastest"
+	require.EqualError(t, tg.setupBalancers(), `unknown tablet balancer mode "fastest", allowed values: random, least_loaded`)
+
+	balancerMode = "fastest"
+	balancerKeyspaceModes = ""
+	require.Error(t, tg.setupBalancers())
+}
+
+// blockingConn does not answer the queries until they are cancelled
+type blockingConn struct {
+	*sandboxconn.SandboxConn
+	cancelled chan struct{}
+}
+
+func (bc *blockingConn) Execute(ctx context.Context, target *querypb.Target, query string, bindVars map[string]*querypb.BindVariable, transactionID, reservedID int64, options *querypb.ExecuteOptions) (*sqltypes.Result, error) {
+	<-ctx.Done()
+	close(bc.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestTabletGatewayHedgedReads(t *testing.T) {
+	ctx := utils.LeakCheckContext(t)
+	defer func(enabled bool, minDelay time.Duration) {
+		hedgedReads = enabled
+		hedgedReadsMinDelay = minDelay
+	}(hedgedReads, hedgedReadsMinDelay)
+	hedgedReads = true
+	hedgedReadsMinDelay = time.Millisecond
+
+	target := &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
+	hc := discovery.NewFakeHealthCheck(nil)
+	tg := NewTabletGateway(ctx, hc, &fakeTopoServer{}, "cell")
+	defer tg.Close(ctx)
+
+	// the tablet of the local cell is tried first, but it does not answer
+	var slow *blockingConn
+	hc.AddFakeTablet("cell", "1.1.1.1", 1001, "ks", "0", target.TabletType, true, 10, nil, func(tablet *topodatapb.Tablet) queryservice.QueryService {
+		slow = &blockingConn{SandboxConn: sandboxconn.NewSandboxConn(tablet), cancelled: make(chan struct{})}
+		return slow
+	})
+	fast := hc.AddTestTablet("cell2", "1.1.1.2", 1001, "ks", "0", target.TabletType, true, 10, nil)
+
+	// the queries are not hedged before we know the latencies of the target
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err := tg.Execute(shortCtx, target, "select 1", nil, 0, 0, nil)
+	require.ErrorContains(t, err, "context deadline exceeded")
+	assert.EqualValues(t, 0, fast.ExecCount.Load())
+
+	lt := tg.latencyTrackerFor(target)
+	for i := 0; i < hedgingMinSamples; i++ {
+		lt.record(time.Millisecond, hedgedReadsPercentile)
+	}
+	slow.cancelled = make(chan struct{})
+	statsKey := "ks.0.replica"
+	won := hedgedReadsWon.Counts()[statsKey]
+	_, err = tg.Execute(ctx, target, "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, fast.ExecCount.Load())
+	assert.EqualValues(t, won+1, hedgedReadsWon.Counts()[statsKey])
+	// the query of the slow tablet was cancelled
+	<-slow.cancelled
+
+	// the directive can disable the hedging
+	slow.cancelled = make(chan struct{})
+	shortCtx, cancel = context.WithTimeout(withHedgedReads(ctx, false), 50*time.Millisecond)
+	defer cancel()
+	_, err = tg.Execute(shortCtx, target, "select 1", nil, 0, 0, nil)
+	require.ErrorContains(t, err, "context deadline exceeded")
+	assert.EqualValues(t, 1, fast.ExecCount.Load())
+}
+
+func TestLatencyTracker(t *testing.T) {
+	lt := &latencyTracker{}
+	_, ok := lt.hedgingDelay()
+	assert.False(t, ok)
+	// the delay is computed again every hedgingRefreshEvery latencies
+	for i := 1; i <= 2*hedgingRefreshEvery; i++ {
+		lt.record(time.Duration(i)*time.Millisecond, 50)
+	}
+	delay, ok := lt.hedgingDelay()
+	assert.True(t, ok)
+	assert.Equal(t, 32*time.Millisecond, delay)
+
+	// the older latencies are forgotten
+	for i := 0; i < hedgingSamples; i++ {
+		lt.record(2*time.Millisecond, 50)
+	}
+	delay, _ = lt.hedgingDelay()
+	assert.Equal(t, hedgedReadsMinDelay, delay)
+}
+
 func TestTabletGatewayReplicaTransactionError(t *testing.T) {
 	ctx := utils.LeakCheckContext(t)
 
@@ -298,3 +413,81 @@ func verifyShardErrors(t *testing.T, err error, wantErrors []string, wantCode vt
 	}
 	require.Equal(t, vterrors.Code(err), wantCode, "wanted error code: %s, got: %v", wantCode, vterrors.Code(err))
 }
+
+func TestTabletGatewayReadAfterWrite(t *testing.T) {
+	ctx := utils.LeakCheckContext(t)
+	target := &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
+	hc := discovery.NewFakeHealthCheck(nil)
+	tg := NewTabletGateway(ctx, hc, &fakeTopoServer{}, "cell")
+	defer tg.Close(ctx)
+	replica := hc.AddTestTablet("cell", "1.1.1.1", 1001, "ks", "0", topodatapb.TabletType_REPLICA, true, 10, nil)
+	primary := hc.AddTestTablet("cell", "1.1.1.2", 1001, "ks", "0", topodatapb.TabletType_PRIMARY, true, 0, nil)
+
+	const uuid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	rawCtx := func(gtids string) context.Context {
+		session := NewSafeSession(&vtgatepb.Session{ReadAfterWrite: &vtgatepb.ReadAfterWrite{ReadAfterWriteGtid: gtids, ReadAfterWriteTimeout: 0.5}})
+		return withReadAfterWrite(ctx, session)
+	}
+	waited := sqltypes.MakeTestResult(sqltypes.MakeTestFields("wait", "int64"), "0")
+	timedOut := sqltypes.MakeTestResult(sqltypes.MakeTestFields("wait", "int64"), "1")
+
+	// the replica waits for the GTIDs of the shard
+	replica.SetResults([]*sqltypes.Result{waited})
+	_, err := tg.Execute(rawCtx("other/0|"+uuid+":1-100;ks/0|"+uuid+":1-5"), target, "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	utils.MustMatch(t, []*querypb.BoundQuery{{
+		Sql: waitForGTIDQuery,
+		BindVariables: map[string]*querypb.BindVariable{
+			"gtid":    sqltypes.StringBindVariable(uuid + ":1-5"),
+			"timeout": sqltypes.Float64BindVariable(0.5),
+		},
+	}, {
+		Sql:           "select 1",
+		BindVariables: map[string]*querypb.BindVariable{},
+	}}, replica.Queries)
+
+	// the replica is known to have applied them
+	replica.Queries = nil
+	_, err = tg.Execute(rawCtx(uuid+":1-3"), target, "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, replica.Queries, 1)
+
+	// the query is sent to the primary when the replica is late
+	replica.Queries = nil
+	replica.SetResults([]*sqltypes.Result{timedOut})
+	_, err = tg.Execute(rawCtx(uuid+":1-10"), target, "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, replica.Queries, 1)
+	assert.Equal(t, waitForGTIDQuery, replica.Queries[0].Sql)
+	require.Len(t, primary.Queries, 1)
+	assert.Equal(t, "select 1", primary.Queries[0].Sql)
+
+	// the GTIDs executed by the primary are captured when the GTIDs of the writes are unknown
+	primary.Queries = nil
+	replica.Queries = nil
+	primary.SetResults([]*sqltypes.Result{sqltypes.MakeTestResult(sqltypes.MakeTestFields("@@global.gtid_executed", "varchar"), uuid+":1-12")})
+	replica.SetResults([]*sqltypes.Result{waited})
+	_, err = tg.Execute(rawCtx("ks/0|"), target, "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, primary.Queries, 1)
+	assert.Equal(t, executedGTIDsQuery, primary.Queries[0].Sql)
+	require.Len(t, replica.Queries, 2)
+	assert.Equal(t, uuid+":1-12", string(replica.Queries[0].BindVariables["gtid"].Value))
+
+	// and the query is sent to the primary if they cannot be captured
+	primary.Queries = nil
+	replica.Queries = nil
+	primary.MustFailCodes[vtrpcpb.Code_UNAVAILABLE] = 1
+	_, err = tg.Execute(rawCtx("ks/0|"), target, "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Empty(t, replica.Queries)
+	require.Len(t, primary.Queries, 2)
+	assert.Equal(t, executedGTIDsQuery, primary.Queries[0].Sql)
+	assert.Equal(t, "select 1", primary.Queries[1].Sql)
+
+	// the queries of the primary do not wait
+	primary.Queries = nil
+	_, err = tg.Execute(rawCtx(uuid+":1-20"), primaryTarget(target), "select 1", nil, 0, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, primary.Queries, 1)
+}
@@ -92,10 +92,45 @@ func (txc *TxConn) Commit(ctx context.Context, session *SafeSession) error {
 		twopc = txc.mode == vtgatepb.TransactionMode_TWOPC
 	}
 
+	// the transactions of the shard sessions are reset by the commit
+	tracked := txc.gtidsToTrack(session)
+
+	var err error
 	if twopc {
-		return txc.commit2PC(ctx, session)
+		err = txc.commit2PC(ctx, session)
+	} else {
+		err = txc.commitNormal(ctx, session)
+	}
+	if err != nil {
+		return err
+	}
+	txc.trackCommitGTIDs(session, tracked)
+	return nil
+}
+
+// gtidsToTrack returns the shard sessions of the transaction whose writes have to be tracked after the commit,
+// if the session tracks its GTIDs.
+func (txc *TxConn) gtidsToTrack(session *SafeSession) []*vtgatepb.Session_ShardSession {
+	if !session.TracksGTIDs() {
+		return nil
+	}
+	var tracked []*vtgatepb.Session_ShardSession
+	for _, shardSessions := range [][]*vtgatepb.Session_ShardSession{session.PreSessions, session.ShardSessions, session.PostSessions} {
+		for _, s := range shardSessions {
+			if s.TransactionId != 0 && s.Target.TabletType == topodatapb.TabletType_PRIMARY {
+				tracked = append(tracked, &vtgatepb.Session_ShardSession{Target: s.Target, TabletAlias: s.TabletAlias})
+			}
+		}
+	}
+	return tracked
+}
+
+// trackCommitGTIDs marks the shards written by the transaction, for the next replica reads of the
+// session to capture the GTIDs of the commit. MySQL does not report the GTIDs of a commit.
+func (txc *TxConn) trackCommitGTIDs(session *SafeSession, tracked []*vtgatepb.Session_ShardSession) {
+	for _, s := range tracked {
+		trackWriteGTID(session, s.Target, nil)
 	}
-	return txc.commitNormal(ctx, session)
 }
 
 func (txc *TxConn) queryService(alias *topodatapb.TabletAlias) (queryservice.QueryService, error) {
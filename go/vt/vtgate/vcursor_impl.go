@@ -51,6 +51,7 @@ import (
 	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/logstats"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/queryrewrite"
 	"vitess.io/vitess/go/vt/vtgate/semantics"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vtgate/vschemaacl"
@@ -76,6 +77,7 @@ type iExecute interface {
 	showVitessReplicationStatus(ctx context.Context, filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
 	showShards(ctx context.Context, filter *sqlparser.ShowFilter, destTabletType topodatapb.TabletType) (*sqltypes.Result, error)
 	showTablets(filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
+	showQueryDigests(filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
 	showVitessMetadata(ctx context.Context, filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
 	setVitessMetadata(ctx context.Context, name, value string) error
 
@@ -116,6 +118,17 @@ type vcursorImpl struct {
 
 	warmingReadsPercent int
 	warmingReadsChannel chan bool
+
+	// resultCache is set when the query asks for its result to be cached, for resultCacheTTL or the default TTL
+	resultCache    bool
+	resultCacheTTL time.Duration
+
+	// hedgedReads overrides --tablet-hedged-reads for the query when hedgedReadsSet is true
+	hedgedReads    bool
+	hedgedReadsSet bool
+
+	// shards are the distinct shards queried, when the query digests are enabled
+	shards *shardSet
 }
 
 // newVcursorImpl creates a vcursorImpl. Before creating this object, you have to separate out any marginComments that came with
@@ -162,9 +175,13 @@ func newVCursorImpl(
 
 	warmingReadsPct := 0
 	var warmingReadsChan chan bool
+	var shards *shardSet
 	if executor != nil {
 		warmingReadsPct = executor.warmingReadsPercent
 		warmingReadsChan = executor.warmingReadsChannel
+		if executor.digests != nil {
+			shards = &shardSet{}
+		}
 	}
 	return &vcursorImpl{
 		safeSession:         safeSession,
@@ -183,6 +200,7 @@ func newVCursorImpl(
 		pv:                  pv,
 		warmingReadsPercent: warmingReadsPct,
 		warmingReadsChannel: warmingReadsChan,
+		shards:              shards,
 	}, nil
 }
 
@@ -553,6 +571,7 @@ const txRollback = "Rollback Transaction"
 func (vc *vcursorImpl) ExecuteMultiShard(ctx context.Context, primitive engine.Primitive, rss []*srvtopo.ResolvedShard, queries []*querypb.BoundQuery, rollbackOnError, canAutocommit bool) (*sqltypes.Result, []error) {
 	noOfShards := len(rss)
 	atomic.AddUint64(&vc.logStats.ShardQueries, uint64(noOfShards))
+	vc.shards.add(rss...)
 	err := vc.markSavepoint(ctx, rollbackOnError && (noOfShards > 1), map[string]*querypb.BindVariable{})
 	if err != nil {
 		return nil, []error{err}
@@ -568,6 +587,7 @@ func (vc *vcursorImpl) ExecuteMultiShard(ctx context.Context, primitive engine.P
 func (vc *vcursorImpl) StreamExecuteMulti(ctx context.Context, primitive engine.Primitive, query string, rss []*srvtopo.ResolvedShard, bindVars []map[string]*querypb.BindVariable, rollbackOnError bool, autocommit bool, callback func(reply *sqltypes.Result) error) []error {
 	noOfShards := len(rss)
 	atomic.AddUint64(&vc.logStats.ShardQueries, uint64(noOfShards))
+	vc.shards.add(rss...)
 	err := vc.markSavepoint(ctx, rollbackOnError && (noOfShards > 1), map[string]*querypb.BindVariable{})
 	if err != nil {
 		return []error{err}
@@ -588,6 +608,7 @@ func (vc *vcursorImpl) ExecuteLock(ctx context.Context, rs *srvtopo.ResolvedShar
 // ExecuteStandalone is part of the engine.VCursor interface.
 func (vc *vcursorImpl) ExecuteStandalone(ctx context.Context, primitive engine.Primitive, query string, bindVars map[string]*querypb.BindVariable, rs *srvtopo.ResolvedShard) (*sqltypes.Result, error) {
 	rss := []*srvtopo.ResolvedShard{rs}
+	vc.shards.add(rs)
 	bqs := []*querypb.BoundQuery{
 		{
 			Sql:           vc.marginComments.Leading + query + vc.marginComments.Trailing,
@@ -893,6 +914,18 @@ func (vc *vcursorImpl) SetConsolidator(consolidator querypb.ExecuteOptions_Conso
 	vc.safeSession.GetOrCreateOptions().Consolidator = consolidator
 }
 
+// applyQueryRewrite routes the query to the keyspace and the tablet type chosen by the query rewriting rules.
+// The tablet type is only changed for the selects outside of a transaction.
+func (vc *vcursorImpl) applyQueryRewrite(rewrite *queryrewrite.Result, stmt sqlparser.Statement) {
+	if rewrite.Keyspace != "" {
+		vc.keyspace = rewrite.Keyspace
+		vc.destination = nil
+	}
+	if rewrite.TabletType != topodatapb.TabletType_UNKNOWN && !vc.safeSession.InTransaction() && sqlparser.ASTToStatementType(stmt) == sqlparser.StmtSelect {
+		vc.tabletType = rewrite.TabletType
+	}
+}
+
 func (vc *vcursorImpl) SetWorkloadName(workloadName string) {
 	if workloadName != "" {
 		vc.safeSession.GetOrCreateOptions().WorkloadName = workloadName
@@ -1145,6 +1178,7 @@ func (vc *vcursorImpl) ExecuteVSchema(ctx context.Context, keyspace string, vsch
 
 func (vc *vcursorImpl) MessageStream(ctx context.Context, rss []*srvtopo.ResolvedShard, tableName string, callback func(*sqltypes.Result) error) error {
 	atomic.AddUint64(&vc.logStats.ShardQueries, uint64(len(rss)))
+	vc.shards.add(rss...)
 	return vc.executor.ExecuteMessageStream(ctx, rss, tableName, callback)
 }
 
@@ -1154,6 +1188,8 @@ func (vc *vcursorImpl) VStream(ctx context.Context, rss []*srvtopo.ResolvedShard
 
 func (vc *vcursorImpl) ShowExec(ctx context.Context, command sqlparser.ShowCommandType, filter *sqlparser.ShowFilter) (*sqltypes.Result, error) {
 	switch command {
+	case sqlparser.VitessQueryDigests:
+		return vc.executor.showQueryDigests(filter)
 	case sqlparser.VitessReplicationStatus:
 		return vc.executor.showVitessReplicationStatus(ctx, filter)
 	case sqlparser.VitessShards:
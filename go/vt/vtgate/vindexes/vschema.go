@@ -106,6 +106,9 @@ type Table struct {
 	Columns                 []Column               `json:"columns,omitempty"`
 	Pinned                  []byte                 `json:"pinned,omitempty"`
 	ColumnListAuthoritative bool                   `json:"column_list_authoritative,omitempty"`
+	// PrimaryKey are the columns of the primary key of the table, if known.
+	// This information comes from the schema tracker.
+	PrimaryKey sqlparser.Columns `json:"primary_key,omitempty"`
 	// ReferencedBy is an inverse mapping of tables in other keyspaces that
 	// reference this table via Source.
 	//
@@ -144,9 +147,10 @@ type ColumnVindex struct {
 	backfill bool
 }
 
-// TableInfo contains column and foreign key info for a table.
+// TableInfo contains column, primary key and foreign key info for a table.
 type TableInfo struct {
 	Columns     []Column
+	PrimaryKey  sqlparser.Columns
 	ForeignKeys []*sqlparser.ForeignKeyDefinition
 }
 
@@ -946,6 +950,23 @@ func (vschema *VSchema) FindTable(keyspace, tablename string) (*Table, error) {
 	)
 }
 
+// AddPrimaryKey sets the primary key columns of a table in the vschema.
+func (vschema *VSchema) AddPrimaryKey(ksname, tblName string, cols []string) error {
+	ks, ok := vschema.Keyspaces[ksname]
+	if !ok {
+		return fmt.Errorf("keyspace %s not found in vschema", ksname)
+	}
+	tbl, ok := ks.Tables[tblName]
+	if !ok {
+		return fmt.Errorf("table %s not found in keyspace %s", tblName, ksname)
+	}
+	tbl.PrimaryKey = nil
+	for _, col := range cols {
+		tbl.PrimaryKey = append(tbl.PrimaryKey, sqlparser.NewIdentifierCI(col))
+	}
+	return nil
+}
+
 // findGlobalTable looks for a table that is uniquely named across all
 // keyspaces.
 //
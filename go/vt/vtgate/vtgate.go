@@ -26,6 +26,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -51,6 +52,10 @@ import (
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+	"vitess.io/vitess/go/vt/vtgate/queryrewrite"
+	"vitess.io/vitess/go/vt/vtgate/quota"
+	"vitess.io/vitess/go/vt/vtgate/resultcache"
 	vtschema "vitess.io/vitess/go/vt/vtgate/schema"
 	"vitess.io/vitess/go/vt/vtgate/vtgateservice"
 )
@@ -115,6 +120,19 @@ var (
 	warmingReadsPercent      = 0
 	warmingReadsQueryTimeout = 5 * time.Second
 	warmingReadsConcurrency  = 500
+
+	// plan cache snapshots, to warm up the plan cache after a restart
+	planCacheSnapshotFile      string
+	planCacheSnapshotInterval  = 5 * time.Minute
+	planCacheSnapshotSize      = 1000
+	planCacheWarmupTimeout     = time.Minute
+	planCacheWarmupConcurrency = 4
+
+	// query digests
+	queryDigestsMaxQueries int
+
+	// readAfterWriteTimeout is how long the replica reads wait for the previous writes of their session
+	readAfterWriteTimeout = time.Second
 )
 
 func registerFlags(fs *pflag.FlagSet) {
@@ -151,6 +169,13 @@ func registerFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&warmingReadsPercent, "warming-reads-percent", 0, "Percentage of reads on the primary to forward to replicas. Useful for keeping buffer pools warm")
 	fs.IntVar(&warmingReadsConcurrency, "warming-reads-concurrency", 500, "Number of concurrent warming reads allowed")
 	fs.DurationVar(&warmingReadsQueryTimeout, "warming-reads-query-timeout", 5*time.Second, "Timeout of warming read queries")
+	fs.StringVar(&planCacheSnapshotFile, "plan-cache-snapshot-file", planCacheSnapshotFile, "Local file where the most executed queries of the plan cache are saved. At startup, their plans are built again before vtgate reports healthy")
+	fs.DurationVar(&planCacheSnapshotInterval, "plan-cache-snapshot-interval", planCacheSnapshotInterval, "How often the most executed queries of the plan cache are saved to --plan-cache-snapshot-file")
+	fs.IntVar(&planCacheSnapshotSize, "plan-cache-snapshot-size", planCacheSnapshotSize, "Maximum number of queries saved to --plan-cache-snapshot-file")
+	fs.DurationVar(&planCacheWarmupTimeout, "plan-cache-warmup-timeout", planCacheWarmupTimeout, "Maximum time spent warming up the plan cache at startup, during which vtgate reports unhealthy")
+	fs.IntVar(&planCacheWarmupConcurrency, "plan-cache-warmup-concurrency", planCacheWarmupConcurrency, "Number of queries planned in parallel when warming up the plan cache")
+	fs.IntVar(&queryDigestsMaxQueries, "query-digests-max-queries", queryDigestsMaxQueries, "Maximum number of normalized queries whose execution statistics are aggregated, and shown by SHOW VITESS_QUERY_DIGESTS and /debug/query_digests. The query digests are disabled when set to 0")
+	fs.DurationVar(&readAfterWriteTimeout, "read-after-write-timeout", readAfterWriteTimeout, "How long a replica read waits for its replica to apply the previous writes of the session, when the session tracks their GTIDs (session_track_gtids = own_gtid) or sets read_after_write_gtid, before being sent to the primary. Can be overridden by the read_after_write_timeout session variable")
 
 	_ = fs.String("schema_change_signal_user", "", "User to be used to send down query to vttablet to retrieve schema changes")
 	_ = fs.MarkDeprecated("schema_change_signal_user", "schema tracking uses an internal api and does not require a user to be specified")
@@ -238,6 +263,9 @@ type VTGate struct {
 	logExecute       *logutil.ThrottledLogger
 	logPrepare       *logutil.ThrottledLogger
 	logStreamExecute *logutil.ThrottledLogger
+
+	// planCacheWarming is true while the plan cache is warmed up from its snapshot
+	planCacheWarming atomic.Bool
 }
 
 // RegisterVTGate defines the type of registration mechanism.
@@ -329,6 +357,20 @@ func Init(
 		warmingReadsPercent,
 	)
 
+	executor.quotas, err = quota.NewLimiterFromFlags(ctx, ts)
+	if err != nil {
+		log.Fatalf("Unable to load the quota rules: %v", err)
+	}
+
+	executor.rewriteRules, err = queryrewrite.NewEngineFromFlags(ctx, ts)
+	if err != nil {
+		log.Fatalf("Unable to load the query rewriting rules: %v", err)
+	}
+	if queryDigestsMaxQueries > 0 {
+		executor.digests = querydigest.NewCollector(queryDigestsMaxQueries)
+	}
+	executor.resultCache = resultcache.NewCacheFromFlags(resultCacheStream(vsm))
+
 	if err := executor.defaultQueryLogger(); err != nil {
 		log.Fatalf("error initializing query logger: %v", err)
 	}
@@ -365,14 +407,42 @@ func Init(
 		if st != nil && enableSchemaChangeSignal {
 			st.Stop()
 		}
+		if executor.resultCache != nil {
+			executor.resultCache.Close()
+		}
+		if planCacheSnapshotFile != "" && !vtgateInst.planCacheWarming.Load() {
+			if err := executor.savePlanSnapshot(planCacheSnapshotFile, planCacheSnapshotSize); err != nil {
+				log.Warningf("Unable to save the plan cache snapshot %s: %v", planCacheSnapshotFile, err)
+			}
+		}
 	})
 	vtgateInst.registerDebugHealthHandler()
 	vtgateInst.registerDebugEnvHandler()
+	vtgateInst.registerDebugBuffersHandler()
+	vtgateInst.registerDebugQueryDigestsHandler()
+	vtgateInst.startPlanCacheSnapshots(ctx)
 
 	initAPI(gw.hc)
 	return vtgateInst
 }
 
+// resultCacheStream returns the VStream of the row changes invalidating the result cache.
+// It follows the primaries, whose changes are the first ones to be visible.
+func resultCacheStream(vsm *vstreamManager) resultcache.StreamFunc {
+	return func(ctx context.Context, keyspace string, tables []string, send func([]*binlogdatapb.VEvent) error) error {
+		vgtid := &binlogdatapb.VGtid{
+			ShardGtids: []*binlogdatapb.ShardGtid{{Keyspace: keyspace, Gtid: "current"}},
+		}
+		filter := &binlogdatapb.Filter{}
+		for _, table := range tables {
+			filter.Rules = append(filter.Rules, &binlogdatapb.Rule{Match: table})
+		}
+		// the heartbeats tell the cache the stream is running, even when the tables do not change
+		flags := &vtgatepb.VStreamFlags{HeartbeatInterval: 1}
+		return vsm.VStream(ctx, topodatapb.TabletType_PRIMARY, vgtid, filter, flags, send)
+	}
+}
+
 func addKeyspacesToTracker(ctx context.Context, srvResolver *srvtopo.Resolver, st *vtschema.Tracker, gw *TabletGateway) {
 	keyspaces, err := srvResolver.GetAllKeyspaces(ctx)
 	if err != nil {
@@ -432,9 +502,22 @@ func (vtg *VTGate) registerDebugHealthHandler() {
 	})
 }
 
+func (vtg *VTGate) registerDebugBuffersHandler() {
+	servenv.HTTPHandleFunc("/debug/buffers", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		returnAsJSON(w, vtg.gw.BufferStatus())
+	})
+}
+
 // IsHealthy returns nil if server is healthy.
 // Otherwise, it returns an error indicating the reason.
 func (vtg *VTGate) IsHealthy() error {
+	if vtg.planCacheWarming.Load() {
+		return errors.New("the plan cache is warming up")
+	}
 	return nil
 }
 
@@ -197,6 +197,10 @@ func (vde *Engine) handleCreateResumeAction(ctx context.Context, dbClient binlog
 		if err != nil {
 			return err
 		}
+		scheduleOptions := options.GetScheduleOptions()
+		if err := validateScheduleOptions(scheduleOptions); err != nil {
+			return vterrors.Wrapf(err, "invalid schedule options for vdiff %s", req.VdiffUuid)
+		}
 		query, err := sqlparser.ParseAndBind(sqlNewVDiff,
 			sqltypes.StringBindVariable(req.Keyspace),
 			sqltypes.StringBindVariable(req.Workflow),
@@ -205,6 +209,9 @@ func (vde *Engine) handleCreateResumeAction(ctx context.Context, dbClient binlog
 			sqltypes.StringBindVariable(vde.thisTablet.Shard),
 			sqltypes.StringBindVariable(topoproto.TabletDbName(vde.thisTablet)),
 			sqltypes.StringBindVariable(req.VdiffUuid),
+			sqltypes.BoolBindVariable(scheduleOptions.GetRecheck()),
+			sqltypes.Int64BindVariable(scheduleOptions.GetIntervalSeconds()),
+			sqltypes.BoolBindVariable(scheduleOptions.GetIncremental()),
 		)
 		if err != nil {
 			return err
@@ -81,7 +81,7 @@ func TestPerformVDiffAction(t *testing.T) {
 					query: fmt.Sprintf("select id as id from _vt.vdiff where vdiff_uuid = %s", encodeString(uuid)),
 				},
 				{
-					query: fmt.Sprintf(`insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid) values('', '', 'pending', '{\"picker_options\":{\"source_cell\":\"cell1,zone100_test\",\"target_cell\":\"cell1,zone100_test\"}}', '0', 'vt_vttest', %s)`, encodeString(uuid)),
+					query: fmt.Sprintf(`insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid, recheck, schedule_interval_seconds, incremental) values('', '', 'pending', '{\"picker_options\":{\"source_cell\":\"cell1,zone100_test\",\"target_cell\":\"cell1,zone100_test\"}}', '0', 'vt_vttest', %s, 0, 0, 0)`, encodeString(uuid)),
 				},
 			},
 			postFunc: func() error {
@@ -115,7 +115,7 @@ func TestPerformVDiffAction(t *testing.T) {
 					query: fmt.Sprintf("select id as id from _vt.vdiff where vdiff_uuid = %s", encodeString(uuid)),
 				},
 				{
-					query: fmt.Sprintf(`insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid) values('', '', 'pending', '{\"picker_options\":{\"source_cell\":\"all\",\"target_cell\":\"all\"}}', '0', 'vt_vttest', %s)`, encodeString(uuid)),
+					query: fmt.Sprintf(`insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid, recheck, schedule_interval_seconds, incremental) values('', '', 'pending', '{\"picker_options\":{\"source_cell\":\"all\",\"target_cell\":\"all\"}}', '0', 'vt_vttest', %s, 0, 0, 0)`, encodeString(uuid)),
 				},
 			},
 			postFunc: func() error {
@@ -125,6 +125,54 @@ func TestPerformVDiffAction(t *testing.T) {
 				return tstenv.TopoServ.DeleteCellsAlias(ctx, "all")
 			},
 		},
+		{
+			name: "create with schedule options",
+			req: &tabletmanagerdatapb.VDiffRequest{
+				Action:    string(CreateAction),
+				VdiffUuid: uuid,
+				Options: &tabletmanagerdatapb.VDiffOptions{
+					PickerOptions: &tabletmanagerdatapb.VDiffPickerOptions{
+						SourceCell: "cell1",
+						TargetCell: "cell1",
+					},
+					ScheduleOptions: &tabletmanagerdatapb.VDiffScheduleOptions{
+						Recheck:         true,
+						IntervalSeconds: 3600,
+						Incremental:     true,
+					},
+				},
+			},
+			expectQueries: []queryAndResult{
+				{
+					query: fmt.Sprintf("select id as id from _vt.vdiff where vdiff_uuid = %s", encodeString(uuid)),
+				},
+				{
+					query: fmt.Sprintf(`insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid, recheck, schedule_interval_seconds, incremental) values('', '', 'pending', '{\"picker_options\":{\"source_cell\":\"cell1\",\"target_cell\":\"cell1\"},\"schedule_options\":{\"recheck\":true,\"interval_seconds\":3600,\"incremental\":true}}', '0', 'vt_vttest', %s, 1, 3600, 1)`, encodeString(uuid)),
+				},
+			},
+		},
+		{
+			name: "create with invalid schedule options",
+			req: &tabletmanagerdatapb.VDiffRequest{
+				Action:    string(CreateAction),
+				VdiffUuid: uuid,
+				Options: &tabletmanagerdatapb.VDiffOptions{
+					PickerOptions: &tabletmanagerdatapb.VDiffPickerOptions{
+						SourceCell: "cell1",
+						TargetCell: "cell1",
+					},
+					ScheduleOptions: &tabletmanagerdatapb.VDiffScheduleOptions{
+						Incremental: true,
+					},
+				},
+			},
+			expectQueries: []queryAndResult{
+				{
+					query: fmt.Sprintf("select id as id from _vt.vdiff where vdiff_uuid = %s", encodeString(uuid)),
+				},
+			},
+			wantErr: vterrors.Errorf(vtrpcpb.Code_UNKNOWN, "invalid schedule options for vdiff %s: incremental vdiffs need a schedule interval", uuid),
+		},
 		{
 			name: "delete by uuid",
 			req: &tabletmanagerdatapb.VDiffRequest{
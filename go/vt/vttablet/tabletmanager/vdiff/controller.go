@@ -74,6 +74,10 @@ type controller struct {
 	filter              *binlogdatapb.Filter            // vreplication row filter
 	options             *tabletmanagerdata.VDiffOptions // options initially from vtctld command and later from _vt.vdiff
 
+	// recheck and incremental are the schedule options of the vdiff, from _vt.vdiff.
+	recheck     bool
+	incremental bool
+
 	sourceTimeZone, targetTimeZone string // named time zones if conversions are necessary for datetime values
 
 	externalCluster string // for Mount+Migrate
@@ -96,6 +100,8 @@ func newController(ctx context.Context, row sqltypes.RowNamedValues, dbClientFac
 		tmc:             vde.tmClientFactory(),
 		sources:         make(map[string]*migrationSource),
 		options:         options,
+		recheck:         row.AsBool("recheck", false),
+		incremental:     row.AsBool("incremental", false),
 	}
 	ctx, ct.cancel = context.WithCancel(ctx)
 	go ct.run(ctx)
@@ -154,7 +154,8 @@ func (vde *Engine) openLocked(ctx context.Context) error {
 	}
 
 	// At this point we've fully and succesfully opened so begin
-	// retrying error'd VDiffs until the engine is closed.
+	// retrying error'd VDiffs, and starting the scheduled runs of
+	// the completed ones, until the engine is closed.
 	vde.wg.Add(1)
 	go func() {
 		defer vde.wg.Done()
@@ -377,7 +378,61 @@ func (vde *Engine) retryErroredVDiffs() {
 		if err := vde.retryVDiffs(vde.ctx); err != nil {
 			log.Errorf("Error retrying vdiffs: %v", err)
 		}
+		if err := vde.rescheduleVDiffs(vde.ctx); err != nil {
+			log.Errorf("Error starting scheduled vdiffs: %v", err)
+		}
+	}
+}
+
+// rescheduleVDiffs starts the next run of the completed vdiffs which have a
+// schedule interval, once the interval has elapsed since they completed.
+func (vde *Engine) rescheduleVDiffs(ctx context.Context) error {
+	vde.mu.Lock()
+	defer vde.mu.Unlock()
+	dbClient := vde.dbClientFactoryFiltered()
+	if err := dbClient.Connect(); err != nil {
+		return err
+	}
+	defer dbClient.Close()
+
+	qr, err := dbClient.ExecuteFetch(sqlGetVDiffsToReschedule, -1)
+	if err != nil {
+		return err
+	}
+	for _, row := range qr.Named().Rows {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		uuid := row.AsString("vdiff_uuid", "")
+		id, err := row.ToInt64("id")
+		if err != nil {
+			return err
+		}
+		query, err := sqlparser.ParseAndBind(sqlRescheduleVDiff, sqltypes.Int64BindVariable(id))
+		if err != nil {
+			return err
+		}
+		res, err := dbClient.ExecuteFetch(query, -1)
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected == 0 {
+			// It was deleted or resumed in the meantime.
+			continue
+		}
+		log.Infof("Starting the scheduled run of vdiff %s", uuid)
+		insertVDiffLog(ctx, dbClient, id, "Starting the scheduled run")
+		options := &tabletmanagerdata.VDiffOptions{}
+		if err := json.Unmarshal(row.AsBytes("options", []byte("{}")), options); err != nil {
+			return err
+		}
+		if err := vde.addController(row, options); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (vde *Engine) resetControllers() {
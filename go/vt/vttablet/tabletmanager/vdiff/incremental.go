@@ -0,0 +1,352 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vdiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+	"vitess.io/vitess/go/vt/vttablet/tabletconn"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+const (
+	// maxVDiffRecheckRows is the maximum number of rows which differ that are re-checked.
+	// The table is reported as it is when more rows differ.
+	maxVDiffRecheckRows = 1000
+	// maxVDiffIncrementalRows is the maximum number of rows changed on the sources
+	// that are compared by an incremental diff. The whole table is compared when
+	// more rows have changed.
+	maxVDiffIncrementalRows = 100000
+)
+
+// errTooManyChanges stops the collection of the changed rows.
+var errTooManyChanges = fmt.Errorf("more than %d rows have changed", maxVDiffIncrementalRows)
+
+// prepareIncrementalDiff restricts the diff of the table to the rows changed on the sources
+// since the positions up to which the table was last found to have no differences. The whole
+// table is compared when the changes cannot be collected.
+func (td *tableDiffer) prepareIncrementalDiff(ctx context.Context, dbClient binlogplayer.DBClient) error {
+	td.pks, td.verifiedPos = nil, nil
+	query, err := sqlparser.ParseAndBind(sqlGetTableVerifiedPos,
+		sqltypes.Int64BindVariable(td.wd.ct.id),
+		sqltypes.StringBindVariable(td.table.Name),
+	)
+	if err != nil {
+		return err
+	}
+	qr, err := dbClient.ExecuteFetch(query, 1)
+	if err != nil {
+		return err
+	}
+	verifiedPos := make(map[string]string)
+	if len(qr.Rows) == 1 {
+		if pos := qr.Named().Row().AsBytes("last_verified_pos", nil); len(pos) > 0 {
+			if err := json.Unmarshal(pos, &verifiedPos); err != nil {
+				return err
+			}
+		}
+	}
+
+	fallback := func(reason string) error {
+		msg := fmt.Sprintf("Comparing the whole table %s: %s", td.table.Name, reason)
+		log.Infof("%s for vdiff %s", msg, td.wd.ct.uuid)
+		insertVDiffLog(ctx, dbClient, td.wd.ct.id, msg)
+		return nil
+	}
+	for shard := range td.wd.ct.sources {
+		if verifiedPos[shard] == "" {
+			return fallback("it was not verified in a previous run")
+		}
+	}
+	if len(td.tablePlan.aggregates) != 0 {
+		return fallback("its rows are aggregated")
+	}
+	if td.tablePlan.sourceTable == "" {
+		return fallback("its source table is not known")
+	}
+
+	pks, stopPos, err := td.collectChangedPKs(ctx, dbClient, verifiedPos)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fallback(fmt.Sprintf("the changed rows could not be collected: %v", err))
+	}
+	pks = td.sortPKs(pks)
+	if td.lastPK != nil && len(td.lastPK.Rows) == 1 {
+		// The diff is resumed: the rows up to the last primary key were already compared.
+		lastPK := sqltypes.MakeRowTrusted(td.lastPK.Fields, td.lastPK.Rows[0])
+		i := sort.Search(len(pks), func(i int) bool {
+			return td.comparePKValues(pks[i], lastPK) > 0
+		})
+		pks = pks[i:]
+	} else if len(pks) > 0 {
+		// The rows are streamed from the first changed row when possible.
+		if before := pkBefore(pks[0]); before != nil {
+			td.lastPK = td.lastPKResult(before)
+		}
+	}
+	if pks == nil {
+		pks = [][]sqltypes.Value{}
+	}
+	td.pks, td.verifiedPos = pks, stopPos
+	insertVDiffLog(ctx, dbClient, td.wd.ct.id, fmt.Sprintf("Comparing the %d rows of table %s which changed since the last run", len(pks), td.table.Name))
+	return nil
+}
+
+// collectChangedPKs streams the changes of the source table on each source, from
+// the verified position up to the position of the stream of the source, and returns
+// the primary keys of the changed rows along with the positions they were streamed up to.
+func (td *tableDiffer) collectChangedPKs(ctx context.Context, dbClient binlogplayer.DBClient, verifiedPos map[string]string) ([][]sqltypes.Value, map[string]string, error) {
+	if err := td.updateSourcePositions(dbClient); err != nil {
+		return nil, nil, err
+	}
+	if err := td.selectTablets(ctx); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		pks     [][]sqltypes.Value
+		stopPos = make(map[string]string)
+	)
+	err := td.forEachSource(func(source *migrationSource) error {
+		startPos, err := binlogplayer.DecodePosition(verifiedPos[source.shard])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		stopPos[source.shard] = verifiedPos[source.shard]
+		mu.Unlock()
+		if startPos.AtLeast(source.position) {
+			return nil
+		}
+		conn, err := tabletconn.GetDialer()(source.tablet, false)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(ctx)
+
+		req := &binlogdatapb.VStreamRequest{
+			Target: &querypb.Target{
+				Keyspace:   source.tablet.Keyspace,
+				Shard:      source.shard,
+				TabletType: source.tablet.Type,
+			},
+			Position: verifiedPos[source.shard],
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  td.tablePlan.sourceTable,
+					Filter: td.sourceQuery,
+				}},
+			},
+		}
+		var (
+			fields []*querypb.Field
+			pkCols []int
+			done   bool
+		)
+		err = conn.VStream(ctx, req, func(events []*binlogdatapb.VEvent) error {
+			for _, event := range events {
+				switch event.Type {
+				case binlogdatapb.VEventType_FIELD:
+					indexes, err := td.pkFieldIndexes(event.FieldEvent.Fields)
+					if err != nil {
+						return err
+					}
+					fields, pkCols = event.FieldEvent.Fields, indexes
+				case binlogdatapb.VEventType_ROW:
+					if fields == nil {
+						return fmt.Errorf("unexpected: no fields for the rows of table %s", td.tablePlan.sourceTable)
+					}
+					mu.Lock()
+					for _, change := range event.RowEvent.RowChanges {
+						for _, image := range []*querypb.Row{change.Before, change.After} {
+							if image == nil {
+								continue
+							}
+							row := sqltypes.MakeRowTrusted(fields, image)
+							pk := make([]sqltypes.Value, len(pkCols))
+							for i, col := range pkCols {
+								pk[i] = row[col]
+							}
+							pks = append(pks, pk)
+						}
+					}
+					tooMany := len(pks) > maxVDiffIncrementalRows
+					mu.Unlock()
+					if tooMany {
+						return errTooManyChanges
+					}
+				case binlogdatapb.VEventType_GTID:
+					pos, err := binlogplayer.DecodePosition(event.Gtid)
+					if err != nil {
+						return err
+					}
+					if pos.AtLeast(source.position) {
+						mu.Lock()
+						stopPos[source.shard] = event.Gtid
+						mu.Unlock()
+						done = true
+						return io.EOF
+					}
+				}
+			}
+			return nil
+		})
+		if done {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("the stream of shard %s ended before reaching position %v", source.shard, source.position)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pks, stopPos, nil
+}
+
+// pkFieldIndexes returns the indexes of the primary key columns of the table in the fields.
+func (td *tableDiffer) pkFieldIndexes(fields []*querypb.Field) ([]int, error) {
+	pkCols := make([]int, len(td.tablePlan.comparePKs))
+	for i, pk := range td.tablePlan.comparePKs {
+		found := false
+		for j, field := range fields {
+			if strings.EqualFold(field.Name, pk.colName) {
+				pkCols[i] = j
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("primary key column %s of table %s is not streamed", pk.colName, td.table.Name)
+		}
+	}
+	return pkCols, nil
+}
+
+// sortPKs sorts the primary keys in the order of the rows and removes the duplicates.
+func (td *tableDiffer) sortPKs(pks [][]sqltypes.Value) [][]sqltypes.Value {
+	sort.SliceStable(pks, func(i, j int) bool {
+		return td.comparePKValues(pks[i], pks[j]) < 0
+	})
+	n := 0
+	for i, pk := range pks {
+		if i > 0 && td.comparePKValues(pks[n-1], pk) == 0 {
+			continue
+		}
+		pks[n] = pk
+		n++
+	}
+	return pks[:n]
+}
+
+// comparePKValues compares two primary keys, as the rows are ordered.
+func (td *tableDiffer) comparePKValues(a, b []sqltypes.Value) int {
+	for i, pk := range td.tablePlan.comparePKs {
+		collationID := pk.collation
+		if collationID == collations.Unknown {
+			collationID = collations.CollationBinaryID
+		}
+		c, err := evalengine.NullsafeCompare(a[i], b[i], collationID)
+		if err != nil {
+			// Fall back to comparing the values as strings when they cannot be compared.
+			c = strings.Compare(a[i].ToString(), b[i].ToString())
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// pkFilter selects the rows whose primary keys are in a list, in the order of the rows.
+type pkFilter struct {
+	td  *tableDiffer
+	pks [][]sqltypes.Value
+}
+
+func (td *tableDiffer) newPKFilter() func(row []sqltypes.Value) (match, stop bool, err error) {
+	pf := &pkFilter{td: td, pks: td.pks}
+	return pf.match
+}
+
+// match returns true if the primary key of the row is in the list. It stops
+// once the row is past the last primary key, as the rows are ordered.
+func (pf *pkFilter) match(row []sqltypes.Value) (match, stop bool, err error) {
+	pk := pf.td.pkValues(row)
+	for len(pf.pks) > 0 {
+		c := pf.td.comparePKValues(pf.pks[0], pk)
+		switch {
+		case c > 0:
+			return false, false, nil
+		case c == 0:
+			// The primary key is kept for the duplicate rows of the reference tables.
+			return true, false, nil
+		}
+		pf.pks = pf.pks[1:]
+	}
+	return false, true, nil
+}
+
+// pkBefore returns a primary key preceding the given one, after which the rows are
+// streamed to start with it, or nil if the rows must be streamed from the start.
+// Only the primary keys starting with an integral column are handled.
+func pkBefore(pk []sqltypes.Value) []sqltypes.Value {
+	if len(pk) == 0 {
+		return nil
+	}
+	var before sqltypes.Value
+	switch {
+	case pk[0].IsSigned():
+		v, err := pk[0].ToInt64()
+		if err != nil || v == math.MinInt64 {
+			return nil
+		}
+		before = sqltypes.MakeTrusted(pk[0].Type(), strconv.AppendInt(nil, v-1, 10))
+	case pk[0].IsUnsigned():
+		v, err := pk[0].ToUint64()
+		if err != nil || v == 0 {
+			return nil
+		}
+		before = sqltypes.MakeTrusted(pk[0].Type(), strconv.AppendUint(nil, v-1, 10))
+	default:
+		return nil
+	}
+	// The rows after the primary key start with the value of the first column.
+	return append([]sqltypes.Value{before}, pk[1:]...)
+}
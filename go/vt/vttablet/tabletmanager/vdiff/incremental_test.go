@@ -0,0 +1,126 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vdiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+)
+
+func TestValidateScheduleOptions(t *testing.T) {
+	require.NoError(t, validateScheduleOptions(nil))
+	require.NoError(t, validateScheduleOptions(&tabletmanagerdatapb.VDiffScheduleOptions{Recheck: true, IntervalSeconds: 3600, Incremental: true}))
+	assert.EqualError(t, validateScheduleOptions(&tabletmanagerdatapb.VDiffScheduleOptions{Incremental: true}), "incremental vdiffs need a schedule interval")
+	assert.EqualError(t, validateScheduleOptions(&tabletmanagerdatapb.VDiffScheduleOptions{IntervalSeconds: -1}), "invalid schedule interval of -1 seconds")
+}
+
+// newTestPKDiffer returns a table differ for rows of (id, name, val) with the primary key (id, name).
+func newTestPKDiffer() *tableDiffer {
+	return &tableDiffer{
+		tablePlan: &tablePlan{
+			table: &tabletmanagerdatapb.TableDefinition{
+				Name: "t1",
+				Fields: []*querypb.Field{
+					{Name: "id", Type: querypb.Type_INT64},
+					{Name: "name", Type: querypb.Type_VARCHAR},
+					{Name: "val", Type: querypb.Type_VARCHAR},
+				},
+			},
+			comparePKs: []compareColInfo{
+				{colIndex: 0, collation: collations.CollationBinaryID, isPK: true, colName: "id"},
+				{colIndex: 1, collation: collations.Default(), isPK: true, colName: "name"},
+			},
+			pkCols: []int{0, 1},
+		},
+	}
+}
+
+func testPK(id int64, name string) []sqltypes.Value {
+	return []sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NewVarChar(name)}
+}
+
+func TestPKFilter(t *testing.T) {
+	td := newTestPKDiffer()
+	td.pks = td.sortPKs([][]sqltypes.Value{testPK(5, "b"), testPK(2, "a"), testPK(5, "a"), testPK(2, "a")})
+	require.Equal(t, [][]sqltypes.Value{testPK(2, "a"), testPK(5, "a"), testPK(5, "b")}, td.pks)
+
+	filter := td.newPKFilter()
+	for _, tc := range []struct {
+		row         []sqltypes.Value
+		match, stop bool
+	}{
+		{row: append(testPK(1, "a"), sqltypes.NewVarChar("x"))},
+		{row: append(testPK(2, "a"), sqltypes.NewVarChar("x")), match: true},
+		// the duplicate rows of reference tables all match
+		{row: append(testPK(2, "a"), sqltypes.NewVarChar("x")), match: true},
+		{row: append(testPK(3, "a"), sqltypes.NewVarChar("x"))},
+		{row: append(testPK(5, "B"), sqltypes.NewVarChar("x")), match: true},
+		{row: append(testPK(6, "a"), sqltypes.NewVarChar("x")), stop: true},
+	} {
+		match, stop, err := filter(tc.row)
+		require.NoError(t, err)
+		assert.Equal(t, tc.match, match, "match of %v", tc.row)
+		assert.Equal(t, tc.stop, stop, "stop of %v", tc.row)
+	}
+}
+
+func TestPKBefore(t *testing.T) {
+	assert.Equal(t, testPK(4, "a"), pkBefore(testPK(5, "a")))
+	assert.Equal(t, []sqltypes.Value{sqltypes.NewUint64(9)}, pkBefore([]sqltypes.Value{sqltypes.NewUint64(10)}))
+	assert.Nil(t, pkBefore([]sqltypes.Value{sqltypes.NewUint64(0)}))
+	assert.Nil(t, pkBefore([]sqltypes.Value{sqltypes.NewVarChar("a")}))
+	assert.Nil(t, pkBefore(nil))
+}
+
+func TestMergeRecheckReport(t *testing.T) {
+	dr := &DiffReport{
+		TableName:       "t1",
+		ProcessedRows:   100,
+		MatchingRows:    95,
+		MismatchedRows:  3,
+		ExtraRowsSource: 1,
+		ExtraRowsTarget: 1,
+	}
+	rdr := &DiffReport{
+		TableName:      "t1",
+		ProcessedRows:  5,
+		MatchingRows:   4,
+		MismatchedRows: 1,
+		MismatchedRowsDiffs: []*DiffMismatch{{
+			Source: &RowDiff{Row: map[string]string{"id": "1"}},
+			Target: &RowDiff{Row: map[string]string{"id": "1"}},
+		}},
+	}
+	merged := mergeRecheckReport(dr, rdr)
+	assert.Equal(t, &DiffReport{
+		TableName:           "t1",
+		ProcessedRows:       100,
+		MatchingRows:        99,
+		MismatchedRows:      1,
+		MismatchedRowsDiffs: rdr.MismatchedRowsDiffs,
+	}, merged)
+	assert.True(t, merged.hasDifferences())
+	assert.False(t, mergeRecheckReport(dr, &DiffReport{ProcessedRows: 5, MatchingRows: 5}).hasDifferences())
+}
@@ -48,6 +48,11 @@ type primitiveExecutor struct {
 	resultch chan *sqltypes.Result
 	err      error
 
+	// filter, if set, selects the rows which are returned. Once it stops
+	// the iteration, the rows which are left in the stream are ignored.
+	filter  func(row []sqltypes.Value) (match, stop bool, err error)
+	stopped bool
+
 	name string // for debug purposes only
 }
 
@@ -75,9 +80,28 @@ func newPrimitiveExecutor(ctx context.Context, prim vtgateEngine.Primitive, name
 	return pe
 }
 
-// next gets the next row in the stream for this shard, if there's currently no rows to process in the stream then wait on the
-// result channel for the shard streamer to produce them.
+// next gets the next row in the stream for this shard which matches the filter, if any.
 func (pe *primitiveExecutor) next() ([]sqltypes.Value, error) {
+	for !pe.stopped {
+		row, err := pe.nextRow()
+		if row == nil || err != nil || pe.filter == nil {
+			return row, err
+		}
+		match, stop, err := pe.filter(row)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			return row, nil
+		}
+		pe.stopped = stop
+	}
+	return nil, nil
+}
+
+// nextRow gets the next row in the stream for this shard, if there's currently no rows to process in the stream then wait on the
+// result channel for the shard streamer to produce them.
+func (pe *primitiveExecutor) nextRow() ([]sqltypes.Value, error) {
 	for len(pe.rows) == 0 {
 		qr, ok := <-pe.resultch
 		if !ok {
@@ -151,3 +151,25 @@ func formatSampleRow(rd *RowDiff) {
 		rowString.WriteString(fmt.Sprintf("%s: %s\n", k, rd.Row[k]))
 	}
 }
+
+// hasDifferences returns true if any rows differ between the source and the target.
+func (dr *DiffReport) hasDifferences() bool {
+	return dr.MismatchedRows > 0 || dr.ExtraRowsSource > 0 || dr.ExtraRowsTarget > 0
+}
+
+// mergeRecheckReport returns the report of the diff with the rows which differed
+// replaced by the rows of the re-check, which only has the rows which still differ.
+func mergeRecheckReport(dr, rdr *DiffReport) *DiffReport {
+	differed := dr.MismatchedRows + dr.ExtraRowsSource + dr.ExtraRowsTarget
+	return &DiffReport{
+		TableName:            dr.TableName,
+		ProcessedRows:        dr.ProcessedRows - differed + rdr.ProcessedRows,
+		MatchingRows:         dr.MatchingRows + rdr.MatchingRows,
+		MismatchedRows:       rdr.MismatchedRows,
+		ExtraRowsSource:      rdr.ExtraRowsSource,
+		ExtraRowsTarget:      rdr.ExtraRowsTarget,
+		ExtraRowsSourceDiffs: rdr.ExtraRowsSourceDiffs,
+		ExtraRowsTargetDiffs: rdr.ExtraRowsTargetDiffs,
+		MismatchedRowsDiffs:  rdr.MismatchedRowsDiffs,
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vdiff
+
+import (
+	"fmt"
+
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+)
+
+// validateScheduleOptions returns an error if the options used to keep a workflow
+// continuously validated cannot be used together. The options are saved in the
+// columns of the vdiff record in _vt.vdiff.
+func validateScheduleOptions(so *tabletmanagerdatapb.VDiffScheduleOptions) error {
+	if so.GetIntervalSeconds() < 0 {
+		return fmt.Errorf("invalid schedule interval of %d seconds", so.GetIntervalSeconds())
+	}
+	if so.GetIncremental() && so.GetIntervalSeconds() == 0 {
+		return fmt.Errorf("incremental vdiffs need a schedule interval")
+	}
+	return nil
+}
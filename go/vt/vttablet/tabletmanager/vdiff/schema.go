@@ -18,12 +18,17 @@ package vdiff
 
 const (
 	sqlAnalyzeTable = "analyze table `%s`.`%s`"
-	sqlNewVDiff     = "insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid) values(%a, %a, %a, %a, %a, %a, %a)"
+	sqlNewVDiff     = "insert into _vt.vdiff(keyspace, workflow, state, options, shard, db_name, vdiff_uuid, recheck, schedule_interval_seconds, incremental) values(%a, %a, %a, %a, %a, %a, %a, %a, %a, %a)"
 	sqlResumeVDiff  = `update _vt.vdiff as vd, _vt.vdiff_table as vdt set vd.started_at = NULL, vd.completed_at = NULL, vd.state = 'pending',
 					vdt.state = 'pending' where vd.vdiff_uuid = %a and vd.id = vdt.vdiff_id and vd.state in ('completed', 'stopped')
 					and vdt.state in ('completed', 'stopped')`
 	sqlRetryVDiff = `update _vt.vdiff as vd left join _vt.vdiff_table as vdt on (vd.id = vdt.vdiff_id) set vd.state = 'pending',
 					vd.last_error = '', vdt.state = 'pending' where vd.id = %a and (vd.state = 'error' or vdt.state = 'error')`
+	// sqlRescheduleVDiff resets a completed vdiff for its next scheduled run. The last verified positions
+	// of the tables are kept for the incremental diffs.
+	sqlRescheduleVDiff = `update _vt.vdiff as vd left join _vt.vdiff_table as vdt on (vd.id = vdt.vdiff_id) set vd.state = 'pending',
+					vd.started_at = NULL, vd.completed_at = NULL, vd.last_error = '', vdt.state = 'pending', vdt.lastpk = NULL,
+					vdt.rows_compared = 0, vdt.mismatch = 0, vdt.report = NULL where vd.id = %a and vd.state = 'completed'`
 	sqlGetVDiffByKeyspaceWorkflowUUID = "select * from _vt.vdiff where keyspace = %a and workflow = %a and vdiff_uuid = %a"
 	sqlGetMostRecentVDiff             = "select * from _vt.vdiff where keyspace = %a and workflow = %a order by id desc limit 1"
 	sqlGetVDiffByID                   = "select * from _vt.vdiff where id = %a"
@@ -46,6 +51,7 @@ const (
 	sqlGetVReplicationEntry          = "select * from _vt.vreplication %s"
 	sqlGetVDiffsToRun                = "select * from _vt.vdiff where state in ('started','pending')" // what VDiffs have not been stopped or completed
 	sqlGetVDiffsToRetry              = "select * from _vt.vdiff where state = 'error' and json_unquote(json_extract(options, '$.core_options.auto_retry')) = 'true'"
+	sqlGetVDiffsToReschedule         = "select * from _vt.vdiff where state = 'completed' and schedule_interval_seconds > 0 and completed_at <= utc_timestamp() - interval schedule_interval_seconds second"
 	sqlGetVDiffID                    = "select id as id from _vt.vdiff where vdiff_uuid = %a"
 	sqlGetVDiffIDsByKeyspaceWorkflow = "select id as id from _vt.vdiff where keyspace = %a and workflow = %a"
 	sqlGetAllVDiffs                  = "select * from _vt.vdiff order by id desc"
@@ -62,6 +68,9 @@ const (
 	sqlUpdateTableState          = "update _vt.vdiff_table set state = %a where vdiff_id = %a and table_name = %a"
 	sqlUpdateTableStateAndReport = "update _vt.vdiff_table set state = %a, rows_compared = %a, report = %a where vdiff_id = %a and table_name = %a"
 	sqlUpdateTableMismatch       = "update _vt.vdiff_table set mismatch = true where vdiff_id = %a and table_name = %a"
+	sqlUpdateTableNoMismatch     = "update _vt.vdiff_table set mismatch = false where vdiff_id = %a and table_name = %a"
+	sqlGetTableVerifiedPos       = "select last_verified_pos as last_verified_pos from _vt.vdiff_table where vdiff_id = %a and table_name = %a"
+	sqlUpdateTableVerifiedPos    = "update _vt.vdiff_table set last_verified_pos = %a where vdiff_id = %a and table_name = %a"
 
 	sqlGetIncompleteTables = "select table_name as table_name from _vt.vdiff_table where vdiff_id = %a and state != 'completed'"
 )
@@ -79,6 +79,23 @@ type tableDiffer struct {
 	wgShardStreamers   sync.WaitGroup
 	shardStreamsCtx    context.Context
 	shardStreamsCancel context.CancelFunc
+
+	// pks, if set, restricts the diff to the rows with these primary keys, in the
+	// order of the rows. It is set for the incremental diffs and the re-checks.
+	pks [][]sqltypes.Value
+	// rechecking is true while the rows which differ are re-checked.
+	rechecking bool
+	// diffPKs are the primary keys of the rows which differ, collected when they are
+	// to be re-checked, and diffPKsAfter is the primary key preceding them, after which
+	// the re-check streams the rows. diffPKsIncomplete is set when some of them are not
+	// collected: too many rows differ, or the diff was resumed.
+	diffPKs           [][]sqltypes.Value
+	diffPKsAfter      []sqltypes.Value
+	diffPKsIncomplete bool
+	// verifiedPos are the positions of the sources, keyed by shard, up to which
+	// the rows are compared. They are saved for the next incremental diff once
+	// the table has no differences.
+	verifiedPos map[string]string
 }
 
 func newTableDiffer(wd *workflowDiffer, table *tabletmanagerdatapb.TableDefinition, sourceQuery string) *tableDiffer {
@@ -159,7 +176,13 @@ func (td *tableDiffer) stopTargetVReplicationStreams(ctx context.Context, dbClie
 	// streams are no longer running because vre.Exec would have replaced old controllers and new ones will not start
 
 	// update position of all source streams
-	query = fmt.Sprintf("select id, source, pos from _vt.vreplication %s", ct.workflowFilter)
+	return td.updateSourcePositions(dbClient)
+}
+
+// updateSourcePositions sets the positions of the sources to the positions of their streams.
+func (td *tableDiffer) updateSourcePositions(dbClient binlogplayer.DBClient) error {
+	ct := td.wd.ct
+	query := fmt.Sprintf("select id, source, pos from _vt.vreplication %s", ct.workflowFilter)
 	qr, err := dbClient.ExecuteFetch(query, -1)
 	if err != nil {
 		return err
@@ -469,50 +492,109 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 	}
 	defer dbClient.Close()
 
-	// We need to continue were we left off when appropriate. This can be an
-	// auto-retry on error, or a manual retry via the resume command.
-	// Otherwise the existing state will be empty and we start from scratch.
-	query, err := sqlparser.ParseAndBind(sqlGetVDiffTable,
-		sqltypes.Int64BindVariable(td.wd.ct.id),
-		sqltypes.StringBindVariable(td.table.Name),
-	)
-	if err != nil {
-		return nil, err
-	}
-	cs, err := dbClient.ExecuteFetch(query, -1)
-	if err != nil {
-		return nil, err
-	}
-	if len(cs.Rows) == 0 {
-		return nil, fmt.Errorf("no state found for vdiff table %s for vdiff_id %d on tablet %v",
-			td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
-	} else if len(cs.Rows) > 1 {
-		return nil, fmt.Errorf("invalid state found for vdiff table %s (multiple records) for vdiff_id %d on tablet %v",
-			td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
-	}
-	curState := cs.Named().Row()
-	mismatch := curState.AsBool("mismatch", false)
+	// A re-check starts from scratch, and its progress is not saved.
+	mismatch := td.rechecking
 	dr := &DiffReport{}
-	if rpt := curState.AsBytes("report", []byte("{}")); json.Valid(rpt) {
-		if err = json.Unmarshal(rpt, dr); err != nil {
+	if !td.rechecking {
+		// We need to continue were we left off when appropriate. This can be an
+		// auto-retry on error, or a manual retry via the resume command.
+		// Otherwise the existing state will be empty and we start from scratch.
+		query, err := sqlparser.ParseAndBind(sqlGetVDiffTable,
+			sqltypes.Int64BindVariable(td.wd.ct.id),
+			sqltypes.StringBindVariable(td.table.Name),
+		)
+		if err != nil {
 			return nil, err
 		}
+		cs, err := dbClient.ExecuteFetch(query, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(cs.Rows) == 0 {
+			return nil, fmt.Errorf("no state found for vdiff table %s for vdiff_id %d on tablet %v",
+				td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
+		} else if len(cs.Rows) > 1 {
+			return nil, fmt.Errorf("invalid state found for vdiff table %s (multiple records) for vdiff_id %d on tablet %v",
+				td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
+		}
+		curState := cs.Named().Row()
+		mismatch = curState.AsBool("mismatch", false)
+		if rpt := curState.AsBytes("report", []byte("{}")); json.Valid(rpt) {
+			if err = json.Unmarshal(rpt, dr); err != nil {
+				return nil, err
+			}
+		}
 	}
 	dr.TableName = td.table.Name
 
+	// The primary keys of the rows which differ are collected to re-check them, unless
+	// the diff is resumed with rows which differ, whose primary keys are not known.
+	collect := td.wd.ct.recheck && !td.rechecking
+	if collect {
+		td.diffPKs, td.diffPKsAfter = nil, nil
+		td.diffPKsIncomplete = dr.hasDifferences()
+	}
+
+	// The rows left in the streams are ignored when the diff returns early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	sourceExecutor := newPrimitiveExecutor(ctx, td.sourcePrimitive, "source")
 	targetExecutor := newPrimitiveExecutor(ctx, td.targetPrimitive, "target")
-	var sourceRow, lastProcessedRow, targetRow []sqltypes.Value
+	if td.pks != nil {
+		sourceExecutor.filter = td.newPKFilter()
+		targetExecutor.filter = td.newPKFilter()
+	}
+	var sourceRow, lastProcessedRow, targetRow, lastConsumedRow []sqltypes.Value
 	advanceSource := true
 	advanceTarget := true
 
+	recordDiff := func(row []sqltypes.Value) {
+		if !collect || td.diffPKsIncomplete {
+			return
+		}
+		if len(td.diffPKs) >= maxVDiffRecheckRows {
+			td.diffPKs, td.diffPKsIncomplete = nil, true
+			return
+		}
+		if len(td.diffPKs) == 0 {
+			switch {
+			case lastConsumedRow != nil:
+				td.diffPKsAfter = td.pkValues(lastConsumedRow)
+			case td.lastPK != nil && len(td.lastPK.Rows) == 1:
+				td.diffPKsAfter = sqltypes.MakeRowTrusted(td.lastPK.Fields, td.lastPK.Rows[0])
+			}
+		}
+		td.diffPKs = append(td.diffPKs, td.pkValues(row))
+	}
+	drain := func(executor *primitiveExecutor) (int64, error) {
+		if !collect {
+			return executor.drain(ctx)
+		}
+		var count int64
+		for {
+			row, err := executor.next()
+			if err != nil {
+				return 0, err
+			}
+			if row == nil {
+				return count, nil
+			}
+			recordDiff(row)
+			count++
+		}
+	}
+
 	// Save our progress when we finish the run
 	defer func() {
+		if td.rechecking {
+			return
+		}
 		if err := td.updateTableProgress(dbClient, dr, lastProcessedRow); err != nil {
 			log.Errorf("Failed to update vdiff progress on %s table: %v", td.table.Name, err)
 		}
 	}()
 
+	var err error
 	for {
 		lastProcessedRow = sourceRow
 
@@ -537,6 +619,9 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 			return dr, nil
 		}
 		if advanceSource {
+			if sourceRow != nil {
+				lastConsumedRow = sourceRow
+			}
 			sourceRow, err = sourceExecutor.next()
 			if err != nil {
 				log.Error(err)
@@ -544,6 +629,9 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 			}
 		}
 		if advanceTarget {
+			if targetRow != nil {
+				lastConsumedRow = targetRow
+			}
 			targetRow, err = targetExecutor.next()
 			if err != nil {
 				log.Error(err)
@@ -563,9 +651,10 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 				return nil, vterrors.Wrap(err, "unexpected error generating diff")
 			}
 			dr.ExtraRowsTargetDiffs = append(dr.ExtraRowsTargetDiffs, diffRow)
+			recordDiff(targetRow)
 
 			// drain target, update count
-			count, err := targetExecutor.drain(ctx)
+			count, err := drain(targetExecutor)
 			if err != nil {
 				return nil, err
 			}
@@ -581,7 +670,8 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 				return nil, vterrors.Wrap(err, "unexpected error generating diff")
 			}
 			dr.ExtraRowsSourceDiffs = append(dr.ExtraRowsSourceDiffs, diffRow)
-			count, err := sourceExecutor.drain(ctx)
+			recordDiff(sourceRow)
+			count, err := drain(sourceExecutor)
 			if err != nil {
 				return nil, err
 			}
@@ -605,6 +695,7 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 				}
 				dr.ExtraRowsSourceDiffs = append(dr.ExtraRowsSourceDiffs, diffRow)
 			}
+			recordDiff(sourceRow)
 			dr.ExtraRowsSource++
 			advanceTarget = false
 			continue
@@ -616,6 +707,7 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 				}
 				dr.ExtraRowsTargetDiffs = append(dr.ExtraRowsTargetDiffs, diffRow)
 			}
+			recordDiff(targetRow)
 			dr.ExtraRowsTarget++
 			advanceSource = false
 			continue
@@ -640,6 +732,7 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 				}
 				dr.MismatchedRowsDiffs = append(dr.MismatchedRowsDiffs, &DiffMismatch{Source: sourceDiffRow, Target: targetDiffRow})
 			}
+			recordDiff(sourceRow)
 			dr.MismatchedRows++
 		default:
 			dr.MatchingRows++
@@ -648,7 +741,7 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 		// Update progress every 10,000 rows as we go along. This will allow us to provide
 		// approximate progress information but without too much overhead for when it's not
 		// needed or even desired.
-		if dr.ProcessedRows%1e4 == 0 {
+		if dr.ProcessedRows%1e4 == 0 && !td.rechecking {
 			if err := td.updateTableProgress(dbClient, dr, sourceRow); err != nil {
 				return nil, err
 			}
@@ -787,19 +880,64 @@ func updateTableMismatch(dbClient binlogplayer.DBClient, vdiffID int64, table st
 	return nil
 }
 
+func updateTableNoMismatch(dbClient binlogplayer.DBClient, vdiffID int64, table string) error {
+	query, err := sqlparser.ParseAndBind(sqlUpdateTableNoMismatch,
+		sqltypes.Int64BindVariable(vdiffID),
+		sqltypes.StringBindVariable(table),
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := dbClient.ExecuteFetch(query, 1); err != nil {
+		return err
+	}
+	return nil
+}
+
+// updateVerifiedPos saves the positions of the sources up to which the table has no differences.
+func (td *tableDiffer) updateVerifiedPos(dbClient binlogplayer.DBClient) error {
+	pos, err := json.Marshal(td.verifiedPos)
+	if err != nil {
+		return err
+	}
+	query, err := sqlparser.ParseAndBind(sqlUpdateTableVerifiedPos,
+		sqltypes.StringBindVariable(string(pos)),
+		sqltypes.Int64BindVariable(td.wd.ct.id),
+		sqltypes.StringBindVariable(td.table.Name),
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := dbClient.ExecuteFetch(query, 1); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (td *tableDiffer) lastPKFromRow(row []sqltypes.Value) ([]byte, error) {
-	pkColCnt := len(td.tablePlan.pkCols)
-	pkFields := make([]*querypb.Field, pkColCnt)
-	pkVals := make([]sqltypes.Value, pkColCnt)
+	buf, err := prototext.Marshal(td.lastPKResult(td.pkValues(row)))
+	return buf, err
+}
+
+// pkValues returns the values of the primary key of the row.
+func (td *tableDiffer) pkValues(row []sqltypes.Value) []sqltypes.Value {
+	pkVals := make([]sqltypes.Value, len(td.tablePlan.pkCols))
 	for i, colIndex := range td.tablePlan.pkCols {
-		pkFields[i] = td.tablePlan.table.Fields[colIndex]
 		pkVals[i] = row[colIndex]
 	}
-	buf, err := prototext.Marshal(&querypb.QueryResult{
+	return pkVals
+}
+
+// lastPKResult returns the primary key as the lastpk after which rows are streamed.
+func (td *tableDiffer) lastPKResult(pkVals []sqltypes.Value) *querypb.QueryResult {
+	pkFields := make([]*querypb.Field, len(td.tablePlan.pkCols))
+	for i, colIndex := range td.tablePlan.pkCols {
+		pkFields[i] = td.tablePlan.table.Fields[colIndex]
+	}
+	return &querypb.QueryResult{
 		Fields: pkFields,
 		Rows:   []*querypb.Row{sqltypes.RowToProto3(pkVals)},
-	})
-	return buf, err
+	}
 }
 
 // If SourceTimeZone is defined in the BinlogSource (_vt.vreplication.source), the
@@ -40,6 +40,8 @@ type tablePlan struct {
 	// sourceQuery and targetQuery are select queries.
 	sourceQuery string
 	targetQuery string
+	// sourceTable is the table selected by the sourceQuery.
+	sourceTable string
 
 	// compareCols is the list of non-pk columns to compare.
 	// If the value is -1, it's a pk column and should not be
@@ -142,6 +144,11 @@ func (td *tableDiffer) buildTablePlan(dbClient binlogplayer.DBClient, dbName str
 	}
 
 	sourceSelect.From = sel.From
+	if len(sel.From) == 1 {
+		if tableExpr, ok := sel.From[0].(*sqlparser.AliasedTableExpr); ok {
+			tp.sourceTable = sqlparser.GetTableName(tableExpr.Expr).String()
+		}
+	}
 	// The target table name should the one that matched the rule.
 	// It can be different from the source table.
 	targetSelect.From = sqlparser.TableExprs{
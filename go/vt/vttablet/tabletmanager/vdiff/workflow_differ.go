@@ -19,6 +19,7 @@ package vdiff
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
 
@@ -154,16 +155,48 @@ func (wd *workflowDiffer) diffTable(ctx context.Context, dbClient binlogplayer.D
 	if err := td.updateTableState(ctx, dbClient, StartedState); err != nil {
 		return err
 	}
-	if err := td.initialize(ctx); err != nil {
-		return err
+	// The positions of the sources are only saved for the next incremental diff
+	// when the whole table was compared in this run.
+	resumed := td.lastPK != nil
+	if wd.ct.incremental {
+		if err := td.prepareIncrementalDiff(ctx, dbClient); err != nil {
+			return err
+		}
 	}
-	log.Infof("Table initialization done on table %s for vdiff %s", td.table.Name, wd.ct.uuid)
-	dr, err := td.diff(ctx, wd.opts.CoreOptions.MaxRows, wd.opts.ReportOptions.DebugQuery, wd.opts.ReportOptions.OnlyPks, wd.opts.CoreOptions.MaxExtraRowsToCompare)
-	if err != nil {
-		log.Errorf("Encountered an error diffing table %s for vdiff %s: %v", td.table.Name, wd.ct.uuid, err)
-		return err
+	dr := &DiffReport{TableName: td.table.Name}
+	if td.pks == nil || len(td.pks) > 0 {
+		if err := td.initialize(ctx); err != nil {
+			return err
+		}
+		log.Infof("Table initialization done on table %s for vdiff %s", td.table.Name, wd.ct.uuid)
+		var err error
+		dr, err = td.diff(ctx, wd.opts.CoreOptions.MaxRows, wd.opts.ReportOptions.DebugQuery, wd.opts.ReportOptions.OnlyPks, wd.opts.CoreOptions.MaxExtraRowsToCompare)
+		if err != nil {
+			log.Errorf("Encountered an error diffing table %s for vdiff %s: %v", td.table.Name, wd.ct.uuid, err)
+			return err
+		}
+		log.Infof("Table diff done on table %s for vdiff %s with report: %+v", td.table.Name, wd.ct.uuid, dr)
+	}
+	if wd.ct.incremental && td.pks == nil {
+		td.verifiedPos = nil
+		if !resumed {
+			td.verifiedPos = make(map[string]string, len(wd.ct.sources))
+			for shard, source := range wd.ct.sources {
+				td.verifiedPos[shard] = source.snapshotPosition
+			}
+		}
+	}
+	rechecked := false
+	if wd.ct.recheck && dr.hasDifferences() {
+		rdr, err := wd.recheckTable(ctx, dbClient, td)
+		if err != nil {
+			return err
+		}
+		if rdr != nil {
+			dr, rechecked = mergeRecheckReport(dr, rdr), true
+			log.Infof("Table re-check done on table %s for vdiff %s with report: %+v", td.table.Name, wd.ct.uuid, dr)
+		}
 	}
-	log.Infof("Table diff done on table %s for vdiff %s with report: %+v", td.table.Name, wd.ct.uuid, dr)
 	if dr.ExtraRowsSource > 0 || dr.ExtraRowsTarget > 0 {
 		if err := wd.reconcileExtraRows(dr, wd.opts.CoreOptions.MaxExtraRowsToCompare); err != nil {
 			log.Errorf("Encountered an error reconciling extra rows found for table %s for vdiff %s: %v", td.table.Name, wd.ct.uuid, err)
@@ -171,10 +204,22 @@ func (wd *workflowDiffer) diffTable(ctx context.Context, dbClient binlogplayer.D
 		}
 	}
 
-	if dr.MismatchedRows > 0 || dr.ExtraRowsTarget > 0 || dr.ExtraRowsSource > 0 {
+	if dr.hasDifferences() {
 		if err := updateTableMismatch(dbClient, wd.ct.id, td.table.Name); err != nil {
 			return err
 		}
+	} else {
+		if rechecked {
+			// The rows which differed in the first pass were marked as a mismatch.
+			if err := updateTableNoMismatch(dbClient, wd.ct.id, td.table.Name); err != nil {
+				return err
+			}
+		}
+		if wd.ct.incremental && td.verifiedPos != nil {
+			if err := td.updateVerifiedPos(dbClient); err != nil {
+				return err
+			}
+		}
 	}
 
 	log.Infof("Completed reconciliation on table %s for vdiff %s with updated report: %+v", td.table.Name, wd.ct.uuid, dr)
@@ -184,6 +229,36 @@ func (wd *workflowDiffer) diffTable(ctx context.Context, dbClient binlogplayer.D
 	return nil
 }
 
+// recheckTable compares again the rows of the table which differ, once the target has caught up
+// with the source, and returns the report of the rows which were re-checked. It returns a nil
+// report when the rows cannot be re-checked.
+func (wd *workflowDiffer) recheckTable(ctx context.Context, dbClient binlogplayer.DBClient, td *tableDiffer) (*DiffReport, error) {
+	if td.diffPKsIncomplete || len(td.diffPKs) == 0 {
+		insertVDiffLog(ctx, dbClient, wd.ct.id, fmt.Sprintf("Not re-checking table %s: too many rows differ, or the diff was resumed", td.table.Name))
+		return nil, nil
+	}
+	// The streams of the first pass are no longer needed.
+	if td.shardStreamsCancel != nil {
+		td.shardStreamsCancel()
+	}
+	td.wgShardStreamers.Wait()
+
+	pks, lastPK := td.pks, td.lastPK
+	defer func() {
+		td.pks, td.lastPK, td.rechecking = pks, lastPK, false
+	}()
+	td.pks, td.lastPK, td.rechecking = td.diffPKs, nil, true
+	if td.diffPKsAfter != nil {
+		td.lastPK = td.lastPKResult(td.diffPKsAfter)
+	}
+	log.Infof("Re-checking %d rows of table %s for vdiff %s", len(td.diffPKs), td.table.Name, wd.ct.uuid)
+	insertVDiffLog(ctx, dbClient, wd.ct.id, fmt.Sprintf("Re-checking %d rows of table %s which differ", len(td.diffPKs), td.table.Name))
+	if err := td.initialize(ctx); err != nil {
+		return nil, err
+	}
+	return td.diff(ctx, math.MaxInt64, wd.opts.ReportOptions.DebugQuery, wd.opts.ReportOptions.OnlyPks, wd.opts.CoreOptions.MaxExtraRowsToCompare)
+}
+
 func (wd *workflowDiffer) diff(ctx context.Context) error {
 	dbClient := wd.ct.dbClientFactory()
 	if err := dbClient.Connect(); err != nil {
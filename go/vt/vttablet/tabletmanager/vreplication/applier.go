@@ -0,0 +1,365 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// A workflow with a sink (see the sink of its binlog source) applies its rows to the sink,
+// with the applier registered for the scheme of its URL, instead of the target database. The stream goes through the same phases:
+// the rows are copied, the changes of the tables being copied are caught up, and the changes are
+// replicated. The target database still has the tables of the workflow, which define the columns
+// of the rows, and keeps its state: the position and the copy state are saved once the changes
+// are committed by the applier, so the changes are applied at least once.
+
+// Applier applies the row changes of a workflow to a sink.
+type Applier interface {
+	// Apply applies the changes to the sink. They are only
+	// guaranteed to be applied once Commit returns.
+	Apply(ctx context.Context, changes []*RowChange) error
+	// Commit commits the changes applied since the last commit.
+	Commit(ctx context.Context) error
+	// Close releases the resources of the applier. The changes
+	// which are not committed are discarded.
+	Close() error
+}
+
+// RowChange is the change of a row of a target table. Before is not set for an
+// insert, and After is not set for a delete. The values are in the order of the
+// fields, which are the columns of the table with the types of their values.
+type RowChange struct {
+	Table  string
+	Fields []*querypb.Field
+	Before []sqltypes.Value
+	After  []sqltypes.Value
+}
+
+// ApplierFactory creates the applier of a workflow for its sink URL.
+type ApplierFactory func(sink *url.URL) (Applier, error)
+
+var (
+	appliersMu sync.Mutex
+	appliers   = make(map[string]ApplierFactory)
+)
+
+// RegisterApplier registers the factory of the appliers for the sinks of the URL scheme.
+func RegisterApplier(scheme string, factory ApplierFactory) {
+	appliersMu.Lock()
+	defer appliersMu.Unlock()
+	if _, ok := appliers[scheme]; ok {
+		panic(fmt.Sprintf("applier for %s is already registered", scheme))
+	}
+	appliers[scheme] = factory
+}
+
+// newApplier creates the applier of a sink, or returns nil if there is no sink.
+func newApplier(sink string) (Applier, error) {
+	if sink == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink %s: %v", sink, err)
+	}
+	appliersMu.Lock()
+	factory, ok := appliers[u.Scheme]
+	appliersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no applier registered for the sink %s", sink)
+	}
+	return factory(u)
+}
+
+// applierSink converts the rows of the stream with the table plans into the
+// row changes of the target tables, which are applied by the applier.
+type applierSink struct {
+	applier Applier
+	// plans are the conversions of the table plans, keyed by the
+	// target table. They are built again when its plan changes.
+	plans map[string]*applierPlan
+}
+
+// applierPlan computes the values of the columns of a target table from the rows of its table plan.
+type applierPlan struct {
+	tablePlan *TablePlan
+	fields    []*querypb.Field
+	// values are the functions computing the values of the columns.
+	values []func(env *evalengine.ExpressionEnv) (sqltypes.Value, error)
+	// lastpk are the indices of the columns of Lastpk in the rows.
+	lastpk []int
+}
+
+func newApplierSink(applier Applier) *applierSink {
+	return &applierSink{
+		applier: applier,
+		plans:   make(map[string]*applierPlan),
+	}
+}
+
+// plan returns the conversion of the table plan.
+func (sink *applierSink) plan(tp *TablePlan) (*applierPlan, error) {
+	if plan, ok := sink.plans[tp.TargetName]; ok && plan.tablePlan == tp {
+		return plan, nil
+	}
+	plan, err := buildApplierPlan(tp)
+	if err != nil {
+		return nil, err
+	}
+	sink.plans[tp.TargetName] = plan
+	return plan, nil
+}
+
+func buildApplierPlan(tp *TablePlan) (*applierPlan, error) {
+	tpb := tp.TablePlanBuilder
+	if tpb == nil {
+		return nil, fmt.Errorf("unexpected: table %s has no plan builder", tp.TargetName)
+	}
+	fieldIndex := func(name string) (int, error) {
+		for i, field := range tp.Fields {
+			if strings.EqualFold(field.Name, name) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("column %s of table %s is not sent by the source", name, tp.TargetName)
+	}
+	fieldType := func(i int, column string) *querypb.Field {
+		field := tp.Fields[i].CloneVT()
+		field.Name = column
+		field.Table = tp.TargetName
+		_, convertCharset := tp.ConvertCharset[field.Name]
+		_, enumValues := tp.EnumValuesMap[field.Name]
+		if convertCharset || enumValues || tp.ConvertIntToEnum[field.Name] {
+			// The values are converted to strings, see bindFieldVal.
+			field.Type = querypb.Type_VARCHAR
+		}
+		return field
+	}
+	fieldValue := func(i int) func(env *evalengine.ExpressionEnv) (sqltypes.Value, error) {
+		field := tp.Fields[i]
+		return func(env *evalengine.ExpressionEnv) (sqltypes.Value, error) {
+			if field.Type == querypb.Type_JSON {
+				return env.Row[i], nil
+			}
+			bindVar, err := tp.bindFieldVal(field, &env.Row[i])
+			if err != nil {
+				return sqltypes.Value{}, err
+			}
+			return sqltypes.BindVariableToValue(bindVar)
+		}
+	}
+
+	plan := &applierPlan{tablePlan: tp}
+	for _, cexpr := range tpb.colExprs {
+		if tpb.isColumnGenerated(cexpr.colName) {
+			continue
+		}
+		column := cexpr.colName.String()
+		if cexpr.operation != opExpr {
+			return nil, fmt.Errorf("aggregate column %s of table %s cannot be applied by an applier", column, tp.TargetName)
+		}
+		var (
+			field *querypb.Field
+			value func(env *evalengine.ExpressionEnv) (sqltypes.Value, error)
+		)
+		switch expr := cexpr.expr.(type) {
+		case *sqlparser.ColName:
+			name := expr.Name.String()
+			if evalExpr, ok := tp.EvalColumns[name]; ok {
+				field = evalField(tp, column, evalExpr)
+				value = func(env *evalengine.ExpressionEnv) (sqltypes.Value, error) {
+					result, err := env.Evaluate(evalExpr)
+					if err != nil {
+						return sqltypes.Value{}, vterrors.Wrapf(err, "failed to evaluate %s of %s", column, tp.TargetName)
+					}
+					return result.Value(collations.Default()), nil
+				}
+				break
+			}
+			i, err := fieldIndex(name)
+			if err != nil {
+				return nil, err
+			}
+			field, value = fieldType(i, column), fieldValue(i)
+		case *sqlparser.ConvertUsingExpr:
+			// The source sends the converted value as the column.
+			i, err := fieldIndex(column)
+			if err != nil {
+				return nil, err
+			}
+			field, value = fieldType(i, column), fieldValue(i)
+		default:
+			return nil, fmt.Errorf("expression %s of table %s cannot be applied by an applier", sqlparser.String(cexpr.expr), tp.TargetName)
+		}
+		plan.fields = append(plan.fields, field)
+		plan.values = append(plan.values, value)
+	}
+	if tp.Lastpk != nil {
+		for _, field := range tp.Lastpk.Fields {
+			i, err := fieldIndex(field.Name)
+			if err != nil {
+				return nil, err
+			}
+			plan.lastpk = append(plan.lastpk, i)
+		}
+	}
+	return plan, nil
+}
+
+// evalField returns the field of a column evaluated by the target. Its type
+// is the type of the expression, or VARBINARY if it cannot be determined.
+func evalField(tp *TablePlan, column string, expr evalengine.Expr) *querypb.Field {
+	field := &querypb.Field{Name: column, Table: tp.TargetName, Type: querypb.Type_VARBINARY, Charset: uint32(collations.CollationBinaryID)}
+	env := evalengine.EmptyExpressionEnv()
+	env.Fields = tp.Fields
+	typ, err := env.TypeOf(expr)
+	if err != nil || typ.Type == sqltypes.Unknown {
+		return field
+	}
+	field.Type = typ.Type
+	field.Charset = uint32(typ.Coll)
+	return field
+}
+
+// isCopied returns true if the primary key of the row is within the rows already copied,
+// if the table is being copied. The changes of the other rows are streamed by the copy.
+func (plan *applierPlan) isCopied(vals []sqltypes.Value) (bool, error) {
+	lastpk := plan.tablePlan.Lastpk
+	if lastpk == nil || len(lastpk.Rows) != 1 {
+		return true, nil
+	}
+	for i, index := range plan.lastpk {
+		cmp, err := evalengine.NullsafeCompare(vals[index], lastpk.Rows[0][i], collations.ID(lastpk.Fields[i].Charset))
+		if err != nil {
+			return false, err
+		}
+		if cmp != 0 {
+			return cmp < 0, nil
+		}
+	}
+	return true, nil
+}
+
+// image returns the values of the columns for the image of a row,
+// or nil if the row does not match the filter of the plan.
+func (plan *applierPlan) image(row *querypb.Row, checkCopied bool) ([]sqltypes.Value, error) {
+	if row == nil {
+		return nil, nil
+	}
+	vals := sqltypes.MakeRowTrusted(plan.tablePlan.Fields, row)
+	match, err := plan.tablePlan.matchesFilter(vals)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, nil
+	}
+	if checkCopied {
+		copied, err := plan.isCopied(vals)
+		if err != nil {
+			return nil, err
+		}
+		if !copied {
+			return nil, nil
+		}
+	}
+	env := evalengine.EmptyExpressionEnv()
+	env.Row = vals
+	image := make([]sqltypes.Value, len(plan.values))
+	for i, value := range plan.values {
+		if image[i], err = value(env); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+// applyRowEvent applies the changes of a row event, like TablePlan.applyChange.
+func (sink *applierSink) applyRowEvent(ctx context.Context, tp *TablePlan, rowEvent *binlogdatapb.RowEvent) error {
+	plan, err := sink.plan(tp)
+	if err != nil {
+		return err
+	}
+	changes := make([]*RowChange, 0, len(rowEvent.RowChanges))
+	for _, rowChange := range rowEvent.RowChanges {
+		if tp.isPartial(rowChange) {
+			return fmt.Errorf("partial row images of table %s cannot be applied by an applier", tp.TargetName)
+		}
+		change := &RowChange{Table: tp.TargetName, Fields: plan.fields}
+		if change.Before, err = plan.image(rowChange.Before, true); err != nil {
+			return err
+		}
+		if change.After, err = plan.image(rowChange.After, true); err != nil {
+			return err
+		}
+		if change.Before == nil && change.After == nil {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return sink.applier.Apply(ctx, changes)
+}
+
+// applyRows applies the rows copied from the source as inserts, like TablePlan.applyBulkInsert.
+func (sink *applierSink) applyRows(ctx context.Context, tp *TablePlan, rows []*querypb.Row) error {
+	plan, err := sink.plan(tp)
+	if err != nil {
+		return err
+	}
+	changes := make([]*RowChange, 0, len(rows))
+	for _, row := range rows {
+		after, err := plan.image(row, false)
+		if err != nil {
+			return err
+		}
+		if after == nil {
+			continue
+		}
+		changes = append(changes, &RowChange{Table: tp.TargetName, Fields: plan.fields, After: after})
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return sink.applier.Apply(ctx, changes)
+}
+
+func (sink *applierSink) commit(ctx context.Context) error {
+	if err := sink.applier.Commit(ctx); err != nil {
+		return vterrors.Wrapf(err, "failed to commit the changes of the applier")
+	}
+	return nil
+}
+
+func (sink *applierSink) close() error {
+	return sink.applier.Close()
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"vitess.io/vitess/go/sqltypes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func init() {
+	RegisterApplier("parquet", newParquetApplier)
+}
+
+// parquetApplier writes the changes of each table committed together to a
+// Parquet file of the directory of the table, named after the time of the commit.
+type parquetApplier struct {
+	dir     string
+	pending map[string][]*parquetBatch
+	// last is the name of the last file, which is a number.
+	last int64
+}
+
+// parquetBatch is the changes of a table with the same fields, which are written to the same file.
+type parquetBatch struct {
+	fields  []*querypb.Field
+	changes []*RowChange
+}
+
+func newParquetApplier(sink *url.URL) (Applier, error) {
+	if sink.Path == "" {
+		return nil, fmt.Errorf("no directory in the sink %s", sink)
+	}
+	if err := os.MkdirAll(sink.Path, 0755); err != nil {
+		return nil, err
+	}
+	return &parquetApplier{
+		dir:     sink.Path,
+		pending: make(map[string][]*parquetBatch),
+	}, nil
+}
+
+// Apply is part of the Applier interface.
+func (pa *parquetApplier) Apply(_ context.Context, changes []*RowChange) error {
+	for _, change := range changes {
+		batches := pa.pending[change.Table]
+		if len(batches) == 0 || !sameFields(batches[len(batches)-1].fields, change.Fields) {
+			batches = append(batches, &parquetBatch{fields: change.Fields})
+			pa.pending[change.Table] = batches
+		}
+		batch := batches[len(batches)-1]
+		batch.changes = append(batch.changes, change)
+	}
+	return nil
+}
+
+func sameFields(a, b []*querypb.Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+	return true
+}
+
+// Commit is part of the Applier interface.
+func (pa *parquetApplier) Commit(_ context.Context) error {
+	tables := make([]string, 0, len(pa.pending))
+	for table := range pa.pending {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		dir := filepath.Join(pa.dir, table)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for len(pa.pending[table]) > 0 {
+			batch := pa.pending[table][0]
+			data, err := encodeParquet(batch.fields, batch.changes)
+			if err != nil {
+				return err
+			}
+			if err := pa.writeFile(dir, data); err != nil {
+				return err
+			}
+			pa.pending[table] = pa.pending[table][1:]
+		}
+		delete(pa.pending, table)
+	}
+	return nil
+}
+
+// writeFile writes the file atomically, so that the directory only has complete files.
+func (pa *parquetApplier) writeFile(dir string, data []byte) error {
+	name := time.Now().UnixNano()
+	if name <= pa.last {
+		name = pa.last + 1
+	}
+	pa.last = name
+	tmp, err := os.CreateTemp(dir, ".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, fmt.Sprintf("%d.parquet", name)))
+}
+
+// Close is part of the Applier interface.
+func (pa *parquetApplier) Close() error {
+	pa.pending = make(map[string][]*parquetBatch)
+	return nil
+}
+
+// parquetNode returns the node of the column of a field, which is optional
+// because the column is in an optional image.
+func parquetNode(typ querypb.Type) parquet.Node {
+	switch {
+	case sqltypes.IsBinary(typ) || typ == querypb.Type_BIT || typ == querypb.Type_GEOMETRY:
+		return parquet.Optional(parquet.Leaf(parquet.ByteArrayType))
+	case typ == querypb.Type_JSON:
+		return parquet.Optional(parquet.JSON())
+	case sqltypes.IsSigned(typ):
+		return parquet.Optional(parquet.Int(64))
+	case sqltypes.IsUnsigned(typ):
+		return parquet.Optional(parquet.Uint(64))
+	case sqltypes.IsFloat(typ):
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	default:
+		return parquet.Optional(parquet.String())
+	}
+}
+
+// parquetValue converts a value to the type of its column.
+func parquetValue(typ querypb.Type, val sqltypes.Value) (parquet.Value, error) {
+	switch {
+	case sqltypes.IsBinary(typ) || typ == querypb.Type_BIT || typ == querypb.Type_GEOMETRY || typ == querypb.Type_JSON:
+		return parquet.ByteArrayValue(val.Raw()), nil
+	case sqltypes.IsSigned(typ):
+		v, err := val.ToInt64()
+		return parquet.Int64Value(v), err
+	case sqltypes.IsUnsigned(typ):
+		v, err := val.ToUint64()
+		return parquet.Int64Value(int64(v)), err
+	case sqltypes.IsFloat(typ):
+		v, err := val.ToFloat64()
+		return parquet.DoubleValue(v), err
+	default:
+		return parquet.ByteArrayValue(val.Raw()), nil
+	}
+}
+
+// encodeParquet encodes the changes of a table as a Parquet file, whose rows
+// are the operations of the changes with the optional groups of their before
+// and after images.
+func encodeParquet(fields []*querypb.Field, changes []*RowChange) ([]byte, error) {
+	image := make(parquet.Group, len(fields))
+	for _, field := range fields {
+		image[field.Name] = parquetNode(field.Type)
+	}
+	schema := parquet.NewSchema(changes[0].Table, parquet.Group{
+		"op":     parquet.String(),
+		"before": parquet.Optional(image),
+		"after":  parquet.Optional(image),
+	})
+	// The columns of a group are sorted by name, so the columns of the
+	// fields are looked up by their paths.
+	columns := make(map[string]int)
+	for i, path := range schema.Columns() {
+		columns[strings.Join(path, ".")] = i
+	}
+	images := []struct {
+		name string
+		vals func(change *RowChange) []sqltypes.Value
+	}{
+		{name: "before", vals: func(change *RowChange) []sqltypes.Value { return change.Before }},
+		{name: "after", vals: func(change *RowChange) []sqltypes.Value { return change.After }},
+	}
+
+	rows := make([]parquet.Row, 0, len(changes))
+	for _, change := range changes {
+		row := make(parquet.Row, len(columns))
+		op := columns["op"]
+		row[op] = parquet.ByteArrayValue([]byte(change.Op())).Level(0, 0, op)
+		for _, image := range images {
+			vals := image.vals(change)
+			if vals != nil && len(vals) != len(fields) {
+				return nil, fmt.Errorf("%s image of %s has %d values for %d columns", image.name, change.Table, len(vals), len(fields))
+			}
+			for i, field := range fields {
+				column := columns[image.name+"."+field.Name]
+				switch {
+				case vals == nil:
+					row[column] = parquet.NullValue().Level(0, 0, column)
+				case vals[i].IsNull():
+					row[column] = parquet.NullValue().Level(0, 1, column)
+				default:
+					v, err := parquetValue(field.Type, vals[i])
+					if err != nil {
+						return nil, fmt.Errorf("invalid value of %s of %s: %v", field.Name, change.Table, err)
+					}
+					row[column] = v.Level(0, 2, column)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(rows); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+// parquetChange is a row of the Parquet files of the test table.
+type parquetChange struct {
+	Op     string        `parquet:"op"`
+	Before *parquetImage `parquet:"before"`
+	After  *parquetImage `parquet:"after"`
+}
+
+type parquetImage struct {
+	ID    *int64   `parquet:"id"`
+	Name  *string  `parquet:"name"`
+	Price *string  `parquet:"price"`
+	Score *float64 `parquet:"score"`
+}
+
+// readParquet reads a Parquet file and its rows with the reader of the library.
+func readParquet[T any](t *testing.T, name string) (*parquet.File, []T) {
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	stat, err := f.Stat()
+	require.NoError(t, err)
+	file, err := parquet.OpenFile(f, stat.Size())
+	require.NoError(t, err)
+	rows, err := parquet.Read[T](f, stat.Size())
+	require.NoError(t, err)
+	return file, rows
+}
+
+func TestParquetApplier(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	applier, err := newApplier("parquet://" + dir)
+	require.NoError(t, err)
+	defer applier.Close()
+
+	fields := sqltypes.MakeTestFields("id|name|price|score", "int64|varchar|decimal|float64")
+	row := func(id int64, name string) []sqltypes.Value {
+		return []sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NewVarChar(name), sqltypes.NULL, sqltypes.NewFloat64(1.5)}
+	}
+	require.NoError(t, applier.Apply(ctx, []*RowChange{
+		{Table: "t1", Fields: fields, After: row(1, "ann")},
+		{Table: "t1", Fields: fields, Before: row(1, "ann"), After: row(1, "bob")},
+		{Table: "t1", Fields: fields, Before: row(1, "bob")},
+	}))
+	files, err := filepath.Glob(filepath.Join(dir, "t1", "*"))
+	require.NoError(t, err)
+	assert.Empty(t, files, "the changes are written when committed")
+	require.NoError(t, applier.Commit(ctx))
+	// a commit without changes writes no file
+	require.NoError(t, applier.Commit(ctx))
+
+	files, err = filepath.Glob(filepath.Join(dir, "t1", "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0], ".parquet"), files[0])
+	file, changes := readParquet[parquetChange](t, files[0])
+	assert.EqualValues(t, 3, file.NumRows())
+	assert.Equal(t, [][]string{
+		{"after", "id"}, {"after", "name"}, {"after", "price"}, {"after", "score"},
+		{"before", "id"}, {"before", "name"}, {"before", "price"}, {"before", "score"},
+		{"op"},
+	}, file.Schema().Columns())
+	price, ok := file.Schema().Lookup("after", "price")
+	require.True(t, ok)
+	assert.True(t, price.Node.Optional())
+	assert.Equal(t, parquet.String().Type(), price.Node.Type())
+
+	id, ann, bob, score := int64(1), "ann", "bob", 1.5
+	assert.Equal(t, []parquetChange{
+		{Op: "insert", After: &parquetImage{ID: &id, Name: &ann, Score: &score}},
+		{Op: "update", Before: &parquetImage{ID: &id, Name: &ann, Score: &score}, After: &parquetImage{ID: &id, Name: &bob, Score: &score}},
+		{Op: "delete", Before: &parquetImage{ID: &id, Name: &bob, Score: &score}},
+	}, changes)
+
+	// the changes with other fields are written to another file
+	other := sqltypes.MakeTestFields("id", "uint64")
+	require.NoError(t, applier.Apply(ctx, []*RowChange{
+		{Table: "t1", Fields: fields, After: row(2, "amy")},
+		{Table: "t1", Fields: other, After: []sqltypes.Value{sqltypes.NewUint64(3)}},
+	}))
+	require.NoError(t, applier.Commit(ctx))
+	files, err = filepath.Glob(filepath.Join(dir, "t1", "*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	type uintChange struct {
+		Op    string `parquet:"op"`
+		After *struct {
+			ID *uint64 `parquet:"id"`
+		} `parquet:"after"`
+	}
+	_, uintChanges := readParquet[uintChange](t, files[2])
+	require.Len(t, uintChanges, 1)
+	assert.Equal(t, "insert", uintChanges[0].Op)
+	assert.EqualValues(t, 3, *uintChanges[0].After.ID)
+
+	require.NoError(t, applier.Apply(ctx, []*RowChange{{Table: "t1", Fields: other, After: []sqltypes.Value{sqltypes.NewVarChar("x")}}}))
+	assert.ErrorContains(t, applier.Commit(ctx), "invalid value of id of t1")
+}
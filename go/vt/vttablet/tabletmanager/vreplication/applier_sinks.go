@@ -0,0 +1,273 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"vitess.io/vitess/go/sqltypes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// The built-in appliers are:
+//   - file:///path/to/dir appends the changes of each table to the
+//     newline-delimited JSON file <table>.ndjson of the directory.
+//   - parquet:///path/to/dir writes the changes of each table committed
+//     together to a Parquet file of the directory <table> (applier_parquet.go).
+//   - http://host/path and https://host/path post the changes of each
+//     commit to the webhook, as a {"changes": [...]} document.
+//   - stream://name feeds the stream read by another workflow (applier_stream.go).
+// Other sinks can be added with RegisterApplier.
+
+func init() {
+	RegisterApplier("file", newFileApplier)
+	RegisterApplier("http", newWebhookApplier)
+	RegisterApplier("https", newWebhookApplier)
+}
+
+// Op returns the operation of the change: insert, update or delete.
+func (rc *RowChange) Op() string {
+	switch {
+	case rc.Before == nil:
+		return "insert"
+	case rc.After == nil:
+		return "delete"
+	default:
+		return "update"
+	}
+}
+
+// MarshalJSON encodes the change as a JSON object, whose images are objects with
+// the columns of the table in order. The numbers are encoded as JSON numbers, JSON
+// values as they are, the binary values in base64 and the other values as strings.
+func (rc *RowChange) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"table":`)
+	if err := writeJSON(&buf, rc.Table); err != nil {
+		return nil, err
+	}
+	buf.WriteString(`,"op":"`)
+	buf.WriteString(rc.Op())
+	buf.WriteByte('"')
+	for _, image := range []struct {
+		name string
+		vals []sqltypes.Value
+	}{{"before", rc.Before}, {"after", rc.After}} {
+		if image.vals == nil {
+			continue
+		}
+		if len(image.vals) != len(rc.Fields) {
+			return nil, fmt.Errorf("%s image of %s has %d values for %d columns", image.name, rc.Table, len(image.vals), len(rc.Fields))
+		}
+		buf.WriteString(`,"` + image.name + `":{`)
+		for i, val := range image.vals {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(&buf, rc.Fields[i].Name); err != nil {
+				return nil, err
+			}
+			buf.WriteByte(':')
+			if err := writeJSONValue(&buf, val); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, val sqltypes.Value) error {
+	switch {
+	case val.IsNull():
+		buf.WriteString("null")
+		return nil
+	case val.IsIntegral() || val.IsFloat() || val.IsDecimal():
+		return writeJSON(buf, json.Number(val.ToString()))
+	case val.Type() == querypb.Type_JSON:
+		return writeJSON(buf, json.RawMessage(val.Raw()))
+	case val.IsBinary() || val.Type() == querypb.Type_BIT || val.Type() == querypb.Type_GEOMETRY:
+		return writeJSON(buf, base64.StdEncoding.EncodeToString(val.Raw()))
+	default:
+		return writeJSON(buf, val.ToString())
+	}
+}
+
+// fileApplier appends the changes of each table to a newline-delimited JSON file.
+// The changes are buffered until they are committed.
+type fileApplier struct {
+	dir     string
+	files   map[string]*os.File
+	pending map[string]*bytes.Buffer
+}
+
+func newFileApplier(sink *url.URL) (Applier, error) {
+	if sink.Path == "" {
+		return nil, fmt.Errorf("no directory in the sink %s", sink)
+	}
+	if err := os.MkdirAll(sink.Path, 0755); err != nil {
+		return nil, err
+	}
+	return &fileApplier{
+		dir:     sink.Path,
+		files:   make(map[string]*os.File),
+		pending: make(map[string]*bytes.Buffer),
+	}, nil
+}
+
+// Apply is part of the Applier interface.
+func (fa *fileApplier) Apply(_ context.Context, changes []*RowChange) error {
+	for _, change := range changes {
+		data, err := change.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buf, ok := fa.pending[change.Table]
+		if !ok {
+			buf = &bytes.Buffer{}
+			fa.pending[change.Table] = buf
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// Commit is part of the Applier interface.
+func (fa *fileApplier) Commit(_ context.Context) error {
+	tables := make([]string, 0, len(fa.pending))
+	for table := range fa.pending {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		file, ok := fa.files[table]
+		if !ok {
+			var err error
+			file, err = os.OpenFile(filepath.Join(fa.dir, table+".ndjson"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return err
+			}
+			fa.files[table] = file
+		}
+		if _, err := fa.pending[table].WriteTo(file); err != nil {
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+		delete(fa.pending, table)
+	}
+	return nil
+}
+
+// Close is part of the Applier interface.
+func (fa *fileApplier) Close() error {
+	var closeErr error
+	for table, file := range fa.files {
+		if err := file.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+		delete(fa.files, table)
+	}
+	fa.pending = make(map[string]*bytes.Buffer)
+	return closeErr
+}
+
+// webhookApplier posts the changes of each commit to a webhook. The
+// changes are committed once the webhook responds with a 2xx status.
+type webhookApplier struct {
+	url     string
+	client  *http.Client
+	pending []json.RawMessage
+}
+
+func newWebhookApplier(sink *url.URL) (Applier, error) {
+	return &webhookApplier{
+		url:    sink.String(),
+		client: &http.Client{},
+	}, nil
+}
+
+// Apply is part of the Applier interface.
+func (wa *webhookApplier) Apply(_ context.Context, changes []*RowChange) error {
+	for _, change := range changes {
+		data, err := change.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		wa.pending = append(wa.pending, data)
+	}
+	return nil
+}
+
+// Commit is part of the Applier interface.
+func (wa *webhookApplier) Commit(ctx context.Context) error {
+	if len(wa.pending) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(struct {
+		Changes []json.RawMessage `json:"changes"`
+	}{wa.pending})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wa.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := wa.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s responded with status %s", wa.url, resp.Status)
+	}
+	wa.pending = nil
+	return nil
+}
+
+// Close is part of the Applier interface.
+func (wa *webhookApplier) Close() error {
+	wa.pending = nil
+	wa.client.CloseIdleConnections()
+	return nil
+}
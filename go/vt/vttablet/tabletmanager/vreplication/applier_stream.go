@@ -0,0 +1,495 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// A stream hands the changes committed by the workflow whose sink is stream://<name>
+// over to the workflow whose source stream is <name>, on the same tablet. A commit
+// waits until the reader has committed the changes, so that they are not lost if either
+// workflow stops: the changes are read at least once. The position of the reader is
+// made of the epoch of the stream, which is new when the tablet restarts, and of the
+// sequence number of the last commit it read. A reader whose position is of another
+// epoch starts with the changes which are not committed yet.
+
+func init() {
+	RegisterApplier("stream", newStreamApplier)
+}
+
+// streamHeartbeatInterval is the interval of the heartbeats sent to the reader of an idle stream.
+const streamHeartbeatInterval = time.Second
+
+var (
+	changeStreamsMu sync.Mutex
+	changeStreams   = make(map[string]*changeStream)
+)
+
+// changeStream is the stream of the changes committed by a workflow.
+type changeStream struct {
+	name  string
+	epoch string
+
+	mu sync.Mutex
+	// changed is closed when a batch is published or committed.
+	changed chan struct{}
+	writer  bool
+	reader  bool
+	seq     int64
+	// batch is the last batch published, until it is committed by the reader.
+	batch *streamBatch
+	// committed is the sequence number of the last batch committed by the reader.
+	committed int64
+}
+
+// streamBatch is the changes of a commit of a stream.
+type streamBatch struct {
+	seq       int64
+	timestamp int64
+	changes   []*RowChange
+}
+
+func getChangeStream(name string) *changeStream {
+	changeStreamsMu.Lock()
+	defer changeStreamsMu.Unlock()
+	cs, ok := changeStreams[name]
+	if !ok {
+		cs = &changeStream{
+			name:    name,
+			epoch:   strconv.FormatInt(time.Now().UnixNano(), 10),
+			changed: make(chan struct{}),
+		}
+		changeStreams[name] = cs
+	}
+	return cs
+}
+
+// notify wakes up the goroutines waiting for the stream to change. It's called with the lock held.
+func (cs *changeStream) notify() {
+	close(cs.changed)
+	cs.changed = make(chan struct{})
+}
+
+// position returns the position of the batch with the sequence number. The high
+// bits of the sequence number follow the epoch in the file of a FilePos position,
+// whose positions only have 32 bits.
+func (cs *changeStream) position(seq int64) replication.Position {
+	return replication.Position{GTIDSet: replication.FilePosGTID{
+		File: fmt.Sprintf("%s.%010d", cs.epoch, seq>>32),
+		Pos:  uint32(seq),
+	}}
+}
+
+// sequence returns the sequence number of a position of the stream, or false if it is of another epoch.
+func (cs *changeStream) sequence(pos replication.Position) (int64, bool) {
+	gtid, ok := pos.GTIDSet.(replication.FilePosGTID)
+	if !ok {
+		return 0, false
+	}
+	epoch, high, ok := strings.Cut(gtid.File, ".")
+	if !ok || epoch != cs.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(high, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq<<32 | int64(gtid.Pos), true
+}
+
+func (cs *changeStream) openWriter() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.writer {
+		return fmt.Errorf("stream %s is already fed by another workflow", cs.name)
+	}
+	cs.writer = true
+	return nil
+}
+
+func (cs *changeStream) closeWriter() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.writer = false
+	// The changes which are not committed are published again when the writer restarts.
+	cs.batch = nil
+}
+
+// publish publishes the changes, and waits until the reader has committed them.
+func (cs *changeStream) publish(ctx context.Context, changes []*RowChange) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.seq++
+	batch := &streamBatch{seq: cs.seq, timestamp: time.Now().Unix(), changes: changes}
+	cs.batch = batch
+	cs.notify()
+	for cs.committed < batch.seq {
+		changed := cs.changed
+		cs.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			cs.mu.Lock()
+			return ctx.Err()
+		case <-changed:
+		}
+		cs.mu.Lock()
+	}
+	if cs.batch == batch {
+		cs.batch = nil
+	}
+	return nil
+}
+
+// openReader registers the reader of the stream, and returns the sequence number of the last batch it read.
+func (cs *changeStream) openReader(startPos string) (int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.reader {
+		return 0, fmt.Errorf("stream %s is already read by another workflow", cs.name)
+	}
+	if startPos != "" {
+		pos, err := replication.DecodePosition(startPos)
+		if err != nil {
+			return 0, err
+		}
+		if seq, ok := cs.sequence(pos); ok && seq > cs.committed {
+			cs.committed = seq
+			cs.notify()
+		}
+	}
+	cs.reader = true
+	return cs.committed, nil
+}
+
+func (cs *changeStream) closeReader() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.reader = false
+}
+
+// next returns the batch following the last one read, or nil if there is none before the timeout.
+func (cs *changeStream) next(ctx context.Context, last int64, timeout time.Duration) (*streamBatch, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for cs.batch == nil || cs.batch.seq <= last {
+		changed := cs.changed
+		cs.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			cs.mu.Lock()
+			return nil, ctx.Err()
+		case <-timer.C:
+			cs.mu.Lock()
+			return nil, nil
+		case <-changed:
+		}
+		cs.mu.Lock()
+	}
+	return cs.batch, nil
+}
+
+// commit records that the reader has committed the batches up to the sequence number.
+func (cs *changeStream) commit(seq int64) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if seq > cs.committed {
+		cs.committed = seq
+		cs.notify()
+	}
+}
+
+// streamApplier publishes the changes of each commit to a stream.
+type streamApplier struct {
+	stream  *changeStream
+	pending []*RowChange
+}
+
+func newStreamApplier(sink *url.URL) (Applier, error) {
+	if sink.Host == "" {
+		return nil, fmt.Errorf("no stream name in the sink %s", sink)
+	}
+	stream := getChangeStream(sink.Host)
+	if err := stream.openWriter(); err != nil {
+		return nil, err
+	}
+	return &streamApplier{stream: stream}, nil
+}
+
+// Apply is part of the Applier interface.
+func (sa *streamApplier) Apply(_ context.Context, changes []*RowChange) error {
+	sa.pending = append(sa.pending, changes...)
+	return nil
+}
+
+// Commit is part of the Applier interface.
+func (sa *streamApplier) Commit(ctx context.Context) error {
+	if len(sa.pending) == 0 {
+		return nil
+	}
+	if err := sa.stream.publish(ctx, sa.pending); err != nil {
+		return err
+	}
+	sa.pending = nil
+	return nil
+}
+
+// Close is part of the Applier interface.
+func (sa *streamApplier) Close() error {
+	sa.pending = nil
+	sa.stream.closeWriter()
+	return nil
+}
+
+// streamConnector is the VStreamerClient of a workflow reading a stream.
+type streamConnector struct {
+	stream *changeStream
+}
+
+func newStreamConnector(name string) *streamConnector {
+	return &streamConnector{stream: getChangeStream(name)}
+}
+
+// Open is part of the VStreamerClient interface.
+func (sc *streamConnector) Open(ctx context.Context) error {
+	return nil
+}
+
+// Close is part of the VStreamerClient interface.
+func (sc *streamConnector) Close(ctx context.Context) error {
+	return nil
+}
+
+// VStream is part of the VStreamerClient interface. A transaction is sent for each
+// batch, with the fields of the tables the first time they are seen, and the rows
+// matching the filter. The batches without such rows are committed right away.
+func (sc *streamConnector) VStream(ctx context.Context, startPos string, tablePKs []*binlogdatapb.TableLastPK, filter *binlogdatapb.Filter, send func([]*binlogdatapb.VEvent) error) error {
+	last, err := sc.stream.openReader(startPos)
+	if err != nil {
+		return err
+	}
+	defer sc.stream.closeReader()
+	plans := make(map[string]*streamTablePlan)
+	for {
+		batch, err := sc.stream.next(ctx, last, streamHeartbeatInterval)
+		if err != nil {
+			return err
+		}
+		if batch == nil {
+			now := time.Now()
+			if err := send([]*binlogdatapb.VEvent{{
+				Type:        binlogdatapb.VEventType_HEARTBEAT,
+				Timestamp:   now.Unix(),
+				CurrentTime: now.UnixNano(),
+			}}); err != nil {
+				return err
+			}
+			continue
+		}
+		last = batch.seq
+		events, err := sc.events(batch, filter, plans)
+		if err != nil {
+			return err
+		}
+		if events == nil {
+			sc.stream.commit(batch.seq)
+			continue
+		}
+		if err := send(events); err != nil {
+			return err
+		}
+	}
+}
+
+// events returns the events of a batch, or nil if none of its rows match the filter.
+func (sc *streamConnector) events(batch *streamBatch, filter *binlogdatapb.Filter, plans map[string]*streamTablePlan) ([]*binlogdatapb.VEvent, error) {
+	var rows []*binlogdatapb.VEvent
+	for _, change := range batch.changes {
+		plan, ok := plans[change.Table]
+		if !ok || !sameFields(plan.source, change.Fields) {
+			var err error
+			if plan, err = buildStreamTablePlan(sc.stream.name, change.Table, change.Fields, filter); err != nil {
+				return nil, err
+			}
+			plans[change.Table] = plan
+			if plan.fields != nil {
+				rows = append(rows, &binlogdatapb.VEvent{
+					Type:       binlogdatapb.VEventType_FIELD,
+					Timestamp:  batch.timestamp,
+					FieldEvent: &binlogdatapb.FieldEvent{TableName: change.Table, Fields: plan.fields},
+				})
+			}
+		}
+		if plan.fields == nil {
+			continue
+		}
+		before, err := plan.row(change.Before)
+		if err != nil {
+			return nil, err
+		}
+		after, err := plan.row(change.After)
+		if err != nil {
+			return nil, err
+		}
+		if before == nil && after == nil {
+			continue
+		}
+		rows = append(rows, &binlogdatapb.VEvent{
+			Type:      binlogdatapb.VEventType_ROW,
+			Timestamp: batch.timestamp,
+			RowEvent: &binlogdatapb.RowEvent{
+				TableName:  change.Table,
+				RowChanges: []*binlogdatapb.RowChange{{Before: before, After: after}},
+			},
+		})
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UnixNano()
+	events := make([]*binlogdatapb.VEvent, 0, len(rows)+3)
+	events = append(events, &binlogdatapb.VEvent{Type: binlogdatapb.VEventType_BEGIN, Timestamp: batch.timestamp, CurrentTime: now})
+	events = append(events, rows...)
+	return append(events,
+		&binlogdatapb.VEvent{Type: binlogdatapb.VEventType_GTID, Gtid: replication.EncodePosition(sc.stream.position(batch.seq)), Timestamp: batch.timestamp, CurrentTime: now},
+		&binlogdatapb.VEvent{Type: binlogdatapb.VEventType_COMMIT, Timestamp: batch.timestamp, CurrentTime: now},
+	), nil
+}
+
+// committed records that the workflow reading the stream has committed the changes up to the position.
+func (sc *streamConnector) committed(pos replication.Position) {
+	if seq, ok := sc.stream.sequence(pos); ok {
+		sc.stream.commit(seq)
+	}
+}
+
+// VStreamRows is part of the VStreamerClient interface.
+func (sc *streamConnector) VStreamRows(ctx context.Context, query string, lastpk *querypb.QueryResult, send func(*binlogdatapb.VStreamRowsResponse) error) error {
+	return fmt.Errorf("the rows of stream %s cannot be copied", sc.stream.name)
+}
+
+// VStreamTables is part of the VStreamerClient interface.
+func (sc *streamConnector) VStreamTables(ctx context.Context, send func(*binlogdatapb.VStreamTablesResponse) error) error {
+	return fmt.Errorf("the tables of stream %s cannot be copied", sc.stream.name)
+}
+
+// streamTablePlan selects the columns of the rows of a table of a stream, for the rule of the
+// table. Only the columns can be selected, and the rows can be filtered by a where clause.
+type streamTablePlan struct {
+	// source are the fields of the changes of the table.
+	source []*querypb.Field
+	// fields are the fields of the selected columns, or nil if the table does not match the filter.
+	fields  []*querypb.Field
+	columns []int
+	where   evalengine.Expr
+}
+
+func buildStreamTablePlan(stream, table string, source []*querypb.Field, filter *binlogdatapb.Filter) (*streamTablePlan, error) {
+	plan := &streamTablePlan{source: source}
+	rule, err := MatchTable(table, filter)
+	if err != nil || rule == nil {
+		return plan, err
+	}
+	query := rule.Filter
+	switch {
+	case query == "":
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("select * from %v", sqlparser.NewIdentifierCS(table))
+		query = buf.String()
+	case key.IsValidKeyRange(query):
+		return nil, fmt.Errorf("the rows of table %s of stream %s cannot be filtered by keyrange", table, stream)
+	}
+	sel, _, err := analyzeSelectFrom(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, expr := range sel.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			for i := range source {
+				plan.columns = append(plan.columns, i)
+			}
+			continue
+		case *sqlparser.AliasedExpr:
+			if col, ok := expr.Expr.(*sqlparser.ColName); ok && col.Qualifier.IsEmpty() {
+				i := -1
+				for j, field := range source {
+					if col.Name.EqualString(field.Name) {
+						i = j
+					}
+				}
+				if i < 0 {
+					return nil, fmt.Errorf("column %s is not in table %s of stream %s", sqlparser.String(col), table, stream)
+				}
+				plan.columns = append(plan.columns, i)
+				continue
+			}
+		}
+		return nil, fmt.Errorf("%s of table %s of stream %s is not a column", sqlparser.String(expr), table, stream)
+	}
+	if sel.Where != nil {
+		if plan.where, err = evalengine.Translate(sel.Where.Expr, fieldsConfig(source)); err != nil {
+			return nil, vterrors.Wrapf(err, "cannot evaluate %v", sqlparser.String(sel.Where.Expr))
+		}
+	}
+	plan.fields = make([]*querypb.Field, 0, len(plan.columns))
+	for _, i := range plan.columns {
+		plan.fields = append(plan.fields, source[i])
+	}
+	return plan, nil
+}
+
+// row returns the selected columns of an image of a change, or nil if it does not match the where clause.
+func (plan *streamTablePlan) row(vals []sqltypes.Value) (*querypb.Row, error) {
+	if vals == nil {
+		return nil, nil
+	}
+	if plan.where != nil {
+		env := evalengine.EmptyExpressionEnv()
+		env.Row = vals
+		result, err := env.Evaluate(plan.where)
+		if err != nil {
+			return nil, err
+		}
+		if !result.ToBoolean() {
+			return nil, nil
+		}
+	}
+	selected := make([]sqltypes.Value, 0, len(plan.columns))
+	for _, i := range plan.columns {
+		selected = append(selected, vals[i])
+	}
+	return sqltypes.RowToProto3(selected), nil
+}
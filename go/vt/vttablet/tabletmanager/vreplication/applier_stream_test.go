@@ -0,0 +1,169 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/sqltypes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func TestStreamApplier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	applier, err := newApplier("stream://orders")
+	require.NoError(t, err)
+	defer applier.Close()
+	_, err = newApplier("stream://orders")
+	assert.EqualError(t, err, "stream orders is already fed by another workflow")
+
+	fields := sqltypes.MakeTestFields("id|name|price", "int64|varchar|int64")
+	change := func(table string, id int64, name string) *RowChange {
+		return &RowChange{Table: table, Fields: fields, After: []sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NewVarChar(name), sqltypes.NULL}}
+	}
+	filter := &binlogdatapb.Filter{Rules: []*binlogdatapb.Rule{{Match: "t1", Filter: "select id, name from t1 where id < 3"}}}
+	connector := newStreamConnector("orders")
+	batches := make(chan []*binlogdatapb.VEvent)
+	readErr := make(chan error, 1)
+	read := func(ctx context.Context, startPos string) {
+		go func() {
+			readErr <- connector.VStream(ctx, startPos, nil, filter, func(events []*binlogdatapb.VEvent) error {
+				if events[0].Type != binlogdatapb.VEventType_HEARTBEAT {
+					batches <- events
+				}
+				return nil
+			})
+		}()
+	}
+	types := func(events []*binlogdatapb.VEvent) []binlogdatapb.VEventType {
+		var types []binlogdatapb.VEventType
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	// the commit waits until the reader has committed the changes
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t1", 1, "ann"), change("t2", 2, "bob"), change("t1", 3, "joe")}))
+	committed := make(chan error, 1)
+	go func() { committed <- applier.Commit(ctx) }()
+	readCtx, stopReading := context.WithCancel(ctx)
+	read(readCtx, "")
+	events := <-batches
+	require.Equal(t, []binlogdatapb.VEventType{
+		binlogdatapb.VEventType_BEGIN,
+		binlogdatapb.VEventType_FIELD,
+		binlogdatapb.VEventType_ROW,
+		binlogdatapb.VEventType_GTID,
+		binlogdatapb.VEventType_COMMIT,
+	}, types(events))
+	assert.Equal(t, "t1", events[1].FieldEvent.TableName)
+	assert.Equal(t, []string{"id", "name"}, []string{events[1].FieldEvent.Fields[0].Name, events[1].FieldEvent.Fields[1].Name})
+	assert.Equal(t, []*binlogdatapb.RowChange{{After: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("ann")})}}, events[2].RowEvent.RowChanges)
+	select {
+	case <-committed:
+		t.Fatal("the changes are committed before the reader commits them")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pos, err := replication.DecodePosition(events[3].Gtid)
+	require.NoError(t, err)
+	connector.committed(pos)
+	require.NoError(t, <-committed)
+
+	err = newStreamConnector("orders").VStream(ctx, "", nil, filter, func([]*binlogdatapb.VEvent) error { return nil })
+	assert.EqualError(t, err, "stream orders is already read by another workflow")
+
+	// the changes which are not read are committed by the stream
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t2", 4, "amy"), change("t1", 5, "amy")}))
+	require.NoError(t, applier.Commit(ctx))
+
+	// a reader restarted before committing the changes reads them again
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t1", 2, "sue")}))
+	go func() { committed <- applier.Commit(ctx) }()
+	events = <-batches
+	assert.Equal(t, []binlogdatapb.VEventType{
+		binlogdatapb.VEventType_BEGIN,
+		binlogdatapb.VEventType_ROW,
+		binlogdatapb.VEventType_GTID,
+		binlogdatapb.VEventType_COMMIT,
+	}, types(events))
+	stopReading()
+	assert.ErrorIs(t, <-readErr, context.Canceled)
+	readCtx, stopReading = context.WithCancel(ctx)
+	defer stopReading()
+	read(readCtx, replication.EncodePosition(pos))
+	again := <-batches
+	require.Len(t, again, 5, "the fields are sent again")
+	assert.Equal(t, events[1].RowEvent.RowChanges, again[2].RowEvent.RowChanges)
+	assert.Equal(t, events[2].Gtid, again[3].Gtid)
+	next, err := replication.DecodePosition(again[3].Gtid)
+	require.NoError(t, err)
+	assert.True(t, next.AtLeast(pos))
+	assert.False(t, pos.AtLeast(next))
+	connector.committed(next)
+	require.NoError(t, <-committed)
+
+	// the changes are kept when the commit is canceled
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t1", 1, "max")}))
+	commitCtx, cancelCommit := context.WithCancel(ctx)
+	go func() { committed <- applier.Commit(commitCtx) }()
+	<-batches
+	cancelCommit()
+	assert.ErrorIs(t, <-committed, context.Canceled)
+	assert.Len(t, applier.(*streamApplier).pending, 1)
+}
+
+func TestStreamTablePlan(t *testing.T) {
+	fields := sqltypes.MakeTestFields("id|name", "int64|varchar")
+	rule := func(filter string) *binlogdatapb.Filter {
+		return &binlogdatapb.Filter{Rules: []*binlogdatapb.Rule{{Match: "t1", Filter: filter}}}
+	}
+
+	plan, err := buildStreamTablePlan("s1", "t1", fields, rule(""))
+	require.NoError(t, err)
+	assert.Equal(t, fields, plan.fields)
+	row, err := plan.row([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("ann")})
+	require.NoError(t, err)
+	assert.Equal(t, sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("ann")}), row)
+
+	plan, err = buildStreamTablePlan("s1", "t2", fields, rule(""))
+	require.NoError(t, err)
+	assert.Nil(t, plan.fields)
+
+	plan, err = buildStreamTablePlan("s1", "t1", fields, rule("select name from t1 where id = 1"))
+	require.NoError(t, err)
+	assert.Equal(t, []*querypb.Field{fields[1]}, plan.fields)
+	row, err = plan.row([]sqltypes.Value{sqltypes.NewInt64(2), sqltypes.NewVarChar("ann")})
+	require.NoError(t, err)
+	assert.Nil(t, row)
+
+	_, err = buildStreamTablePlan("s1", "t1", fields, rule("select id + 1 as id from t1"))
+	assert.EqualError(t, err, "id + 1 as id of table t1 of stream s1 is not a column")
+	_, err = buildStreamTablePlan("s1", "t1", fields, rule("select id, other from t1"))
+	assert.EqualError(t, err, "column other is not in table t1 of stream s1")
+	_, err = buildStreamTablePlan("s1", "t1", fields, rule("-80"))
+	assert.EqualError(t, err, "the rows of table t1 of stream s1 cannot be filtered by keyrange")
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// recordingApplier records the changes it applies and commits.
+type recordingApplier struct {
+	pending   []string
+	committed []string
+	closed    bool
+}
+
+func (ra *recordingApplier) Apply(_ context.Context, changes []*RowChange) error {
+	for _, change := range changes {
+		data, err := change.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		ra.pending = append(ra.pending, string(data))
+	}
+	return nil
+}
+
+func (ra *recordingApplier) Commit(_ context.Context) error {
+	ra.committed = append(ra.committed, ra.pending...)
+	ra.pending = nil
+	return nil
+}
+
+func (ra *recordingApplier) Close() error {
+	ra.closed = true
+	return nil
+}
+
+func TestApplierSink(t *testing.T) {
+	ctx := context.Background()
+	input := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "t1",
+			Filter: "select id, concat(upper(name), '!') as name, keyspace_id() as ksid from t1 where id % 2 = 1",
+		}},
+	}
+	colInfos := map[string][]*ColumnInfo{
+		"t1": {{Name: "id", IsPK: true}, {Name: "name"}, {Name: "ksid"}},
+	}
+	fields := []*querypb.Field{
+		{Name: "id", Type: querypb.Type_INT64},
+		{Name: "name", Type: querypb.Type_VARCHAR, Charset: 255},
+		{Name: "keyspace_id", Type: querypb.Type_VARBINARY},
+	}
+	row := func(id int64, name string) *querypb.Row {
+		return sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NewVarChar(name), sqltypes.NewVarBinary("\x01")})
+	}
+	// The expressions of the rows applied to a sink are evaluated by the evalengine.
+	source := getSource(input)
+	source.Sink = "file:///tmp/vreplication"
+	buildPlan := func(copyState map[string]*sqltypes.Result) *TablePlan {
+		plan, err := buildReplicatorPlan(source, colInfos, copyState, binlogplayer.NewStats())
+		require.NoError(t, err)
+		tplan, err := plan.buildExecutionPlan(&binlogdatapb.FieldEvent{TableName: "t1", Fields: fields})
+		require.NoError(t, err)
+		return tplan
+	}
+
+	applier := &recordingApplier{}
+	sink := newApplierSink(applier)
+	tplan := buildPlan(nil)
+	err := sink.applyRowEvent(ctx, tplan, &binlogdatapb.RowEvent{
+		TableName: "t1",
+		RowChanges: []*binlogdatapb.RowChange{
+			{After: row(1, "ann")},
+			// the rows which do not match the filter are not applied
+			{After: row(2, "joe")},
+			{Before: row(1, "ann"), After: row(1, "bob")},
+			// a row which stops matching the filter is deleted
+			{Before: row(1, "bob"), After: row(2, "bob")},
+		},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, applier.committed)
+	require.NoError(t, sink.commit(ctx))
+	assert.Equal(t, []string{
+		`{"table":"t1","op":"insert","after":{"id":1,"name":"ANN!","ksid":"AQ=="}}`,
+		`{"table":"t1","op":"update","before":{"id":1,"name":"ANN!","ksid":"AQ=="},"after":{"id":1,"name":"BOB!","ksid":"AQ=="}}`,
+		`{"table":"t1","op":"delete","before":{"id":1,"name":"BOB!","ksid":"AQ=="}}`,
+	}, applier.committed)
+	// the fields have the types of the values
+	var types []querypb.Type
+	for _, field := range sink.plans["t1"].fields {
+		types = append(types, field.Type)
+	}
+	assert.Equal(t, []querypb.Type{querypb.Type_INT64, querypb.Type_VARCHAR, querypb.Type_VARBINARY}, types)
+
+	// the copied rows are inserted
+	applier.committed = nil
+	require.NoError(t, sink.applyRows(ctx, tplan, []*querypb.Row{row(3, "amy"), row(4, "joe")}))
+	require.NoError(t, sink.commit(ctx))
+	assert.Equal(t, []string{
+		`{"table":"t1","op":"insert","after":{"id":3,"name":"AMY!","ksid":"AQ=="}}`,
+	}, applier.committed)
+
+	// while the table is copied, only the changes of the rows already copied are applied
+	applier.committed = nil
+	tplan = buildPlan(map[string]*sqltypes.Result{
+		"t1": sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "5"),
+	})
+	err = sink.applyRowEvent(ctx, tplan, &binlogdatapb.RowEvent{
+		TableName: "t1",
+		RowChanges: []*binlogdatapb.RowChange{
+			{After: row(5, "ann")},
+			{After: row(7, "joe")},
+			{Before: row(5, "ann"), After: row(7, "ann")},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, sink.commit(ctx))
+	assert.Equal(t, []string{
+		`{"table":"t1","op":"insert","after":{"id":5,"name":"ANN!","ksid":"AQ=="}}`,
+		`{"table":"t1","op":"delete","before":{"id":5,"name":"ANN!","ksid":"AQ=="}}`,
+	}, applier.committed)
+
+	require.NoError(t, sink.close())
+	assert.True(t, applier.closed)
+}
+
+func TestApplierSinkUnsupported(t *testing.T) {
+	input := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "t1",
+			Filter: "select id, count(*) as cnt from t1 group by id",
+		}},
+	}
+	colInfos := map[string][]*ColumnInfo{
+		"t1": {{Name: "id", IsPK: true}, {Name: "cnt"}},
+	}
+	plan, err := buildReplicatorPlan(getSource(input), colInfos, nil, binlogplayer.NewStats())
+	require.NoError(t, err)
+	tplan, err := plan.buildExecutionPlan(&binlogdatapb.FieldEvent{
+		TableName: "t1",
+		Fields:    []*querypb.Field{{Name: "id", Type: querypb.Type_INT64}},
+	})
+	require.NoError(t, err)
+	err = newApplierSink(&recordingApplier{}).applyRows(context.Background(), tplan, nil)
+	assert.EqualError(t, err, "aggregate column cnt of table t1 cannot be applied by an applier")
+}
+
+func TestRowChangeMarshalJSON(t *testing.T) {
+	change := &RowChange{
+		Table:  "t1",
+		Fields: sqltypes.MakeTestFields("id|price|doc|data|name|deleted", "int64|decimal|json|varbinary|varchar|int64"),
+		After: []sqltypes.Value{
+			sqltypes.NewInt64(1),
+			sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("12.50")),
+			sqltypes.MakeTrusted(querypb.Type_JSON, []byte(`{"a": [1, 2]}`)),
+			sqltypes.NewVarBinary("\xff\x00"),
+			sqltypes.NewVarChar(`say "hi"`),
+			sqltypes.NULL,
+		},
+	}
+	data, err := change.MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"table":"t1","op":"insert","after":{"id":1,"price":12.50,"doc":{"a":[1,2]},"data":"/wA=","name":"say \"hi\"","deleted":null}}`, string(data))
+
+	change.Before = []sqltypes.Value{sqltypes.NewInt64(1)}
+	_, err = change.MarshalJSON()
+	assert.EqualError(t, err, "before image of t1 has 1 values for 6 columns")
+}
+
+func TestFileApplier(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "sink")
+	sink, err := url.Parse("file://" + dir)
+	require.NoError(t, err)
+	applier, err := newFileApplier(sink)
+	require.NoError(t, err)
+
+	change := func(table string, id int64) *RowChange {
+		return &RowChange{Table: table, Fields: sqltypes.MakeTestFields("id", "int64"), After: []sqltypes.Value{sqltypes.NewInt64(id)}}
+	}
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t1", 1), change("t2", 2)}))
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t1", 3)}))
+	_, err = os.Stat(filepath.Join(dir, "t1.ndjson"))
+	assert.True(t, os.IsNotExist(err), "the changes are written when committed")
+	require.NoError(t, applier.Commit(ctx))
+	// the changes which are not committed are discarded
+	require.NoError(t, applier.Apply(ctx, []*RowChange{change("t1", 4)}))
+	require.NoError(t, applier.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "t1.ndjson"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"table":"t1","op":"insert","after":{"id":1}}`+"\n"+`{"table":"t1","op":"insert","after":{"id":3}}`+"\n", string(data))
+	data, err = os.ReadFile(filepath.Join(dir, "t2.ndjson"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"table":"t2","op":"insert","after":{"id":2}}`+"\n", string(data))
+}
+
+func TestWebhookApplier(t *testing.T) {
+	ctx := context.Background()
+	var bodies []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, err := url.Parse(server.URL + "/changes")
+	require.NoError(t, err)
+	applier, err := newWebhookApplier(sink)
+	require.NoError(t, err)
+	defer applier.Close()
+
+	// nothing is posted without changes
+	require.NoError(t, applier.Commit(ctx))
+	assert.Empty(t, bodies)
+
+	changes := []*RowChange{
+		{Table: "t1", Fields: sqltypes.MakeTestFields("id", "int64"), After: []sqltypes.Value{sqltypes.NewInt64(1)}},
+		{Table: "t1", Fields: sqltypes.MakeTestFields("id", "int64"), Before: []sqltypes.Value{sqltypes.NewInt64(2)}},
+	}
+	require.NoError(t, applier.Apply(ctx, changes))
+	require.NoError(t, applier.Commit(ctx))
+	require.Len(t, bodies, 1)
+	var doc struct {
+		Changes []map[string]any `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &doc))
+	require.Len(t, doc.Changes, 2)
+	assert.Equal(t, "insert", doc.Changes[0]["op"])
+	assert.Equal(t, "delete", doc.Changes[1]["op"])
+
+	// the changes are posted again until the webhook accepts them
+	status = http.StatusServiceUnavailable
+	require.NoError(t, applier.Apply(ctx, changes[:1]))
+	assert.ErrorContains(t, applier.Commit(ctx), "responded with status 503")
+	status = http.StatusOK
+	require.NoError(t, applier.Commit(ctx))
+	require.Len(t, bodies, 3)
+	assert.Equal(t, bodies[1], bodies[2])
+}
+
+func TestNewApplier(t *testing.T) {
+	applier, err := newApplier("file://" + t.TempDir())
+	require.NoError(t, err)
+	assert.IsType(t, &fileApplier{}, applier)
+	require.NoError(t, applier.Close())
+
+	applier, err = newApplier("")
+	require.NoError(t, err)
+	assert.Nil(t, applier)
+
+	_, err = newApplier("unknown://host")
+	assert.EqualError(t, err, "no applier registered for the sink unknown://host")
+}
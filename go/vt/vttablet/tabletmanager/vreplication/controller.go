@@ -178,6 +178,27 @@ func (ct *controller) run(ctx context.Context) {
 	}
 }
 
+// setApplySessionVars sets the session variables of a connection applying the events of a stream.
+func setApplySessionVars(dbClient binlogplayer.DBClient) error {
+	// Timestamp fields from binlogs are always sent as UTC.
+	// So, we should set the timezone to be UTC for those values to be correctly inserted.
+	if _, err := dbClient.ExecuteFetch("set @@session.time_zone = '+00:00'", 10000); err != nil {
+		return err
+	}
+	// Tables may have varying character sets. To ship the bits without interpreting them
+	// we set the character set to be binary.
+	if _, err := dbClient.ExecuteFetch("set names 'binary'", 10000); err != nil {
+		return err
+	}
+	if _, err := dbClient.ExecuteFetch(fmt.Sprintf("set @@session.net_read_timeout = %v", vttablet.VReplicationNetReadTimeout), 10000); err != nil {
+		return err
+	}
+	if _, err := dbClient.ExecuteFetch(fmt.Sprintf("set @@session.net_write_timeout = %v", vttablet.VReplicationNetWriteTimeout), 10000); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (ct *controller) runBlp(ctx context.Context) (err error) {
 	defer func() {
 		ct.sourceTablet.Store(&topodatapb.TabletAlias{})
@@ -219,20 +240,7 @@ func (ct *controller) runBlp(ctx context.Context) (err error) {
 		player := binlogplayer.NewBinlogPlayerKeyRange(dbClient, tablet, ct.source.KeyRange, ct.id, ct.blpStats)
 		return player.ApplyBinlogEvents(ctx)
 	case ct.source.Filter != nil:
-		// Timestamp fields from binlogs are always sent as UTC.
-		// So, we should set the timezone to be UTC for those values to be correctly inserted.
-		if _, err := dbClient.ExecuteFetch("set @@session.time_zone = '+00:00'", 10000); err != nil {
-			return err
-		}
-		// Tables may have varying character sets. To ship the bits without interpreting them
-		// we set the character set to be binary.
-		if _, err := dbClient.ExecuteFetch("set names 'binary'", 10000); err != nil {
-			return err
-		}
-		if _, err := dbClient.ExecuteFetch(fmt.Sprintf("set @@session.net_read_timeout = %v", vttablet.VReplicationNetReadTimeout), 10000); err != nil {
-			return err
-		}
-		if _, err := dbClient.ExecuteFetch(fmt.Sprintf("set @@session.net_write_timeout = %v", vttablet.VReplicationNetWriteTimeout), 10000); err != nil {
+		if err := setApplySessionVars(dbClient); err != nil {
 			return err
 		}
 		// We must apply AUTO_INCREMENT values precisely as we got them. This include the 0 value, which is not recommended in AUTO_INCREMENT, and yet is valid.
@@ -247,6 +255,8 @@ func (ct *controller) runBlp(ctx context.Context) (err error) {
 			if err != nil {
 				return err
 			}
+		} else if name := ct.source.GetSourceStream(); name != "" {
+			vsClient = newStreamConnector(name)
 		} else {
 			vsClient = newTabletConnector(tablet)
 		}
@@ -297,7 +307,7 @@ func (ct *controller) setMessage(dbClient binlogplayer.DBClient, message string)
 // the vreplication stream. If the source is marked as external, it
 // returns nil.
 func (ct *controller) pickSourceTablet(ctx context.Context, dbClient binlogplayer.DBClient) (*topodatapb.Tablet, error) {
-	if ct.source.GetExternalMysql() != "" {
+	if ct.source.GetExternalMysql() != "" || ct.source.GetSourceStream() != "" {
 		return nil, nil
 	}
 	log.Infof("Trying to find an eligible source tablet for vreplication stream id %d for workflow: %s",
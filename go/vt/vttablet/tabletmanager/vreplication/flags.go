@@ -39,6 +39,7 @@ var (
 
 	vreplicationStoreCompressedGTID   = false
 	vreplicationParallelInsertWorkers = 1
+	vreplicationParallelApplyWorkers  = 1
 )
 
 func registerVReplicationFlags(fs *pflag.FlagSet) {
@@ -68,6 +69,7 @@ func registerVReplicationFlags(fs *pflag.FlagSet) {
 	fs.Duration("vreplication_healthcheck_timeout", 1*time.Minute, "healthcheck retry delay")
 
 	fs.IntVar(&vreplicationParallelInsertWorkers, "vreplication-parallel-insert-workers", vreplicationParallelInsertWorkers, "Number of parallel insertion workers to use during copy phase. Set <= 1 to disable parallelism, or > 1 to enable concurrent insertion during copy phase.")
+	fs.IntVar(&vreplicationParallelApplyWorkers, "vreplication-parallel-apply-workers", vreplicationParallelApplyWorkers, "Number of parallel workers applying the transactions of a stream during the replication phase. Set <= 1 to apply them serially, or > 1 to apply the transactions which do not change the same rows concurrently.")
 }
 
 func init() {
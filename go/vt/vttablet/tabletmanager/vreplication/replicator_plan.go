@@ -17,10 +17,12 @@ limitations under the License.
 package vreplication
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"vitess.io/vitess/go/bytes2"
 	"vitess.io/vitess/go/mysql/collations"
@@ -81,27 +83,37 @@ func (rp *ReplicatorPlan) buildExecutionPlan(fieldEvent *binlogdatapb.FieldEvent
 			trimmed.Name = strings.Trim(trimmed.Name, "`")
 			tplanv.Fields = append(tplanv.Fields, trimmed)
 		}
+		var err error
+		tplanv.Filter, tplanv.EvalColumns, err = translateExprs(tplanv.TablePlanBuilder, tplanv.Fields)
+		if err != nil {
+			return nil, err
+		}
 		return &tplanv, nil
 	}
 	// select * construct was used. We need to use the field names.
-	tplan, err := rp.buildFromFields(prelim.TargetName, prelim.Lastpk, fieldEvent.Fields)
+	tplan, err := rp.buildFromFields(prelim.TargetName, prelim.Lastpk, prelim.targetFilter, fieldEvent.Fields)
 	if err != nil {
 		return nil, err
 	}
 	tplan.Fields = fieldEvent.Fields
+	tplan.Filter, _, err = translateExprs(tplan.TablePlanBuilder, tplan.Fields)
+	if err != nil {
+		return nil, err
+	}
 	return tplan, nil
 }
 
 // buildFromFields builds a full TablePlan, but uses the field info as the
 // full column list. This happens when the query used was a 'select *', which
 // requires us to wait for the field info sent by the source.
-func (rp *ReplicatorPlan) buildFromFields(tableName string, lastpk *sqltypes.Result, fields []*querypb.Field) (*TablePlan, error) {
+func (rp *ReplicatorPlan) buildFromFields(tableName string, lastpk *sqltypes.Result, targetFilter sqlparser.Expr, fields []*querypb.Field) (*TablePlan, error) {
 	tpb := &tablePlanBuilder{
-		name:     sqlparser.NewIdentifierCS(tableName),
-		lastpk:   lastpk,
-		colInfos: rp.ColInfoMap[tableName],
-		stats:    rp.stats,
-		source:   rp.Source,
+		name:         sqlparser.NewIdentifierCS(tableName),
+		lastpk:       lastpk,
+		colInfos:     rp.ColInfoMap[tableName],
+		stats:        rp.stats,
+		source:       rp.Source,
+		targetFilter: targetFilter,
 	}
 	for _, field := range fields {
 		colName := sqlparser.NewIdentifierCI(field.Name)
@@ -215,6 +227,16 @@ type TablePlan struct {
 	PartialInserts map[string]*sqlparser.ParsedQuery
 	// PartialUpdates are same as PartialInserts, but for update statements
 	PartialUpdates map[string]*sqlparser.ParsedQuery
+
+	// Filter is the part of the where clause of the rule that the source cannot
+	// apply. Only the rows sent by the source which match it are applied.
+	// EvalColumns are the expressions of the columns computed by the evalengine,
+	// keyed by the names of the bindvars of their values without the a_ or b_ prefix.
+	// They are translated once the fields are known.
+	Filter      evalengine.Expr
+	EvalColumns map[string]evalengine.Expr
+	// targetFilter is the untranslated Filter of a select * construct.
+	targetFilter sqlparser.Expr
 }
 
 // MarshalJSON performs a custom JSON Marshalling.
@@ -248,13 +270,45 @@ func (tp *TablePlan) applyBulkInsert(sqlbuffer *bytes2.Buffer, rows []*querypb.R
 	sqlbuffer.WriteString(tp.BulkInsertFront.Query)
 	sqlbuffer.WriteString(" values ")
 
-	for i, row := range rows {
-		if i > 0 {
-			sqlbuffer.WriteString(", ")
+	inserted := 0
+	for _, row := range rows {
+		if tp.Filter == nil && len(tp.EvalColumns) == 0 {
+			if inserted > 0 {
+				sqlbuffer.WriteString(", ")
+			}
+			if err := tp.BulkInsertValues.AppendFromRow(sqlbuffer, tp.Fields, row, tp.FieldsToSkip); err != nil {
+				return nil, err
+			}
+			inserted++
+			continue
+		}
+		// The values computed by the evalengine are not in the row,
+		// so the values of the rows are bound by name.
+		vals := sqltypes.MakeRowTrusted(tp.Fields, row)
+		match, err := tp.matchesFilter(vals)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		bindvars := make(map[string]*querypb.BindVariable, len(tp.Fields)+len(tp.EvalColumns))
+		if err := tp.bindAfterValues(bindvars, vals); err != nil {
+			return nil, err
 		}
-		if err := tp.BulkInsertValues.AppendFromRow(sqlbuffer, tp.Fields, row, tp.FieldsToSkip); err != nil {
+		values, err := tp.BulkInsertValues.GenerateQuery(bindvars, nil)
+		if err != nil {
 			return nil, err
 		}
+		if inserted > 0 {
+			sqlbuffer.WriteString(", ")
+		}
+		sqlbuffer.WriteString(values)
+		inserted++
+	}
+	if inserted == 0 {
+		// None of the rows match the filter.
+		return &sqltypes.Result{}, nil
 	}
 	if tp.BulkInsertOnDup != nil {
 		sqlbuffer.WriteString(tp.BulkInsertOnDup.Query)
@@ -355,45 +409,115 @@ func (tp *TablePlan) bindFieldVal(field *querypb.Field, val *sqltypes.Value) (*q
 	return sqltypes.ValueBindVariable(*val), nil
 }
 
+// applyCollation is the collation of the connections applying the events, which are set to the
+// binary character set by setApplySessionVars. The expressions evaluated by the evalengine use it.
+const applyCollation = collations.CollationBinaryID
+
+// applySession is the session of the connections applying the events: the expressions evaluated
+// by the evalengine use its time zone, which setApplySessionVars sets to UTC.
+type applySession struct{}
+
+func (applySession) TimeZone() *time.Location { return time.UTC }
+
+func (applySession) GetKeyspace() string { return "" }
+
+// newExpressionEnv returns the environment evaluating the expressions of the plan on the values of a row.
+func newExpressionEnv(vals []sqltypes.Value) *evalengine.ExpressionEnv {
+	env := evalengine.NewExpressionEnv(context.Background(), nil, applySession{})
+	env.Row = vals
+	return env
+}
+
+// matchesFilter returns true if the values of a row match the filter of the plan, if it has one.
+func (tp *TablePlan) matchesFilter(vals []sqltypes.Value) (bool, error) {
+	if tp.Filter == nil {
+		return true, nil
+	}
+	result, err := newExpressionEnv(vals).Evaluate(tp.Filter)
+	if err != nil {
+		return false, vterrors.Wrapf(err, "failed to evaluate the filter of %s", tp.TargetName)
+	}
+	return result.ToBoolean(), nil
+}
+
+// bindEvalColumns binds the values computed by the evalengine from the values of a row,
+// with the given prefix.
+func (tp *TablePlan) bindEvalColumns(bindvars map[string]*querypb.BindVariable, prefix string, vals []sqltypes.Value) error {
+	if len(tp.EvalColumns) == 0 {
+		return nil
+	}
+	env := newExpressionEnv(vals)
+	for name, expr := range tp.EvalColumns {
+		result, err := env.Evaluate(expr)
+		if err != nil {
+			return vterrors.Wrapf(err, "failed to evaluate %s of %s", strings.TrimPrefix(name, evalColumnPrefix), tp.TargetName)
+		}
+		bindvars[prefix+name] = sqltypes.ValueBindVariable(result.Value(applyCollation))
+	}
+	return nil
+}
+
+// bindAfterValues binds the values of the after image of a row.
+func (tp *TablePlan) bindAfterValues(bindvars map[string]*querypb.BindVariable, vals []sqltypes.Value) error {
+	for i, field := range tp.Fields {
+		var bindVar *querypb.BindVariable
+		var newVal *sqltypes.Value
+		var err error
+		if field.Type == querypb.Type_JSON {
+			if vals[i].IsNull() { // An SQL NULL and not an actual JSON value
+				newVal = &sqltypes.NULL
+			} else { // A JSON value (which may be a JSON null literal value)
+				newVal, err = vjson.MarshalSQLValue(vals[i].Raw())
+				if err != nil {
+					return err
+				}
+			}
+			bindVar, err = tp.bindFieldVal(field, newVal)
+		} else {
+			bindVar, err = tp.bindFieldVal(field, &vals[i])
+		}
+		if err != nil {
+			return err
+		}
+		bindvars["a_"+field.Name] = bindVar
+	}
+	return tp.bindEvalColumns(bindvars, "a_", vals)
+}
+
 func (tp *TablePlan) applyChange(rowChange *binlogdatapb.RowChange, executor func(string) (*sqltypes.Result, error)) (*sqltypes.Result, error) {
 	// MakeRowTrusted is needed here because Proto3ToResult is not convenient.
+	// The images which do not match the filter of the plan are ignored: an update
+	// of a row which stops matching it, for example, is applied as a delete.
 	var before, after bool
+	var err error
 	bindvars := make(map[string]*querypb.BindVariable, len(tp.Fields))
 	if rowChange.Before != nil {
-		before = true
 		vals := sqltypes.MakeRowTrusted(tp.Fields, rowChange.Before)
-		for i, field := range tp.Fields {
-			bindVar, err := tp.bindFieldVal(field, &vals[i])
-			if err != nil {
+		if before, err = tp.matchesFilter(vals); err != nil {
+			return nil, err
+		}
+		if before {
+			for i, field := range tp.Fields {
+				bindVar, err := tp.bindFieldVal(field, &vals[i])
+				if err != nil {
+					return nil, err
+				}
+				bindvars["b_"+field.Name] = bindVar
+			}
+			if err := tp.bindEvalColumns(bindvars, "b_", vals); err != nil {
 				return nil, err
 			}
-			bindvars["b_"+field.Name] = bindVar
 		}
 	}
 	if rowChange.After != nil {
-		after = true
 		vals := sqltypes.MakeRowTrusted(tp.Fields, rowChange.After)
-		for i, field := range tp.Fields {
-			var bindVar *querypb.BindVariable
-			var newVal *sqltypes.Value
-			var err error
-			if field.Type == querypb.Type_JSON {
-				if vals[i].IsNull() { // An SQL NULL and not an actual JSON value
-					newVal = &sqltypes.NULL
-				} else { // A JSON value (which may be a JSON null literal value)
-					newVal, err = vjson.MarshalSQLValue(vals[i].Raw())
-					if err != nil {
-						return nil, err
-					}
-				}
-				bindVar, err = tp.bindFieldVal(field, newVal)
-			} else {
-				bindVar, err = tp.bindFieldVal(field, &vals[i])
-			}
-			if err != nil {
+		if after, err = tp.matchesFilter(vals); err != nil {
+			return nil, err
+		}
+		if after {
+			if err := tp.bindAfterValues(bindvars, vals); err != nil {
 				return nil, err
 			}
-			bindvars["a_"+field.Name] = bindVar
 		}
 	}
 	switch {
@@ -440,7 +564,7 @@ func (tp *TablePlan) applyChange(rowChange *binlogdatapb.RowChange, executor fun
 		}
 		return execParsedQuery(tp.Insert, bindvars, executor)
 	}
-	// Unreachable.
+	// Neither image matches the filter of the plan.
 	return nil, nil
 }
 
@@ -21,12 +21,16 @@ import (
 	"strings"
 	"testing"
 
+	"vitess.io/vitess/go/bytes2"
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"vitess.io/vitess/go/sqltypes"
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
 )
 
 type TestReplicatorPlan struct {
@@ -414,6 +418,104 @@ func TestBuildPlayerPlan(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		// the conditions the source does not support are evaluated on the target,
+		// and the expressions of the columns by the statements applied to it
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select c1, upper(c2) as c2 from t1 where in_keyrange(c1, 'hash', '-80') and c3 = 1 and length(c2) > 3",
+			}},
+		},
+		plan: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t1",
+					Filter: "select c1, c2, c2 from t1 where in_keyrange(c1, 'hash', '-80') and c3 = 1",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"t1": {
+					TargetName:   "t1",
+					SendRule:     "t1",
+					PKReferences: []string{"c1"},
+					InsertFront:  "insert into t1(c1,c2)",
+					InsertValues: "(:a_c1,upper(:a_c2))",
+					Insert:       "insert into t1(c1,c2) values (:a_c1,upper(:a_c2))",
+					Update:       "update t1 set c2=upper(:a_c2) where c1=:b_c1",
+					Delete:       "delete from t1 where c1=:b_c1",
+				},
+			},
+		},
+		planpk: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t1",
+					Filter: "select c1, c2, pk1, pk2, c2 from t1 where in_keyrange(c1, 'hash', '-80') and c3 = 1",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"t1": {
+					TargetName:   "t1",
+					SendRule:     "t1",
+					PKReferences: []string{"c1", "pk1", "pk2"},
+					InsertFront:  "insert into t1(c1,c2)",
+					InsertValues: "(:a_c1,upper(:a_c2))",
+					Insert:       "insert into t1(c1,c2) select :a_c1, upper(:a_c2) from dual where (:a_pk1,:a_pk2) <= (1,'aaa')",
+					Update:       "update t1 set c2=upper(:a_c2) where c1=:b_c1 and (:b_pk1,:b_pk2) <= (1,'aaa')",
+					Delete:       "delete from t1 where c1=:b_c1 and (:b_pk1,:b_pk2) <= (1,'aaa')",
+				},
+			},
+		},
+	}, {
+		// the conditions the source does not support are evaluated on the target, with select *
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select * from t1 where in_keyrange('-80') and json_extract(c2, '$.a') = 1",
+			}},
+		},
+		plan: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t1",
+					Filter: "select * from t1 where in_keyrange('-80')",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"t1": {
+					TargetName: "t1",
+					SendRule:   "t1",
+				},
+			},
+		},
+		planpk: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t1",
+					Filter: "select * from t1 where in_keyrange('-80')",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"t1": {
+					TargetName: "t1",
+					SendRule:   "t1",
+				},
+			},
+		},
+	}, {
+		// condition not supported by the evalengine
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select * from t1 where c1 in (select c1 from t2)",
+			}},
+		},
+		err: "unsupported constraint: c1 in (select c1 from t2)",
 	}, {
 		input: &binlogdatapb.Filter{
 			Rules: []*binlogdatapb.Rule{{
@@ -821,3 +923,113 @@ func TestBuildPlayerPlanExclude(t *testing.T) {
 	wantPlan, _ := json.Marshal(want)
 	assert.Equal(t, string(gotPlan), string(wantPlan))
 }
+
+func TestTablePlanEvaluation(t *testing.T) {
+	input := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match: "t1",
+			Filter: "select id, concat(upper(name), '!') as name, " +
+				"case when email like '%@example.com' then 'masked' else email end as email from t1 where id % 2 = 1",
+		}},
+	}
+	colInfos := map[string][]*ColumnInfo{
+		"t1": {{Name: "id", IsPK: true}, {Name: "name"}, {Name: "email"}},
+	}
+	testcases := []struct {
+		name string
+		// evaluate evaluates the expressions of the columns with the evalengine.
+		// The conditions the source does not support always are.
+		evaluate    bool
+		wantChanges []string
+		wantBulk    []string
+	}{{
+		name: "expressions evaluated by the target",
+		wantChanges: []string{
+			"insert into t1(id,`name`,email) values (1,concat(upper('ann'), '!'),case when 'ann@example.com' like '%@example.com' then 'masked' else 'ann@example.com' end)",
+			"update t1 set `name`=concat(upper('bob'), '!'), email=case when 'bob@example.org' like '%@example.com' then 'masked' else 'bob@example.org' end where id=1",
+			"delete from t1 where id=1",
+			"insert into t1(id,`name`,email) values (3,concat(upper('bob'), '!'),case when 'bob@example.org' like '%@example.com' then 'masked' else 'bob@example.org' end)",
+			"delete from t1 where id=3",
+		},
+		wantBulk: []string{
+			"insert into t1(id,`name`,email) values (1,concat(upper('ann'), '!'),case when 'ann@example.com' like '%@example.com' then 'masked' else 'ann@example.com' end), " +
+				"(3,concat(upper('bob'), '!'),case when 'bob@example.org' like '%@example.com' then 'masked' else 'bob@example.org' end)",
+		},
+	}, {
+		name:     "expressions evaluated by the evalengine",
+		evaluate: true,
+		wantChanges: []string{
+			"insert into t1(id,`name`,email) values (1,'ANN!','masked')",
+			"update t1 set `name`='BOB!', email='bob@example.org' where id=1",
+			"delete from t1 where id=1",
+			"insert into t1(id,`name`,email) values (3,'BOB!','bob@example.org')",
+			"delete from t1 where id=3",
+		},
+		wantBulk: []string{
+			"insert into t1(id,`name`,email) values (1,'ANN!','masked'), (3,'BOB!','bob@example.org')",
+		},
+	}}
+	for _, tcase := range testcases {
+		t.Run(tcase.name, func(t *testing.T) {
+			source := getSource(input)
+			source.EvaluateExpressions = tcase.evaluate
+			plan, err := buildReplicatorPlan(source, colInfos, nil, binlogplayer.NewStats())
+			require.NoError(t, err)
+			sendFilter := plan.TablePlans["t1"].SendRule.Filter
+			require.Equal(t, "select id, `name`, email, email, id from t1", sendFilter)
+
+			// The fields are the columns of the send query.
+			stmt, err := sqlparser.Parse(sendFilter)
+			require.NoError(t, err)
+			var fields []*querypb.Field
+			for _, expr := range stmt.(*sqlparser.Select).SelectExprs {
+				name := expr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName).Name.String()
+				if name == "id" {
+					fields = append(fields, &querypb.Field{Name: name, Type: querypb.Type_INT64})
+				} else {
+					fields = append(fields, &querypb.Field{Name: name, Type: querypb.Type_VARCHAR, Charset: 255})
+				}
+			}
+			tplan, err := plan.buildExecutionPlan(&binlogdatapb.FieldEvent{TableName: "t1", Fields: fields})
+			require.NoError(t, err)
+
+			row := func(id int64, name, email string) *querypb.Row {
+				values := map[string]sqltypes.Value{"id": sqltypes.NewInt64(id), "name": sqltypes.NewVarChar(name), "email": sqltypes.NewVarChar(email)}
+				var vals []sqltypes.Value
+				for _, field := range fields {
+					vals = append(vals, values[field.Name])
+				}
+				return sqltypes.RowToProto3(vals)
+			}
+			var queries []string
+			executor := func(sql string) (*sqltypes.Result, error) {
+				queries = append(queries, sql)
+				return &sqltypes.Result{}, nil
+			}
+
+			changes := []*binlogdatapb.RowChange{
+				{After: row(1, "ann", "ann@example.com")},
+				// the rows which do not match the filter are not applied
+				{After: row(2, "joe", "joe@example.com")},
+				{Before: row(1, "ann", "ann@example.com"), After: row(1, "bob", "bob@example.org")},
+				// a row which stops matching the filter is deleted, and a row which starts matching it is inserted
+				{Before: row(1, "bob", "bob@example.org"), After: row(2, "bob", "bob@example.org")},
+				{Before: row(2, "bob", "bob@example.org"), After: row(3, "bob", "bob@example.org")},
+				{Before: row(3, "bob", "bob@example.org")},
+			}
+			for _, change := range changes {
+				_, err := tplan.applyChange(change, executor)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tcase.wantChanges, queries)
+
+			queries = nil
+			var sqlbuffer bytes2.Buffer
+			_, err = tplan.applyBulkInsert(&sqlbuffer, []*querypb.Row{row(1, "ann", "ann@example.com"), row(2, "joe", "joe@example.com"), row(3, "bob", "bob@example.org")}, executor)
+			require.NoError(t, err)
+			_, err = tplan.applyBulkInsert(&sqlbuffer, []*querypb.Row{row(4, "joe", "joe@example.com")}, executor)
+			require.NoError(t, err)
+			assert.Equal(t, tcase.wantBulk, queries)
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"sort"
 	"strings"
 
+	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/textutil"
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
@@ -29,6 +30,7 @@ import (
 	"vitess.io/vitess/go/vt/schema"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
@@ -59,6 +61,9 @@ type tablePlanBuilder struct {
 	stats             *binlogplayer.Stats
 	source            *binlogdatapb.BinlogSource
 	pkIndices         []bool
+	// targetFilter is the part of the where clause that the source
+	// cannot apply. It's evaluated on the rows sent by the source.
+	targetFilter sqlparser.Expr
 }
 
 // colExpr describes the processing to be performed to
@@ -73,6 +78,9 @@ type colExpr struct {
 	// expr stores the expected field name from vstreamer and dictates
 	// the generated bindvar names, like a_col or b_col.
 	expr sqlparser.Expr
+	// evalExpr is set if the value of the column is computed by the
+	// evalengine. If so, expr is the column of the bindvar of the value.
+	evalExpr sqlparser.Expr
 	// references contains all the column names referenced in the expression.
 	references map[string]bool
 
@@ -92,6 +100,10 @@ const (
 	opSum
 )
 
+// evalColumnPrefix prefixes the names of the bindvars of the values
+// computed by the evalengine, like a__vt_eval_col or b__vt_eval_col.
+const evalColumnPrefix = "_vt_eval_"
+
 // insertType describes the type of insert statement to generate.
 // Please refer to TestBuildPlayerPlan for examples.
 type insertType int
@@ -219,6 +231,10 @@ func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*Colum
 	sendRule := &binlogdatapb.Rule{
 		Match: fromTable,
 	}
+	sourceWhere, targetFilter, err := splitWhere(sel.Where)
+	if err != nil {
+		return nil, err
+	}
 
 	enumValuesMap := map[string](map[string]string){}
 	for k, v := range rule.ConvertEnumToText {
@@ -236,6 +252,10 @@ func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*Colum
 			return nil, fmt.Errorf("unsupported qualifier for '*' expression: %v", sqlparser.String(expr))
 		}
 		sendRule.Filter = query
+		if targetFilter != nil {
+			sel.Where = sourceWhere
+			sendRule.Filter = sqlparser.String(sel)
+		}
 		tablePlan := &TablePlan{
 			TargetName:       tableName,
 			SendRule:         sendRule,
@@ -244,6 +264,7 @@ func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*Colum
 			EnumValuesMap:    enumValuesMap,
 			ConvertCharset:   rule.ConvertCharset,
 			ConvertIntToEnum: rule.ConvertIntToEnum,
+			targetFilter:     targetFilter,
 		}
 
 		return tablePlan, nil
@@ -253,12 +274,13 @@ func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*Colum
 		name: sqlparser.NewIdentifierCS(tableName),
 		sendSelect: &sqlparser.Select{
 			From:  sel.From,
-			Where: sel.Where,
+			Where: sourceWhere,
 		},
-		lastpk:   lastpk,
-		colInfos: colInfos,
-		stats:    stats,
-		source:   source,
+		lastpk:       lastpk,
+		colInfos:     colInfos,
+		stats:        stats,
+		source:       source,
+		targetFilter: targetFilter,
 	}
 
 	if err := tpb.analyzeExprs(sel.SelectExprs); err != nil {
@@ -276,6 +298,9 @@ func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*Colum
 			tpb.addCol(sqlparser.NewIdentifierCI(f.Name))
 		}
 	}
+	if err := tpb.analyzeTargetFilter(); err != nil {
+		return nil, err
+	}
 	if err := tpb.analyzeGroupBy(sel.GroupBy); err != nil {
 		return nil, err
 	}
@@ -517,9 +542,150 @@ func (tpb *tablePlanBuilder) analyzeExpr(selExpr sqlparser.SelectExpr) (*colExpr
 		return nil, err
 	}
 	cexpr.expr = aliased.Expr
+	// Expressions computed from the columns are evaluated by the target, unless the
+	// workflow evaluates them with the evalengine and it supports them. The rows applied
+	// to a sink have no target to evaluate them.
+	evaluate := tpb.source.GetEvaluateExpressions() || tpb.source.GetSink() != ""
+	if _, ok := aliased.Expr.(*sqlparser.ColName); !ok && len(cexpr.references) > 0 && evaluate && canEvaluate(aliased.Expr) {
+		cexpr.evalExpr = aliased.Expr
+		cexpr.expr = &sqlparser.ColName{Name: sqlparser.NewIdentifierCI(evalColumnPrefix + as.String())}
+	}
 	return cexpr, nil
 }
 
+// splitWhere splits the where clause of a rule into the conditions sent to the source,
+// which are the ones the vstreamer supports, and the other conditions, which are
+// evaluated by the evalengine on the rows sent by the source.
+func splitWhere(where *sqlparser.Where) (*sqlparser.Where, sqlparser.Expr, error) {
+	if where == nil {
+		return nil, nil, nil
+	}
+	var sourceExprs, targetExprs []sqlparser.Expr
+	for _, expr := range sqlparser.SplitAndExpression(nil, where.Expr) {
+		if isSourceFilter(expr) {
+			sourceExprs = append(sourceExprs, expr)
+			continue
+		}
+		if !canEvaluate(expr) {
+			return nil, nil, fmt.Errorf("unsupported constraint: %v", sqlparser.String(expr))
+		}
+		targetExprs = append(targetExprs, expr)
+	}
+	if len(targetExprs) == 0 {
+		return where, nil, nil
+	}
+	return sqlparser.NewWhere(sqlparser.WhereClause, sqlparser.AndExpressions(sourceExprs...)), sqlparser.AndExpressions(targetExprs...), nil
+}
+
+// isSourceFilter returns true if the condition is applied by the vstreamer:
+// in_keyrange, the comparison of a column with an integer or a string,
+// and a column being not null.
+func isSourceFilter(expr sqlparser.Expr) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.FuncExpr:
+		return expr.Name.EqualString("in_keyrange")
+	case *sqlparser.ComparisonExpr:
+		switch expr.Operator {
+		case sqlparser.EqualOp, sqlparser.LessThanOp, sqlparser.LessEqualOp, sqlparser.GreaterThanOp, sqlparser.GreaterEqualOp, sqlparser.NotEqualOp:
+		default:
+			return false
+		}
+		if col, ok := expr.Left.(*sqlparser.ColName); !ok || !col.Qualifier.IsEmpty() {
+			return false
+		}
+		val, ok := expr.Right.(*sqlparser.Literal)
+		return ok && (val.Type == sqlparser.IntVal || val.Type == sqlparser.StrVal)
+	case *sqlparser.IsExpr:
+		col, ok := expr.Left.(*sqlparser.ColName)
+		return ok && col.Qualifier.IsEmpty() && expr.Right == sqlparser.IsNotNullOp
+	}
+	return false
+}
+
+// canEvaluate returns true if the evalengine supports the expression.
+// The columns are resolved once the fields are known, by translateExprs.
+func canEvaluate(expr sqlparser.Expr) bool {
+	_, err := evalengine.Translate(expr, &evalengine.Config{
+		ResolveColumn: func(*sqlparser.ColName) (int, error) {
+			return 0, nil
+		},
+	})
+	return err == nil
+}
+
+// analyzeTargetFilter adds the columns referenced by the target filter to the send query.
+func (tpb *tablePlanBuilder) analyzeTargetFilter() error {
+	if tpb.targetFilter == nil {
+		return nil
+	}
+	return sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.ColName:
+			if !node.Qualifier.IsEmpty() {
+				return false, fmt.Errorf("unsupported qualifier for column: %v", sqlparser.String(node))
+			}
+			tpb.addCol(node.Name)
+		case *sqlparser.Subquery:
+			return false, fmt.Errorf("unsupported subquery: %v", sqlparser.String(node))
+		}
+		return true, nil
+	}, tpb.targetFilter)
+}
+
+// translateExprs translates the expressions evaluated by the evalengine, resolving
+// their columns with the fields of the rows sent by the source.
+func translateExprs(tpb *tablePlanBuilder, fields []*querypb.Field) (filter evalengine.Expr, evalColumns map[string]evalengine.Expr, err error) {
+	cfg := fieldsConfig(fields)
+	if tpb.targetFilter != nil {
+		filter, err = evalengine.Translate(tpb.targetFilter, cfg)
+		if err != nil {
+			return nil, nil, vterrors.Wrapf(err, "cannot evaluate %v", sqlparser.String(tpb.targetFilter))
+		}
+	}
+	for _, cexpr := range tpb.colExprs {
+		if cexpr.evalExpr == nil {
+			continue
+		}
+		expr, err := evalengine.Translate(cexpr.evalExpr, cfg)
+		if err != nil {
+			return nil, nil, vterrors.Wrapf(err, "cannot evaluate %v", sqlparser.String(cexpr.evalExpr))
+		}
+		if evalColumns == nil {
+			evalColumns = make(map[string]evalengine.Expr)
+		}
+		evalColumns[evalColumnPrefix+cexpr.colName.String()] = expr
+	}
+	return filter, evalColumns, nil
+}
+
+// fieldsConfig returns the configuration translating the expressions
+// whose columns are resolved to the fields of the rows.
+func fieldsConfig(fields []*querypb.Field) *evalengine.Config {
+	return &evalengine.Config{
+		ResolveColumn: func(col *sqlparser.ColName) (int, error) {
+			for i, field := range fields {
+				if col.Name.EqualString(field.Name) {
+					return i, nil
+				}
+			}
+			return 0, fmt.Errorf("column %v not found in the fields sent by the source", sqlparser.String(col))
+		},
+		ResolveType: func(expr sqlparser.Expr) (evalengine.Type, bool) {
+			col, ok := expr.(*sqlparser.ColName)
+			if !ok {
+				return evalengine.UnknownType(), false
+			}
+			for _, field := range fields {
+				if col.Name.EqualString(field.Name) {
+					return evalengine.Type{Type: field.Type, Coll: collations.ID(field.Charset), Nullable: true}, true
+				}
+			}
+			return evalengine.UnknownType(), false
+		},
+		Collation: applyCollation,
+	}
+}
+
 // addCol adds the specified column to the send query
 // if it's not already present.
 func (tpb *tablePlanBuilder) addCol(ident sqlparser.IdentifierCI) {
@@ -138,6 +138,8 @@ type vcopierCopyWorker struct {
 	pkfields        []*querypb.Field
 	sqlbuffer       bytes2.Buffer
 	tablePlan       *TablePlan
+	// applier applies the rows instead of the target database, if set.
+	applier *applierSink
 }
 
 func newVCopier(vr *vreplicator) *vcopier {
@@ -408,7 +410,7 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 	copyStateGCTicker := time.NewTicker(copyStateGCInterval)
 	defer copyStateGCTicker.Stop()
 
-	parallelism := getInsertParallelism()
+	parallelism := vc.getInsertParallelism()
 	copyWorkerFactory := vc.newCopyWorkerFactory(parallelism)
 	copyWorkQueue := vc.newCopyWorkQueue(parallelism, copyWorkerFactory)
 	defer copyWorkQueue.close()
@@ -731,10 +733,12 @@ func (vc *vcopier) newCopyWorkerFactory(parallelism int) func(context.Context) (
 		}
 	}
 	return func(_ context.Context) (*vcopierCopyWorker, error) {
-		return newVCopierCopyWorker(
+		worker := newVCopierCopyWorker(
 			false, /* close db client */
 			vc.vr.dbClient,
-		), nil
+		)
+		worker.applier = vc.vr.applier
+		return worker, nil
 	}
 }
 
@@ -1084,7 +1088,13 @@ func (vbc *vcopierCopyWorker) execute(ctx context.Context, task *vcopierCopyTask
 				return nil
 			}
 		case vcopierCopyTaskCommit:
-			advanceFn = func(context.Context, *vcopierCopyTaskArgs) error {
+			advanceFn = func(ctx context.Context, _ *vcopierCopyTaskArgs) error {
+				// The rows are committed by the applier before the copy state.
+				if vbc.applier != nil {
+					if err := vbc.applier.commit(ctx); err != nil {
+						return err
+					}
+				}
 				// Commit.
 				if err := vbc.vdbClient.Commit(); err != nil {
 					return vterrors.Wrapf(err, "error commiting transaction")
@@ -1149,6 +1159,12 @@ func (vbc *vcopierCopyWorker) insertCopyState(ctx context.Context, lastpk *query
 }
 
 func (vbc *vcopierCopyWorker) insertRows(ctx context.Context, rows []*querypb.Row) (*sqltypes.Result, error) {
+	if vbc.applier != nil {
+		if err := vbc.applier.applyRows(ctx, vbc.tablePlan, rows); err != nil {
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
+	}
 	return vbc.tablePlan.applyBulkInsert(
 		&vbc.sqlbuffer,
 		rows,
@@ -1206,7 +1222,11 @@ func vcopierCopyTaskGetNextState(vts vcopierCopyTaskState) vcopierCopyTaskState
 }
 
 // getInsertParallelism returns the number of parallel workers to use for inserting batches during the copy phase.
-func getInsertParallelism() int {
+// The rows applied by an applier are inserted by a single worker.
+func (vc *vcopier) getInsertParallelism() int {
+	if vc.vr.applier != nil {
+		return 1
+	}
 	parallelism := int(math.Max(1, float64(vreplicationParallelInsertWorkers)))
 	return parallelism
 }
@@ -79,6 +79,16 @@ func (vc *vdbClient) Rollback() error {
 	return nil
 }
 
+// Discard rolls back the transaction, and forgets its queries so that they are
+// not retried along with the next one.
+func (vc *vdbClient) Discard() error {
+	if err := vc.Rollback(); err != nil {
+		return err
+	}
+	vc.queries = nil
+	return nil
+}
+
 func (vc *vdbClient) ExecuteFetch(query string, maxrows int) (*sqltypes.Result, error) {
 	defer vc.stats.Timings.Record(binlogplayer.BlplQuery, time.Now())
 
@@ -78,6 +78,9 @@ type vplayer struct {
 	// foreignKeyChecksStateInitialized is set to true once we have initialized the foreignKeyChecksEnabled.
 	// The initialization is done on the first row event that this vplayer sees.
 	foreignKeyChecksStateInitialized bool
+
+	// parallelApplier is set when the transactions are applied by parallel workers.
+	parallelApplier *parallelApplier
 }
 
 // NoForeignKeyCheckFlagBitmask is the bitmask for the 2nd bit (least significant) of the flags in a binlog row event.
@@ -248,6 +251,9 @@ func (vp *vplayer) applyStmtEvent(ctx context.Context, event *binlogdatapb.VEven
 	if sql == "" {
 		sql = event.Dml
 	}
+	if vp.vr.applier != nil && event.Type != binlogdatapb.VEventType_SAVEPOINT {
+		return fmt.Errorf("statement based replication is not supported by the applier of workflow %s", vp.vr.WorkflowName)
+	}
 	if event.Type == binlogdatapb.VEventType_SAVEPOINT || vp.canAcceptStmtEvents {
 		start := time.Now()
 		_, err := vp.vr.dbClient.ExecuteWithRetry(ctx, sql)
@@ -266,11 +272,19 @@ func (vp *vplayer) applyRowEvent(ctx context.Context, rowEvent *binlogdatapb.Row
 	if tplan == nil {
 		return fmt.Errorf("unexpected event on table %s", rowEvent.TableName)
 	}
+	if vp.vr.applier != nil {
+		return vp.vr.applier.applyRowEvent(ctx, tplan, rowEvent)
+	}
+	return vp.applyRowChanges(ctx, vp.vr.dbClient, tplan, rowEvent)
+}
+
+// applyRowChanges applies the changes of a row event with the table plan, on the given connection.
+func (vp *vplayer) applyRowChanges(ctx context.Context, dbClient *vdbClient, tplan *TablePlan, rowEvent *binlogdatapb.RowEvent) error {
 	for _, change := range rowEvent.RowChanges {
 		_, err := tplan.applyChange(change, func(sql string) (*sqltypes.Result, error) {
 			stats := NewVrLogStats("ROWCHANGE")
 			start := time.Now()
-			qr, err := vp.vr.dbClient.ExecuteWithRetry(ctx, sql)
+			qr, err := dbClient.ExecuteWithRetry(ctx, sql)
 			vp.vr.stats.QueryCount.Add(vp.phase, 1)
 			vp.vr.stats.QueryTimings.Record(vp.phase, start)
 			stats.Send(sql)
@@ -375,6 +389,17 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 	// can estimate this value more accurately.
 	defer vp.vr.stats.ReplicationLagSeconds.Store(math.MaxInt64)
 	defer vp.vr.stats.VReplicationLags.Add(strconv.Itoa(int(vp.vr.id)), math.MaxInt64)
+
+	// The commits of the changes read from a stream are acknowledged by the serial player.
+	if vreplicationParallelApplyWorkers > 1 && vp.copyState == nil && vp.stopPos.IsZero() && vp.vr.applier == nil && vp.vr.source.SourceStream == "" {
+		pa, err := newParallelApplier(ctx, vp, vreplicationParallelApplyWorkers)
+		if err != nil {
+			return err
+		}
+		defer pa.close()
+		vp.parallelApplier = pa
+	}
+
 	var sbm int64 = -1
 	for {
 		if ctx.Err() != nil {
@@ -404,6 +429,12 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 		// In both cases, now > timeLastSaved. If so, the GTID of the last unsavedEvent
 		// must be saved.
 		if time.Since(vp.timeLastSaved) >= idleTimeout && vp.unsavedEvent != nil {
+			// The position of the transactions applied by the parallel workers must be saved first.
+			if vp.parallelApplier != nil {
+				if err := vp.parallelApplier.drain(); err != nil {
+					return err
+				}
+			}
 			posReached, err := vp.updatePos(vp.unsavedEvent.Timestamp)
 			if err != nil {
 				return err
@@ -435,11 +466,18 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 					// applying the next set of events as part of the current transaction. This approach
 					// also handles the case where the last transaction is partial. In that case,
 					// we only group the transactions with commits we've seen so far.
-					if hasAnotherCommit(items, i, j+1) {
+					// The transactions applied by parallel workers are not grouped, to be applied concurrently.
+					if vp.parallelApplier == nil && hasAnotherCommit(items, i, j+1) {
 						continue
 					}
 				}
-				if err := vp.applyEvent(ctx, event, mustSave); err != nil {
+				var err error
+				if vp.parallelApplier != nil {
+					err = vp.parallelApplier.applyEvent(ctx, event, mustSave)
+				} else {
+					err = vp.applyEvent(ctx, event, mustSave)
+				}
+				if err != nil {
 					if err != io.EOF {
 						vp.vr.stats.ErrorCounts.Add([]string{"Apply"}, 1)
 						log.Errorf("Error applying event: %s", err.Error())
@@ -503,6 +541,12 @@ func (vp *vplayer) applyEvent(ctx context.Context, event *binlogdatapb.VEvent, m
 			vp.unsavedEvent = event
 			return nil
 		}
+		// The changes are committed by the applier before the position is saved.
+		if vp.vr.applier != nil {
+			if err := vp.vr.applier.commit(ctx); err != nil {
+				return err
+			}
+		}
 		posReached, err := vp.updatePos(event.Timestamp)
 		if err != nil {
 			return err
@@ -510,6 +554,10 @@ func (vp *vplayer) applyEvent(ctx context.Context, event *binlogdatapb.VEvent, m
 		if err := vp.vr.dbClient.Commit(); err != nil {
 			return err
 		}
+		// The workflow feeding the stream waits until the changes are committed.
+		if sc, ok := vp.vr.sourceVStreamer.(*streamConnector); ok {
+			sc.committed(vp.pos)
+		}
 		if posReached {
 			return io.EOF
 		}
@@ -0,0 +1,499 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql/replication"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/log"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// Parallel apply: during the replication phase, the transactions of a stream can be applied by parallel
+// workers, each one with its own connection. The vplayer sends them the transactions made of row events,
+// and the transactions changing rows with the same primary key are applied one after the other, while the
+// others are applied concurrently. The transactions are committed in the order of the source, along with
+// their position: the position saved in _vt.vreplication is the position of the last transaction committed,
+// and all the transactions before it are committed as well.
+//
+// A transaction which cannot be applied concurrently with the ones before it, for instance because it
+// conflicts with them on a unique secondary key, is rolled back and applied again once they are committed.
+// The transactions with statements or changing tables without a primary key, as well as the other events
+// saving the position (DDL, OTHER and JOURNAL), are applied by the vplayer itself once all the transactions
+// before them are committed.
+
+// parallelApplyCommitWait is how long a transaction applied by a worker holds its locks while waiting for
+// the transactions before it to be committed. Since one of them may be waiting for these locks, the
+// transaction is rolled back after that, and applied again once they are committed.
+var parallelApplyCommitWait = time.Second
+
+var errParallelApplyCommitWait = errors.New("timed out waiting for the previous transactions to be committed")
+
+// parallelApplier sends the transactions of the vplayer to the parallel workers.
+type parallelApplier struct {
+	vp *vplayer
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	txs    chan *parallelTx
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+
+	// The fields below are only used by the vplayer.
+
+	// pending is the transaction being received, and serial is set
+	// if it is applied by the vplayer instead of the workers.
+	pending *parallelTx
+	serial  bool
+	// seq numbers the transactions sent to the workers, last is the last one
+	// sent, and writers the last one sent changing each row.
+	seq     int64
+	last    *parallelTx
+	writers map[string]*parallelTx
+}
+
+// parallelTx is a transaction applied by a worker.
+type parallelTx struct {
+	// seq is the order in which the transactions are sent to the workers.
+	seq     int64
+	changes []parallelRowEvent
+	keys    []string
+
+	pos       replication.Position
+	timestamp int64
+
+	// prev is the transaction committed before this one, and after the last transaction sent
+	// before this one changing the same rows, which has to be committed before this one is applied.
+	// They are reset once the transaction is committed.
+	prev  *parallelTx
+	after *parallelTx
+	// done is closed once the transaction is committed.
+	done chan struct{}
+}
+
+type parallelRowEvent struct {
+	tplan    *TablePlan
+	rowEvent *binlogdatapb.RowEvent
+}
+
+// parallelWorker applies transactions on its own connection.
+type parallelWorker struct {
+	dbClient *vdbClient
+	// foreignKeyChecksEnabled is the state of the foreign key checks of the
+	// connection, which are disabled when the connection is created.
+	foreignKeyChecksEnabled bool
+}
+
+func newParallelApplier(ctx context.Context, vp *vplayer, workers int) (*parallelApplier, error) {
+	pa := &parallelApplier{
+		vp:      vp,
+		txs:     make(chan *parallelTx, workers),
+		writers: make(map[string]*parallelTx),
+	}
+	pa.ctx, pa.cancel = context.WithCancel(ctx)
+
+	var parallelWorkers []*parallelWorker
+	for i := 0; i < workers; i++ {
+		dbClient, err := vp.vr.newClientConnection(ctx)
+		if err == nil {
+			err = setApplySessionVars(dbClient)
+		}
+		if err != nil {
+			if dbClient != nil {
+				dbClient.Close()
+			}
+			for _, w := range parallelWorkers {
+				w.dbClient.Close()
+			}
+			pa.cancel()
+			return nil, fmt.Errorf("failed to create the connection of a parallel apply worker: %v", err)
+		}
+		parallelWorkers = append(parallelWorkers, &parallelWorker{dbClient: dbClient})
+	}
+	log.Infof("VReplication stream %v applies its transactions with %v parallel workers", vp.vr.id, workers)
+
+	for _, w := range parallelWorkers {
+		pa.wg.Add(1)
+		go pa.work(w)
+	}
+	return pa, nil
+}
+
+// close stops the workers. The transactions which are not committed yet are rolled back.
+func (pa *parallelApplier) close() {
+	pa.cancel()
+	pa.wg.Wait()
+}
+
+// fail stops the workers on the first error of a transaction.
+func (pa *parallelApplier) fail(err error) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	if pa.err == nil {
+		pa.err = err
+		pa.cancel()
+	}
+}
+
+// error returns the error which stopped the workers, if they are stopped.
+func (pa *parallelApplier) error() error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	if pa.err != nil {
+		return pa.err
+	}
+	return pa.ctx.Err()
+}
+
+// applyEvent applies an event of the stream: the row events of the transactions are sent to the workers,
+// and the other events are applied by the vplayer.
+func (pa *parallelApplier) applyEvent(ctx context.Context, event *binlogdatapb.VEvent, mustSave bool) error {
+	if err := pa.error(); err != nil {
+		return err
+	}
+	vp := pa.vp
+	switch event.Type {
+	case binlogdatapb.VEventType_FIELD:
+		if pa.serial {
+			break
+		}
+		tplan, err := vp.replicatorPlan.buildExecutionPlan(event.FieldEvent)
+		if err != nil {
+			return err
+		}
+		vp.tablePlans[event.FieldEvent.TableName] = tplan
+		return nil
+	case binlogdatapb.VEventType_ROW:
+		if pa.serial {
+			break
+		}
+		tplan := vp.tablePlans[event.RowEvent.TableName]
+		if tplan == nil {
+			return fmt.Errorf("unexpected event on table %s", event.RowEvent.TableName)
+		}
+		if keys, ok := rowEventKeys(tplan, event.RowEvent); ok {
+			if pa.pending == nil {
+				pa.pending = &parallelTx{}
+			}
+			pa.pending.changes = append(pa.pending.changes, parallelRowEvent{tplan: tplan, rowEvent: event.RowEvent})
+			pa.pending.keys = append(pa.pending.keys, keys...)
+			return nil
+		}
+		if err := pa.applySerially(ctx); err != nil {
+			return err
+		}
+	case binlogdatapb.VEventType_INSERT, binlogdatapb.VEventType_DELETE, binlogdatapb.VEventType_UPDATE,
+		binlogdatapb.VEventType_REPLACE, binlogdatapb.VEventType_SAVEPOINT:
+		if !pa.serial {
+			if err := pa.applySerially(ctx); err != nil {
+				return err
+			}
+		}
+	case binlogdatapb.VEventType_COMMIT:
+		tx := pa.pending
+		pa.pending = nil
+		if pa.serial || tx == nil {
+			// The transaction applied by the vplayer is committed, or the empty transaction skipped.
+			pa.serial = false
+			break
+		}
+		tx.pos = vp.pos
+		tx.timestamp = event.Timestamp
+		if err := pa.send(tx); err != nil {
+			return err
+		}
+		vp.unsavedEvent = nil
+		vp.timeLastSaved = time.Now()
+		return nil
+	case binlogdatapb.VEventType_DDL, binlogdatapb.VEventType_OTHER, binlogdatapb.VEventType_JOURNAL:
+		// These events save the position with the connection of the vplayer.
+		if err := pa.drain(); err != nil {
+			return err
+		}
+	}
+	return vp.applyEvent(ctx, event, mustSave)
+}
+
+// applySerially makes the vplayer apply the transaction being received, once all the transactions
+// sent to the workers are committed. The row events received so far are applied first.
+func (pa *parallelApplier) applySerially(ctx context.Context) error {
+	pa.serial = true
+	if err := pa.drain(); err != nil {
+		return err
+	}
+	tx := pa.pending
+	pa.pending = nil
+	if tx == nil {
+		return nil
+	}
+	vp := pa.vp
+	if err := vp.vr.dbClient.Begin(); err != nil {
+		return err
+	}
+	for _, change := range tx.changes {
+		if err := vp.updateFKCheck(ctx, change.rowEvent.Flags); err != nil {
+			return err
+		}
+		if err := vp.applyRowChanges(ctx, vp.vr.dbClient, change.tplan, change.rowEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a transaction to the workers, after the transactions changing the same rows.
+func (pa *parallelApplier) send(tx *parallelTx) error {
+	pa.seq++
+	tx.seq = pa.seq
+	tx.prev = pa.last
+	tx.done = make(chan struct{})
+	for _, key := range tx.keys {
+		// Since the transactions are committed in order, the last one changing
+		// the same rows as the transaction is the only one to wait for.
+		// A row changed twice by the transaction is already mapped to it.
+		if writer, ok := pa.writers[key]; ok && writer != tx && !writer.committed() && (tx.after == nil || writer.seq > tx.after.seq) {
+			tx.after = writer
+		}
+		pa.writers[key] = tx
+	}
+	tx.keys = nil
+
+	select {
+	case pa.txs <- tx:
+	case <-pa.ctx.Done():
+		return pa.error()
+	}
+	pa.last = tx
+
+	if len(pa.writers) > relayLogMaxItems {
+		for key, writer := range pa.writers {
+			if writer.committed() {
+				delete(pa.writers, key)
+			}
+		}
+	}
+	return nil
+}
+
+// drain waits until all the transactions sent to the workers are committed.
+func (pa *parallelApplier) drain() error {
+	if pa.last == nil {
+		return nil
+	}
+	select {
+	case <-pa.last.done:
+	case <-pa.ctx.Done():
+		return pa.error()
+	}
+	pa.last = nil
+	clear(pa.writers)
+	return nil
+}
+
+func (pa *parallelApplier) work(w *parallelWorker) {
+	defer pa.wg.Done()
+	defer w.dbClient.Close()
+	for {
+		select {
+		case <-pa.ctx.Done():
+			return
+		case tx := <-pa.txs:
+			if err := pa.apply(w, tx); err != nil {
+				if pa.ctx.Err() == nil {
+					pa.vp.vr.stats.ErrorCounts.Add([]string{"Apply"}, 1)
+					log.Errorf("Error applying transaction in parallel worker: %v", err)
+				}
+				pa.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// apply applies a transaction, and commits it with its position once the transactions before it are committed.
+func (pa *parallelApplier) apply(w *parallelWorker, tx *parallelTx) error {
+	defer w.dbClient.Rollback()
+
+	if tx.after != nil {
+		if err := pa.waitCommitted(tx.after, 0); err != nil {
+			return err
+		}
+	}
+	err := pa.execute(w, tx)
+	if err == nil && tx.prev != nil {
+		err = pa.waitCommitted(tx.prev, parallelApplyCommitWait)
+	}
+	if err != nil {
+		if pa.ctx.Err() != nil {
+			return pa.ctx.Err()
+		}
+		// The transaction is applied again once the transactions before it are committed,
+		// when it cannot conflict with them anymore.
+		log.V(2).Infof("Applying transaction at %v again after the previous ones: %v", tx.pos, err)
+		if err := w.dbClient.Discard(); err != nil {
+			return err
+		}
+		if tx.prev != nil {
+			if err := pa.waitCommitted(tx.prev, 0); err != nil {
+				return err
+			}
+		}
+		if err := pa.execute(w, tx); err != nil {
+			return err
+		}
+	}
+
+	vr := pa.vp.vr
+	update := binlogplayer.GenerateUpdatePos(vr.id, tx.pos, time.Now().Unix(), tx.timestamp, vr.stats.CopyRowCount.Get(), vreplicationStoreCompressedGTID)
+	if _, err := w.dbClient.Execute(update); err != nil {
+		return fmt.Errorf("error %v updating position", err)
+	}
+	if err := w.dbClient.Commit(); err != nil {
+		return err
+	}
+	vr.stats.SetLastPosition(tx.pos)
+	tx.prev, tx.after = nil, nil
+	close(tx.done)
+	return nil
+}
+
+// execute applies the row events of the transaction, without committing it.
+func (pa *parallelApplier) execute(w *parallelWorker, tx *parallelTx) error {
+	if err := w.dbClient.Begin(); err != nil {
+		return err
+	}
+	for _, change := range tx.changes {
+		if err := w.updateFKCheck(change.rowEvent.Flags); err != nil {
+			return err
+		}
+		if err := pa.vp.applyRowChanges(pa.ctx, w.dbClient, change.tplan, change.rowEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitCommitted waits until the transaction is committed. If timeout is not zero,
+// it returns errParallelApplyCommitWait if the transaction is not committed in time.
+func (pa *parallelApplier) waitCommitted(tx *parallelTx, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-tx.done:
+		return nil
+	case <-expired:
+		return errParallelApplyCommitWait
+	case <-pa.ctx.Done():
+		return pa.ctx.Err()
+	}
+}
+
+// updateFKCheck sets the foreign key checks of the connection as the row event flags require, like vplayer.updateFKCheck.
+func (w *parallelWorker) updateFKCheck(flags uint32) error {
+	enabled := flags&NoForeignKeyCheckFlagBitmask == 0
+	if enabled == w.foreignKeyChecksEnabled {
+		return nil
+	}
+	if _, err := w.dbClient.Execute("set @@session.foreign_key_checks=" + strconv.FormatBool(enabled)); err != nil {
+		return fmt.Errorf("failed to set session foreign_key_checks: %w", err)
+	}
+	w.foreignKeyChecksEnabled = enabled
+	return nil
+}
+
+func (tx *parallelTx) committed() bool {
+	select {
+	case <-tx.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// rowEventKeys returns the keys of the rows changed by the row event, made of the name of the target table and
+// the values of its primary key, before and after the changes. The values are compared as they are sent by the
+// source: two transactions changing the same row with values equal for the collation of the primary key are
+// applied concurrently, and the later one applied again if it waits for the other one.
+// It returns false if the changes cannot be applied concurrently with the other ones: if the target table
+// has no primary key, or if the row images are partial.
+func rowEventKeys(tplan *TablePlan, rowEvent *binlogdatapb.RowEvent) ([]string, bool) {
+	if len(tplan.PKReferences) == 0 {
+		return nil, false
+	}
+	pkIndexes := make([]int, 0, len(tplan.PKReferences))
+	for _, pkref := range tplan.PKReferences {
+		idx := -1
+		for i, field := range tplan.Fields {
+			if field.Name == pkref {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, false
+		}
+		pkIndexes = append(pkIndexes, idx)
+	}
+
+	keys := make([]string, 0, len(rowEvent.RowChanges))
+	for _, change := range rowEvent.RowChanges {
+		if tplan.isPartial(change) {
+			return nil, false
+		}
+		for _, row := range []*querypb.Row{change.Before, change.After} {
+			if row == nil {
+				continue
+			}
+			keys = append(keys, rowKey(tplan.TargetName, sqltypes.MakeRowTrusted(tplan.Fields, row), pkIndexes))
+		}
+	}
+	return keys, true
+}
+
+func rowKey(table string, row []sqltypes.Value, pkIndexes []int) string {
+	var key strings.Builder
+	key.WriteString(table)
+	for _, idx := range pkIndexes {
+		key.WriteByte(0)
+		if row[idx].IsNull() {
+			key.WriteByte('-')
+			continue
+		}
+		raw := row[idx].Raw()
+		key.WriteString(strconv.Itoa(len(raw)))
+		key.WriteByte(':')
+		key.Write(raw)
+	}
+	return key.String()
+}
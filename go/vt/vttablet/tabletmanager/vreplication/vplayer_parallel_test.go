@@ -0,0 +1,348 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/sqlerror"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/sqlparser"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+func TestRowEventKeys(t *testing.T) {
+	fields := []*querypb.Field{
+		{Name: "id", Type: querypb.Type_INT64},
+		{Name: "name", Type: querypb.Type_VARCHAR},
+		{Name: "val", Type: querypb.Type_VARCHAR},
+	}
+	row := func(id int64, name, val string) *querypb.Row {
+		return sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NewVarChar(name), sqltypes.NewVarChar(val)})
+	}
+	rowEvent := &binlogdatapb.RowEvent{
+		TableName: "t1",
+		RowChanges: []*binlogdatapb.RowChange{
+			{After: row(1, "a", "x")},
+			{Before: row(1, "a", "x"), After: row(1, "a", "y")},
+			{Before: row(2, "b", "x")},
+		},
+	}
+
+	tplan := &TablePlan{TargetName: "t1", Fields: fields, PKReferences: []string{"id"}}
+	keys, ok := rowEventKeys(tplan, rowEvent)
+	require.True(t, ok)
+	assert.Equal(t, []string{"t1\x001:1", "t1\x001:1", "t1\x001:1", "t1\x001:2"}, keys)
+
+	tplan = &TablePlan{TargetName: "t2", Fields: fields, PKReferences: []string{"id", "name"}}
+	keys, ok = rowEventKeys(tplan, rowEvent)
+	require.True(t, ok)
+	assert.Equal(t, "t2\x001:1\x001:a", keys[0])
+	assert.Equal(t, "t2\x001:2\x001:b", keys[3])
+
+	// the values of the key are delimited
+	rowEvent = &binlogdatapb.RowEvent{
+		TableName: "t1",
+		RowChanges: []*binlogdatapb.RowChange{
+			{After: row(1, "a\x001:b", "x")},
+			{After: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NULL, sqltypes.NewVarChar("x")})},
+		},
+	}
+	keys, ok = rowEventKeys(tplan, rowEvent)
+	require.True(t, ok)
+	assert.Equal(t, []string{"t2\x001:1\x005:a\x001:b", "t2\x001:1\x00-"}, keys)
+
+	// the changes of a table without a primary key are not applied concurrently
+	_, ok = rowEventKeys(&TablePlan{TargetName: "t3", Fields: fields}, rowEvent)
+	assert.False(t, ok)
+}
+
+func TestParallelApplierSend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pa := &parallelApplier{
+		ctx:     ctx,
+		cancel:  cancel,
+		txs:     make(chan *parallelTx, 10),
+		writers: make(map[string]*parallelTx),
+	}
+
+	tx1 := &parallelTx{keys: []string{"t1\x001:1", "t1\x001:2"}}
+	tx2 := &parallelTx{keys: []string{"t1\x001:3"}}
+	tx3 := &parallelTx{keys: []string{"t1\x001:2", "t1\x001:3", "t1\x001:3"}}
+	for _, tx := range []*parallelTx{tx1, tx2, tx3} {
+		require.NoError(t, pa.send(tx))
+	}
+	assert.Nil(t, tx1.prev)
+	assert.Nil(t, tx1.after)
+	assert.Equal(t, tx1, tx2.prev)
+	assert.Nil(t, tx2.after, "tx2 changes other rows than tx1")
+	assert.Equal(t, tx2, tx3.prev)
+	assert.Equal(t, tx2, tx3.after, "tx3 is applied after the last transaction changing its rows")
+
+	// the transactions committed are not waited for anymore
+	close(tx1.done)
+	close(tx2.done)
+	tx4 := &parallelTx{keys: []string{"t1\x001:1"}}
+	require.NoError(t, pa.send(tx4))
+	assert.Nil(t, tx4.after)
+	tx5 := &parallelTx{keys: []string{"t1\x001:1", "t1\x001:3"}}
+	require.NoError(t, pa.send(tx5))
+	assert.Equal(t, tx4, tx5.after)
+
+	close(tx3.done)
+	close(tx4.done)
+	close(tx5.done)
+	require.NoError(t, pa.drain())
+	assert.Nil(t, pa.last)
+	assert.Empty(t, pa.writers)
+
+	// a failure of a worker stops the applier
+	pa.fail(fmt.Errorf("failed"))
+	assert.EqualError(t, pa.error(), "failed")
+}
+
+// fakeParallelDB is a table t1(id, val) whose changes are visible once committed,
+// for the parallel workers to be tested without MySQL.
+type fakeParallelDB struct {
+	mu   sync.Mutex
+	rows map[string]string
+	// seen is the value of the row of each update when it was executed.
+	seen []string
+	// committed are the changes of the committed transactions.
+	committed [][]string
+	// failures are the errors returned by the next executions of a query.
+	failures map[string][]error
+	// delays are how long the executions of a query take.
+	delays map[string]time.Duration
+}
+
+var (
+	fakeInsertRE = regexp.MustCompile(`^insert into t1\(id, val\) values \((\d+), '(\w+)'\)$`)
+	fakeUpdateRE = regexp.MustCompile(`^update t1 set val = '(\w+)' where id = (\d+)$`)
+)
+
+type fakeParallelConn struct {
+	db      *fakeParallelDB
+	changes []string
+}
+
+func (c *fakeParallelConn) DBName() string { return "db" }
+func (c *fakeParallelConn) Connect() error { return nil }
+func (c *fakeParallelConn) Close()         {}
+
+func (c *fakeParallelConn) Begin() error {
+	c.changes = nil
+	return nil
+}
+
+func (c *fakeParallelConn) Rollback() error {
+	c.changes = nil
+	return nil
+}
+
+func (c *fakeParallelConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for _, change := range c.changes {
+		if m := fakeInsertRE.FindStringSubmatch(change); m != nil {
+			c.db.rows[m[1]] = m[2]
+		} else if m := fakeUpdateRE.FindStringSubmatch(change); m != nil {
+			c.db.rows[m[2]] = m[1]
+		}
+	}
+	c.db.committed = append(c.db.committed, c.changes)
+	c.changes = nil
+	return nil
+}
+
+func (c *fakeParallelConn) ExecuteFetch(query string, maxrows int) (*sqltypes.Result, error) {
+	if strings.HasPrefix(query, "update _vt.vreplication") || strings.HasPrefix(query, "set ") {
+		return &sqltypes.Result{}, nil
+	}
+	db := c.db
+	db.mu.Lock()
+	if errs := db.failures[query]; len(errs) > 0 {
+		db.failures[query] = errs[1:]
+		db.mu.Unlock()
+		return nil, errs[0]
+	}
+	delay := db.delays[query]
+	db.mu.Unlock()
+	time.Sleep(delay)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if m := fakeUpdateRE.FindStringSubmatch(query); m != nil {
+		db.seen = append(db.seen, m[2]+":"+db.rows[m[2]])
+	}
+	c.changes = append(c.changes, query)
+	return &sqltypes.Result{RowsAffected: 1}, nil
+}
+
+func TestParallelApplierApply(t *testing.T) {
+	defer func(delay time.Duration) { dbLockRetryDelay = delay }(dbLockRetryDelay)
+	dbLockRetryDelay = 0
+
+	const (
+		insert1 = "insert into t1(id, val) values (1, 'a')"
+		insert2 = "insert into t1(id, val) values (2, 'x')"
+		updateB = "update t1 set val = 'b' where id = 1"
+		updateC = "update t1 set val = 'c' where id = 1"
+	)
+	db := &fakeParallelDB{
+		rows: make(map[string]string),
+		// the first insert is slow, for the updates of its row to be applied concurrently if they did not wait for it
+		delays: map[string]time.Duration{insert1: 50 * time.Millisecond},
+		// the last update conflicts with the transactions before it, and is applied again after them,
+		// and then hits a deadlock and is retried
+		failures: map[string][]error{updateC: {
+			sqlerror.NewSQLError(sqlerror.ERDupEntry, sqlerror.SSConstraintViolation, "Duplicate entry"),
+			sqlerror.NewSQLError(sqlerror.ERLockDeadlock, sqlerror.SSLockDeadlock, "Deadlock found"),
+		}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stats := binlogplayer.NewStats()
+	defer stats.Stop()
+	pa := &parallelApplier{
+		vp:      &vplayer{vr: &vreplicator{id: 1, stats: stats}},
+		txs:     make(chan *parallelTx, 4),
+		writers: make(map[string]*parallelTx),
+	}
+	pa.ctx, pa.cancel = context.WithCancel(ctx)
+	for i := 0; i < 4; i++ {
+		pa.wg.Add(1)
+		go pa.work(&parallelWorker{dbClient: newVDBClient(&fakeParallelConn{db: db}, stats)})
+	}
+	defer pa.close()
+
+	tplan := &TablePlan{
+		TargetName:   "t1",
+		Fields:       []*querypb.Field{{Name: "id", Type: querypb.Type_INT64}, {Name: "val", Type: querypb.Type_VARCHAR}},
+		PKReferences: []string{"id"},
+		Insert:       sqlparser.BuildParsedQuery("insert into t1(id, val) values (%a, %a)", ":a_id", ":a_val"),
+		Update:       sqlparser.BuildParsedQuery("update t1 set val = %a where id = %a", ":a_val", ":b_id"),
+	}
+	row := func(id int64, val string) *querypb.Row {
+		return sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NewVarChar(val)})
+	}
+	for _, change := range []*binlogdatapb.RowChange{
+		{After: row(1, "a")},
+		{Before: row(1, "a"), After: row(1, "b")},
+		{After: row(2, "x")},
+		{Before: row(1, "b"), After: row(1, "c")},
+	} {
+		rowEvent := &binlogdatapb.RowEvent{TableName: "t1", RowChanges: []*binlogdatapb.RowChange{change}}
+		keys, ok := rowEventKeys(tplan, rowEvent)
+		require.True(t, ok)
+		require.NoError(t, pa.send(&parallelTx{changes: []parallelRowEvent{{tplan: tplan, rowEvent: rowEvent}}, keys: keys}))
+	}
+	require.NoError(t, pa.drain())
+	require.NoError(t, pa.error())
+
+	// the updates saw the rows committed by the transactions before them
+	assert.Equal(t, []string{"1:a", "1:b"}, db.seen)
+	assert.Equal(t, map[string]string{"1": "c", "2": "x"}, db.rows)
+	// the transactions are committed in order, and the queries of the attempts rolled back are not retried
+	assert.Equal(t, [][]string{{insert1}, {updateB}, {insert2}, {updateC}}, db.committed)
+}
+
+func TestPlayerParallelApply(t *testing.T) {
+	doNotLogDBQueries = true
+	defer func() { doNotLogDBQueries = false }()
+	defer func(workers int) { vreplicationParallelApplyWorkers = workers }(vreplicationParallelApplyWorkers)
+	vreplicationParallelApplyWorkers = 4
+
+	defer deleteTablet(addTablet(100))
+	execStatements(t, []string{
+		"create table t1(id int, val varchar(128), primary key(id))",
+		fmt.Sprintf("create table %s.t1(id int, val varchar(128), primary key(id))", vrepldb),
+		"create table nopk(id int, val varchar(128))",
+		fmt.Sprintf("create table %s.nopk(id int, val varchar(128))", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table t1",
+		fmt.Sprintf("drop table %s.t1", vrepldb),
+		"drop table nopk",
+		fmt.Sprintf("drop table %s.nopk", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match: "/.*",
+		}},
+	}
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter:   filter,
+		OnDdl:    binlogdatapb.OnDDLAction_IGNORE,
+	}
+	cancel, _ := startVReplication(t, bls, "")
+	defer cancel()
+
+	var queries []string
+	var want [][]string
+	for i := 1; i <= 20; i++ {
+		queries = append(queries, fmt.Sprintf("insert into t1 values(%d, 'aaa')", i))
+	}
+	// the transactions changing the same rows are applied in order
+	for i := 1; i <= 20; i++ {
+		if i%2 == 1 {
+			queries = append(queries, fmt.Sprintf("update t1 set val='bbb' where id=%d", i))
+			want = append(want, []string{fmt.Sprint(i), "bbb"})
+			continue
+		}
+		queries = append(queries,
+			fmt.Sprintf("delete from t1 where id=%d", i),
+			fmt.Sprintf("insert into t1 values(%d, 'ccc')", i),
+		)
+		want = append(want, []string{fmt.Sprint(i), "ccc"})
+	}
+	// the transactions changing a table without a primary key are applied by the vplayer
+	queries = append(queries,
+		"insert into nopk values(1, 'aaa')",
+		"begin",
+		"insert into t1 values(21, 'ddd')",
+		"insert into nopk values(2, 'bbb')",
+		"commit",
+		"insert into t1 values(22, 'eee')",
+	)
+	want = append(want, []string{"21", "ddd"}, []string{"22", "eee"})
+	execStatements(t, queries)
+
+	expectData(t, "t1", want)
+	expectData(t, "nopk", [][]string{
+		{"1", "aaa"},
+		{"2", "bbb"},
+	})
+}
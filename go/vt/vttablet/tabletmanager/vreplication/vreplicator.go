@@ -113,6 +113,10 @@ type vreplicator struct {
 	WorkflowName    string
 
 	throttleUpdatesRateLimiter *timer.RateLimiter
+
+	// applier is set if the rows are applied by the applier of the workflow
+	// instead of the target database.
+	applier *applierSink
 }
 
 // newVReplicator creates a new vreplicator. The valid fields from the source are:
@@ -245,6 +249,8 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 	vr.throttleUpdatesRateLimiter = timer.NewRateLimiter(time.Second)
 	defer vr.throttleUpdatesRateLimiter.Stop()
 
+	defer vr.closeApplier()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -263,6 +269,9 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 		if err := vr.validateBinlogRowImage(); err != nil {
 			return err
 		}
+		if err := vr.openApplier(); err != nil {
+			return err
+		}
 
 		// If any of the operations below changed state to Stopped or Error, we should return.
 		if settings.State == binlogdatapb.VReplicationWorkflowState_Stopped || settings.State == binlogdatapb.VReplicationWorkflowState_Error {
@@ -295,7 +304,8 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 					}
 				}
 			}
-		case settings.StartPos.IsZero():
+		// A source stream has no rows to copy: it is read from the changes not committed yet.
+		case settings.StartPos.IsZero() && vr.source.SourceStream == "":
 			if err := newVCopier(vr).initTablesForCopy(ctx); err != nil {
 				vr.stats.ErrorCounts.Add([]string{"Copy"}, 1)
 				return err
@@ -317,6 +327,29 @@ func (vr *vreplicator) replicate(ctx context.Context) error {
 	}
 }
 
+// openApplier creates the applier of the workflow, if it has one.
+func (vr *vreplicator) openApplier() error {
+	if vr.applier != nil {
+		return nil
+	}
+	applier, err := newApplier(vr.source.Sink)
+	if err != nil || applier == nil {
+		return err
+	}
+	vr.applier = newApplierSink(applier)
+	return nil
+}
+
+func (vr *vreplicator) closeApplier() {
+	if vr.applier == nil {
+		return
+	}
+	if err := vr.applier.close(); err != nil {
+		log.Warningf("Failed to close the applier of workflow %s: %v", vr.WorkflowName, err)
+	}
+	vr.applier = nil
+}
+
 // ColumnInfo is used to store charset and collation
 type ColumnInfo struct {
 	Name        string
@@ -1379,7 +1379,12 @@ func (ts *trafficSwitcher) createReverseVReplication(ctx context.Context) error
 						// For non-reference tables we return an error if there's no primary
 						// vindex as it's not clear what to do.
 						if len(vtable.ColumnVindexes) > 0 && len(vtable.ColumnVindexes[0].Columns) > 0 {
-							inKeyrange = fmt.Sprintf(" where in_keyrange(%s, '%s.%s', '%s')", sqlparser.String(vtable.ColumnVindexes[0].Columns[0]),
+							// All the columns of a multi-column primary vindex are needed to compute the keyspace id.
+							vindexCols := make([]string, 0, len(vtable.ColumnVindexes[0].Columns))
+							for _, col := range vtable.ColumnVindexes[0].Columns {
+								vindexCols = append(vindexCols, sqlparser.String(col))
+							}
+							inKeyrange = fmt.Sprintf(" where in_keyrange(%s, '%s.%s', '%s')", strings.Join(vindexCols, ", "),
 								ts.SourceKeyspaceName(), vtable.ColumnVindexes[0].Name, key.KeyRangeString(source.GetShard().KeyRange))
 						} else {
 							return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "no primary vindex found for the %s table in the %s keyspace",